@@ -0,0 +1,250 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"strings"
+	"time"
+
+	"go.uber.org/zap"
+
+	"github.com/aegisx/aegisx/internal/auth"
+	"github.com/aegisx/aegisx/internal/config"
+	"github.com/aegisx/aegisx/internal/firewall"
+	"github.com/aegisx/aegisx/internal/ids"
+	"github.com/aegisx/aegisx/internal/lb"
+	"github.com/aegisx/aegisx/internal/policy"
+	"github.com/aegisx/aegisx/internal/store"
+	"github.com/aegisx/aegisx/internal/vpn"
+)
+
+// Check outcomes, in increasing severity.
+const (
+	checkOK   = "ok"
+	checkSkip = "skip"
+	checkWarn = "warn"
+	checkFail = "FAIL"
+)
+
+type checkResult struct {
+	name   string
+	status string
+	detail string
+}
+
+// doctor runs `aegisx-api doctor`: end-to-end readiness checks of the host
+// for installers. It changes nothing and exits 1 when a check fails.
+func doctor(args []string) int {
+	fs := flag.NewFlagSet("doctor", flag.ContinueOnError)
+	cfgFile := fs.String("config", os.Getenv("AEGISX_CONFIG"), "config file (default: search /etc/aegisx, $HOME/.aegisx, .)")
+	timeout := fs.Duration("timeout", 10*time.Second, "timeout for each network check")
+	if err := fs.Parse(args); err != nil {
+		return 2
+	}
+
+	results := runChecks(*cfgFile, *timeout)
+	return report(os.Stdout, results)
+}
+
+func runChecks(cfgFile string, timeout time.Duration) []checkResult {
+	var results []checkResult
+	add := func(name, status, format string, a ...any) {
+		results = append(results, checkResult{name: name, status: status, detail: fmt.Sprintf(format, a...)})
+	}
+	log := zap.NewNop()
+
+	// ── Config ────────────────────────────────────────────────────────────
+	cfg, err := config.Load(cfgFile)
+	if err != nil {
+		add("config", checkFail, "%v", err)
+		return results // nothing else can be checked without a config
+	}
+	for _, p := range configProblems(cfg) {
+		add("config", p.status, "%s", p.detail)
+	}
+	if _, err := auth.NewService(auth.Config{
+		JWTSecret:         cfg.Auth.JWTSecret,
+		JWTExpiry:         cfg.Auth.JWTExpiry,
+		AdminUser:         cfg.Auth.AdminUser,
+		AdminPassword:     cfg.Auth.AdminPassword,
+		AdminPasswordHash: cfg.Auth.AdminPasswordHash,
+	}); err != nil {
+		add("config", checkFail, "auth: %v", err)
+	} else {
+		add("config", checkOK, "loaded; auth settings valid")
+	}
+
+	// ── Database and migrations ──────────────────────────────────────────
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	db, err := store.Connect(ctx, cfg.Database, log)
+	cancel()
+	if err != nil {
+		add("database", checkFail, "%v", err)
+		add("migrations", checkSkip, "database unreachable")
+	} else {
+		add("database", checkOK, "connected")
+		ctx, cancel := context.WithTimeout(context.Background(), timeout)
+		applied, missing, err := db.CheckSchema(ctx, cfg.Database.MigrationsPath)
+		cancel()
+		switch {
+		case err != nil:
+			add("migrations", checkFail, "%v", err)
+		case len(missing) > 0:
+			add("migrations", checkFail, "%d applied, pending: %s", applied, strings.Join(missing, ", "))
+		default:
+			add("migrations", checkOK, "%d applied", applied)
+		}
+		db.Close()
+	}
+
+	// ── nftables ──────────────────────────────────────────────────────────
+	if cfg.Firewall.Backend != "nftables" {
+		add("nftables", checkSkip, "firewall backend is %s", cfg.Firewall.Backend)
+	} else if !binaryPresent("nft") {
+		add("nftables", checkFail, "nft not found in PATH")
+	} else {
+		fw := firewall.NewAdapter(cfg.Firewall.TableName, cfg.Firewall.RollbackDir, false, log)
+		if err := fw.Check(sampleIR()); err != nil {
+			add("nftables", checkFail, "sample ruleset rejected: %v", err)
+		} else {
+			add("nftables", checkOK, "sample ruleset accepted by nft -c")
+		}
+	}
+
+	// ── WireGuard ─────────────────────────────────────────────────────────
+	if !cfg.VPN.Enabled {
+		add("wireguard", checkSkip, "VPN disabled")
+	} else if err := vpn.KernelSupport(); err != nil {
+		add("wireguard", checkFail, "%v", err)
+	} else if !binaryPresent("wg-quick") {
+		add("wireguard", checkFail, "wg-quick not found in PATH (install wireguard-tools)")
+	} else {
+		add("wireguard", checkOK, "kernel module available")
+	}
+
+	// ── Suricata ──────────────────────────────────────────────────────────
+	if !cfg.IDS.Enabled {
+		add("suricata", checkSkip, "IDS disabled")
+	} else {
+		adapter := ids.NewAdapter(ids.Config{SocketPath: cfg.IDS.SocketPath}, log)
+		if err := adapter.Ping(); err != nil {
+			add("suricata", checkFail, "%v", err)
+		} else {
+			add("suricata", checkOK, "answering on %s", cfg.IDS.SocketPath)
+		}
+	}
+
+	// ── HAProxy ───────────────────────────────────────────────────────────
+	switch {
+	case !cfg.LB.Enabled:
+		add("haproxy", checkSkip, "load balancer disabled")
+	case cfg.LB.Backend != "haproxy":
+		add("haproxy", checkSkip, "load balancer backend is %s", cfg.LB.Backend)
+	case !binaryPresent("haproxy"):
+		add("haproxy", checkWarn, "haproxy not found in PATH; load balancer policies cannot be applied")
+	case !fileExists(cfg.LB.ConfigPath):
+		add("haproxy", checkWarn, "%s not rendered yet", cfg.LB.ConfigPath)
+	default:
+		adapter := lb.NewAdapter(cfg.LB.ConfigPath, cfg.LB.StatsSocket, cfg.LB.StatsPass,
+			"", cfg.LB.UDPConfigPath, cfg.LB.ErrorsDir, log)
+		if err := adapter.CheckConfig(); err != nil {
+			add("haproxy", checkFail, "%v", err)
+		} else {
+			add("haproxy", checkOK, "%s is valid", cfg.LB.ConfigPath)
+		}
+	}
+
+	return results
+}
+
+// configProblems flags settings that load fine but are unsafe or point at
+// missing files.
+func configProblems(cfg *config.Config) []checkResult {
+	var out []checkResult
+	add := func(status, format string, a ...any) {
+		out = append(out, checkResult{status: status, detail: fmt.Sprintf(format, a...)})
+	}
+	if n := len(cfg.Auth.JWTSecret); n > 0 && n < 32 {
+		add(checkWarn, "auth.jwt_secret is only %d bytes; use at least 32", n)
+	}
+	switch {
+	case cfg.Auth.AdminPasswordHash != "":
+	case cfg.Auth.AdminPassword == "":
+		add(checkWarn, "no admin credential; the API will start in setup mode")
+	case cfg.Auth.AdminPassword == "changeme":
+		add(checkWarn, "auth.admin_password is the default")
+	default:
+		add(checkWarn, "auth.admin_password is plaintext; prefer auth.admin_password_hash")
+	}
+	if (cfg.Server.TLSCert == "") != (cfg.Server.TLSKey == "") {
+		add(checkFail, "server.tls_cert and server.tls_key must be set together")
+	}
+	for _, f := range []string{cfg.Server.TLSCert, cfg.Server.TLSKey} {
+		if f != "" && !fileExists(f) {
+			add(checkFail, "%s does not exist", f)
+		}
+	}
+	switch cfg.Firewall.Backend {
+	case "nftables", "iptables":
+	default:
+		add(checkFail, "firewall.backend %q must be nftables or iptables", cfg.Firewall.Backend)
+	}
+	if cfg.Auth.SCIM.Enabled && cfg.Auth.SCIM.Token == "" {
+		add(checkFail, "auth.scim.token is required when SCIM is enabled")
+	}
+	return out
+}
+
+// sampleIR is a minimal ruleset that exercises the nftables template.
+func sampleIR() *policy.IR {
+	return &policy.IR{
+		ID:        "doctor",
+		CreatedAt: time.Now(),
+		FirewallRules: []policy.CompiledFirewallRule{{
+			Priority: 100,
+			Chain:    "input",
+			Action:   "accept",
+			Protocol: "tcp",
+			DstPorts: []string{"22"},
+			States:   []string{"new"},
+			Comment:  "aegisx doctor",
+		}},
+	}
+}
+
+// report prints the results and returns the process exit code.
+func report(w io.Writer, results []checkResult) int {
+	counts := map[string]int{}
+	fmt.Fprintln(w, "AegisX doctor")
+	fmt.Fprintln(w)
+	for _, r := range results {
+		counts[r.status]++
+		fmt.Fprintf(w, "  [%-4s] %-11s %s\n", r.status, r.name, r.detail)
+	}
+	fmt.Fprintln(w)
+
+	verdict := "ready"
+	if counts[checkFail] > 0 {
+		verdict = "not ready"
+	}
+	fmt.Fprintf(w, "%s: %d ok, %d warnings, %d failed, %d skipped\n",
+		verdict, counts[checkOK], counts[checkWarn], counts[checkFail], counts[checkSkip])
+	if counts[checkFail] > 0 {
+		return 1
+	}
+	return 0
+}
+
+func binaryPresent(name string) bool {
+	_, err := exec.LookPath(name)
+	return err == nil
+}
+
+func fileExists(path string) bool {
+	_, err := os.Stat(path)
+	return err == nil
+}
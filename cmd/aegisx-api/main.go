@@ -4,6 +4,9 @@ package main
 
 import (
 	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/json"
 	"fmt"
 	"net/http"
 	"os"
@@ -11,18 +14,50 @@ import (
 	"syscall"
 	"time"
 
+	"github.com/google/uuid"
 	"go.uber.org/zap"
 
+	"github.com/aegisx/aegisx/internal/admission"
 	"github.com/aegisx/aegisx/internal/api"
 	"github.com/aegisx/aegisx/internal/auth"
+	"github.com/aegisx/aegisx/internal/backup"
+	"github.com/aegisx/aegisx/internal/ban"
+	"github.com/aegisx/aegisx/internal/blob"
+	"github.com/aegisx/aegisx/internal/blocklist"
 	"github.com/aegisx/aegisx/internal/config"
+	"github.com/aegisx/aegisx/internal/directory"
+	"github.com/aegisx/aegisx/internal/features"
 	"github.com/aegisx/aegisx/internal/firewall"
+	"github.com/aegisx/aegisx/internal/geoip"
+	"github.com/aegisx/aegisx/internal/honeypot"
+	"github.com/aegisx/aegisx/internal/hooks"
+	"github.com/aegisx/aegisx/internal/ids"
+	"github.com/aegisx/aegisx/internal/ifstats"
+	"github.com/aegisx/aegisx/internal/lb"
 	"github.com/aegisx/aegisx/internal/metrics"
+	"github.com/aegisx/aegisx/internal/monitor"
+	"github.com/aegisx/aegisx/internal/policy"
+	"github.com/aegisx/aegisx/internal/retention"
+	"github.com/aegisx/aegisx/internal/scim"
+	"github.com/aegisx/aegisx/internal/setup"
+	"github.com/aegisx/aegisx/internal/signing"
+	"github.com/aegisx/aegisx/internal/speedtest"
 	"github.com/aegisx/aegisx/internal/store"
+	"github.com/aegisx/aegisx/internal/supervisor"
+	"github.com/aegisx/aegisx/internal/timesync"
+	"github.com/aegisx/aegisx/internal/vpn"
 	"github.com/aegisx/aegisx/pkg/logger"
+	"github.com/aegisx/aegisx/pkg/plugin"
+	"github.com/aegisx/aegisx/pkg/version"
 )
 
 func main() {
+	if len(os.Args) > 1 && os.Args[1] == "doctor" {
+		os.Exit(doctor(os.Args[2:]))
+	}
+	if len(os.Args) > 1 && os.Args[1] == "observability" {
+		os.Exit(observabilityCmd(os.Args[2:]))
+	}
 	if err := run(); err != nil {
 		fmt.Fprintf(os.Stderr, "fatal: %v\n", err)
 		os.Exit(1)
@@ -44,7 +79,9 @@ func run() error {
 	}
 	defer log.Sync()
 
-	log.Info("AegisX starting", zap.String("version", "0.1.0"))
+	build := version.Get()
+	log.Info("AegisX starting", zap.String("version", build.Version), zap.String("commit", build.Commit),
+		zap.String("build_time", build.BuildTime), zap.String("profile", cfg.Profile))
 
 	// ── Database ──────────────────────────────────────────────────────────
 	ctx := context.Background()
@@ -58,28 +95,312 @@ func run() error {
 		return fmt.Errorf("migrations: %w", err)
 	}
 
+	// ── First-run setup ───────────────────────────────────────────────────
+	// Without an admin credential only the setup endpoint is served; once
+	// it has written the config, startup continues with the new settings.
+	if setup.Needed(cfg) {
+		if cfg, err = runSetup(ctx, cfg, db, log); err != nil || cfg == nil {
+			return err
+		}
+	}
+	if cfg.Auth.AdminPassword != "" && cfg.Auth.AdminPasswordHash == "" {
+		log.Warn("auth.admin_password is stored in plaintext; prefer auth.admin_password_hash")
+	}
+
 	// ── Services ──────────────────────────────────────────────────────────
 	policyStore := store.NewPolicyStore(db)
+	namespaceStore := store.NewNamespaceStore(db)
+	vpnStore := store.NewVPNStore(db)
+	lbStore := store.NewLBStore(db)
+	idsStore := store.NewIDSStore(db)
+	eventStore := store.NewEventStore(db)
+	changeStore := store.NewChangeStore(db)
+	banStore := store.NewBanStore(db)
+	blockListStore := store.NewBlockListStore(db)
+	monitorStore := store.NewMonitorStore(db)
+	wanTestStore := store.NewWANTestStore(db)
+	retentionStore := store.NewRetentionStore(db)
+	impersonationStore := store.NewImpersonationStore(db)
+	freezeStore := store.NewFreezeStore(db)
+	readOnlyStore := store.NewReadOnlyStore(db)
+	breakGlassStore := store.NewBreakGlassStore(db)
+	auditStore := store.NewAuditStore(db)
+
+	// ── Object storage ────────────────────────────────────────────────────
+	sc := cfg.Storage
+	blobStore, err := blob.New(blob.Config{
+		Backend: sc.Backend,
+		Dir:     sc.Dir,
+		S3: blob.S3Config{
+			Endpoint:            sc.S3.Endpoint,
+			Region:              sc.S3.Region,
+			Bucket:              sc.S3.Bucket,
+			Prefix:              sc.S3.Prefix,
+			PathStyle:           sc.S3.PathStyle,
+			AccessKeyID:         sc.S3.AccessKeyID,
+			SecretAccessKey:     sc.S3.SecretAccessKey,
+			SecretAccessKeyFile: sc.S3.SecretAccessKeyFile,
+			SSE:                 sc.S3.SSE,
+			KMSKeyID:            sc.S3.KMSKeyID,
+		},
+	})
+	if err != nil {
+		return fmt.Errorf("storage: %w", err)
+	}
+	log.Info("object storage ready", zap.String("backend", sc.Backend))
+	backupMgr := backup.NewManager(store.NewBackupStore(db), blob.WithPrefix(blobStore, cfg.Backup.Prefix), log)
 
 	authSvc, err := auth.NewService(auth.Config{
-		JWTSecret:     cfg.Auth.JWTSecret,
-		JWTExpiry:     cfg.Auth.JWTExpiry,
-		AdminUser:     cfg.Auth.AdminUser,
-		AdminPassword: cfg.Auth.AdminPassword,
+		JWTSecret:         cfg.Auth.JWTSecret,
+		JWTExpiry:         cfg.Auth.JWTExpiry,
+		AdminUser:         cfg.Auth.AdminUser,
+		AdminPassword:     cfg.Auth.AdminPassword,
+		AdminPasswordHash: cfg.Auth.AdminPasswordHash,
 	})
 	if err != nil {
 		return fmt.Errorf("auth service: %w", err)
 	}
+	if sc := cfg.Auth.Sessions; sc.IdleTimeout > 0 || sc.MaxPerUser > 0 {
+		authSvc.UseSessions(store.NewLoginSessionStore(db), auth.SessionConfig{
+			IdleTimeout: sc.IdleTimeout,
+			MaxPerUser:  sc.MaxPerUser,
+		})
+		log.Info("login sessions tracked",
+			zap.Duration("idle_timeout", sc.IdleTimeout), zap.Int("max_per_user", sc.MaxPerUser))
+	}
+
+	var passkeys *auth.Passkeys
+	if cfg.Auth.WebAuthn.Enabled {
+		passkeys, err = auth.NewPasskeys(authSvc, auth.WebAuthnConfig{
+			RPID:          cfg.Auth.WebAuthn.RPID,
+			RPDisplayName: cfg.Auth.WebAuthn.RPDisplayName,
+			RPOrigins:     cfg.Auth.WebAuthn.RPOrigins,
+			SecondFactor:  cfg.Auth.WebAuthn.SecondFactor,
+		}, store.NewWebAuthnStore(db))
+		if err != nil {
+			return fmt.Errorf("passkeys: %w", err)
+		}
+		log.Info("passkey login enabled",
+			zap.String("rp_id", cfg.Auth.WebAuthn.RPID),
+			zap.Bool("second_factor", cfg.Auth.WebAuthn.SecondFactor))
+	}
+
+	var (
+		userStore  *store.UserStore
+		scimMapper *scim.Mapper
+	)
+	if cfg.Auth.SCIM.Enabled {
+		if cfg.Auth.SCIM.Token == "" {
+			return fmt.Errorf("scim: auth.scim.token is required")
+		}
+		var err error
+		scimMapper, err = groupMapper(cfg.Auth.SCIM.GroupMappings, cfg.Auth.SCIM.DefaultTenant, cfg.Auth.SCIM.DefaultRole)
+		if err != nil {
+			return fmt.Errorf("scim: %w", err)
+		}
+		userStore = store.NewUserStore(db)
+		authSvc.UseDirectory(userStore)
+		log.Info("scim provisioning enabled", zap.Int("group_mappings", len(cfg.Auth.SCIM.GroupMappings)))
+	}
+
+	var ldapSync *directory.Syncer
+	if lc := cfg.Auth.LDAP; lc.Enabled {
+		mapper, err := groupMapper(lc.GroupMappings, lc.DefaultTenant, lc.DefaultRole)
+		if err != nil {
+			return fmt.Errorf("ldap: %w", err)
+		}
+		var tlsCfg *tls.Config
+		if lc.CAFile != "" {
+			ca, err := os.ReadFile(lc.CAFile)
+			if err != nil {
+				return fmt.Errorf("ldap: ca_file: %w", err)
+			}
+			pool := x509.NewCertPool()
+			if !pool.AppendCertsFromPEM(ca) {
+				return fmt.Errorf("ldap: ca_file: no certificates in %s", lc.CAFile)
+			}
+			tlsCfg = &tls.Config{RootCAs: pool}
+		}
+		ldapUsers := store.NewUserStore(db)
+		ldapSync, err = directory.NewSyncer(directory.Config{
+			URL:             lc.URL,
+			StartTLS:        lc.StartTLS,
+			TLS:             tlsCfg,
+			BindDN:          lc.BindDN,
+			BindPassword:    lc.BindPassword,
+			Timeout:         lc.Timeout,
+			UserBaseDN:      lc.UserBaseDN,
+			UserFilter:      lc.UserFilter,
+			UsernameAttr:    lc.UsernameAttr,
+			EmailAttr:       lc.EmailAttr,
+			DisplayNameAttr: lc.DisplayNameAttr,
+			GroupBaseDN:     lc.GroupBaseDN,
+			GroupFilter:     lc.GroupFilter,
+			GroupNameAttr:   lc.GroupNameAttr,
+			MemberAttr:      lc.MemberAttr,
+		}, ldapUsers, mapper, log)
+		if err != nil {
+			return fmt.Errorf("ldap: %w", err)
+		}
+		if userStore == nil {
+			authSvc.UseDirectory(ldapUsers)
+		}
+		authSvc.UseLDAP(ldapSync)
+		log.Info("ldap login enabled",
+			zap.String("url", lc.URL), zap.Int("group_mappings", len(lc.GroupMappings)))
+	}
+
+	// ── Plugins ───────────────────────────────────────────────────────────
+	// Kinds must be registered before the first manifest is parsed.
+	plugins := plugin.NewRegistry()
+	if cfg.Plugins.Enabled {
+		loaded, err := plugins.LoadDir(cfg.Plugins.Dir)
+		if err != nil {
+			return fmt.Errorf("plugins: %w", err)
+		}
+		for _, k := range plugins.Kinds() {
+			if err := policy.RegisterKind(k); err != nil {
+				return fmt.Errorf("plugins: %w", err)
+			}
+		}
+		log.Info("plugins loaded", zap.Strings("files", loaded),
+			zap.Int("kinds", len(plugins.Kinds())), zap.Int("backends", len(plugins.Backends())))
+	}
+
+	// ── Features ──────────────────────────────────────────────────────────
+	// Without a license the community features keep working; a bad license
+	// is logged rather than fatal for the same reason.
+	var license *features.License
+	if cfg.Features.LicensePublicKey != "" {
+		if _, statErr := os.Stat(cfg.Features.LicensePath); statErr == nil {
+			license, err = features.LoadLicense(cfg.Features.LicensePath, cfg.Features.LicensePublicKey)
+			if err != nil {
+				log.Error("license rejected", zap.String("path", cfg.Features.LicensePath), zap.Error(err))
+			}
+		}
+	}
+	featureSet := features.NewSet(cfg.Features.Flags, nil, log)
+	featureSet.SetLicense(license)
+
+	// ── Clock ─────────────────────────────────────────────────────────────
+	clock := timesync.NewMonitor(timesync.Config{
+		ConfPath: cfg.Time.ChronyConfPath,
+		Servers:  cfg.Time.Servers,
+		Pools:    cfg.Time.Pools,
+		MaxSkew:  cfg.Time.MaxSkew,
+		Interval: cfg.Time.CheckInterval,
+	}, log)
+	if cfg.Time.ManageChrony {
+		if err := clock.WriteConfig(); err != nil {
+			log.Error("configure chrony", zap.Error(err))
+		}
+	}
+	var clockCheck func() error
+	if cfg.Time.CheckInterval <= 0 {
+		clock = nil
+	} else if cfg.Time.RefuseUnsynced {
+		clockCheck = clock.Synced
+	}
+
+	// In ips mode the firewall steers accepted traffic to Suricata's queues.
+	var ipsQueue *firewall.IPSQueue
+	if cfg.IDS.Enabled && cfg.IDS.Mode == "ips" {
+		ipsQueue = &firewall.IPSQueue{
+			Num:      cfg.IDS.QueueNum,
+			Count:    cfg.IDS.QueueCount,
+			FailOpen: cfg.IDS.FailOpen,
+			Hooks:    cfg.IDS.QueueHooks,
+		}
+		log.Info("ips queue enabled",
+			zap.Int("queue_num", ipsQueue.Num), zap.Int("queue_count", ipsQueue.Count),
+			zap.Bool("fail_open", ipsQueue.FailOpen))
+	}
+
+	var scan *firewall.ScanDetection
+	if sd := cfg.Firewall.ScanDetection; sd.Enabled {
+		switch sd.Action {
+		case "log", "throttle", "block":
+		default:
+			return fmt.Errorf("firewall.scan_detection.action %q: must be log, throttle or block", sd.Action)
+		}
+		scan = &firewall.ScanDetection{
+			PortsPerMinute: sd.PortsPerMinute,
+			Action:         sd.Action,
+			Cooldown:       sd.Cooldown,
+			ThrottleRate:   sd.ThrottleRate,
+			Exempt:         sd.Exempt,
+		}
+	}
+
+	hookList := make([]hooks.Hook, len(cfg.Hooks))
+	for i, h := range cfg.Hooks {
+		hookList[i] = hooks.Hook{Name: h.Name, Event: h.Event, Command: h.Command, URL: h.URL, Timeout: h.Timeout}
+	}
+	hookRunner, err := hooks.NewRunner(hookList, log)
+	if err != nil {
+		return fmt.Errorf("hooks: %w", err)
+	}
+
+	tarpitPort := 0
+	if cfg.Honeypot.Enabled {
+		tarpitPort = cfg.Honeypot.Port
+	}
 
 	firewallSvc := firewall.NewService(firewall.ServiceConfig{
-		TableName:   cfg.Firewall.TableName,
-		RollbackDir: cfg.Firewall.RollbackDir,
-		PolicyDir:   cfg.Firewall.PolicyDir,
-		DryRun:      cfg.Firewall.DryRun,
+		TableName:     cfg.Firewall.TableName,
+		RollbackDir:   cfg.Firewall.RollbackDir,
+		PolicyDir:     cfg.Firewall.PolicyDir,
+		DryRun:        cfg.Firewall.DryRun,
+		IPS:           ipsQueue,
+		TarpitPort:    tarpitPort,
+		Scan:          scan,
+		Bans:          cfg.Bans.Enabled,
+		BlockLists:    cfg.BlockList.Enabled,
+		ClockCheck:    clockCheck,
+		Hooks:         hookRunner,
+		UnusedAfter:   cfg.Firewall.HitAnalysis.UnusedAfter,
+		ApplyTimeout:  cfg.Firewall.ApplyTimeout,
+		FQDNTimeout:   cfg.Firewall.FQDN.Timeout,
+		NetNSDirs:     cfg.Firewall.NetNS.Dirs,
+		StrictParsing: cfg.Firewall.StrictParsing,
+		Limits: policy.Limits{
+			MaxRulesPerChain: cfg.Firewall.Limits.MaxRulesPerChain,
+			MaxSetElements:   cfg.Firewall.Limits.MaxSetElements,
+			MaxRenderBytes:   cfg.Firewall.Limits.MaxRenderBytes,
+		},
 	}, log)
 
+	for _, b := range plugins.Backends() {
+		b := b
+		firewallSvc.OnApply(func(ir *policy.IR) {
+			data, err := json.Marshal(ir)
+			if err != nil {
+				log.Error("encode ir for plugin backend", zap.String("backend", b.Name()), zap.Error(err))
+				return
+			}
+			applyCtx, cancel := context.WithTimeout(ctx, cfg.Plugins.ApplyTimeout)
+			defer cancel()
+			if err := b.Apply(applyCtx, data); err != nil {
+				log.Error("plugin backend apply failed", zap.String("backend", b.Name()), zap.Error(err))
+			}
+		})
+	}
+
+	// ── Monitors ──────────────────────────────────────────────────────────
+	// Loaded before the first apply so rule conditions naming a monitor
+	// compile.
+	var monitorMgr *monitor.Manager
+	if cfg.Monitors.Enabled {
+		monitorMgr = monitor.NewManager(monitorStore, firewallSvc, log)
+		if err := monitorMgr.Load(ctx); err != nil {
+			return err
+		}
+		log.Info("monitors enabled", zap.Int("max", cfg.Monitors.Max))
+	}
+
 	// ── Metrics server ────────────────────────────────────────────────────
 	if cfg.Metrics.Enabled {
+		metrics.SetTenantAllowlist(cfg.Metrics.TenantLabels)
 		metricsSrv := metrics.NewServer(cfg.Metrics.Port, cfg.Metrics.Path)
 		go func() {
 			if err := metricsSrv.Start(); err != nil && err != http.ErrServerClosed {
@@ -98,14 +419,401 @@ func run() error {
 			zap.String("dir", cfg.Firewall.PolicyDir))
 	}
 
+	if ha := cfg.Firewall.HitAnalysis; ha.Enabled && !cfg.Firewall.DryRun {
+		go firewallSvc.WatchHits(reloadCtx, ha.PollInterval)
+		log.Info("rule hit sampling enabled",
+			zap.Duration("interval", ha.PollInterval), zap.Duration("unused_after", ha.UnusedAfter))
+	}
+
+	if vc := cfg.Firewall.Verify; vc.Enabled && !cfg.Firewall.DryRun {
+		go firewallSvc.WatchVerify(reloadCtx, vc.Interval)
+		log.Info("ruleset verification enabled", zap.Duration("interval", vc.Interval))
+	}
+
+	if fc := cfg.Firewall.FQDN; fc.RefreshInterval > 0 {
+		go firewallSvc.WatchFQDNs(reloadCtx, fc.RefreshInterval)
+	}
+	if nc := cfg.Firewall.NetNS; nc.WatchInterval > 0 {
+		go firewallSvc.WatchNetNS(reloadCtx, nc.WatchInterval)
+	}
+	if pc := cfg.Firewall.WANPaths; pc.Interval > 0 {
+		go firewallSvc.WatchWANPaths(reloadCtx, pc.Interval)
+	}
+	go firewallSvc.WatchExpiry(reloadCtx)
+	if ldapSync != nil && cfg.Auth.LDAP.SyncInterval > 0 {
+		go ldapSync.Run(reloadCtx, cfg.Auth.LDAP.SyncInterval)
+	}
+
+	if clock != nil {
+		go clock.Run(reloadCtx)
+		log.Info("clock checks enabled",
+			zap.Duration("max_skew", cfg.Time.MaxSkew), zap.Bool("refuse_unsynced", cfg.Time.RefuseUnsynced))
+	}
+
+	// ── Supervised daemons ────────────────────────────────────────────────
+	// Started before the adapters that talk to them. They get their own
+	// context so they are stopped only once everything else has returned.
+	var daemons *supervisor.Supervisor
+	if sc := cfg.Supervisor; len(sc.Daemons) > 0 {
+		specs := make([]supervisor.Daemon, len(sc.Daemons))
+		for i, d := range sc.Daemons {
+			specs[i] = supervisor.Daemon{Name: d.Name, Command: d.Command, ReloadSignal: d.ReloadSignal}
+		}
+		daemons, err = supervisor.New(specs, supervisor.Config{
+			MinBackoff:  sc.MinBackoff,
+			MaxBackoff:  sc.MaxBackoff,
+			StableAfter: sc.StableAfter,
+			StopTimeout: sc.StopTimeout,
+		}, hookRunner, log)
+		if err != nil {
+			return fmt.Errorf("supervisor: %w", err)
+		}
+		daemonCtx, stopDaemons := context.WithCancel(context.Background())
+		daemonsDone := make(chan struct{})
+		go func() {
+			daemons.Run(daemonCtx)
+			close(daemonsDone)
+		}()
+		defer func() {
+			stopDaemons()
+			<-daemonsDone
+		}()
+		log.Info("daemon supervision enabled", zap.Int("daemons", len(specs)))
+	}
+
+	// ── VPN ───────────────────────────────────────────────────────────────
+	vpnMgr := vpn.NewManager(cfg.VPN.Interface, cfg.VPN.ConfigPath, vpn.RelayBinaries{
+		UDP2Raw:  cfg.VPN.UDP2RawPath,
+		WSTunnel: cfg.VPN.WSTunnelPath,
+	}, log)
+	if cfg.VPN.Enabled {
+		collector := vpn.NewStatsCollector(vpnMgr, vpnStore, cfg.VPN.StatsInterval, log)
+		go collector.Run(reloadCtx)
+		log.Info("vpn stats collector started",
+			zap.Duration("interval", cfg.VPN.StatsInterval))
+	}
+
+	// ── Load balancer ─────────────────────────────────────────────────────
+	var (
+		lbAdapter   *lb.Adapter
+		lbCollector *lb.AccessLogCollector
+	)
+	if cfg.LB.Enabled {
+		lbAdapter = lb.NewAdapter(cfg.LB.ConfigPath, cfg.LB.StatsSocket, cfg.LB.StatsPass,
+			cfg.LB.AccessLogAddr, cfg.LB.UDPConfigPath, cfg.LB.ErrorsDir, log)
+		if daemons != nil && daemons.Manages("haproxy") {
+			lbAdapter.SetReloader(func() error { return daemons.Reload("haproxy") })
+		}
+		if maint, err := lbStore.ListMaintenance(ctx, nil); err != nil {
+			log.Warn("could not restore lb maintenance state", zap.Error(err))
+		} else {
+			names := make([]string, len(maint))
+			for i, m := range maint {
+				names[i] = m.Backend
+			}
+			lbAdapter.RestoreMaintenance(names)
+		}
+
+		if cfg.LB.AccessLogAddr != "" {
+			lbCollector = lb.NewAccessLogCollector(cfg.LB.AccessLogAddr, cfg.LB.MaxAnalyticsPairs, log)
+			go func() {
+				if err := lbCollector.Run(reloadCtx); err != nil {
+					log.Error("lb access log collector error", zap.Error(err))
+				}
+			}()
+			log.Info("lb access log collector started",
+				zap.String("addr", cfg.LB.AccessLogAddr))
+		}
+	}
+
+	// ── GeoIP ─────────────────────────────────────────────────────────────
+	// Firewall events and IDS alerts are enriched with the country and AS of
+	// their remote address when stored. Rules selecting countries match sets
+	// the ruleset declares empty; they are filled after every apply and when
+	// the database is updated.
+	var geo *geoip.Resolver
+	if cfg.GeoIP.CountryDB != "" || cfg.GeoIP.ASNDB != "" {
+		geo = geoip.NewResolver(geoip.Config{
+			CountryDB: cfg.GeoIP.CountryDB,
+			ASNDB:     cfg.GeoIP.ASNDB,
+		}, log)
+		countrySets := geoip.NewCountrySets(geo, geoip.SetsConfig{
+			Table:         cfg.Firewall.TableName,
+			DryRun:        cfg.Firewall.DryRun,
+			CheckInterval: cfg.GeoIP.CheckInterval,
+		}, firewallSvc.CurrentIR, log)
+		firewallSvc.OnApply(func(ir *policy.IR) {
+			if err := countrySets.Sync(reloadCtx, ir); err != nil {
+				log.Error("fill country sets", zap.Error(err))
+			}
+		})
+		if err := countrySets.Sync(reloadCtx, firewallSvc.CurrentIR()); err != nil {
+			log.Error("fill country sets", zap.Error(err))
+		}
+		go countrySets.Run(reloadCtx)
+	}
+
+	// ── IDS ───────────────────────────────────────────────────────────────
+	// The suggester turns IDS alerts, honeypot probes and port scans into
+	// block proposals.
+	suggester := ids.NewSuggester(idsStore, geo, cfg.IDS.SuggestMinAlerts,
+		cfg.IDS.SuggestWindow, cfg.IDS.SuggestInterval, log)
+	if cfg.IDS.Enabled || cfg.Honeypot.Enabled || cfg.Firewall.ScanDetection.Enabled {
+		go suggester.Run(reloadCtx)
+	}
+
+	var idsAdapter *ids.Adapter
+	if cfg.IDS.Enabled {
+		idsAdapter = ids.NewAdapter(ids.Config{
+			ConfigPath:    cfg.IDS.ConfigPath,
+			RulesPath:     cfg.IDS.RulesPath,
+			SourceDir:     cfg.IDS.SourceDir,
+			ThresholdPath: cfg.IDS.ThresholdPath,
+			SocketPath:    cfg.IDS.SocketPath,
+			LogPath:       cfg.IDS.LogPath,
+			Mode:          cfg.IDS.Mode,
+		}, log)
+		idsAdapter.OnAlert(suggester.Record)
+		go func() {
+			if err := idsAdapter.TailAlerts(reloadCtx); err != nil && err != context.Canceled {
+				log.Error("ids alert tailer error", zap.Error(err))
+			}
+		}()
+		go idsAdapter.PollStats(reloadCtx, cfg.IDS.StatsInterval, cfg.IDS.LossThreshold, suggester.RecordLoss)
+		firewallSvc.OnApply(func(ir *policy.IR) {
+			if err := idsAdapter.ApplyIR(ir); err != nil {
+				log.Error("apply ids rules", zap.Error(err))
+			}
+		})
+		log.Info("ids alert collection started",
+			zap.String("log_path", cfg.IDS.LogPath))
+	}
+
+	if scan != nil && !cfg.Firewall.DryRun {
+		go firewallSvc.WatchScans(reloadCtx, cfg.Firewall.ScanDetection.PollInterval, suggester.RecordScan)
+		log.Info("port scan detection enabled",
+			zap.String("action", scan.Action), zap.Int("ports_per_minute", scan.PortsPerMinute))
+	}
+
+	// ── Firewall events ───────────────────────────────────────────────────
+	// Packets logged by rules are read back from the kernel log for the
+	// blocked-traffic analytics.
+	if ev := cfg.Firewall.Events; ev.Enabled && !cfg.Firewall.DryRun {
+		go func() {
+			err := firewallSvc.TailLog(reloadCtx, ev.LogPath, func(e firewall.LogEvent) {
+				info := geo.Remote(e.SrcIP, e.DstIP)
+				rec := &store.FirewallEvent{
+					Timestamp: e.At,
+					Rule:      e.Rule,
+					Action:    e.Action,
+					InIface:   e.InIface,
+					OutIface:  e.OutIface,
+					SrcIP:     e.SrcIP,
+					DstIP:     e.DstIP,
+					Protocol:  e.Protocol,
+					SrcPort:   e.SrcPort,
+					DstPort:   e.DstPort,
+					Country:   info.Country,
+					ASN:       info.ASN,
+					ASOrg:     info.ASOrg,
+				}
+				ctx, cancel := context.WithTimeout(reloadCtx, 5*time.Second)
+				defer cancel()
+				if err := eventStore.InsertFirewallEvent(ctx, rec); err != nil {
+					log.Warn("store firewall event", zap.Error(err))
+				}
+			})
+			if err != nil && err != context.Canceled {
+				log.Error("kernel log tailer error", zap.Error(err))
+			}
+		}()
+		log.Info("firewall event collection started", zap.String("log_path", ev.LogPath))
+	}
+
+	// ── Honeypot ──────────────────────────────────────────────────────────
+	if cfg.Honeypot.Enabled {
+		tarpit := honeypot.NewTarpit(honeypot.Config{
+			Port:        cfg.Honeypot.Port,
+			Delay:       cfg.Honeypot.Delay,
+			MaxConns:    cfg.Honeypot.MaxConns,
+			MaxDuration: cfg.Honeypot.MaxDuration,
+		}, log)
+		tarpit.OnProbe(suggester.RecordProbe)
+		go func() {
+			if err := tarpit.Run(reloadCtx); err != nil {
+				log.Error("honeypot tarpit error", zap.Error(err))
+			}
+		}()
+		log.Info("honeypot tarpit started", zap.Int("port", cfg.Honeypot.Port))
+	}
+
+	// ── Brute-force bans ──────────────────────────────────────────────────
+	var banMgr *ban.Manager
+	if cfg.Bans.Enabled {
+		banMgr, err = ban.NewManager(banStore, cfg.Firewall.TableName, cfg.Bans.Ignore, cfg.Firewall.DryRun, log)
+		if err != nil {
+			return fmt.Errorf("bans: %w", err)
+		}
+		for name, j := range map[string]config.JailConfig{ban.JailSSH: cfg.Bans.SSH, ban.JailAPI: cfg.Bans.API} {
+			if j.Enabled {
+				banMgr.AddJail(ban.Jail{Name: name, MaxRetry: j.MaxRetry, FindTime: j.FindTime,
+					BanTime: j.BanTime, MaxBanTime: j.MaxBanTime})
+			}
+		}
+		// A new ruleset starts with empty sets; put the active bans back.
+		firewallSvc.OnApply(func(*policy.IR) {
+			if err := banMgr.Restore(reloadCtx); err != nil {
+				log.Error("restore bans", zap.Error(err))
+			}
+		})
+		if cfg.Bans.SSH.Enabled {
+			go func() {
+				if err := banMgr.TailSSHD(reloadCtx, cfg.Bans.AuthLogPath); err != nil && err != context.Canceled {
+					log.Error("auth log tailer error", zap.Error(err))
+				}
+			}()
+		}
+		log.Info("brute-force protection enabled", zap.String("auth_log", cfg.Bans.AuthLogPath))
+	}
+
+	// ── Block lists ───────────────────────────────────────────────────────
+	var blockListMgr *blocklist.Manager
+	if bl := cfg.BlockList; bl.Enabled {
+		blockListMgr = blocklist.NewManager(blockListStore, blocklist.Config{
+			Table:        cfg.Firewall.TableName,
+			DryRun:       cfg.Firewall.DryRun,
+			MaxEntries:   bl.MaxEntries,
+			SyncInterval: bl.SyncInterval,
+			FetchTimeout: bl.FetchTimeout,
+			MaxFeedBytes: bl.MaxFeedBytes,
+		}, log)
+		// A new ruleset starts with empty sets; fill them again.
+		firewallSvc.OnApply(func(*policy.IR) {
+			if err := blockListMgr.Sync(reloadCtx); err != nil {
+				log.Error("restore blocklists", zap.Error(err))
+			}
+		})
+		go blockListMgr.Run(reloadCtx)
+		log.Info("block lists enabled", zap.Duration("sync_interval", bl.SyncInterval))
+	}
+
+	// ── WAN tests ─────────────────────────────────────────────────────────
+	var wanTests *speedtest.Runner
+	if wt := cfg.WANTest; wt.Enabled {
+		if wt.Method != speedtest.MethodHTTP && wt.Method != speedtest.MethodIperf3 {
+			return fmt.Errorf("wan_test.method %q: must be %s or %s", wt.Method, speedtest.MethodHTTP, speedtest.MethodIperf3)
+		}
+		wanTests = speedtest.NewRunner(wanTestStore, firewallSvc, speedtest.Config{
+			Method:        wt.Method,
+			LatencyTarget: wt.LatencyTarget,
+			Samples:       wt.Samples,
+			DownloadURL:   wt.DownloadURL,
+			UploadURL:     wt.UploadURL,
+			Iperf3Path:    wt.Iperf3Path,
+			Iperf3Server:  wt.Iperf3Server,
+			Duration:      wt.Duration,
+			Interval:      wt.Interval,
+		}, log)
+		go wanTests.Schedule(reloadCtx)
+		log.Info("WAN tests enabled", zap.String("method", wt.Method), zap.Duration("interval", wt.Interval))
+	}
+
+	// ── Interface statistics ──────────────────────────────────────────────
+	var ifStats *ifstats.Sampler
+	if is := cfg.IfStats; is.Enabled {
+		if is.Interval <= 0 {
+			return fmt.Errorf("interface_stats.interval %s: must be positive", is.Interval)
+		}
+		ifStats = ifstats.NewSampler(ifstats.Config{Interval: is.Interval, History: is.History}, log)
+		go ifStats.Run(reloadCtx)
+		log.Info("interface statistics enabled", zap.Duration("interval", is.Interval), zap.Duration("history", is.History))
+	}
+
+	// ── Retention ─────────────────────────────────────────────────────────
+	if rc := cfg.Retention; rc.Enabled {
+		policies := []retention.Policy{
+			{Table: store.TableIDSAlerts, MaxAge: rc.IDSAlerts.MaxAge, Archive: rc.IDSAlerts.Archive},
+			{Table: store.TableFirewallEvents, MaxAge: rc.FirewallEvents.MaxAge, Archive: rc.FirewallEvents.Archive},
+			{Table: store.TableVPNPeerStats, MaxAge: rc.FlowStats.MaxAge, Archive: rc.FlowStats.Archive},
+			{Table: store.TableMetricsSnapshots, MaxAge: rc.FlowStats.MaxAge, Archive: rc.FlowStats.Archive},
+			{Table: store.TableAuditLog, MaxAge: rc.Audit.MaxAge, Archive: rc.Audit.Archive},
+			{Table: store.TableMonitorEvents, MaxAge: rc.MonitorEvents.MaxAge, Archive: rc.MonitorEvents.Archive},
+			{Table: store.TableWANTests, MaxAge: rc.WANTests.MaxAge, Archive: rc.WANTests.Archive},
+		}
+		archive := blob.WithPrefix(blobStore, rc.ArchivePrefix)
+		retentionMgr, err := retention.NewManager(retentionStore, archive, retention.Config{
+			Interval:  rc.Interval,
+			BatchSize: rc.BatchSize,
+			Policies:  policies,
+		}, log)
+		if err != nil {
+			return fmt.Errorf("retention: %w", err)
+		}
+		go retentionMgr.Run(reloadCtx)
+		log.Info("retention enabled", zap.Duration("interval", rc.Interval))
+	}
+
+	// ── Admission rules ───────────────────────────────────────────────────
+	var admissionCtrl *admission.Controller
+	if cfg.Admission.Enabled {
+		admissionCtrl, err = admission.New(cfg.Admission.Dir, log)
+		if err != nil {
+			return fmt.Errorf("admission: %w", err)
+		}
+	}
+
+	// ── Manifest signatures ───────────────────────────────────────────────
+	var verifier *signing.Verifier
+	if len(cfg.Signing.Keys) > 0 || cfg.Signing.Enforce {
+		verifier, err = signing.New(cfg.Signing.Keys, cfg.Signing.Enforce)
+		if err != nil {
+			return fmt.Errorf("signing: %w", err)
+		}
+	}
+
 	// ── HTTP API server ───────────────────────────────────────────────────
 	srv := api.NewServer(api.ServerDeps{
-		Config:      cfg,
-		FirewallSvc: firewallSvc,
-		PolicyStore: policyStore,
-		AuthSvc:     authSvc,
-		Log:         log,
+		Config:         cfg,
+		FirewallSvc:    firewallSvc,
+		PolicyStore:    policyStore,
+		NamespaceStore: namespaceStore,
+		VPNStore:       vpnStore,
+		VPNManager:     vpnMgr,
+		LBAdapter:      lbAdapter,
+		LBStore:        lbStore,
+		LBCollector:    lbCollector,
+		IDSAdapter:     idsAdapter,
+		IDSStore:       idsStore,
+		EventStore:     eventStore,
+		GeoIP:          geo,
+		ChangeStore:    changeStore,
+		Impersonations: impersonationStore,
+		Freezes:        freezeStore,
+		ReadOnly:       readOnlyStore,
+		BreakGlass:     breakGlassStore,
+		Hooks:          hookRunner,
+		AuditStore:     auditStore,
+		BanManager:     banMgr,
+		BlockLists:     blockListMgr,
+		Monitors:       monitorMgr,
+		WANTests:       wanTests,
+		IfStats:        ifStats,
+		Daemons:        daemons,
+		Backups:        backupMgr,
+		Clock:          clock,
+		Features:       featureSet,
+		Admission:      admissionCtrl,
+		Signing:        verifier,
+		AuthSvc:        authSvc,
+		Passkeys:       passkeys,
+		UserStore:      userStore,
+		SCIMMapper:     scimMapper,
+		LDAP:           ldapSync,
+		Log:            log,
 	})
+	if cfg.Server.ReadOnly {
+		log.Warn("API is read-only", zap.String("reason", cfg.Server.ReadOnlyReason))
+	}
 
 	// ── Graceful shutdown ─────────────────────────────────────────────────
 	sigCh := make(chan os.Signal, 1)
@@ -131,3 +839,21 @@ func run() error {
 	log.Info("shutdown complete")
 	return nil
 }
+
+// groupMapper builds the mapper that gives directory users a tenant and role
+// by their groups.
+func groupMapper(groups []config.SCIMGroupMapping, defaultTenant, defaultRole string) (*scim.Mapper, error) {
+	fallback, err := uuid.Parse(defaultTenant)
+	if err != nil {
+		return nil, fmt.Errorf("default_tenant: %w", err)
+	}
+	mappings := make([]scim.Mapping, 0, len(groups))
+	for _, m := range groups {
+		tenantID, err := uuid.Parse(m.Tenant)
+		if err != nil {
+			return nil, fmt.Errorf("mapping %q: tenant: %w", m.Group, err)
+		}
+		mappings = append(mappings, scim.Mapping{Group: m.Group, TenantID: tenantID, Role: m.Role})
+	}
+	return scim.NewMapper(mappings, fallback, defaultRole)
+}
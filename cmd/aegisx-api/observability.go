@@ -0,0 +1,52 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+
+	"github.com/aegisx/aegisx/internal/observability"
+)
+
+// observabilityCmd runs `aegisx-api observability`: it writes the generated
+// Grafana dashboards, their provisioning config and the Prometheus alert
+// rules under -out, which defaults to the deploy directory docker-compose
+// mounts. `make observability` keeps the checked-in copies in sync.
+func observabilityCmd(args []string) int {
+	fs := flag.NewFlagSet("observability", flag.ContinueOnError)
+	out := fs.String("out", "deploy", "directory to write the provisioning files under")
+	if err := fs.Parse(args); err != nil {
+		return 2
+	}
+
+	b, err := observability.Generate()
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+	files, err := b.Files()
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+	names := make([]string, 0, len(files))
+	for name := range files {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		p := filepath.Join(*out, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(p), 0o755); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			return 1
+		}
+		if err := os.WriteFile(p, files[name], 0o644); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			return 1
+		}
+		fmt.Println(p)
+	}
+	return 0
+}
@@ -0,0 +1,72 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"go.uber.org/zap"
+
+	"github.com/aegisx/aegisx/internal/api"
+	"github.com/aegisx/aegisx/internal/config"
+	"github.com/aegisx/aegisx/internal/setup"
+	"github.com/aegisx/aegisx/internal/store"
+)
+
+// runSetup serves the first-run setup endpoint until the wizard has written
+// the config, then reloads it. It returns a nil config when interrupted.
+func runSetup(ctx context.Context, cfg *config.Config, db *store.DB, log *zap.Logger) (*config.Config, error) {
+	wizard, err := setup.NewWizard(cfg, store.NewTenantStore(db))
+	if err != nil {
+		return nil, fmt.Errorf("setup: %w", err)
+	}
+	srv := api.NewSetupServer(&cfg.Server, wizard, log)
+
+	log.Warn("no admin credential configured; serving first-run setup only",
+		zap.String("endpoint", "/api/v1/setup"), zap.String("config", wizard.Path()))
+	// The token goes to stdout only, so only whoever runs the server can
+	// complete setup.
+	fmt.Printf("AegisX setup token (send as X-Setup-Token): %s\n", wizard.Token())
+
+	sigCh := make(chan os.Signal, 1)
+	signal.Notify(sigCh, syscall.SIGINT, syscall.SIGTERM)
+	defer signal.Stop(sigCh)
+
+	errCh := make(chan error, 1)
+	go func() {
+		errCh <- srv.Start()
+	}()
+
+	var interrupted bool
+	select {
+	case err := <-errCh:
+		return nil, fmt.Errorf("setup server: %w", err)
+	case <-wizard.Done():
+	case sig := <-sigCh:
+		log.Info("setup interrupted", zap.String("signal", sig.String()))
+		interrupted = true
+	}
+
+	shutdownCtx, cancel := context.WithTimeout(ctx, 10*time.Second)
+	defer cancel()
+	if err := srv.Shutdown(shutdownCtx); err != nil {
+		return nil, fmt.Errorf("setup server shutdown: %w", err)
+	}
+	if err := <-errCh; err != nil && err != http.ErrServerClosed {
+		return nil, fmt.Errorf("setup server: %w", err)
+	}
+	if interrupted {
+		return nil, nil
+	}
+
+	next, err := config.Load(wizard.Path())
+	if err != nil {
+		return nil, fmt.Errorf("reload config: %w", err)
+	}
+	log.Info("setup complete; starting normally")
+	return next, nil
+}
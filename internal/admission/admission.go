@@ -0,0 +1,194 @@
+// Package admission evaluates operator-written Rego rules against policy
+// changes before the API accepts them. Rules live in *.rego files under one
+// directory and contribute messages to the set
+//
+//	data.aegisx.admission.deny
+//
+// A change is rejected when the set is non-empty. For example:
+//
+//	package aegisx.admission
+//
+//	deny[msg] {
+//	    r := input.ir.firewallRules[_]
+//	    r.action == "accept"
+//	    r.dstPorts[_] == "22"
+//	    count(r.srcAddrs) == 0
+//	    msg := sprintf("%s opens SSH to any source", [r.comment])
+//	}
+package admission
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/open-policy-agent/opa/rego"
+	"go.uber.org/zap"
+	"gopkg.in/yaml.v3"
+
+	"github.com/aegisx/aegisx/internal/policy"
+)
+
+const denyQuery = "data.aegisx.admission.deny"
+
+// Operations a change is admitted for.
+const (
+	OpCreate = "create"
+	OpUpdate = "update"
+	OpApply  = "apply"
+)
+
+// Request describes a policy change.
+type Request struct {
+	Operation string          `json:"operation"`
+	User      string          `json:"user"`
+	Role      string          `json:"role"`
+	Tenant    string          `json:"tenant"`
+	Namespace string          `json:"namespace"`
+	Name      string          `json:"name"`
+	Kind      string          `json:"kind"`
+	Spec      json.RawMessage `json:"spec,omitempty"`
+	RawYAML   string          `json:"-"`
+}
+
+// input is what the Rego rules see: the request plus the manifests decoded
+// as plain documents and, when they compile on their own, the IR.
+type input struct {
+	Request
+	Manifests []map[string]any `json:"manifests"`
+	IR        *policy.IR       `json:"ir,omitempty"`
+}
+
+// Controller holds the prepared rules. A nil Controller admits everything.
+type Controller struct {
+	dir string
+	log *zap.Logger
+
+	mu      sync.RWMutex
+	query   rego.PreparedEvalQuery
+	modules []string
+}
+
+// New loads the rules in dir.
+func New(dir string, log *zap.Logger) (*Controller, error) {
+	c := &Controller{dir: dir, log: log}
+	if err := c.Reload(context.Background()); err != nil {
+		return nil, err
+	}
+	return c, nil
+}
+
+// Reload re-reads the rule files; the old rules stay active on error.
+func (c *Controller) Reload(ctx context.Context) error {
+	paths, err := filepath.Glob(filepath.Join(c.dir, "*.rego"))
+	if err != nil {
+		return err
+	}
+	sort.Strings(paths)
+
+	opts := []func(*rego.Rego){rego.Query(denyQuery)}
+	for _, p := range paths {
+		src, err := os.ReadFile(p)
+		if err != nil {
+			return fmt.Errorf("read %s: %w", p, err)
+		}
+		opts = append(opts, rego.Module(filepath.Base(p), string(src)))
+	}
+	q, err := rego.New(opts...).PrepareForEval(ctx)
+	if err != nil {
+		return fmt.Errorf("compile admission rules: %w", err)
+	}
+
+	c.mu.Lock()
+	c.query = q
+	c.modules = paths
+	c.mu.Unlock()
+	c.log.Info("admission rules loaded", zap.String("dir", c.dir), zap.Int("files", len(paths)))
+	return nil
+}
+
+// Modules returns the loaded rule files.
+func (c *Controller) Modules() []string {
+	if c == nil {
+		return []string{}
+	}
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return append([]string{}, c.modules...)
+}
+
+// Review evaluates the rules for req and returns the deny messages, sorted.
+func (c *Controller) Review(ctx context.Context, req Request) ([]string, error) {
+	if c == nil {
+		return nil, nil
+	}
+	in := input{Request: req, Manifests: []map[string]any{}}
+	if req.RawYAML != "" {
+		docs, err := decodeDocuments(req.RawYAML)
+		if err != nil {
+			return nil, err
+		}
+		in.Manifests = docs
+		if ms, err := policy.NewParser().ParseReader(strings.NewReader(req.RawYAML)); err == nil {
+			if ir, err := policy.NewEngine().Compile(ms); err == nil {
+				in.IR = ir
+			}
+		}
+	}
+
+	// Round-trip through JSON so the rules see the API field names.
+	raw, err := json.Marshal(in)
+	if err != nil {
+		return nil, err
+	}
+	var doc any
+	if err := json.Unmarshal(raw, &doc); err != nil {
+		return nil, err
+	}
+
+	c.mu.RLock()
+	q := c.query
+	c.mu.RUnlock()
+	rs, err := q.Eval(ctx, rego.EvalInput(doc))
+	if err != nil {
+		return nil, fmt.Errorf("evaluate admission rules: %w", err)
+	}
+
+	var denies []string
+	for _, r := range rs {
+		for _, expr := range r.Expressions {
+			set, _ := expr.Value.([]any)
+			for _, v := range set {
+				denies = append(denies, fmt.Sprint(v))
+			}
+		}
+	}
+	sort.Strings(denies)
+	return denies, nil
+}
+
+// ─── Private helpers ──────────────────────────────────────────────────────
+
+func decodeDocuments(raw string) ([]map[string]any, error) {
+	dec := yaml.NewDecoder(strings.NewReader(raw))
+	var docs []map[string]any
+	for {
+		var d map[string]any
+		if err := dec.Decode(&d); err != nil {
+			if err == io.EOF {
+				break
+			}
+			return nil, fmt.Errorf("decode manifests: %w", err)
+		}
+		if d != nil {
+			docs = append(docs, d)
+		}
+	}
+	return docs, nil
+}
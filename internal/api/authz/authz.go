@@ -0,0 +1,373 @@
+// Package authz is the authorization matrix of the API: the permission
+// each authenticated route needs, and the least role that holds each
+// permission. Authentication stays with the server's auth middleware; the
+// matrix decides what the authenticated caller may do.
+//
+// Handlers still apply finer checks the matrix cannot express: namespace
+// bindings for policies and namespaces, and ownership of break-glass
+// sessions.
+package authz
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Roles, least privileged first.
+const (
+	RoleViewer   = "viewer"
+	RoleOperator = "operator"
+	RoleAdmin    = "admin"
+
+	// RoleInstance is the instance operator's, for permissions that reach
+	// across tenants. It is never a tenant role: the server grants it to
+	// the admin account of its config alone.
+	RoleInstance = "instance"
+)
+
+var roleRank = map[string]int{RoleViewer: 1, RoleOperator: 2, RoleAdmin: 3, RoleInstance: 4}
+
+// Permission is an action on a resource, named resource:action.
+type Permission string
+
+const (
+	PasskeysSelf       Permission = "passkeys:self"
+	PoliciesRead       Permission = "policies:read"
+	PoliciesWrite      Permission = "policies:write"
+	PoliciesApply      Permission = "policies:apply"
+	ChangesRead        Permission = "changes:read"
+	ChangesReview      Permission = "changes:review"
+	AdmissionRead      Permission = "admission:read"
+	AdmissionReload    Permission = "admission:reload"
+	IDSRead            Permission = "ids:read"
+	IDSTriage          Permission = "ids:triage"
+	IDSRules           Permission = "ids:rules"
+	NamespacesRead     Permission = "namespaces:read"
+	NamespacesWrite    Permission = "namespaces:write"
+	NamespacesManage   Permission = "namespaces:manage"
+	FirewallRead       Permission = "firewall:read"
+	FirewallApply      Permission = "firewall:apply"
+	FirewallFlush      Permission = "firewall:flush"
+	BansRead           Permission = "bans:read"
+	BansWrite          Permission = "bans:write"
+	BlockListsRead     Permission = "blocklists:read"
+	BlockListsWrite    Permission = "blocklists:write"
+	MonitorsRead       Permission = "monitors:read"
+	MonitorsWrite      Permission = "monitors:write"
+	WANTestsRead       Permission = "wantests:read"
+	WANTestsRun        Permission = "wantests:run"
+	AnalyticsRead      Permission = "analytics:read"
+	LBRead             Permission = "lb:read"
+	LBMaintenance      Permission = "lb:maintenance"
+	VPNRead            Permission = "vpn:read"
+	VPNManage          Permission = "vpn:manage"
+	FreezesRead        Permission = "freezes:read"
+	FreezesWrite       Permission = "freezes:write"
+	BreakGlassRequest  Permission = "breakglass:request"
+	BreakGlassApprove  Permission = "breakglass:approve"
+	ImpersonationAdmin Permission = "impersonation:admin"
+	ImpersonationEnd   Permission = "impersonation:end"
+	SystemRead         Permission = "system:read"
+	SystemReadOnly     Permission = "system:read-only"
+	BackupsManage      Permission = "backups:manage"
+	DirectorySync      Permission = "directory:sync"
+	AuditRead          Permission = "audit:read"
+	AuditVerify        Permission = "audit:verify"
+	GraphQLQuery       Permission = "graphql:query"
+
+	// PoliciesRawRules is needed, besides PoliciesWrite, to write or
+	// approve a FirewallPolicy with rawRules, which nothing validates
+	// beyond `nft -c`. No route requires it on its own.
+	PoliciesRawRules Permission = "policies:raw-rules"
+)
+
+// minRole is the least role holding each permission.
+var minRole = map[Permission]string{
+	PasskeysSelf: RoleViewer,
+
+	// Namespace bindings decide who may change and apply policies and
+	// namespaces, so a tenant viewer may hold an operator binding.
+	PoliciesRead:       RoleViewer,
+	PoliciesWrite:      RoleViewer,
+	PoliciesApply:      RoleViewer,
+	ChangesRead:        RoleViewer,
+	ChangesReview:      RoleOperator,
+	AdmissionRead:      RoleViewer,
+	AdmissionReload:    RoleAdmin,
+	IDSRead:            RoleViewer,
+	IDSTriage:          RoleOperator,
+	IDSRules:           RoleAdmin,
+	NamespacesRead:     RoleViewer,
+	NamespacesWrite:    RoleViewer,
+	NamespacesManage:   RoleAdmin,
+	FirewallRead:       RoleViewer,
+	FirewallApply:      RoleOperator,
+	FirewallFlush:      RoleAdmin,
+	BansRead:           RoleViewer,
+	BansWrite:          RoleOperator,
+	BlockListsRead:     RoleViewer,
+	BlockListsWrite:    RoleOperator,
+	MonitorsRead:       RoleViewer,
+	MonitorsWrite:      RoleOperator,
+	WANTestsRead:       RoleViewer,
+	WANTestsRun:        RoleOperator,
+	AnalyticsRead:      RoleViewer,
+	LBRead:             RoleViewer,
+	LBMaintenance:      RoleOperator,
+	VPNRead:            RoleViewer,
+	VPNManage:          RoleOperator,
+	FreezesRead:        RoleViewer,
+	FreezesWrite:       RoleAdmin,
+	BreakGlassRequest:  RoleViewer, // requesters see and end only their own sessions
+	BreakGlassApprove:  RoleAdmin,
+	ImpersonationAdmin: RoleInstance,
+	ImpersonationEnd:   RoleViewer, // an impersonation token ends its own session; others need ImpersonationAdmin
+	SystemRead:         RoleViewer,
+	SystemReadOnly:     RoleAdmin,
+	BackupsManage:      RoleInstance, // backups span every tenant
+	DirectorySync:      RoleAdmin,
+	AuditRead:          RoleAdmin,
+	AuditVerify:        RoleAdmin,
+
+	// Each GraphQL field checks the permission of its REST counterpart.
+	GraphQLQuery: RoleViewer,
+
+	PoliciesRawRules: RoleAdmin,
+}
+
+// routes maps every authenticated route, as "METHOD /path" with gin's
+// parameter syntax, to the permission it needs.
+var routes = map[string]Permission{
+	"GET /api/v1/auth/passkeys":                  PasskeysSelf,
+	"POST /api/v1/auth/passkeys/register/begin":  PasskeysSelf,
+	"POST /api/v1/auth/passkeys/register/finish": PasskeysSelf,
+	"DELETE /api/v1/auth/passkeys/:id":           PasskeysSelf,
+
+	"GET /api/v1/policies":                  PoliciesRead,
+	"POST /api/v1/policies":                 PoliciesWrite,
+	"POST /api/v1/policies/validate":        PoliciesRead,
+	"POST /api/v1/policies/preview":         PoliciesRead,
+	"POST /api/v1/policies/validate-schema": PoliciesRead,
+	"GET /api/v1/policies/:id":              PoliciesRead,
+	"PUT /api/v1/policies/:id":              PoliciesWrite,
+	"DELETE /api/v1/policies/:id":           PoliciesWrite,
+	"POST /api/v1/policies/:id/apply":       PoliciesApply,
+	"POST /api/v1/policies/:id/enable":      PoliciesApply,
+	"POST /api/v1/policies/:id/disable":     PoliciesApply,
+	"GET /api/v1/policies/:id/diff":         PoliciesRead,
+	"GET /api/v1/policies/:id/render":       PoliciesRead,
+	"GET /api/v1/policies/:id/revisions":    PoliciesRead,
+	"GET /api/v1/schemas/:kind":             PoliciesRead,
+	"GET /api/v1/changes":                   ChangesRead,
+	"GET /api/v1/changes/:id":               ChangesRead,
+	"POST /api/v1/changes/:id/approve":      ChangesReview,
+	"POST /api/v1/changes/:id/reject":       ChangesReview,
+	"GET /api/v1/admission/rules":           AdmissionRead,
+	"POST /api/v1/admission/reload":         AdmissionReload,
+
+	"GET /api/v1/ids/stats":                    IDSRead,
+	"GET /api/v1/ids/alerts":                   IDSRead,
+	"GET /api/v1/ids/suggestions":              IDSRead,
+	"POST /api/v1/ids/suggestions/:id/accept":  IDSTriage,
+	"POST /api/v1/ids/suggestions/:id/dismiss": IDSTriage,
+	"GET /api/v1/ids/sids":                     IDSRead,
+	"PUT /api/v1/ids/sids/:sid":                IDSRules,
+	"DELETE /api/v1/ids/sids/:sid":             IDSRules,
+	"POST /api/v1/ids/ruleset/rebuild":         IDSRules,
+
+	"GET /api/v1/namespaces":                           NamespacesRead,
+	"POST /api/v1/namespaces":                          NamespacesManage,
+	"GET /api/v1/namespaces/:name":                     NamespacesRead,
+	"PUT /api/v1/namespaces/:name":                     NamespacesWrite,
+	"DELETE /api/v1/namespaces/:name":                  NamespacesManage,
+	"GET /api/v1/namespaces/:name/bindings":            NamespacesRead,
+	"PUT /api/v1/namespaces/:name/bindings/:userId":    NamespacesWrite,
+	"DELETE /api/v1/namespaces/:name/bindings/:userId": NamespacesWrite,
+
+	"GET /api/v1/firewall/status":               FirewallRead,
+	"POST /api/v1/firewall/apply":               FirewallApply,
+	"POST /api/v1/firewall/rollback":            FirewallApply,
+	"POST /api/v1/firewall/flush":               FirewallFlush,
+	"GET /api/v1/firewall/rules":                FirewallRead,
+	"GET /api/v1/firewall/rules/:handle/source": FirewallRead,
+	"GET /api/v1/firewall/health":               FirewallRead,
+	"GET /api/v1/firewall/analysis":             FirewallRead,
+	"POST /api/v1/firewall/test":                FirewallRead,
+	"POST /api/v1/firewall/simulate":            FirewallRead,
+	"GET /api/v1/firewall/scans":                FirewallRead,
+	"GET /api/v1/firewall/events":               FirewallRead,
+	"GET /api/v1/firewall/netns":                FirewallRead,
+	"GET /api/v1/wan/uplinks":                   FirewallRead,
+	"GET /api/v1/wan/paths":                     FirewallRead,
+	"GET /api/v1/vrfs":                          FirewallRead,
+	"GET /api/v1/wan/tests":                     WANTestsRead,
+	"POST /api/v1/wan/tests":                    WANTestsRun,
+
+	"GET /api/v1/bans":             BansRead,
+	"POST /api/v1/bans":            BansWrite,
+	"DELETE /api/v1/bans/:address": BansWrite,
+
+	"GET /api/v1/blocklists":                            BlockListsRead,
+	"POST /api/v1/blocklists":                           BlockListsWrite,
+	"GET /api/v1/blocklists/:id":                        BlockListsRead,
+	"PATCH /api/v1/blocklists/:id":                      BlockListsWrite,
+	"DELETE /api/v1/blocklists/:id":                     BlockListsWrite,
+	"GET /api/v1/blocklists/:id/stats":                  BlockListsRead,
+	"GET /api/v1/blocklists/:id/entries":                BlockListsRead,
+	"POST /api/v1/blocklists/:id/entries":               BlockListsWrite,
+	"DELETE /api/v1/blocklists/:id/entries/:entryId":    BlockListsWrite,
+	"POST /api/v1/blocklists/:id/feeds":                 BlockListsWrite,
+	"DELETE /api/v1/blocklists/:id/feeds/:feedId":       BlockListsWrite,
+	"POST /api/v1/blocklists/:id/feeds/:feedId/refresh": BlockListsWrite,
+
+	"GET /api/v1/monitors":            MonitorsRead,
+	"POST /api/v1/monitors":           MonitorsWrite,
+	"GET /api/v1/monitors/:id":        MonitorsRead,
+	"PUT /api/v1/monitors/:id":        MonitorsWrite,
+	"DELETE /api/v1/monitors/:id":     MonitorsWrite,
+	"GET /api/v1/monitors/:id/events": MonitorsRead,
+
+	"GET /api/v1/analytics/blocked": AnalyticsRead,
+
+	"GET /api/v1/lb/analytics":                     LBRead,
+	"GET /api/v1/lb/discovery":                     LBRead,
+	"GET /api/v1/lb/maintenance":                   LBRead,
+	"PUT /api/v1/lb/backends/:name/maintenance":    LBMaintenance,
+	"DELETE /api/v1/lb/backends/:name/maintenance": LBMaintenance,
+
+	"GET /api/v1/vpn/peers":                   VPNRead,
+	"GET /api/v1/vpn/peers/:id/stats":         VPNRead,
+	"GET /api/v1/vpn/peers/:id/config":        VPNManage, // exposes the peer's address, allowed IPs and PSK
+	"PUT /api/v1/vpn/peers/:id/profile":       VPNManage,
+	"POST /api/v1/vpn/peers/:id/mtu-probe":    VPNManage,
+	"POST /api/v1/vpn/peers/:id/portal-token": VPNManage,
+
+	"GET /api/v1/freezes":        FreezesRead,
+	"POST /api/v1/freezes":       FreezesWrite,
+	"DELETE /api/v1/freezes/:id": FreezesWrite,
+
+	"GET /api/v1/breakglass":              BreakGlassRequest,
+	"POST /api/v1/breakglass":             BreakGlassRequest,
+	"POST /api/v1/breakglass/:id/approve": BreakGlassApprove,
+	"POST /api/v1/breakglass/:id/token":   BreakGlassRequest,
+	"DELETE /api/v1/breakglass/:id":       BreakGlassRequest,
+
+	"GET /api/v1/impersonations":           ImpersonationAdmin,
+	"POST /api/v1/impersonations":          ImpersonationAdmin,
+	"DELETE /api/v1/impersonations/:id":    ImpersonationEnd,
+	"GET /api/v1/impersonations/:id/audit": ImpersonationAdmin,
+
+	"GET /api/v1/status":                       SystemRead,
+	"GET /api/v1/version":                      SystemRead,
+	"GET /api/v1/time":                         SystemRead,
+	"GET /api/v1/system/features":              SystemRead,
+	"GET /api/v1/system/permissions":           SystemRead,
+	"GET /api/v1/system/read-only":             SystemRead,
+	"PUT /api/v1/system/read-only":             SystemReadOnly,
+	"DELETE /api/v1/system/read-only":          SystemReadOnly,
+	"GET /api/v1/system/backups":               BackupsManage,
+	"POST /api/v1/system/backups":              BackupsManage,
+	"POST /api/v1/system/backups/:name/verify": BackupsManage,
+
+	"GET /api/v1/system/interfaces/:name/stats": SystemRead,
+	"GET /api/v1/system/ldap":                   DirectorySync,
+	"POST /api/v1/system/ldap/sync":             DirectorySync,
+	"GET /api/v1/system/audit":                  AuditRead,
+	"GET /api/v1/system/audit/verify":           AuditVerify,
+
+	"GET /api/v1/observability/grafana-dashboards": SystemRead,
+
+	"GET /api/v1/graphql":  GraphQLQuery,
+	"POST /api/v1/graphql": GraphQLQuery,
+}
+
+// public are the /api/v1 routes outside the matrix: they run before a
+// session exists, or authenticate with tokens of their own.
+var public = map[string]bool{
+	"POST /api/v1/auth/login":                 true,
+	"POST /api/v1/auth/refresh":               true,
+	"POST /api/v1/auth/logout":                true,
+	"POST /api/v1/auth/passkeys/login/begin":  true,
+	"POST /api/v1/auth/passkeys/login/finish": true,
+	"GET /api/v1/portal/peer":                 true,
+	"GET /api/v1/portal/peer/config":          true,
+	"GET /api/v1/portal/peer/status":          true,
+	"POST /api/v1/portal/peer/rotate":         true,
+}
+
+// ForRoute returns the permission the route needs; false when the route is
+// not in the matrix, which callers must treat as denied.
+func ForRoute(method, path string) (Permission, bool) {
+	p, ok := routes[method+" "+path]
+	return p, ok
+}
+
+// Allows reports whether role holds p.
+func Allows(role string, p Permission) bool {
+	need, ok := minRole[p]
+	return ok && roleRank[role] >= roleRank[need]
+}
+
+// Role returns the least role holding p.
+func Role(p Permission) string {
+	return minRole[p]
+}
+
+// Entry is one row of the matrix.
+type Entry struct {
+	Method     string     `json:"method"`
+	Path       string     `json:"path"`
+	Permission Permission `json:"permission"`
+	Role       string     `json:"role"` // least role holding Permission
+}
+
+// Matrix returns every authenticated route with its permission, sorted by
+// path and method.
+func Matrix() []Entry {
+	out := make([]Entry, 0, len(routes))
+	for k, p := range routes {
+		method, path, _ := strings.Cut(k, " ")
+		out = append(out, Entry{Method: method, Path: path, Permission: p, Role: minRole[p]})
+	}
+	sort.Slice(out, func(i, j int) bool {
+		if out[i].Path != out[j].Path {
+			return out[i].Path < out[j].Path
+		}
+		return out[i].Method < out[j].Method
+	})
+	return out
+}
+
+// Check verifies the matrix against the registered routes: every /api/v1
+// route is public or has a permission, every permission has a role, and
+// every route in the matrix exists.
+func Check(registered gin.RoutesInfo) error {
+	var errs []string
+	seen := make(map[string]bool, len(registered))
+	for _, r := range registered {
+		if !strings.HasPrefix(r.Path, "/api/v1/") {
+			continue
+		}
+		k := r.Method + " " + r.Path
+		seen[k] = true
+		if _, ok := routes[k]; !ok && !public[k] {
+			errs = append(errs, k+" has no permission")
+		}
+	}
+	for k, p := range routes {
+		if _, ok := minRole[p]; !ok {
+			errs = append(errs, fmt.Sprintf("%s: permission %s has no role", k, p))
+		}
+		if !seen[k] {
+			errs = append(errs, k+" is not a registered route")
+		}
+	}
+	if len(errs) > 0 {
+		sort.Strings(errs)
+		return fmt.Errorf("authorization matrix: %s", strings.Join(errs, "; "))
+	}
+	return nil
+}
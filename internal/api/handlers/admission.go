@@ -0,0 +1,43 @@
+package handlers
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+
+	"github.com/aegisx/aegisx/internal/admission"
+)
+
+// AdmissionHandler handles /api/v1/admission: the Rego rules that gate
+// policy changes.
+type AdmissionHandler struct {
+	ctrl *admission.Controller
+	log  *zap.Logger
+}
+
+func NewAdmissionHandler(ctrl *admission.Controller, log *zap.Logger) *AdmissionHandler {
+	return &AdmissionHandler{ctrl: ctrl, log: log}
+}
+
+// Rules GET /api/v1/admission/rules
+func (h *AdmissionHandler) Rules(c *gin.Context) {
+	items := h.ctrl.Modules()
+	c.JSON(http.StatusOK, gin.H{"items": items, "count": len(items), "enabled": h.ctrl != nil})
+}
+
+// Reload POST /api/v1/admission/reload
+// Re-reads the rule files; on a compile error the previous rules stay active.
+func (h *AdmissionHandler) Reload(c *gin.Context) {
+	if h.ctrl == nil {
+		Abort(c, http.StatusServiceUnavailable, CodeFeatureDisabled, "admission control is disabled")
+		return
+	}
+	if err := h.ctrl.Reload(c.Request.Context()); err != nil {
+		h.log.Warn("reload admission rules", zap.Error(err))
+		fail(c, http.StatusBadRequest, err.Error())
+		return
+	}
+	items := h.ctrl.Modules()
+	c.JSON(http.StatusOK, gin.H{"items": items, "count": len(items), "enabled": true})
+}
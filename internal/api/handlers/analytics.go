@@ -0,0 +1,127 @@
+package handlers
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+
+	"github.com/aegisx/aegisx/internal/geoip"
+	"github.com/aegisx/aegisx/internal/store"
+)
+
+// maxTrafficBuckets bounds the series length so a long window with a small
+// bucket cannot produce an unbounded response.
+const maxTrafficBuckets = 1000
+
+// AnalyticsHandler handles /api/v1/analytics endpoints.
+type AnalyticsHandler struct {
+	events *store.EventStore
+	geo    *geoip.Resolver // nil when GeoIP enrichment is disabled
+	log    *zap.Logger
+}
+
+func NewAnalyticsHandler(events *store.EventStore, geo *geoip.Resolver, log *zap.Logger) *AnalyticsHandler {
+	return &AnalyticsHandler{events: events, geo: geo, log: log}
+}
+
+// Blocked GET /api/v1/analytics/blocked?by=country&source=firewall&since=24h&bucket=1h&limit=10
+// Returns the countries, ASNs or destination ports (by=port, "tcp/22") that
+// blocked traffic came from or went to most since ?since=, each with a
+// count per ?bucket= for charting. source=firewall counts packets dropped
+// by rules with log enabled; source=ids counts blocked IDS, tarpit and
+// port-scan alerts. Country and ASN groups need the GeoIP databases;
+// "geoip" tells whether they are loaded. With ?format=csv or an Accept
+// header of text/csv the groups are a CSV download instead, with a row per
+// bucket when ?bucket= is set.
+func (h *AnalyticsHandler) Blocked(c *gin.Context) {
+	q := store.TrafficQuery{
+		Source: c.DefaultQuery("source", store.TrafficFirewall),
+		By:     c.DefaultQuery("by", store.GroupCountry),
+	}
+	switch q.Source {
+	case store.TrafficFirewall, store.TrafficIDS:
+	default:
+		fail(c, http.StatusBadRequest, "source must be firewall or ids")
+		return
+	}
+	switch q.By {
+	case store.GroupCountry, store.GroupASN, store.GroupPort:
+	default:
+		fail(c, http.StatusBadRequest, "by must be country, asn or port")
+		return
+	}
+
+	since, err := time.ParseDuration(c.DefaultQuery("since", "24h"))
+	if err != nil || since <= 0 {
+		fail(c, http.StatusBadRequest, "since must be a positive duration such as 24h")
+		return
+	}
+	if v := c.Query("bucket"); v != "" {
+		if q.Bucket, err = time.ParseDuration(v); err != nil || q.Bucket < time.Minute {
+			fail(c, http.StatusBadRequest, "bucket must be a duration of at least 1m")
+			return
+		}
+		if since/q.Bucket > maxTrafficBuckets {
+			fail(c, http.StatusBadRequest, "since/bucket yields more than 1000 buckets")
+			return
+		}
+	}
+	q.Limit, err = strconv.Atoi(c.DefaultQuery("limit", "10"))
+	if err != nil || q.Limit <= 0 || q.Limit > 100 {
+		fail(c, http.StatusBadRequest, "limit must be between 1 and 100")
+		return
+	}
+	q.Since = time.Now().Add(-since)
+
+	items, err := h.events.BlockedTraffic(c.Request.Context(), q)
+	if err != nil {
+		h.log.Error("blocked traffic analytics", zap.Error(err))
+		fail(c, http.StatusInternalServerError, "failed to aggregate blocked traffic")
+		return
+	}
+	if wantsCSV(c) {
+		h.exportBlocked(c, q, items)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"source": q.Source,
+		"by":     q.By,
+		"since":  q.Since,
+		"bucket": q.Bucket.String(),
+		"geoip":  h.geo.Enabled(),
+		"items":  items,
+		"count":  len(items),
+	})
+}
+
+// exportBlocked writes the groups of Blocked as CSV.
+func (h *AnalyticsHandler) exportBlocked(c *gin.Context, q store.TrafficQuery, items []*store.TrafficGroup) {
+	header := []string{q.By, "label", "count", "sources"}
+	var rows [][]string
+	if q.Bucket > 0 {
+		header = []string{q.By, "label", "bucket", "count"}
+		for _, g := range items {
+			for _, p := range g.Series {
+				rows = append(rows, []string{g.Key, g.Label, csvTime(p.At), strconv.FormatInt(p.Count, 10)})
+			}
+		}
+	} else {
+		for _, g := range items {
+			rows = append(rows, []string{g.Key, g.Label, strconv.FormatInt(g.Count, 10), strconv.FormatInt(g.Sources, 10)})
+		}
+	}
+
+	exp := newCSVExport(c, "blocked-"+q.Source+"-by-"+q.By, header...)
+	for _, row := range rows {
+		if err := exp.Write(row...); err != nil {
+			h.log.Warn("export blocked traffic", zap.Error(err))
+			return
+		}
+	}
+	if err := exp.Close(); err != nil {
+		h.log.Warn("export blocked traffic", zap.Error(err))
+	}
+}
@@ -2,20 +2,24 @@ package handlers
 
 import (
 	"net/http"
+	"strings"
 
 	"github.com/gin-gonic/gin"
 	"go.uber.org/zap"
 
 	"github.com/aegisx/aegisx/internal/auth"
+	"github.com/aegisx/aegisx/internal/ban"
 )
 
 type AuthHandler struct {
-	svc *auth.Service
-	log *zap.Logger
+	svc      *auth.Service
+	passkeys *auth.Passkeys // nil when WebAuthn is disabled
+	bans     *ban.Manager   // nil when brute-force protection is disabled
+	log      *zap.Logger
 }
 
-func NewAuthHandler(svc *auth.Service, log *zap.Logger) *AuthHandler {
-	return &AuthHandler{svc: svc, log: log}
+func NewAuthHandler(svc *auth.Service, passkeys *auth.Passkeys, bans *ban.Manager, log *zap.Logger) *AuthHandler {
+	return &AuthHandler{svc: svc, passkeys: passkeys, bans: bans, log: log}
 }
 
 type LoginRequest struct {
@@ -30,30 +34,46 @@ type LoginResponse struct {
 	Role         string `json:"role"`
 }
 
+// MFARequiredResponse is returned by Login instead of tokens when the user
+// must also present a passkey; MFAToken starts the passkey login.
+type MFARequiredResponse struct {
+	MFARequired bool   `json:"mfaRequired"`
+	MFAToken    string `json:"mfaToken"`
+}
+
 // Login POST /api/v1/auth/login
 func (h *AuthHandler) Login(c *gin.Context) {
 	var req LoginRequest
 	if err := c.ShouldBindJSON(&req); err != nil {
-		c.JSON(http.StatusBadRequest, errResp(err.Error()))
+		fail(c, http.StatusBadRequest, err.Error())
 		return
 	}
 
-	resp, err := h.svc.Login(c.Request.Context(), req.Username, req.Password)
+	id, err := h.svc.Authenticate(c.Request.Context(), req.Username, req.Password)
 	if err != nil {
-		h.log.Warn("login failed",
-			zap.String("username", req.Username),
-			zap.String("ip", c.ClientIP()),
-			zap.Error(err))
-		c.JSON(http.StatusUnauthorized, errResp("invalid credentials"))
+		h.loginFailed(c, req.Username, err)
 		return
 	}
 
-	c.JSON(http.StatusOK, LoginResponse{
-		Token:        resp.AccessToken,
-		RefreshToken: resp.RefreshToken,
-		ExpiresIn:    resp.ExpiresIn,
-		Role:         resp.Role,
-	})
+	if h.passkeys != nil && h.passkeys.SecondFactor() {
+		registered, err := h.passkeys.Registered(c.Request.Context(), id.UserID)
+		if err != nil {
+			h.log.Error("load passkeys", zap.Error(err))
+			fail(c, http.StatusInternalServerError, "login failed")
+			return
+		}
+		if registered {
+			token, err := h.svc.IssueMFAToken(id)
+			if err != nil {
+				h.log.Error("issue mfa token", zap.Error(err))
+				fail(c, http.StatusInternalServerError, "login failed")
+				return
+			}
+			c.JSON(http.StatusOK, MFARequiredResponse{MFARequired: true, MFAToken: token})
+			return
+		}
+	}
+	h.issue(c, id)
 }
 
 // Refresh POST /api/v1/auth/refresh
@@ -62,13 +82,13 @@ func (h *AuthHandler) Refresh(c *gin.Context) {
 		RefreshToken string `json:"refreshToken" binding:"required"`
 	}
 	if err := c.ShouldBindJSON(&body); err != nil {
-		c.JSON(http.StatusBadRequest, errResp(err.Error()))
+		fail(c, http.StatusBadRequest, err.Error())
 		return
 	}
 
 	resp, err := h.svc.RefreshToken(c.Request.Context(), body.RefreshToken)
 	if err != nil {
-		c.JSON(http.StatusUnauthorized, errResp("invalid or expired refresh token"))
+		fail(c, http.StatusUnauthorized, "invalid or expired refresh token")
 		return
 	}
 
@@ -79,9 +99,46 @@ func (h *AuthHandler) Refresh(c *gin.Context) {
 	})
 }
 
+// issue writes a fresh token pair for id.
+func (h *AuthHandler) issue(c *gin.Context, id *auth.Identity) {
+	resp, err := h.svc.IssueTokens(c.Request.Context(), id)
+	if err != nil {
+		h.log.Error("issue tokens", zap.Error(err))
+		fail(c, http.StatusInternalServerError, "login failed")
+		return
+	}
+	c.JSON(http.StatusOK, LoginResponse{
+		Token:        resp.AccessToken,
+		RefreshToken: resp.RefreshToken,
+		ExpiresIn:    resp.ExpiresIn,
+		Role:         resp.Role,
+	})
+}
+
+// loginFailed logs a failed login, counts it towards an API ban and writes
+// a deliberately vague 401.
+func (h *AuthHandler) loginFailed(c *gin.Context, username string, err error) {
+	h.log.Warn("login failed",
+		zap.String("username", username),
+		zap.String("ip", c.ClientIP()),
+		zap.Error(err))
+	if h.bans != nil {
+		h.bans.Fail(ban.JailAPI, c.ClientIP())
+	}
+	fail(c, http.StatusUnauthorized, "invalid credentials")
+}
+
 // Logout POST /api/v1/auth/logout
+// Ends the session of the bearer token when sessions are tracked, which
+// revokes its access and refresh tokens. Otherwise the client just drops
+// the tokens.
 func (h *AuthHandler) Logout(c *gin.Context) {
-	// Stateless JWT: client drops the token.
-	// For stateful sessions, blacklist the token here.
+	token, ok := strings.CutPrefix(c.GetHeader("Authorization"), "Bearer ")
+	if ok {
+		if err := h.svc.Logout(c.Request.Context(), token); err != nil {
+			failErr(c, http.StatusInternalServerError, "logout failed", err)
+			return
+		}
+	}
 	c.JSON(http.StatusOK, gin.H{"status": "logged out"})
 }
@@ -0,0 +1,103 @@
+package handlers
+
+import (
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+
+	"github.com/aegisx/aegisx/internal/ban"
+)
+
+// BanHandler handles /api/v1/bans.
+type BanHandler struct {
+	bans *ban.Manager // nil when brute-force protection is disabled
+	log  *zap.Logger
+}
+
+func NewBanHandler(bans *ban.Manager, log *zap.Logger) *BanHandler {
+	return &BanHandler{bans: bans, log: log}
+}
+
+type banRequest struct {
+	Address  string `json:"address"  binding:"required"`
+	Duration string `json:"duration" binding:"required"` // e.g. "1h"
+	Reason   string `json:"reason"`
+}
+
+// List GET /api/v1/bans
+// Returns the active bans, newest first.
+func (h *BanHandler) List(c *gin.Context) {
+	if !h.enabled(c) {
+		return
+	}
+	items, err := h.bans.Active(c.Request.Context())
+	if err != nil {
+		h.log.Error("list bans", zap.Error(err))
+		fail(c, http.StatusInternalServerError, "failed to list bans")
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"items": items, "count": len(items)})
+}
+
+// Create POST /api/v1/bans
+// Bans an address by hand for the given duration.
+func (h *BanHandler) Create(c *gin.Context) {
+	if !h.enabled(c) {
+		return
+	}
+	var req banRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		fail(c, http.StatusBadRequest, err.Error())
+		return
+	}
+	d, err := time.ParseDuration(req.Duration)
+	if err != nil || d <= 0 {
+		fail(c, http.StatusBadRequest, "invalid duration")
+		return
+	}
+	b, err := h.bans.Ban(c.Request.Context(), req.Address, d, req.Reason)
+	if err != nil {
+		if strings.Contains(err.Error(), "invalid address") {
+			fail(c, http.StatusBadRequest, err.Error())
+			return
+		}
+		h.log.Error("ban address", zap.Error(err))
+		fail(c, http.StatusInternalServerError, "failed to ban address")
+		return
+	}
+	c.JSON(http.StatusCreated, b)
+}
+
+// Delete DELETE /api/v1/bans/:address
+// Lifts the active bans of an address.
+func (h *BanHandler) Delete(c *gin.Context) {
+	if !h.enabled(c) {
+		return
+	}
+	uid := callerID(c)
+	lifted, err := h.bans.Unban(c.Request.Context(), c.Param("address"), &uid)
+	if err != nil {
+		switch {
+		case strings.Contains(err.Error(), "invalid address"):
+			fail(c, http.StatusBadRequest, err.Error())
+		case strings.Contains(err.Error(), "not found"):
+			fail(c, http.StatusNotFound, "address is not banned")
+		default:
+			h.log.Error("unban address", zap.Error(err))
+			fail(c, http.StatusInternalServerError, err.Error())
+		}
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"items": lifted, "count": len(lifted)})
+}
+
+func (h *BanHandler) enabled(c *gin.Context) bool {
+	if h.bans == nil {
+		Abort(c, http.StatusServiceUnavailable, CodeFeatureDisabled, "brute-force protection is disabled")
+		return false
+	}
+	return true
+}
@@ -0,0 +1,479 @@
+package handlers
+
+import (
+	"net/http"
+	"net/url"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"go.uber.org/zap"
+
+	"github.com/aegisx/aegisx/internal/blocklist"
+	"github.com/aegisx/aegisx/internal/store"
+)
+
+// BlockListHandler handles /api/v1/blocklists.
+type BlockListHandler struct {
+	mgr *blocklist.Manager // nil when block lists are disabled
+	log *zap.Logger
+}
+
+func NewBlockListHandler(mgr *blocklist.Manager, log *zap.Logger) *BlockListHandler {
+	return &BlockListHandler{mgr: mgr, log: log}
+}
+
+// minFeedRefresh keeps subscriptions from hammering feed providers.
+const minFeedRefresh = 5 * time.Minute
+
+var blockListName = regexp.MustCompile(`^[a-z0-9][a-z0-9_-]{0,62}$`)
+
+type blockListRequest struct {
+	Name        string `json:"name"`
+	Description string `json:"description"`
+	Enabled     *bool  `json:"enabled"` // default true
+}
+
+type blockListEntryRequest struct {
+	Address string `json:"address" binding:"required"` // address or CIDR prefix
+	Comment string `json:"comment"`
+	TTL     string `json:"ttl"` // e.g. "24h"; empty never expires
+
+	// ExpiresAt expires the entry at a fixed time instead of after a TTL.
+	ExpiresAt *time.Time `json:"expiresAt"`
+}
+
+type blockListFeedRequest struct {
+	URL       string `json:"url"     binding:"required"`
+	Format    string `json:"format"  binding:"required"` // plain|cidr|json
+	JSONField string `json:"jsonField"`
+	Refresh   string `json:"refresh" binding:"required"` // e.g. "6h"
+}
+
+// BlockListEntryHits is an entry with the traffic its set element dropped.
+type BlockListEntryHits struct {
+	*store.BlockListEntry
+	Source  string `json:"source"` // manual or feed
+	Packets uint64 `json:"packets"`
+	Bytes   uint64 `json:"bytes"`
+}
+
+// List GET /api/v1/blocklists
+func (h *BlockListHandler) List(c *gin.Context) {
+	items, err := h.mgr.Store().List(c.Request.Context())
+	if err != nil {
+		h.log.Error("list blocklists", zap.Error(err))
+		fail(c, http.StatusInternalServerError, "failed to list blocklists")
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"items": items, "count": len(items)})
+}
+
+// Create POST /api/v1/blocklists
+func (h *BlockListHandler) Create(c *gin.Context) {
+	var req blockListRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		fail(c, http.StatusBadRequest, err.Error())
+		return
+	}
+	if !blockListName.MatchString(req.Name) {
+		fail(c, http.StatusBadRequest, "name must be lower-case letters, digits, '-' or '_'")
+		return
+	}
+	uid := callerID(c)
+	l := &store.BlockList{Name: req.Name, Description: req.Description, Enabled: true, CreatedBy: &uid}
+	if req.Enabled != nil {
+		l.Enabled = *req.Enabled
+	}
+	if err := h.mgr.Store().Create(c.Request.Context(), l); err != nil {
+		if strings.Contains(err.Error(), "already exists") {
+			fail(c, http.StatusConflict, err.Error())
+			return
+		}
+		h.log.Error("create blocklist", zap.Error(err))
+		fail(c, http.StatusInternalServerError, "failed to create blocklist")
+		return
+	}
+	c.JSON(http.StatusCreated, l)
+}
+
+// Get GET /api/v1/blocklists/:id
+// Returns the list with its feed subscriptions.
+func (h *BlockListHandler) Get(c *gin.Context) {
+	l, ok := h.load(c)
+	if !ok {
+		return
+	}
+	feeds, err := h.mgr.Store().ListFeeds(c.Request.Context(), l.ID)
+	if err != nil {
+		h.log.Error("list blocklist feeds", zap.Error(err))
+		fail(c, http.StatusInternalServerError, "failed to list feeds")
+		return
+	}
+	if feeds == nil {
+		feeds = []*store.BlockListFeed{}
+	}
+	c.JSON(http.StatusOK, gin.H{"blocklist": l, "feeds": feeds})
+}
+
+// Update PATCH /api/v1/blocklists/:id
+// Changes the description or enables/disables the whole list.
+func (h *BlockListHandler) Update(c *gin.Context) {
+	l, ok := h.load(c)
+	if !ok {
+		return
+	}
+	var req struct {
+		Description *string `json:"description"`
+		Enabled     *bool   `json:"enabled"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		fail(c, http.StatusBadRequest, err.Error())
+		return
+	}
+	if req.Description != nil {
+		l.Description = *req.Description
+	}
+	if req.Enabled != nil {
+		l.Enabled = *req.Enabled
+	}
+	if err := h.mgr.Store().Update(c.Request.Context(), l); err != nil {
+		h.log.Error("update blocklist", zap.Error(err))
+		fail(c, http.StatusInternalServerError, "failed to update blocklist")
+		return
+	}
+	h.sync(c)
+	c.JSON(http.StatusOK, l)
+}
+
+// Delete DELETE /api/v1/blocklists/:id
+func (h *BlockListHandler) Delete(c *gin.Context) {
+	l, ok := h.load(c)
+	if !ok {
+		return
+	}
+	if err := h.mgr.Store().Delete(c.Request.Context(), l.ID); err != nil {
+		h.log.Error("delete blocklist", zap.Error(err))
+		fail(c, http.StatusInternalServerError, "failed to delete blocklist")
+		return
+	}
+	h.sync(c)
+	c.JSON(http.StatusOK, gin.H{"status": "deleted"})
+}
+
+// ListEntries GET /api/v1/blocklists/:id/entries?limit=100&offset=0
+// Returns a page of the unexpired entries, newest first, each with its
+// source and the packets and bytes its set element dropped.
+func (h *BlockListHandler) ListEntries(c *gin.Context) {
+	l, ok := h.load(c)
+	if !ok {
+		return
+	}
+	limit, err := strconv.Atoi(c.DefaultQuery("limit", "100"))
+	if err != nil || limit <= 0 || limit > 1000 {
+		fail(c, http.StatusBadRequest, "limit must be between 1 and 1000")
+		return
+	}
+	offset, err := strconv.Atoi(c.DefaultQuery("offset", "0"))
+	if err != nil || offset < 0 {
+		fail(c, http.StatusBadRequest, "invalid offset")
+		return
+	}
+
+	ctx := c.Request.Context()
+	entries, total, err := h.mgr.Store().ListEntries(ctx, l.ID, limit, offset)
+	if err != nil {
+		h.log.Error("list blocklist entries", zap.Error(err))
+		fail(c, http.StatusInternalServerError, "failed to list entries")
+		return
+	}
+	counters, err := h.mgr.Counters(ctx)
+	if err != nil {
+		// The sets only exist once a ruleset has been applied.
+		h.log.Debug("read blocklist counters", zap.Error(err))
+	}
+	items := make([]BlockListEntryHits, len(entries))
+	for i, e := range entries {
+		items[i] = BlockListEntryHits{BlockListEntry: e, Source: "manual"}
+		if e.FeedID != nil {
+			items[i].Source = "feed"
+		}
+		if hit, ok := blocklist.Lookup(counters, e.Address); ok {
+			items[i].Packets, items[i].Bytes = hit.Packets, hit.Bytes
+		}
+	}
+	c.JSON(http.StatusOK, gin.H{"items": items, "count": len(items), "total": total})
+}
+
+// AddEntry POST /api/v1/blocklists/:id/entries
+func (h *BlockListHandler) AddEntry(c *gin.Context) {
+	l, ok := h.load(c)
+	if !ok {
+		return
+	}
+	var req blockListEntryRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		fail(c, http.StatusBadRequest, err.Error())
+		return
+	}
+	addr, err := blocklist.Canonical(req.Address)
+	if err != nil {
+		fail(c, http.StatusBadRequest, err.Error())
+		return
+	}
+	uid := callerID(c)
+	e := &store.BlockListEntry{BlockListID: l.ID, Address: addr, Comment: req.Comment, CreatedBy: &uid}
+	switch {
+	case req.TTL != "" && req.ExpiresAt != nil:
+		fail(c, http.StatusBadRequest, "ttl and expiresAt are mutually exclusive")
+		return
+	case req.TTL != "":
+		d, err := time.ParseDuration(req.TTL)
+		if err != nil || d <= 0 {
+			fail(c, http.StatusBadRequest, "invalid ttl")
+			return
+		}
+		exp := time.Now().Add(d)
+		e.ExpiresAt = &exp
+	case req.ExpiresAt != nil:
+		if !req.ExpiresAt.After(time.Now()) {
+			fail(c, http.StatusBadRequest, "expiresAt must be in the future")
+			return
+		}
+		e.ExpiresAt = req.ExpiresAt
+	}
+	if err := h.mgr.Store().AddEntry(c.Request.Context(), e); err != nil {
+		h.log.Error("add blocklist entry", zap.Error(err))
+		fail(c, http.StatusInternalServerError, "failed to add entry")
+		return
+	}
+	h.sync(c)
+	if e.ExpiresAt != nil {
+		h.mgr.Rearm()
+	}
+	c.JSON(http.StatusCreated, e)
+}
+
+// DeleteEntry DELETE /api/v1/blocklists/:id/entries/:entryId
+// Removes a manual entry; feed entries go away with their feed.
+func (h *BlockListHandler) DeleteEntry(c *gin.Context) {
+	l, ok := h.load(c)
+	if !ok {
+		return
+	}
+	id, err := uuid.Parse(c.Param("entryId"))
+	if err != nil {
+		fail(c, http.StatusBadRequest, "invalid entry id")
+		return
+	}
+	if err := h.mgr.Store().DeleteEntry(c.Request.Context(), l.ID, id); err != nil {
+		if strings.Contains(err.Error(), "not found") {
+			fail(c, http.StatusNotFound, "manual entry not found")
+			return
+		}
+		h.log.Error("delete blocklist entry", zap.Error(err))
+		fail(c, http.StatusInternalServerError, "failed to delete entry")
+		return
+	}
+	h.sync(c)
+	c.JSON(http.StatusOK, gin.H{"status": "deleted"})
+}
+
+// AddFeed POST /api/v1/blocklists/:id/feeds
+// Subscribes the list to a feed; it is fetched on the next sync.
+func (h *BlockListHandler) AddFeed(c *gin.Context) {
+	l, ok := h.load(c)
+	if !ok {
+		return
+	}
+	var req blockListFeedRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		fail(c, http.StatusBadRequest, err.Error())
+		return
+	}
+	u, err := url.Parse(req.URL)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		fail(c, http.StatusBadRequest, "url must be an http or https URL")
+		return
+	}
+	if !blocklist.ValidFormat(req.Format) {
+		fail(c, http.StatusBadRequest, "format must be plain, cidr or json")
+		return
+	}
+	if req.JSONField != "" && req.Format != blocklist.FormatJSON {
+		fail(c, http.StatusBadRequest, "jsonField only applies to the json format")
+		return
+	}
+	refresh, err := time.ParseDuration(req.Refresh)
+	if err != nil || refresh < minFeedRefresh {
+		fail(c, http.StatusBadRequest, "refresh must be a duration of at least "+minFeedRefresh.String())
+		return
+	}
+
+	uid := callerID(c)
+	f := &store.BlockListFeed{
+		BlockListID: l.ID,
+		URL:         req.URL,
+		Format:      req.Format,
+		JSONField:   req.JSONField,
+		RefreshSecs: int(refresh / time.Second),
+		CreatedBy:   &uid,
+	}
+	if err := h.mgr.Store().CreateFeed(c.Request.Context(), f); err != nil {
+		if strings.Contains(err.Error(), "already exists") {
+			fail(c, http.StatusConflict, err.Error())
+			return
+		}
+		h.log.Error("create blocklist feed", zap.Error(err))
+		fail(c, http.StatusInternalServerError, "failed to create feed")
+		return
+	}
+	c.JSON(http.StatusCreated, f)
+}
+
+// DeleteFeed DELETE /api/v1/blocklists/:id/feeds/:feedId
+// Unsubscribes and drops the feed's entries.
+func (h *BlockListHandler) DeleteFeed(c *gin.Context) {
+	l, ok := h.load(c)
+	if !ok {
+		return
+	}
+	id, err := uuid.Parse(c.Param("feedId"))
+	if err != nil {
+		fail(c, http.StatusBadRequest, "invalid feed id")
+		return
+	}
+	if err := h.mgr.Store().DeleteFeed(c.Request.Context(), l.ID, id); err != nil {
+		if strings.Contains(err.Error(), "not found") {
+			fail(c, http.StatusNotFound, "feed not found")
+			return
+		}
+		h.log.Error("delete blocklist feed", zap.Error(err))
+		fail(c, http.StatusInternalServerError, "failed to delete feed")
+		return
+	}
+	h.sync(c)
+	c.JSON(http.StatusOK, gin.H{"status": "deleted"})
+}
+
+// RefreshFeed POST /api/v1/blocklists/:id/feeds/:feedId/refresh
+// Fetches the feed now instead of waiting for its refresh interval.
+func (h *BlockListHandler) RefreshFeed(c *gin.Context) {
+	l, ok := h.load(c)
+	if !ok {
+		return
+	}
+	id, err := uuid.Parse(c.Param("feedId"))
+	if err != nil {
+		fail(c, http.StatusBadRequest, "invalid feed id")
+		return
+	}
+	ctx := c.Request.Context()
+	f, err := h.mgr.Store().GetFeed(ctx, l.ID, id)
+	if err != nil {
+		fail(c, http.StatusNotFound, "feed not found")
+		return
+	}
+	if err := h.mgr.RefreshFeed(ctx, f); err != nil {
+		fail(c, http.StatusBadGateway, "refresh failed: "+err.Error())
+		return
+	}
+	if f, err = h.mgr.Store().GetFeed(ctx, l.ID, id); err != nil {
+		fail(c, http.StatusInternalServerError, "failed to load feed")
+		return
+	}
+	c.JSON(http.StatusOK, f)
+}
+
+// Stats GET /api/v1/blocklists/:id/stats
+// Returns the list's entry count, the traffic its entries dropped since the
+// ruleset was last loaded, and the ten entries that dropped the most.
+func (h *BlockListHandler) Stats(c *gin.Context) {
+	l, ok := h.load(c)
+	if !ok {
+		return
+	}
+	ctx := c.Request.Context()
+	addrs, err := h.mgr.Store().EntryAddresses(ctx, l.ID)
+	if err != nil {
+		h.log.Error("list blocklist addresses", zap.Error(err))
+		fail(c, http.StatusInternalServerError, "failed to load entries")
+		return
+	}
+	counters, err := h.mgr.Counters(ctx)
+	if err != nil {
+		fail(c, http.StatusServiceUnavailable, "read set counters: "+err.Error())
+		return
+	}
+
+	type hit struct {
+		Address string `json:"address"`
+		blocklist.Counter
+	}
+	var total blocklist.Counter
+	var hits []hit
+	for _, a := range addrs {
+		ct, ok := blocklist.Lookup(counters, a)
+		if !ok || ct.Packets == 0 {
+			continue
+		}
+		total.Packets += ct.Packets
+		total.Bytes += ct.Bytes
+		hits = append(hits, hit{Address: a, Counter: ct})
+	}
+	sort.Slice(hits, func(i, j int) bool {
+		if hits[i].Packets != hits[j].Packets {
+			return hits[i].Packets > hits[j].Packets
+		}
+		return hits[i].Address < hits[j].Address
+	})
+	if len(hits) > 10 {
+		hits = hits[:10]
+	}
+	if hits == nil {
+		hits = []hit{}
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"entries": len(addrs),
+		"packets": total.Packets,
+		"bytes":   total.Bytes,
+		"top":     hits,
+	})
+}
+
+// Available aborts with 503 when block lists are disabled.
+func (h *BlockListHandler) Available(c *gin.Context) {
+	if h.mgr == nil {
+		Abort(c, http.StatusServiceUnavailable, CodeFeatureDisabled, "block lists are disabled")
+		return
+	}
+	c.Next()
+}
+
+// ─── Private helpers ──────────────────────────────────────────────────────
+
+func (h *BlockListHandler) load(c *gin.Context) (*store.BlockList, bool) {
+	id, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		fail(c, http.StatusBadRequest, "invalid id")
+		return nil, false
+	}
+	l, err := h.mgr.Store().Get(c.Request.Context(), id)
+	if err != nil {
+		fail(c, http.StatusNotFound, "blocklist not found")
+		return nil, false
+	}
+	return l, true
+}
+
+// sync pushes a change to the sets right away. A failure is only logged:
+// the periodic sync retries it.
+func (h *BlockListHandler) sync(c *gin.Context) {
+	if err := h.mgr.Sync(c.Request.Context()); err != nil {
+		h.log.Warn("sync blocklist sets", zap.Error(err))
+	}
+}
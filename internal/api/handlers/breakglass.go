@@ -0,0 +1,365 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"go.uber.org/zap"
+
+	"github.com/aegisx/aegisx/internal/auth"
+	"github.com/aegisx/aegisx/internal/config"
+	"github.com/aegisx/aegisx/internal/hooks"
+	"github.com/aegisx/aegisx/internal/store"
+)
+
+const defaultBreakGlassTTL = 30 * time.Minute
+
+// BreakGlassHandler handles /api/v1/breakglass endpoints: emergency admin
+// access, granted by the sealed code or by a second admin, that expires on
+// its own and is audited and announced at every step.
+type BreakGlassHandler struct {
+	cfg     *config.BreakGlassConfig
+	authSvc *auth.Service
+	store   *store.BreakGlassStore
+	audit   *store.AuditStore
+	hooks   *hooks.Runner
+	log     *zap.Logger
+}
+
+func NewBreakGlassHandler(cfg *config.BreakGlassConfig, authSvc *auth.Service, sessions *store.BreakGlassStore,
+	audit *store.AuditStore, hookRunner *hooks.Runner, log *zap.Logger) *BreakGlassHandler {
+	return &BreakGlassHandler{cfg: cfg, authSvc: authSvc, store: sessions, audit: audit, hooks: hookRunner, log: log}
+}
+
+// BreakGlassRequest is the body of POST /breakglass.
+type BreakGlassRequest struct {
+	Reason string `json:"reason" binding:"required"`
+	TTL    string `json:"ttl"`  // default 30m, at most auth.break_glass.max_ttl
+	Code   string `json:"code"` // the sealed emergency code; without it a second admin must approve
+}
+
+// Request POST /api/v1/breakglass
+// With the emergency code the session is granted at once and the token is
+// returned; otherwise it waits for another admin's approval.
+func (h *BreakGlassHandler) Request(c *gin.Context) {
+	if !h.ownCredentials(c) {
+		return
+	}
+	var req BreakGlassRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		fail(c, http.StatusBadRequest, err.Error())
+		return
+	}
+	if strings.TrimSpace(req.Reason) == "" {
+		fail(c, http.StatusBadRequest, "reason is required")
+		return
+	}
+	ttl := defaultBreakGlassTTL
+	if req.TTL != "" {
+		var err error
+		if ttl, err = time.ParseDuration(req.TTL); err != nil || ttl <= 0 {
+			fail(c, http.StatusBadRequest, "ttl must be a positive duration")
+			return
+		}
+	}
+	if ttl > h.cfg.MaxTTL {
+		fail(c, http.StatusBadRequest, "ttl must be at most "+h.cfg.MaxTTL.String())
+		return
+	}
+
+	session := &store.BreakGlass{
+		TenantID:   mustTenantID(c),
+		UserID:     callerID(c),
+		Reason:     req.Reason,
+		TTLSeconds: int(ttl.Seconds()),
+		Method:     "approval",
+	}
+	if req.Code != "" {
+		session.Method = "code"
+		if h.cfg.CodeHash == "" || !auth.CheckPassword(req.Code, h.cfg.CodeHash) {
+			h.log.Warn("break-glass code rejected",
+				zap.String("user", session.UserID.String()), zap.String("ip", c.ClientIP()))
+			h.record(c, store.AuditBreakGlassDeny, nil, "failure", gin.H{"reason": req.Reason})
+			h.hooks.NotifyBreakGlass(hooks.BreakGlassNotice{
+				TenantID: session.TenantID, UserID: session.UserID, Action: "denied",
+				Method: session.Method, Reason: session.Reason,
+			})
+			fail(c, http.StatusForbidden, "invalid break-glass code")
+			return
+		}
+	}
+
+	if err := h.store.Create(c.Request.Context(), session, session.Method == "code"); err != nil {
+		h.log.Error("create break-glass session", zap.Error(err))
+		fail(c, http.StatusInternalServerError, "failed to create break-glass session")
+		return
+	}
+
+	if session.Method == "approval" {
+		if !h.record(c, store.AuditBreakGlassRequest, session, "success", gin.H{"reason": session.Reason, "ttl": ttl.String()}) {
+			return
+		}
+		h.announce(session, "requested", nil)
+		c.JSON(http.StatusAccepted, gin.H{"session": session})
+		return
+	}
+
+	if !h.record(c, store.AuditBreakGlassGrant, session, "success", gin.H{"reason": session.Reason, "method": "code", "expiresAt": session.ExpiresAt}) {
+		return
+	}
+	h.announce(session, "granted", nil)
+	h.respondToken(c, http.StatusCreated, session)
+}
+
+// List GET /api/v1/breakglass
+// Admins see every session of the tenant, other users their own.
+func (h *BreakGlassHandler) List(c *gin.Context) {
+	var only *uuid.UUID
+	if !isAdmin(c) || c.GetBool("break_glass") {
+		id := callerID(c)
+		only = &id
+	}
+	items, err := h.store.List(c.Request.Context(), mustTenantID(c), only)
+	if err != nil {
+		h.log.Error("list break-glass sessions", zap.Error(err))
+		fail(c, http.StatusInternalServerError, "failed to list break-glass sessions")
+		return
+	}
+	if items == nil {
+		items = []*store.BreakGlass{}
+	}
+	c.JSON(http.StatusOK, gin.H{"items": items, "count": len(items)})
+}
+
+// Approve POST /api/v1/breakglass/:id/approve
+// A second admin grants a pending request; nobody approves their own.
+func (h *BreakGlassHandler) Approve(c *gin.Context) {
+	if !h.ownCredentials(c) {
+		return
+	}
+	session, ok := h.load(c)
+	if !ok {
+		return
+	}
+	approver := callerID(c)
+	if session.UserID == approver {
+		fail(c, http.StatusForbidden, "a break-glass request must be approved by another admin")
+		return
+	}
+	if !session.Pending() {
+		fail(c, http.StatusConflict, "break-glass request is not pending")
+		return
+	}
+
+	granted, err := h.store.Approve(c.Request.Context(), session.ID, approver)
+	if err != nil {
+		if strings.Contains(err.Error(), "not found") {
+			fail(c, http.StatusConflict, "break-glass request is not pending")
+			return
+		}
+		h.log.Error("approve break-glass session", zap.Error(err))
+		fail(c, http.StatusInternalServerError, "failed to approve break-glass request")
+		return
+	}
+	if !h.record(c, store.AuditBreakGlassGrant, granted, "success", gin.H{"reason": granted.Reason, "method": "approval", "expiresAt": granted.ExpiresAt}) {
+		return
+	}
+	h.announce(granted, "granted", &approver)
+	c.JSON(http.StatusOK, gin.H{"session": granted})
+}
+
+// Token POST /api/v1/breakglass/:id/token
+// Returns a token for the requester of a granted session.
+func (h *BreakGlassHandler) Token(c *gin.Context) {
+	if !h.ownCredentials(c) {
+		return
+	}
+	session, ok := h.load(c)
+	if !ok {
+		return
+	}
+	if session.UserID != callerID(c) {
+		fail(c, http.StatusForbidden, "only the requester can obtain the break-glass token")
+		return
+	}
+	if !session.Active() {
+		fail(c, http.StatusConflict, "break-glass session is not active")
+		return
+	}
+	h.respondToken(c, http.StatusOK, session)
+}
+
+// End DELETE /api/v1/breakglass/:id
+// The requester or an admin withdraws a request or ends a session early.
+func (h *BreakGlassHandler) End(c *gin.Context) {
+	session, ok := h.load(c)
+	if !ok {
+		return
+	}
+	by := callerID(c)
+	if session.UserID != by && !isAdmin(c) {
+		fail(c, http.StatusForbidden, "admin role required")
+		return
+	}
+	ended, err := h.store.End(c.Request.Context(), session.ID, by)
+	if err != nil {
+		if strings.Contains(err.Error(), "not found") {
+			fail(c, http.StatusConflict, "break-glass session has already ended")
+			return
+		}
+		h.log.Error("end break-glass session", zap.Error(err))
+		fail(c, http.StatusInternalServerError, "failed to end break-glass session")
+		return
+	}
+	h.record(c, store.AuditBreakGlassEnd, ended, "success", gin.H{"reason": ended.Reason})
+	h.announce(ended, "ended", &by)
+	c.JSON(http.StatusOK, gin.H{"session": ended})
+}
+
+// RecordBreakGlassRequest audits a request made with a break-glass token.
+// The server calls it after the handler has run.
+func (h *BreakGlassHandler) RecordBreakGlassRequest(c *gin.Context) {
+	val, ok := c.Get("break_glass_id")
+	if !ok {
+		return
+	}
+	sessionID := val.(uuid.UUID)
+	tenantID := mustTenantID(c)
+	actor := callerID(c)
+	status := "success"
+	if c.Writer.Status() >= 400 {
+		status = "failure"
+	}
+	detail, _ := json.Marshal(gin.H{
+		"breakGlassId": sessionID,
+		"method":       c.Request.Method,
+		"status":       c.Writer.Status(),
+		"requestId":    c.GetString("request_id"),
+	})
+	entry := &store.AuditEntry{
+		TenantID:   &tenantID,
+		UserID:     &actor,
+		Action:     store.AuditBreakGlassUse,
+		Resource:   c.FullPath(),
+		ResourceID: c.Param("id"),
+		Detail:     detail,
+		IPAddress:  c.ClientIP(),
+		UserAgent:  c.Request.UserAgent(),
+		Status:     status,
+	}
+	// The request context may already be cancelled by the time we get here.
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if err := h.audit.Record(ctx, entry); err != nil {
+		h.log.Error("audit break-glass request", zap.Error(err), zap.String("session", sessionID.String()))
+	}
+}
+
+// ─── Helpers ──────────────────────────────────────────────────────────────
+
+// ownCredentials refuses impersonation and break-glass tokens: emergency
+// access is requested, approved and collected as oneself.
+func (h *BreakGlassHandler) ownCredentials(c *gin.Context) bool {
+	if _, ok := c.Get("impersonation_id"); ok {
+		fail(c, http.StatusForbidden, "not permitted while impersonating")
+		return false
+	}
+	if c.GetBool("break_glass") {
+		fail(c, http.StatusForbidden, "not permitted with a break-glass token")
+		return false
+	}
+	return true
+}
+
+// load returns the session named by :id if it belongs to the caller's
+// tenant, writing the error response otherwise.
+func (h *BreakGlassHandler) load(c *gin.Context) (*store.BreakGlass, bool) {
+	id, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		fail(c, http.StatusBadRequest, "invalid id")
+		return nil, false
+	}
+	session, err := h.store.Get(c.Request.Context(), id)
+	if err != nil || session.TenantID != mustTenantID(c) {
+		if err == nil || strings.Contains(err.Error(), "not found") {
+			fail(c, http.StatusNotFound, "break-glass session not found")
+			return nil, false
+		}
+		h.log.Error("get break-glass session", zap.Error(err))
+		fail(c, http.StatusInternalServerError, "failed to load break-glass session")
+		return nil, false
+	}
+	return session, true
+}
+
+func (h *BreakGlassHandler) respondToken(c *gin.Context, status int, session *store.BreakGlass) {
+	token, err := h.authSvc.IssueBreakGlassToken(session.UserID, session.TenantID, session.ID, *session.ExpiresAt)
+	if err != nil {
+		h.log.Error("issue break-glass token", zap.Error(err))
+		fail(c, http.StatusInternalServerError, "failed to issue token")
+		return
+	}
+	c.JSON(status, gin.H{
+		"session":   session,
+		"token":     token,
+		"expiresIn": int(time.Until(*session.ExpiresAt).Seconds()),
+	})
+}
+
+// record writes an audit entry. Without the trail no access is handed out,
+// so a failure aborts with 500 and returns false.
+func (h *BreakGlassHandler) record(c *gin.Context, action string, session *store.BreakGlass, status string, detail gin.H) bool {
+	actor := callerID(c)
+	tenantID := mustTenantID(c)
+	entry := &store.AuditEntry{
+		TenantID:  &tenantID,
+		UserID:    &actor,
+		Action:    action,
+		Resource:  "break_glass",
+		IPAddress: c.ClientIP(),
+		UserAgent: c.Request.UserAgent(),
+		Status:    status,
+	}
+	if session != nil {
+		entry.ResourceID = session.ID.String()
+	}
+	entry.Detail, _ = json.Marshal(detail)
+	if err := h.audit.Record(c.Request.Context(), entry); err != nil {
+		h.log.Error("audit break-glass", zap.Error(err), zap.String("action", action))
+		if !c.IsAborted() {
+			fail(c, http.StatusInternalServerError, "failed to record break-glass event")
+		}
+		return false
+	}
+	return true
+}
+
+// announce logs the event loudly and sends it to the break-glass hooks.
+func (h *BreakGlassHandler) announce(session *store.BreakGlass, action string, by *uuid.UUID) {
+	fields := []zap.Field{
+		zap.String("session", session.ID.String()),
+		zap.String("tenant", session.TenantID.String()),
+		zap.String("user", session.UserID.String()),
+		zap.String("method", session.Method),
+		zap.String("reason", session.Reason),
+	}
+	if session.ExpiresAt != nil {
+		fields = append(fields, zap.Time("expires_at", *session.ExpiresAt))
+	}
+	h.log.Warn("break-glass "+action, fields...)
+	h.hooks.NotifyBreakGlass(hooks.BreakGlassNotice{
+		SessionID: session.ID,
+		TenantID:  session.TenantID,
+		UserID:    session.UserID,
+		Action:    action,
+		Method:    session.Method,
+		Reason:    session.Reason,
+		By:        by,
+		ExpiresAt: session.ExpiresAt,
+	})
+}
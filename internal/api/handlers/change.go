@@ -0,0 +1,198 @@
+package handlers
+
+import (
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"go.uber.org/zap"
+
+	"github.com/aegisx/aegisx/internal/admission"
+	"github.com/aegisx/aegisx/internal/store"
+)
+
+// ChangeHandler handles /api/v1/changes: the queue of proposed policies
+// that need a second person's approval before they are written.
+type ChangeHandler struct {
+	changes  *store.ChangeStore
+	policies *PolicyHandler
+	log      *zap.Logger
+}
+
+func NewChangeHandler(changes *store.ChangeStore, policies *PolicyHandler, log *zap.Logger) *ChangeHandler {
+	return &ChangeHandler{changes: changes, policies: policies, log: log}
+}
+
+type reviewRequest struct {
+	Comment string `json:"comment"`
+}
+
+// List GET /api/v1/changes?status=pending
+func (h *ChangeHandler) List(c *gin.Context) {
+	items, err := h.changes.List(c.Request.Context(), mustTenantID(c), c.Query("status"))
+	if err != nil {
+		h.log.Error("list policy changes", zap.Error(err))
+		fail(c, http.StatusInternalServerError, "failed to list changes")
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"items": items, "count": len(items)})
+}
+
+// Get GET /api/v1/changes/:id
+func (h *ChangeHandler) Get(c *gin.Context) {
+	ch, ok := h.load(c)
+	if !ok {
+		return
+	}
+	c.JSON(http.StatusOK, ch)
+}
+
+// Approve POST /api/v1/changes/:id/approve
+// Writes the proposed policy (creating it or replacing the one with the same
+// name). The requester cannot approve their own change.
+func (h *ChangeHandler) Approve(c *gin.Context) {
+	ch, ok := h.review(c)
+	if !ok {
+		return
+	}
+	ctx := c.Request.Context()
+	reviewer := callerID(c)
+
+	existing, err := h.policies.store.GetByName(ctx, ch.TenantID, ch.Namespace, ch.Name)
+	if err != nil && !strings.Contains(err.Error(), "not found") {
+		h.log.Error("lookup policy for change", zap.Error(err))
+		fail(c, http.StatusInternalServerError, "failed to look up policy")
+		return
+	}
+	if existing != nil && existing.Kind != ch.Kind {
+		fail(c, http.StatusConflict, "a "+existing.Kind+" named "+ch.Name+" already exists")
+		return
+	}
+	record := &store.PolicyRecord{Name: ch.Name, Namespace: ch.Namespace, Kind: ch.Kind, Spec: ch.Spec, RawYAML: ch.RawYAML}
+	if !h.policies.checkQuota(c, ch.TenantID, record, existing == nil) {
+		return
+	}
+	// Proposed changes carry no signature, so enforcement refuses them.
+	if _, ok := h.policies.verify(c, ch.RawYAML, ""); !ok {
+		return
+	}
+	op := admission.OpCreate
+	if existing != nil {
+		op = admission.OpUpdate
+	}
+	if !h.policies.admit(c, op, ch.Namespace, ch.Name, ch.Kind, ch.Spec, ch.RawYAML) {
+		return
+	}
+	if !h.policies.inNamespace(c, record) {
+		return
+	}
+	// The reviewer, not the proposer, answers for any raw rules.
+	if !h.policies.checkRawRules(c, record) {
+		return
+	}
+
+	if existing != nil {
+		existing.Spec = ch.Spec
+		existing.RawYAML = ch.RawYAML
+		existing.Signature, existing.Provenance = "", nil
+		err = h.policies.store.Update(ctx, existing)
+	} else {
+		existing = &store.PolicyRecord{
+			TenantID:  ch.TenantID,
+			Name:      ch.Name,
+			Namespace: ch.Namespace,
+			Kind:      ch.Kind,
+			Spec:      ch.Spec,
+			RawYAML:   ch.RawYAML,
+			Enabled:   true,
+			CreatedBy: &reviewer,
+		}
+		err = h.policies.store.Create(ctx, existing)
+	}
+	if err != nil {
+		h.log.Error("write policy for change", zap.Error(err), zap.String("change_id", ch.ID.String()))
+		fail(c, http.StatusInternalServerError, "failed to write policy")
+		return
+	}
+
+	ch.Status = "approved"
+	ch.PolicyID = &existing.ID
+	h.finish(c, ch)
+}
+
+// Reject POST /api/v1/changes/:id/reject
+func (h *ChangeHandler) Reject(c *gin.Context) {
+	ch, ok := h.review(c)
+	if !ok {
+		return
+	}
+	ch.Status = "rejected"
+	h.finish(c, ch)
+}
+
+// ─── Helpers ──────────────────────────────────────────────────────────────
+
+func (h *ChangeHandler) load(c *gin.Context) (*store.PolicyChange, bool) {
+	id, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		fail(c, http.StatusBadRequest, "invalid id")
+		return nil, false
+	}
+	ch, err := h.changes.Get(c.Request.Context(), mustTenantID(c), id)
+	if err != nil {
+		if strings.Contains(err.Error(), "not found") {
+			fail(c, http.StatusNotFound, "change not found")
+		} else {
+			fail(c, http.StatusInternalServerError, err.Error())
+		}
+		return nil, false
+	}
+	if !h.policies.authorize(c, ch.Namespace, false) {
+		return nil, false
+	}
+	return ch, true
+}
+
+// review loads a pending change and checks that the caller may decide on it.
+func (h *ChangeHandler) review(c *gin.Context) (*store.PolicyChange, bool) {
+	var req reviewRequest
+	if c.Request.ContentLength > 0 {
+		if err := c.ShouldBindJSON(&req); err != nil {
+			fail(c, http.StatusBadRequest, err.Error())
+			return nil, false
+		}
+	}
+	ch, ok := h.load(c)
+	if !ok {
+		return nil, false
+	}
+	if ch.Status != "pending" {
+		fail(c, http.StatusConflict, "change is already "+ch.Status)
+		return nil, false
+	}
+	if !h.policies.authorize(c, ch.Namespace, true) {
+		return nil, false
+	}
+	reviewer := callerID(c)
+	if ch.RequestedBy != nil && *ch.RequestedBy == reviewer {
+		fail(c, http.StatusForbidden, "a change cannot be reviewed by its requester")
+		return nil, false
+	}
+	ch.ReviewedBy = &reviewer
+	ch.ReviewComment = req.Comment
+	return ch, true
+}
+
+func (h *ChangeHandler) finish(c *gin.Context, ch *store.PolicyChange) {
+	if err := h.changes.Review(c.Request.Context(), ch); err != nil {
+		if strings.Contains(err.Error(), "already reviewed") {
+			fail(c, http.StatusConflict, err.Error())
+			return
+		}
+		h.log.Error("review policy change", zap.Error(err))
+		fail(c, http.StatusInternalServerError, "failed to record review")
+		return
+	}
+	c.JSON(http.StatusOK, ch)
+}
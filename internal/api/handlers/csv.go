@@ -0,0 +1,161 @@
+package handlers
+
+import (
+	"encoding/csv"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+// Row bounds of list endpoints. Exports are streamed, so they may be far
+// larger than a JSON page.
+const (
+	maxListRows   = 1000
+	maxExportRows = 100000
+)
+
+// csvFlushRows is how many rows an export buffers before flushing them to
+// the client.
+const csvFlushRows = 500
+
+// wantsCSV reports whether the caller asked for CSV, with ?format=csv or an
+// Accept header naming text/csv.
+func wantsCSV(c *gin.Context) bool {
+	if f := c.Query("format"); f != "" {
+		return f == "csv"
+	}
+	return strings.Contains(c.GetHeader("Accept"), "text/csv")
+}
+
+// exportWindow reads ?since= (default 24h), ?until= and ?limit= of a list
+// endpoint that can export CSV, and writes a 400 when one is invalid.
+func exportWindow(c *gin.Context, asCSV bool) (since, until time.Time, limit int, ok bool) {
+	d, err := time.ParseDuration(c.DefaultQuery("since", "24h"))
+	if err != nil || d <= 0 {
+		fail(c, http.StatusBadRequest, "since must be a positive duration such as 24h")
+		return since, until, 0, false
+	}
+	since = time.Now().Add(-d)
+	if v := c.Query("until"); v != "" {
+		if until, err = time.Parse(time.RFC3339, v); err != nil {
+			fail(c, http.StatusBadRequest, "until must be an RFC 3339 time")
+			return since, until, 0, false
+		}
+	}
+	maxRows, def := maxListRows, 100
+	if asCSV {
+		maxRows, def = maxExportRows, maxExportRows
+	}
+	limit = def
+	if v := c.Query("limit"); v != "" {
+		if limit, err = strconv.Atoi(v); err != nil || limit <= 0 || limit > maxRows {
+			fail(c, http.StatusBadRequest, fmt.Sprintf("limit must be between 1 and %d", maxRows))
+			return since, until, 0, false
+		}
+	}
+	return since, until, limit, true
+}
+
+// csvExport streams rows to the client as a CSV attachment. Nothing is
+// written before the first row, so an error up to then can still be
+// answered with a JSON error; see Started.
+type csvExport struct {
+	c      *gin.Context
+	name   string
+	header []string
+	w      *csv.Writer
+	rows   int
+}
+
+// newCSVExport prepares an export downloaded as name-<time>.csv whose first
+// row is header.
+func newCSVExport(c *gin.Context, name string, header ...string) *csvExport {
+	return &csvExport{c: c, name: name, header: header}
+}
+
+// Write sends one row.
+func (e *csvExport) Write(row ...string) error {
+	if e.w == nil {
+		e.start()
+	}
+	for i, v := range row {
+		row[i] = csvCell(v)
+	}
+	if err := e.w.Write(row); err != nil {
+		return err
+	}
+	e.rows++
+	if e.rows%csvFlushRows == 0 {
+		e.w.Flush()
+		e.c.Writer.Flush()
+	}
+	return e.w.Error()
+}
+
+// Started reports whether the response is under way.
+func (e *csvExport) Started() bool { return e.w != nil }
+
+// Close sends the header of an export without rows and flushes the rest.
+func (e *csvExport) Close() error {
+	if e.w == nil {
+		e.start()
+	}
+	e.w.Flush()
+	return e.w.Error()
+}
+
+func (e *csvExport) start() {
+	name := fmt.Sprintf("%s-%s.csv", e.name, time.Now().UTC().Format("20060102T150405Z"))
+	e.c.Header("Content-Type", "text/csv; charset=utf-8")
+	e.c.Header("Content-Disposition", `attachment; filename="`+name+`"`)
+	e.c.Status(http.StatusOK)
+	e.w = csv.NewWriter(e.c.Writer)
+	e.w.Write(e.header)
+}
+
+// csvCell defuses values a spreadsheet would run as a formula by prefixing
+// them with a quote. Numbers, negative ones included, are left alone.
+func csvCell(v string) string {
+	if v == "" {
+		return v
+	}
+	switch v[0] {
+	case '=', '+', '@', '\t', '\r':
+		return "'" + v
+	case '-':
+		if _, err := strconv.ParseFloat(v, 64); err != nil {
+			return "'" + v
+		}
+	}
+	return v
+}
+
+// csvTime formats t for an export; the zero time is an empty cell.
+func csvTime(t time.Time) string {
+	if t.IsZero() {
+		return ""
+	}
+	return t.UTC().Format(time.RFC3339)
+}
+
+// csvInt formats n for an export; zero, which the stores use for unknown,
+// is an empty cell.
+func csvInt(n int64) string {
+	if n == 0 {
+		return ""
+	}
+	return strconv.FormatInt(n, 10)
+}
+
+// csvUUID formats an optional ID for an export.
+func csvUUID(id *uuid.UUID) string {
+	if id == nil {
+		return ""
+	}
+	return id.String()
+}
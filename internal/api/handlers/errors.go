@@ -0,0 +1,94 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/aegisx/aegisx/internal/policy"
+)
+
+// Error codes are part of the API contract (see docs/API_ERRORS.md):
+// automation matches on them, so existing codes are never renamed or reused.
+const (
+	CodeInvalidRequest   = "invalid_request"    // malformed body, parameter or policy
+	CodeUnauthenticated  = "unauthenticated"    // missing or invalid token
+	CodeForbidden        = "forbidden"          // authenticated but not allowed
+	CodeNotFound         = "not_found"          // the addressed resource does not exist
+	CodeConflict         = "conflict"           // clashes with existing state
+	CodeChangeFrozen     = "change_frozen"      // a change freeze window is open; details has the reason
+	CodeQuotaExceeded    = "quota_exceeded"     // a namespace quota would be exceeded
+	CodeValidationFailed = "validation_failed"  // policy validation; details lists each problem
+	CodePolicyTestFailed = "policy_test_failed" // PolicyTest expectations broke; details lists each failure
+	CodeRulesetTooLarge  = "ruleset_too_large"  // compiled ruleset exceeds the configured limits; details lists each
+	CodeAdmissionDenied  = "admission_denied"   // rejected by admission rules; details lists violations
+	CodeSignatureInvalid = "signature_invalid"  // the manifest is unsigned or its signature does not verify
+	CodeFeatureDisabled  = "feature_disabled"   // the subsystem or licensed feature is off
+	CodeUnavailable      = "unavailable"        // temporarily unable to answer; retry later
+	CodeReadOnly         = "read_only"          // the API or the tenant is read-only; details has the reason
+	CodeUpstreamError    = "upstream_error"     // a managed daemon (HAProxy, Suricata, …) failed
+	CodeInternal         = "internal"           // unexpected server-side failure
+)
+
+// ErrorBody is the payload of every error response, wrapped as {"error": …}.
+type ErrorBody struct {
+	Code      string   `json:"code"`
+	Message   string   `json:"message"`
+	Details   []string `json:"details,omitempty"`
+	RequestID string   `json:"requestId,omitempty"`
+}
+
+// statusCodes gives the code used when a handler does not pick one.
+var statusCodes = map[int]string{
+	http.StatusBadRequest:          CodeInvalidRequest,
+	http.StatusUnauthorized:        CodeUnauthenticated,
+	http.StatusForbidden:           CodeForbidden,
+	http.StatusNotFound:            CodeNotFound,
+	http.StatusConflict:            CodeConflict,
+	http.StatusUnprocessableEntity: CodeValidationFailed,
+	http.StatusBadGateway:          CodeUpstreamError,
+	http.StatusServiceUnavailable:  CodeUnavailable,
+}
+
+// fail writes an error response whose code follows from the status.
+func fail(c *gin.Context, status int, msg string, details ...string) {
+	code, ok := statusCodes[status]
+	if !ok {
+		code = CodeInternal
+	}
+	Abort(c, status, code, msg, details...)
+}
+
+// failErr writes err, lifting policy validation, PolicyTest and ruleset
+// limit errors into a 422 with one detail per problem; other errors get
+// status and msg.
+func failErr(c *gin.Context, status int, msg string, err error) {
+	var ve *policy.ValidationError
+	if errors.As(err, &ve) {
+		Abort(c, http.StatusUnprocessableEntity, CodeValidationFailed, "policy validation failed", ve.Errors...)
+		return
+	}
+	var te *policy.TestError
+	if errors.As(err, &te) {
+		Abort(c, http.StatusUnprocessableEntity, CodePolicyTestFailed, "policy tests failed", te.Failures...)
+		return
+	}
+	var le *policy.LimitError
+	if errors.As(err, &le) {
+		Abort(c, http.StatusUnprocessableEntity, CodeRulesetTooLarge, "ruleset limits exceeded", le.Violations...)
+		return
+	}
+	fail(c, status, msg+": "+err.Error())
+}
+
+// Abort writes an error response with an explicit code and stops the chain.
+// Middleware outside this package uses it too.
+func Abort(c *gin.Context, status int, code, msg string, details ...string) {
+	c.AbortWithStatusJSON(status, gin.H{"error": ErrorBody{
+		Code:      code,
+		Message:   msg,
+		Details:   details,
+		RequestID: c.GetString("request_id"),
+	}})
+}
@@ -0,0 +1,60 @@
+package handlers
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// writeCached writes body as a 200 with ETag and Last-Modified, or a bare
+// 304 when the client's copy (If-None-Match, else If-Modified-Since) is
+// current. An empty etag is derived from the body, which is always correct;
+// callers pass one only when they know a cheaper stable identity.
+func writeCached(c *gin.Context, etag string, modified time.Time, body any) {
+	data, err := json.Marshal(body)
+	if err != nil {
+		fail(c, http.StatusInternalServerError, "encode response: "+err.Error())
+		return
+	}
+	if etag == "" {
+		sum := sha256.Sum256(data)
+		etag = hex.EncodeToString(sum[:16])
+	}
+	etag = `"` + etag + `"`
+
+	c.Header("ETag", etag)
+	c.Header("Cache-Control", "private, no-cache")
+	if !modified.IsZero() {
+		c.Header("Last-Modified", modified.UTC().Format(http.TimeFormat))
+	}
+
+	if inm := c.GetHeader("If-None-Match"); inm != "" {
+		if etagMatches(inm, etag) {
+			c.Status(http.StatusNotModified)
+			return
+		}
+	} else if ims := c.GetHeader("If-Modified-Since"); ims != "" && !modified.IsZero() {
+		// HTTP dates have second precision.
+		if t, err := http.ParseTime(ims); err == nil && !modified.Truncate(time.Second).After(t) {
+			c.Status(http.StatusNotModified)
+			return
+		}
+	}
+	c.Data(http.StatusOK, "application/json; charset=utf-8", data)
+}
+
+// etagMatches implements the weak comparison If-None-Match calls for.
+func etagMatches(header, etag string) bool {
+	for _, candidate := range strings.Split(header, ",") {
+		candidate = strings.TrimSpace(candidate)
+		if candidate == "*" || strings.TrimPrefix(candidate, "W/") == etag {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,82 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"sort"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+// parseFields reads ?fields=a,b and checks each name against the JSON fields
+// of sample. It returns nil when the parameter is absent and writes a 400
+// for unknown names.
+func parseFields(c *gin.Context, sample any) ([]string, bool) {
+	raw := c.Query("fields")
+	if raw == "" {
+		return nil, true
+	}
+	known, err := toMap(sample)
+	if err != nil {
+		fail(c, http.StatusInternalServerError, err.Error())
+		return nil, false
+	}
+
+	var fields []string
+	for _, f := range strings.Split(raw, ",") {
+		f = strings.TrimSpace(f)
+		if f == "" {
+			continue
+		}
+		if _, ok := known[f]; !ok {
+			valid := make([]string, 0, len(known))
+			for k := range known {
+				valid = append(valid, k)
+			}
+			sort.Strings(valid)
+			fail(c, http.StatusBadRequest, "unknown field "+f, "valid fields: "+strings.Join(valid, ", "))
+			return nil, false
+		}
+		fields = append(fields, f)
+	}
+	return fields, true
+}
+
+// hasField reports whether fields selects name; nil selects everything.
+func hasField(fields []string, name string) bool {
+	if fields == nil {
+		return true
+	}
+	for _, f := range fields {
+		if f == name {
+			return true
+		}
+	}
+	return false
+}
+
+// project returns v reduced to fields, or v itself when fields is nil.
+func project(v any, fields []string) (any, error) {
+	if fields == nil {
+		return v, nil
+	}
+	m, err := toMap(v)
+	if err != nil {
+		return nil, err
+	}
+	out := make(map[string]any, len(fields))
+	for _, f := range fields {
+		out[f] = m[f]
+	}
+	return out, nil
+}
+
+func toMap(v any) (map[string]any, error) {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+	var m map[string]any
+	return m, json.Unmarshal(data, &m)
+}
@@ -1,34 +1,73 @@
 package handlers
 
 import (
+	"bytes"
 	"net/http"
+	"net/netip"
+	"strconv"
+	"strings"
+	"time"
 
 	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
 	"go.uber.org/zap"
 
 	"github.com/aegisx/aegisx/internal/firewall"
 	"github.com/aegisx/aegisx/internal/policy"
+	"github.com/aegisx/aegisx/internal/store"
 )
 
 // FirewallHandler handles /api/v1/firewall endpoints.
 type FirewallHandler struct {
-	svc    *firewall.Service
-	parser *policy.Parser
-	log    *zap.Logger
+	svc      *firewall.Service
+	policies *store.PolicyStore
+	events   *store.EventStore
+	parser   *policy.Parser
+	log      *zap.Logger
 }
 
-func NewFirewallHandler(svc *firewall.Service, log *zap.Logger) *FirewallHandler {
-	return &FirewallHandler{svc: svc, parser: policy.NewParser(), log: log}
+// maxTestFlows caps the flows of one /firewall/test request, including
+// those read from a pcap.
+const maxTestFlows = 10000
+
+// FlowTestRequest is the body of POST /api/v1/firewall/test.
+type FlowTestRequest struct {
+	RawYAML       string        `json:"rawYaml"` // candidate policy set; empty tests the applied one
+	Flows         []policy.Flow `json:"flows"`
+	Pcap          []byte        `json:"pcap"`          // base64 classic pcap; each connection becomes a flow
+	PcapDirection string        `json:"pcapDirection"` // chain for pcap flows (default forward)
+}
+
+// SimulateRequest is the body of POST /api/v1/firewall/simulate: the
+// packet's 5-tuple and interfaces, and optionally a candidate policy set.
+type SimulateRequest struct {
+	policy.Flow
+	RawYAML string `json:"rawYaml"` // empty simulates the applied policy
 }
 
-// Status GET /api/v1/firewall/status
+func NewFirewallHandler(svc *firewall.Service, policies *store.PolicyStore, events *store.EventStore, log *zap.Logger) *FirewallHandler {
+	return &FirewallHandler{svc: svc, policies: policies, events: events, parser: svc.Parser(), log: log}
+}
+
+// Status GET /api/v1/firewall/status?format=text
+// Returns the live table as parsed nft JSON, or as nft text with
+// format=text, with the kernel's view of it: nft version, table handle,
+// rules per chain, the last apply and whether a rollback snapshot exists.
 func (h *FirewallHandler) Status(c *gin.Context) {
-	ruleset, err := h.svc.Status()
+	kernel := h.svc.KernelStatus(c.Request.Context())
+	var ruleset any
+	var err error
+	if c.Query("format") == "text" {
+		ruleset, err = h.svc.StatusText()
+	} else {
+		ruleset, err = h.svc.Status()
+	}
 	if err != nil {
 		h.log.Warn("firewall status unavailable", zap.Error(err))
 		c.JSON(http.StatusOK, gin.H{
 			"status":  "unknown",
 			"message": err.Error(),
+			"kernel":  kernel,
 		})
 		return
 	}
@@ -37,6 +76,7 @@ func (h *FirewallHandler) Status(c *gin.Context) {
 	resp := gin.H{
 		"status":  "active",
 		"ruleset": ruleset,
+		"kernel":  kernel,
 	}
 	if ir != nil {
 		resp["irId"] = ir.ID
@@ -52,7 +92,7 @@ func (h *FirewallHandler) Status(c *gin.Context) {
 func (h *FirewallHandler) ApplyDir(c *gin.Context) {
 	if err := h.svc.ApplyPolicyDir(c.Request.Context()); err != nil {
 		h.log.Error("apply policy dir failed", zap.Error(err))
-		c.JSON(http.StatusInternalServerError, errResp("apply failed: "+err.Error()))
+		failErr(c, http.StatusInternalServerError, "apply failed", err)
 		return
 	}
 	c.JSON(http.StatusOK, gin.H{"status": "applied"})
@@ -62,7 +102,7 @@ func (h *FirewallHandler) ApplyDir(c *gin.Context) {
 func (h *FirewallHandler) Rollback(c *gin.Context) {
 	if err := h.svc.Rollback(c.Request.Context()); err != nil {
 		h.log.Error("rollback failed", zap.Error(err))
-		c.JSON(http.StatusInternalServerError, errResp("rollback failed: "+err.Error()))
+		fail(c, http.StatusInternalServerError, "rollback failed: "+err.Error())
 		return
 	}
 	c.JSON(http.StatusOK, gin.H{"status": "rolled back"})
@@ -72,23 +112,326 @@ func (h *FirewallHandler) Rollback(c *gin.Context) {
 func (h *FirewallHandler) Flush(c *gin.Context) {
 	if err := h.svc.Flush(c.Request.Context()); err != nil {
 		h.log.Error("flush failed", zap.Error(err))
-		c.JSON(http.StatusInternalServerError, errResp("flush failed: "+err.Error()))
+		fail(c, http.StatusInternalServerError, "flush failed: "+err.Error())
 		return
 	}
 	c.JSON(http.StatusOK, gin.H{"status": "flushed"})
 }
 
 // ListRules GET /api/v1/firewall/rules
-// Returns the compiled rules from the current IR.
+// Returns the compiled rules from the current IR. An IR never changes once
+// compiled, so its ID serves as the ETag.
 func (h *FirewallHandler) ListRules(c *gin.Context) {
 	ir := h.svc.CurrentIR()
 	if ir == nil {
-		c.JSON(http.StatusOK, gin.H{"items": []any{}, "count": 0})
+		writeCached(c, "ir-none", time.Time{}, gin.H{"items": []any{}, "count": 0})
 		return
 	}
-	c.JSON(http.StatusOK, gin.H{
+	writeCached(c, "ir-"+ir.ID, ir.CreatedAt, gin.H{
 		"items": ir.FirewallRules,
 		"count": len(ir.FirewallRules),
 		"irId":  ir.ID,
 	})
 }
+
+// RuleSource is the origin of one live nft rule.
+type RuleSource struct {
+	Handle    int    `json:"handle"`
+	Chain     string `json:"chain"`
+	Comment   string `json:"comment"`
+	Origin    string `json:"origin"` // policy | builtin | unknown
+	Namespace string `json:"namespace,omitempty"`
+	Policy    string `json:"policy,omitempty"`
+	Rule      string `json:"rule,omitempty"`
+
+	// Set when the policy is stored in the API rather than only in the
+	// policy directory.
+	PolicyID  *uuid.UUID `json:"policyId,omitempty"`
+	Revision  int        `json:"revision,omitempty"`
+	Author    *uuid.UUID `json:"author,omitempty"`
+	ChangedAt *time.Time `json:"changedAt,omitempty"`
+	AppliedAt *time.Time `json:"appliedAt,omitempty"`
+}
+
+// RuleSource GET /api/v1/firewall/rules/:handle/source
+// Maps a live rule handle back to the policy, rule, revision and author it
+// was generated from, via the "namespace/policy/rule" comment every policy
+// rule carries. Rules AegisX adds itself are reported as builtin; rules
+// without a comment were not added by AegisX.
+func (h *FirewallHandler) RuleSource(c *gin.Context) {
+	handle, err := strconv.Atoi(c.Param("handle"))
+	if err != nil || handle <= 0 {
+		fail(c, http.StatusBadRequest, "invalid handle")
+		return
+	}
+	rs, err := h.svc.Status()
+	if err != nil {
+		fail(c, http.StatusServiceUnavailable, "read ruleset: "+err.Error())
+		return
+	}
+	rule, ok := rs.Rule(handle)
+	if !ok {
+		fail(c, http.StatusNotFound, "rule not found")
+		return
+	}
+
+	src := RuleSource{Handle: rule.Handle, Chain: rule.Chain, Comment: rule.Comment, Origin: "unknown"}
+	parts := strings.SplitN(rule.Comment, "/", 3)
+	switch {
+	case len(parts) == 3:
+		src.Origin = "policy"
+		src.Namespace, src.Policy, src.Rule = parts[0], parts[1], parts[2]
+	case rule.Comment != "":
+		src.Origin = "builtin"
+	}
+	if src.Origin != "policy" {
+		c.JSON(http.StatusOK, src)
+		return
+	}
+
+	ctx := c.Request.Context()
+	tenantID := mustTenantID(c)
+	record, err := h.policies.GetByName(ctx, tenantID, src.Namespace, src.Policy)
+	if err != nil {
+		// Applied from the policy directory, or deleted since.
+		c.JSON(http.StatusOK, src)
+		return
+	}
+	src.PolicyID = &record.ID
+	src.AppliedAt = record.AppliedAt
+
+	revs, err := h.policies.ListRevisions(ctx, tenantID, record.ID)
+	if err != nil {
+		fail(c, http.StatusInternalServerError, "list revisions failed")
+		return
+	}
+	// The live rule comes from the newest revision saved before the last
+	// apply; later edits are not loaded yet.
+	for _, r := range revs {
+		if record.AppliedAt == nil || !r.ChangedAt.After(*record.AppliedAt) {
+			src.Revision = r.Version
+			src.Author = r.ChangedBy
+			changed := r.ChangedAt
+			src.ChangedAt = &changed
+			break
+		}
+	}
+	c.JSON(http.StatusOK, src)
+}
+
+// Analysis GET /api/v1/firewall/analysis
+// Suggests priority changes that move frequently hit rules earlier and lists
+// rules without a hit for ?unusedAfter= (default from config, e.g. "720h").
+func (h *FirewallHandler) Analysis(c *gin.Context) {
+	var unusedAfter time.Duration
+	if v := c.Query("unusedAfter"); v != "" {
+		d, err := time.ParseDuration(v)
+		if err != nil || d <= 0 {
+			fail(c, http.StatusBadRequest, "unusedAfter must be a positive duration such as 720h")
+			return
+		}
+		unusedAfter = d
+	}
+	c.JSON(http.StatusOK, h.svc.HitAnalysis(unusedAfter))
+}
+
+// Test POST /api/v1/firewall/test
+// Runs synthetic flows, or the connections of a pcap, through a candidate
+// policy set and returns the verdict of each. Flows with "expect" are
+// checked; CI can fail the build on a non-zero "failed".
+func (h *FirewallHandler) Test(c *gin.Context) {
+	var req FlowTestRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		fail(c, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	flows := req.Flows
+	if len(req.Pcap) > 0 {
+		captured, err := policy.FlowsFromPcap(bytes.NewReader(req.Pcap), maxTestFlows+1)
+		if err != nil {
+			fail(c, http.StatusBadRequest, "pcap: "+err.Error())
+			return
+		}
+		for i := range captured {
+			captured[i].Direction = req.PcapDirection
+		}
+		flows = append(flows, captured...)
+	}
+	if len(flows) == 0 {
+		fail(c, http.StatusBadRequest, "flows or pcap is required")
+		return
+	}
+	if len(flows) > maxTestFlows {
+		fail(c, http.StatusBadRequest, "too many flows (max 10000)")
+		return
+	}
+
+	var manifests []*policy.Manifest
+	if req.RawYAML != "" {
+		ms, err := h.parser.ParseReader(strings.NewReader(req.RawYAML))
+		if err != nil {
+			fail(c, http.StatusBadRequest, "parse policy: "+err.Error())
+			return
+		}
+		manifests = ms
+	}
+
+	results, err := h.svc.TestFlows(manifests, flows)
+	if err != nil {
+		failErr(c, http.StatusUnprocessableEntity, "test failed", err)
+		return
+	}
+	passed, failed := 0, 0
+	for _, r := range results {
+		switch {
+		case r.Pass == nil:
+		case *r.Pass:
+			passed++
+		default:
+			failed++
+		}
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"items":  results,
+		"count":  len(results),
+		"passed": passed,
+		"failed": failed,
+	})
+}
+
+// Simulate POST /api/v1/firewall/simulate
+// Reports what would happen to the first packet of a connection: the DNAT
+// rewriting it, every rule it matches, the deciding rule and verdict, and
+// the SNAT on the way out. Nothing touches the kernel.
+func (h *FirewallHandler) Simulate(c *gin.Context) {
+	var req SimulateRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		fail(c, http.StatusBadRequest, err.Error())
+		return
+	}
+	for _, a := range []struct{ field, value string }{{"src", req.Src}, {"dst", req.Dst}} {
+		if _, err := netip.ParseAddr(a.value); err != nil {
+			fail(c, http.StatusBadRequest, a.field+" must be an IP address")
+			return
+		}
+	}
+	for _, p := range []int{req.SrcPort, req.DstPort} {
+		if p < 0 || p > 65535 {
+			fail(c, http.StatusBadRequest, "ports must be between 0 and 65535")
+			return
+		}
+	}
+
+	var manifests []*policy.Manifest
+	if req.RawYAML != "" {
+		ms, err := h.parser.ParseReader(strings.NewReader(req.RawYAML))
+		if err != nil {
+			fail(c, http.StatusBadRequest, "parse policy: "+err.Error())
+			return
+		}
+		manifests = ms
+	}
+
+	trace, err := h.svc.Trace(manifests, req.Flow)
+	if err != nil {
+		failErr(c, http.StatusUnprocessableEntity, "simulation failed", err)
+		return
+	}
+	c.JSON(http.StatusOK, trace)
+}
+
+// Health GET /api/v1/firewall/health
+// Returns the state of the health-check targets that gate failover rules.
+func (h *FirewallHandler) Health(c *gin.Context) {
+	items := h.svc.HealthStatus()
+	c.JSON(http.StatusOK, gin.H{"items": items, "count": len(items)})
+}
+
+// Scans GET /api/v1/firewall/scans
+// Returns the sources currently flagged by port scan detection.
+func (h *FirewallHandler) Scans(c *gin.Context) {
+	items, err := h.svc.ScanStatus()
+	if err != nil {
+		h.log.Error("list flagged scanners", zap.Error(err))
+		fail(c, http.StatusInternalServerError, "failed to read scan set")
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"items": items, "count": len(items)})
+}
+
+// Events GET /api/v1/firewall/events?since=24h&until=&action=drop&limit=100
+// Returns the packets logged by rules with log enabled, newest first. With
+// ?format=csv or an Accept header of text/csv they are streamed as a CSV
+// download of up to 100000 rows instead.
+func (h *FirewallHandler) Events(c *gin.Context) {
+	asCSV := wantsCSV(c)
+	since, until, limit, ok := exportWindow(c, asCSV)
+	if !ok {
+		return
+	}
+	q := store.EventQuery{Since: since, Until: until, Action: c.Query("action"), Limit: limit}
+
+	if asCSV {
+		exp := newCSVExport(c, "firewall-events", "timestamp", "rule", "action", "in_iface",
+			"out_iface", "protocol", "src_ip", "src_port", "dst_ip", "dst_port",
+			"country", "asn", "as_org")
+		err := h.events.EachFirewallEvent(c.Request.Context(), q, func(e *store.FirewallEvent) error {
+			return exp.Write(csvTime(e.Timestamp), e.Rule, e.Action, e.InIface, e.OutIface,
+				e.Protocol, e.SrcIP, csvInt(int64(e.SrcPort)), e.DstIP, csvInt(int64(e.DstPort)),
+				e.Country, csvInt(int64(e.ASN)), e.ASOrg)
+		})
+		if err == nil {
+			err = exp.Close()
+		}
+		if err != nil {
+			h.log.Error("export firewall events", zap.Error(err))
+			if !exp.Started() {
+				fail(c, http.StatusInternalServerError, "failed to export events")
+			}
+		}
+		return
+	}
+
+	items := []*store.FirewallEvent{}
+	err := h.events.EachFirewallEvent(c.Request.Context(), q, func(e *store.FirewallEvent) error {
+		items = append(items, e)
+		return nil
+	})
+	if err != nil {
+		h.log.Error("list firewall events", zap.Error(err))
+		fail(c, http.StatusInternalServerError, "failed to list events")
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"items": items, "count": len(items)})
+}
+
+// NetNamespaces GET /api/v1/firewall/netns
+// Returns the network namespaces found on the host, with the number of
+// applied rules targeting each and whether they are loaded. Namespaces
+// targeted by policies that do not exist yet are listed without a path.
+func (h *FirewallHandler) NetNamespaces(c *gin.Context) {
+	items := h.svc.NetNamespaces()
+	c.JSON(http.StatusOK, gin.H{"items": items, "count": len(items)})
+}
+
+// WANUplinks GET /api/v1/wan/uplinks
+// Returns health, steering state and byte counters of each WAN uplink.
+func (h *FirewallHandler) WANUplinks(c *gin.Context) {
+	items := h.svc.WANStatus()
+	c.JSON(http.StatusOK, gin.H{"items": items, "count": len(items)})
+}
+
+// WANPaths GET /api/v1/wan/paths
+// Returns each WAN path with the uplink it currently steers over and why.
+func (h *FirewallHandler) WANPaths(c *gin.Context) {
+	items := h.svc.WANPaths()
+	c.JSON(http.StatusOK, gin.H{"items": items, "count": len(items)})
+}
+
+// VRFs GET /api/v1/vrfs
+// Returns the applied VRFs with their interfaces, routes and link state.
+func (h *FirewallHandler) VRFs(c *gin.Context) {
+	items := h.svc.VRFStatus()
+	c.JSON(http.StatusOK, gin.H{"items": items, "count": len(items)})
+}
@@ -0,0 +1,103 @@
+package handlers
+
+import (
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"go.uber.org/zap"
+
+	"github.com/aegisx/aegisx/internal/store"
+)
+
+// FreezeHandler handles /api/v1/freezes endpoints.
+type FreezeHandler struct {
+	store *store.FreezeStore
+	log   *zap.Logger
+}
+
+func NewFreezeHandler(s *store.FreezeStore, log *zap.Logger) *FreezeHandler {
+	return &FreezeHandler{store: s, log: log}
+}
+
+type CreateFreezeRequest struct {
+	Reason   string    `json:"reason"   binding:"required"`
+	StartsAt time.Time `json:"startsAt"` // default now
+	EndsAt   time.Time `json:"endsAt"   binding:"required"`
+}
+
+// List GET /api/v1/freezes?all=true
+// Returns the current and upcoming windows, or all of them with all=true.
+func (h *FreezeHandler) List(c *gin.Context) {
+	items, err := h.store.List(c.Request.Context(), mustTenantID(c), c.Query("all") == "true")
+	if err != nil {
+		h.log.Error("list freeze windows", zap.Error(err))
+		fail(c, http.StatusInternalServerError, "failed to list freeze windows")
+		return
+	}
+	if items == nil {
+		items = []*store.FreezeWindow{}
+	}
+	c.JSON(http.StatusOK, gin.H{"items": items, "count": len(items)})
+}
+
+// Create POST /api/v1/freezes
+func (h *FreezeHandler) Create(c *gin.Context) {
+	var req CreateFreezeRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		fail(c, http.StatusBadRequest, err.Error())
+		return
+	}
+	if strings.TrimSpace(req.Reason) == "" {
+		fail(c, http.StatusBadRequest, "reason is required")
+		return
+	}
+	if req.StartsAt.IsZero() {
+		req.StartsAt = time.Now()
+	}
+	if !req.EndsAt.After(req.StartsAt) || !req.EndsAt.After(time.Now()) {
+		fail(c, http.StatusBadRequest, "endsAt must be in the future and after startsAt")
+		return
+	}
+
+	creator := callerID(c)
+	w := &store.FreezeWindow{
+		TenantID:  mustTenantID(c),
+		Reason:    req.Reason,
+		StartsAt:  req.StartsAt,
+		EndsAt:    req.EndsAt,
+		CreatedBy: &creator,
+	}
+	if err := h.store.Create(c.Request.Context(), w); err != nil {
+		h.log.Error("create freeze window", zap.Error(err))
+		fail(c, http.StatusInternalServerError, "failed to create freeze window")
+		return
+	}
+	h.log.Info("change freeze scheduled",
+		zap.String("id", w.ID.String()),
+		zap.Time("starts_at", w.StartsAt), zap.Time("ends_at", w.EndsAt),
+		zap.String("reason", w.Reason))
+	c.JSON(http.StatusCreated, w)
+}
+
+// Delete DELETE /api/v1/freezes/:id
+// Cancels a window, or lifts it early if it is open.
+func (h *FreezeHandler) Delete(c *gin.Context) {
+	id, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		fail(c, http.StatusBadRequest, "invalid id")
+		return
+	}
+	if err := h.store.Delete(c.Request.Context(), mustTenantID(c), id); err != nil {
+		if strings.Contains(err.Error(), "not found") {
+			fail(c, http.StatusNotFound, "freeze window not found")
+			return
+		}
+		h.log.Error("delete freeze window", zap.Error(err))
+		fail(c, http.StatusInternalServerError, "failed to delete freeze window")
+		return
+	}
+	c.Status(http.StatusNoContent)
+}
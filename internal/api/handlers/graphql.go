@@ -0,0 +1,264 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"runtime"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+
+	"github.com/aegisx/aegisx/internal/api/authz"
+	"github.com/aegisx/aegisx/internal/firewall"
+	"github.com/aegisx/aegisx/internal/graphql"
+	"github.com/aegisx/aegisx/internal/store"
+	"github.com/aegisx/aegisx/internal/wan"
+	"github.com/aegisx/aegisx/pkg/version"
+)
+
+// maxQueryBytes bounds the query document of a GraphQL request, and
+// maxRequestBytes a posted request, variables included.
+const (
+	maxQueryBytes   = 64 << 10
+	maxRequestBytes = 256 << 10
+)
+
+// GraphQLHandler handles /api/v1/graphql, which answers in one round trip
+// what the dashboard would otherwise fetch from many REST endpoints. Each
+// field checks the permission its REST counterpart needs.
+type GraphQLHandler struct {
+	enabled    bool
+	policies   *store.PolicyStore
+	namespaces *store.NamespaceStore
+	ids        *store.IDSStore
+	vpn        *store.VPNStore
+	svc        *firewall.Service
+	log        *zap.Logger
+}
+
+func NewGraphQLHandler(enabled bool, policies *store.PolicyStore, namespaces *store.NamespaceStore, ids *store.IDSStore, vpn *store.VPNStore, svc *firewall.Service, log *zap.Logger) *GraphQLHandler {
+	return &GraphQLHandler{enabled: enabled, policies: policies, namespaces: namespaces, ids: ids, vpn: vpn, svc: svc, log: log}
+}
+
+// nodeInfo describes the instance answering the query.
+type nodeInfo struct {
+	Hostname   string    `json:"hostname"`
+	Version    string    `json:"version"`
+	Uptime     string    `json:"uptime"`
+	StartedAt  time.Time `json:"startedAt"`
+	GoVersion  string    `json:"goVersion"`
+	OS         string    `json:"os"`
+	Arch       string    `json:"arch"`
+	Goroutines int       `json:"goroutines"`
+}
+
+// metricsSummary condenses the state of the applied ruleset.
+type metricsSummary struct {
+	IRID              string             `json:"irId"`
+	IRVersion         int64              `json:"irVersion"`
+	AppliedAt         *time.Time         `json:"appliedAt"`
+	FirewallRules     int                `json:"firewallRules"`
+	NATRules          int                `json:"natRules"`
+	HealthTargets     int                `json:"healthTargets"`
+	HealthTargetsDown int                `json:"healthTargetsDown"`
+	Uplinks           []wan.UplinkStatus `json:"uplinks"`
+}
+
+// Query GET|POST /api/v1/graphql
+// Runs a GraphQL query, posted as {"query", "operationName", "variables"}
+// or passed as ?query=&operationName=&variables= with variables as JSON.
+// The query root has:
+//
+//	policies(kind, namespace)     the policies the caller may read
+//	nodes                         this instance
+//	alerts(since, action, limit)  IDS alerts, newest first; since defaults to 24h
+//	peers                         VPN peers of the tenant
+//	metrics                       a summary of the applied ruleset
+//
+// Results follow the REST endpoints' JSON field names. Only queries are
+// supported, and errors are reported in the response body with status 200.
+func (h *GraphQLHandler) Query(c *gin.Context) {
+	var req graphql.Request
+	if c.Request.Method == http.MethodGet {
+		req.Query = c.Query("query")
+		req.OperationName = c.Query("operationName")
+		if v := c.Query("variables"); v != "" {
+			if err := json.Unmarshal([]byte(v), &req.Variables); err != nil {
+				fail(c, http.StatusBadRequest, "variables must be a JSON object")
+				return
+			}
+		}
+	} else {
+		c.Request.Body = http.MaxBytesReader(c.Writer, c.Request.Body, maxRequestBytes)
+		if err := c.ShouldBindJSON(&req); err != nil {
+			var tooLarge *http.MaxBytesError
+			if errors.As(err, &tooLarge) {
+				fail(c, http.StatusRequestEntityTooLarge, fmt.Sprintf("request is larger than %d bytes", maxRequestBytes))
+				return
+			}
+			fail(c, http.StatusBadRequest, err.Error())
+			return
+		}
+	}
+	if req.Query == "" {
+		fail(c, http.StatusBadRequest, "query is required")
+		return
+	}
+	if len(req.Query) > maxQueryBytes {
+		fail(c, http.StatusRequestEntityTooLarge, fmt.Sprintf("query is larger than %d bytes", maxQueryBytes))
+		return
+	}
+	c.JSON(http.StatusOK, h.schema(c).Execute(c.Request.Context(), req))
+}
+
+// Available aborts with 503 when the GraphQL endpoint is disabled.
+func (h *GraphQLHandler) Available(c *gin.Context) {
+	if !h.enabled {
+		Abort(c, http.StatusServiceUnavailable, CodeFeatureDisabled, "the graphql endpoint is disabled")
+		return
+	}
+	c.Next()
+}
+
+// schema builds the query root for the caller of c.
+func (h *GraphQLHandler) schema(c *gin.Context) *graphql.Schema {
+	role, _ := c.Get("role")
+	roleName, _ := role.(string)
+	guard := func(p authz.Permission, fn graphql.Resolver) graphql.Resolver {
+		return func(ctx context.Context, args graphql.Args, sel graphql.Selection) (any, error) {
+			if !authz.Allows(roleName, p) {
+				return nil, fmt.Errorf("forbidden: requires %s (role %s)", p, authz.Role(p))
+			}
+			return fn(ctx, args, sel)
+		}
+	}
+	return &graphql.Schema{Query: map[string]graphql.Field{
+		"policies": {Args: []string{"kind", "namespace"}, Resolve: guard(authz.PoliciesRead, func(ctx context.Context, args graphql.Args, sel graphql.Selection) (any, error) {
+			return h.listPolicies(ctx, c, args, sel)
+		})},
+		"nodes": {Resolve: guard(authz.SystemRead, func(context.Context, graphql.Args, graphql.Selection) (any, error) {
+			return h.nodes(), nil
+		})},
+		"alerts": {Args: []string{"since", "action", "limit"}, Resolve: guard(authz.IDSRead, h.alerts)},
+		"peers": {Resolve: guard(authz.VPNRead, func(ctx context.Context, _ graphql.Args, _ graphql.Selection) (any, error) {
+			peers, err := h.vpn.ListPeers(ctx, mustTenantID(c))
+			if err != nil {
+				h.log.Error("graphql: list vpn peers", zap.Error(err))
+				return nil, fmt.Errorf("failed to list peers")
+			}
+			return peers, nil
+		})},
+		"metrics": {Resolve: guard(authz.FirewallRead, func(context.Context, graphql.Args, graphql.Selection) (any, error) {
+			return h.metrics(), nil
+		})},
+	}}
+}
+
+func (h *GraphQLHandler) listPolicies(ctx context.Context, c *gin.Context, args graphql.Args, sel graphql.Selection) (any, error) {
+	kind, err := args.String("kind")
+	if err != nil {
+		return nil, err
+	}
+	namespace, err := args.String("namespace")
+	if err != nil {
+		return nil, err
+	}
+	acl, err := loadNamespaceACL(ctx, h.namespaces, c)
+	if err != nil {
+		h.log.Error("load namespace access", zap.Error(err))
+		return nil, fmt.Errorf("failed to list policies")
+	}
+	if namespace != "" && !acl.canRead(namespace) {
+		return nil, fmt.Errorf("no access to namespace %s", namespace)
+	}
+	policies, err := h.policies.List(ctx, mustTenantID(c), store.PolicyFilter{
+		Kind:       kind,
+		Namespace:  namespace,
+		SkipBodies: !sel.Has("spec") && !sel.Has("rawYaml"),
+	})
+	if err != nil {
+		h.log.Error("graphql: list policies", zap.Error(err))
+		return nil, fmt.Errorf("failed to list policies")
+	}
+	items := make([]*store.PolicyRecord, 0, len(policies))
+	for _, p := range policies {
+		if acl.canRead(p.Namespace) {
+			items = append(items, p)
+		}
+	}
+	return items, nil
+}
+
+// nodes lists the instance answering; the API has no view of other nodes.
+func (h *GraphQLHandler) nodes() []nodeInfo {
+	host, _ := os.Hostname()
+	return []nodeInfo{{
+		Hostname:   host,
+		Version:    version.Version,
+		Uptime:     time.Since(startTime).String(),
+		StartedAt:  startTime,
+		GoVersion:  runtime.Version(),
+		OS:         runtime.GOOS,
+		Arch:       runtime.GOARCH,
+		Goroutines: runtime.NumGoroutine(),
+	}}
+}
+
+func (h *GraphQLHandler) alerts(ctx context.Context, args graphql.Args, _ graphql.Selection) (any, error) {
+	since, err := args.String("since")
+	if err != nil {
+		return nil, err
+	}
+	if since == "" {
+		since = "24h"
+	}
+	d, err := time.ParseDuration(since)
+	if err != nil || d <= 0 {
+		return nil, fmt.Errorf("since must be a positive duration such as 24h")
+	}
+	action, err := args.String("action")
+	if err != nil {
+		return nil, err
+	}
+	limit, err := args.Int("limit", 100)
+	if err != nil {
+		return nil, err
+	}
+	if limit <= 0 || limit > maxListRows {
+		return nil, fmt.Errorf("limit must be between 1 and %d", maxListRows)
+	}
+
+	items := []*store.IDSAlert{}
+	q := store.EventQuery{Since: time.Now().Add(-d), Action: action, Limit: limit}
+	err = h.ids.EachAlert(ctx, q, func(a *store.IDSAlert) error {
+		items = append(items, a)
+		return nil
+	})
+	if err != nil {
+		h.log.Error("graphql: list ids alerts", zap.Error(err))
+		return nil, fmt.Errorf("failed to list alerts")
+	}
+	return items, nil
+}
+
+func (h *GraphQLHandler) metrics() *metricsSummary {
+	m := &metricsSummary{Uplinks: h.svc.WANStatus()}
+	if ir := h.svc.CurrentIR(); ir != nil {
+		applied := ir.CreatedAt
+		m.IRID, m.IRVersion, m.AppliedAt = ir.ID, ir.Version, &applied
+		m.FirewallRules = len(ir.FirewallRules)
+		m.NATRules = len(ir.NATRules)
+	}
+	for _, t := range h.svc.HealthStatus() {
+		m.HealthTargets++
+		if t.Known && !t.Up {
+			m.HealthTargetsDown++
+		}
+	}
+	return m
+}
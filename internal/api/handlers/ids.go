@@ -0,0 +1,345 @@
+package handlers
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"go.uber.org/zap"
+
+	"github.com/aegisx/aegisx/internal/ids"
+	"github.com/aegisx/aegisx/internal/store"
+)
+
+// IDSHandler handles /api/v1/ids endpoints.
+type IDSHandler struct {
+	adapter *ids.Adapter // nil when IDS is disabled
+	store   *store.IDSStore
+	changes *store.ChangeStore
+	log     *zap.Logger
+}
+
+func NewIDSHandler(adapter *ids.Adapter, idsStore *store.IDSStore, changes *store.ChangeStore, log *zap.Logger) *IDSHandler {
+	return &IDSHandler{adapter: adapter, store: idsStore, changes: changes, log: log}
+}
+
+type sidOverrideRequest struct {
+	GID       int    `json:"gid"`
+	Action    string `json:"action"` // disable | enable | alert | drop
+	Threshold *struct {
+		Type    string `json:"type"  binding:"required,oneof=limit threshold both suppress"`
+		Track   string `json:"track"`
+		Count   int    `json:"count"`
+		Seconds int    `json:"seconds"`
+	} `json:"threshold"`
+	Comment string `json:"comment"`
+}
+
+type acceptSuggestionRequest struct {
+	Namespace string `json:"namespace"`
+	Reason    string `json:"reason"`
+}
+
+// ListAlerts GET /api/v1/ids/alerts?since=24h&until=&action=blocked&limit=100
+// Returns the stored alerts, newest first. With ?format=csv or an Accept
+// header of text/csv they are streamed as a CSV download of up to 100000
+// rows instead.
+func (h *IDSHandler) ListAlerts(c *gin.Context) {
+	asCSV := wantsCSV(c)
+	since, until, limit, ok := exportWindow(c, asCSV)
+	if !ok {
+		return
+	}
+	q := store.EventQuery{Since: since, Until: until, Action: c.Query("action"), Limit: limit}
+
+	if asCSV {
+		exp := newCSVExport(c, "ids-alerts", "timestamp", "signature_id", "signature", "severity",
+			"category", "action", "protocol", "src_ip", "src_port", "dst_ip", "dst_port",
+			"flow_id", "country", "asn", "as_org")
+		err := h.store.EachAlert(c.Request.Context(), q, func(a *store.IDSAlert) error {
+			return exp.Write(csvTime(a.Timestamp), csvInt(int64(a.SignatureID)), a.SignatureMsg,
+				csvInt(int64(a.Severity)), a.Category, a.Action, a.Protocol,
+				a.SrcIP, csvInt(int64(a.SrcPort)), a.DstIP, csvInt(int64(a.DstPort)),
+				csvInt(a.FlowID), a.Country, csvInt(int64(a.ASN)), a.ASOrg)
+		})
+		if err == nil {
+			err = exp.Close()
+		}
+		if err != nil {
+			h.log.Error("export ids alerts", zap.Error(err))
+			if !exp.Started() {
+				fail(c, http.StatusInternalServerError, "failed to export alerts")
+			}
+		}
+		return
+	}
+
+	items := []*store.IDSAlert{}
+	err := h.store.EachAlert(c.Request.Context(), q, func(a *store.IDSAlert) error {
+		items = append(items, a)
+		return nil
+	})
+	if err != nil {
+		h.log.Error("list ids alerts", zap.Error(err))
+		fail(c, http.StatusInternalServerError, "failed to list alerts")
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"items": items, "count": len(items)})
+}
+
+// ListSuggestions GET /api/v1/ids/suggestions?status=open
+// Returns firewall rule suggestions derived from recurring alerts. The
+// status filter defaults to open; pass status=all for every suggestion.
+func (h *IDSHandler) ListSuggestions(c *gin.Context) {
+	status := c.DefaultQuery("status", "open")
+	if status == "all" {
+		status = ""
+	}
+	items, err := h.store.ListSuggestions(c.Request.Context(), status)
+	if err != nil {
+		h.log.Error("list ids suggestions", zap.Error(err))
+		fail(c, http.StatusInternalServerError, "failed to list suggestions")
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"items": items, "count": len(items)})
+}
+
+// AcceptSuggestion POST /api/v1/ids/suggestions/:id/accept
+// Queues the suggested FirewallPolicy as a pending change; it takes effect
+// once another user approves it under /api/v1/changes and it is applied.
+func (h *IDSHandler) AcceptSuggestion(c *gin.Context) {
+	var req acceptSuggestionRequest
+	if c.Request.ContentLength > 0 {
+		if err := c.ShouldBindJSON(&req); err != nil {
+			fail(c, http.StatusBadRequest, err.Error())
+			return
+		}
+	}
+	if req.Namespace == "" {
+		req.Namespace = store.DefaultNamespace
+	}
+
+	sg, ok := h.loadOpen(c)
+	if !ok {
+		return
+	}
+	m, raw, err := ids.SuggestionManifest(sg, req.Namespace)
+	if err != nil {
+		fail(c, http.StatusUnprocessableEntity, err.Error())
+		return
+	}
+	spec, _ := json.Marshal(m.FirewallSpec)
+	if req.Reason == "" {
+		req.Reason = fmt.Sprintf("IDS: %d alerts from %s (%s)",
+			sg.AlertCount, sg.Target, strings.Join(sg.Signatures, "; "))
+	}
+
+	uid := callerID(c)
+	ch := &store.PolicyChange{
+		TenantID:    mustTenantID(c),
+		Namespace:   req.Namespace,
+		Name:        m.Metadata.Name,
+		Kind:        m.Kind,
+		Spec:        spec,
+		RawYAML:     raw,
+		Reason:      req.Reason,
+		Source:      "ids-suggestion",
+		RequestedBy: &uid,
+	}
+	if err := h.changes.Create(c.Request.Context(), ch); err != nil {
+		h.log.Error("queue suggestion change", zap.Error(err))
+		fail(c, http.StatusInternalServerError, "failed to create change")
+		return
+	}
+	if err := h.store.ResolveSuggestion(c.Request.Context(), sg.ID, "accepted", &ch.ID); err != nil {
+		h.log.Warn("mark suggestion accepted", zap.Error(err), zap.String("suggestion_id", sg.ID.String()))
+	}
+	c.JSON(http.StatusAccepted, ch)
+}
+
+// DismissSuggestion POST /api/v1/ids/suggestions/:id/dismiss
+// Dismissed suggestions are not proposed again for the same target.
+func (h *IDSHandler) DismissSuggestion(c *gin.Context) {
+	sg, ok := h.loadOpen(c)
+	if !ok {
+		return
+	}
+	if err := h.store.ResolveSuggestion(c.Request.Context(), sg.ID, "dismissed", nil); err != nil {
+		fail(c, http.StatusConflict, err.Error())
+		return
+	}
+	c.Status(http.StatusNoContent)
+}
+
+// Stats GET /api/v1/ids/stats
+// Returns the latest engine counters: capture loss, decoder totals and
+// memory use.
+func (h *IDSHandler) Stats(c *gin.Context) {
+	if h.adapter == nil {
+		Abort(c, http.StatusServiceUnavailable, CodeFeatureDisabled, "IDS is disabled")
+		return
+	}
+	st := h.adapter.LastStats()
+	if st == nil {
+		fail(c, http.StatusServiceUnavailable, "no counters collected yet")
+		return
+	}
+	c.JSON(http.StatusOK, st)
+}
+
+// ListSIDOverrides GET /api/v1/ids/sids
+func (h *IDSHandler) ListSIDOverrides(c *gin.Context) {
+	items, err := h.store.ListSIDOverrides(c.Request.Context())
+	if err != nil {
+		h.log.Error("list sid overrides", zap.Error(err))
+		fail(c, http.StatusInternalServerError, "failed to list overrides")
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"items": items, "count": len(items)})
+}
+
+// PutSIDOverride PUT /api/v1/ids/sids/:sid
+// Disables, re-enables or changes the action of an upstream signature,
+// and/or sets its threshold. The ruleset is rebuilt immediately.
+func (h *IDSHandler) PutSIDOverride(c *gin.Context) {
+	sid, ok := parseSID(c)
+	if !ok {
+		return
+	}
+	var req sidOverrideRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		fail(c, http.StatusBadRequest, err.Error())
+		return
+	}
+	switch req.Action {
+	case "", "disable", "enable", "alert", "drop":
+	default:
+		fail(c, http.StatusBadRequest, "action must be disable, enable, alert or drop")
+		return
+	}
+	if req.Action == "" && req.Threshold == nil {
+		fail(c, http.StatusBadRequest, "an action or a threshold is required")
+		return
+	}
+	if req.GID == 0 {
+		req.GID = 1
+	}
+
+	uid := callerID(c)
+	o := &store.SIDOverride{GID: req.GID, SID: sid, Action: req.Action, Comment: req.Comment, UpdatedBy: &uid}
+	if t := req.Threshold; t != nil {
+		if t.Type != "suppress" {
+			if (t.Track != "by_src" && t.Track != "by_dst") || t.Count < 1 || t.Seconds < 1 {
+				fail(c, http.StatusBadRequest, "threshold needs track by_src|by_dst and positive count and seconds")
+				return
+			}
+		}
+		o.ThresholdType, o.Track, o.Count, o.Seconds = t.Type, t.Track, t.Count, t.Seconds
+	}
+
+	if err := h.store.PutSIDOverride(c.Request.Context(), o); err != nil {
+		h.log.Error("put sid override", zap.Error(err))
+		fail(c, http.StatusInternalServerError, "failed to save override")
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"override": o, "ruleset": h.rebuild(c)})
+}
+
+// DeleteSIDOverride DELETE /api/v1/ids/sids/:sid?gid=1
+// Restores the upstream behaviour of a signature.
+func (h *IDSHandler) DeleteSIDOverride(c *gin.Context) {
+	sid, ok := parseSID(c)
+	if !ok {
+		return
+	}
+	gid, err := strconv.Atoi(c.DefaultQuery("gid", "1"))
+	if err != nil {
+		fail(c, http.StatusBadRequest, "invalid gid")
+		return
+	}
+	if err := h.store.DeleteSIDOverride(c.Request.Context(), gid, sid); err != nil {
+		if strings.Contains(err.Error(), "not found") {
+			fail(c, http.StatusNotFound, "override not found")
+			return
+		}
+		fail(c, http.StatusInternalServerError, err.Error())
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"ruleset": h.rebuild(c)})
+}
+
+// RebuildRuleset POST /api/v1/ids/ruleset/rebuild
+// Re-merges the downloaded rulesets with the SID overrides; call it after
+// a ruleset update.
+func (h *IDSHandler) RebuildRuleset(c *gin.Context) {
+	if h.adapter == nil {
+		Abort(c, http.StatusServiceUnavailable, CodeFeatureDisabled, "IDS is disabled")
+		return
+	}
+	overrides, err := h.store.ListSIDOverrides(c.Request.Context())
+	if err != nil {
+		fail(c, http.StatusInternalServerError, err.Error())
+		return
+	}
+	stats, err := h.adapter.ApplyOverrides(overrides)
+	if err != nil {
+		h.log.Error("rebuild ids ruleset", zap.Error(err))
+		fail(c, http.StatusInternalServerError, "rebuild failed: "+err.Error())
+		return
+	}
+	c.JSON(http.StatusOK, stats)
+}
+
+// ─── Helpers ──────────────────────────────────────────────────────────────
+
+// rebuild applies the stored overrides after a change and reports the
+// outcome; the change itself is already saved, so failures don't abort.
+func (h *IDSHandler) rebuild(c *gin.Context) gin.H {
+	if h.adapter == nil {
+		return gin.H{"status": "skipped", "reason": "IDS is disabled"}
+	}
+	overrides, err := h.store.ListSIDOverrides(c.Request.Context())
+	if err == nil {
+		var stats ids.BuildStats
+		if stats, err = h.adapter.ApplyOverrides(overrides); err == nil {
+			return gin.H{"status": "rebuilt", "stats": stats}
+		}
+	}
+	h.log.Error("rebuild ids ruleset", zap.Error(err))
+	return gin.H{"status": "failed", "error": err.Error()}
+}
+
+func parseSID(c *gin.Context) (int64, bool) {
+	sid, err := strconv.ParseInt(c.Param("sid"), 10, 64)
+	if err != nil || sid <= 0 {
+		fail(c, http.StatusBadRequest, "invalid sid")
+		return 0, false
+	}
+	return sid, true
+}
+
+func (h *IDSHandler) loadOpen(c *gin.Context) (*store.IDSSuggestion, bool) {
+	id, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		fail(c, http.StatusBadRequest, "invalid id")
+		return nil, false
+	}
+	sg, err := h.store.GetSuggestion(c.Request.Context(), id)
+	if err != nil {
+		if strings.Contains(err.Error(), "not found") {
+			fail(c, http.StatusNotFound, "suggestion not found")
+		} else {
+			fail(c, http.StatusInternalServerError, err.Error())
+		}
+		return nil, false
+	}
+	if sg.Status != "open" {
+		fail(c, http.StatusConflict, "suggestion is already "+sg.Status)
+		return nil, false
+	}
+	return sg, true
+}
@@ -0,0 +1,266 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"go.uber.org/zap"
+
+	"github.com/aegisx/aegisx/internal/auth"
+	"github.com/aegisx/aegisx/internal/store"
+)
+
+const defaultImpersonationTTL = time.Hour
+
+// ImpersonationHandler handles /api/v1/impersonations: time-boxed tokens
+// that let the instance operator act inside a tenant without sharing
+// credentials. Tenant admins cannot impersonate, not even in their own
+// tenant. Every step, and every request made with such a token, is audited.
+type ImpersonationHandler struct {
+	authSvc *auth.Service
+	store   *store.ImpersonationStore
+	audit   *store.AuditStore
+	log     *zap.Logger
+}
+
+func NewImpersonationHandler(authSvc *auth.Service, sessions *store.ImpersonationStore, audit *store.AuditStore, log *zap.Logger) *ImpersonationHandler {
+	return &ImpersonationHandler{authSvc: authSvc, store: sessions, audit: audit, log: log}
+}
+
+// StartImpersonationRequest is the body of POST /impersonations.
+type StartImpersonationRequest struct {
+	TenantID uuid.UUID `json:"tenantId" binding:"required"`
+	Role     string    `json:"role"` // default viewer
+	Reason   string    `json:"reason" binding:"required"`
+	TTL      string    `json:"ttl"` // default 1h, at most 4h
+}
+
+// Start POST /api/v1/impersonations
+func (h *ImpersonationHandler) Start(c *gin.Context) {
+	if !h.allowed(c) {
+		return
+	}
+
+	var req StartImpersonationRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		fail(c, http.StatusBadRequest, err.Error())
+		return
+	}
+	if strings.TrimSpace(req.Reason) == "" {
+		fail(c, http.StatusBadRequest, "reason is required")
+		return
+	}
+	if req.Role == "" {
+		req.Role = "viewer"
+	}
+	if !validBindingRoles[req.Role] {
+		fail(c, http.StatusBadRequest, "role must be one of admin|operator|viewer")
+		return
+	}
+	ttl := defaultImpersonationTTL
+	if req.TTL != "" {
+		var err error
+		if ttl, err = time.ParseDuration(req.TTL); err != nil || ttl <= 0 || ttl > auth.MaxImpersonationTTL {
+			fail(c, http.StatusBadRequest, "ttl must be a duration of at most "+auth.MaxImpersonationTTL.String())
+			return
+		}
+	}
+
+	actor := callerID(c)
+	session := &store.Impersonation{
+		ActorID:   actor,
+		TenantID:  req.TenantID,
+		Role:      req.Role,
+		Reason:    req.Reason,
+		ExpiresAt: time.Now().Add(ttl),
+	}
+	if err := h.store.Create(c.Request.Context(), session); err != nil {
+		if strings.Contains(err.Error(), "not found") {
+			fail(c, http.StatusNotFound, "tenant not found")
+			return
+		}
+		h.log.Error("create impersonation", zap.Error(err))
+		fail(c, http.StatusInternalServerError, "failed to start impersonation")
+		return
+	}
+
+	// No token without a trail.
+	err := h.record(c, store.AuditImpersonateStart, session, gin.H{
+		"homeTenant": mustTenantID(c),
+		"role":       session.Role,
+		"reason":     session.Reason,
+		"expiresAt":  session.ExpiresAt,
+	})
+	if err != nil {
+		h.log.Error("audit impersonation", zap.Error(err), zap.String("session", session.ID.String()))
+		fail(c, http.StatusInternalServerError, "failed to record impersonation")
+		return
+	}
+
+	token, err := h.authSvc.IssueImpersonationToken(actor, session.TenantID, session.ID, session.Role, session.ExpiresAt)
+	if err != nil {
+		h.log.Error("issue impersonation token", zap.Error(err))
+		fail(c, http.StatusInternalServerError, "failed to issue token")
+		return
+	}
+	h.log.Warn("impersonation started",
+		zap.String("session", session.ID.String()),
+		zap.String("actor", actor.String()),
+		zap.String("tenant", session.TenantID.String()),
+		zap.String("role", session.Role),
+		zap.String("reason", session.Reason))
+
+	c.JSON(http.StatusCreated, gin.H{
+		"session":   session,
+		"token":     token,
+		"expiresIn": int(ttl.Seconds()),
+	})
+}
+
+// List GET /api/v1/impersonations?active=true
+func (h *ImpersonationHandler) List(c *gin.Context) {
+	if !h.allowed(c) {
+		return
+	}
+	items, err := h.store.List(c.Request.Context(), c.Query("active") == "true")
+	if err != nil {
+		fail(c, http.StatusInternalServerError, err.Error())
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"items": items, "count": len(items)})
+}
+
+// End DELETE /api/v1/impersonations/:id
+// The instance operator ends any session; an impersonation token may end
+// its own.
+func (h *ImpersonationHandler) End(c *gin.Context) {
+	id, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		fail(c, http.StatusBadRequest, "invalid id")
+		return
+	}
+	if own, _ := c.Get("impersonation_id"); own != id && !h.allowed(c) {
+		return
+	}
+
+	actor := callerID(c)
+	session, err := h.store.End(c.Request.Context(), id, &actor)
+	if err != nil {
+		if strings.Contains(err.Error(), "not found") {
+			fail(c, http.StatusNotFound, "no active impersonation "+id.String())
+			return
+		}
+		fail(c, http.StatusInternalServerError, err.Error())
+		return
+	}
+	if err := h.record(c, store.AuditImpersonateEnd, session, nil); err != nil {
+		h.log.Error("audit impersonation", zap.Error(err), zap.String("session", session.ID.String()))
+	}
+	h.log.Warn("impersonation ended",
+		zap.String("session", session.ID.String()),
+		zap.String("by", actor.String()))
+	c.JSON(http.StatusOK, session)
+}
+
+// Audit GET /api/v1/impersonations/:id/audit
+// Lists what was done under the session, newest first.
+func (h *ImpersonationHandler) Audit(c *gin.Context) {
+	if !h.allowed(c) {
+		return
+	}
+	id, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		fail(c, http.StatusBadRequest, "invalid id")
+		return
+	}
+	session, err := h.store.Get(c.Request.Context(), id)
+	if err != nil {
+		fail(c, http.StatusNotFound, "impersonation not found")
+		return
+	}
+	items, err := h.audit.List(c.Request.Context(), store.AuditFilter{ImpersonationID: &id, Limit: 1000})
+	if err != nil {
+		fail(c, http.StatusInternalServerError, err.Error())
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"session": session, "items": items, "count": len(items)})
+}
+
+// RecordImpersonatedRequest audits a request made with an impersonation
+// token. The server calls it after the handler has run.
+func (h *ImpersonationHandler) RecordImpersonatedRequest(c *gin.Context) {
+	val, ok := c.Get("impersonation_id")
+	if !ok {
+		return
+	}
+	sessionID := val.(uuid.UUID)
+	tenantID := mustTenantID(c)
+	actor := callerID(c)
+	status := "success"
+	if c.Writer.Status() >= 400 {
+		status = "failure"
+	}
+	detail, _ := json.Marshal(gin.H{
+		"method":    c.Request.Method,
+		"status":    c.Writer.Status(),
+		"requestId": c.GetString("request_id"),
+	})
+	entry := &store.AuditEntry{
+		TenantID:        &tenantID,
+		UserID:          &actor,
+		ImpersonationID: &sessionID,
+		Action:          store.AuditImpersonateRequest,
+		Resource:        c.FullPath(),
+		ResourceID:      c.Param("id"),
+		Detail:          detail,
+		IPAddress:       c.ClientIP(),
+		UserAgent:       c.Request.UserAgent(),
+		Status:          status,
+	}
+	// The request context may already be cancelled by the time we get here.
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if err := h.audit.Record(ctx, entry); err != nil {
+		h.log.Error("audit impersonated request", zap.Error(err), zap.String("session", sessionID.String()))
+	}
+}
+
+// ─── Helpers ──────────────────────────────────────────────────────────────
+
+// allowed admits the instance operator using their own credentials;
+// impersonation tokens cannot start or inspect other sessions. The route
+// matrix already requires the operator for every route but End.
+func (h *ImpersonationHandler) allowed(c *gin.Context) bool {
+	if _, ok := c.Get("impersonation_id"); ok {
+		fail(c, http.StatusForbidden, "not permitted while impersonating")
+		return false
+	}
+	if !c.GetBool("instance_operator") {
+		fail(c, http.StatusForbidden, "impersonation is reserved to the instance operator")
+		return false
+	}
+	return true
+}
+
+func (h *ImpersonationHandler) record(c *gin.Context, action string, session *store.Impersonation, detail gin.H) error {
+	actor := callerID(c)
+	entry := &store.AuditEntry{
+		TenantID:        &session.TenantID,
+		UserID:          &actor,
+		ImpersonationID: &session.ID,
+		Action:          action,
+		Resource:        "impersonation",
+		ResourceID:      session.ID.String(),
+		IPAddress:       c.ClientIP(),
+		UserAgent:       c.Request.UserAgent(),
+	}
+	if detail != nil {
+		entry.Detail, _ = json.Marshal(detail)
+	}
+	return h.audit.Record(c.Request.Context(), entry)
+}
@@ -0,0 +1,83 @@
+package handlers
+
+import (
+	"io"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+
+	"github.com/aegisx/aegisx/internal/ifstats"
+)
+
+// InterfaceHandler handles /api/v1/system/interfaces.
+type InterfaceHandler struct {
+	sampler *ifstats.Sampler // nil when interface statistics are disabled
+	log     *zap.Logger
+}
+
+func NewInterfaceHandler(sampler *ifstats.Sampler, log *zap.Logger) *InterfaceHandler {
+	return &InterfaceHandler{sampler: sampler, log: log}
+}
+
+// Stats GET /api/v1/system/interfaces/:name/stats?watch=true
+// Returns the rx/tx byte and packet rates of the interface over the kept
+// history, oldest first. With watch=true the response is an event stream
+// that sends the history as one "history" event and then every new sample
+// as a "sample" event until the client goes away.
+func (h *InterfaceHandler) Stats(c *gin.Context) {
+	name := c.Param("name")
+	if c.Query("watch") != "true" {
+		items, ok := h.sampler.History(name)
+		if !ok {
+			fail(c, http.StatusNotFound, "interface not found")
+			return
+		}
+		c.JSON(http.StatusOK, gin.H{
+			"interface": name,
+			"interval":  h.sampler.Interval().String(),
+			"items":     items,
+			"count":     len(items),
+		})
+		return
+	}
+
+	samples, cancel, ok := h.sampler.Subscribe(name)
+	if !ok {
+		fail(c, http.StatusNotFound, "interface not found")
+		return
+	}
+	defer cancel()
+	history, _ := h.sampler.History(name)
+
+	// The stream outlives the server's write timeout.
+	if err := http.NewResponseController(c.Writer).SetWriteDeadline(time.Time{}); err != nil {
+		h.log.Warn("clear write deadline for interface stats stream", zap.Error(err))
+	}
+	c.Header("Cache-Control", "no-cache")
+	c.Header("X-Accel-Buffering", "no")
+	c.SSEvent("history", gin.H{"interface": name, "interval": h.sampler.Interval().String(), "items": history})
+	ctx := c.Request.Context()
+	c.Stream(func(io.Writer) bool {
+		select {
+		case <-ctx.Done():
+			return false
+		case s, ok := <-samples:
+			if !ok {
+				return false
+			}
+			c.SSEvent("sample", s)
+			return true
+		}
+	})
+}
+
+// Available aborts with 503 when interface statistics are disabled.
+func (h *InterfaceHandler) Available(c *gin.Context) {
+	if h.sampler == nil {
+		Abort(c, http.StatusServiceUnavailable, CodeFeatureDisabled, "interface statistics are disabled")
+		return
+	}
+	c.Next()
+}
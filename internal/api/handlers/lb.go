@@ -0,0 +1,146 @@
+package handlers
+
+import (
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+
+	"github.com/aegisx/aegisx/internal/lb"
+	"github.com/aegisx/aegisx/internal/store"
+)
+
+// LBHandler handles /api/v1/lb endpoints.
+type LBHandler struct {
+	adapter   *lb.Adapter
+	store     *store.LBStore
+	collector *lb.AccessLogCollector
+	log       *zap.Logger
+}
+
+func NewLBHandler(adapter *lb.Adapter, store *store.LBStore, collector *lb.AccessLogCollector, log *zap.Logger) *LBHandler {
+	return &LBHandler{adapter: adapter, store: store, collector: collector, log: log}
+}
+
+// Available rejects requests while the load balancer is disabled.
+func (h *LBHandler) Available(c *gin.Context) {
+	if h.adapter == nil {
+		Abort(c, http.StatusServiceUnavailable, CodeFeatureDisabled, "load balancer is disabled")
+		return
+	}
+	c.Next()
+}
+
+type maintenanceRequest struct {
+	Reason string `json:"reason"`
+}
+
+// ListMaintenance GET /api/v1/lb/maintenance
+func (h *LBHandler) ListMaintenance(c *gin.Context) {
+	tenantID := mustTenantID(c)
+	items, err := h.store.ListMaintenance(c.Request.Context(), &tenantID)
+	if err != nil {
+		h.log.Error("list maintenance", zap.Error(err))
+		fail(c, http.StatusInternalServerError, "failed to list maintenance")
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"items": items, "count": len(items)})
+}
+
+// StartMaintenance PUT /api/v1/lb/backends/:name/maintenance
+// Disables every server of the backend; clients receive its 503 page until
+// maintenance ends. Policies are left untouched.
+func (h *LBHandler) StartMaintenance(c *gin.Context) {
+	var req maintenanceRequest
+	if c.Request.ContentLength > 0 {
+		if err := c.ShouldBindJSON(&req); err != nil {
+			fail(c, http.StatusBadRequest, err.Error())
+			return
+		}
+	}
+
+	name := c.Param("name")
+	if err := h.adapter.SetMaintenance(name, true); err != nil {
+		h.maintenanceError(c, name, err)
+		return
+	}
+
+	uid := callerID(c)
+	m := &store.LBMaintenance{
+		TenantID:  mustTenantID(c),
+		Backend:   name,
+		Reason:    req.Reason,
+		StartedBy: &uid,
+	}
+	if err := h.store.StartMaintenance(c.Request.Context(), m); err != nil {
+		h.log.Error("persist maintenance", zap.Error(err), zap.String("backend", name))
+		fail(c, http.StatusInternalServerError, "maintenance is active but could not be persisted")
+		return
+	}
+	c.JSON(http.StatusOK, m)
+}
+
+// EndMaintenance DELETE /api/v1/lb/backends/:name/maintenance
+func (h *LBHandler) EndMaintenance(c *gin.Context) {
+
+	name := c.Param("name")
+	if err := h.adapter.SetMaintenance(name, false); err != nil {
+		h.maintenanceError(c, name, err)
+		return
+	}
+	if err := h.store.EndMaintenance(c.Request.Context(), mustTenantID(c), name); err != nil {
+		if !strings.Contains(err.Error(), "not in maintenance") {
+			h.log.Error("clear maintenance", zap.Error(err), zap.String("backend", name))
+			fail(c, http.StatusInternalServerError, "failed to clear maintenance")
+			return
+		}
+	}
+	c.JSON(http.StatusOK, gin.H{"backend": name, "maintenance": false})
+}
+
+// Discovery GET /api/v1/lb/discovery
+// Returns the servers currently discovered for each dynamic backend.
+func (h *LBHandler) Discovery(c *gin.Context) {
+	servers := h.adapter.DiscoveredServers()
+	c.JSON(http.StatusOK, gin.H{"items": servers, "count": len(servers)})
+}
+
+// Analytics GET /api/v1/lb/analytics?window=15m&frontend=web
+// Returns request rate, status-code mix and latency percentiles per
+// frontend/backend, computed from HAProxy access logs. Window max is 1h.
+func (h *LBHandler) Analytics(c *gin.Context) {
+	if h.collector == nil {
+		Abort(c, http.StatusServiceUnavailable, CodeFeatureDisabled, "access log collection is disabled")
+		return
+	}
+
+	window := 15 * time.Minute
+	if w := c.Query("window"); w != "" {
+		d, err := time.ParseDuration(w)
+		if err != nil || d <= 0 || d > time.Hour {
+			fail(c, http.StatusBadRequest, "window must be a duration up to 1h")
+			return
+		}
+		window = d
+	}
+
+	items := h.collector.Analytics(window, c.Query("frontend"))
+	c.JSON(http.StatusOK, gin.H{
+		"window": window.String(),
+		"items":  items,
+		"count":  len(items),
+	})
+}
+
+// ─── Helpers ──────────────────────────────────────────────────────────────
+
+func (h *LBHandler) maintenanceError(c *gin.Context, name string, err error) {
+	if strings.Contains(err.Error(), "not found") {
+		fail(c, http.StatusNotFound, "backend not found")
+		return
+	}
+	h.log.Error("set maintenance", zap.Error(err), zap.String("backend", name))
+	fail(c, http.StatusBadGateway, "haproxy runtime API: "+err.Error())
+}
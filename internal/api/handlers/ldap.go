@@ -0,0 +1,47 @@
+package handlers
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+
+	"github.com/aegisx/aegisx/internal/directory"
+)
+
+// LDAPHandler handles /api/v1/system/ldap.
+type LDAPHandler struct {
+	sync *directory.Syncer // nil when LDAP is disabled
+	log  *zap.Logger
+}
+
+func NewLDAPHandler(sync *directory.Syncer, log *zap.Logger) *LDAPHandler {
+	return &LDAPHandler{sync: sync, log: log}
+}
+
+// Status GET /api/v1/system/ldap
+// Returns the outcome of the last directory sync.
+func (h *LDAPHandler) Status(c *gin.Context) {
+	c.JSON(http.StatusOK, h.sync.Status())
+}
+
+// Sync POST /api/v1/system/ldap/sync
+// Syncs users and groups from the directory now rather than at the next
+// interval, and returns the outcome.
+func (h *LDAPHandler) Sync(c *gin.Context) {
+	st, err := h.sync.Sync(c.Request.Context())
+	if err != nil {
+		failErr(c, http.StatusBadGateway, "ldap sync failed", err)
+		return
+	}
+	c.JSON(http.StatusOK, st)
+}
+
+// Available aborts with 503 when LDAP is disabled.
+func (h *LDAPHandler) Available(c *gin.Context) {
+	if h.sync == nil {
+		Abort(c, http.StatusServiceUnavailable, CodeFeatureDisabled, "ldap is disabled")
+		return
+	}
+	c.Next()
+}
@@ -0,0 +1,324 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"go.uber.org/zap"
+
+	"github.com/aegisx/aegisx/internal/config"
+	"github.com/aegisx/aegisx/internal/firewall"
+	"github.com/aegisx/aegisx/internal/health"
+	"github.com/aegisx/aegisx/internal/monitor"
+	"github.com/aegisx/aegisx/internal/policy"
+	"github.com/aegisx/aegisx/internal/store"
+)
+
+// MonitorHandler handles /api/v1/monitors.
+type MonitorHandler struct {
+	mgr *monitor.Manager // nil when monitors are disabled
+	fw  *firewall.Service
+	cfg *config.MonitorsConfig
+	log *zap.Logger
+}
+
+func NewMonitorHandler(mgr *monitor.Manager, fw *firewall.Service, cfg *config.MonitorsConfig, log *zap.Logger) *MonitorHandler {
+	return &MonitorHandler{mgr: mgr, fw: fw, cfg: cfg, log: log}
+}
+
+var monitorName = regexp.MustCompile(`^[a-z0-9][a-z0-9_-]{0,62}$`)
+
+type monitorRequest struct {
+	Name          string `json:"name"` // fixed once created
+	Description   string `json:"description"`
+	Type          string `json:"type"    binding:"required"` // icmp|tcp|http|dns
+	Address       string `json:"address" binding:"required"`
+	Interface     string `json:"interface"`
+	Interval      string `json:"interval"` // default 10s
+	Timeout       string `json:"timeout"`  // default 2s
+	Rise          int    `json:"rise"`     // default 2
+	Fall          int    `json:"fall"`     // default 3
+	ExpectStatus  int    `json:"expectStatus"`
+	Query         string `json:"query"`
+	RecordType    string `json:"recordType"`
+	ExpectAddress string `json:"expectAddress"`
+	Enabled       *bool  `json:"enabled"` // default true
+}
+
+// MonitorStatus is a monitor with its current probe state; Status is nil
+// while the monitor is disabled.
+type MonitorStatus struct {
+	*store.Monitor
+	Status *health.TargetStatus `json:"status,omitempty"`
+}
+
+// List GET /api/v1/monitors
+func (h *MonitorHandler) List(c *gin.Context) {
+	mons, err := h.mgr.Store().List(c.Request.Context())
+	if err != nil {
+		h.log.Error("list monitors", zap.Error(err))
+		fail(c, http.StatusInternalServerError, "failed to list monitors")
+		return
+	}
+	status := h.mgr.Status()
+	items := make([]MonitorStatus, len(mons))
+	for i, m := range mons {
+		items[i] = withStatus(m, status)
+	}
+	c.JSON(http.StatusOK, gin.H{"items": items, "count": len(items)})
+}
+
+// Create POST /api/v1/monitors
+// The monitor is probed right away; rules may name it in a condition once
+// it exists.
+func (h *MonitorHandler) Create(c *gin.Context) {
+	var req monitorRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		fail(c, http.StatusBadRequest, err.Error())
+		return
+	}
+	if !monitorName.MatchString(req.Name) {
+		fail(c, http.StatusBadRequest, "name must be lower-case letters, digits, '-' or '_'")
+		return
+	}
+	if h.fw.PolicyTarget(req.Name) {
+		fail(c, http.StatusConflict, "the applied policy already defines a health target named "+req.Name)
+		return
+	}
+	ctx := c.Request.Context()
+	if h.cfg.Max > 0 {
+		mons, err := h.mgr.Store().List(ctx)
+		if err != nil {
+			h.log.Error("list monitors", zap.Error(err))
+			fail(c, http.StatusInternalServerError, "failed to list monitors")
+			return
+		}
+		if len(mons) >= h.cfg.Max {
+			Abort(c, http.StatusConflict, CodeQuotaExceeded, "at most "+strconv.Itoa(h.cfg.Max)+" monitors may exist")
+			return
+		}
+	}
+
+	uid := callerID(c)
+	m := &store.Monitor{Name: req.Name, CreatedBy: &uid}
+	if !h.fill(c, m, &req) {
+		return
+	}
+	if err := h.mgr.Store().Create(ctx, m); err != nil {
+		if strings.Contains(err.Error(), "already exists") {
+			fail(c, http.StatusConflict, err.Error())
+			return
+		}
+		h.log.Error("create monitor", zap.Error(err))
+		fail(c, http.StatusInternalServerError, "failed to create monitor")
+		return
+	}
+	h.reload(c)
+	c.JSON(http.StatusCreated, m)
+}
+
+// Get GET /api/v1/monitors/:id
+// Returns the monitor, its probe state and its latest state changes.
+func (h *MonitorHandler) Get(c *gin.Context) {
+	m, ok := h.load(c)
+	if !ok {
+		return
+	}
+	events, err := h.mgr.Store().ListEvents(c.Request.Context(), m.ID, 20)
+	if err != nil {
+		h.log.Error("list monitor events", zap.Error(err))
+		fail(c, http.StatusInternalServerError, "failed to list events")
+		return
+	}
+	if events == nil {
+		events = []*store.MonitorEvent{}
+	}
+	c.JSON(http.StatusOK, gin.H{"monitor": withStatus(m, h.mgr.Status()), "events": events})
+}
+
+// Update PUT /api/v1/monitors/:id
+// Replaces the probe settings; the name cannot change. A changed monitor
+// starts over as unknown, which rules treat as up.
+func (h *MonitorHandler) Update(c *gin.Context) {
+	m, ok := h.load(c)
+	if !ok {
+		return
+	}
+	var req monitorRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		fail(c, http.StatusBadRequest, err.Error())
+		return
+	}
+	if req.Name != "" && req.Name != m.Name {
+		fail(c, http.StatusBadRequest, "a monitor cannot be renamed")
+		return
+	}
+	if !h.fill(c, m, &req) {
+		return
+	}
+	if !m.Enabled && h.fw.TargetInUse(m.Name) {
+		fail(c, http.StatusConflict, "rules of the applied policy are conditioned on "+m.Name)
+		return
+	}
+	if err := h.mgr.Store().Update(c.Request.Context(), m); err != nil {
+		h.log.Error("update monitor", zap.Error(err))
+		fail(c, http.StatusInternalServerError, "failed to update monitor")
+		return
+	}
+	h.reload(c)
+	c.JSON(http.StatusOK, m)
+}
+
+// Delete DELETE /api/v1/monitors/:id
+// Refused while rules of the applied policy are conditioned on the monitor.
+func (h *MonitorHandler) Delete(c *gin.Context) {
+	m, ok := h.load(c)
+	if !ok {
+		return
+	}
+	if h.fw.TargetInUse(m.Name) {
+		fail(c, http.StatusConflict, "rules of the applied policy are conditioned on "+m.Name)
+		return
+	}
+	if err := h.mgr.Store().Delete(c.Request.Context(), m.ID); err != nil {
+		h.log.Error("delete monitor", zap.Error(err))
+		fail(c, http.StatusInternalServerError, "failed to delete monitor")
+		return
+	}
+	h.reload(c)
+	c.JSON(http.StatusOK, gin.H{"status": "deleted"})
+}
+
+// Events GET /api/v1/monitors/:id/events?limit=100
+// Returns the monitor's state changes, newest first.
+func (h *MonitorHandler) Events(c *gin.Context) {
+	m, ok := h.load(c)
+	if !ok {
+		return
+	}
+	limit, err := strconv.Atoi(c.DefaultQuery("limit", "100"))
+	if err != nil || limit <= 0 || limit > 1000 {
+		fail(c, http.StatusBadRequest, "limit must be between 1 and 1000")
+		return
+	}
+	items, err := h.mgr.Store().ListEvents(c.Request.Context(), m.ID, limit)
+	if err != nil {
+		h.log.Error("list monitor events", zap.Error(err))
+		fail(c, http.StatusInternalServerError, "failed to list events")
+		return
+	}
+	if items == nil {
+		items = []*store.MonitorEvent{}
+	}
+	c.JSON(http.StatusOK, gin.H{"items": items, "count": len(items)})
+}
+
+// Available aborts with 503 when monitors are disabled.
+func (h *MonitorHandler) Available(c *gin.Context) {
+	if h.mgr == nil {
+		Abort(c, http.StatusServiceUnavailable, CodeFeatureDisabled, "monitors are disabled")
+		return
+	}
+	c.Next()
+}
+
+// ─── Private helpers ──────────────────────────────────────────────────────
+
+func (h *MonitorHandler) load(c *gin.Context) (*store.Monitor, bool) {
+	id, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		fail(c, http.StatusBadRequest, "invalid id")
+		return nil, false
+	}
+	m, err := h.mgr.Store().Get(c.Request.Context(), id)
+	if err != nil {
+		fail(c, http.StatusNotFound, "monitor not found")
+		return nil, false
+	}
+	return m, true
+}
+
+// fill copies req onto m, applying defaults, and validates the result as a
+// health target. It writes the error response and returns false when req
+// is invalid.
+func (h *MonitorHandler) fill(c *gin.Context, m *store.Monitor, req *monitorRequest) bool {
+	interval, ok := parseMonitorDuration(c, "interval", req.Interval, 10*time.Second)
+	if !ok {
+		return false
+	}
+	if interval < h.cfg.MinInterval {
+		fail(c, http.StatusBadRequest, "interval must be at least "+h.cfg.MinInterval.String())
+		return false
+	}
+	timeout, ok := parseMonitorDuration(c, "timeout", req.Timeout, 2*time.Second)
+	if !ok {
+		return false
+	}
+	if timeout > interval {
+		fail(c, http.StatusBadRequest, "timeout must not exceed the interval")
+		return false
+	}
+
+	m.Description = req.Description
+	m.Type = req.Type
+	m.Address = req.Address
+	m.Interface = req.Interface
+	m.IntervalMs = int(interval / time.Millisecond)
+	m.TimeoutMs = int(timeout / time.Millisecond)
+	m.Rise, m.Fall = req.Rise, req.Fall
+	if m.Rise == 0 {
+		m.Rise = 2
+	}
+	if m.Fall == 0 {
+		m.Fall = 3
+	}
+	m.ExpectStatus = req.ExpectStatus
+	m.Query = req.Query
+	m.RecordType = strings.ToUpper(req.RecordType)
+	m.ExpectAddress = req.ExpectAddress
+	m.Enabled = req.Enabled == nil || *req.Enabled
+
+	if err := policy.ValidateHealthTarget(monitor.Target(m)); err != nil {
+		var ve *policy.ValidationError
+		if errors.As(err, &ve) {
+			Abort(c, http.StatusUnprocessableEntity, CodeValidationFailed, "invalid monitor", ve.Errors...)
+		} else {
+			fail(c, http.StatusBadRequest, err.Error())
+		}
+		return false
+	}
+	return true
+}
+
+func parseMonitorDuration(c *gin.Context, field, s string, def time.Duration) (time.Duration, bool) {
+	if s == "" {
+		return def, true
+	}
+	d, err := time.ParseDuration(s)
+	if err != nil || d <= 0 {
+		fail(c, http.StatusBadRequest, "invalid "+field)
+		return 0, false
+	}
+	return d, true
+}
+
+// reload hands the stored monitors to the prober right away.
+func (h *MonitorHandler) reload(c *gin.Context) {
+	if err := h.mgr.Load(c.Request.Context()); err != nil {
+		h.log.Warn("reload monitors", zap.Error(err))
+	}
+}
+
+func withStatus(m *store.Monitor, status map[string]health.TargetStatus) MonitorStatus {
+	out := MonitorStatus{Monitor: m}
+	if st, ok := status[m.Name]; ok {
+		out.Status = &st
+	}
+	return out
+}
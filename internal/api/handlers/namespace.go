@@ -0,0 +1,389 @@
+package handlers
+
+import (
+	"context"
+	"net/http"
+	"regexp"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"go.uber.org/zap"
+
+	"github.com/aegisx/aegisx/internal/store"
+)
+
+// namespaceNameRe mirrors Kubernetes DNS-label naming.
+var namespaceNameRe = regexp.MustCompile(`^[a-z0-9]([-a-z0-9]{0,61}[a-z0-9])?$`)
+
+var validBindingRoles = map[string]bool{"admin": true, "operator": true, "viewer": true}
+
+// NamespaceHandler handles /api/v1/namespaces endpoints.
+type NamespaceHandler struct {
+	store *store.NamespaceStore
+	log   *zap.Logger
+}
+
+func NewNamespaceHandler(store *store.NamespaceStore, log *zap.Logger) *NamespaceHandler {
+	return &NamespaceHandler{store: store, log: log}
+}
+
+// ─── Request / Response DTOs ──────────────────────────────────────────────
+
+type CreateNamespaceRequest struct {
+	Name        string            `json:"name"        binding:"required"`
+	Description string            `json:"description"`
+	Labels      map[string]string `json:"labels"`
+	MaxPolicies int               `json:"maxPolicies"`
+	MaxRules    int               `json:"maxRules"`
+}
+
+type UpdateNamespaceRequest struct {
+	Description *string           `json:"description"`
+	Labels      map[string]string `json:"labels"`
+	MaxPolicies *int              `json:"maxPolicies"`
+	MaxRules    *int              `json:"maxRules"`
+}
+
+type PutBindingRequest struct {
+	Role string `json:"role" binding:"required"`
+}
+
+// ─── Handlers ─────────────────────────────────────────────────────────────
+
+// List GET /api/v1/namespaces
+// Namespaces the caller cannot read are omitted.
+func (h *NamespaceHandler) List(c *gin.Context) {
+	tenantID := mustTenantID(c)
+
+	acl, err := loadNamespaceACL(c.Request.Context(), h.store, c)
+	if err != nil {
+		h.log.Error("load namespace access", zap.Error(err))
+		fail(c, http.StatusInternalServerError, "failed to list namespaces")
+		return
+	}
+
+	namespaces, err := h.store.List(c.Request.Context(), tenantID)
+	if err != nil {
+		h.log.Error("list namespaces", zap.Error(err))
+		fail(c, http.StatusInternalServerError, "failed to list namespaces")
+		return
+	}
+
+	items := make([]*store.NamespaceRecord, 0, len(namespaces))
+	for _, n := range namespaces {
+		if acl.canRead(n.Name) {
+			items = append(items, n)
+		}
+	}
+	c.JSON(http.StatusOK, gin.H{"items": items, "count": len(items)})
+}
+
+// Get GET /api/v1/namespaces/:name
+func (h *NamespaceHandler) Get(c *gin.Context) {
+	tenantID := mustTenantID(c)
+	name := c.Param("name")
+
+	acl, err := loadNamespaceACL(c.Request.Context(), h.store, c)
+	if err != nil {
+		fail(c, http.StatusInternalServerError, err.Error())
+		return
+	}
+	if !acl.canRead(name) {
+		fail(c, http.StatusForbidden, "no access to namespace "+name)
+		return
+	}
+
+	n, err := h.store.Get(c.Request.Context(), tenantID, name)
+	if err != nil {
+		if strings.Contains(err.Error(), "not found") {
+			fail(c, http.StatusNotFound, "namespace not found")
+			return
+		}
+		fail(c, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	count, err := h.store.CountPolicies(c.Request.Context(), tenantID, name)
+	if err != nil {
+		fail(c, http.StatusInternalServerError, err.Error())
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"namespace": n, "policyCount": count})
+}
+
+// Create POST /api/v1/namespaces
+func (h *NamespaceHandler) Create(c *gin.Context) {
+	tenantID := mustTenantID(c)
+
+	var req CreateNamespaceRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		fail(c, http.StatusBadRequest, err.Error())
+		return
+	}
+	if !namespaceNameRe.MatchString(req.Name) {
+		fail(c, http.StatusBadRequest, "invalid namespace name "+req.Name)
+		return
+	}
+	if req.MaxPolicies < 0 || req.MaxRules < 0 {
+		fail(c, http.StatusBadRequest, "quotas must not be negative")
+		return
+	}
+
+	uid := callerID(c)
+	record := &store.NamespaceRecord{
+		TenantID:    tenantID,
+		Name:        req.Name,
+		Description: req.Description,
+		Labels:      req.Labels,
+		MaxPolicies: req.MaxPolicies,
+		MaxRules:    req.MaxRules,
+		CreatedBy:   &uid,
+	}
+
+	if err := h.store.Create(c.Request.Context(), record); err != nil {
+		h.log.Error("create namespace", zap.Error(err))
+		fail(c, http.StatusInternalServerError, "failed to create namespace")
+		return
+	}
+	c.JSON(http.StatusCreated, record)
+}
+
+// Update PUT /api/v1/namespaces/:name
+func (h *NamespaceHandler) Update(c *gin.Context) {
+	tenantID := mustTenantID(c)
+	name := c.Param("name")
+
+	if !h.canAdminister(c, name) {
+		fail(c, http.StatusForbidden, "namespace admin role required")
+		return
+	}
+
+	var req UpdateNamespaceRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		fail(c, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	existing, err := h.store.Get(c.Request.Context(), tenantID, name)
+	if err != nil {
+		fail(c, http.StatusNotFound, "namespace not found")
+		return
+	}
+
+	if req.Description != nil {
+		existing.Description = *req.Description
+	}
+	if req.Labels != nil {
+		existing.Labels = req.Labels
+	}
+	// Quotas are a tenant-admin decision; namespace admins may not raise their own.
+	if req.MaxPolicies != nil || req.MaxRules != nil {
+		if !isAdmin(c) {
+			fail(c, http.StatusForbidden, "only tenant admins may change quotas")
+			return
+		}
+		if req.MaxPolicies != nil {
+			existing.MaxPolicies = *req.MaxPolicies
+		}
+		if req.MaxRules != nil {
+			existing.MaxRules = *req.MaxRules
+		}
+		if existing.MaxPolicies < 0 || existing.MaxRules < 0 {
+			fail(c, http.StatusBadRequest, "quotas must not be negative")
+			return
+		}
+	}
+
+	if err := h.store.Update(c.Request.Context(), existing); err != nil {
+		fail(c, http.StatusInternalServerError, "failed to update namespace")
+		return
+	}
+	c.JSON(http.StatusOK, existing)
+}
+
+// Delete DELETE /api/v1/namespaces/:name
+func (h *NamespaceHandler) Delete(c *gin.Context) {
+	tenantID := mustTenantID(c)
+	name := c.Param("name")
+
+	if name == store.DefaultNamespace {
+		fail(c, http.StatusBadRequest, "the default namespace cannot be deleted")
+		return
+	}
+
+	if err := h.store.Delete(c.Request.Context(), tenantID, name); err != nil {
+		switch {
+		case strings.Contains(err.Error(), "not found"):
+			fail(c, http.StatusNotFound, "namespace not found")
+		case strings.Contains(err.Error(), "still contains"):
+			fail(c, http.StatusConflict, err.Error())
+		default:
+			fail(c, http.StatusInternalServerError, err.Error())
+		}
+		return
+	}
+	c.Status(http.StatusNoContent)
+}
+
+// ListBindings GET /api/v1/namespaces/:name/bindings
+func (h *NamespaceHandler) ListBindings(c *gin.Context) {
+	tenantID := mustTenantID(c)
+	name := c.Param("name")
+
+	if !h.canAdminister(c, name) {
+		fail(c, http.StatusForbidden, "namespace admin role required")
+		return
+	}
+
+	n, err := h.store.Get(c.Request.Context(), tenantID, name)
+	if err != nil {
+		fail(c, http.StatusNotFound, "namespace not found")
+		return
+	}
+
+	bindings, err := h.store.ListBindings(c.Request.Context(), tenantID, n.ID)
+	if err != nil {
+		fail(c, http.StatusInternalServerError, err.Error())
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"items": bindings, "count": len(bindings)})
+}
+
+// PutBinding PUT /api/v1/namespaces/:name/bindings/:userId
+func (h *NamespaceHandler) PutBinding(c *gin.Context) {
+	tenantID := mustTenantID(c)
+	name := c.Param("name")
+
+	if !h.canAdminister(c, name) {
+		fail(c, http.StatusForbidden, "namespace admin role required")
+		return
+	}
+
+	userID, err := uuid.Parse(c.Param("userId"))
+	if err != nil {
+		fail(c, http.StatusBadRequest, "invalid user id")
+		return
+	}
+
+	var req PutBindingRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		fail(c, http.StatusBadRequest, err.Error())
+		return
+	}
+	if !validBindingRoles[req.Role] {
+		fail(c, http.StatusBadRequest, "role must be one of admin|operator|viewer")
+		return
+	}
+
+	n, err := h.store.Get(c.Request.Context(), tenantID, name)
+	if err != nil {
+		fail(c, http.StatusNotFound, "namespace not found")
+		return
+	}
+
+	binding := &store.NamespaceBinding{NamespaceID: n.ID, UserID: userID, Role: req.Role}
+	if err := h.store.PutBinding(c.Request.Context(), tenantID, binding); err != nil {
+		if strings.Contains(err.Error(), "not found") {
+			fail(c, http.StatusNotFound, "user not found")
+			return
+		}
+		h.log.Error("put namespace binding", zap.Error(err))
+		fail(c, http.StatusInternalServerError, "failed to save binding")
+		return
+	}
+	c.JSON(http.StatusOK, binding)
+}
+
+// DeleteBinding DELETE /api/v1/namespaces/:name/bindings/:userId
+func (h *NamespaceHandler) DeleteBinding(c *gin.Context) {
+	tenantID := mustTenantID(c)
+	name := c.Param("name")
+
+	if !h.canAdminister(c, name) {
+		fail(c, http.StatusForbidden, "namespace admin role required")
+		return
+	}
+
+	userID, err := uuid.Parse(c.Param("userId"))
+	if err != nil {
+		fail(c, http.StatusBadRequest, "invalid user id")
+		return
+	}
+
+	n, err := h.store.Get(c.Request.Context(), tenantID, name)
+	if err != nil {
+		fail(c, http.StatusNotFound, "namespace not found")
+		return
+	}
+
+	if err := h.store.DeleteBinding(c.Request.Context(), tenantID, n.ID, userID); err != nil {
+		fail(c, http.StatusNotFound, "binding not found")
+		return
+	}
+	c.Status(http.StatusNoContent)
+}
+
+// ─── Helpers ──────────────────────────────────────────────────────────────
+
+func (h *NamespaceHandler) canAdminister(c *gin.Context, name string) bool {
+	if isAdmin(c) {
+		return true
+	}
+	acl, err := loadNamespaceACL(c.Request.Context(), h.store, c)
+	if err != nil {
+		h.log.Warn("load namespace access", zap.Error(err))
+		return false
+	}
+	return acl.roles[name] == "admin"
+}
+
+// namespaceACL resolves what the caller may do in each namespace.
+// Tenant admins may do anything. A namespace without bindings is open to
+// every tenant user; once bound, only users holding a binding may read it,
+// and only operator/admin bindings may write.
+type namespaceACL struct {
+	admin bool
+	owned map[string]bool
+	roles map[string]string
+}
+
+func loadNamespaceACL(ctx context.Context, ns *store.NamespaceStore, c *gin.Context) (*namespaceACL, error) {
+	if isAdmin(c) {
+		return &namespaceACL{admin: true}, nil
+	}
+	owned, roles, err := ns.LoadAccess(ctx, mustTenantID(c), callerID(c))
+	if err != nil {
+		return nil, err
+	}
+	return &namespaceACL{owned: owned, roles: roles}, nil
+}
+
+func (a *namespaceACL) canRead(ns string) bool {
+	if a.admin || !a.owned[ns] {
+		return true
+	}
+	return a.roles[ns] != ""
+}
+
+func (a *namespaceACL) canWrite(ns string) bool {
+	if a.admin {
+		return true
+	}
+	if !a.owned[ns] {
+		return true
+	}
+	role := a.roles[ns]
+	return role == "admin" || role == "operator"
+}
+
+func isAdmin(c *gin.Context) bool {
+	role, _ := c.Get("role")
+	return role == "admin"
+}
+
+func callerID(c *gin.Context) uuid.UUID {
+	val, _ := c.Get("user_id")
+	id, _ := val.(uuid.UUID)
+	return id
+}
@@ -0,0 +1,41 @@
+package handlers
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+
+	"github.com/aegisx/aegisx/internal/observability"
+)
+
+type ObservabilityHandler struct {
+	log *zap.Logger
+}
+
+func NewObservabilityHandler(log *zap.Logger) *ObservabilityHandler {
+	return &ObservabilityHandler{log: log}
+}
+
+// GrafanaDashboards GET /api/v1/observability/grafana-dashboards
+// Returns the Grafana dashboards and Prometheus alerting rules generated for
+// the metrics this build exports. ?uid= returns one dashboard as Grafana
+// imports it.
+func (h *ObservabilityHandler) GrafanaDashboards(c *gin.Context) {
+	b, err := observability.Generate()
+	if err != nil {
+		h.log.Error("generate dashboards", zap.Error(err))
+		failErr(c, http.StatusInternalServerError, "failed to generate dashboards", err)
+		return
+	}
+	if uid := c.Query("uid"); uid != "" {
+		d, ok := b.Dashboard(uid)
+		if !ok {
+			fail(c, http.StatusNotFound, "dashboard not found")
+			return
+		}
+		c.JSON(http.StatusOK, d)
+		return
+	}
+	c.JSON(http.StatusOK, b)
+}
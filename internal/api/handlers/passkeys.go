@@ -0,0 +1,173 @@
+package handlers
+
+import (
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"go.uber.org/zap"
+
+	"github.com/aegisx/aegisx/internal/auth"
+)
+
+// PasskeyLoginRequest starts a passkey login. With passkeys as a second
+// factor MFAToken (from /auth/login) is required; otherwise Username alone
+// makes the passkey a password replacement.
+type PasskeyLoginRequest struct {
+	Username string `json:"username"`
+	MFAToken string `json:"mfaToken"`
+}
+
+// PasskeyLoginBegin POST /api/v1/auth/passkeys/login/begin
+// Returns a ceremony ID and the options for navigator.credentials.get().
+func (h *AuthHandler) PasskeyLoginBegin(c *gin.Context) {
+	if !h.passkeysEnabled(c) {
+		return
+	}
+	var req PasskeyLoginRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		fail(c, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	var (
+		id  *auth.Identity
+		err error
+	)
+	switch {
+	case req.MFAToken != "":
+		id, err = h.svc.ValidateMFAToken(c.Request.Context(), req.MFAToken)
+	case h.passkeys.SecondFactor():
+		fail(c, http.StatusBadRequest, "mfaToken is required: sign in with your password first")
+		return
+	case req.Username != "":
+		id, err = h.svc.Lookup(c.Request.Context(), req.Username)
+	default:
+		fail(c, http.StatusBadRequest, "username or mfaToken is required")
+		return
+	}
+	if err != nil {
+		h.loginFailed(c, req.Username, err)
+		return
+	}
+
+	ceremony, opts, err := h.passkeys.BeginLogin(c.Request.Context(), id)
+	if err != nil {
+		h.loginFailed(c, id.Username, err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"ceremony": ceremony, "options": opts})
+}
+
+// PasskeyLoginFinish POST /api/v1/auth/passkeys/login/finish?ceremony=
+// The body is the PublicKeyCredential returned by the authenticator.
+func (h *AuthHandler) PasskeyLoginFinish(c *gin.Context) {
+	if !h.passkeysEnabled(c) {
+		return
+	}
+	id, err := h.passkeys.FinishLogin(c.Request.Context(), c.Query("ceremony"), c.Request)
+	if err != nil {
+		h.loginFailed(c, "", err)
+		return
+	}
+	h.log.Info("passkey login", zap.String("username", id.Username), zap.String("ip", c.ClientIP()))
+	h.issue(c, id)
+}
+
+// ListPasskeys GET /api/v1/auth/passkeys
+func (h *AuthHandler) ListPasskeys(c *gin.Context) {
+	id, ok := h.passkeyOwner(c)
+	if !ok {
+		return
+	}
+	items, err := h.passkeys.Credentials(c.Request.Context(), id.UserID)
+	if err != nil {
+		fail(c, http.StatusInternalServerError, err.Error())
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"items": items, "count": len(items)})
+}
+
+// PasskeyRegisterBegin POST /api/v1/auth/passkeys/register/begin
+// Returns a ceremony ID and the options for navigator.credentials.create().
+func (h *AuthHandler) PasskeyRegisterBegin(c *gin.Context) {
+	id, ok := h.passkeyOwner(c)
+	if !ok {
+		return
+	}
+	ceremony, opts, err := h.passkeys.BeginRegistration(c.Request.Context(), id)
+	if err != nil {
+		h.log.Error("begin passkey registration", zap.Error(err))
+		fail(c, http.StatusInternalServerError, "failed to start registration")
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"ceremony": ceremony, "options": opts})
+}
+
+// PasskeyRegisterFinish POST /api/v1/auth/passkeys/register/finish?ceremony=&name=
+// The body is the PublicKeyCredential returned by the authenticator.
+func (h *AuthHandler) PasskeyRegisterFinish(c *gin.Context) {
+	id, ok := h.passkeyOwner(c)
+	if !ok {
+		return
+	}
+	name := strings.TrimSpace(c.Query("name"))
+	if name == "" {
+		name = "passkey"
+	}
+	cred, err := h.passkeys.FinishRegistration(c.Request.Context(), id, c.Query("ceremony"), name, c.Request)
+	if err != nil {
+		fail(c, http.StatusBadRequest, err.Error())
+		return
+	}
+	h.log.Info("passkey registered", zap.String("username", id.Username), zap.String("credential", cred.ID.String()))
+	c.JSON(http.StatusCreated, cred)
+}
+
+// DeletePasskey DELETE /api/v1/auth/passkeys/:id
+func (h *AuthHandler) DeletePasskey(c *gin.Context) {
+	id, ok := h.passkeyOwner(c)
+	if !ok {
+		return
+	}
+	credID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		fail(c, http.StatusBadRequest, "invalid id")
+		return
+	}
+	if err := h.passkeys.RemoveCredential(c.Request.Context(), id.UserID, credID); err != nil {
+		fail(c, http.StatusNotFound, "passkey not found")
+		return
+	}
+	h.log.Info("passkey removed", zap.String("username", id.Username), zap.String("credential", credID.String()))
+	c.Status(http.StatusNoContent)
+}
+
+// ─── Helpers ──────────────────────────────────────────────────────────────
+
+func (h *AuthHandler) passkeysEnabled(c *gin.Context) bool {
+	if h.passkeys == nil {
+		Abort(c, http.StatusServiceUnavailable, CodeFeatureDisabled, "passkey login is disabled")
+		return false
+	}
+	return true
+}
+
+// passkeyOwner resolves the caller for managing their own passkeys. An
+// impersonating admin must not enrol keys for someone else.
+func (h *AuthHandler) passkeyOwner(c *gin.Context) (*auth.Identity, bool) {
+	if !h.passkeysEnabled(c) {
+		return nil, false
+	}
+	if _, ok := c.Get("impersonation_id"); ok {
+		fail(c, http.StatusForbidden, "not permitted while impersonating")
+		return nil, false
+	}
+	id, err := h.svc.LookupID(c.Request.Context(), callerID(c))
+	if err != nil {
+		fail(c, http.StatusNotFound, "user not found")
+		return nil, false
+	}
+	return id, true
+}
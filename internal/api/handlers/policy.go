@@ -1,30 +1,51 @@
 package handlers
 
 import (
-	"context"
 	"encoding/json"
+	"fmt"
+	"io"
 	"net/http"
+	"strconv"
 	"strings"
+	"time"
 
 	"github.com/gin-gonic/gin"
 	"github.com/google/uuid"
 	"go.uber.org/zap"
 
+	"github.com/aegisx/aegisx/internal/admission"
+	"github.com/aegisx/aegisx/internal/api/authz"
 	"github.com/aegisx/aegisx/internal/firewall"
+	"github.com/aegisx/aegisx/internal/ids"
+	"github.com/aegisx/aegisx/internal/lb"
+	"github.com/aegisx/aegisx/internal/metrics"
 	"github.com/aegisx/aegisx/internal/policy"
+	"github.com/aegisx/aegisx/internal/redact"
+	"github.com/aegisx/aegisx/internal/signing"
 	"github.com/aegisx/aegisx/internal/store"
+	"github.com/aegisx/aegisx/internal/vpn"
 )
 
 // PolicyHandler handles /api/v1/policies endpoints.
 type PolicyHandler struct {
 	store       *store.PolicyStore
+	namespaces  *store.NamespaceStore
 	firewallSvc *firewall.Service
+	admission   *admission.Controller
+	signing     *signing.Verifier // nil when no signing keys are configured
+	lbAdapter   *lb.Adapter       // nil when the load balancer is disabled
+	vpnMgr      *vpn.Manager
+	idsAdapter  *ids.Adapter // nil when IDS is disabled
 	parser      *policy.Parser
 	log         *zap.Logger
 }
 
-func NewPolicyHandler(store *store.PolicyStore, fw *firewall.Service, log *zap.Logger) *PolicyHandler {
-	return &PolicyHandler{store: store, firewallSvc: fw, parser: policy.NewParser(), log: log}
+func NewPolicyHandler(store *store.PolicyStore, namespaces *store.NamespaceStore, fw *firewall.Service, adm *admission.Controller, verifier *signing.Verifier, lbAdapter *lb.Adapter, vpnMgr *vpn.Manager, idsAdapter *ids.Adapter, log *zap.Logger) *PolicyHandler {
+	return &PolicyHandler{
+		store: store, namespaces: namespaces, firewallSvc: fw, admission: adm, signing: verifier,
+		lbAdapter: lbAdapter, vpnMgr: vpnMgr, idsAdapter: idsAdapter,
+		parser: fw.Parser(), log: log,
+	}
 }
 
 // ─── Request / Response DTOs ──────────────────────────────────────────────
@@ -36,49 +57,220 @@ type CreatePolicyRequest struct {
 	Spec      json.RawMessage `json:"spec"      binding:"required"`
 	RawYAML   string          `json:"rawYaml"`
 	Enabled   bool            `json:"enabled"`
+	Signature string          `json:"signature"` // of RawYAML; see package signing
+}
+
+type ValidatePolicyRequest struct {
+	RawYAML string `json:"rawYaml" binding:"required"`
+}
+
+// ValidateSchemaRequest is the body of POST /api/v1/policies/validate-schema:
+// manifests, in YAML or JSON, or the kind and spec of one policy.
+type ValidateSchemaRequest struct {
+	RawYAML string          `json:"rawYaml"`
+	Kind    string          `json:"kind"`
+	Spec    json.RawMessage `json:"spec"`
+}
+
+// PreviewPolicyRequest is the body of POST /api/v1/policies/preview.
+type PreviewPolicyRequest struct {
+	RawYAML string `json:"rawYaml" binding:"required"`
 }
 
 type UpdatePolicyRequest struct {
 	Spec    json.RawMessage `json:"spec"`
 	RawYAML string          `json:"rawYaml"`
 	Enabled *bool           `json:"enabled"`
+	// Signature signs RawYAML, or the stored manifest when RawYAML is
+	// empty. A new manifest without one is stored unsigned.
+	Signature string `json:"signature"`
 }
 
 // ─── Handlers ─────────────────────────────────────────────────────────────
 
-// List GET /api/v1/policies
+// List GET /api/v1/policies?kind=&namespace=&fields=&bodies=&watch=&resourceVersion=
+// Policies in namespaces the caller cannot read are omitted. fields selects
+// the returned keys (e.g. fields=id,name,version); bodies=false drops spec
+// and rawYaml, which are also skipped when fields does not name them. The
+// X-Resource-Version header holds the version the list is current at.
+//
+// With watch=true the response is an event stream of "added", "modified"
+// and "deleted" events, each carrying the resourceVersion and the policy as
+// the list would show it. Without resourceVersion the current policies come
+// first as "added" events; with it, the changes after that version are
+// replayed, or the request fails with 410 when they are no longer kept. A
+// "bookmark" event every 30s carries the version to resume from.
 func (h *PolicyHandler) List(c *gin.Context) {
 	tenantID := mustTenantID(c)
-	kind := c.Query("kind")
+	fields, ok := parseFields(c, store.PolicyRecord{})
+	if !ok {
+		return
+	}
+	filter := store.PolicyFilter{
+		Kind:       c.Query("kind"),
+		Namespace:  c.Query("namespace"),
+		SkipBodies: c.Query("bodies") == "false" || !(hasField(fields, "spec") || hasField(fields, "rawYaml")),
+	}
+
+	acl, err := loadNamespaceACL(c.Request.Context(), h.namespaces, c)
+	if err != nil {
+		h.log.Error("load namespace access", zap.Error(err))
+		fail(c, http.StatusInternalServerError, "failed to list policies")
+		return
+	}
+	if filter.Namespace != "" && !acl.canRead(filter.Namespace) {
+		fail(c, http.StatusForbidden, "no access to namespace "+filter.Namespace)
+		return
+	}
+	if c.Query("watch") == "true" {
+		h.watch(c, tenantID, filter, fields, acl)
+		return
+	}
 
-	policies, err := h.store.List(c.Request.Context(), tenantID, kind)
+	rv := h.store.ResourceVersion()
+	policies, err := h.store.List(c.Request.Context(), tenantID, filter)
 	if err != nil {
 		h.log.Error("list policies", zap.Error(err))
-		c.JSON(http.StatusInternalServerError, errResp("failed to list policies"))
+		fail(c, http.StatusInternalServerError, "failed to list policies")
 		return
 	}
-	c.JSON(http.StatusOK, gin.H{"items": policies, "count": len(policies)})
+
+	items := make([]any, 0, len(policies))
+	var modified time.Time
+	for _, p := range policies {
+		if !acl.canRead(p.Namespace) {
+			continue
+		}
+		item, err := project(p, fields)
+		if err != nil {
+			fail(c, http.StatusInternalServerError, "encode policy: "+err.Error())
+			return
+		}
+		items = append(items, item)
+		modified = latest(modified, p)
+	}
+	c.Header("X-Resource-Version", strconv.FormatInt(rv, 10))
+	writeCached(c, "", modified, gin.H{"items": items, "count": len(items)})
 }
 
-// Get GET /api/v1/policies/:id
+// watchBookmark is how often a policy watch sends the version to resume
+// from, which also keeps idle connections open through proxies.
+const watchBookmark = 30 * time.Second
+
+// watch streams the policy changes List's filter selects; see List.
+func (h *PolicyHandler) watch(c *gin.Context, tenantID uuid.UUID, filter store.PolicyFilter, fields []string, acl *namespaceACL) {
+	var since int64
+	if v := c.Query("resourceVersion"); v != "" {
+		n, err := strconv.ParseInt(v, 10, 64)
+		if err != nil || n <= 0 {
+			fail(c, http.StatusBadRequest, "resourceVersion must be a positive integer")
+			return
+		}
+		since = n
+	}
+	events, cancel, rv, err := h.store.Watch(since)
+	if err != nil { // store.ErrResourceVersionExpired
+		fail(c, http.StatusGone, err.Error())
+		return
+	}
+	defer cancel()
+
+	// Without a version to resume from the watch starts with the current
+	// policies, listed after subscribing so that no change falls between.
+	var initial []*store.PolicyRecord
+	if since == 0 {
+		if initial, err = h.store.List(c.Request.Context(), tenantID, filter); err != nil {
+			h.log.Error("list policies", zap.Error(err))
+			fail(c, http.StatusInternalServerError, "failed to list policies")
+			return
+		}
+	}
+
+	send := func(typ string, version int64, p *store.PolicyRecord) bool {
+		if p.TenantID != tenantID || !acl.canRead(p.Namespace) ||
+			(filter.Kind != "" && p.Kind != filter.Kind) ||
+			(filter.Namespace != "" && p.Namespace != filter.Namespace) {
+			return true
+		}
+		if filter.SkipBodies {
+			cp := *p
+			cp.Spec, cp.RawYAML = json.RawMessage("null"), ""
+			p = &cp
+		}
+		item, err := project(p, fields)
+		if err != nil {
+			h.log.Error("encode policy event", zap.Error(err))
+			return false
+		}
+		c.SSEvent(typ, gin.H{"type": typ, "resourceVersion": version, "object": item})
+		return true
+	}
+
+	// The stream outlives the server's write timeout.
+	if err := http.NewResponseController(c.Writer).SetWriteDeadline(time.Time{}); err != nil {
+		h.log.Warn("clear write deadline for policy watch", zap.Error(err))
+	}
+	c.Header("Cache-Control", "no-cache")
+	c.Header("X-Accel-Buffering", "no")
+	c.Header("X-Resource-Version", strconv.FormatInt(rv, 10))
+	for _, p := range initial {
+		if !send(store.WatchAdded, rv, p) {
+			return
+		}
+	}
+	c.SSEvent("bookmark", gin.H{"type": "bookmark", "resourceVersion": rv})
+
+	ticker := time.NewTicker(watchBookmark)
+	defer ticker.Stop()
+	ctx := c.Request.Context()
+	c.Stream(func(io.Writer) bool {
+		select {
+		case <-ctx.Done():
+			return false
+		case <-ticker.C:
+			c.SSEvent("bookmark", gin.H{"type": "bookmark", "resourceVersion": rv})
+			return true
+		case ev, ok := <-events:
+			if !ok {
+				return false
+			}
+			rv = ev.ResourceVersion
+			return send(ev.Type, ev.ResourceVersion, ev.Policy)
+		}
+	})
+}
+
+// Get GET /api/v1/policies/:id?fields=
 func (h *PolicyHandler) Get(c *gin.Context) {
 	tenantID := mustTenantID(c)
+	fields, ok := parseFields(c, store.PolicyRecord{})
+	if !ok {
+		return
+	}
 	id, err := uuid.Parse(c.Param("id"))
 	if err != nil {
-		c.JSON(http.StatusBadRequest, errResp("invalid id"))
+		fail(c, http.StatusBadRequest, "invalid id")
 		return
 	}
 
 	p, err := h.store.Get(c.Request.Context(), tenantID, id)
 	if err != nil {
 		if strings.Contains(err.Error(), "not found") {
-			c.JSON(http.StatusNotFound, errResp("policy not found"))
+			fail(c, http.StatusNotFound, "policy not found")
 			return
 		}
-		c.JSON(http.StatusInternalServerError, errResp(err.Error()))
+		fail(c, http.StatusInternalServerError, err.Error())
+		return
+	}
+	if !h.authorize(c, p.Namespace, false) {
 		return
 	}
-	c.JSON(http.StatusOK, p)
+	body, err := project(p, fields)
+	if err != nil {
+		fail(c, http.StatusInternalServerError, "encode policy: "+err.Error())
+		return
+	}
+	writeCached(c, "", latest(time.Time{}, p), body)
 }
 
 // Create POST /api/v1/policies
@@ -88,12 +280,22 @@ func (h *PolicyHandler) Create(c *gin.Context) {
 
 	var req CreatePolicyRequest
 	if err := c.ShouldBindJSON(&req); err != nil {
-		c.JSON(http.StatusBadRequest, errResp(err.Error()))
+		fail(c, http.StatusBadRequest, err.Error())
 		return
 	}
 
 	if req.Namespace == "" {
-		req.Namespace = "default"
+		req.Namespace = store.DefaultNamespace
+	}
+	if !h.authorize(c, req.Namespace, true) {
+		return
+	}
+	if !unredacted(c, req.Spec, req.RawYAML) {
+		return
+	}
+	prov, ok := h.verify(c, req.RawYAML, req.Signature)
+	if !ok {
+		return
 	}
 
 	uid, _ := userID.(uuid.UUID)
@@ -106,11 +308,23 @@ func (h *PolicyHandler) Create(c *gin.Context) {
 		RawYAML:   req.RawYAML,
 		Enabled:   req.Enabled,
 		CreatedBy: &uid,
+
+		Signature:  req.Signature,
+		Provenance: prov,
+	}
+	if !h.checkQuota(c, tenantID, record, true) {
+		return
+	}
+	if !h.admit(c, admission.OpCreate, req.Namespace, req.Name, req.Kind, req.Spec, req.RawYAML) {
+		return
+	}
+	if !h.parsable(c, record) || !h.inNamespace(c, record) || !h.checkRawRules(c, record) {
+		return
 	}
 
 	if err := h.store.Create(c.Request.Context(), record); err != nil {
 		h.log.Error("create policy", zap.Error(err))
-		c.JSON(http.StatusInternalServerError, errResp("failed to create policy"))
+		fail(c, http.StatusInternalServerError, "failed to create policy")
 		return
 	}
 	c.JSON(http.StatusCreated, record)
@@ -121,21 +335,38 @@ func (h *PolicyHandler) Update(c *gin.Context) {
 	tenantID := mustTenantID(c)
 	id, err := uuid.Parse(c.Param("id"))
 	if err != nil {
-		c.JSON(http.StatusBadRequest, errResp("invalid id"))
+		fail(c, http.StatusBadRequest, "invalid id")
 		return
 	}
 
 	var req UpdatePolicyRequest
 	if err := c.ShouldBindJSON(&req); err != nil {
-		c.JSON(http.StatusBadRequest, errResp(err.Error()))
+		fail(c, http.StatusBadRequest, err.Error())
 		return
 	}
 
 	existing, err := h.store.Get(c.Request.Context(), tenantID, id)
 	if err != nil {
-		c.JSON(http.StatusNotFound, errResp("policy not found"))
+		fail(c, http.StatusNotFound, "policy not found")
+		return
+	}
+	if !h.authorize(c, existing.Namespace, true) {
 		return
 	}
+	if !unredacted(c, req.Spec, req.RawYAML) {
+		return
+	}
+	if req.RawYAML != "" || req.Signature != "" {
+		raw := existing.RawYAML
+		if req.RawYAML != "" {
+			raw = req.RawYAML
+		}
+		prov, ok := h.verify(c, raw, req.Signature)
+		if !ok {
+			return
+		}
+		existing.Signature, existing.Provenance = req.Signature, prov
+	}
 
 	if req.Spec != nil {
 		existing.Spec = req.Spec
@@ -146,27 +377,60 @@ func (h *PolicyHandler) Update(c *gin.Context) {
 	if req.Enabled != nil {
 		existing.Enabled = *req.Enabled
 	}
+	if (req.Spec != nil || req.RawYAML != "") && !h.checkQuota(c, tenantID, existing, false) {
+		return
+	}
+	if !h.parsable(c, existing) || !h.inNamespace(c, existing) || !h.checkRawRules(c, existing) {
+		return
+	}
+	if !h.admit(c, admission.OpUpdate, existing.Namespace, existing.Name, existing.Kind, existing.Spec, existing.RawYAML) {
+		return
+	}
 
 	if err := h.store.Update(c.Request.Context(), existing); err != nil {
-		c.JSON(http.StatusInternalServerError, errResp("failed to update policy"))
+		fail(c, http.StatusInternalServerError, "failed to update policy")
 		return
 	}
 	c.JSON(http.StatusOK, existing)
 }
 
 // Delete DELETE /api/v1/policies/:id
+// Deleting an enabled policy first re-applies the tenant's remaining enabled
+// policies so its rules leave the dataplane; if that fails, nothing is
+// deleted.
 func (h *PolicyHandler) Delete(c *gin.Context) {
 	tenantID := mustTenantID(c)
 	id, err := uuid.Parse(c.Param("id"))
 	if err != nil {
-		c.JSON(http.StatusBadRequest, errResp("invalid id"))
+		fail(c, http.StatusBadRequest, "invalid id")
 		return
 	}
 
+	existing, err := h.store.Get(c.Request.Context(), tenantID, id)
+	if err != nil {
+		fail(c, http.StatusNotFound, "policy not found")
+		return
+	}
+	if !h.authorize(c, existing.Namespace, true) {
+		return
+	}
+
+	var applied []uuid.UUID
+	if existing.Enabled {
+		var ok bool
+		applied, ok = h.applyTenant(c, tenantID, func(p *store.PolicyRecord) bool {
+			return p.Enabled && p.ID != id
+		})
+		if !ok {
+			return
+		}
+	}
+
 	if err := h.store.Delete(c.Request.Context(), tenantID, id); err != nil {
-		c.JSON(http.StatusNotFound, errResp("policy not found"))
+		fail(c, http.StatusNotFound, "policy not found")
 		return
 	}
+	h.markApplied(c, tenantID, applied)
 	c.Status(http.StatusNoContent)
 }
 
@@ -175,25 +439,35 @@ func (h *PolicyHandler) Apply(c *gin.Context) {
 	tenantID := mustTenantID(c)
 	id, err := uuid.Parse(c.Param("id"))
 	if err != nil {
-		c.JSON(http.StatusBadRequest, errResp("invalid id"))
+		fail(c, http.StatusBadRequest, "invalid id")
 		return
 	}
 
 	record, err := h.store.Get(c.Request.Context(), tenantID, id)
 	if err != nil {
-		c.JSON(http.StatusNotFound, errResp("policy not found"))
+		fail(c, http.StatusNotFound, "policy not found")
+		return
+	}
+	if !h.authorize(c, record.Namespace, true) {
+		return
+	}
+
+	if !h.checkSigned(c, record) {
+		return
+	}
+	if !h.admit(c, admission.OpApply, record.Namespace, record.Name, record.Kind, record.Spec, record.RawYAML) {
 		return
 	}
 
 	manifests, err := h.parseRecordToManifests(record)
 	if err != nil {
-		c.JSON(http.StatusBadRequest, errResp("parse policy: "+err.Error()))
+		fail(c, http.StatusBadRequest, "parse policy: "+err.Error())
 		return
 	}
 
-	if err := h.firewallSvc.ApplyManifests(context.Background(), manifests); err != nil {
+	if err := h.firewallSvc.ApplyManifests(metrics.WithTenant(c.Request.Context(), tenantID.String()), manifests); err != nil {
 		h.log.Error("apply policy", zap.Error(err), zap.String("policy_id", id.String()))
-		c.JSON(http.StatusInternalServerError, errResp("apply failed: "+err.Error()))
+		failErr(c, http.StatusInternalServerError, "apply failed", err)
 		return
 	}
 
@@ -204,73 +478,614 @@ func (h *PolicyHandler) Apply(c *gin.Context) {
 	c.JSON(http.StatusOK, gin.H{"status": "applied", "policyId": id})
 }
 
+// Enable POST /api/v1/policies/:id/enable
+func (h *PolicyHandler) Enable(c *gin.Context) { h.setEnabled(c, true) }
+
+// Disable POST /api/v1/policies/:id/disable
+func (h *PolicyHandler) Disable(c *gin.Context) { h.setEnabled(c, false) }
+
 // Diff GET /api/v1/policies/:id/diff
+//
+// Returns the text diff of the ruleset applying the policy would write
+// against the live one, and under "changes" the same at the IR level: the
+// rules, NAT rules, sets and other items added, removed or modified, with
+// the fields that changed.
 func (h *PolicyHandler) Diff(c *gin.Context) {
 	tenantID := mustTenantID(c)
 	id, err := uuid.Parse(c.Param("id"))
 	if err != nil {
-		c.JSON(http.StatusBadRequest, errResp("invalid id"))
+		fail(c, http.StatusBadRequest, "invalid id")
+		return
+	}
+
+	record, err := h.store.Get(c.Request.Context(), tenantID, id)
+	if err != nil {
+		fail(c, http.StatusNotFound, "policy not found")
+		return
+	}
+	if !h.authorize(c, record.Namespace, false) {
+		return
+	}
+
+	manifests, err := h.parseRecordToManifests(record)
+	if err != nil {
+		fail(c, http.StatusBadRequest, "parse policy: "+err.Error())
+		return
+	}
+
+	diff, irDiff, warnings, err := h.firewallSvc.DiffManifests(manifests)
+	if err != nil {
+		failErr(c, http.StatusInternalServerError, "diff failed", err)
+		return
+	}
+	if warnings == nil {
+		warnings = []policy.Warning{}
+	}
+
+	c.JSON(http.StatusOK, gin.H{"diff": diff, "changes": redactIRDiff(irDiff), "warnings": warnings})
+}
+
+// redactIRDiff encodes d with the secrets it carries, such as VPN private
+// keys, redacted: fields named as secrets, and sensitive keys anywhere in
+// the items before and after.
+func redactIRDiff(d *policy.IRDiff) json.RawMessage {
+	for i := range d.Changes {
+		for j, f := range d.Changes[i].Fields {
+			if redact.Key(f.Field) {
+				d.Changes[i].Fields[j].Before = redact.Placeholder
+				d.Changes[i].Fields[j].After = redact.Placeholder
+			}
+		}
+	}
+	raw, err := json.Marshal(d)
+	if err != nil {
+		return json.RawMessage(`{"changes":[]}`)
+	}
+	return redact.JSON(raw)
+}
+
+// Validate POST /api/v1/policies/validate
+//
+// Compiles the posted manifests without storing or applying them. Hard
+// errors fail with 422 and one detail per problem; a policy that compiles
+// is returned with the analysis warnings, such as rules shadowed by an
+// earlier rule or contradicting one.
+func (h *PolicyHandler) Validate(c *gin.Context) {
+	var req ValidatePolicyRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		fail(c, http.StatusBadRequest, err.Error())
+		return
+	}
+	manifests, err := h.parser.ParseReader(strings.NewReader(req.RawYAML))
+	if err != nil {
+		fail(c, http.StatusBadRequest, "parse policy: "+err.Error())
+		return
+	}
+	ir, err := h.firewallSvc.Compile(manifests)
+	if err != nil {
+		failErr(c, http.StatusUnprocessableEntity, "validation failed", err)
+		return
+	}
+	warnings := ir.Warnings
+	if warnings == nil {
+		warnings = []policy.Warning{}
+	}
+	c.JSON(http.StatusOK, gin.H{"valid": true, "warnings": warnings, "count": len(warnings)})
+}
+
+// ValidateSchema POST /api/v1/policies/validate-schema
+//
+// Checks the posted manifests, or the posted kind and spec, against the
+// JSON Schema of their kind (see Schema) without compiling them. Values the
+// schema does not allow fail with 422 and one detail per value, giving its
+// line, column and path.
+func (h *PolicyHandler) ValidateSchema(c *gin.Context) {
+	var req ValidateSchemaRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		fail(c, http.StatusBadRequest, err.Error())
+		return
+	}
+	var (
+		errs []*policy.SchemaError
+		err  error
+	)
+	switch {
+	case req.RawYAML != "":
+		errs, err = policy.ValidateManifestSchema([]byte(req.RawYAML))
+	case req.Kind != "" && len(req.Spec) > 0:
+		errs, err = policy.ValidateSchema(req.Kind, req.Spec)
+	default:
+		fail(c, http.StatusBadRequest, "rawYaml, or kind and spec, are required")
+		return
+	}
+	if err != nil {
+		fail(c, http.StatusBadRequest, err.Error())
+		return
+	}
+	if len(errs) > 0 {
+		details := make([]string, len(errs))
+		for i, e := range errs {
+			details[i] = e.Error()
+		}
+		Abort(c, http.StatusUnprocessableEntity, CodeValidationFailed, "schema validation failed", details...)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"valid": true})
+}
+
+// Schema GET /api/v1/schemas/:kind
+//
+// Returns the JSON Schema of manifests of kind, generated from its spec
+// type, for editors and CI pipelines to check manifests with before they
+// are submitted.
+func (h *PolicyHandler) Schema(c *gin.Context) {
+	s, ok := policy.Schema(c.Param("kind"))
+	if !ok {
+		fail(c, http.StatusNotFound, "unknown kind "+c.Param("kind"), policy.SchemaKinds()...)
+		return
+	}
+	writeCached(c, "", time.Time{}, s)
+}
+
+// Preview POST /api/v1/policies/preview
+//
+// Renders the PolicyValues among the posted manifests with their
+// PolicyTemplate and returns the documents as they would be stored and
+// compiled, without validating, storing or applying them.
+func (h *PolicyHandler) Preview(c *gin.Context) {
+	var req PreviewPolicyRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		fail(c, http.StatusBadRequest, err.Error())
+		return
+	}
+	rendered, err := h.parser.Preview(strings.NewReader(req.RawYAML))
+	if err != nil {
+		fail(c, http.StatusUnprocessableEntity, "render policy: "+err.Error())
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"rawYaml": redact.String(rendered)})
+}
+
+// Render GET /api/v1/policies/:id/render?backend=nftables|haproxy|wireguard|suricata
+//
+// Returns the configuration the backend adapter would produce for this
+// policy alone, for inspection or for loading by hand on a host AegisX does
+// not manage. Secrets are redacted as in every other response.
+func (h *PolicyHandler) Render(c *gin.Context) {
+	tenantID := mustTenantID(c)
+	id, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		fail(c, http.StatusBadRequest, "invalid id")
 		return
 	}
+	backend := c.DefaultQuery("backend", "nftables")
 
 	record, err := h.store.Get(c.Request.Context(), tenantID, id)
 	if err != nil {
-		c.JSON(http.StatusNotFound, errResp("policy not found"))
+		fail(c, http.StatusNotFound, "policy not found")
+		return
+	}
+	if !h.authorize(c, record.Namespace, false) {
 		return
 	}
 
 	manifests, err := h.parseRecordToManifests(record)
 	if err != nil {
-		c.JSON(http.StatusBadRequest, errResp("parse policy: "+err.Error()))
+		fail(c, http.StatusBadRequest, "parse policy: "+err.Error())
+		return
+	}
+	ir, err := h.firewallSvc.Compile(manifests)
+	if err != nil {
+		failErr(c, http.StatusInternalServerError, "compile failed", err)
 		return
 	}
 
-	diff, err := h.firewallSvc.DiffManifests(manifests)
+	var config string
+	switch backend {
+	case "nftables":
+		config, err = h.firewallSvc.Render(ir)
+	case "haproxy":
+		if h.lbAdapter == nil {
+			Abort(c, http.StatusServiceUnavailable, CodeFeatureDisabled, "load balancer is disabled")
+			return
+		}
+		config, err = h.lbAdapter.Generate(ir)
+	case "wireguard":
+		var sb strings.Builder
+		for i := range ir.VPNConfigs {
+			conf, genErr := h.vpnMgr.Render(&ir.VPNConfigs[i])
+			if genErr != nil {
+				err = fmt.Errorf("%s: %w", ir.VPNConfigs[i].Interface, genErr)
+				break
+			}
+			fmt.Fprintf(&sb, "# ==> %s.conf <==\n%s", ir.VPNConfigs[i].Interface, conf)
+		}
+		config = sb.String()
+	case "suricata":
+		if h.idsAdapter == nil {
+			Abort(c, http.StatusServiceUnavailable, CodeFeatureDisabled, "IDS is disabled")
+			return
+		}
+		config, err = h.idsAdapter.Render(ir)
+	default:
+		fail(c, http.StatusBadRequest, "backend must be one of nftables, haproxy, wireguard, suricata")
+		return
+	}
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, errResp(err.Error()))
+		failErr(c, http.StatusInternalServerError, "render failed", err)
 		return
 	}
 
-	c.JSON(http.StatusOK, gin.H{"diff": diff})
+	c.JSON(http.StatusOK, gin.H{"backend": backend, "config": redact.String(config)})
 }
 
 // ListRevisions GET /api/v1/policies/:id/revisions
 func (h *PolicyHandler) ListRevisions(c *gin.Context) {
+	tenantID := mustTenantID(c)
 	id, err := uuid.Parse(c.Param("id"))
 	if err != nil {
-		c.JSON(http.StatusBadRequest, errResp("invalid id"))
+		fail(c, http.StatusBadRequest, "invalid id")
 		return
 	}
-	revs, err := h.store.ListRevisions(c.Request.Context(), id)
+	p, err := h.store.Get(c.Request.Context(), tenantID, id)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, errResp(err.Error()))
+		fail(c, http.StatusNotFound, "policy not found")
+		return
+	}
+	if !h.authorize(c, p.Namespace, false) {
 		return
 	}
-	c.JSON(http.StatusOK, gin.H{"items": revs})
+	revs, err := h.store.ListRevisions(c.Request.Context(), tenantID, id)
+	if err != nil {
+		fail(c, http.StatusInternalServerError, err.Error())
+		return
+	}
+	writeCached(c, "", time.Time{}, gin.H{"items": revs})
 }
 
 // ─── Helpers ──────────────────────────────────────────────────────────────
 
+// authorize checks the caller's namespace access and writes a 403 on denial.
+func (h *PolicyHandler) authorize(c *gin.Context, namespace string, write bool) bool {
+	acl, err := loadNamespaceACL(c.Request.Context(), h.namespaces, c)
+	if err != nil {
+		h.log.Error("load namespace access", zap.Error(err))
+		fail(c, http.StatusInternalServerError, "failed to resolve namespace access")
+		return false
+	}
+	allowed := acl.canRead(namespace)
+	if write {
+		allowed = acl.canWrite(namespace)
+	}
+	if !allowed {
+		fail(c, http.StatusForbidden, "no access to namespace "+namespace)
+		return false
+	}
+	return true
+}
+
+// checkQuota verifies the namespace of record exists (unless it is
+// "default") and that the record stays within its policy and rule quotas.
+// Rules are counted on the record's manifests, whether it was written as
+// rawYaml or as a spec. It writes the error response itself and returns
+// false when the request must stop.
+func (h *PolicyHandler) checkQuota(c *gin.Context, tenantID uuid.UUID, record *store.PolicyRecord, creating bool) bool {
+	namespace := record.Namespace
+	ns, err := h.namespaces.Get(c.Request.Context(), tenantID, namespace)
+	if err != nil {
+		if !strings.Contains(err.Error(), "not found") {
+			fail(c, http.StatusInternalServerError, err.Error())
+			return false
+		}
+		if namespace == store.DefaultNamespace {
+			return true
+		}
+		fail(c, http.StatusBadRequest, "namespace "+namespace+" does not exist")
+		return false
+	}
+
+	if creating && ns.MaxPolicies > 0 {
+		count, err := h.namespaces.CountPolicies(c.Request.Context(), tenantID, namespace)
+		if err != nil {
+			fail(c, http.StatusInternalServerError, err.Error())
+			return false
+		}
+		if count >= ns.MaxPolicies {
+			Abort(c, http.StatusConflict, CodeQuotaExceeded, fmt.Sprintf(
+				"namespace %s policy quota exceeded (%d/%d)", namespace, count, ns.MaxPolicies))
+			return false
+		}
+	}
+
+	if ns.MaxRules > 0 {
+		manifests, err := h.parseRecordToManifests(record)
+		if err != nil {
+			fail(c, http.StatusBadRequest, "parse policy: "+err.Error())
+			return false
+		}
+		rules := 0
+		for _, m := range manifests {
+			if m.FirewallSpec != nil {
+				rules += len(m.FirewallSpec.Rules)
+				for _, ch := range m.FirewallSpec.Chains {
+					rules += len(ch.Rules)
+				}
+			}
+		}
+		if rules > ns.MaxRules {
+			Abort(c, http.StatusConflict, CodeQuotaExceeded, fmt.Sprintf(
+				"namespace %s rule quota exceeded (%d/%d)", namespace, rules, ns.MaxRules))
+			return false
+		}
+	}
+	return true
+}
+
+// admit runs the admission rules on a policy change and writes a 403 with
+// the violations when they deny it.
+func (h *PolicyHandler) admit(c *gin.Context, op, namespace, name, kind string, spec json.RawMessage, rawYAML string) bool {
+	role, _ := c.Get("role")
+	roleName, _ := role.(string)
+	denies, err := h.admission.Review(c.Request.Context(), admission.Request{
+		Operation: op,
+		User:      callerID(c).String(),
+		Role:      roleName,
+		Tenant:    mustTenantID(c).String(),
+		Namespace: namespace,
+		Name:      name,
+		Kind:      kind,
+		Spec:      spec,
+		RawYAML:   rawYAML,
+	})
+	if err != nil {
+		h.log.Error("admission review", zap.Error(err))
+		fail(c, http.StatusInternalServerError, "admission review failed")
+		return false
+	}
+	if len(denies) > 0 {
+		Abort(c, http.StatusForbidden, CodeAdmissionDenied, "denied by admission policy", denies...)
+		return false
+	}
+	return true
+}
+
+// verify checks signature over a manifest being written and returns the
+// provenance to store with it: nil when it is unsigned or no keys are
+// configured. It writes a 422 for a signature that does not verify, or a
+// missing one while signatures are enforced.
+func (h *PolicyHandler) verify(c *gin.Context, rawYAML, signature string) (*store.Provenance, bool) {
+	keyID, err := h.signing.Verify(rawYAML, signature)
+	if err != nil {
+		Abort(c, http.StatusUnprocessableEntity, CodeSignatureInvalid, err.Error())
+		return nil, false
+	}
+	if keyID == "" {
+		return nil, true
+	}
+	return &store.Provenance{KeyID: keyID, VerifiedAt: time.Now()}, true
+}
+
+// checkSigned refuses to apply, while signatures are enforced, a policy
+// whose manifest does not verify against its stored signature: one written
+// before enforcement, or changed in the database behind the API's back.
+func (h *PolicyHandler) checkSigned(c *gin.Context, p *store.PolicyRecord) bool {
+	if !h.signing.Enforced() {
+		return true
+	}
+	if _, err := h.signing.Verify(p.RawYAML, p.Signature); err != nil {
+		Abort(c, http.StatusUnprocessableEntity, CodeSignatureInvalid,
+			fmt.Sprintf("policy %s/%s: %v", p.Namespace, p.Name, err))
+		return false
+	}
+	return true
+}
+
+// unredacted rejects a body copied from an API response, whose secrets
+// were replaced by redact.Placeholder; saving it would lose the real keys.
+func unredacted(c *gin.Context, spec json.RawMessage, rawYAML string) bool {
+	if redact.Contains(string(spec)) || redact.Contains(rawYAML) {
+		fail(c, http.StatusUnprocessableEntity, "policy contains redacted values; supply the secrets again")
+		return false
+	}
+	return true
+}
+
+// parseRecordToManifests returns the manifests of a stored policy: those
+// of its manifest, in YAML or JSON, or else the one its JSON spec makes.
 func (h *PolicyHandler) parseRecordToManifests(record *store.PolicyRecord) ([]*policy.Manifest, error) {
 	if record.RawYAML != "" {
-		return h.parser.ParseReader(strings.NewReader(record.RawYAML))
+		return h.parser.Parse([]byte(record.RawYAML))
+	}
+	if len(record.Spec) == 0 || string(record.Spec) == "null" {
+		return nil, nil
+	}
+	m, err := h.parser.ParseSpecJSON(record.Kind, policy.Metadata{Name: record.Name, Namespace: record.Namespace}, record.Spec)
+	if err != nil {
+		return nil, err
 	}
-	// Reconstruct minimal manifest from stored JSON spec.
-	return nil, nil // TODO: JSON-based reconstruction
+	return []*policy.Manifest{m}, nil
 }
 
-func mustTenantID(c *gin.Context) uuid.UUID {
-	val, _ := c.Get("tenant_id")
-	if id, ok := val.(uuid.UUID); ok {
-		return id
+// parsable writes a 400 when a record without a manifest has a spec that
+// does not decode, so a policy that could never be applied is not stored.
+func (h *PolicyHandler) parsable(c *gin.Context, record *store.PolicyRecord) bool {
+	if record.RawYAML != "" {
+		return true
+	}
+	if _, err := h.parseRecordToManifests(record); err != nil {
+		fail(c, http.StatusBadRequest, "parse policy: "+err.Error())
+		return false
+	}
+	return true
+}
+
+// inNamespace writes a 400 when a manifest of the record declares a
+// namespace other than the record's. Access is checked against the
+// record's namespace, but groups, rule comments and attribution follow the
+// manifest's, so a mismatch would let a writer of one namespace declare
+// objects in another.
+func (h *PolicyHandler) inNamespace(c *gin.Context, record *store.PolicyRecord) bool {
+	manifests, err := h.parseRecordToManifests(record)
+	if err != nil {
+		return true // nothing of it applies; parsable and apply report why
+	}
+	for _, m := range manifests {
+		if m.Metadata.Namespace != record.Namespace {
+			fail(c, http.StatusBadRequest, fmt.Sprintf("%s %s declares namespace %q, but the policy is in namespace %q",
+				m.Kind, m.Metadata.Name, m.Metadata.Namespace, record.Namespace))
+			return false
+		}
+	}
+	return true
+}
+
+// checkRawRules writes a 403 when a caller without PoliciesRawRules writes
+// a policy with rawRules, and a 400 when nft rejects its snippets.
+func (h *PolicyHandler) checkRawRules(c *gin.Context, record *store.PolicyRecord) bool {
+	manifests, err := h.parseRecordToManifests(record)
+	if err != nil {
+		return true // nothing of it applies; parsable and apply report why
 	}
-	// Fall back to header (for multi-tenant proxied requests)
-	if h := c.GetHeader("X-Tenant-ID"); h != "" {
-		if id, err := uuid.Parse(h); err == nil {
-			return id
+	raw := false
+	for _, m := range manifests {
+		if m.FirewallSpec != nil && len(m.FirewallSpec.RawRules) > 0 {
+			raw = true
 		}
 	}
-	return uuid.Nil
+	if !raw {
+		return true
+	}
+	role, _ := c.Get("role")
+	roleName, _ := role.(string)
+	if !authz.Allows(roleName, authz.PoliciesRawRules) {
+		fail(c, http.StatusForbidden, fmt.Sprintf("rawRules require the %s role", authz.Role(authz.PoliciesRawRules)))
+		return false
+	}
+	if err := h.firewallSvc.CheckRawRules(manifests); err != nil {
+		Abort(c, http.StatusBadRequest, CodeValidationFailed, "rawRules rejected", err.Error())
+		return false
+	}
+	return true
 }
 
-func errResp(msg string) gin.H { return gin.H{"error": msg} }
+// setEnabled recompiles the tenant's enabled policies with the flag of id
+// flipped and applies the result; the flag is only persisted once the
+// dataplane has accepted it.
+func (h *PolicyHandler) setEnabled(c *gin.Context, enabled bool) {
+	tenantID := mustTenantID(c)
+	id, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		fail(c, http.StatusBadRequest, "invalid id")
+		return
+	}
+
+	record, err := h.store.Get(c.Request.Context(), tenantID, id)
+	if err != nil {
+		fail(c, http.StatusNotFound, "policy not found")
+		return
+	}
+	if !h.authorize(c, record.Namespace, true) {
+		return
+	}
+	if record.Enabled == enabled {
+		c.JSON(http.StatusOK, record)
+		return
+	}
+	if enabled && !h.admit(c, admission.OpApply, record.Namespace, record.Name, record.Kind, record.Spec, record.RawYAML) {
+		return
+	}
+
+	applied, ok := h.applyTenant(c, tenantID, func(p *store.PolicyRecord) bool {
+		if p.ID == id {
+			return enabled
+		}
+		return p.Enabled
+	})
+	if !ok {
+		return
+	}
+
+	actor := callerID(c)
+	updated, err := h.store.SetEnabled(c.Request.Context(), tenantID, id, enabled, &actor)
+	if err != nil {
+		h.log.Error("set policy enabled", zap.Error(err), zap.String("policy_id", id.String()))
+		fail(c, http.StatusInternalServerError, "policy applied but the flag was not saved: "+err.Error())
+		return
+	}
+	h.markApplied(c, tenantID, applied)
+	h.log.Info("policy toggled",
+		zap.String("policy_id", id.String()),
+		zap.Bool("enabled", enabled),
+		zap.String("actor", actor.String()))
+	c.JSON(http.StatusOK, updated)
+}
+
+// applyTenant compiles every policy of the tenant for which include returns
+// true into one IR and applies it, replacing the dataplane state. It returns
+// the IDs of the included policies, or writes an error and returns false.
+func (h *PolicyHandler) applyTenant(c *gin.Context, tenantID uuid.UUID, include func(*store.PolicyRecord) bool) ([]uuid.UUID, bool) {
+	all, err := h.store.List(c.Request.Context(), tenantID, store.PolicyFilter{})
+	if err != nil {
+		h.log.Error("list policies", zap.Error(err))
+		fail(c, http.StatusInternalServerError, "failed to list policies")
+		return nil, false
+	}
+
+	var (
+		manifests []*policy.Manifest
+		ids       []uuid.UUID
+	)
+	for _, p := range all {
+		if !include(p) {
+			continue
+		}
+		if !h.checkSigned(c, p) {
+			return nil, false
+		}
+		ms, err := h.parseRecordToManifests(p)
+		if err != nil {
+			fail(c, http.StatusBadRequest, fmt.Sprintf("parse policy %s/%s: %v", p.Namespace, p.Name, err))
+			return nil, false
+		}
+		manifests = append(manifests, ms...)
+		ids = append(ids, p.ID)
+	}
+
+	if err := h.firewallSvc.ApplyManifests(metrics.WithTenant(c.Request.Context(), tenantID.String()), manifests); err != nil {
+		h.log.Error("apply tenant policies", zap.Error(err), zap.String("tenant_id", tenantID.String()))
+		failErr(c, http.StatusInternalServerError, "apply failed", err)
+		return nil, false
+	}
+	return ids, true
+}
+
+func (h *PolicyHandler) markApplied(c *gin.Context, tenantID uuid.UUID, ids []uuid.UUID) {
+	for _, id := range ids {
+		if err := h.store.MarkApplied(c.Request.Context(), tenantID, id); err != nil {
+			h.log.Warn("mark applied failed", zap.Error(err))
+		}
+	}
+}
+
+// latest returns the later of t and the last change of p.
+func latest(t time.Time, p *store.PolicyRecord) time.Time {
+	if p.UpdatedAt.After(t) {
+		t = p.UpdatedAt
+	}
+	if p.AppliedAt != nil && p.AppliedAt.After(t) {
+		t = *p.AppliedAt
+	}
+	return t
+}
+
+// mustTenantID returns the tenant of the request's token. The auth
+// middleware rejects tokens without one, so reaching the panic is a routing
+// bug: a tenant-scoped handler mounted outside the authenticated groups.
+func mustTenantID(c *gin.Context) uuid.UUID {
+	val, _ := c.Get("tenant_id")
+	id, ok := val.(uuid.UUID)
+	if !ok || id == uuid.Nil {
+		panic("handlers: request has no tenant")
+	}
+	return id
+}
@@ -0,0 +1,215 @@
+package handlers
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"go.uber.org/zap"
+
+	"github.com/aegisx/aegisx/internal/config"
+	"github.com/aegisx/aegisx/internal/store"
+	"github.com/aegisx/aegisx/internal/vpn"
+)
+
+// PortalHandler serves the VPN self-service portal. Every request is bound
+// to the single peer carried in the caller's portal token.
+type PortalHandler struct {
+	store    *store.VPNStore
+	policies *store.PolicyStore
+	mgr      *vpn.Manager
+	cfg      config.VPNConfig
+	log      *zap.Logger
+}
+
+func NewPortalHandler(store *store.VPNStore, policies *store.PolicyStore, mgr *vpn.Manager, cfg config.VPNConfig, log *zap.Logger) *PortalHandler {
+	return &PortalHandler{store: store, policies: policies, mgr: mgr, cfg: cfg, log: log}
+}
+
+// Peer GET /api/v1/portal/peer
+func (h *PortalHandler) Peer(c *gin.Context) {
+	peer, ok := h.loadPeer(c)
+	if !ok {
+		return
+	}
+	c.JSON(http.StatusOK, peer)
+}
+
+// Config GET /api/v1/portal/peer/config
+// Returns the wg-quick config with a private-key placeholder; the server
+// never retains client private keys.
+func (h *PortalHandler) Config(c *gin.Context) {
+	peer, ok := h.loadPeer(c)
+	if !ok {
+		return
+	}
+
+	conf, err := renderPeerConfig(h.mgr, h.cfg, peer, "", "")
+	if err != nil {
+		h.log.Error("render client config", zap.Error(err))
+		fail(c, http.StatusInternalServerError, "failed to render config: "+err.Error())
+		return
+	}
+	c.Header("Content-Disposition", `attachment; filename="`+h.cfg.Interface+`.conf"`)
+	c.Data(http.StatusOK, "text/plain; charset=utf-8", []byte(conf))
+}
+
+// Status GET /api/v1/portal/peer/status
+func (h *PortalHandler) Status(c *gin.Context) {
+	peer, ok := h.loadPeer(c)
+	if !ok {
+		return
+	}
+
+	resp := gin.H{"peerId": peer.ID, "connected": false}
+	status, err := h.mgr.Status()
+	if err != nil {
+		h.log.Warn("vpn status unavailable", zap.Error(err))
+		resp["message"] = "interface status unavailable"
+		c.JSON(http.StatusOK, resp)
+		return
+	}
+	for _, p := range status.Peers {
+		if p.PublicKey != peer.PublicKey {
+			continue
+		}
+		resp["endpoint"] = p.Endpoint
+		resp["rxBytes"] = p.RxBytes
+		resp["txBytes"] = p.TxBytes
+		if !p.LastHandshakeTime.IsZero() {
+			resp["lastHandshake"] = p.LastHandshakeTime
+			resp["connected"] = time.Since(p.LastHandshakeTime) < 3*time.Minute
+		}
+		break
+	}
+	c.JSON(http.StatusOK, resp)
+}
+
+// RotateKey POST /api/v1/portal/peer/rotate
+// Generates a fresh keypair, swaps the public key on the live interface, in
+// the VPN policies that list the peer and in the store, and returns the new
+// config including the private key. This is the only time the private key
+// is ever transmitted. If the key cannot be stored, the live swap is undone.
+func (h *PortalHandler) RotateKey(c *gin.Context) {
+	peer, ok := h.loadPeer(c)
+	if !ok {
+		return
+	}
+
+	// The interface config is generated from the policies, so the new key
+	// must land there or the next VPN apply restores the old one.
+	holders, err := h.peerPolicies(c.Request.Context(), peer)
+	if err != nil {
+		h.log.Error("find peer policies", zap.Error(err), zap.String("peer_id", peer.ID.String()))
+		fail(c, http.StatusInternalServerError, "failed to rotate key")
+		return
+	}
+	for _, p := range holders {
+		if p.Signature != "" {
+			fail(c, http.StatusConflict, fmt.Sprintf("peer is defined in signed policy %s/%s; rotate its key there", p.Namespace, p.Name))
+			return
+		}
+	}
+
+	priv, pub, err := vpn.GenerateKeyPair()
+	if err != nil {
+		fail(c, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	if err := h.mgr.ReplacePeerKey(peer.PublicKey, pub, peer.AllowedIPs, peer.PresharedKey); err != nil {
+		h.log.Error("rotate peer key", zap.Error(err), zap.String("peer_id", peer.ID.String()))
+		fail(c, http.StatusInternalServerError, "failed to rotate key on interface")
+		return
+	}
+	if err := h.persistKey(c.Request.Context(), peer, holders, pub); err != nil {
+		h.log.Error("persist rotated key", zap.Error(err), zap.String("peer_id", peer.ID.String()))
+		if err := h.mgr.ReplacePeerKey(pub, peer.PublicKey, peer.AllowedIPs, peer.PresharedKey); err != nil {
+			h.log.Error("restore peer key on interface", zap.Error(err), zap.String("peer_id", peer.ID.String()))
+		}
+		fail(c, http.StatusInternalServerError, "failed to persist rotated key")
+		return
+	}
+	peer.PublicKey = pub
+
+	conf, err := renderPeerConfig(h.mgr, h.cfg, peer, priv, "")
+	if err != nil {
+		fail(c, http.StatusInternalServerError, "failed to render config: "+err.Error())
+		return
+	}
+
+	h.log.Info("peer key rotated via portal", zap.String("peer_id", peer.ID.String()))
+	c.JSON(http.StatusOK, gin.H{"publicKey": pub, "config": conf})
+}
+
+// ─── Helpers ──────────────────────────────────────────────────────────────
+
+// peerPolicies returns the tenant's policies that list peer by its public
+// key.
+func (h *PortalHandler) peerPolicies(ctx context.Context, peer *store.VPNPeerRecord) ([]*store.PolicyRecord, error) {
+	all, err := h.policies.List(ctx, peer.TenantID, store.PolicyFilter{})
+	if err != nil {
+		return nil, err
+	}
+	var out []*store.PolicyRecord
+	for _, p := range all {
+		if strings.Contains(p.RawYAML, peer.PublicKey) || bytes.Contains(p.Spec, []byte(peer.PublicKey)) {
+			out = append(out, p)
+		}
+	}
+	return out, nil
+}
+
+// persistKey replaces the peer's public key with pub in holders and in the
+// store. On failure it puts back the policies it had already changed.
+func (h *PortalHandler) persistKey(ctx context.Context, peer *store.VPNPeerRecord, holders []*store.PolicyRecord, pub string) error {
+	var done []*store.PolicyRecord
+	undo := func() {
+		for _, p := range done {
+			p.RawYAML = strings.ReplaceAll(p.RawYAML, pub, peer.PublicKey)
+			p.Spec = bytes.ReplaceAll(p.Spec, []byte(pub), []byte(peer.PublicKey))
+			if err := h.policies.Update(ctx, p); err != nil {
+				h.log.Error("restore peer key in policy", zap.Error(err), zap.String("policy_id", p.ID.String()))
+			}
+		}
+	}
+	for _, p := range holders {
+		p.RawYAML = strings.ReplaceAll(p.RawYAML, peer.PublicKey, pub)
+		p.Spec = bytes.ReplaceAll(p.Spec, []byte(peer.PublicKey), []byte(pub))
+		if err := h.policies.Update(ctx, p); err != nil {
+			undo()
+			return fmt.Errorf("update policy %s/%s: %w", p.Namespace, p.Name, err)
+		}
+		done = append(done, p)
+	}
+	if err := h.store.UpdatePeerKey(ctx, peer.TenantID, peer.ID, pub); err != nil {
+		undo()
+		return err
+	}
+	return nil
+}
+
+func (h *PortalHandler) loadPeer(c *gin.Context) (*store.VPNPeerRecord, bool) {
+	val, _ := c.Get("peer_id")
+	peerID, ok := val.(uuid.UUID)
+	if !ok {
+		fail(c, http.StatusForbidden, "token is not bound to a peer")
+		return nil, false
+	}
+
+	peer, err := h.store.GetPeer(c.Request.Context(), mustTenantID(c), peerID)
+	if err != nil {
+		fail(c, http.StatusNotFound, "peer not found")
+		return nil, false
+	}
+	if !peer.Active {
+		fail(c, http.StatusForbidden, "peer is disabled")
+		return nil, false
+	}
+	return peer, true
+}
@@ -0,0 +1,166 @@
+package handlers
+
+import (
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"go.uber.org/zap"
+
+	"github.com/aegisx/aegisx/internal/config"
+	"github.com/aegisx/aegisx/internal/store"
+)
+
+// Read-only scopes.
+const (
+	ReadOnlyGlobal = "global"
+	ReadOnlyTenant = "tenant"
+)
+
+// ReadOnlyHandler handles /api/v1/system/read-only.
+type ReadOnlyHandler struct {
+	store *store.ReadOnlyStore
+	cfg   *config.ServerConfig
+	log   *zap.Logger
+}
+
+func NewReadOnlyHandler(s *store.ReadOnlyStore, cfg *config.ServerConfig, log *zap.Logger) *ReadOnlyHandler {
+	return &ReadOnlyHandler{store: s, cfg: cfg, log: log}
+}
+
+// ReadOnlyState is whether one scope is read-only, and why.
+type ReadOnlyState struct {
+	ReadOnly  bool       `json:"readOnly"`
+	Source    string     `json:"source,omitempty"` // config or api
+	Reason    string     `json:"reason,omitempty"`
+	Since     *time.Time `json:"since,omitempty"`
+	EnabledBy *uuid.UUID `json:"enabledBy,omitempty"`
+}
+
+type readOnlyRequest struct {
+	Reason string `json:"reason" binding:"required"`
+	Scope  string `json:"scope"` // tenant (default) or global
+}
+
+// Status GET /api/v1/system/read-only
+// Returns the global state and that of the caller's tenant.
+func (h *ReadOnlyHandler) Status(c *gin.Context) {
+	ctx := c.Request.Context()
+	tenantID := mustTenantID(c)
+
+	var global ReadOnlyState
+	if h.cfg.ReadOnly {
+		global = ReadOnlyState{ReadOnly: true, Source: "config", Reason: ConfigReadOnlyReason(h.cfg)}
+	} else {
+		sw, err := h.store.Get(ctx, nil)
+		if err != nil {
+			h.log.Error("get read-only switch", zap.Error(err))
+			fail(c, http.StatusInternalServerError, "failed to read read-only state")
+			return
+		}
+		global = switchState(sw)
+	}
+	sw, err := h.store.Get(ctx, &tenantID)
+	if err != nil {
+		h.log.Error("get read-only switch", zap.Error(err))
+		fail(c, http.StatusInternalServerError, "failed to read read-only state")
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{ReadOnlyGlobal: global, ReadOnlyTenant: switchState(sw)})
+}
+
+// Enable PUT /api/v1/system/read-only
+// Makes the caller's tenant, or with scope=global the whole API,
+// read-only. Setting it again replaces the reason.
+func (h *ReadOnlyHandler) Enable(c *gin.Context) {
+	var req readOnlyRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		fail(c, http.StatusBadRequest, err.Error())
+		return
+	}
+	req.Reason = strings.TrimSpace(req.Reason)
+	if req.Reason == "" {
+		fail(c, http.StatusBadRequest, "reason is required")
+		return
+	}
+	tenantID, ok := h.scope(c, req.Scope)
+	if !ok {
+		return
+	}
+	by := callerID(c)
+	sw := &store.ReadOnlySwitch{TenantID: tenantID, Reason: req.Reason, EnabledBy: &by}
+	if err := h.store.Set(c.Request.Context(), sw); err != nil {
+		h.log.Error("set read-only switch", zap.Error(err))
+		fail(c, http.StatusInternalServerError, "failed to enable read-only mode")
+		return
+	}
+	h.log.Warn("read-only mode enabled", zap.String("scope", scopeName(tenantID)),
+		zap.String("reason", req.Reason), zap.String("by", by.String()))
+	c.JSON(http.StatusOK, switchState(sw))
+}
+
+// Disable DELETE /api/v1/system/read-only?scope=tenant
+// Lifts read-only mode set through the API. Read-only mode from the config
+// can only be lifted there.
+func (h *ReadOnlyHandler) Disable(c *gin.Context) {
+	scope := c.DefaultQuery("scope", ReadOnlyTenant)
+	tenantID, ok := h.scope(c, scope)
+	if !ok {
+		return
+	}
+	if err := h.store.Clear(c.Request.Context(), tenantID); err != nil {
+		if strings.Contains(err.Error(), "not found") {
+			if tenantID == nil && h.cfg.ReadOnly {
+				fail(c, http.StatusConflict, "read-only mode is set in the config (server.read_only)")
+				return
+			}
+			fail(c, http.StatusNotFound, "read-only mode is not enabled")
+			return
+		}
+		h.log.Error("clear read-only switch", zap.Error(err))
+		fail(c, http.StatusInternalServerError, "failed to disable read-only mode")
+		return
+	}
+	h.log.Warn("read-only mode disabled", zap.String("scope", scopeName(tenantID)),
+		zap.String("by", callerID(c).String()))
+	c.Status(http.StatusNoContent)
+}
+
+// ConfigReadOnlyReason is the reason given for read-only mode set in cfg.
+func ConfigReadOnlyReason(cfg *config.ServerConfig) string {
+	if cfg.ReadOnlyReason != "" {
+		return cfg.ReadOnlyReason
+	}
+	return "this instance is configured read-only"
+}
+
+// ─── Private helpers ──────────────────────────────────────────────────────
+
+// scope returns the tenant of scope, nil for the global scope.
+func (h *ReadOnlyHandler) scope(c *gin.Context, scope string) (*uuid.UUID, bool) {
+	switch scope {
+	case "", ReadOnlyTenant:
+		id := mustTenantID(c)
+		return &id, true
+	case ReadOnlyGlobal:
+		return nil, true
+	}
+	fail(c, http.StatusBadRequest, "scope must be tenant or global")
+	return nil, false
+}
+
+func switchState(sw *store.ReadOnlySwitch) ReadOnlyState {
+	if sw == nil {
+		return ReadOnlyState{}
+	}
+	return ReadOnlyState{ReadOnly: true, Source: "api", Reason: sw.Reason, Since: &sw.CreatedAt, EnabledBy: sw.EnabledBy}
+}
+
+func scopeName(tenantID *uuid.UUID) string {
+	if tenantID == nil {
+		return ReadOnlyGlobal
+	}
+	return ReadOnlyTenant + " " + tenantID.String()
+}
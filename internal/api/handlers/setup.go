@@ -0,0 +1,55 @@
+package handlers
+
+import (
+	"fmt"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+
+	"github.com/aegisx/aegisx/internal/setup"
+)
+
+// SetupHandler serves /api/v1/setup while the API runs in setup mode.
+type SetupHandler struct {
+	wizard *setup.Wizard
+	log    *zap.Logger
+}
+
+func NewSetupHandler(wizard *setup.Wizard, log *zap.Logger) *SetupHandler {
+	return &SetupHandler{wizard: wizard, log: log}
+}
+
+// Status GET /api/v1/setup
+func (h *SetupHandler) Status(c *gin.Context) {
+	c.JSON(http.StatusOK, gin.H{
+		"required":   !h.wizard.Completed(),
+		"configPath": h.wizard.Path(),
+	})
+}
+
+// Complete POST /api/v1/setup
+// Body: {"adminUser":"admin","adminPassword":"…","tenantName":"default"}.
+// The API restarts into normal mode once the config is written.
+func (h *SetupHandler) Complete(c *gin.Context) {
+	var req setup.Request
+	if err := c.ShouldBindJSON(&req); err != nil {
+		fail(c, http.StatusBadRequest, err.Error())
+		return
+	}
+	if h.wizard.Completed() {
+		fail(c, http.StatusGone, "setup already completed")
+		return
+	}
+	if len(req.AdminPassword) < setup.MinPasswordLength {
+		fail(c, http.StatusUnprocessableEntity, fmt.Sprintf("adminPassword must be at least %d characters", setup.MinPasswordLength))
+		return
+	}
+	if err := h.wizard.Complete(c.Request.Context(), req); err != nil {
+		h.log.Error("setup failed", zap.Error(err))
+		fail(c, http.StatusInternalServerError, err.Error())
+		return
+	}
+	h.log.Info("setup completed", zap.String("config", h.wizard.Path()))
+	c.JSON(http.StatusOK, gin.H{"configPath": h.wizard.Path(), "status": "complete"})
+}
@@ -7,38 +7,100 @@ import (
 
 	"github.com/gin-gonic/gin"
 	"go.uber.org/zap"
+
+	"github.com/aegisx/aegisx/internal/api/authz"
+	"github.com/aegisx/aegisx/internal/features"
+	"github.com/aegisx/aegisx/internal/timesync"
+	"github.com/aegisx/aegisx/pkg/version"
 )
 
 var startTime = time.Now()
 
-const Version = "0.1.0"
-
 type SystemHandler struct {
-	log *zap.Logger
+	clock    *timesync.Monitor
+	features *features.Set
+	backends []string // dataplane components in use, for /version
+	log      *zap.Logger
 }
 
-func NewSystemHandler(log *zap.Logger) *SystemHandler {
-	return &SystemHandler{log: log}
+func NewSystemHandler(clock *timesync.Monitor, fs *features.Set, backends []string, log *zap.Logger) *SystemHandler {
+	return &SystemHandler{clock: clock, features: fs, backends: backends, log: log}
 }
 
 // Status GET /api/v1/status
 func (h *SystemHandler) Status(c *gin.Context) {
-	c.JSON(http.StatusOK, gin.H{
+	resp := gin.H{
 		"status":    "ok",
-		"version":   Version,
+		"version":   version.Version,
 		"uptime":    time.Since(startTime).String(),
 		"goVersion": runtime.Version(),
 		"os":        runtime.GOOS,
 		"arch":      runtime.GOARCH,
 		"goroutines": runtime.NumGoroutine(),
-	})
+	}
+	if h.clock != nil {
+		resp["time"] = h.clock.Status()
+	}
+	c.JSON(http.StatusOK, resp)
+}
+
+// Time GET /api/v1/time
+// Returns the NTP synchronisation state of the system clock.
+func (h *SystemHandler) Time(c *gin.Context) {
+	if h.clock == nil {
+		Abort(c, http.StatusServiceUnavailable, CodeFeatureDisabled, "clock checks are disabled")
+		return
+	}
+	resp := gin.H{"now": time.Now(), "status": h.clock.Status()}
+	if err := h.clock.Synced(); err != nil {
+		resp["warning"] = err.Error()
+	}
+	c.JSON(http.StatusOK, resp)
+}
+
+// Features GET /api/v1/system/features
+// Returns every feature with its state, and the license summary if one is
+// loaded, so the UI can hide what is unavailable.
+func (h *SystemHandler) Features(c *gin.Context) {
+	items := h.features.List()
+	resp := gin.H{"items": items, "count": len(items)}
+	if l := h.features.License(); l != nil {
+		resp["license"] = gin.H{
+			"id":        l.ID,
+			"licensee":  l.Licensee,
+			"expiresAt": l.ExpiresAt,
+			"expired":   time.Now().After(l.ExpiresAt),
+			"maxNodes":  l.MaxNodes,
+		}
+	}
+	c.JSON(http.StatusOK, resp)
+}
+
+// Permissions GET /api/v1/system/permissions
+// Returns the authorization matrix: each route with the permission it needs
+// and the least role holding it.
+func (h *SystemHandler) Permissions(c *gin.Context) {
+	items := authz.Matrix()
+	c.JSON(http.StatusOK, gin.H{"items": items, "count": len(items)})
 }
 
 // Version GET /api/v1/version
+// Returns the build metadata, the enabled features and the version of each
+// dataplane component in use.
 func (h *SystemHandler) Version(c *gin.Context) {
+	var enabled []features.Feature
+	for _, f := range h.features.List() {
+		if f.Enabled {
+			enabled = append(enabled, f.Name)
+		}
+	}
+	if enabled == nil {
+		enabled = []features.Feature{}
+	}
 	c.JSON(http.StatusOK, gin.H{
-		"version":   Version,
-		"buildTime": "unknown",
-		"gitCommit": "unknown",
+		"build":     version.Get(),
+		"version":   version.Version,
+		"features":  enabled,
+		"dataplane": version.Backends(c.Request.Context(), h.backends...),
 	})
 }
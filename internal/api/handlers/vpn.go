@@ -0,0 +1,390 @@
+package handlers
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"go.uber.org/zap"
+
+	"github.com/aegisx/aegisx/internal/auth"
+	"github.com/aegisx/aegisx/internal/config"
+	"github.com/aegisx/aegisx/internal/store"
+	"github.com/aegisx/aegisx/internal/vpn"
+)
+
+const (
+	// maxStatsPoints bounds the size of a stats series regardless of range.
+	maxStatsPoints = 300
+	maxStatsRange  = 90 * 24 * time.Hour
+)
+
+// VPNHandler handles /api/v1/vpn endpoints.
+type VPNHandler struct {
+	store   *store.VPNStore
+	mgr     *vpn.Manager
+	authSvc *auth.Service
+	cfg     config.VPNConfig
+	log     *zap.Logger
+}
+
+func NewVPNHandler(store *store.VPNStore, mgr *vpn.Manager, authSvc *auth.Service, cfg config.VPNConfig, log *zap.Logger) *VPNHandler {
+	return &VPNHandler{store: store, mgr: mgr, authSvc: authSvc, cfg: cfg, log: log}
+}
+
+// StatsPoint is one downsampled point of a peer's bandwidth series.
+type StatsPoint struct {
+	Time          time.Time  `json:"t"`
+	RxBytes       int64      `json:"rxBytes"`
+	TxBytes       int64      `json:"txBytes"`
+	RxRate        float64    `json:"rxBytesPerSec"`
+	TxRate        float64    `json:"txBytesPerSec"`
+	LastHandshake *time.Time `json:"lastHandshake,omitempty"`
+}
+
+// ListPeers GET /api/v1/vpn/peers
+func (h *VPNHandler) ListPeers(c *gin.Context) {
+	tenantID := mustTenantID(c)
+
+	peers, err := h.store.ListPeers(c.Request.Context(), tenantID)
+	if err != nil {
+		h.log.Error("list vpn peers", zap.Error(err))
+		fail(c, http.StatusInternalServerError, "failed to list peers")
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"items": peers, "count": len(peers)})
+}
+
+// PeerStats GET /api/v1/vpn/peers/:id/stats?range=24h
+// Returns a downsampled rx/tx series with per-second rates derived from
+// counter deltas between points.
+func (h *VPNHandler) PeerStats(c *gin.Context) {
+	tenantID := mustTenantID(c)
+	id, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		fail(c, http.StatusBadRequest, "invalid id")
+		return
+	}
+
+	rng, err := parseStatsRange(c.DefaultQuery("range", "24h"))
+	if err != nil {
+		fail(c, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	if _, err := h.store.GetPeer(c.Request.Context(), tenantID, id); err != nil {
+		fail(c, http.StatusNotFound, "peer not found")
+		return
+	}
+
+	step := rng / maxStatsPoints
+	if step < time.Minute {
+		step = time.Minute
+	}
+	step = step.Round(time.Minute)
+
+	samples, err := h.store.PeerStats(c.Request.Context(), tenantID, id, time.Now().Add(-rng), step)
+	if err != nil {
+		h.log.Error("peer stats", zap.Error(err), zap.String("peer_id", id.String()))
+		fail(c, http.StatusInternalServerError, "failed to load peer stats")
+		return
+	}
+
+	points := make([]StatsPoint, len(samples))
+	for i, smp := range samples {
+		points[i] = StatsPoint{
+			Time:          smp.Bucket,
+			RxBytes:       smp.RxBytes,
+			TxBytes:       smp.TxBytes,
+			LastHandshake: smp.LastHandshake,
+		}
+		if i == 0 {
+			continue
+		}
+		secs := smp.Bucket.Sub(samples[i-1].Bucket).Seconds()
+		if secs <= 0 {
+			continue
+		}
+		points[i].RxRate = counterDelta(samples[i-1].RxBytes, smp.RxBytes) / secs
+		points[i].TxRate = counterDelta(samples[i-1].TxBytes, smp.TxBytes) / secs
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"peerId": id,
+		"range":  rng.String(),
+		"step":   step.String(),
+		"points": points,
+	})
+}
+
+// PeerConfig GET /api/v1/vpn/peers/:id/config?profile=split|full
+// Renders the peer's client config; profile defaults to the peer's stored one.
+func (h *VPNHandler) PeerConfig(c *gin.Context) {
+	tenantID := mustTenantID(c)
+	id, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		fail(c, http.StatusBadRequest, "invalid id")
+		return
+	}
+
+	profile := c.Query("profile")
+	if profile != "" && !vpn.ValidProfile(profile) {
+		fail(c, http.StatusBadRequest, "profile must be split or full")
+		return
+	}
+
+	peer, err := h.store.GetPeer(c.Request.Context(), tenantID, id)
+	if err != nil {
+		fail(c, http.StatusNotFound, "peer not found")
+		return
+	}
+
+	conf, err := renderPeerConfig(h.mgr, h.cfg, peer, "", profile)
+	if err != nil {
+		h.log.Error("render client config", zap.Error(err))
+		fail(c, http.StatusInternalServerError, "failed to render config: "+err.Error())
+		return
+	}
+	c.Header("Content-Disposition", `attachment; filename="`+h.cfg.Interface+`.conf"`)
+	c.Data(http.StatusOK, "text/plain; charset=utf-8", []byte(conf))
+}
+
+// SetProfile PUT /api/v1/vpn/peers/:id/profile
+func (h *VPNHandler) SetProfile(c *gin.Context) {
+	tenantID := mustTenantID(c)
+	id, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		fail(c, http.StatusBadRequest, "invalid id")
+		return
+	}
+
+	var body struct {
+		Profile string `json:"profile" binding:"required"`
+	}
+	if err := c.ShouldBindJSON(&body); err != nil {
+		fail(c, http.StatusBadRequest, err.Error())
+		return
+	}
+	if !vpn.ValidProfile(body.Profile) {
+		fail(c, http.StatusBadRequest, "profile must be split or full")
+		return
+	}
+
+	if err := h.store.SetTunnelProfile(c.Request.Context(), tenantID, id, body.Profile); err != nil {
+		fail(c, http.StatusNotFound, "peer not found")
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"peerId": id, "tunnelProfile": body.Profile})
+}
+
+// ProbeMTU POST /api/v1/vpn/peers/:id/mtu-probe
+// Measures the underlay path MTU to the peer's current endpoint and suggests
+// a tunnel MTU and keepalive. Nothing is changed on the interface.
+func (h *VPNHandler) ProbeMTU(c *gin.Context) {
+	tenantID := mustTenantID(c)
+	id, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		fail(c, http.StatusBadRequest, "invalid id")
+		return
+	}
+
+	peer, err := h.store.GetPeer(c.Request.Context(), tenantID, id)
+	if err != nil {
+		fail(c, http.StatusNotFound, "peer not found")
+		return
+	}
+
+	// Prefer the endpoint WireGuard last saw over the configured one: for a
+	// NAT-ed peer only the observed address is reachable.
+	observed := ""
+	if status, err := h.mgr.Status(); err == nil {
+		for _, p := range status.Peers {
+			if p.PublicKey == peer.PublicKey {
+				observed = p.Endpoint
+				break
+			}
+		}
+	}
+	endpoint := observed
+	if endpoint == "" {
+		endpoint = peer.Endpoint
+	}
+	if endpoint == "" {
+		fail(c, http.StatusConflict, "peer has no known endpoint; it must connect at least once")
+		return
+	}
+	host, _, err := net.SplitHostPort(endpoint)
+	if err != nil {
+		host = endpoint
+	}
+
+	ctx, cancel := context.WithTimeout(c.Request.Context(), 30*time.Second)
+	defer cancel()
+	pathMTU, err := vpn.ProbePathMTU(ctx, host)
+	if err != nil {
+		h.log.Warn("mtu probe failed", zap.Error(err), zap.String("peer_id", id.String()))
+		fail(c, http.StatusBadGateway, "probe failed: "+err.Error())
+		return
+	}
+
+	ip := net.ParseIP(host)
+	v6 := ip == nil || ip.To4() == nil
+
+	// A peer with no static endpoint, or one seen from a different address
+	// than configured, is behind NAT and needs keepalives.
+	natted := peer.Endpoint == "" || (observed != "" && observed != peer.Endpoint)
+	keepAlive := 0
+	if natted {
+		keepAlive = vpn.DefaultKeepAlive
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"peerId":             id,
+		"endpoint":           endpoint,
+		"pathMTU":            pathMTU,
+		"suggestedMTU":       vpn.RecommendedMTU(pathMTU, v6),
+		"natDetected":        natted,
+		"suggestedKeepAlive": keepAlive,
+	})
+}
+
+// IssuePortalToken POST /api/v1/vpn/peers/:id/portal-token
+// Mints a self-service token for the peer's owner. Optional body: {"ttl":"72h"}.
+// Portal tokens cannot be revoked, so ttl may shorten vpn.portal_token_ttl
+// but not exceed it.
+func (h *VPNHandler) IssuePortalToken(c *gin.Context) {
+	tenantID := mustTenantID(c)
+	id, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		fail(c, http.StatusBadRequest, "invalid id")
+		return
+	}
+
+	var body struct {
+		TTL string `json:"ttl"`
+	}
+	if c.Request.ContentLength > 0 {
+		if err := c.ShouldBindJSON(&body); err != nil {
+			fail(c, http.StatusBadRequest, err.Error())
+			return
+		}
+	}
+	ttl := h.cfg.PortalTokenTTL
+	if body.TTL != "" {
+		if ttl, err = time.ParseDuration(body.TTL); err != nil || ttl <= 0 {
+			fail(c, http.StatusBadRequest, "invalid ttl")
+			return
+		}
+		if ttl > h.cfg.PortalTokenTTL {
+			fail(c, http.StatusBadRequest, fmt.Sprintf("ttl must not exceed %s", h.cfg.PortalTokenTTL))
+			return
+		}
+	}
+
+	if _, err := h.store.GetPeer(c.Request.Context(), tenantID, id); err != nil {
+		fail(c, http.StatusNotFound, "peer not found")
+		return
+	}
+
+	token, err := h.authSvc.IssuePortalToken(tenantID, id, ttl)
+	if err != nil {
+		h.log.Error("issue portal token", zap.Error(err))
+		fail(c, http.StatusInternalServerError, "failed to issue token")
+		return
+	}
+	c.JSON(http.StatusCreated, gin.H{
+		"token":     token,
+		"peerId":    id,
+		"expiresIn": int(ttl.Seconds()),
+	})
+}
+
+// ─── Helpers ──────────────────────────────────────────────────────────────
+
+// parseStatsRange accepts Go durations plus a "d" (days) suffix, e.g. "7d".
+func parseStatsRange(s string) (time.Duration, error) {
+	var (
+		d   time.Duration
+		err error
+	)
+	if days, ok := strings.CutSuffix(s, "d"); ok {
+		var n int
+		n, err = strconv.Atoi(days)
+		d = time.Duration(n) * 24 * time.Hour
+	} else {
+		d, err = time.ParseDuration(s)
+	}
+	if err != nil || d <= 0 {
+		return 0, fmt.Errorf("invalid range %q", s)
+	}
+	if d > maxStatsRange {
+		return 0, fmt.Errorf("range %q exceeds maximum of %s", s, maxStatsRange)
+	}
+	return d, nil
+}
+
+// renderPeerConfig builds a peer's wg-quick client config. An empty profile
+// selects the peer's stored profile.
+func renderPeerConfig(mgr *vpn.Manager, cfg config.VPNConfig, peer *store.VPNPeerRecord, privateKey, profile string) (string, error) {
+	status, err := mgr.Status()
+	if err != nil {
+		return "", err
+	}
+
+	var dns []string
+	for _, d := range strings.Split(cfg.DNS, ",") {
+		if d = strings.TrimSpace(d); d != "" {
+			dns = append(dns, d)
+		}
+	}
+
+	if profile == "" {
+		profile = peer.TunnelProfile
+	}
+
+	// Clients sit behind NAT far more often than not, so they get the default
+	// keepalive unless the peer record overrides it. The MTU assumes an IPv6
+	// underlay since the client's uplink is unknown.
+	keepAlive := peer.KeepAlive
+	if keepAlive == 0 {
+		keepAlive = cfg.KeepAlive
+	}
+	mtu := cfg.MTU
+	if mtu == 0 {
+		mtu = vpn.RecommendedMTU(cfg.UplinkMTU, true, cfg.Encapsulation...)
+	}
+
+	client := vpn.ClientConfig{
+		PrivateKey:      privateKey,
+		Address:         peer.AllowedIPs,
+		MTU:             mtu,
+		ServerPublicKey: status.PublicKey,
+		Endpoint:        cfg.Endpoint,
+		PresharedKey:    peer.PresharedKey,
+		KeepAlive:       keepAlive,
+	}
+	err = vpn.ApplyProfile(&client, profile, vpn.ProfileSettings{
+		SplitCIDRs: cfg.SplitCIDRs,
+		Network:    cfg.Network,
+		DNS:        dns,
+	})
+	if err != nil {
+		return "", err
+	}
+	return vpn.GenerateClientConfig(client)
+}
+
+// counterDelta handles counter resets (interface restart) by treating the
+// new reading as the full delta.
+func counterDelta(prev, cur int64) float64 {
+	if cur < prev {
+		return float64(cur)
+	}
+	return float64(cur - prev)
+}
@@ -0,0 +1,98 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+
+	"github.com/aegisx/aegisx/internal/speedtest"
+	"github.com/aegisx/aegisx/internal/store"
+)
+
+// WANTestHandler handles /api/v1/wan/tests.
+type WANTestHandler struct {
+	runner *speedtest.Runner // nil when WAN tests are disabled
+	log    *zap.Logger
+}
+
+func NewWANTestHandler(runner *speedtest.Runner, log *zap.Logger) *WANTestHandler {
+	return &WANTestHandler{runner: runner, log: log}
+}
+
+// List GET /api/v1/wan/tests?uplink=wan1&since=168h&limit=100
+// Returns test results newest first, whether a test is running, and the
+// uplinks a test can run over.
+func (h *WANTestHandler) List(c *gin.Context) {
+	since, err := time.ParseDuration(c.DefaultQuery("since", "168h"))
+	if err != nil || since <= 0 {
+		fail(c, http.StatusBadRequest, "since must be a positive duration such as 24h")
+		return
+	}
+	limit, err := strconv.Atoi(c.DefaultQuery("limit", "100"))
+	if err != nil || limit <= 0 || limit > 1000 {
+		fail(c, http.StatusBadRequest, "limit must be between 1 and 1000")
+		return
+	}
+	items, err := h.runner.Store().List(c.Request.Context(), store.WANTestFilter{
+		Uplink: c.Query("uplink"),
+		Since:  time.Now().Add(-since),
+		Limit:  limit,
+	})
+	if err != nil {
+		h.log.Error("list wan tests", zap.Error(err))
+		fail(c, http.StatusInternalServerError, "failed to list WAN tests")
+		return
+	}
+	if items == nil {
+		items = []*store.WANTest{}
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"running": h.runner.Running(),
+		"uplinks": h.runner.Uplinks(),
+		"items":   items,
+		"count":   len(items),
+	})
+}
+
+// Run POST /api/v1/wan/tests
+// Starts a test of one uplink, or of all of them when none is named. A test
+// takes longer than a request may, so it runs in the background; its
+// results show up in List.
+func (h *WANTestHandler) Run(c *gin.Context) {
+	var req struct {
+		Uplink string `json:"uplink"`
+	}
+	if c.Request.ContentLength != 0 {
+		if err := c.ShouldBindJSON(&req); err != nil {
+			fail(c, http.StatusBadRequest, err.Error())
+			return
+		}
+	}
+	uid := callerID(c)
+	switch err := h.runner.Start(req.Uplink, &uid); {
+	case errors.Is(err, speedtest.ErrUnknownUplink):
+		fail(c, http.StatusNotFound, err.Error())
+		return
+	case errors.Is(err, speedtest.ErrBusy):
+		fail(c, http.StatusConflict, err.Error())
+		return
+	case err != nil:
+		h.log.Error("start wan test", zap.Error(err))
+		fail(c, http.StatusInternalServerError, "failed to start WAN test")
+		return
+	}
+	c.JSON(http.StatusAccepted, gin.H{"status": "started"})
+}
+
+// Available aborts with 503 when WAN tests are disabled.
+func (h *WANTestHandler) Available(c *gin.Context) {
+	if h.runner == nil {
+		Abort(c, http.StatusServiceUnavailable, CodeFeatureDisabled, "WAN tests are disabled")
+		return
+	}
+	c.Next()
+}
@@ -2,40 +2,133 @@ package api
 
 import (
 	"context"
+	"crypto/subtle"
 	"fmt"
 	"net/http"
+	"regexp"
+	"strings"
 	"time"
 
 	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
 	"go.uber.org/zap"
 
+	"github.com/aegisx/aegisx/internal/admission"
+	"github.com/aegisx/aegisx/internal/api/authz"
 	"github.com/aegisx/aegisx/internal/api/handlers"
 	"github.com/aegisx/aegisx/internal/auth"
+	"github.com/aegisx/aegisx/internal/backup"
+	"github.com/aegisx/aegisx/internal/ban"
+	"github.com/aegisx/aegisx/internal/blocklist"
 	"github.com/aegisx/aegisx/internal/config"
+	"github.com/aegisx/aegisx/internal/directory"
+	"github.com/aegisx/aegisx/internal/features"
 	"github.com/aegisx/aegisx/internal/firewall"
+	"github.com/aegisx/aegisx/internal/geoip"
+	"github.com/aegisx/aegisx/internal/hooks"
+	"github.com/aegisx/aegisx/internal/ids"
+	"github.com/aegisx/aegisx/internal/ifstats"
+	"github.com/aegisx/aegisx/internal/lb"
+	"github.com/aegisx/aegisx/internal/metrics"
+	"github.com/aegisx/aegisx/internal/monitor"
+	"github.com/aegisx/aegisx/internal/scim"
+	"github.com/aegisx/aegisx/internal/setup"
+	"github.com/aegisx/aegisx/internal/signing"
+	"github.com/aegisx/aegisx/internal/speedtest"
 	"github.com/aegisx/aegisx/internal/store"
+	"github.com/aegisx/aegisx/internal/supervisor"
+	"github.com/aegisx/aegisx/internal/timesync"
+	"github.com/aegisx/aegisx/internal/vpn"
 )
 
 // Server is the HTTP API server.
 type Server struct {
 	cfg        *config.ServerConfig
+	vpnCfg     *config.VPNConfig
 	router     *gin.Engine
 	httpServer *http.Server
 	log        *zap.Logger
 
 	// Services
-	firewallSvc *firewall.Service
-	policyStore *store.PolicyStore
-	authSvc     *auth.Service
+	firewallSvc    *firewall.Service
+	policyStore    *store.PolicyStore
+	namespaceStore *store.NamespaceStore
+	vpnStore       *store.VPNStore
+	vpnMgr         *vpn.Manager
+	lbAdapter      *lb.Adapter
+	lbStore        *store.LBStore
+	lbCollector    *lb.AccessLogCollector
+	idsAdapter     *ids.Adapter
+	idsStore       *store.IDSStore
+	eventStore     *store.EventStore
+	geo            *geoip.Resolver
+	changeStore    *store.ChangeStore
+	impersonations *store.ImpersonationStore
+	freezes        *store.FreezeStore
+	readOnly       *store.ReadOnlyStore
+	breakGlass     *store.BreakGlassStore
+	breakGlassCfg  *config.BreakGlassConfig
+	hooks          *hooks.Runner
+	auditStore     *store.AuditStore
+	banMgr         *ban.Manager
+	blockLists     *blocklist.Manager
+	monitors       *monitor.Manager
+	monitorsCfg    *config.MonitorsConfig
+	wanTests       *speedtest.Runner
+	ifStats        *ifstats.Sampler
+	daemons        *supervisor.Supervisor
+	backups        *backup.Manager
+	clock          *timesync.Monitor
+	features       *features.Set
+	admission      *admission.Controller
+	signing        *signing.Verifier
+	authSvc        *auth.Service
+	passkeys       *auth.Passkeys
+	scimCfg        *config.SCIMConfig
+	scimUsers      *store.UserStore
+	scimMapper     *scim.Mapper
+	ldap           *directory.Syncer
 }
 
 // ServerDeps bundles all service dependencies.
 type ServerDeps struct {
-	Config      *config.Config
-	FirewallSvc *firewall.Service
-	PolicyStore *store.PolicyStore
-	AuthSvc     *auth.Service
-	Log         *zap.Logger
+	Config         *config.Config
+	FirewallSvc    *firewall.Service
+	PolicyStore    *store.PolicyStore
+	NamespaceStore *store.NamespaceStore
+	VPNStore       *store.VPNStore
+	VPNManager     *vpn.Manager
+	LBAdapter      *lb.Adapter // nil when the load balancer is disabled
+	LBStore        *store.LBStore
+	LBCollector    *lb.AccessLogCollector // nil when access logging is off
+	IDSAdapter     *ids.Adapter           // nil when IDS is disabled
+	IDSStore       *store.IDSStore
+	EventStore     *store.EventStore
+	GeoIP          *geoip.Resolver // nil when no GeoIP database is configured
+	ChangeStore    *store.ChangeStore
+	Impersonations *store.ImpersonationStore
+	Freezes        *store.FreezeStore
+	ReadOnly       *store.ReadOnlyStore
+	BreakGlass     *store.BreakGlassStore
+	Hooks          *hooks.Runner // nil when no hooks are configured
+	AuditStore     *store.AuditStore
+	BanManager     *ban.Manager       // nil when brute-force protection is disabled
+	BlockLists     *blocklist.Manager // nil when block lists are disabled
+	Monitors       *monitor.Manager   // nil when monitors are disabled
+	WANTests       *speedtest.Runner  // nil when WAN tests are disabled
+	IfStats        *ifstats.Sampler   // nil when interface statistics are disabled
+	Backups        *backup.Manager
+	Clock          *timesync.Monitor // nil when clock checks are disabled
+	Features       *features.Set
+	Admission      *admission.Controller // nil when admission control is disabled
+	Signing        *signing.Verifier     // nil when no signing keys are configured
+	AuthSvc        *auth.Service
+	Passkeys       *auth.Passkeys   // nil when WebAuthn is disabled
+	UserStore      *store.UserStore // nil when SCIM is disabled
+	SCIMMapper     *scim.Mapper
+	LDAP           *directory.Syncer      // nil when LDAP is disabled
+	Daemons        *supervisor.Supervisor // nil when no daemon is supervised
+	Log            *zap.Logger
 }
 
 // NewServer wires up the Gin router with all routes and middleware.
@@ -48,16 +141,55 @@ func NewServer(deps ServerDeps) *Server {
 	router := gin.New()
 
 	s := &Server{
-		cfg:         &deps.Config.Server,
-		router:      router,
-		log:         deps.Log,
-		firewallSvc: deps.FirewallSvc,
-		policyStore: deps.PolicyStore,
-		authSvc:     deps.AuthSvc,
+		cfg:            &deps.Config.Server,
+		vpnCfg:         &deps.Config.VPN,
+		router:         router,
+		log:            deps.Log,
+		firewallSvc:    deps.FirewallSvc,
+		policyStore:    deps.PolicyStore,
+		namespaceStore: deps.NamespaceStore,
+		vpnStore:       deps.VPNStore,
+		vpnMgr:         deps.VPNManager,
+		lbAdapter:      deps.LBAdapter,
+		lbStore:        deps.LBStore,
+		lbCollector:    deps.LBCollector,
+		idsAdapter:     deps.IDSAdapter,
+		idsStore:       deps.IDSStore,
+		eventStore:     deps.EventStore,
+		geo:            deps.GeoIP,
+		changeStore:    deps.ChangeStore,
+		impersonations: deps.Impersonations,
+		freezes:        deps.Freezes,
+		readOnly:       deps.ReadOnly,
+		breakGlass:     deps.BreakGlass,
+		breakGlassCfg:  &deps.Config.Auth.BreakGlass,
+		hooks:          deps.Hooks,
+		auditStore:     deps.AuditStore,
+		banMgr:         deps.BanManager,
+		blockLists:     deps.BlockLists,
+		monitors:       deps.Monitors,
+		monitorsCfg:    &deps.Config.Monitors,
+		wanTests:       deps.WANTests,
+		ifStats:        deps.IfStats,
+		daemons:        deps.Daemons,
+		backups:        deps.Backups,
+		clock:          deps.Clock,
+		features:       deps.Features,
+		admission:      deps.Admission,
+		signing:        deps.Signing,
+		authSvc:        deps.AuthSvc,
+		passkeys:       deps.Passkeys,
+		scimCfg:        &deps.Config.Auth.SCIM,
+		scimUsers:      deps.UserStore,
+		scimMapper:     deps.SCIMMapper,
+		ldap:           deps.LDAP,
 	}
 
 	s.setupMiddleware()
 	s.setupRoutes()
+	if err := authz.Check(router.Routes()); err != nil {
+		panic(err)
+	}
 
 	s.httpServer = &http.Server{
 		Addr:         fmt.Sprintf("%s:%d", deps.Config.Server.Host, deps.Config.Server.Port),
@@ -69,9 +201,41 @@ func NewServer(deps ServerDeps) *Server {
 	return s
 }
 
+// NewSetupServer serves only the first-run setup endpoint, used while no
+// admin credential is configured. Callers must present the setup token in
+// X-Setup-Token.
+func NewSetupServer(cfg *config.ServerConfig, wizard *setup.Wizard, log *zap.Logger) *Server {
+	port := cfg.Port
+	if port == 0 {
+		port = 8080
+	}
+
+	gin.SetMode(gin.ReleaseMode)
+	router := gin.New()
+	s := &Server{cfg: cfg, router: router, log: log}
+	router.Use(gin.Recovery(), s.requestID(), s.requestLogger(), s.securityHeaders())
+
+	router.GET("/healthz", func(c *gin.Context) {
+		c.JSON(http.StatusOK, gin.H{"status": "setup", "timestamp": time.Now()})
+	})
+	h := handlers.NewSetupHandler(wizard, log)
+	g := router.Group("/api/v1/setup", setupGuard(wizard))
+	g.GET("", h.Status)
+	g.POST("", h.Complete)
+
+	s.httpServer = &http.Server{
+		Addr:         fmt.Sprintf("%s:%d", cfg.Host, port),
+		Handler:      router,
+		ReadTimeout:  cfg.ReadTimeout,
+		WriteTimeout: cfg.WriteTimeout,
+	}
+	return s
+}
+
 func (s *Server) setupMiddleware() {
 	s.router.Use(
 		gin.Recovery(),
+		s.requestID(),
 		s.requestLogger(),
 		s.corsMiddleware(),
 		s.securityHeaders(),
@@ -81,7 +245,29 @@ func (s *Server) setupMiddleware() {
 func (s *Server) setupRoutes() {
 	// Health
 	s.router.GET("/healthz", func(c *gin.Context) {
-		c.JSON(http.StatusOK, gin.H{"status": "ok", "timestamp": time.Now()})
+		resp := gin.H{"status": "ok", "timestamp": time.Now()}
+		var warnings []string
+		// A drifting clock does not make the API unhealthy, but schedules
+		// and token expiry can no longer be trusted.
+		if s.clock != nil {
+			if err := s.clock.Synced(); err != nil {
+				warnings = append(warnings, err.Error())
+			}
+		}
+		// Nor does a crashed daemon; the supervisor is restarting it.
+		if s.daemons != nil {
+			daemons := s.daemons.Status()
+			for _, d := range daemons {
+				if d.State != supervisor.StateRunning {
+					warnings = append(warnings, "daemon "+d.Name+" is "+d.State)
+				}
+			}
+			resp["daemons"] = daemons
+		}
+		if len(warnings) > 0 {
+			resp["warnings"] = warnings
+		}
+		c.JSON(http.StatusOK, resp)
 	})
 	s.router.GET("/readyz", func(c *gin.Context) {
 		c.JSON(http.StatusOK, gin.H{"status": "ready"})
@@ -89,46 +275,309 @@ func (s *Server) setupRoutes() {
 
 	// Prometheus metrics — served by metrics package on separate port
 
+	// ── SCIM provisioning (IdP-facing, own bearer token) ────────────────
+	if s.scimUsers != nil {
+		scimHandler := handlers.NewSCIMHandler(s.scimUsers, s.scimMapper, s.log)
+		sc := s.router.Group("/scim/v2", s.requireFeature(features.SCIM), s.scimAuth(), s.readOnlyGuard())
+		{
+			sc.GET("/ServiceProviderConfig", scimHandler.ServiceProviderConfig)
+			sc.GET("/Users", scimHandler.ListUsers)
+			sc.POST("/Users", scimHandler.CreateUser)
+			sc.GET("/Users/:id", scimHandler.GetUser)
+			sc.PUT("/Users/:id", scimHandler.ReplaceUser)
+			sc.PATCH("/Users/:id", scimHandler.PatchUser)
+			sc.DELETE("/Users/:id", scimHandler.DeleteUser)
+			sc.GET("/Groups", scimHandler.ListGroups)
+			sc.POST("/Groups", scimHandler.CreateGroup)
+			sc.GET("/Groups/:id", scimHandler.GetGroup)
+			sc.PUT("/Groups/:id", scimHandler.ReplaceGroup)
+			sc.PATCH("/Groups/:id", scimHandler.PatchGroup)
+			sc.DELETE("/Groups/:id", scimHandler.DeleteGroup)
+		}
+	}
+
 	v1 := s.router.Group("/api/v1")
 
 	// ── Auth ────────────────────────────────────────────────────────────
-	authHandler := handlers.NewAuthHandler(s.authSvc, s.log)
+	authHandler := handlers.NewAuthHandler(s.authSvc, s.passkeys, s.banMgr, s.log)
 	v1.POST("/auth/login", authHandler.Login)
 	v1.POST("/auth/refresh", authHandler.Refresh)
 	v1.POST("/auth/logout", s.authMiddleware(), authHandler.Logout)
+	v1.POST("/auth/passkeys/login/begin", authHandler.PasskeyLoginBegin)
+	v1.POST("/auth/passkeys/login/finish", authHandler.PasskeyLoginFinish)
 
 	// ── All routes below require authentication ─────────────────────────
-	protected := v1.Group("", s.authMiddleware())
+	impersonationHandler := handlers.NewImpersonationHandler(s.authSvc, s.impersonations, s.auditStore, s.log)
+	breakGlassHandler := handlers.NewBreakGlassHandler(s.breakGlassCfg, s.authSvc, s.breakGlass, s.auditStore, s.hooks, s.log)
+	protected := v1.Group("", s.authMiddleware(), s.authorize(), s.readOnlyGuard(), s.auditImpersonated(impersonationHandler), s.auditBreakGlass(breakGlassHandler))
+
+	// ── Passkeys (the caller's own WebAuthn credentials) ────────────────
+	passkeys := protected.Group("/auth/passkeys")
+	{
+		passkeys.GET("", authHandler.ListPasskeys)
+		passkeys.POST("/register/begin", authHandler.PasskeyRegisterBegin)
+		passkeys.POST("/register/finish", authHandler.PasskeyRegisterFinish)
+		passkeys.DELETE("/:id", authHandler.DeletePasskey)
+	}
 
 	// ── Policies ─────────────────────────────────────────────────────────
-	policyHandler := handlers.NewPolicyHandler(s.policyStore, s.firewallSvc, s.log)
+	policyHandler := handlers.NewPolicyHandler(s.policyStore, s.namespaceStore, s.firewallSvc, s.admission, s.signing, s.lbAdapter, s.vpnMgr, s.idsAdapter, s.log)
 	policies := protected.Group("/policies")
 	{
 		policies.GET("", policyHandler.List)
 		policies.POST("", policyHandler.Create)
+		policies.POST("/validate", policyHandler.Validate)
+		policies.POST("/preview", policyHandler.Preview)
+		policies.POST("/validate-schema", policyHandler.ValidateSchema)
 		policies.GET("/:id", policyHandler.Get)
 		policies.PUT("/:id", policyHandler.Update)
-		policies.DELETE("/:id", policyHandler.Delete)
-		policies.POST("/:id/apply", policyHandler.Apply)
+		policies.DELETE("/:id", s.freezeGuard(), policyHandler.Delete)
+		policies.POST("/:id/apply", s.freezeGuard(), policyHandler.Apply)
+		policies.POST("/:id/enable", s.freezeGuard(), policyHandler.Enable)
+		policies.POST("/:id/disable", s.freezeGuard(), policyHandler.Disable)
 		policies.GET("/:id/diff", policyHandler.Diff)
+		policies.GET("/:id/render", policyHandler.Render)
 		policies.GET("/:id/revisions", policyHandler.ListRevisions)
 	}
+	protected.GET("/schemas/:kind", policyHandler.Schema)
+
+	// ── Policy changes (approval queue) ──────────────────────────────────
+	changeHandler := handlers.NewChangeHandler(s.changeStore, policyHandler, s.log)
+	changes := protected.Group("/changes")
+	{
+		changes.GET("", changeHandler.List)
+		changes.GET("/:id", changeHandler.Get)
+		changes.POST("/:id/approve", changeHandler.Approve)
+		changes.POST("/:id/reject", changeHandler.Reject)
+	}
+
+	// ── Admission rules ──────────────────────────────────────────────────
+	admissionHandler := handlers.NewAdmissionHandler(s.admission, s.log)
+	protected.GET("/admission/rules", admissionHandler.Rules)
+	protected.POST("/admission/reload", admissionHandler.Reload)
+
+	// ── IDS ──────────────────────────────────────────────────────────────
+	idsHandler := handlers.NewIDSHandler(s.idsAdapter, s.idsStore, s.changeStore, s.log)
+	idsGroup := protected.Group("/ids")
+	{
+		idsGroup.GET("/stats", idsHandler.Stats)
+		idsGroup.GET("/alerts", idsHandler.ListAlerts)
+		idsGroup.GET("/suggestions", idsHandler.ListSuggestions)
+		idsGroup.POST("/suggestions/:id/accept", idsHandler.AcceptSuggestion)
+		idsGroup.POST("/suggestions/:id/dismiss", idsHandler.DismissSuggestion)
+		idsGroup.GET("/sids", idsHandler.ListSIDOverrides)
+		idsGroup.PUT("/sids/:sid", idsHandler.PutSIDOverride)
+		idsGroup.DELETE("/sids/:sid", idsHandler.DeleteSIDOverride)
+		idsGroup.POST("/ruleset/rebuild", idsHandler.RebuildRuleset)
+	}
+
+	// ── Namespaces ───────────────────────────────────────────────────────
+	nsHandler := handlers.NewNamespaceHandler(s.namespaceStore, s.log)
+	namespaces := protected.Group("/namespaces")
+	{
+		namespaces.GET("", nsHandler.List)
+		namespaces.POST("", nsHandler.Create)
+		namespaces.GET("/:name", nsHandler.Get)
+		namespaces.PUT("/:name", nsHandler.Update)
+		namespaces.DELETE("/:name", nsHandler.Delete)
+		namespaces.GET("/:name/bindings", nsHandler.ListBindings)
+		namespaces.PUT("/:name/bindings/:userId", nsHandler.PutBinding)
+		namespaces.DELETE("/:name/bindings/:userId", nsHandler.DeleteBinding)
+	}
 
 	// ── Firewall ─────────────────────────────────────────────────────────
-	fwHandler := handlers.NewFirewallHandler(s.firewallSvc, s.log)
+	fwHandler := handlers.NewFirewallHandler(s.firewallSvc, s.policyStore, s.eventStore, s.log)
 	firewall := protected.Group("/firewall")
 	{
 		firewall.GET("/status", fwHandler.Status)
-		firewall.POST("/apply", fwHandler.ApplyDir)
-		firewall.POST("/rollback", fwHandler.Rollback)
-		firewall.POST("/flush", fwHandler.Flush)
+		firewall.POST("/apply", s.freezeGuard(), fwHandler.ApplyDir)
+		firewall.POST("/rollback", s.freezeGuard(), fwHandler.Rollback)
+		firewall.POST("/flush", s.freezeGuard(), fwHandler.Flush)
 		firewall.GET("/rules", fwHandler.ListRules)
+		firewall.GET("/rules/:handle/source", fwHandler.RuleSource)
+		firewall.GET("/health", fwHandler.Health)
+		firewall.GET("/analysis", fwHandler.Analysis)
+		firewall.POST("/test", fwHandler.Test)
+		firewall.POST("/simulate", fwHandler.Simulate)
+		firewall.GET("/scans", fwHandler.Scans)
+		firewall.GET("/events", fwHandler.Events)
+		firewall.GET("/netns", fwHandler.NetNamespaces)
+	}
+
+	// ── Multi-WAN ────────────────────────────────────────────────────────
+	protected.GET("/wan/uplinks", fwHandler.WANUplinks)
+	protected.GET("/wan/paths", fwHandler.WANPaths)
+	protected.GET("/vrfs", fwHandler.VRFs)
+	wanTestHandler := handlers.NewWANTestHandler(s.wanTests, s.log)
+	wanTests := protected.Group("/wan/tests", wanTestHandler.Available)
+	{
+		wanTests.GET("", wanTestHandler.List)
+		wanTests.POST("", wanTestHandler.Run)
+	}
+
+	// ── Brute-force bans ─────────────────────────────────────────────────
+	banHandler := handlers.NewBanHandler(s.banMgr, s.log)
+	bans := protected.Group("/bans")
+	{
+		bans.GET("", banHandler.List)
+		bans.POST("", banHandler.Create)
+		bans.DELETE("/:address", banHandler.Delete)
+	}
+
+	// ── Block lists ──────────────────────────────────────────────────────
+	blockListHandler := handlers.NewBlockListHandler(s.blockLists, s.log)
+	blockLists := protected.Group("/blocklists", blockListHandler.Available)
+	{
+		blockLists.GET("", blockListHandler.List)
+		blockLists.POST("", blockListHandler.Create)
+		blockLists.GET("/:id", blockListHandler.Get)
+		blockLists.PATCH("/:id", blockListHandler.Update)
+		blockLists.DELETE("/:id", blockListHandler.Delete)
+		blockLists.GET("/:id/stats", blockListHandler.Stats)
+		blockLists.GET("/:id/entries", blockListHandler.ListEntries)
+		blockLists.POST("/:id/entries", blockListHandler.AddEntry)
+		blockLists.DELETE("/:id/entries/:entryId", blockListHandler.DeleteEntry)
+		blockLists.POST("/:id/feeds", blockListHandler.AddFeed)
+		blockLists.DELETE("/:id/feeds/:feedId", blockListHandler.DeleteFeed)
+		blockLists.POST("/:id/feeds/:feedId/refresh", blockListHandler.RefreshFeed)
+	}
+
+	// ── Monitors ─────────────────────────────────────────────────────────
+	monitorHandler := handlers.NewMonitorHandler(s.monitors, s.firewallSvc, s.monitorsCfg, s.log)
+	monitors := protected.Group("/monitors", monitorHandler.Available)
+	{
+		monitors.GET("", monitorHandler.List)
+		monitors.POST("", monitorHandler.Create)
+		monitors.GET("/:id", monitorHandler.Get)
+		monitors.PUT("/:id", monitorHandler.Update)
+		monitors.DELETE("/:id", monitorHandler.Delete)
+		monitors.GET("/:id/events", monitorHandler.Events)
+	}
+
+	// ── Analytics ────────────────────────────────────────────────────────
+	analyticsHandler := handlers.NewAnalyticsHandler(s.eventStore, s.geo, s.log)
+	analytics := protected.Group("/analytics")
+	{
+		analytics.GET("/blocked", analyticsHandler.Blocked)
+	}
+
+	// ── Load balancer ────────────────────────────────────────────────────
+	lbHandler := handlers.NewLBHandler(s.lbAdapter, s.lbStore, s.lbCollector, s.log)
+	lbGroup := protected.Group("/lb", lbHandler.Available)
+	{
+		lbGroup.GET("/analytics", lbHandler.Analytics)
+		lbGroup.GET("/discovery", lbHandler.Discovery)
+		lbGroup.GET("/maintenance", lbHandler.ListMaintenance)
+		lbGroup.PUT("/backends/:name/maintenance", lbHandler.StartMaintenance)
+		lbGroup.DELETE("/backends/:name/maintenance", lbHandler.EndMaintenance)
+	}
+
+	// ── VPN ──────────────────────────────────────────────────────────────
+	vpnHandler := handlers.NewVPNHandler(s.vpnStore, s.vpnMgr, s.authSvc, *s.vpnCfg, s.log)
+	vpnGroup := protected.Group("/vpn")
+	{
+		vpnGroup.GET("/peers", vpnHandler.ListPeers)
+		vpnGroup.GET("/peers/:id/stats", vpnHandler.PeerStats)
+		vpnGroup.GET("/peers/:id/config", vpnHandler.PeerConfig)
+		vpnGroup.PUT("/peers/:id/profile", vpnHandler.SetProfile)
+		vpnGroup.POST("/peers/:id/mtu-probe", vpnHandler.ProbeMTU)
+		vpnGroup.POST("/peers/:id/portal-token", vpnHandler.IssuePortalToken)
+	}
+
+	// ── VPN self-service portal (portal-scoped tokens only) ─────────────
+	portalHandler := handlers.NewPortalHandler(s.vpnStore, s.policyStore, s.vpnMgr, *s.vpnCfg, s.log)
+	portal := v1.Group("/portal", s.portalMiddleware(), s.readOnlyGuard())
+	{
+		portal.GET("/peer", portalHandler.Peer)
+		portal.GET("/peer/config", portalHandler.Config)
+		portal.GET("/peer/status", portalHandler.Status)
+		portal.POST("/peer/rotate", portalHandler.RotateKey)
+	}
+
+	// ── Change freezes ───────────────────────────────────────────────────
+	freezeHandler := handlers.NewFreezeHandler(s.freezes, s.log)
+	freezes := protected.Group("/freezes")
+	{
+		freezes.GET("", freezeHandler.List)
+		freezes.POST("", freezeHandler.Create)
+		freezes.DELETE("/:id", freezeHandler.Delete)
+	}
+
+	// ── Break-glass (emergency admin access) ─────────────────────────────
+	breakGlass := protected.Group("/breakglass")
+	{
+		breakGlass.GET("", breakGlassHandler.List)
+		breakGlass.POST("", breakGlassHandler.Request)
+		breakGlass.POST("/:id/approve", breakGlassHandler.Approve)
+		breakGlass.POST("/:id/token", breakGlassHandler.Token)
+		breakGlass.DELETE("/:id", breakGlassHandler.End)
+	}
+
+	// ── Impersonation (admin act-as tenant) ──────────────────────────────
+	impersonations := protected.Group("/impersonations")
+	{
+		impersonations.GET("", impersonationHandler.List)
+		impersonations.POST("", impersonationHandler.Start)
+		impersonations.DELETE("/:id", impersonationHandler.End)
+		impersonations.GET("/:id/audit", impersonationHandler.Audit)
 	}
 
 	// ── System status ────────────────────────────────────────────────────
-	sysHandler := handlers.NewSystemHandler(s.log)
+	backends := []string{"nftables"}
+	if s.lbAdapter != nil {
+		backends = append(backends, "haproxy")
+	}
+	if s.idsAdapter != nil {
+		backends = append(backends, "suricata")
+	}
+	if s.vpnCfg.Enabled {
+		backends = append(backends, "wireguard")
+	}
+	sysHandler := handlers.NewSystemHandler(s.clock, s.features, backends, s.log)
 	protected.GET("/status", sysHandler.Status)
 	protected.GET("/version", sysHandler.Version)
+	protected.GET("/time", sysHandler.Time)
+	protected.GET("/system/features", sysHandler.Features)
+	protected.GET("/system/permissions", sysHandler.Permissions)
+
+	// ── Interface statistics ─────────────────────────────────────────────
+	ifHandler := handlers.NewInterfaceHandler(s.ifStats, s.log)
+	protected.GET("/system/interfaces/:name/stats", ifHandler.Available, ifHandler.Stats)
+
+	// ── LDAP directory ───────────────────────────────────────────────────
+	ldapHandler := handlers.NewLDAPHandler(s.ldap, s.log)
+	protected.GET("/system/ldap", ldapHandler.Available, ldapHandler.Status)
+	protected.POST("/system/ldap/sync", ldapHandler.Available, ldapHandler.Sync)
+
+	// ── Audit log ────────────────────────────────────────────────────────
+	auditHandler := handlers.NewAuditHandler(s.auditStore, s.log)
+	protected.GET("/system/audit", auditHandler.List)
+	protected.GET("/system/audit/verify", auditHandler.Verify)
+
+	// ── Observability ────────────────────────────────────────────────────
+	obsHandler := handlers.NewObservabilityHandler(s.log)
+	protected.GET("/observability/grafana-dashboards", obsHandler.GrafanaDashboards)
+
+	// ── GraphQL ──────────────────────────────────────────────────────────
+	gqlHandler := handlers.NewGraphQLHandler(s.cfg.GraphQL, s.policyStore, s.namespaceStore,
+		s.idsStore, s.vpnStore, s.firewallSvc, s.log)
+	protected.GET("/graphql", gqlHandler.Available, gqlHandler.Query)
+	protected.POST("/graphql", gqlHandler.Available, gqlHandler.Query)
+
+	// ── Read-only mode ───────────────────────────────────────────────────
+	readOnlyHandler := handlers.NewReadOnlyHandler(s.readOnly, s.cfg, s.log)
+	protected.GET("/system/read-only", readOnlyHandler.Status)
+	protected.PUT("/system/read-only", readOnlyHandler.Enable)
+	protected.DELETE("/system/read-only", readOnlyHandler.Disable)
+
+	// ── Backups ──────────────────────────────────────────────────────────
+	backupHandler := handlers.NewBackupHandler(s.backups, s.log)
+	backups := protected.Group("/system/backups")
+	{
+		backups.GET("", backupHandler.List)
+		backups.POST("", backupHandler.Create)
+		backups.POST("/:name/verify", backupHandler.Verify)
+	}
 }
 
 // Start begins listening for HTTP connections.
@@ -150,6 +599,40 @@ func (s *Server) Shutdown(ctx context.Context) error {
 
 // ─── Middleware helpers ───────────────────────────────────────────────────
 
+// requestID tags each request with the caller's X-Request-ID, or a new one,
+// so error responses and log lines can be correlated. The trace ID of a W3C
+// traceparent header is kept too; metrics link to it through exemplars.
+func (s *Server) requestID() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		id := c.GetHeader("X-Request-ID")
+		if id == "" || len(id) > 128 {
+			id = uuid.NewString()
+		}
+		c.Set("request_id", id)
+		c.Header("X-Request-ID", id)
+		traceID := traceIDOf(c.GetHeader("traceparent"))
+		if traceID != "" {
+			c.Set("trace_id", traceID)
+		}
+		c.Request = c.Request.WithContext(metrics.WithTrace(c.Request.Context(), traceID, id))
+		c.Next()
+	}
+}
+
+// traceparentRe matches a W3C traceparent header: version, trace ID,
+// parent ID and flags.
+var traceparentRe = regexp.MustCompile(`^[0-9a-f]{2}-([0-9a-f]{32})-[0-9a-f]{16}-[0-9a-f]{2}$`)
+
+// traceIDOf returns the trace ID of a traceparent header, or "" if the
+// header is missing or malformed. An all-zero trace ID is invalid.
+func traceIDOf(header string) string {
+	m := traceparentRe.FindStringSubmatch(strings.TrimSpace(header))
+	if m == nil || strings.Trim(m[1], "0") == "" {
+		return ""
+	}
+	return m[1]
+}
+
 func (s *Server) requestLogger() gin.HandlerFunc {
 	return func(c *gin.Context) {
 		start := time.Now()
@@ -160,6 +643,7 @@ func (s *Server) requestLogger() gin.HandlerFunc {
 			zap.Int("status", c.Writer.Status()),
 			zap.Duration("latency", time.Since(start)),
 			zap.String("ip", c.ClientIP()),
+			zap.String("request_id", c.GetString("request_id")),
 		)
 	}
 }
@@ -168,7 +652,8 @@ func (s *Server) corsMiddleware() gin.HandlerFunc {
 	return func(c *gin.Context) {
 		c.Header("Access-Control-Allow-Origin", "*")
 		c.Header("Access-Control-Allow-Methods", "GET, POST, PUT, DELETE, OPTIONS")
-		c.Header("Access-Control-Allow-Headers", "Authorization, Content-Type, X-Tenant-ID")
+		c.Header("Access-Control-Allow-Headers", "Authorization, Content-Type, X-Request-ID, If-None-Match, If-Modified-Since")
+		c.Header("Access-Control-Expose-Headers", "ETag, Last-Modified, X-Request-ID, X-Resource-Version")
 
 		if c.Request.Method == http.MethodOptions {
 			c.AbortWithStatus(http.StatusNoContent)
@@ -191,25 +676,269 @@ func (s *Server) securityHeaders() gin.HandlerFunc {
 
 func (s *Server) authMiddleware() gin.HandlerFunc {
 	return func(c *gin.Context) {
-		token := c.GetHeader("Authorization")
-		if token == "" {
-			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "missing authorization header"})
+		claims, ok := s.bearerClaims(c)
+		if !ok {
 			return
 		}
-		// Strip "Bearer " prefix
-		if len(token) > 7 && token[:7] == "Bearer " {
-			token = token[7:]
+		// Limited-scope tokens must never reach the full API.
+		if claims.Scope != "" {
+			handlers.Abort(c, http.StatusForbidden, handlers.CodeForbidden, "token scope not permitted")
+			return
 		}
 
-		claims, err := s.authSvc.ValidateToken(token)
-		if err != nil {
-			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "invalid token"})
+		// Impersonation tokens live only as long as their session.
+		if claims.ImpersonatorID != nil {
+			sessionID, err := uuid.Parse(claims.ID)
+			if err != nil {
+				handlers.Abort(c, http.StatusUnauthorized, handlers.CodeUnauthenticated, "invalid token")
+				return
+			}
+			session, err := s.impersonations.Get(c.Request.Context(), sessionID)
+			if err != nil || !session.Active() || session.TenantID != claims.TenantID {
+				handlers.Abort(c, http.StatusUnauthorized, handlers.CodeUnauthenticated, "impersonation session has ended")
+				return
+			}
+			c.Set("impersonation_id", sessionID)
+		}
+
+		// So do break-glass tokens: ending the session revokes them.
+		if claims.BreakGlass {
+			sessionID, err := uuid.Parse(claims.ID)
+			if err != nil {
+				handlers.Abort(c, http.StatusUnauthorized, handlers.CodeUnauthenticated, "invalid token")
+				return
+			}
+			session, err := s.breakGlass.Get(c.Request.Context(), sessionID)
+			if err != nil || !session.Active() || session.TenantID != claims.TenantID || session.UserID != claims.UserID {
+				handlers.Abort(c, http.StatusUnauthorized, handlers.CodeUnauthenticated, "break-glass session has ended")
+				return
+			}
+			c.Set("break_glass_id", sessionID)
+		}
+
+		// Provisioned users lose access as soon as the IdP deactivates them.
+		if err := s.authSvc.CheckActive(c.Request.Context(), claims); err != nil {
+			handlers.Abort(c, http.StatusUnauthorized, handlers.CodeUnauthenticated, err.Error())
+			return
+		}
+		if err := s.authSvc.CheckSession(c.Request.Context(), claims); err != nil {
+			handlers.Abort(c, http.StatusUnauthorized, handlers.CodeUnauthenticated, err.Error())
 			return
 		}
 
 		c.Set("user_id", claims.UserID)
 		c.Set("tenant_id", claims.TenantID)
 		c.Set("role", claims.Role)
+		c.Set("break_glass", claims.BreakGlass)
+		c.Set("instance_operator", s.authSvc.IsInstanceOperator(claims))
+		c.Next()
+	}
+}
+
+// authorize admits the caller when their role holds the permission the
+// route needs in the authorization matrix. Routes missing from the matrix
+// are refused.
+func (s *Server) authorize() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		perm, ok := authz.ForRoute(c.Request.Method, c.FullPath())
+		if !ok {
+			s.log.Error("route has no permission", zap.String("method", c.Request.Method), zap.String("path", c.FullPath()))
+			handlers.Abort(c, http.StatusForbidden, handlers.CodeForbidden, "route is not authorized")
+			return
+		}
+		role, _ := c.Get("role")
+		roleName, _ := role.(string)
+		if c.GetBool("instance_operator") {
+			roleName = authz.RoleInstance
+		}
+		if !authz.Allows(roleName, perm) {
+			handlers.Abort(c, http.StatusForbidden, handlers.CodeForbidden,
+				fmt.Sprintf("%s requires the %s role", perm, authz.Role(perm)))
+			return
+		}
 		c.Next()
 	}
 }
+
+// auditImpersonated records every request made with an impersonation token
+// once its handler has run.
+func (s *Server) auditImpersonated(h *handlers.ImpersonationHandler) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		c.Next()
+		h.RecordImpersonatedRequest(c)
+	}
+}
+
+// auditBreakGlass records every request made with a break-glass token once
+// its handler has run.
+func (s *Server) auditBreakGlass(h *handlers.BreakGlassHandler) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		c.Next()
+		h.RecordBreakGlassRequest(c)
+	}
+}
+
+// scimAuth admits only requests carrying the configured SCIM token.
+func (s *Server) scimAuth() gin.HandlerFunc {
+	want := []byte("Bearer " + s.scimCfg.Token)
+	return func(c *gin.Context) {
+		got := []byte(c.GetHeader("Authorization"))
+		if s.scimCfg.Token == "" || subtle.ConstantTimeCompare(got, want) != 1 {
+			c.Header("WWW-Authenticate", `Bearer realm="scim"`)
+			c.AbortWithStatus(http.StatusUnauthorized)
+			return
+		}
+		c.Next()
+	}
+}
+
+// setupGuard admits holders of the setup token only. A loopback address
+// proves nothing: behind a reverse proxy on the same host, every request
+// arrives from loopback, whoever sent it.
+func setupGuard(wizard *setup.Wizard) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if wizard.CheckToken(c.GetHeader("X-Setup-Token")) {
+			c.Next()
+			return
+		}
+		handlers.Abort(c, http.StatusForbidden, handlers.CodeForbidden, "setup requires the setup token in X-Setup-Token")
+	}
+}
+
+// freezeGuard refuses dataplane changes while a freeze window of the
+// caller's tenant is open. Break-glass access is exempt.
+func (s *Server) freezeGuard() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if c.GetBool("break_glass") {
+			c.Next()
+			return
+		}
+		tenantID, _ := c.Get("tenant_id")
+		id, _ := tenantID.(uuid.UUID)
+		w, err := s.freezes.Active(c.Request.Context(), id)
+		if err != nil {
+			s.log.Error("check change freeze", zap.Error(err))
+			handlers.Abort(c, http.StatusInternalServerError, handlers.CodeInternal, "failed to check change freeze")
+			return
+		}
+		if w != nil {
+			until := w.EndsAt.UTC().Format(time.RFC3339)
+			handlers.Abort(c, http.StatusLocked, handlers.CodeChangeFrozen,
+				"changes are frozen until "+until+": "+w.Reason,
+				"reason: "+w.Reason, "endsAt: "+until)
+			return
+		}
+		c.Next()
+	}
+}
+
+// readOnlyExempt are the routes that pass readOnlyGuard whatever their
+// method: those that change nothing, and the switch itself.
+var readOnlyExempt = map[string]bool{
+	"/api/v1/system/read-only":            true,
+	"/api/v1/firewall/test":               true,
+	"/api/v1/firewall/simulate":           true,
+	"/api/v1/policies/validate":           true,
+	"/api/v1/policies/preview":            true,
+	"/api/v1/policies/validate-schema":    true,
+	"/api/v1/vpn/peers/:id/mtu-probe":     true,
+	"/api/v1/system/backups/:name/verify": true,
+	"/api/v1/graphql":                     true,
+}
+
+// readOnlyGuard refuses mutating requests with 503 while the instance is
+// configured read-only or a read-only switch covers the caller's tenant.
+// Break-glass access is not exempt: a standby must not take writes.
+func (s *Server) readOnlyGuard() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		switch c.Request.Method {
+		case http.MethodGet, http.MethodHead, http.MethodOptions:
+			c.Next()
+			return
+		}
+		if readOnlyExempt[c.FullPath()] {
+			c.Next()
+			return
+		}
+		if s.cfg.ReadOnly {
+			reason := handlers.ConfigReadOnlyReason(s.cfg)
+			handlers.Abort(c, http.StatusServiceUnavailable, handlers.CodeReadOnly,
+				"the API is read-only: "+reason, "reason: "+reason, "scope: "+handlers.ReadOnlyGlobal)
+			return
+		}
+		tenantID, _ := c.Get("tenant_id")
+		id, _ := tenantID.(uuid.UUID)
+		sw, err := s.readOnly.Active(c.Request.Context(), id)
+		if err != nil {
+			s.log.Error("check read-only mode", zap.Error(err))
+			handlers.Abort(c, http.StatusInternalServerError, handlers.CodeInternal, "failed to check read-only mode")
+			return
+		}
+		if sw != nil {
+			scope, what := handlers.ReadOnlyGlobal, "the API"
+			if sw.TenantID != nil {
+				scope, what = handlers.ReadOnlyTenant, "the tenant"
+			}
+			handlers.Abort(c, http.StatusServiceUnavailable, handlers.CodeReadOnly,
+				what+" is read-only: "+sw.Reason, "reason: "+sw.Reason, "scope: "+scope)
+			return
+		}
+		c.Next()
+	}
+}
+
+// requireFeature rejects requests to routes whose feature is not available.
+func (s *Server) requireFeature(f features.Feature) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if !s.features.Enabled(f) {
+			handlers.Abort(c, http.StatusForbidden, handlers.CodeFeatureDisabled, fmt.Sprintf("feature %q is not enabled", f))
+			return
+		}
+		c.Next()
+	}
+}
+
+// portalMiddleware admits only VPN portal tokens and binds the request to
+// the token's peer.
+func (s *Server) portalMiddleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		claims, ok := s.bearerClaims(c)
+		if !ok {
+			return
+		}
+		if claims.Scope != auth.ScopeVPNPortal || claims.PeerID == nil {
+			handlers.Abort(c, http.StatusForbidden, handlers.CodeForbidden, "portal token required")
+			return
+		}
+
+		c.Set("tenant_id", claims.TenantID)
+		c.Set("peer_id", *claims.PeerID)
+		c.Next()
+	}
+}
+
+// bearerClaims validates the Authorization header, aborting with 401 on failure.
+func (s *Server) bearerClaims(c *gin.Context) (*auth.Claims, bool) {
+	token := c.GetHeader("Authorization")
+	if token == "" {
+		handlers.Abort(c, http.StatusUnauthorized, handlers.CodeUnauthenticated, "missing authorization header")
+		return nil, false
+	}
+	// Strip "Bearer " prefix
+	if len(token) > 7 && token[:7] == "Bearer " {
+		token = token[7:]
+	}
+
+	claims, err := s.authSvc.ValidateToken(token)
+	if err != nil {
+		handlers.Abort(c, http.StatusUnauthorized, handlers.CodeUnauthenticated, "invalid token")
+		return nil, false
+	}
+	// Every tenant-scoped query keys on the token's tenant; a token without
+	// one would read and write under the nil UUID.
+	if claims.TenantID == uuid.Nil {
+		handlers.Abort(c, http.StatusUnauthorized, handlers.CodeUnauthenticated, "token has no tenant")
+		return nil, false
+	}
+	return claims, true
+}
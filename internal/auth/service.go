@@ -8,16 +8,60 @@ import (
 	"github.com/golang-jwt/jwt/v5"
 	"github.com/google/uuid"
 	"golang.org/x/crypto/bcrypt"
+
+	"github.com/aegisx/aegisx/internal/store"
 )
 
+// ScopeVPNPortal marks a token that may only use the VPN self-service
+// portal endpoints for the single peer named in Claims.PeerID.
+const ScopeVPNPortal = "vpn-portal"
+
+// ScopeMFA marks a short-lived token proving the password step of a login
+// whose second factor (a passkey) is still outstanding.
+const ScopeMFA = "mfa"
+
+const mfaTokenTTL = 5 * time.Minute
+
+// sessionTouchEvery is how often the last use of a login session is
+// recorded; an idle timeout shorter than ten times this is tracked more
+// finely.
+const sessionTouchEvery = time.Minute
+
+// MaxImpersonationTTL caps how long an impersonation token is valid.
+const MaxImpersonationTTL = 4 * time.Hour
+
 // Claims are embedded in JWT tokens.
 type Claims struct {
 	UserID   uuid.UUID `json:"uid"`
 	TenantID uuid.UUID `json:"tid"`
 	Role     string    `json:"role"`
+	// Scope is empty for full API tokens and set for limited-scope tokens.
+	Scope  string     `json:"scope,omitempty"`
+	PeerID *uuid.UUID `json:"pid,omitempty"`
+	// ImpersonatorID is set on tokens an admin obtained to act inside
+	// another tenant; the registered ID claim holds the session ID.
+	ImpersonatorID *uuid.UUID `json:"imp,omitempty"`
+	// Provisioned marks tokens of IdP-managed users, which are re-checked
+	// against the directory on every request.
+	Provisioned bool `json:"prv,omitempty"`
+	// BreakGlass marks tokens of emergency access, which may change the
+	// dataplane during a change freeze.
+	BreakGlass bool `json:"bg,omitempty"`
+	// SessionID is the login session the token was issued for, when
+	// sessions are tracked; ending the session revokes the token.
+	SessionID *uuid.UUID `json:"sid,omitempty"`
 	jwt.RegisteredClaims
 }
 
+// Identity is an authenticated user.
+type Identity struct {
+	UserID      uuid.UUID
+	TenantID    uuid.UUID
+	Username    string
+	Role        string
+	Provisioned bool // managed through SCIM or LDAP
+}
+
 // TokenPair holds access + refresh tokens.
 type TokenPair struct {
 	AccessToken  string
@@ -28,12 +72,29 @@ type TokenPair struct {
 
 // Service provides authentication primitives.
 type Service struct {
-	jwtSecret  []byte
-	jwtExpiry  time.Duration
-	adminUser  string
-	adminHash  string // bcrypt
-	adminID    uuid.UUID
-	tenantID   uuid.UUID
+	jwtSecret []byte
+	jwtExpiry time.Duration
+	adminUser string
+	adminHash string // bcrypt
+	adminID   uuid.UUID
+	tenantID  uuid.UUID
+	users     *store.UserStore // nil unless SCIM or LDAP is enabled
+	ldap      PasswordDirectory
+
+	sessions *store.LoginSessionStore // nil unless sessions are tracked
+	session  SessionConfig
+}
+
+// SessionConfig limits login sessions. A zero field means no limit.
+type SessionConfig struct {
+	IdleTimeout time.Duration // end sessions unused for this long
+	MaxPerUser  int           // evict the oldest sessions beyond this many
+}
+
+// PasswordDirectory checks passwords against an external directory, such
+// as LDAP, and returns the user record it created or updated for the user.
+type PasswordDirectory interface {
+	Authenticate(ctx context.Context, username, password string) (*store.UserRecord, error)
 }
 
 type Config struct {
@@ -41,6 +102,9 @@ type Config struct {
 	JWTExpiry     time.Duration
 	AdminUser     string
 	AdminPassword string
+	// AdminPasswordHash is a bcrypt hash of the admin password, as written
+	// by the setup wizard. It takes precedence over AdminPassword.
+	AdminPasswordHash string
 }
 
 func NewService(cfg Config) (*Service, error) {
@@ -48,41 +112,240 @@ func NewService(cfg Config) (*Service, error) {
 		return nil, fmt.Errorf("jwt_secret is required")
 	}
 
-	hash, err := bcrypt.GenerateFromPassword([]byte(cfg.AdminPassword), bcrypt.DefaultCost)
-	if err != nil {
-		return nil, fmt.Errorf("hash admin password: %w", err)
+	hash := []byte(cfg.AdminPasswordHash)
+	if len(hash) > 0 {
+		if _, err := bcrypt.Cost(hash); err != nil {
+			return nil, fmt.Errorf("admin_password_hash: %w", err)
+		}
+	} else {
+		var err error
+		hash, err = bcrypt.GenerateFromPassword([]byte(cfg.AdminPassword), bcrypt.DefaultCost)
+		if err != nil {
+			return nil, fmt.Errorf("hash admin password: %w", err)
+		}
 	}
 
+	// The admin ID is derived from the name so that it is stable across
+	// restarts: stored passkeys and audit entries refer to it.
 	return &Service{
-		jwtSecret:  []byte(cfg.JWTSecret),
-		jwtExpiry:  cfg.JWTExpiry,
-		adminUser:  cfg.AdminUser,
-		adminHash:  string(hash),
-		adminID:    uuid.New(),
-		tenantID:   uuid.MustParse("00000000-0000-0000-0000-000000000001"),
+		jwtSecret: []byte(cfg.JWTSecret),
+		jwtExpiry: cfg.JWTExpiry,
+		adminUser: cfg.AdminUser,
+		adminHash: string(hash),
+		adminID:   uuid.NewSHA1(uuid.NameSpaceOID, []byte("aegisx:user:"+cfg.AdminUser)),
+		tenantID:  uuid.MustParse("00000000-0000-0000-0000-000000000001"),
 	}, nil
 }
 
+// UseDirectory makes users provisioned through SCIM or LDAP known to the
+// service.
+func (s *Service) UseDirectory(users *store.UserStore) { s.users = users }
+
+// UseSessions records every login as a session in sessions, so that
+// sessions unused for cfg.IdleTimeout expire, signing in more than
+// cfg.MaxPerUser times ends the oldest sessions, and logging out revokes
+// the session's tokens.
+func (s *Service) UseSessions(sessions *store.LoginSessionStore, cfg SessionConfig) {
+	s.sessions, s.session = sessions, cfg
+}
+
+// UseLDAP checks the passwords of everyone but the admin against dir rather
+// than the local credentials.
+func (s *Service) UseLDAP(dir PasswordDirectory) { s.ldap = dir }
+
 // Login validates credentials and returns a token pair.
-func (s *Service) Login(_ context.Context, username, password string) (*TokenPair, error) {
-	// Bootstrap admin user — in production, look up from DB.
-	if username != s.adminUser {
-		return nil, fmt.Errorf("user not found")
+func (s *Service) Login(ctx context.Context, username, password string) (*TokenPair, error) {
+	id, err := s.Authenticate(ctx, username, password)
+	if err != nil {
+		return nil, err
+	}
+	return s.IssueTokens(ctx, id)
+}
+
+// Authenticate validates credentials without issuing tokens.
+func (s *Service) Authenticate(ctx context.Context, username, password string) (*Identity, error) {
+	if s.ldap != nil && username != s.adminUser {
+		u, err := s.ldap.Authenticate(ctx, username, password)
+		if err != nil {
+			return nil, err
+		}
+		return provisioned(u)
+	}
+	id, err := s.Lookup(ctx, username)
+	if err != nil {
+		return nil, err
+	}
+	// Provisioned users have no password; they sign in with a passkey.
+	if id.Provisioned {
+		return nil, fmt.Errorf("password login not available for provisioned users")
 	}
 	if err := bcrypt.CompareHashAndPassword([]byte(s.adminHash), []byte(password)); err != nil {
 		return nil, fmt.Errorf("invalid password")
 	}
+	return id, nil
+}
+
+// Lookup returns the active user with the given name.
+func (s *Service) Lookup(ctx context.Context, username string) (*Identity, error) {
+	if username == s.adminUser {
+		return s.admin(), nil
+	}
+	if s.users == nil {
+		return nil, fmt.Errorf("user not found")
+	}
+	u, err := s.users.GetByUsername(ctx, username)
+	if err != nil {
+		return nil, err
+	}
+	return provisioned(u)
+}
+
+// LookupID returns the active user with the given ID.
+func (s *Service) LookupID(ctx context.Context, userID uuid.UUID) (*Identity, error) {
+	if userID == s.adminID {
+		return s.admin(), nil
+	}
+	if s.users == nil {
+		return nil, fmt.Errorf("user not found")
+	}
+	u, err := s.users.Get(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	return provisioned(u)
+}
+
+// CheckActive rejects tokens of provisioned users that have since been
+// deactivated, deleted or moved to another tenant or role.
+func (s *Service) CheckActive(ctx context.Context, claims *Claims) error {
+	if !claims.Provisioned {
+		return nil
+	}
+	id, err := s.LookupID(ctx, claims.UserID)
+	if err != nil {
+		return err
+	}
+	if id.TenantID != claims.TenantID || id.Role != claims.Role {
+		return fmt.Errorf("user's tenant or role has changed")
+	}
+	return nil
+}
+
+// IssueTokens returns a token pair for an authenticated user, starting a
+// new session when sessions are tracked.
+func (s *Service) IssueTokens(ctx context.Context, id *Identity) (*TokenPair, error) {
+	sid, err := s.startSession(ctx, id.UserID, id.TenantID)
+	if err != nil {
+		return nil, err
+	}
+	return s.issueTokenPair(id.UserID, id.TenantID, id.Role, id.Provisioned, sid)
+}
+
+// CheckSession rejects tokens whose session was ended by a logout or an
+// eviction, or has been unused for longer than the idle timeout, and
+// otherwise records the use. Tokens issued while sessions were not tracked
+// carry none and pass.
+func (s *Service) CheckSession(ctx context.Context, claims *Claims) error {
+	if s.sessions == nil || claims.SessionID == nil {
+		return nil
+	}
+	sess, err := s.sessions.Get(ctx, *claims.SessionID)
+	if err != nil || !sess.Active() || sess.UserID != claims.UserID {
+		return fmt.Errorf("session has ended")
+	}
+	idle := time.Since(sess.LastSeenAt)
+	if t := s.session.IdleTimeout; t > 0 && idle > t {
+		if err := s.sessions.End(ctx, sess.ID, store.SessionIdle); err != nil {
+			return err
+		}
+		return fmt.Errorf("session expired after %s of inactivity", t)
+	}
+	every := sessionTouchEvery
+	if t := s.session.IdleTimeout; t > 0 && t/10 < every {
+		every = t / 10
+	}
+	if idle >= every {
+		return s.sessions.Touch(ctx, sess.ID)
+	}
+	return nil
+}
+
+// Logout ends the session of token, if it has one. Tokens that do not
+// parse are ignored: there is nothing to revoke.
+func (s *Service) Logout(ctx context.Context, token string) error {
+	if s.sessions == nil {
+		return nil
+	}
+	claims, err := s.parseToken(token)
+	if err != nil || claims.SessionID == nil {
+		return nil
+	}
+	return s.sessions.End(ctx, *claims.SessionID, store.SessionLogout)
+}
+
+// IssueMFAToken signs a token that only proves the password step for id.
+func (s *Service) IssueMFAToken(id *Identity) (string, error) {
+	now := time.Now()
+	claims := &Claims{
+		UserID:   id.UserID,
+		TenantID: id.TenantID,
+		Scope:    ScopeMFA,
+		RegisteredClaims: jwt.RegisteredClaims{
+			Subject:   id.UserID.String(),
+			IssuedAt:  jwt.NewNumericDate(now),
+			ExpiresAt: jwt.NewNumericDate(now.Add(mfaTokenTTL)),
+			Issuer:    "aegisx",
+		},
+	}
+	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
+	return token.SignedString(s.jwtSecret)
+}
 
-	return s.issueTokenPair(s.adminID, s.tenantID, "admin")
+// ValidateMFAToken returns the user whose password step the token proves.
+func (s *Service) ValidateMFAToken(ctx context.Context, token string) (*Identity, error) {
+	claims, err := s.parseToken(token)
+	if err != nil {
+		return nil, err
+	}
+	if claims.Scope != ScopeMFA {
+		return nil, fmt.Errorf("not an mfa token")
+	}
+	return s.LookupID(ctx, claims.UserID)
 }
 
 // RefreshToken issues a new access token from a valid refresh token.
-func (s *Service) RefreshToken(_ context.Context, refreshToken string) (*TokenPair, error) {
+func (s *Service) RefreshToken(ctx context.Context, refreshToken string) (*TokenPair, error) {
 	claims, err := s.parseToken(refreshToken)
 	if err != nil {
 		return nil, fmt.Errorf("invalid refresh token: %w", err)
 	}
-	return s.issueTokenPair(claims.UserID, claims.TenantID, claims.Role)
+	if claims.Scope != "" {
+		return nil, fmt.Errorf("scoped tokens cannot be refreshed")
+	}
+	if claims.ImpersonatorID != nil {
+		return nil, fmt.Errorf("impersonation tokens cannot be refreshed")
+	}
+	if claims.BreakGlass {
+		return nil, fmt.Errorf("break-glass tokens cannot be refreshed")
+	}
+	if err := s.CheckSession(ctx, claims); err != nil {
+		return nil, err
+	}
+	id := &Identity{UserID: claims.UserID, TenantID: claims.TenantID, Role: claims.Role}
+	if claims.Provisioned {
+		// Pick up deprovisioning and group changes.
+		if id, err = s.LookupID(ctx, claims.UserID); err != nil {
+			return nil, err
+		}
+	}
+	// A token from before sessions were tracked starts one.
+	sid := claims.SessionID
+	if sid == nil && s.sessions != nil {
+		if sid, err = s.startSession(ctx, id.UserID, id.TenantID); err != nil {
+			return nil, err
+		}
+	}
+	return s.issueTokenPair(id.UserID, id.TenantID, id.Role, id.Provisioned, sid)
 }
 
 // ValidateToken parses and validates a JWT, returning its claims.
@@ -90,6 +353,78 @@ func (s *Service) ValidateToken(tokenStr string) (*Claims, error) {
 	return s.parseToken(tokenStr)
 }
 
+// IssuePortalToken signs a limited-scope token that lets an end user manage
+// only their own VPN peer. It carries no role and cannot be refreshed.
+func (s *Service) IssuePortalToken(tenantID, peerID uuid.UUID, ttl time.Duration) (string, error) {
+	if ttl <= 0 {
+		ttl = 7 * 24 * time.Hour
+	}
+	now := time.Now()
+	claims := &Claims{
+		TenantID: tenantID,
+		Scope:    ScopeVPNPortal,
+		PeerID:   &peerID,
+		RegisteredClaims: jwt.RegisteredClaims{
+			Subject:   peerID.String(),
+			IssuedAt:  jwt.NewNumericDate(now),
+			ExpiresAt: jwt.NewNumericDate(now.Add(ttl)),
+			Issuer:    "aegisx",
+		},
+	}
+	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
+	return token.SignedString(s.jwtSecret)
+}
+
+// IssueImpersonationToken signs a full-API token for tenantID with the given
+// role on behalf of actorID. It is bound to sessionID, expires at expiresAt
+// (at most MaxImpersonationTTL away) and cannot be refreshed.
+func (s *Service) IssueImpersonationToken(actorID, tenantID, sessionID uuid.UUID, role string, expiresAt time.Time) (string, error) {
+	now := time.Now()
+	if !expiresAt.After(now) || expiresAt.Sub(now) > MaxImpersonationTTL {
+		return "", fmt.Errorf("impersonation must end within %s", MaxImpersonationTTL)
+	}
+	claims := &Claims{
+		UserID:         actorID,
+		TenantID:       tenantID,
+		Role:           role,
+		ImpersonatorID: &actorID,
+		RegisteredClaims: jwt.RegisteredClaims{
+			ID:        sessionID.String(),
+			Subject:   actorID.String(),
+			IssuedAt:  jwt.NewNumericDate(now),
+			ExpiresAt: jwt.NewNumericDate(expiresAt),
+			Issuer:    "aegisx",
+		},
+	}
+	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
+	return token.SignedString(s.jwtSecret)
+}
+
+// IssueBreakGlassToken signs an admin token for userID bound to the
+// break-glass session sessionID. It expires with the session and cannot be
+// refreshed.
+func (s *Service) IssueBreakGlassToken(userID, tenantID, sessionID uuid.UUID, expiresAt time.Time) (string, error) {
+	now := time.Now()
+	if !expiresAt.After(now) {
+		return "", fmt.Errorf("break-glass session has expired")
+	}
+	claims := &Claims{
+		UserID:     userID,
+		TenantID:   tenantID,
+		Role:       "admin",
+		BreakGlass: true,
+		RegisteredClaims: jwt.RegisteredClaims{
+			ID:        sessionID.String(),
+			Subject:   userID.String(),
+			IssuedAt:  jwt.NewNumericDate(now),
+			ExpiresAt: jwt.NewNumericDate(expiresAt),
+			Issuer:    "aegisx",
+		},
+	}
+	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
+	return token.SignedString(s.jwtSecret)
+}
+
 // HashPassword returns a bcrypt hash of the plaintext password.
 func HashPassword(password string) (string, error) {
 	h, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
@@ -103,19 +438,72 @@ func CheckPassword(password, hash string) bool {
 
 // ─── Private helpers ──────────────────────────────────────────────────────
 
-func (s *Service) issueTokenPair(userID, tenantID uuid.UUID, role string) (*TokenPair, error) {
-	expiry := s.jwtExpiry
-	if expiry == 0 {
-		expiry = 24 * time.Hour
+// IsInstanceOperator reports whether claims belong to the instance
+// operator: the admin account of the server config, signed in as itself.
+// Tenant users never are, whatever their role, and neither are
+// impersonation, break-glass or limited-scope tokens.
+func (s *Service) IsInstanceOperator(claims *Claims) bool {
+	return claims.UserID == s.adminID && claims.TenantID == s.tenantID && !claims.Provisioned &&
+		claims.ImpersonatorID == nil && !claims.BreakGlass && claims.Scope == ""
+}
+
+// provisioned turns a directory user into an Identity; users without a
+// role (in no mapped group) have no access.
+func provisioned(u *store.UserRecord) (*Identity, error) {
+	if !u.Active || u.Role == "" {
+		return nil, fmt.Errorf("user is not active")
 	}
+	return &Identity{UserID: u.ID, TenantID: u.TenantID, Username: u.Username, Role: u.Role, Provisioned: true}, nil
+}
 
-	accessToken, err := s.signToken(userID, tenantID, role, expiry)
+func (s *Service) admin() *Identity {
+	return &Identity{UserID: s.adminID, TenantID: s.tenantID, Username: s.adminUser, Role: "admin"}
+}
+
+// startSession records a new session for userID and ends its oldest
+// sessions beyond the limit. It returns nil when sessions are not tracked.
+func (s *Service) startSession(ctx context.Context, userID, tenantID uuid.UUID) (*uuid.UUID, error) {
+	if s.sessions == nil {
+		return nil, nil
+	}
+	sess := &store.LoginSession{UserID: userID, TenantID: tenantID, ExpiresAt: time.Now().Add(s.refreshExpiry())}
+	if err := s.sessions.Create(ctx, sess); err != nil {
+		return nil, err
+	}
+	if s.session.MaxPerUser > 0 {
+		var idleSince time.Time
+		if t := s.session.IdleTimeout; t > 0 {
+			idleSince = time.Now().Add(-t)
+		}
+		if _, err := s.sessions.EvictOldest(ctx, userID, s.session.MaxPerUser, idleSince); err != nil {
+			return nil, err
+		}
+	}
+	return &sess.ID, nil
+}
+
+func (s *Service) accessExpiry() time.Duration {
+	if s.jwtExpiry == 0 {
+		return 24 * time.Hour
+	}
+	return s.jwtExpiry
+}
+
+// refreshExpiry is the lifetime of refresh tokens, 7× that of access
+// tokens.
+func (s *Service) refreshExpiry() time.Duration {
+	return s.accessExpiry() * 7
+}
+
+func (s *Service) issueTokenPair(userID, tenantID uuid.UUID, role string, prov bool, sid *uuid.UUID) (*TokenPair, error) {
+	expiry := s.accessExpiry()
+
+	accessToken, err := s.signToken(userID, tenantID, role, prov, sid, expiry)
 	if err != nil {
 		return nil, err
 	}
 
-	// Refresh token lives 7× longer.
-	refreshToken, err := s.signToken(userID, tenantID, role, expiry*7)
+	refreshToken, err := s.signToken(userID, tenantID, role, prov, sid, s.refreshExpiry())
 	if err != nil {
 		return nil, err
 	}
@@ -128,12 +516,14 @@ func (s *Service) issueTokenPair(userID, tenantID uuid.UUID, role string) (*Toke
 	}, nil
 }
 
-func (s *Service) signToken(userID, tenantID uuid.UUID, role string, expiry time.Duration) (string, error) {
+func (s *Service) signToken(userID, tenantID uuid.UUID, role string, prov bool, sid *uuid.UUID, expiry time.Duration) (string, error) {
 	now := time.Now()
 	claims := &Claims{
-		UserID:   userID,
-		TenantID: tenantID,
-		Role:     role,
+		UserID:      userID,
+		TenantID:    tenantID,
+		Role:        role,
+		Provisioned: prov,
+		SessionID:   sid,
 		RegisteredClaims: jwt.RegisteredClaims{
 			IssuedAt:  jwt.NewNumericDate(now),
 			ExpiresAt: jwt.NewNumericDate(now.Add(expiry)),
@@ -0,0 +1,255 @@
+package auth
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/go-webauthn/webauthn/protocol"
+	"github.com/go-webauthn/webauthn/webauthn"
+	"github.com/google/uuid"
+
+	"github.com/aegisx/aegisx/internal/store"
+)
+
+// ceremonyTTL bounds how long a registration or login may take between its
+// begin and finish calls.
+const ceremonyTTL = 5 * time.Minute
+
+// WebAuthnConfig configures passkey logins.
+type WebAuthnConfig struct {
+	RPID          string   // the dashboard's host name, e.g. fw.example.com
+	RPDisplayName string   // shown by the authenticator
+	RPOrigins     []string // e.g. https://fw.example.com
+	// SecondFactor requires users with a passkey to present it after their
+	// password; otherwise a passkey replaces the password.
+	SecondFactor bool
+}
+
+// Passkeys runs WebAuthn registration and login ceremonies. Pending
+// ceremonies are held in memory, so begin and finish must reach the same
+// API instance.
+type Passkeys struct {
+	svc          *Service
+	wa           *webauthn.WebAuthn
+	store        *store.WebAuthnStore
+	secondFactor bool
+
+	mu         sync.Mutex
+	ceremonies map[string]*ceremony
+}
+
+type ceremony struct {
+	userID  uuid.UUID
+	session webauthn.SessionData
+	expires time.Time
+}
+
+func NewPasskeys(svc *Service, cfg WebAuthnConfig, st *store.WebAuthnStore) (*Passkeys, error) {
+	if cfg.RPID == "" || len(cfg.RPOrigins) == 0 {
+		return nil, fmt.Errorf("webauthn: rp_id and rp_origins are required")
+	}
+	wa, err := webauthn.New(&webauthn.Config{
+		RPID:          cfg.RPID,
+		RPDisplayName: cfg.RPDisplayName,
+		RPOrigins:     cfg.RPOrigins,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("webauthn: %w", err)
+	}
+	return &Passkeys{
+		svc:          svc,
+		wa:           wa,
+		store:        st,
+		secondFactor: cfg.SecondFactor,
+		ceremonies:   make(map[string]*ceremony),
+	}, nil
+}
+
+// SecondFactor reports whether passkeys are required after the password.
+func (p *Passkeys) SecondFactor() bool { return p.secondFactor }
+
+// Registered reports whether the user has at least one passkey.
+func (p *Passkeys) Registered(ctx context.Context, userID uuid.UUID) (bool, error) {
+	creds, err := p.store.List(ctx, userID)
+	return len(creds) > 0, err
+}
+
+// Credentials returns the user's passkeys.
+func (p *Passkeys) Credentials(ctx context.Context, userID uuid.UUID) ([]*store.WebAuthnCredential, error) {
+	return p.store.List(ctx, userID)
+}
+
+// RemoveCredential deletes one of the user's passkeys.
+func (p *Passkeys) RemoveCredential(ctx context.Context, userID, id uuid.UUID) error {
+	return p.store.Delete(ctx, userID, id)
+}
+
+// BeginRegistration starts adding a passkey for id. It returns the ceremony
+// ID to pass to FinishRegistration and the options for
+// navigator.credentials.create().
+func (p *Passkeys) BeginRegistration(ctx context.Context, id *Identity) (string, *protocol.CredentialCreation, error) {
+	user, err := p.user(ctx, id)
+	if err != nil {
+		return "", nil, err
+	}
+	exclude := make([]protocol.CredentialDescriptor, len(user.creds))
+	for i, c := range user.creds {
+		exclude[i] = c.Descriptor()
+	}
+	opts, session, err := p.wa.BeginRegistration(user, webauthn.WithExclusions(exclude))
+	if err != nil {
+		return "", nil, err
+	}
+	return p.start(id.UserID, session), opts, nil
+}
+
+// FinishRegistration verifies the authenticator's response in r and stores
+// the new passkey under name.
+func (p *Passkeys) FinishRegistration(ctx context.Context, id *Identity, ceremonyID, name string, r *http.Request) (*store.WebAuthnCredential, error) {
+	cer, err := p.take(ceremonyID, id.UserID)
+	if err != nil {
+		return nil, err
+	}
+	user, err := p.user(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	cred, err := p.wa.FinishRegistration(user, cer.session, r)
+	if err != nil {
+		return nil, fmt.Errorf("verify registration: %w", err)
+	}
+	data, err := json.Marshal(cred)
+	if err != nil {
+		return nil, err
+	}
+	rec := &store.WebAuthnCredential{
+		UserID:       id.UserID,
+		Name:         name,
+		CredentialID: cred.ID,
+		Credential:   data,
+		SignCount:    cred.Authenticator.SignCount,
+	}
+	if err := p.store.Create(ctx, rec); err != nil {
+		return nil, err
+	}
+	return rec, nil
+}
+
+// BeginLogin starts a passkey login for id and returns the ceremony ID and
+// the options for navigator.credentials.get().
+func (p *Passkeys) BeginLogin(ctx context.Context, id *Identity) (string, *protocol.CredentialAssertion, error) {
+	user, err := p.user(ctx, id)
+	if err != nil {
+		return "", nil, err
+	}
+	if len(user.creds) == 0 {
+		return "", nil, fmt.Errorf("no passkey registered")
+	}
+	opts, session, err := p.wa.BeginLogin(user)
+	if err != nil {
+		return "", nil, err
+	}
+	return p.start(id.UserID, session), opts, nil
+}
+
+// FinishLogin verifies the assertion in r and returns the logged-in user.
+func (p *Passkeys) FinishLogin(ctx context.Context, ceremonyID string, r *http.Request) (*Identity, error) {
+	cer, err := p.take(ceremonyID, uuid.Nil)
+	if err != nil {
+		return nil, err
+	}
+	id, err := p.svc.LookupID(ctx, cer.userID)
+	if err != nil {
+		return nil, err
+	}
+	user, err := p.user(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	cred, err := p.wa.FinishLogin(user, cer.session, r)
+	if err != nil {
+		return nil, fmt.Errorf("verify assertion: %w", err)
+	}
+	// A sign counter that failed to advance means the key was cloned.
+	if cred.Authenticator.CloneWarning {
+		return nil, fmt.Errorf("authenticator sign counter went backwards; credential may be cloned")
+	}
+	data, err := json.Marshal(cred)
+	if err != nil {
+		return nil, err
+	}
+	if err := p.store.Touch(ctx, cred.ID, cred.Authenticator.SignCount, data); err != nil {
+		return nil, err
+	}
+	return id, nil
+}
+
+// ─── Private helpers ──────────────────────────────────────────────────────
+
+// webauthnUser adapts an Identity and its stored credentials to the
+// library's User interface.
+type webauthnUser struct {
+	id    *Identity
+	creds []webauthn.Credential
+}
+
+func (u *webauthnUser) WebAuthnID() []byte                         { return u.id.UserID[:] }
+func (u *webauthnUser) WebAuthnName() string                       { return u.id.Username }
+func (u *webauthnUser) WebAuthnDisplayName() string                { return u.id.Username }
+func (u *webauthnUser) WebAuthnCredentials() []webauthn.Credential { return u.creds }
+
+func (p *Passkeys) user(ctx context.Context, id *Identity) (*webauthnUser, error) {
+	recs, err := p.store.List(ctx, id.UserID)
+	if err != nil {
+		return nil, err
+	}
+	u := &webauthnUser{id: id, creds: make([]webauthn.Credential, 0, len(recs))}
+	for _, r := range recs {
+		var c webauthn.Credential
+		if err := json.Unmarshal(r.Credential, &c); err != nil {
+			return nil, fmt.Errorf("decode credential %s: %w", r.ID, err)
+		}
+		u.creds = append(u.creds, c)
+	}
+	return u, nil
+}
+
+func (p *Passkeys) start(userID uuid.UUID, session *webauthn.SessionData) string {
+	buf := make([]byte, 16)
+	_, _ = rand.Read(buf)
+	key := hex.EncodeToString(buf)
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	now := time.Now()
+	for k, c := range p.ceremonies {
+		if now.After(c.expires) {
+			delete(p.ceremonies, k)
+		}
+	}
+	p.ceremonies[key] = &ceremony{userID: userID, session: *session, expires: now.Add(ceremonyTTL)}
+	return key
+}
+
+// take removes and returns a pending ceremony. A non-nil userID must match
+// the user the ceremony was started for.
+func (p *Passkeys) take(key string, userID uuid.UUID) (*ceremony, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	c, ok := p.ceremonies[key]
+	if !ok || time.Now().After(c.expires) {
+		delete(p.ceremonies, key)
+		return nil, fmt.Errorf("unknown or expired ceremony")
+	}
+	if userID != uuid.Nil && c.userID != userID {
+		return nil, fmt.Errorf("unknown or expired ceremony")
+	}
+	delete(p.ceremonies, key)
+	return c, nil
+}
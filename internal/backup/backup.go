@@ -0,0 +1,216 @@
+// Package backup writes the configuration tables to object storage and
+// checks, before anything touches live data, that a backup can be
+// restored: it loads the backup into a scratch schema, checks referential
+// integrity and that the policies still compile, and reports how the
+// restore would change each table.
+package backup
+
+import (
+	"archive/tar"
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"path"
+	"regexp"
+	"strings"
+	"time"
+
+	"go.uber.org/zap"
+
+	"github.com/aegisx/aegisx/internal/blob"
+	"github.com/aegisx/aegisx/internal/store"
+	"github.com/aegisx/aegisx/pkg/version"
+)
+
+const (
+	formatVersion = 1
+	manifestName  = "manifest.json"
+	tablesDir     = "tables/"
+	suffix        = ".tar.gz"
+	loadBatch     = 500 // rows per insert into the scratch schema
+)
+
+var nameRe = regexp.MustCompile(`^[A-Za-z0-9][A-Za-z0-9._-]*\.tar\.gz$`)
+
+// Manifest is the first entry of a backup archive.
+type Manifest struct {
+	Format    int         `json:"format"`
+	CreatedAt time.Time   `json:"createdAt"`
+	Version   string      `json:"version"` // of the aegisx that wrote it
+	Tables    []TableInfo `json:"tables"`
+}
+
+// TableInfo describes one table in a backup; its rows follow as
+// tables/<name>.ndjson.
+type TableInfo struct {
+	Name    string   `json:"name"`
+	Columns []string `json:"columns"`
+	Rows    int64    `json:"rows"`
+}
+
+// Manager creates and verifies backups.
+type Manager struct {
+	store *store.BackupStore
+	blobs blob.Store
+	log   *zap.Logger
+}
+
+// NewManager returns a Manager keeping backups in blobs.
+func NewManager(s *store.BackupStore, blobs blob.Store, log *zap.Logger) *Manager {
+	return &Manager{store: s, blobs: blobs, log: log}
+}
+
+// ValidName reports whether name can name a backup.
+func ValidName(name string) bool {
+	return nameRe.MatchString(name)
+}
+
+// List returns the stored backups, oldest first.
+func (m *Manager) List(ctx context.Context) ([]blob.Object, error) {
+	objs, err := m.blobs.List(ctx, "")
+	if err != nil {
+		return nil, err
+	}
+	out := objs[:0]
+	for _, o := range objs {
+		if ValidName(o.Key) {
+			out = append(out, o)
+		}
+	}
+	return out, nil
+}
+
+// Create writes a backup of every configuration table, read in one
+// snapshot, and returns it with its manifest.
+func (m *Manager) Create(ctx context.Context) (*blob.Object, *Manifest, error) {
+	tables, err := m.store.Tables(ctx)
+	if err != nil {
+		return nil, nil, err
+	}
+	man := &Manifest{Format: formatVersion, CreatedAt: time.Now().UTC(), Version: version.Version}
+	data := make(map[string]*bytes.Buffer, len(tables))
+	for _, t := range tables {
+		cols, err := m.store.Columns(ctx, "public", t)
+		if err != nil {
+			return nil, nil, err
+		}
+		man.Tables = append(man.Tables, TableInfo{Name: t, Columns: cols})
+		data[t] = &bytes.Buffer{}
+	}
+	rows := make(map[string]int64, len(tables))
+	err = m.store.Dump(ctx, tables, func(table string, row json.RawMessage) error {
+		rows[table]++
+		data[table].Write(row)
+		return data[table].WriteByte('\n')
+	})
+	if err != nil {
+		return nil, nil, err
+	}
+	for i := range man.Tables {
+		man.Tables[i].Rows = rows[man.Tables[i].Name]
+	}
+
+	var buf bytes.Buffer
+	zw := gzip.NewWriter(&buf)
+	tw := tar.NewWriter(zw)
+	head, err := json.MarshalIndent(man, "", "  ")
+	if err != nil {
+		return nil, nil, err
+	}
+	if err := writeEntry(tw, manifestName, head, man.CreatedAt); err != nil {
+		return nil, nil, err
+	}
+	for _, t := range man.Tables {
+		if err := writeEntry(tw, tablesDir+t.Name+".ndjson", data[t.Name].Bytes(), man.CreatedAt); err != nil {
+			return nil, nil, err
+		}
+	}
+	if err := tw.Close(); err != nil {
+		return nil, nil, err
+	}
+	if err := zw.Close(); err != nil {
+		return nil, nil, err
+	}
+
+	key := "aegisx-" + man.CreatedAt.Format("20060102T150405Z") + suffix
+	size := int64(buf.Len())
+	if err := m.blobs.Put(ctx, key, &buf, size); err != nil {
+		return nil, nil, fmt.Errorf("store backup: %w", err)
+	}
+	m.log.Info("backup created", zap.String("name", key), zap.Int64("bytes", size), zap.Int("tables", len(man.Tables)))
+	return &blob.Object{Key: key, Size: size, ModTime: man.CreatedAt}, man, nil
+}
+
+func writeEntry(tw *tar.Writer, name string, data []byte, at time.Time) error {
+	hdr := &tar.Header{Name: name, Mode: 0o640, Size: int64(len(data)), ModTime: at, Typeflag: tar.TypeReg}
+	if err := tw.WriteHeader(hdr); err != nil {
+		return err
+	}
+	_, err := tw.Write(data)
+	return err
+}
+
+// archive reads a backup entry by entry; the manifest has been read by the
+// time openArchive returns.
+type archive struct {
+	body io.Closer
+	zr   *gzip.Reader
+	tr   *tar.Reader
+	man  *Manifest
+}
+
+func (m *Manager) openArchive(ctx context.Context, name string) (*archive, error) {
+	body, err := m.blobs.Get(ctx, name)
+	if err != nil {
+		return nil, err
+	}
+	zr, err := gzip.NewReader(body)
+	if err != nil {
+		body.Close()
+		return nil, fmt.Errorf("backup %s: %w", name, err)
+	}
+	a := &archive{body: body, zr: zr, tr: tar.NewReader(zr)}
+	hdr, err := a.tr.Next()
+	if err == nil && hdr.Name != manifestName {
+		err = fmt.Errorf("first entry is %s, not %s", hdr.Name, manifestName)
+	}
+	if err == nil {
+		a.man = &Manifest{}
+		err = json.NewDecoder(a.tr).Decode(a.man)
+	}
+	if err == nil && a.man.Format != formatVersion {
+		err = fmt.Errorf("format %d is not supported", a.man.Format)
+	}
+	if err != nil {
+		a.Close()
+		return nil, fmt.Errorf("backup %s: manifest: %w", name, err)
+	}
+	return a, nil
+}
+
+// next returns the table of the next entry and a reader of its rows, or
+// io.EOF after the last.
+func (a *archive) next() (string, *bufio.Scanner, error) {
+	for {
+		hdr, err := a.tr.Next()
+		if err != nil {
+			return "", nil, err
+		}
+		dir, file := path.Split(hdr.Name)
+		if dir != tablesDir || !strings.HasSuffix(file, ".ndjson") {
+			continue
+		}
+		sc := bufio.NewScanner(a.tr)
+		sc.Buffer(make([]byte, 64*1024), 64<<20)
+		return strings.TrimSuffix(file, ".ndjson"), sc, nil
+	}
+}
+
+func (a *archive) Close() error {
+	a.zr.Close()
+	return a.body.Close()
+}
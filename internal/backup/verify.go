@@ -0,0 +1,302 @@
+package backup
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+
+	"go.uber.org/zap"
+
+	"github.com/aegisx/aegisx/internal/policy"
+	"github.com/aegisx/aegisx/internal/store"
+)
+
+const policiesTable = "policies"
+
+// Report is the outcome of a restore dry-run. OK is set when Problems is
+// empty, i.e. a restore of the backup would succeed; Warnings do not
+// prevent one.
+type Report struct {
+	Backup    string         `json:"backup"`
+	CreatedAt time.Time      `json:"createdAt"`
+	Version   string         `json:"version"`
+	OK        bool           `json:"ok"`
+	Tables    []TableReport  `json:"tables"`
+	Orphans   []Orphan       `json:"orphans,omitempty"`
+	Policies  []TenantReport `json:"policies,omitempty"`
+	Problems  []string       `json:"problems,omitempty"`
+	Warnings  []string       `json:"warnings,omitempty"`
+}
+
+// TableReport is what a restore would do to one table.
+type TableReport struct {
+	Name string `json:"name"`
+	store.TableDiff
+	Error string `json:"error,omitempty"`
+}
+
+// Orphan counts the rows of a backup table whose reference would dangle
+// after a restore.
+type Orphan struct {
+	Constraint string `json:"constraint"`
+	Table      string `json:"table"`
+	RefTable   string `json:"refTable"`
+	Rows       int64  `json:"rows"`
+}
+
+// TenantReport is whether a tenant's policies in the backup compile.
+type TenantReport struct {
+	TenantID string   `json:"tenantId"`
+	Policies int      `json:"policies"`
+	Enabled  int      `json:"enabled"`
+	Rules    int      `json:"rules"` // firewall rules the enabled policies compile to
+	Errors   []string `json:"errors,omitempty"`
+}
+
+// Verify is a restore dry-run of the named backup. It loads the backup into
+// a scratch schema, checks its foreign keys and that each tenant's policies
+// parse and compile together, and compares every table with its live
+// counterpart. Live data is only read; the scratch schema is dropped
+// before Verify returns.
+func (m *Manager) Verify(ctx context.Context, name string) (*Report, error) {
+	a, err := m.openArchive(ctx, name)
+	if err != nil {
+		return nil, err
+	}
+	defer a.Close()
+
+	r := &Report{Backup: name, CreatedAt: a.man.CreatedAt, Version: a.man.Version}
+	live, err := m.store.Tables(ctx)
+	if err != nil {
+		return nil, err
+	}
+	isLive := make(map[string]bool, len(live))
+	for _, t := range live {
+		isLive[t] = true
+	}
+
+	// Columns to load per table: those of the backup that still exist.
+	// Dropped columns lose their data, new ones take their defaults.
+	columns := make(map[string][]string)
+	expected := make(map[string]int64)
+	var tables []string
+	for _, t := range a.man.Tables {
+		if !isLive[t.Name] {
+			r.Problems = append(r.Problems, fmt.Sprintf("table %s no longer exists", t.Name))
+			continue
+		}
+		liveCols, err := m.store.Columns(ctx, "public", t.Name)
+		if err != nil {
+			return nil, err
+		}
+		for _, c := range t.Columns {
+			if contains(liveCols, c) {
+				columns[t.Name] = append(columns[t.Name], c)
+			} else {
+				r.Warnings = append(r.Warnings, fmt.Sprintf("column %s.%s no longer exists; its values would be dropped", t.Name, c))
+			}
+		}
+		expected[t.Name] = t.Rows
+		tables = append(tables, t.Name)
+		delete(isLive, t.Name)
+	}
+	for _, t := range live {
+		if isLive[t] {
+			r.Warnings = append(r.Warnings, fmt.Sprintf("table %s is not in the backup", t))
+		}
+	}
+
+	scratch := fmt.Sprintf("restore_check_%d", time.Now().UnixNano())
+	if err := m.store.CreateScratch(ctx, scratch, tables); err != nil {
+		return nil, err
+	}
+	defer func() {
+		if err := m.store.DropScratch(context.WithoutCancel(ctx), scratch); err != nil {
+			m.log.Error("drop restore scratch schema", zap.String("schema", scratch), zap.Error(err))
+		}
+	}()
+
+	loaded := make(map[string]bool)
+	failed := make(map[string]string)
+	for {
+		table, sc, err := a.next()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("backup %s: %w", name, err)
+		}
+		if _, ok := columns[table]; !ok || loaded[table] {
+			continue
+		}
+		loaded[table] = true
+		n, err := m.load(ctx, scratch, table, columns[table], sc)
+		if err != nil {
+			failed[table] = err.Error()
+			r.Problems = append(r.Problems, err.Error())
+		} else if n != expected[table] {
+			r.Problems = append(r.Problems, fmt.Sprintf("table %s has %d rows, the manifest says %d", table, n, expected[table]))
+		}
+	}
+
+	for _, t := range tables {
+		tr := TableReport{Name: t, Error: failed[t]}
+		if !loaded[t] {
+			tr.Error = "missing from the archive"
+			r.Problems = append(r.Problems, fmt.Sprintf("table %s is in the manifest but not in the archive", t))
+		} else if tr.Error == "" {
+			d, err := m.store.Diff(ctx, scratch, t)
+			if err != nil {
+				return nil, err
+			}
+			tr.TableDiff = *d
+		}
+		r.Tables = append(r.Tables, tr)
+	}
+
+	// Only tables that loaded completely are checked further.
+	usable := make(map[string]bool, len(loaded))
+	for t := range loaded {
+		usable[t] = failed[t] == ""
+	}
+	if err := m.checkReferences(ctx, scratch, usable, r); err != nil {
+		return nil, err
+	}
+	if usable[policiesTable] {
+		if err := m.checkPolicies(ctx, scratch, r); err != nil {
+			return nil, err
+		}
+	}
+
+	r.OK = len(r.Problems) == 0
+	m.log.Info("backup verified", zap.String("name", name), zap.Bool("ok", r.OK),
+		zap.Int("problems", len(r.Problems)), zap.Int("warnings", len(r.Warnings)))
+	return r, nil
+}
+
+// ─── Private helpers ──────────────────────────────────────────────────────
+
+// load inserts the rows of sc into scratch.table in batches and returns how
+// many it read.
+func (m *Manager) load(ctx context.Context, scratch, table string, cols []string, sc *bufio.Scanner) (int64, error) {
+	var n int64
+	batch := make([]json.RawMessage, 0, loadBatch)
+	flush := func() error {
+		err := m.store.LoadScratch(ctx, scratch, table, cols, batch)
+		batch = batch[:0]
+		return err
+	}
+	for sc.Scan() {
+		line := sc.Bytes()
+		if len(line) == 0 {
+			continue
+		}
+		if !json.Valid(line) {
+			return n, fmt.Errorf("table %s: row %d is not valid JSON", table, n+1)
+		}
+		batch = append(batch, append(json.RawMessage(nil), line...))
+		n++
+		if len(batch) == loadBatch {
+			if err := flush(); err != nil {
+				return n, err
+			}
+		}
+	}
+	if err := sc.Err(); err != nil {
+		return n, fmt.Errorf("table %s: %w", table, err)
+	}
+	return n, flush()
+}
+
+// checkReferences counts dangling references from each loaded table. A
+// referenced table that is not loaded is looked up live.
+func (m *Manager) checkReferences(ctx context.Context, scratch string, loaded map[string]bool, r *Report) error {
+	fks, err := m.store.ForeignKeys(ctx)
+	if err != nil {
+		return err
+	}
+	for _, fk := range fks {
+		if !loaded[fk.Table] {
+			continue
+		}
+		ref := "public"
+		if loaded[fk.RefTable] {
+			ref = scratch
+		}
+		n, err := m.store.Orphans(ctx, scratch, ref, fk)
+		if err != nil {
+			return err
+		}
+		if n > 0 {
+			r.Orphans = append(r.Orphans, Orphan{Constraint: fk.Name, Table: fk.Table, RefTable: fk.RefTable, Rows: n})
+			r.Problems = append(r.Problems, fmt.Sprintf("%d rows of %s reference missing %s rows (%s)",
+				n, fk.Table, fk.RefTable, fk.Name))
+		}
+	}
+	return nil
+}
+
+// checkPolicies parses every policy in the backup and compiles each
+// tenant's enabled policies together, as applying them would.
+func (m *Manager) checkPolicies(ctx context.Context, scratch string, r *Report) error {
+	policies, err := m.store.ScratchPolicies(ctx, scratch)
+	if err != nil {
+		return err
+	}
+	parser := policy.NewParser()
+	engine := policy.NewEngine()
+
+	var tenants []*TenantReport
+	enabled := make(map[string][]*policy.Manifest)
+	byTenant := make(map[string]*TenantReport)
+	for _, p := range policies {
+		tr := byTenant[p.TenantID]
+		if tr == nil {
+			tr = &TenantReport{TenantID: p.TenantID}
+			byTenant[p.TenantID] = tr
+			tenants = append(tenants, tr)
+		}
+		tr.Policies++
+		if p.RawYAML == "" {
+			continue
+		}
+		manifests, err := parser.ParseReader(strings.NewReader(p.RawYAML))
+		if err != nil {
+			tr.Errors = append(tr.Errors, fmt.Sprintf("policy %s: %v", p.Name, err))
+			continue
+		}
+		if p.Enabled {
+			tr.Enabled++
+			enabled[p.TenantID] = append(enabled[p.TenantID], manifests...)
+		}
+	}
+	for _, tr := range tenants {
+		if ms := enabled[tr.TenantID]; len(ms) > 0 {
+			ir, err := engine.Compile(ms)
+			if err != nil {
+				tr.Errors = append(tr.Errors, "compile: "+err.Error())
+			} else {
+				tr.Rules = len(ir.FirewallRules)
+			}
+		}
+		for _, e := range tr.Errors {
+			r.Problems = append(r.Problems, fmt.Sprintf("tenant %s: %s", tr.TenantID, e))
+		}
+		r.Policies = append(r.Policies, *tr)
+	}
+	return nil
+}
+
+func contains(list []string, s string) bool {
+	for _, v := range list {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,100 @@
+package ban
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"regexp"
+	"time"
+)
+
+// JailSSH is the jail fed by sshd's log lines.
+const JailSSH = "sshd"
+
+// sshdFailureRe matches the sshd messages that indicate a failed or refused
+// login attempt; the last group is the client address.
+var sshdFailureRe = []*regexp.Regexp{
+	regexp.MustCompile(`sshd\[\d+\]: Failed \S+ for (?:invalid user )?.* from (\S+) port \d+`),
+	regexp.MustCompile(`sshd\[\d+\]: Connection (?:closed|reset) by (?:authenticating|invalid) user .* (\S+) port \d+ \[preauth\]`),
+	regexp.MustCompile(`sshd\[\d+\]: error: maximum authentication attempts exceeded for .* from (\S+) port \d+`),
+	regexp.MustCompile(`sshd\[\d+\]: Unable to negotiate with (\S+) port \d+`),
+}
+
+// ParseSSHD returns the client address of an sshd failure line.
+func ParseSSHD(line string) (string, bool) {
+	for _, re := range sshdFailureRe {
+		if m := re.FindStringSubmatch(line); m != nil {
+			return m[1], true
+		}
+	}
+	return "", false
+}
+
+// TailSSHD follows the auth log at path and records sshd failures in the
+// sshd jail. It starts at the end of the file and reopens it after rotation.
+// Call this in a goroutine; it blocks until ctx is cancelled.
+func (m *Manager) TailSSHD(ctx context.Context, path string) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return fmt.Errorf("open auth log: %w", err)
+	}
+	defer func() { f.Close() }()
+	if _, err := f.Seek(0, io.SeekEnd); err != nil {
+		return err
+	}
+
+	reader := bufio.NewReader(f)
+	var partial []byte
+	ticker := time.NewTicker(time.Second)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-ticker.C:
+		}
+
+		for {
+			chunk, err := reader.ReadBytes('\n')
+			partial = append(partial, chunk...)
+			if err != nil {
+				break
+			}
+			if addr, ok := ParseSSHD(string(partial)); ok {
+				m.Fail(JailSSH, addr)
+			}
+			partial = nil
+		}
+
+		// Reopen when logrotate moved the file away or truncated it.
+		if rotated(f, path) {
+			nf, err := os.Open(path)
+			if err != nil {
+				continue // not recreated yet
+			}
+			f.Close()
+			f = nf
+			reader.Reset(f)
+			partial = nil
+		}
+	}
+}
+
+func rotated(f *os.File, path string) bool {
+	cur, err := f.Stat()
+	if err != nil {
+		return true
+	}
+	onDisk, err := os.Stat(path)
+	if err != nil {
+		return false
+	}
+	if !os.SameFile(cur, onDisk) {
+		return true
+	}
+	pos, err := f.Seek(0, io.SeekCurrent)
+	return err == nil && onDisk.Size() < pos
+}
@@ -0,0 +1,245 @@
+// Package ban implements fail2ban-like protection: authentication failures
+// are counted per source, and sources that fail too often are dropped by an
+// nftables set whose elements expire on their own.
+package ban
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"os/exec"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"go.uber.org/zap"
+
+	"github.com/aegisx/aegisx/internal/metrics"
+	"github.com/aegisx/aegisx/internal/store"
+)
+
+// Set names in the AegisX nftables table; the firewall ruleset declares them
+// and drops their members.
+const (
+	SetV4 = "ban_list"
+	SetV6 = "ban_list6"
+)
+
+// JailAPI is the jail fed by failed API logins.
+const JailAPI = "api"
+
+// repeatWindow is how far back earlier bans count towards escalation.
+const repeatWindow = 24 * time.Hour
+
+// Jail is the ban policy of one source of authentication failures.
+type Jail struct {
+	Name       string
+	MaxRetry   int           // failures within FindTime that trigger a ban
+	FindTime   time.Duration // sliding window for counting failures
+	BanTime    time.Duration // first ban; doubled for each ban in the last 24h
+	MaxBanTime time.Duration // cap for escalated bans; 0 disables escalation
+}
+
+// Manager counts failures and maintains the ban set.
+type Manager struct {
+	mu       sync.Mutex
+	jails    map[string]Jail
+	failures map[string][]time.Time // jail + "|" + address
+	ignore   []*net.IPNet
+	store    *store.BanStore
+	table    string
+	dryRun   bool
+	log      *zap.Logger
+}
+
+func NewManager(s *store.BanStore, table string, ignore []string, dryRun bool, log *zap.Logger) (*Manager, error) {
+	m := &Manager{
+		jails:    make(map[string]Jail),
+		failures: make(map[string][]time.Time),
+		store:    s,
+		table:    table,
+		dryRun:   dryRun,
+		log:      log,
+	}
+	for _, cidr := range ignore {
+		if !strings.Contains(cidr, "/") {
+			if strings.Contains(cidr, ":") {
+				cidr += "/128"
+			} else {
+				cidr += "/32"
+			}
+		}
+		_, n, err := net.ParseCIDR(cidr)
+		if err != nil {
+			return nil, fmt.Errorf("ignore %q: %w", cidr, err)
+		}
+		m.ignore = append(m.ignore, n)
+	}
+	return m, nil
+}
+
+// AddJail registers a ban policy.
+func (m *Manager) AddJail(j Jail) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.jails[j.Name] = j
+}
+
+// Fail records one authentication failure of addr in the named jail and bans
+// the address once the jail's limit is reached.
+func (m *Manager) Fail(jail, addr string) {
+	ip := net.ParseIP(addr)
+	if ip == nil || ip.IsLoopback() || m.ignored(ip) {
+		return
+	}
+	addr = ip.String()
+	metrics.AuthFailuresTotal.WithLabelValues(jail).Inc()
+
+	m.mu.Lock()
+	j, ok := m.jails[jail]
+	if !ok {
+		m.mu.Unlock()
+		return
+	}
+	key := jail + "|" + addr
+	now := time.Now()
+	recent := m.failures[key][:0]
+	for _, t := range m.failures[key] {
+		if now.Sub(t) < j.FindTime {
+			recent = append(recent, t)
+		}
+	}
+	recent = append(recent, now)
+	if len(recent) < j.MaxRetry {
+		m.failures[key] = recent
+		m.mu.Unlock()
+		return
+	}
+	delete(m.failures, key)
+	m.mu.Unlock()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	reason := fmt.Sprintf("%d failures within %s", len(recent), j.FindTime)
+	if _, err := m.ban(ctx, addr, j, reason, len(recent)); err != nil {
+		m.log.Error("ban address", zap.String("address", addr), zap.String("jail", jail), zap.Error(err))
+	}
+}
+
+// Ban bans addr manually for d.
+func (m *Manager) Ban(ctx context.Context, addr string, d time.Duration, reason string) (*store.Ban, error) {
+	ip := net.ParseIP(addr)
+	if ip == nil {
+		return nil, fmt.Errorf("invalid address %q", addr)
+	}
+	return m.ban(ctx, ip.String(), Jail{Name: "manual", BanTime: d}, reason, 0)
+}
+
+// Unban lifts every active ban of addr.
+func (m *Manager) Unban(ctx context.Context, addr string, by *uuid.UUID) ([]*store.Ban, error) {
+	ip := net.ParseIP(addr)
+	if ip == nil {
+		return nil, fmt.Errorf("invalid address %q", addr)
+	}
+	lifted, err := m.store.Lift(ctx, ip.String(), by)
+	if err != nil {
+		return nil, err
+	}
+	if err := m.nft("delete", ip, 0); err != nil && !strings.Contains(err.Error(), "No such file") {
+		return lifted, err
+	}
+	m.log.Info("address unbanned", zap.String("address", ip.String()))
+	return lifted, nil
+}
+
+// Active lists the current bans.
+func (m *Manager) Active(ctx context.Context) ([]*store.Ban, error) {
+	return m.store.ListActive(ctx)
+}
+
+// Restore loads the active bans into the nftables sets, e.g. at startup or
+// after the ruleset was replaced.
+func (m *Manager) Restore(ctx context.Context) error {
+	bans, err := m.store.ListActive(ctx)
+	if err != nil {
+		return err
+	}
+	for _, b := range bans {
+		left := time.Until(b.ExpiresAt)
+		if left <= 0 {
+			continue
+		}
+		if err := m.nft("add", net.ParseIP(b.Address), left); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// ─── Private helpers ──────────────────────────────────────────────────────
+
+func (m *Manager) ban(ctx context.Context, addr string, j Jail, reason string, failures int) (*store.Ban, error) {
+	d := j.BanTime
+	if j.MaxBanTime > 0 {
+		prior, err := m.store.CountSince(ctx, addr, time.Now().Add(-repeatWindow))
+		if err != nil {
+			return nil, err
+		}
+		for i := 0; i < prior && d < j.MaxBanTime; i++ {
+			d *= 2
+		}
+		if d > j.MaxBanTime {
+			d = j.MaxBanTime
+		}
+	}
+
+	b := &store.Ban{
+		Address:   addr,
+		Jail:      j.Name,
+		Reason:    reason,
+		Failures:  failures,
+		ExpiresAt: time.Now().Add(d),
+	}
+	if err := m.nft("add", net.ParseIP(addr), d); err != nil {
+		return nil, err
+	}
+	if err := m.store.Create(ctx, b); err != nil {
+		return nil, err
+	}
+	metrics.BansTotal.WithLabelValues(j.Name).Inc()
+	m.log.Warn("address banned",
+		zap.String("address", addr), zap.String("jail", j.Name),
+		zap.Duration("duration", d), zap.String("reason", reason))
+	return b, nil
+}
+
+func (m *Manager) ignored(ip net.IP) bool {
+	for _, n := range m.ignore {
+		if n.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
+
+// nft adds addr to or deletes it from the ban set of its family.
+func (m *Manager) nft(op string, ip net.IP, timeout time.Duration) error {
+	set := SetV6
+	if ip.To4() != nil {
+		set = SetV4
+	}
+	elem := ip.String()
+	if op == "add" {
+		elem += fmt.Sprintf(" timeout %ds", int(timeout.Seconds())+1)
+	}
+	if m.dryRun {
+		m.log.Info("dry-run: nft "+op+" element", zap.String("set", set), zap.String("element", elem))
+		return nil
+	}
+	out, err := exec.Command("nft", op, "element", "inet", m.table, set, "{ "+elem+" }").CombinedOutput()
+	if err != nil {
+		return fmt.Errorf("nft %s element: %w (output: %s)", op, err, out)
+	}
+	return nil
+}
@@ -0,0 +1,134 @@
+// Package blob is the object storage used for artifacts that outlive the
+// database rows they came from: backups, packet captures, report and
+// retention archives, and policy export bundles. Objects live on local disk
+// or in an S3-compatible bucket (AWS S3, MinIO, Ceph RGW) and are addressed
+// by slash-separated keys.
+package blob
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"path"
+	"strings"
+	"time"
+)
+
+// Backends.
+const (
+	BackendLocal = "local"
+	BackendS3    = "s3"
+)
+
+// Server-side encryption modes for S3.
+const (
+	SSENone = ""
+	SSES3   = "AES256"  // keys managed by S3
+	SSEKMS  = "aws:kms" // keys in KMS; KMSKeyID selects one, or the bucket default
+)
+
+// Object describes a stored object.
+type Object struct {
+	Key     string    `json:"key"`
+	Size    int64     `json:"size"`
+	ModTime time.Time `json:"modTime"`
+}
+
+// Store is a flat namespace of objects.
+type Store interface {
+	// Put stores size bytes read from r under key, replacing any object
+	// there. The object becomes visible only once complete.
+	Put(ctx context.Context, key string, r io.Reader, size int64) error
+	// Get opens an object; the caller closes it.
+	Get(ctx context.Context, key string) (io.ReadCloser, error)
+	Stat(ctx context.Context, key string) (*Object, error)
+	// List returns the objects whose key starts with prefix, sorted by key.
+	List(ctx context.Context, prefix string) ([]Object, error)
+	Delete(ctx context.Context, key string) error
+}
+
+// Config selects and configures the backend.
+type Config struct {
+	Backend string
+	Dir     string // local backend root
+	S3      S3Config
+}
+
+// New returns the store cfg describes.
+func New(cfg Config) (Store, error) {
+	switch cfg.Backend {
+	case BackendLocal:
+		return NewLocal(cfg.Dir)
+	case BackendS3:
+		return NewS3(cfg.S3)
+	}
+	return nil, fmt.Errorf("storage backend %q: must be %s or %s", cfg.Backend, BackendLocal, BackendS3)
+}
+
+// WithPrefix returns a view of s whose keys are relative to prefix, so
+// each consumer gets its own area of the shared store.
+func WithPrefix(s Store, prefix string) Store {
+	prefix = strings.Trim(prefix, "/")
+	if prefix == "" {
+		return s
+	}
+	return &prefixed{s: s, prefix: prefix + "/"}
+}
+
+// IsNotFound reports whether err means the object does not exist.
+func IsNotFound(err error) bool {
+	return err != nil && strings.Contains(err.Error(), "not found")
+}
+
+// ─── Private helpers ──────────────────────────────────────────────────────
+
+// checkKey rejects keys that are empty, absolute or not in clean form, so
+// no key escapes the local root or aliases another.
+func checkKey(key string) error {
+	if key == "" || strings.HasPrefix(key, "/") || strings.HasSuffix(key, "/") ||
+		path.Clean(key) != key || key == ".." || strings.HasPrefix(key, "../") {
+		return fmt.Errorf("invalid object key %q", key)
+	}
+	return nil
+}
+
+func notFound(key string) error {
+	return fmt.Errorf("object %s not found", key)
+}
+
+type prefixed struct {
+	s      Store
+	prefix string
+}
+
+func (p *prefixed) Put(ctx context.Context, key string, r io.Reader, size int64) error {
+	return p.s.Put(ctx, p.prefix+key, r, size)
+}
+
+func (p *prefixed) Get(ctx context.Context, key string) (io.ReadCloser, error) {
+	return p.s.Get(ctx, p.prefix+key)
+}
+
+func (p *prefixed) Stat(ctx context.Context, key string) (*Object, error) {
+	o, err := p.s.Stat(ctx, p.prefix+key)
+	if err != nil {
+		return nil, err
+	}
+	o.Key = key
+	return o, nil
+}
+
+func (p *prefixed) List(ctx context.Context, prefix string) ([]Object, error) {
+	objs, err := p.s.List(ctx, p.prefix+prefix)
+	if err != nil {
+		return nil, err
+	}
+	for i := range objs {
+		objs[i].Key = strings.TrimPrefix(objs[i].Key, p.prefix)
+	}
+	return objs, nil
+}
+
+func (p *prefixed) Delete(ctx context.Context, key string) error {
+	return p.s.Delete(ctx, p.prefix+key)
+}
@@ -0,0 +1,145 @@
+package blocklist
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"strings"
+
+	"github.com/aegisx/aegisx/internal/store"
+)
+
+// Feed formats.
+const (
+	FormatPlain = "plain" // one address per line
+	FormatCIDR  = "cidr"  // one address or prefix per line
+	FormatJSON  = "json"  // an array of strings, or of objects with the address under JSONField
+)
+
+// ValidFormat reports whether f is a known feed format.
+func ValidFormat(f string) bool {
+	return f == FormatPlain || f == FormatCIDR || f == FormatJSON
+}
+
+// fetch downloads a feed and returns its addresses in canonical form.
+func (m *Manager) fetch(ctx context.Context, f *store.BlockListFeed) ([]string, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, f.URL, nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("User-Agent", "aegisx-blocklist")
+	resp, err := m.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("feed returned %s", resp.Status)
+	}
+
+	body := io.Reader(resp.Body)
+	if m.cfg.MaxFeedBytes > 0 {
+		body = io.LimitReader(resp.Body, m.cfg.MaxFeedBytes+1)
+	}
+	data, err := io.ReadAll(body)
+	if err != nil {
+		return nil, fmt.Errorf("read feed: %w", err)
+	}
+	if m.cfg.MaxFeedBytes > 0 && int64(len(data)) > m.cfg.MaxFeedBytes {
+		return nil, fmt.Errorf("feed is larger than %d bytes", m.cfg.MaxFeedBytes)
+	}
+	addrs, err := Parse(f.Format, f.JSONField, data)
+	if err != nil {
+		return nil, err
+	}
+	if m.cfg.MaxEntries > 0 && len(addrs) > m.cfg.MaxEntries {
+		return nil, fmt.Errorf("feed has %d entries, limit is %d", len(addrs), m.cfg.MaxEntries)
+	}
+	return addrs, nil
+}
+
+// Parse reads the addresses of a feed body. Blank lines and lines starting
+// with '#' or ';' are skipped, as is anything after the first field, so
+// annotated lists such as "1.2.3.4 ; SBL123" work. Invalid entries fail
+// the whole feed rather than silently shrinking it.
+func Parse(format, jsonField string, data []byte) ([]string, error) {
+	var raw []string
+	switch format {
+	case FormatPlain, FormatCIDR:
+		sc := bufio.NewScanner(strings.NewReader(string(data)))
+		sc.Buffer(make([]byte, 64*1024), 1024*1024)
+		for sc.Scan() {
+			line := strings.TrimSpace(sc.Text())
+			if line == "" || line[0] == '#' || line[0] == ';' {
+				continue
+			}
+			raw = append(raw, strings.Fields(line)[0])
+		}
+		if err := sc.Err(); err != nil {
+			return nil, fmt.Errorf("read feed: %w", err)
+		}
+	case FormatJSON:
+		if jsonField == "" {
+			if err := json.Unmarshal(data, &raw); err != nil {
+				return nil, fmt.Errorf("parse feed: expected an array of strings: %w", err)
+			}
+			break
+		}
+		var objs []map[string]any
+		if err := json.Unmarshal(data, &objs); err != nil {
+			return nil, fmt.Errorf("parse feed: expected an array of objects: %w", err)
+		}
+		for i, o := range objs {
+			s, ok := o[jsonField].(string)
+			if !ok {
+				return nil, fmt.Errorf("parse feed: item %d has no string %q", i, jsonField)
+			}
+			raw = append(raw, s)
+		}
+	default:
+		return nil, fmt.Errorf("unknown feed format %q", format)
+	}
+
+	seen := make(map[string]bool, len(raw))
+	out := make([]string, 0, len(raw))
+	for i, s := range raw {
+		if format == FormatPlain && strings.Contains(s, "/") {
+			return nil, fmt.Errorf("entry %d: %q is a prefix; use format cidr", i+1, s)
+		}
+		a, err := Canonical(s)
+		if err != nil {
+			return nil, fmt.Errorf("entry %d: %w", i+1, err)
+		}
+		if !seen[a] {
+			seen[a] = true
+			out = append(out, a)
+		}
+	}
+	return out, nil
+}
+
+// Canonical returns an address or prefix in the form the store and nft
+// print it: single hosts without a prefix length, prefixes with the host
+// bits cleared.
+func Canonical(s string) (string, error) {
+	if !strings.Contains(s, "/") {
+		ip := net.ParseIP(s)
+		if ip == nil {
+			return "", fmt.Errorf("invalid address %q", s)
+		}
+		return ip.String(), nil
+	}
+	_, n, err := net.ParseCIDR(s)
+	if err != nil {
+		return "", fmt.Errorf("invalid prefix %q", s)
+	}
+	ones, bits := n.Mask.Size()
+	if ones == bits {
+		return n.IP.String(), nil
+	}
+	return n.String(), nil
+}
@@ -0,0 +1,197 @@
+// Package blocklist materializes block lists — manual entries and
+// subscribed threat feeds — into the nftables sets the firewall ruleset
+// drops in both directions, and reads back per-element hit counters.
+package blocklist
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"go.uber.org/zap"
+
+	"github.com/aegisx/aegisx/internal/store"
+)
+
+// Set names in the AegisX nftables table; the firewall ruleset declares them
+// with per-element counters and drops their members.
+const (
+	SetV4 = "blocklist4"
+	SetV6 = "blocklist6"
+)
+
+// Config tunes feed fetching and bounds the sets.
+type Config struct {
+	Table        string
+	DryRun       bool
+	MaxEntries   int           // distinct elements across all lists; 0 means no limit
+	SyncInterval time.Duration // how often due feeds are fetched and expiry applied
+	FetchTimeout time.Duration
+	MaxFeedBytes int64
+}
+
+// Manager keeps the nftables sets in line with the store.
+type Manager struct {
+	syncMu sync.Mutex // serializes Sync
+	store  *store.BlockListStore
+	cfg    Config
+	client *http.Client
+	log    *zap.Logger
+	rearm  chan struct{} // an entry was added; Run re-reads the next expiry
+}
+
+func NewManager(s *store.BlockListStore, cfg Config, log *zap.Logger) *Manager {
+	return &Manager{
+		store:  s,
+		cfg:    cfg,
+		client: &http.Client{Timeout: cfg.FetchTimeout},
+		log:    log,
+		rearm:  make(chan struct{}, 1),
+	}
+}
+
+// Store returns the backing store, for the API.
+func (m *Manager) Store() *store.BlockListStore { return m.store }
+
+// Run fetches due feeds, drops expired entries and syncs the sets every
+// SyncInterval, starting immediately, and as soon as an entry expires.
+func (m *Manager) Run(ctx context.Context) {
+	ticker := time.NewTicker(m.cfg.SyncInterval)
+	defer ticker.Stop()
+
+	for {
+		m.refreshDue(ctx)
+		if n, err := m.store.DeleteExpired(ctx); err != nil {
+			m.log.Warn("delete expired blocklist entries", zap.Error(err))
+		} else if n > 0 {
+			m.log.Info("blocklist entries expired", zap.Int64("count", n))
+		}
+		if err := m.Sync(ctx); err != nil {
+			m.log.Error("sync blocklist sets", zap.Error(err))
+		}
+		if !m.wait(ctx, ticker.C) {
+			return
+		}
+	}
+}
+
+// Rearm tells Run that an entry with an expiry was added, so it wakes in
+// time for it rather than at the next SyncInterval.
+func (m *Manager) Rearm() {
+	select {
+	case m.rearm <- struct{}{}:
+	default:
+	}
+}
+
+// Sync makes the nftables sets hold exactly the active entries of the
+// enabled lists. Elements that stay keep their counters.
+func (m *Manager) Sync(ctx context.Context) error {
+	m.syncMu.Lock()
+	defer m.syncMu.Unlock()
+
+	addrs, err := m.store.ActiveAddresses(ctx)
+	if err != nil {
+		return fmt.Errorf("load entries: %w", err)
+	}
+	want4, want6, err := collapse(addrs)
+	if err != nil {
+		return err
+	}
+	if n := len(want4) + len(want6); m.cfg.MaxEntries > 0 && n > m.cfg.MaxEntries {
+		return fmt.Errorf("block lists hold %d distinct prefixes, limit is %d", n, m.cfg.MaxEntries)
+	}
+	if m.cfg.DryRun {
+		m.log.Info("dry-run: blocklist sync", zap.Int("ipv4", len(want4)), zap.Int("ipv6", len(want6)))
+		return nil
+	}
+
+	have4, err := m.listSet(ctx, SetV4)
+	if err != nil {
+		return err
+	}
+	have6, err := m.listSet(ctx, SetV6)
+	if err != nil {
+		return err
+	}
+	script := m.diffScript(SetV4, have4, want4) + m.diffScript(SetV6, have6, want6)
+	if script == "" {
+		return nil
+	}
+	return m.nftScript(ctx, script)
+}
+
+// RefreshFeed fetches one feed now and syncs the sets.
+func (m *Manager) RefreshFeed(ctx context.Context, f *store.BlockListFeed) error {
+	if err := m.refresh(ctx, f); err != nil {
+		return err
+	}
+	return m.Sync(ctx)
+}
+
+// ─── Private helpers ──────────────────────────────────────────────────────
+
+// wait blocks until tick fires or the earliest entry expiry passes, and
+// reports false when ctx ends first.
+func (m *Manager) wait(ctx context.Context, tick <-chan time.Time) bool {
+	for {
+		var expired <-chan time.Time
+		stop := func() bool { return false }
+		next, err := m.store.NextExpiry(ctx)
+		if err != nil {
+			m.log.Warn("next blocklist expiry", zap.Error(err))
+		} else if next != nil {
+			timer := time.NewTimer(time.Until(*next))
+			expired, stop = timer.C, timer.Stop
+		}
+		select {
+		case <-ctx.Done():
+			stop()
+			return false
+		case <-tick:
+			stop()
+			return true
+		case <-expired:
+			return true
+		case <-m.rearm:
+			stop()
+		}
+	}
+}
+
+func (m *Manager) refreshDue(ctx context.Context) {
+	feeds, err := m.store.ListFeeds(ctx, uuid.Nil)
+	if err != nil {
+		m.log.Warn("list blocklist feeds", zap.Error(err))
+		return
+	}
+	now := time.Now()
+	for _, f := range feeds {
+		if !f.Due(now) {
+			continue
+		}
+		if err := m.refresh(ctx, f); err != nil {
+			m.log.Warn("blocklist feed refresh failed", zap.String("url", f.URL), zap.Error(err))
+		}
+	}
+}
+
+// refresh fetches a feed and replaces its entries. A failure is recorded on
+// the feed and its previous entries are kept.
+func (m *Manager) refresh(ctx context.Context, f *store.BlockListFeed) error {
+	addrs, err := m.fetch(ctx, f)
+	if err != nil {
+		if markErr := m.store.MarkFeedFailed(ctx, f.ID, err); markErr != nil {
+			m.log.Warn("record feed failure", zap.Error(markErr))
+		}
+		return err
+	}
+	if err := m.store.ReplaceFeedEntries(ctx, f, addrs); err != nil {
+		return err
+	}
+	m.log.Info("blocklist feed refreshed", zap.String("url", f.URL), zap.Int("entries", len(addrs)))
+	return nil
+}
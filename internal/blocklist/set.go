@@ -0,0 +1,230 @@
+package blocklist
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net"
+	"os/exec"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Counter is the traffic dropped by one set element since the ruleset was
+// last loaded; every apply recreates the sets and resets it.
+type Counter struct {
+	Packets uint64 `json:"packets"`
+	Bytes   uint64 `json:"bytes"`
+}
+
+// Counters returns the counter of every element in both sets, keyed by the
+// element in canonical form.
+func (m *Manager) Counters(ctx context.Context) (map[string]Counter, error) {
+	out := make(map[string]Counter)
+	if m.cfg.DryRun {
+		return out, nil
+	}
+	for _, set := range []string{SetV4, SetV6} {
+		elems, err := m.listSet(ctx, set)
+		if err != nil {
+			return nil, err
+		}
+		for k, c := range elems {
+			out[k] = c
+		}
+	}
+	return out, nil
+}
+
+// Lookup returns the counter of the element that holds addr: the entry
+// itself, or the wider prefix it was collapsed into.
+func Lookup(counters map[string]Counter, addr string) (Counter, bool) {
+	if c, ok := counters[addr]; ok {
+		return c, true
+	}
+	ip, n, err := net.ParseCIDR(addr)
+	if err != nil {
+		if ip = net.ParseIP(addr); ip == nil {
+			return Counter{}, false
+		}
+		bits := 128
+		if ip.To4() != nil {
+			bits = 32
+		}
+		n = &net.IPNet{IP: ip, Mask: net.CIDRMask(bits, bits)}
+	}
+	ones, bits := n.Mask.Size()
+	for l := ones - 1; l >= 0; l-- {
+		wider := net.IPNet{IP: n.IP.Mask(net.CIDRMask(l, bits)), Mask: net.CIDRMask(l, bits)}
+		if c, ok := counters[wider.String()]; ok {
+			return c, true
+		}
+	}
+	return Counter{}, false
+}
+
+// collapse splits canonical addresses by family and drops those inside a
+// wider prefix, since an interval set without auto-merge refuses overlaps.
+func collapse(addrs []string) (v4, v6 []string, err error) {
+	var nets4, nets6 []*net.IPNet
+	for _, a := range addrs {
+		s := a
+		if !strings.Contains(s, "/") {
+			if strings.Contains(s, ":") {
+				s += "/128"
+			} else {
+				s += "/32"
+			}
+		}
+		_, n, err := net.ParseCIDR(s)
+		if err != nil {
+			return nil, nil, fmt.Errorf("invalid entry %q", a)
+		}
+		if n.IP.To4() != nil {
+			n.IP = n.IP.To4()
+			nets4 = append(nets4, n)
+		} else {
+			nets6 = append(nets6, n)
+		}
+	}
+	return collapseFamily(nets4), collapseFamily(nets6), nil
+}
+
+// collapseFamily sorts by network address, widest first, so a prefix always
+// follows the one containing it.
+func collapseFamily(nets []*net.IPNet) []string {
+	sort.Slice(nets, func(i, j int) bool {
+		if c := bytes.Compare(nets[i].IP, nets[j].IP); c != 0 {
+			return c < 0
+		}
+		oi, _ := nets[i].Mask.Size()
+		oj, _ := nets[j].Mask.Size()
+		return oi < oj
+	})
+	var out []string
+	var last *net.IPNet
+	for _, n := range nets {
+		if last != nil && last.Contains(n.IP) {
+			continue
+		}
+		last = n
+		out = append(out, elemKey(n))
+	}
+	return out
+}
+
+// elemKey prints a host without its prefix length, as nft does.
+func elemKey(n *net.IPNet) string {
+	if ones, bits := n.Mask.Size(); ones == bits {
+		return n.IP.String()
+	}
+	return n.String()
+}
+
+// listSet reads the elements of a set with their counters.
+func (m *Manager) listSet(ctx context.Context, set string) (map[string]Counter, error) {
+	out, err := exec.CommandContext(ctx, "nft", "-j", "list", "set", "inet", m.cfg.Table, set).Output()
+	if err != nil {
+		return nil, fmt.Errorf("nft list set %s: %w", set, err)
+	}
+	var doc struct {
+		Nftables []struct {
+			Set *struct {
+				Elem []json.RawMessage `json:"elem"`
+			} `json:"set"`
+		} `json:"nftables"`
+	}
+	if err := json.Unmarshal(out, &doc); err != nil {
+		return nil, fmt.Errorf("parse nft json: %w", err)
+	}
+	elems := make(map[string]Counter)
+	for _, obj := range doc.Nftables {
+		if obj.Set == nil {
+			continue
+		}
+		for _, raw := range obj.Set.Elem {
+			// With counters an element is {"elem": {"val": …, "counter": …}},
+			// without them just the value.
+			var e struct {
+				Elem *struct {
+					Val     json.RawMessage `json:"val"`
+					Counter *Counter        `json:"counter"`
+				} `json:"elem"`
+			}
+			if json.Unmarshal(raw, &e) == nil && e.Elem != nil {
+				var c Counter
+				if e.Elem.Counter != nil {
+					c = *e.Elem.Counter
+				}
+				elems[elemValue(e.Elem.Val)] = c
+				continue
+			}
+			elems[elemValue(raw)] = Counter{}
+		}
+	}
+	return elems, nil
+}
+
+// elemValue formats an nft JSON address or prefix.
+func elemValue(raw json.RawMessage) string {
+	var s string
+	if json.Unmarshal(raw, &s) == nil {
+		return s
+	}
+	var v struct {
+		Prefix *struct {
+			Addr string `json:"addr"`
+			Len  int    `json:"len"`
+		} `json:"prefix"`
+	}
+	if json.Unmarshal(raw, &v) == nil && v.Prefix != nil {
+		return v.Prefix.Addr + "/" + strconv.Itoa(v.Prefix.Len)
+	}
+	return string(raw)
+}
+
+// diffScript returns the nft commands that turn have into want.
+func (m *Manager) diffScript(set string, have map[string]Counter, want []string) string {
+	keep := make(map[string]bool, len(want))
+	var add, del []string
+	for _, e := range want {
+		keep[e] = true
+		if _, ok := have[e]; !ok {
+			add = append(add, e)
+		}
+	}
+	for e := range have {
+		if !keep[e] {
+			del = append(del, e)
+		}
+	}
+	sort.Strings(del)
+
+	var sb strings.Builder
+	const batch = 1000
+	for _, op := range []struct {
+		verb  string
+		elems []string
+	}{{"delete", del}, {"add", add}} {
+		for i := 0; i < len(op.elems); i += batch {
+			j := i + batch
+			if j > len(op.elems) {
+				j = len(op.elems)
+			}
+			fmt.Fprintf(&sb, "%s element inet %s %s { %s }\n", op.verb, m.cfg.Table, set, strings.Join(op.elems[i:j], ", "))
+		}
+	}
+	return sb.String()
+}
+
+// nftScript runs script as one transaction.
+func (m *Manager) nftScript(ctx context.Context, script string) error {
+	cmd := exec.CommandContext(ctx, "nft", "-f", "-")
+	cmd.Stdin = strings.NewReader(script)
+	if out, err := cmd.CombinedOutput(); err != nil {
+		return fmt.Errorf("nft: %w (output: %s)", err, out)
+	}
+	return nil
+}
@@ -10,15 +10,36 @@ import (
 
 // Config is the root application configuration.
 type Config struct {
-	Server   ServerConfig   `mapstructure:"server"`
-	Database DatabaseConfig `mapstructure:"database"`
-	Auth     AuthConfig     `mapstructure:"auth"`
-	Firewall FirewallConfig `mapstructure:"firewall"`
-	IDS      IDSConfig      `mapstructure:"ids"`
-	LB       LBConfig       `mapstructure:"lb"`
-	VPN      VPNConfig      `mapstructure:"vpn"`
-	Metrics  MetricsConfig  `mapstructure:"metrics"`
-	Log      LogConfig      `mapstructure:"log"`
+	Profile    string           `mapstructure:"profile"` // standard | appliance; see profile.go
+	Server     ServerConfig     `mapstructure:"server"`
+	Database   DatabaseConfig   `mapstructure:"database"`
+	Auth       AuthConfig       `mapstructure:"auth"`
+	Firewall   FirewallConfig   `mapstructure:"firewall"`
+	IDS        IDSConfig        `mapstructure:"ids"`
+	Honeypot   HoneypotConfig   `mapstructure:"honeypot"`
+	Bans       BanConfig        `mapstructure:"bans"`
+	BlockList  BlockListConfig  `mapstructure:"blocklist"`
+	Monitors   MonitorsConfig   `mapstructure:"monitors"`
+	WANTest    WANTestConfig    `mapstructure:"wan_test"`
+	IfStats    IfStatsConfig    `mapstructure:"interface_stats"`
+	GeoIP      GeoIPConfig      `mapstructure:"geoip"`
+	Retention  RetentionConfig  `mapstructure:"retention"`
+	Storage    StorageConfig    `mapstructure:"storage"`
+	Backup     BackupConfig     `mapstructure:"backup"`
+	Time       TimeConfig       `mapstructure:"time"`
+	Features   FeaturesConfig   `mapstructure:"features"`
+	Plugins    PluginsConfig    `mapstructure:"plugins"`
+	Hooks      []HookConfig     `mapstructure:"hooks"`
+	Supervisor SupervisorConfig `mapstructure:"supervisor"`
+	Admission  AdmissionConfig  `mapstructure:"admission"`
+	Signing    SigningConfig    `mapstructure:"signing"`
+	LB         LBConfig         `mapstructure:"lb"`
+	VPN        VPNConfig        `mapstructure:"vpn"`
+	Metrics    MetricsConfig    `mapstructure:"metrics"`
+	Log        LogConfig        `mapstructure:"log"`
+
+	// File is the config file that was read; empty when none was found.
+	File string `mapstructure:"-"`
 }
 
 type ServerConfig struct {
@@ -29,30 +50,213 @@ type ServerConfig struct {
 	WriteTimeout time.Duration `mapstructure:"write_timeout"`
 	TLSCert      string        `mapstructure:"tls_cert"`
 	TLSKey       string        `mapstructure:"tls_key"`
+
+	// ReadOnly refuses every mutating request with 503, e.g. on a standby
+	// instance; unlike the switch set through the API it cannot be lifted
+	// at runtime.
+	ReadOnly       bool   `mapstructure:"read_only"`
+	ReadOnlyReason string `mapstructure:"read_only_reason"`
+
+	// GraphQL serves /api/v1/graphql, a read-only query endpoint over
+	// policies, alerts, peers and status for dashboards.
+	GraphQL bool `mapstructure:"graphql"`
 }
 
+// Database drivers.
+const (
+	DriverPostgres = "postgres"
+	DriverSQLite   = "sqlite" // one local file; the appliance profile's default
+)
+
 type DatabaseConfig struct {
+	// Driver is DriverPostgres or DriverSQLite. For SQLite, DSN is the
+	// path of the database file.
+	Driver          string        `mapstructure:"driver"`
 	DSN             string        `mapstructure:"dsn"`
 	MaxOpenConns    int           `mapstructure:"max_open_conns"`
 	MaxIdleConns    int           `mapstructure:"max_idle_conns"`
 	ConnMaxLifetime time.Duration `mapstructure:"conn_max_lifetime"`
 	MigrationsPath  string        `mapstructure:"migrations_path"`
+
+	// StatementTimeout makes the server cancel any statement running
+	// longer, so a stuck query cannot hold a connection indefinitely.
+	// Zero disables it.
+	StatementTimeout time.Duration `mapstructure:"statement_timeout"`
 }
 
 type AuthConfig struct {
-	JWTSecret     string        `mapstructure:"jwt_secret"`
-	JWTExpiry     time.Duration `mapstructure:"jwt_expiry"`
-	AdminUser     string        `mapstructure:"admin_user"`
-	AdminPassword string        `mapstructure:"admin_password"`
+	JWTSecret         string           `mapstructure:"jwt_secret"`
+	JWTExpiry         time.Duration    `mapstructure:"jwt_expiry"`
+	AdminUser         string           `mapstructure:"admin_user"`
+	AdminPassword     string           `mapstructure:"admin_password"`
+	AdminPasswordHash string           `mapstructure:"admin_password_hash"` // bcrypt; written by the setup wizard
+	WebAuthn          WebAuthnConfig   `mapstructure:"webauthn"`
+	SCIM              SCIMConfig       `mapstructure:"scim"`
+	LDAP              LDAPConfig       `mapstructure:"ldap"`
+	BreakGlass        BreakGlassConfig `mapstructure:"break_glass"`
+	Sessions          SessionsConfig   `mapstructure:"sessions"`
+}
+
+// SessionsConfig tracks logins as server-side sessions when either limit
+// is set; logging out then revokes a session's tokens. Zero means no limit.
+type SessionsConfig struct {
+	IdleTimeout time.Duration `mapstructure:"idle_timeout"` // end sessions unused for this long
+	MaxPerUser  int           `mapstructure:"max_per_user"` // evict the oldest sessions beyond this many
+}
+
+// BreakGlassConfig governs emergency access. Without CodeHash a second
+// admin must approve every break-glass request.
+type BreakGlassConfig struct {
+	CodeHash string        `mapstructure:"code_hash"` // bcrypt of the sealed emergency code
+	MaxTTL   time.Duration `mapstructure:"max_ttl"`
+}
+
+// WebAuthnConfig enables passkey logins for the dashboard.
+type WebAuthnConfig struct {
+	Enabled       bool     `mapstructure:"enabled"`
+	RPID          string   `mapstructure:"rp_id"`           // dashboard host name
+	RPDisplayName string   `mapstructure:"rp_display_name"` // default "AegisX"
+	RPOrigins     []string `mapstructure:"rp_origins"`      // e.g. https://fw.example.com
+	// SecondFactor requires a passkey after the password for users that
+	// have one; otherwise a passkey alone signs in.
+	SecondFactor bool `mapstructure:"second_factor"`
+}
+
+// SCIMConfig enables the SCIM 2.0 provisioning endpoint at /scim/v2. The
+// IdP authenticates with Token; group memberships map users to a tenant
+// and role.
+type SCIMConfig struct {
+	Enabled       bool               `mapstructure:"enabled"`
+	Token         string             `mapstructure:"token"`
+	DefaultTenant string             `mapstructure:"default_tenant"` // for users in no mapped group
+	DefaultRole   string             `mapstructure:"default_role"`   // "" = no access until mapped
+	GroupMappings []SCIMGroupMapping `mapstructure:"group_mappings"`
+}
+
+// SCIMGroupMapping grants members of an IdP group a role in a tenant.
+type SCIMGroupMapping struct {
+	Group  string `mapstructure:"group"`  // IdP group displayName
+	Tenant string `mapstructure:"tenant"` // tenant UUID
+	Role   string `mapstructure:"role"`
+}
+
+// LDAPConfig connects to an LDAP server or Active Directory. Everyone but
+// the admin signs in with their directory password, and every SyncInterval
+// the users UserFilter finds are provisioned with the groups GroupFilter
+// finds; group memberships map users to a tenant and role as for SCIM.
+type LDAPConfig struct {
+	Enabled       bool               `mapstructure:"enabled"`
+	URL           string             `mapstructure:"url"` // ldap://dc1:389 or ldaps://dc1:636
+	StartTLS      bool               `mapstructure:"start_tls"`
+	CAFile        string             `mapstructure:"ca_file"` // PEM; empty uses the system roots
+	BindDN        string             `mapstructure:"bind_dn"` // service account; empty binds anonymously
+	BindPassword  string             `mapstructure:"bind_password"`
+	Timeout       time.Duration      `mapstructure:"timeout"`
+	SyncInterval  time.Duration      `mapstructure:"sync_interval"` // 0 provisions users at login only
+	DefaultTenant string             `mapstructure:"default_tenant"`
+	DefaultRole   string             `mapstructure:"default_role"`
+	GroupMappings []SCIMGroupMapping `mapstructure:"group_mappings"` // group is the group's name attribute
+
+	UserBaseDN      string `mapstructure:"user_base_dn"`
+	UserFilter      string `mapstructure:"user_filter"` // {username} is replaced by the login name
+	UsernameAttr    string `mapstructure:"username_attr"`
+	EmailAttr       string `mapstructure:"email_attr"`
+	DisplayNameAttr string `mapstructure:"display_name_attr"`
+
+	GroupBaseDN   string `mapstructure:"group_base_dn"` // default user_base_dn
+	GroupFilter   string `mapstructure:"group_filter"`
+	GroupNameAttr string `mapstructure:"group_name_attr"`
+	MemberAttr    string `mapstructure:"member_attr"`
 }
 
 type FirewallConfig struct {
-	Backend      string `mapstructure:"backend"`  // "nftables" | "iptables"
-	TableName    string `mapstructure:"table_name"`
-	PolicyDir    string `mapstructure:"policy_dir"`
-	RollbackDir  string `mapstructure:"rollback_dir"`
-	DryRun       bool   `mapstructure:"dry_run"`
-	HotReload    bool   `mapstructure:"hot_reload"`
+	Backend     string `mapstructure:"backend"` // "nftables" | "iptables"
+	TableName   string `mapstructure:"table_name"`
+	PolicyDir   string `mapstructure:"policy_dir"`
+	RollbackDir string `mapstructure:"rollback_dir"`
+	DryRun      bool   `mapstructure:"dry_run"`
+	HotReload   bool   `mapstructure:"hot_reload"`
+
+	// StrictParsing refuses manifests with keys no field takes, such as a
+	// misspelt "destnation", instead of ignoring them.
+	StrictParsing bool `mapstructure:"strict_parsing"`
+
+	// ApplyTimeout bounds compiling and loading a ruleset, including the
+	// pre-apply hooks, rollback and flush. An apply requested through the
+	// API is also cancelled when the client goes away.
+	ApplyTimeout time.Duration `mapstructure:"apply_timeout"`
+
+	ScanDetection ScanDetectionConfig `mapstructure:"scan_detection"`
+	HitAnalysis   HitAnalysisConfig   `mapstructure:"hit_analysis"`
+	Verify        VerifyConfig        `mapstructure:"verify"`
+	Events        EventsConfig        `mapstructure:"events"`
+	Limits        LimitsConfig        `mapstructure:"limits"`
+	FQDN          FQDNConfig          `mapstructure:"fqdn"`
+	NetNS         NetNSConfig         `mapstructure:"netns"`
+	WANPaths      WANPathsConfig      `mapstructure:"wan_paths"`
+}
+
+// WANPathsConfig controls how often the WAN paths are re-evaluated against
+// the measured latency and loss of their uplinks.
+type WANPathsConfig struct {
+	Interval time.Duration `mapstructure:"interval"` // 0 chooses on apply and health changes only
+}
+
+// NetNSConfig controls where the network namespaces policies target with
+// targetNamespace are looked up, and how often the ones not found yet are
+// looked for again.
+type NetNSConfig struct {
+	Dirs          []string      `mapstructure:"dirs"`
+	WatchInterval time.Duration `mapstructure:"watch_interval"` // 0 loads rules on apply only
+}
+
+// FQDNConfig controls the resolution of the names rules select with fqdns.
+// Names are resolved before each apply and again every RefreshInterval;
+// only sets whose addresses changed are updated.
+type FQDNConfig struct {
+	RefreshInterval time.Duration `mapstructure:"refresh_interval"` // 0 resolves on apply only
+	Timeout         time.Duration `mapstructure:"timeout"`          // per name
+}
+
+// VerifyConfig schedules the comparison of the live ruleset with the one
+// the applied IR renders to.
+type VerifyConfig struct {
+	Enabled  bool          `mapstructure:"enabled"`
+	Interval time.Duration `mapstructure:"interval"`
+}
+
+// EventsConfig controls collection of the packets logged by rules with log
+// enabled, which feeds the blocked-traffic analytics.
+type EventsConfig struct {
+	Enabled bool   `mapstructure:"enabled"`
+	LogPath string `mapstructure:"log_path"` // kernel log, e.g. /var/log/kern.log
+}
+
+// LimitsConfig caps the size of compiled rulesets so a small appliance is
+// never pushed one the kernel cannot load. Zero disables a limit.
+type LimitsConfig struct {
+	MaxRulesPerChain int `mapstructure:"max_rules_per_chain"`
+	MaxSetElements   int `mapstructure:"max_set_elements"`
+	MaxRenderBytes   int `mapstructure:"max_render_bytes"`
+}
+
+// HitAnalysisConfig controls sampling of the per-rule counters behind the
+// rule ordering suggestions.
+type HitAnalysisConfig struct {
+	Enabled      bool          `mapstructure:"enabled"`
+	PollInterval time.Duration `mapstructure:"poll_interval"`
+	UnusedAfter  time.Duration `mapstructure:"unused_after"` // rules without a hit for this long are removal candidates
+}
+
+// ScanDetectionConfig flags sources that touch many distinct ports.
+type ScanDetectionConfig struct {
+	Enabled        bool          `mapstructure:"enabled"`
+	PortsPerMinute int           `mapstructure:"ports_per_minute"`
+	Action         string        `mapstructure:"action"`        // log | throttle | block
+	Cooldown       time.Duration `mapstructure:"cooldown"`      // how long a source stays flagged
+	ThrottleRate   string        `mapstructure:"throttle_rate"` // new connections per flagged source, e.g. "10/minute"
+	Exempt         []string      `mapstructure:"exempt"`
+	PollInterval   time.Duration `mapstructure:"poll_interval"`
 }
 
 type IDSConfig struct {
@@ -60,32 +264,276 @@ type IDSConfig struct {
 	Mode           string `mapstructure:"mode"` // "ids" | "ips"
 	ConfigPath     string `mapstructure:"config_path"`
 	RulesPath      string `mapstructure:"rules_path"`
+	SourceDir      string `mapstructure:"source_dir"` // downloaded rulesets (suricata-update output)
+	ThresholdPath  string `mapstructure:"threshold_path"`
 	SocketPath     string `mapstructure:"socket_path"`
 	LogPath        string `mapstructure:"log_path"`
 	UpdateInterval string `mapstructure:"update_interval"`
+
+	// Rule suggestions from recurring alerts
+	SuggestMinAlerts int           `mapstructure:"suggest_min_alerts"`
+	SuggestWindow    time.Duration `mapstructure:"suggest_window"`
+	SuggestInterval  time.Duration `mapstructure:"suggest_interval"`
+
+	// Engine counters and capture-loss alerting
+	StatsInterval time.Duration `mapstructure:"stats_interval"`
+	LossThreshold float64       `mapstructure:"loss_threshold"` // ratio, e.g. 0.01
+
+	// Inline (ips) mode: NFQUEUE wiring; must match Suricata's -q arguments
+	QueueNum   int      `mapstructure:"queue_num"`
+	QueueCount int      `mapstructure:"queue_count"`
+	FailOpen   bool     `mapstructure:"fail_open"`   // accept traffic while Suricata is down
+	QueueHooks []string `mapstructure:"queue_hooks"` // input | forward | output
+}
+
+// HoneypotConfig is the tarpit that TARPIT firewall rules redirect to.
+type HoneypotConfig struct {
+	Enabled     bool          `mapstructure:"enabled"`
+	Port        int           `mapstructure:"port"`
+	Delay       time.Duration `mapstructure:"delay"`        // between junk lines
+	MaxConns    int           `mapstructure:"max_conns"`    // held at once
+	MaxDuration time.Duration `mapstructure:"max_duration"` // per connection
+}
+
+// BanConfig is the fail2ban-like brute-force protection.
+type BanConfig struct {
+	Enabled     bool       `mapstructure:"enabled"`
+	AuthLogPath string     `mapstructure:"auth_log_path"` // sshd log, e.g. /var/log/auth.log
+	Ignore      []string   `mapstructure:"ignore"`        // addresses/CIDRs never banned
+	SSH         JailConfig `mapstructure:"ssh"`
+	API         JailConfig `mapstructure:"api"`
+}
+
+type JailConfig struct {
+	Enabled    bool          `mapstructure:"enabled"`
+	MaxRetry   int           `mapstructure:"max_retry"`
+	FindTime   time.Duration `mapstructure:"find_time"`
+	BanTime    time.Duration `mapstructure:"ban_time"`
+	MaxBanTime time.Duration `mapstructure:"max_ban_time"` // escalation cap for repeat offenders
+}
+
+// BlockListConfig is the store-backed block lists and their feed
+// subscriptions.
+type BlockListConfig struct {
+	Enabled      bool          `mapstructure:"enabled"`
+	MaxEntries   int           `mapstructure:"max_entries"`   // distinct prefixes across all lists
+	SyncInterval time.Duration `mapstructure:"sync_interval"` // feed refresh check and expiry
+	FetchTimeout time.Duration `mapstructure:"fetch_timeout"`
+	MaxFeedBytes int64         `mapstructure:"max_feed_bytes"`
+}
+
+// MonitorsConfig is the synthetic monitors managed through the API.
+type MonitorsConfig struct {
+	Enabled     bool          `mapstructure:"enabled"`
+	Max         int           `mapstructure:"max"`          // monitors that may exist; 0 means no limit
+	MinInterval time.Duration `mapstructure:"min_interval"` // shortest probe interval the API accepts
+}
+
+// WANTestConfig is the latency, jitter and throughput tests of the WAN
+// uplinks. Throughput is measured against DownloadURL and UploadURL with
+// method http, or against Iperf3Server with method iperf3; an unset server
+// leaves that direction out.
+type WANTestConfig struct {
+	Enabled       bool          `mapstructure:"enabled"`
+	Interval      time.Duration `mapstructure:"interval"`       // between scheduled tests; 0 tests on demand only
+	Method        string        `mapstructure:"method"`         // http | iperf3
+	LatencyTarget string        `mapstructure:"latency_target"` // host:port whose TCP handshake is timed
+	Samples       int           `mapstructure:"samples"`
+	DownloadURL   string        `mapstructure:"download_url"`
+	UploadURL     string        `mapstructure:"upload_url"`
+	Iperf3Path    string        `mapstructure:"iperf3_path"`
+	Iperf3Server  string        `mapstructure:"iperf3_server"` // host or host:port
+	Duration      time.Duration `mapstructure:"duration"`      // per throughput direction
+}
+
+// IfStatsConfig is the sampling of the interface traffic counters served
+// by /api/v1/system/interfaces/:name/stats. History is kept in memory only.
+type IfStatsConfig struct {
+	Enabled  bool          `mapstructure:"enabled"`
+	Interval time.Duration `mapstructure:"interval"`
+	History  time.Duration `mapstructure:"history"` // how far back the samples of each interface go
+}
+
+// GeoIPConfig names the MaxMind DB files used to enrich firewall events and
+// IDS alerts with the country and AS of the remote address, and to fill the
+// sets of rules selecting countries. A missing file disables that part.
+type GeoIPConfig struct {
+	CountryDB     string        `mapstructure:"country_db"`     // GeoLite2/GeoIP2 Country or City
+	ASNDB         string        `mapstructure:"asn_db"`         // GeoLite2/GeoIP2 ASN
+	CheckInterval time.Duration `mapstructure:"check_interval"` // how often the files are checked for updates
+}
+
+// RetentionConfig is how long the append-only tables keep their rows.
+type RetentionConfig struct {
+	Enabled   bool          `mapstructure:"enabled"`
+	Interval  time.Duration `mapstructure:"interval"`
+	BatchSize int           `mapstructure:"batch_size"` // rows per delete transaction and archive object
+
+	IDSAlerts      TableRetention `mapstructure:"ids_alerts"`
+	FirewallEvents TableRetention `mapstructure:"firewall_events"`
+	FlowStats      TableRetention `mapstructure:"flow_stats"` // VPN peer samples and metric snapshots
+	Audit          TableRetention `mapstructure:"audit"`
+	MonitorEvents  TableRetention `mapstructure:"monitor_events"`
+	WANTests       TableRetention `mapstructure:"wan_tests"`
+
+	// ArchivePrefix is where in the object storage pruned rows of tables
+	// with archive set are written, as gzipped ND-JSON.
+	ArchivePrefix string `mapstructure:"archive_prefix"`
+}
+
+// TableRetention is the retention of one table; a zero MaxAge keeps rows
+// forever.
+type TableRetention struct {
+	MaxAge  time.Duration `mapstructure:"max_age"`
+	Archive bool          `mapstructure:"archive"`
+}
+
+// StorageConfig is the object storage shared by backups, packet captures,
+// report and retention archives, and policy export bundles.
+type StorageConfig struct {
+	Backend string          `mapstructure:"backend"` // local | s3 (also MinIO, Ceph RGW)
+	Dir     string          `mapstructure:"dir"`     // root of the local backend
+	S3      S3StorageConfig `mapstructure:"s3"`
+}
+
+// S3StorageConfig addresses an S3-compatible bucket. Empty credentials fall
+// back to the AWS_* environment variables.
+type S3StorageConfig struct {
+	Endpoint            string `mapstructure:"endpoint"` // empty for AWS
+	Region              string `mapstructure:"region"`
+	Bucket              string `mapstructure:"bucket"`
+	Prefix              string `mapstructure:"prefix"`
+	PathStyle           bool   `mapstructure:"path_style"` // required by MinIO
+	AccessKeyID         string `mapstructure:"access_key_id"`
+	SecretAccessKey     string `mapstructure:"secret_access_key"`
+	SecretAccessKeyFile string `mapstructure:"secret_access_key_file"` // read instead of secret_access_key
+	SSE                 string `mapstructure:"sse"`                    // "" | AES256 | aws:kms
+	KMSKeyID            string `mapstructure:"kms_key_id"`             // with aws:kms; empty for the bucket default
+}
+
+// BackupConfig is where configuration backups are kept.
+type BackupConfig struct {
+	Prefix string `mapstructure:"prefix"` // in the object storage
+}
+
+// TimeConfig is NTP client management and clock sanity checking.
+type TimeConfig struct {
+	ManageChrony   bool          `mapstructure:"manage_chrony"` // render chrony.conf from servers/pools
+	ChronyConfPath string        `mapstructure:"chrony_conf_path"`
+	Servers        []string      `mapstructure:"servers"`
+	Pools          []string      `mapstructure:"pools"`
+	MaxSkew        time.Duration `mapstructure:"max_skew"`        // larger offsets count as unsynchronised
+	CheckInterval  time.Duration `mapstructure:"check_interval"`  // 0 disables clock checks
+	RefuseUnsynced bool          `mapstructure:"refuse_unsynced"` // reject policies with schedules while unsynchronised
+}
+
+// FeaturesConfig toggles features and points at the enterprise license.
+type FeaturesConfig struct {
+	LicensePath      string          `mapstructure:"license_path"`
+	LicensePublicKey string          `mapstructure:"license_public_key"` // base64 Ed25519
+	Flags            map[string]bool `mapstructure:"flags"`              // false disables a feature even when licensed
+}
+
+// PluginsConfig loads Go plugins that add manifest kinds and backends.
+type PluginsConfig struct {
+	Enabled      bool          `mapstructure:"enabled"`
+	Dir          string        `mapstructure:"dir"`           // *.so files, loaded in name order
+	ApplyTimeout time.Duration `mapstructure:"apply_timeout"` // per backend and apply
+}
+
+// HookConfig is a script or webhook run around applies and rollbacks.
+type HookConfig struct {
+	Name    string        `mapstructure:"name"`
+	Event   string        `mapstructure:"event"`   // pre-apply | post-apply | post-rollback | break-glass | verify-mismatch | health-change | daemon-crash
+	Command []string      `mapstructure:"command"` // gets the payload on stdin
+	URL     string        `mapstructure:"url"`     // gets the payload as a POST body
+	Timeout time.Duration `mapstructure:"timeout"`
+}
+
+// SupervisorConfig lists the daemons AegisX launches and restarts itself,
+// e.g. Suricata, HAProxy or dnsmasq. A daemon left out is assumed to be
+// managed outside AegisX, by systemd or similar.
+type SupervisorConfig struct {
+	Daemons     []DaemonConfig `mapstructure:"daemons"`
+	MinBackoff  time.Duration  `mapstructure:"min_backoff"` // first restart delay, doubled after each crash in a row
+	MaxBackoff  time.Duration  `mapstructure:"max_backoff"`
+	StableAfter time.Duration  `mapstructure:"stable_after"` // uptime after which a crash starts the backoff over
+	StopTimeout time.Duration  `mapstructure:"stop_timeout"` // SIGTERM to SIGKILL on shutdown
+}
+
+// DaemonConfig is one supervised daemon. Its command must keep it in the
+// foreground, e.g. haproxy -W -db or dnsmasq -k.
+type DaemonConfig struct {
+	Name         string   `mapstructure:"name"` // suricata and haproxy are reloaded through the supervisor
+	Command      []string `mapstructure:"command"`
+	ReloadSignal string   `mapstructure:"reload_signal"` // SIGHUP | SIGUSR1 | SIGUSR2
+}
+
+// AdmissionConfig points at the Rego rules that gate policy changes.
+type AdmissionConfig struct {
+	Enabled bool   `mapstructure:"enabled"`
+	Dir     string `mapstructure:"dir"` // *.rego files defining data.aegisx.admission.deny
+}
+
+// SigningConfig trusts the keys policy manifests are signed with; see
+// package signing.
+type SigningConfig struct {
+	Keys []string `mapstructure:"keys"` // PEM public key files, e.g. cosign.pub; empty disables verification
+	// Enforce refuses policies whose manifest is unsigned, on write and on
+	// apply. Generated policies, such as IDS suggestions, are unsigned.
+	Enforce bool `mapstructure:"enforce"`
 }
 
 type LBConfig struct {
+	Enabled     bool   `mapstructure:"enabled"`
 	Backend     string `mapstructure:"backend"` // "haproxy" | "envoy"
 	ConfigPath  string `mapstructure:"config_path"`
 	StatsSocket string `mapstructure:"stats_socket"`
 	StatsUser   string `mapstructure:"stats_user"`
 	StatsPass   string `mapstructure:"stats_pass"`
+	// AccessLogAddr is where HAProxy ships access logs (syslog/UDP) for
+	// analytics. Empty disables collection.
+	AccessLogAddr string `mapstructure:"access_log_addr"`
+	// UDPConfigPath is the nginx stream snippet rendered for UDP frontends,
+	// which HAProxy cannot serve.
+	UDPConfigPath string `mapstructure:"udp_config_path"`
+	ErrorsDir     string `mapstructure:"errors_dir"` // rendered error/maintenance pages
+	// MaxAnalyticsPairs bounds the frontend/backend pairs kept in memory for
+	// the analytics API; traffic of further pairs only reaches the metrics.
+	MaxAnalyticsPairs int `mapstructure:"max_analytics_pairs"`
 }
 
 type VPNConfig struct {
-	Enabled    bool   `mapstructure:"enabled"`
-	Interface  string `mapstructure:"interface"`
-	ListenPort int    `mapstructure:"listen_port"`
-	PrivateKey string `mapstructure:"private_key"`
-	Network    string `mapstructure:"network"`
-	DNS        string `mapstructure:"dns"`
+	Enabled        bool          `mapstructure:"enabled"`
+	Interface      string        `mapstructure:"interface"`
+	ConfigPath     string        `mapstructure:"config_path"`
+	ListenPort     int           `mapstructure:"listen_port"`
+	PrivateKey     string        `mapstructure:"private_key"`
+	Network        string        `mapstructure:"network"`
+	DNS            string        `mapstructure:"dns"`                // comma-separated; pushed to full-tunnel clients
+	SplitCIDRs     []string      `mapstructure:"split_tunnel_cidrs"` // routes for split-tunnel clients
+	MTU            int           `mapstructure:"mtu"`                // client MTU; 0 = derive from uplink
+	UplinkMTU      int           `mapstructure:"uplink_mtu"`
+	Encapsulation  []string      `mapstructure:"encapsulation"`     // pppoe|gre|vxlan|wireguard|ipsec
+	KeepAlive      int           `mapstructure:"default_keepalive"` // for peers without their own
+	Endpoint       string        `mapstructure:"endpoint"`          // public host:port handed to clients
+	PortalTokenTTL time.Duration `mapstructure:"portal_token_ttl"`
+	StatsInterval  time.Duration `mapstructure:"stats_interval"` // peer counter sampling period; see retention.flow_stats
+
+	// Relay programs for a VPNPolicy transport, by name on PATH or by
+	// absolute path. Policies choose the transport, never the program.
+	UDP2RawPath  string `mapstructure:"udp2raw_path"`
+	WSTunnelPath string `mapstructure:"wstunnel_path"`
 }
 
 type MetricsConfig struct {
-	Enabled    bool   `mapstructure:"enabled"`
-	Path       string `mapstructure:"path"`
-	Port       int    `mapstructure:"port"`
+	Enabled bool   `mapstructure:"enabled"`
+	Path    string `mapstructure:"path"`
+	Port    int    `mapstructure:"port"`
+
+	// TenantLabels lists the tenant IDs that get their own tenant label
+	// value; all others share "other", keeping cardinality bounded.
+	TenantLabels []string `mapstructure:"tenant_labels"`
 }
 
 type LogConfig struct {
@@ -99,29 +547,151 @@ func Load(cfgFile string) (*Config, error) {
 	v := viper.New()
 
 	// Defaults
+	v.SetDefault("profile", defaultProfile)
 	v.SetDefault("server.host", "0.0.0.0")
 	v.SetDefault("server.port", 8080)
 	v.SetDefault("server.grpc_port", 9090)
 	v.SetDefault("server.read_timeout", "30s")
 	v.SetDefault("server.write_timeout", "30s")
+	v.SetDefault("database.driver", DriverPostgres)
 	v.SetDefault("database.max_open_conns", 25)
 	v.SetDefault("database.max_idle_conns", 5)
 	v.SetDefault("database.conn_max_lifetime", "5m")
 	v.SetDefault("database.migrations_path", "/app/internal/store/migrations")
+	v.SetDefault("database.statement_timeout", "30s")
 	v.SetDefault("auth.jwt_expiry", "24h")
 	v.SetDefault("auth.admin_user", "admin")
+	v.SetDefault("auth.webauthn.rp_display_name", "AegisX")
+	v.SetDefault("auth.scim.default_tenant", "00000000-0000-0000-0000-000000000001")
+	v.SetDefault("auth.ldap.timeout", "10s")
+	v.SetDefault("auth.ldap.sync_interval", "15m")
+	v.SetDefault("auth.ldap.default_tenant", "00000000-0000-0000-0000-000000000001")
+	v.SetDefault("auth.ldap.user_filter", "(&(objectClass=user)(sAMAccountName={username}))")
+	v.SetDefault("auth.ldap.username_attr", "sAMAccountName")
+	v.SetDefault("auth.ldap.email_attr", "mail")
+	v.SetDefault("auth.ldap.display_name_attr", "displayName")
+	v.SetDefault("auth.ldap.group_filter", "(objectClass=group)")
+	v.SetDefault("auth.ldap.group_name_attr", "cn")
+	v.SetDefault("auth.ldap.member_attr", "member")
+	v.SetDefault("auth.break_glass.max_ttl", "1h")
 	v.SetDefault("firewall.backend", "nftables")
 	v.SetDefault("firewall.table_name", "aegisx")
 	v.SetDefault("firewall.policy_dir", "/etc/aegisx/policies")
 	v.SetDefault("firewall.rollback_dir", "/var/lib/aegisx/rollback")
+	v.SetDefault("firewall.apply_timeout", "60s")
+	v.SetDefault("firewall.scan_detection.ports_per_minute", 20)
+	v.SetDefault("firewall.scan_detection.action", "log")
+	v.SetDefault("firewall.scan_detection.cooldown", "10m")
+	v.SetDefault("firewall.scan_detection.throttle_rate", "10/minute")
+	v.SetDefault("firewall.scan_detection.poll_interval", "15s")
+	v.SetDefault("firewall.hit_analysis.enabled", true)
+	v.SetDefault("firewall.hit_analysis.poll_interval", "1m")
+	v.SetDefault("firewall.hit_analysis.unused_after", "720h")
+	v.SetDefault("firewall.verify.enabled", true)
+	v.SetDefault("firewall.verify.interval", "5m")
+	v.SetDefault("firewall.events.enabled", true)
+	v.SetDefault("firewall.events.log_path", "/var/log/kern.log")
+	v.SetDefault("firewall.limits.max_rules_per_chain", 10000)
+	v.SetDefault("firewall.limits.max_set_elements", 65536)
+	v.SetDefault("firewall.limits.max_render_bytes", 16<<20)
+	v.SetDefault("firewall.fqdn.refresh_interval", "1m")
+	v.SetDefault("firewall.fqdn.timeout", "5s")
+	v.SetDefault("firewall.netns.dirs", []string{"/run/netns", "/var/run/docker/netns"})
+	v.SetDefault("firewall.netns.watch_interval", "10s")
+	v.SetDefault("firewall.wan_paths.interval", "10s")
 	v.SetDefault("ids.mode", "ips")
 	v.SetDefault("ids.config_path", "/etc/suricata/suricata.yaml")
 	v.SetDefault("ids.rules_path", "/etc/suricata/rules")
 	v.SetDefault("ids.socket_path", "/var/run/suricata/suricata-command.socket")
+	v.SetDefault("ids.log_path", "/var/log/suricata")
+	v.SetDefault("ids.source_dir", "/var/lib/suricata/rules")
+	v.SetDefault("ids.threshold_path", "/etc/suricata/threshold.config")
+	v.SetDefault("ids.suggest_min_alerts", 20)
+	v.SetDefault("ids.suggest_window", "1h")
+	v.SetDefault("ids.suggest_interval", "5m")
+	v.SetDefault("ids.stats_interval", "30s")
+	v.SetDefault("ids.loss_threshold", 0.01)
+	v.SetDefault("ids.queue_count", 1)
+	v.SetDefault("ids.fail_open", true)
+	v.SetDefault("ids.queue_hooks", []string{"forward"})
+	v.SetDefault("honeypot.port", 2999)
+	v.SetDefault("honeypot.delay", "10s")
+	v.SetDefault("honeypot.max_conns", 512)
+	v.SetDefault("honeypot.max_duration", "15m")
+	v.SetDefault("bans.auth_log_path", "/var/log/auth.log")
+	v.SetDefault("bans.ssh.enabled", true)
+	v.SetDefault("bans.ssh.max_retry", 5)
+	v.SetDefault("bans.ssh.find_time", "10m")
+	v.SetDefault("bans.ssh.ban_time", "1h")
+	v.SetDefault("bans.ssh.max_ban_time", "24h")
+	v.SetDefault("bans.api.enabled", true)
+	v.SetDefault("bans.api.max_retry", 10)
+	v.SetDefault("bans.api.find_time", "10m")
+	v.SetDefault("bans.api.ban_time", "30m")
+	v.SetDefault("bans.api.max_ban_time", "24h")
+	v.SetDefault("blocklist.enabled", true)
+	v.SetDefault("blocklist.max_entries", 200000)
+	v.SetDefault("blocklist.sync_interval", "1m")
+	v.SetDefault("blocklist.fetch_timeout", "30s")
+	v.SetDefault("blocklist.max_feed_bytes", 32<<20)
+	v.SetDefault("monitors.enabled", true)
+	v.SetDefault("monitors.max", 100)
+	v.SetDefault("monitors.min_interval", "1s")
+	v.SetDefault("wan_test.enabled", true)
+	v.SetDefault("wan_test.interval", "0s")
+	v.SetDefault("wan_test.method", "http")
+	v.SetDefault("wan_test.latency_target", "1.1.1.1:443")
+	v.SetDefault("wan_test.samples", 10)
+	v.SetDefault("wan_test.iperf3_path", "iperf3")
+	v.SetDefault("wan_test.duration", "10s")
+	v.SetDefault("geoip.country_db", "/usr/share/GeoIP/GeoLite2-Country.mmdb")
+	v.SetDefault("geoip.asn_db", "/usr/share/GeoIP/GeoLite2-ASN.mmdb")
+	v.SetDefault("supervisor.min_backoff", "1s")
+	v.SetDefault("supervisor.max_backoff", "1m")
+	v.SetDefault("supervisor.stable_after", "1m")
+	v.SetDefault("supervisor.stop_timeout", "10s")
+	v.SetDefault("interface_stats.enabled", true)
+	v.SetDefault("interface_stats.interval", "2s")
+	v.SetDefault("interface_stats.history", "10m")
+	v.SetDefault("geoip.check_interval", "10m")
+	v.SetDefault("retention.enabled", true)
+	v.SetDefault("retention.interval", "1h")
+	v.SetDefault("retention.batch_size", 10000)
+	v.SetDefault("retention.ids_alerts.max_age", "2160h")
+	v.SetDefault("retention.firewall_events.max_age", "720h")
+	v.SetDefault("retention.flow_stats.max_age", "720h")
+	v.SetDefault("retention.audit.max_age", "8760h")
+	v.SetDefault("retention.monitor_events.max_age", "2160h")
+	v.SetDefault("retention.wan_tests.max_age", "8760h")
+	v.SetDefault("retention.archive_prefix", "archive")
+	v.SetDefault("storage.backend", "local")
+	v.SetDefault("storage.dir", "/var/lib/aegisx/storage")
+	v.SetDefault("backup.prefix", "backups")
+	v.SetDefault("time.chrony_conf_path", "/etc/chrony/chrony.conf")
+	v.SetDefault("time.pools", []string{"pool.ntp.org"})
+	v.SetDefault("time.max_skew", "1s")
+	v.SetDefault("time.check_interval", "30s")
+	v.SetDefault("time.refuse_unsynced", true)
+	v.SetDefault("features.license_path", "/etc/aegisx/license.json")
+	v.SetDefault("plugins.dir", "/usr/lib/aegisx/plugins")
+	v.SetDefault("plugins.apply_timeout", "30s")
+	v.SetDefault("admission.dir", "/etc/aegisx/admission")
+	v.SetDefault("lb.enabled", true)
 	v.SetDefault("lb.backend", "haproxy")
 	v.SetDefault("lb.config_path", "/etc/haproxy/haproxy.cfg")
 	v.SetDefault("lb.stats_socket", "/var/run/haproxy/admin.sock")
+	v.SetDefault("lb.access_log_addr", "127.0.0.1:5140")
+	v.SetDefault("lb.udp_config_path", "/etc/nginx/stream.d/aegisx-udp.conf")
+	v.SetDefault("lb.errors_dir", "/etc/haproxy/errors/aegisx")
+	v.SetDefault("lb.max_analytics_pairs", 1000)
 	v.SetDefault("vpn.interface", "wg0")
+	v.SetDefault("vpn.config_path", "/etc/wireguard/wg0.conf")
+	v.SetDefault("vpn.stats_interval", "1m")
+	v.SetDefault("vpn.portal_token_ttl", "168h")
+	v.SetDefault("vpn.uplink_mtu", 1500)
+	v.SetDefault("vpn.default_keepalive", 25)
+	v.SetDefault("vpn.udp2raw_path", "udp2raw")
+	v.SetDefault("vpn.wstunnel_path", "wstunnel")
 	v.SetDefault("vpn.listen_port", 51820)
 	v.SetDefault("vpn.network", "10.200.0.0/24")
 	v.SetDefault("metrics.enabled", true)
@@ -151,11 +721,15 @@ func Load(cfgFile string) (*Config, error) {
 			return nil, fmt.Errorf("reading config: %w", err)
 		}
 	}
+	if err := applyProfile(v); err != nil {
+		return nil, err
+	}
 
 	var cfg Config
 	if err := v.Unmarshal(&cfg); err != nil {
 		return nil, fmt.Errorf("unmarshalling config: %w", err)
 	}
+	cfg.File = v.ConfigFileUsed()
 
 	return &cfg, nil
 }
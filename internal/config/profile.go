@@ -0,0 +1,60 @@
+package config
+
+import (
+	"fmt"
+
+	"github.com/spf13/viper"
+)
+
+// Resource profiles select a set of defaults sized for the host.
+const (
+	ProfileStandard  = "standard"
+	ProfileAppliance = "appliance" // small ARM routers with around 512 MB of RAM
+)
+
+// applyProfile layers the defaults of the configured profile over the
+// built-in ones. Values set in the config file or environment still win.
+func applyProfile(v *viper.Viper) error {
+	switch p := v.GetString("profile"); p {
+	case ProfileStandard:
+	case ProfileAppliance:
+		applianceDefaults(v)
+	default:
+		return fmt.Errorf("profile %q: must be %s or %s", p, ProfileStandard, ProfileAppliance)
+	}
+	return nil
+}
+
+// applianceDefaults keeps the database in a local SQLite file, trims
+// connection pools, polls less often, shrinks the in-memory caches and
+// turns off the subsystems that need the most memory: Suricata and the
+// HAProxy load balancer.
+func applianceDefaults(v *viper.Viper) {
+	v.SetDefault("database.driver", DriverSQLite)
+	v.SetDefault("database.dsn", "/var/lib/aegisx/aegisx.db")
+	v.SetDefault("database.max_open_conns", 4)
+	v.SetDefault("database.max_idle_conns", 1)
+	v.SetDefault("firewall.scan_detection.poll_interval", "1m")
+	v.SetDefault("firewall.hit_analysis.poll_interval", "5m")
+	v.SetDefault("firewall.verify.interval", "15m")
+	v.SetDefault("firewall.limits.max_rules_per_chain", 2000)
+	v.SetDefault("firewall.limits.max_set_elements", 8192)
+	v.SetDefault("firewall.limits.max_render_bytes", 2<<20)
+	v.SetDefault("blocklist.max_entries", 20000)
+	v.SetDefault("blocklist.max_feed_bytes", 4<<20)
+	v.SetDefault("monitors.max", 20)
+	v.SetDefault("monitors.min_interval", "5s")
+	v.SetDefault("ids.enabled", false)
+	v.SetDefault("ids.suggest_interval", "15m")
+	v.SetDefault("ids.stats_interval", "2m")
+	v.SetDefault("honeypot.max_conns", 64)
+	v.SetDefault("time.check_interval", "2m")
+	v.SetDefault("lb.enabled", false)
+	v.SetDefault("lb.max_analytics_pairs", 32)
+	v.SetDefault("vpn.stats_interval", "5m")
+	v.SetDefault("retention.ids_alerts.max_age", "720h")
+	v.SetDefault("retention.firewall_events.max_age", "168h")
+	v.SetDefault("retention.flow_stats.max_age", "168h")
+	v.SetDefault("retention.monitor_events.max_age", "720h")
+	v.SetDefault("retention.wan_tests.max_age", "2160h")
+}
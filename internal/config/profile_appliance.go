@@ -0,0 +1,7 @@
+//go:build appliance
+
+package config
+
+// defaultProfile is the profile used when the config names none. Appliance
+// builds start small.
+const defaultProfile = ProfileAppliance
@@ -0,0 +1,6 @@
+//go:build !appliance
+
+package config
+
+// defaultProfile is the profile used when the config names none.
+const defaultProfile = ProfileStandard
@@ -0,0 +1,506 @@
+// Package directory connects to an LDAP server or Active Directory. It
+// checks passwords by binding as the user, and periodically syncs users and
+// group memberships into the user store, where group mappings give each
+// user a tenant and role as they do for SCIM.
+package directory
+
+import (
+	"context"
+	"crypto/tls"
+	"fmt"
+	"net"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/go-ldap/ldap/v3"
+	"github.com/google/uuid"
+	"go.uber.org/zap"
+
+	"github.com/aegisx/aegisx/internal/scim"
+	"github.com/aegisx/aegisx/internal/store"
+)
+
+// usernamePlaceholder is replaced in UserFilter by the escaped name of the
+// user signing in, and by * when syncing.
+const usernamePlaceholder = "{username}"
+
+// pageSize is the paged-results size of sync searches; Active Directory
+// returns at most 1000 entries per page.
+const pageSize = 500
+
+// Config describes the directory and how users and groups are found in it.
+type Config struct {
+	URL          string      // ldap://host:389 or ldaps://host:636
+	StartTLS     bool        // upgrade an ldap:// connection
+	TLS          *tls.Config // nil uses the system roots
+	BindDN       string      // service account; empty binds anonymously
+	BindPassword string
+	Timeout      time.Duration
+
+	UserBaseDN      string
+	UserFilter      string // must contain {username}
+	UsernameAttr    string
+	EmailAttr       string
+	DisplayNameAttr string
+
+	GroupBaseDN   string // defaults to UserBaseDN
+	GroupFilter   string
+	GroupNameAttr string
+	MemberAttr    string // holds member DNs
+}
+
+// Status is the outcome of the last sync.
+type Status struct {
+	LastSync time.Time `json:"lastSync,omitempty"`
+	Duration string    `json:"duration,omitempty"`
+	Error    string    `json:"error,omitempty"`
+	Users    int       `json:"users"`
+	Groups   int       `json:"groups"`
+	Created  int       `json:"created"`
+	Updated  int       `json:"updated"`
+	Removed  int       `json:"removed"`
+}
+
+// Syncer authenticates against the directory and keeps the users and
+// groups synced from it up to date.
+type Syncer struct {
+	cfg    Config
+	users  *store.UserStore
+	mapper *scim.Mapper
+	log    *zap.Logger
+
+	syncMu sync.Mutex // one sync at a time
+
+	mu     sync.RWMutex
+	status Status
+}
+
+// NewSyncer validates cfg. Users the sync finds land in the tenant and role
+// mapper resolves for their groups.
+func NewSyncer(cfg Config, users *store.UserStore, mapper *scim.Mapper, log *zap.Logger) (*Syncer, error) {
+	if cfg.URL == "" {
+		return nil, fmt.Errorf("ldap: url is required")
+	}
+	if cfg.UserBaseDN == "" {
+		return nil, fmt.Errorf("ldap: user_base_dn is required")
+	}
+	if !strings.Contains(cfg.UserFilter, usernamePlaceholder) {
+		return nil, fmt.Errorf("ldap: user_filter must contain %s", usernamePlaceholder)
+	}
+	if cfg.UsernameAttr == "" || cfg.GroupNameAttr == "" || cfg.MemberAttr == "" {
+		return nil, fmt.Errorf("ldap: username_attr, group_name_attr and member_attr are required")
+	}
+	if cfg.GroupBaseDN == "" {
+		cfg.GroupBaseDN = cfg.UserBaseDN
+	}
+	return &Syncer{cfg: cfg, users: users, mapper: mapper, log: log}, nil
+}
+
+// Authenticate checks password by binding as the directory user called
+// username, then creates or updates the user's record with the tenant and
+// role their current groups map to.
+func (s *Syncer) Authenticate(ctx context.Context, username, password string) (*store.UserRecord, error) {
+	// An empty password would make an unauthenticated bind, which many
+	// servers accept.
+	if username == "" || password == "" {
+		return nil, fmt.Errorf("invalid password")
+	}
+	conn, done, err := s.dial(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer done()
+
+	entry, err := s.findUser(conn, username)
+	if err != nil {
+		return nil, err
+	}
+	if err := conn.Bind(entry.DN, password); err != nil {
+		if ldap.IsErrorWithCode(err, ldap.LDAPResultInvalidCredentials) {
+			return nil, fmt.Errorf("invalid password")
+		}
+		return nil, fmt.Errorf("ldap bind: %w", err)
+	}
+	if err := s.bind(conn); err != nil {
+		return nil, err
+	}
+	groups, err := s.groupsOf(conn, entry.DN)
+	if err != nil {
+		return nil, err
+	}
+
+	want := s.record(entry, groups)
+	u, err := s.users.GetByUsername(ctx, want.Username)
+	switch {
+	case err != nil:
+		if err := s.users.Create(ctx, want); err != nil {
+			return nil, err
+		}
+		s.log.Info("ldap user provisioned at login", zap.String("user", want.Username), zap.String("role", want.Role))
+		return want, nil
+	case u.Source != store.SourceLDAP:
+		return nil, fmt.Errorf("user is not managed by LDAP")
+	}
+	if changed(u, want) {
+		want.ID = u.ID
+		if err := s.users.Update(ctx, want); err != nil {
+			return nil, err
+		}
+		return want, nil
+	}
+	return u, nil
+}
+
+// Sync reads the users and groups from the directory and makes the LDAP
+// records of the user store match: new users are created, changed ones
+// updated, and those no longer found deprovisioned. Records from SCIM are
+// left alone; a directory user named like one is skipped.
+func (s *Syncer) Sync(ctx context.Context) (Status, error) {
+	s.syncMu.Lock()
+	defer s.syncMu.Unlock()
+
+	start := time.Now()
+	st, err := s.sync(ctx)
+	st.LastSync = start
+	st.Duration = time.Since(start).Round(time.Millisecond).String()
+	if err != nil {
+		st.Error = err.Error()
+		s.log.Error("ldap sync failed", zap.Error(err))
+	} else {
+		s.log.Info("ldap sync finished",
+			zap.Int("users", st.Users), zap.Int("groups", st.Groups),
+			zap.Int("created", st.Created), zap.Int("updated", st.Updated), zap.Int("removed", st.Removed))
+	}
+	s.mu.Lock()
+	s.status = st
+	s.mu.Unlock()
+	return st, err
+}
+
+// Run syncs now and then every interval. Call this in a goroutine; it
+// blocks until ctx is cancelled.
+func (s *Syncer) Run(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		s.Sync(ctx)
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// Status returns the outcome of the last sync.
+func (s *Syncer) Status() Status {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.status
+}
+
+// ─── Private helpers ──────────────────────────────────────────────────────
+
+// dial connects and binds as the service account. The connection is closed
+// when ctx is cancelled, or by calling done.
+func (s *Syncer) dial(ctx context.Context) (conn *ldap.Conn, done func(), err error) {
+	timeout := s.cfg.Timeout
+	if timeout <= 0 {
+		timeout = 10 * time.Second
+	}
+	tlsCfg := s.cfg.TLS
+	if tlsCfg == nil {
+		tlsCfg = &tls.Config{}
+	}
+	if u, err := url.Parse(s.cfg.URL); err == nil && tlsCfg.ServerName == "" {
+		tlsCfg = tlsCfg.Clone()
+		tlsCfg.ServerName = u.Hostname()
+	}
+	conn, err = ldap.DialURL(s.cfg.URL,
+		ldap.DialWithDialer(&net.Dialer{Timeout: timeout}), ldap.DialWithTLSConfig(tlsCfg))
+	if err != nil {
+		return nil, nil, fmt.Errorf("ldap connect: %w", err)
+	}
+	conn.SetTimeout(timeout)
+	stop := context.AfterFunc(ctx, func() { conn.Close() })
+	done = func() {
+		stop()
+		conn.Close()
+	}
+	if s.cfg.StartTLS {
+		if err := conn.StartTLS(tlsCfg); err != nil {
+			done()
+			return nil, nil, fmt.Errorf("ldap starttls: %w", err)
+		}
+	}
+	if err := s.bind(conn); err != nil {
+		done()
+		return nil, nil, err
+	}
+	return conn, done, nil
+}
+
+// bind authenticates conn as the service account.
+func (s *Syncer) bind(conn *ldap.Conn) error {
+	if s.cfg.BindDN == "" {
+		return nil
+	}
+	if err := conn.Bind(s.cfg.BindDN, s.cfg.BindPassword); err != nil {
+		return fmt.Errorf("ldap service bind: %w", err)
+	}
+	return nil
+}
+
+// findUser returns the one entry UserFilter matches for username.
+func (s *Syncer) findUser(conn *ldap.Conn, username string) (*ldap.Entry, error) {
+	filter := strings.ReplaceAll(s.cfg.UserFilter, usernamePlaceholder, ldap.EscapeFilter(username))
+	res, err := conn.Search(ldap.NewSearchRequest(s.cfg.UserBaseDN, ldap.ScopeWholeSubtree, ldap.NeverDerefAliases,
+		2, 0, false, filter, s.userAttrs(), nil))
+	if err != nil && !ldap.IsErrorWithCode(err, ldap.LDAPResultSizeLimitExceeded) {
+		return nil, fmt.Errorf("ldap user search: %w", err)
+	}
+	if res == nil || len(res.Entries) == 0 {
+		return nil, fmt.Errorf("user not found")
+	}
+	if len(res.Entries) > 1 {
+		return nil, fmt.Errorf("user filter matches more than one entry")
+	}
+	return res.Entries[0], nil
+}
+
+// groupsOf returns the names of the groups GroupFilter selects that list
+// dn as a member.
+func (s *Syncer) groupsOf(conn *ldap.Conn, dn string) ([]string, error) {
+	filter := fmt.Sprintf("(&%s(%s=%s))", s.cfg.GroupFilter, s.cfg.MemberAttr, ldap.EscapeFilter(dn))
+	res, err := conn.SearchWithPaging(ldap.NewSearchRequest(s.cfg.GroupBaseDN, ldap.ScopeWholeSubtree, ldap.NeverDerefAliases,
+		0, 0, false, filter, []string{s.cfg.GroupNameAttr}, nil), pageSize)
+	if err != nil {
+		return nil, fmt.Errorf("ldap group search: %w", err)
+	}
+	var names []string
+	for _, e := range res.Entries {
+		if n := e.GetAttributeValue(s.cfg.GroupNameAttr); n != "" {
+			names = append(names, n)
+		}
+	}
+	return names, nil
+}
+
+// directoryGroup is a group as read from the directory.
+type directoryGroup struct {
+	name    string
+	dn      string
+	members []string // lower-cased DNs
+}
+
+func (s *Syncer) sync(ctx context.Context) (Status, error) {
+	var st Status
+	conn, done, err := s.dial(ctx)
+	if err != nil {
+		return st, err
+	}
+	defer done()
+
+	// Read everything first, so a failed search changes nothing.
+	filter := strings.ReplaceAll(s.cfg.UserFilter, usernamePlaceholder, "*")
+	res, err := conn.SearchWithPaging(ldap.NewSearchRequest(s.cfg.UserBaseDN, ldap.ScopeWholeSubtree, ldap.NeverDerefAliases,
+		0, 0, false, filter, s.userAttrs(), nil), pageSize)
+	if err != nil {
+		return st, fmt.Errorf("ldap user search: %w", err)
+	}
+	entries := res.Entries
+	res, err = conn.SearchWithPaging(ldap.NewSearchRequest(s.cfg.GroupBaseDN, ldap.ScopeWholeSubtree, ldap.NeverDerefAliases,
+		0, 0, false, s.cfg.GroupFilter, []string{s.cfg.GroupNameAttr, s.cfg.MemberAttr}, nil), pageSize)
+	if err != nil {
+		return st, fmt.Errorf("ldap group search: %w", err)
+	}
+	var groups []directoryGroup
+	groupsOf := make(map[string][]string) // member DN → group names
+	for _, e := range res.Entries {
+		g := directoryGroup{name: e.GetAttributeValue(s.cfg.GroupNameAttr), dn: e.DN}
+		if g.name == "" {
+			continue
+		}
+		for _, m := range e.GetAttributeValues(s.cfg.MemberAttr) {
+			dn := strings.ToLower(m)
+			g.members = append(g.members, dn)
+			groupsOf[dn] = append(groupsOf[dn], g.name)
+		}
+		groups = append(groups, g)
+	}
+
+	// Users.
+	existing, err := s.listUsers(ctx)
+	if err != nil {
+		return st, err
+	}
+	ids := make(map[string]uuid.UUID) // lower-cased DN → user ID
+	seen := make(map[string]bool)     // lower-cased usernames
+	for _, e := range entries {
+		want := s.record(e, groupsOf[strings.ToLower(e.DN)])
+		key := strings.ToLower(want.Username)
+		if want.Username == "" || seen[key] {
+			continue
+		}
+		seen[key] = true
+		if u, ok := existing[key]; ok {
+			if changed(u, want) {
+				want.ID = u.ID
+				if err := s.users.Update(ctx, want); err != nil {
+					return st, err
+				}
+				st.Updated++
+			}
+			ids[strings.ToLower(e.DN)] = u.ID
+			continue
+		}
+		if err := s.users.Create(ctx, want); err != nil {
+			s.log.Warn("ldap sync: skip user", zap.String("user", want.Username), zap.Error(err))
+			continue
+		}
+		ids[strings.ToLower(e.DN)] = want.ID
+		st.Created++
+	}
+	st.Users = len(ids)
+	for key, u := range existing {
+		if seen[key] {
+			continue
+		}
+		if err := s.users.Delete(ctx, u.ID); err != nil {
+			return st, err
+		}
+		s.log.Info("ldap user deprovisioned", zap.String("user", u.Username))
+		st.Removed++
+	}
+
+	// Groups, by name; members not among the synced users are left out.
+	current, err := s.listGroups(ctx)
+	if err != nil {
+		return st, err
+	}
+	keep := make(map[string]bool, len(groups))
+	for _, g := range groups {
+		if keep[g.name] {
+			continue
+		}
+		keep[g.name] = true
+		want := &store.GroupRecord{ExternalID: g.dn, DisplayName: g.name, Source: store.SourceLDAP, Members: []uuid.UUID{}}
+		for _, m := range g.members {
+			if id, ok := ids[m]; ok {
+				want.Members = append(want.Members, id)
+			}
+		}
+		if cur, ok := current[g.name]; ok {
+			if cur.ExternalID != want.ExternalID || !sameMembers(cur.Members, want.Members) {
+				want.ID = cur.ID
+				if err := s.users.UpdateGroup(ctx, want); err != nil {
+					return st, err
+				}
+			}
+		} else if err := s.users.CreateGroup(ctx, want); err != nil {
+			s.log.Warn("ldap sync: skip group", zap.String("group", g.name), zap.Error(err))
+			continue
+		}
+		st.Groups++
+	}
+	for name, g := range current {
+		if !keep[name] {
+			if _, err := s.users.DeleteGroup(ctx, g.ID); err != nil {
+				return st, err
+			}
+		}
+	}
+	return st, nil
+}
+
+func (s *Syncer) userAttrs() []string {
+	attrs := []string{s.cfg.UsernameAttr}
+	if s.cfg.EmailAttr != "" {
+		attrs = append(attrs, s.cfg.EmailAttr)
+	}
+	if s.cfg.DisplayNameAttr != "" {
+		attrs = append(attrs, s.cfg.DisplayNameAttr)
+	}
+	return attrs
+}
+
+// record is the user record for a directory entry in groups.
+func (s *Syncer) record(e *ldap.Entry, groups []string) *store.UserRecord {
+	tenantID, role := s.mapper.Resolve(groups)
+	u := &store.UserRecord{
+		TenantID:   tenantID,
+		Username:   e.GetAttributeValue(s.cfg.UsernameAttr),
+		ExternalID: e.DN,
+		Role:       role,
+		Active:     true,
+		Source:     store.SourceLDAP,
+	}
+	if s.cfg.EmailAttr != "" {
+		u.Email = e.GetAttributeValue(s.cfg.EmailAttr)
+	}
+	if s.cfg.DisplayNameAttr != "" {
+		u.DisplayName = e.GetAttributeValue(s.cfg.DisplayNameAttr)
+	}
+	if u.Email == "" {
+		u.Email = u.Username
+	}
+	return u
+}
+
+// listUsers returns the users synced from LDAP by lower-cased name.
+func (s *Syncer) listUsers(ctx context.Context) (map[string]*store.UserRecord, error) {
+	out := make(map[string]*store.UserRecord)
+	for offset := 0; ; {
+		items, total, err := s.users.List(ctx, store.UserFilter{Source: store.SourceLDAP, Offset: offset, Limit: 1000})
+		if err != nil {
+			return nil, err
+		}
+		for _, u := range items {
+			out[strings.ToLower(u.Username)] = u
+		}
+		if offset += len(items); len(items) == 0 || offset >= total {
+			return out, nil
+		}
+	}
+}
+
+// listGroups returns the groups synced from LDAP by name.
+func (s *Syncer) listGroups(ctx context.Context) (map[string]*store.GroupRecord, error) {
+	out := make(map[string]*store.GroupRecord)
+	for offset := 0; ; {
+		items, total, err := s.users.ListGroups(ctx, store.UserFilter{Source: store.SourceLDAP, Offset: offset, Limit: 1000})
+		if err != nil {
+			return nil, err
+		}
+		for _, g := range items {
+			out[g.DisplayName] = g
+		}
+		if offset += len(items); len(items) == 0 || offset >= total {
+			return out, nil
+		}
+	}
+}
+
+// changed reports whether the synced fields of want differ from u.
+func changed(u, want *store.UserRecord) bool {
+	return u.TenantID != want.TenantID || u.Role != want.Role || u.Email != want.Email ||
+		u.DisplayName != want.DisplayName || u.ExternalID != want.ExternalID || !u.Active
+}
+
+func sameMembers(a, b []uuid.UUID) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	in := make(map[uuid.UUID]bool, len(a))
+	for _, id := range a {
+		in[id] = true
+	}
+	for _, id := range b {
+		if !in[id] {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,128 @@
+// Package features decides which capabilities are available. Community
+// features are on unless disabled in the config; enterprise features also
+// need a valid signed license that lists them.
+package features
+
+import (
+	"sort"
+	"sync"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+// Feature names a gated capability.
+type Feature string
+
+const (
+	MultiNode         Feature = "multi-node"
+	HA                Feature = "ha"
+	ComplianceReports Feature = "compliance-reports"
+	SCIM              Feature = "scim"
+)
+
+// enterprise lists the features that require a license.
+var enterprise = map[Feature]bool{
+	MultiNode:         true,
+	HA:                true,
+	ComplianceReports: true,
+	SCIM:              true,
+}
+
+// Status describes one feature for the API.
+type Status struct {
+	Name       Feature `json:"name"`
+	Enabled    bool    `json:"enabled"`
+	Enterprise bool    `json:"enterprise"`
+	Reason     string  `json:"reason,omitempty"` // why a feature is off
+}
+
+// Set answers capability checks. It is safe for concurrent use.
+type Set struct {
+	mu      sync.RWMutex
+	flags   map[Feature]bool // config overrides
+	license *License
+	log     *zap.Logger
+}
+
+// NewSet builds a Set from the config flags and an optional license; a nil
+// license leaves every enterprise feature off.
+func NewSet(flags map[string]bool, license *License, log *zap.Logger) *Set {
+	s := &Set{flags: make(map[Feature]bool, len(flags)), license: license, log: log}
+	for name, on := range flags {
+		s.flags[Feature(name)] = on
+	}
+	return s
+}
+
+// Enabled reports whether f may be used right now.
+func (s *Set) Enabled(f Feature) bool {
+	return s.status(f).Enabled
+}
+
+// List returns the state of every known feature, sorted by name. Features
+// that only appear in the config are included.
+func (s *Set) List() []Status {
+	s.mu.RLock()
+	names := make(map[Feature]bool, len(enterprise)+len(s.flags))
+	for f := range enterprise {
+		names[f] = true
+	}
+	for f := range s.flags {
+		names[f] = true
+	}
+	s.mu.RUnlock()
+
+	out := make([]Status, 0, len(names))
+	for f := range names {
+		out = append(out, s.status(f))
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].Name < out[j].Name })
+	return out
+}
+
+// License returns the loaded license, or nil.
+func (s *Set) License() *License {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.license
+}
+
+// SetLicense replaces the license, e.g. after the file was renewed.
+func (s *Set) SetLicense(l *License) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.license = l
+	if l != nil {
+		s.log.Info("license loaded", zap.String("licensee", l.Licensee),
+			zap.Time("expires_at", l.ExpiresAt), zap.Int("features", len(l.Features)))
+	}
+}
+
+// ─── Private helpers ──────────────────────────────────────────────────────
+
+func (s *Set) status(f Feature) Status {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	st := Status{Name: f, Enterprise: enterprise[f]}
+	on, configured := s.flags[f]
+	switch {
+	case configured && !on:
+		st.Reason = "disabled in config"
+	case !st.Enterprise:
+		st.Enabled = configured
+		if !configured {
+			st.Reason = "unknown feature"
+		}
+	case s.license == nil:
+		st.Reason = "requires an enterprise license"
+	case !s.license.Grants(f):
+		st.Reason = "not included in license"
+	case time.Now().After(s.license.ExpiresAt):
+		st.Reason = "license expired"
+	default:
+		st.Enabled = true
+	}
+	return st
+}
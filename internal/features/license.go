@@ -0,0 +1,69 @@
+package features
+
+import (
+	"crypto/ed25519"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"os"
+	"time"
+)
+
+// License grants enterprise features to a licensee until ExpiresAt.
+type License struct {
+	ID        string    `json:"id"`
+	Licensee  string    `json:"licensee"`
+	IssuedAt  time.Time `json:"issuedAt"`
+	ExpiresAt time.Time `json:"expiresAt"`
+	Features  []Feature `json:"features"`
+	MaxNodes  int       `json:"maxNodes,omitempty"` // 0 means unlimited
+}
+
+// licenseFile is the on-disk format. The signature covers the exact bytes of
+// the license object, so the file must not be re-indented after signing.
+type licenseFile struct {
+	License   json.RawMessage `json:"license"`
+	Signature string          `json:"signature"` // base64 Ed25519
+}
+
+// Grants reports whether the license lists f.
+func (l *License) Grants(f Feature) bool {
+	for _, g := range l.Features {
+		if g == f {
+			return true
+		}
+	}
+	return false
+}
+
+// LoadLicense reads the license at path and verifies its signature against
+// the base64-encoded Ed25519 public key. An expired license still loads so
+// the API can report it; Set treats its features as off.
+func LoadLicense(path, publicKey string) (*License, error) {
+	key, err := base64.StdEncoding.DecodeString(publicKey)
+	if err != nil || len(key) != ed25519.PublicKeySize {
+		return nil, fmt.Errorf("license public key must be a base64 Ed25519 key")
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("read license: %w", err)
+	}
+
+	var f licenseFile
+	if err := json.Unmarshal(data, &f); err != nil {
+		return nil, fmt.Errorf("parse license: %w", err)
+	}
+	sig, err := base64.StdEncoding.DecodeString(f.Signature)
+	if err != nil {
+		return nil, fmt.Errorf("decode license signature: %w", err)
+	}
+	if !ed25519.Verify(ed25519.PublicKey(key), f.License, sig) {
+		return nil, fmt.Errorf("license signature is invalid")
+	}
+
+	var l License
+	if err := json.Unmarshal(f.License, &l); err != nil {
+		return nil, fmt.Errorf("parse license: %w", err)
+	}
+	return &l, nil
+}
@@ -4,12 +4,18 @@ package firewall
 import (
 	"context"
 	"fmt"
+	"net"
+	"strings"
 	"sync"
 	"time"
 
 	"go.uber.org/zap"
 
+	"github.com/aegisx/aegisx/internal/health"
+	"github.com/aegisx/aegisx/internal/hooks"
+	"github.com/aegisx/aegisx/internal/metrics"
 	"github.com/aegisx/aegisx/internal/policy"
+	"github.com/aegisx/aegisx/internal/wan"
 )
 
 // Service orchestrates policy compilation and dataplane application.
@@ -19,8 +25,25 @@ type Service struct {
 	engine  *policy.Engine
 	parser  *policy.Parser
 	current *policy.IR
+	health  *health.Monitor
+	wan     *wan.Router
 	log     *zap.Logger
 	cfg     ServiceConfig
+
+	uplinkActive map[string]bool // uplinks steered to by the last apply
+	onApply      []func(*policy.IR)
+	hits         *hitTracker
+
+	monMu    sync.RWMutex
+	monitors []policy.HealthTarget // API-managed targets probed next to the policy's
+
+	fqdns fqdnCache
+
+	nsLoaded map[string]netnsInstance // network namespaces holding the applied rules
+
+	wanPaths []policy.CompiledWANPath // WAN paths with the uplinks the last apply chose
+
+	expiryRearm chan struct{} // wakes WatchExpiry after an apply
 }
 
 type ServiceConfig struct {
@@ -28,40 +51,164 @@ type ServiceConfig struct {
 	RollbackDir string
 	PolicyDir   string
 	DryRun      bool
+	IPS         *IPSQueue // set when Suricata runs inline (ids.mode "ips")
+	TarpitPort  int       // honeypot listener for TARPIT rules; 0 when disabled
+	Scan        *ScanDetection
+	Bans        bool // brute-force ban sets, filled at runtime by package ban
+	BlockLists  bool // block list sets, filled at runtime by package blocklist
+
+	// ClockCheck, when set, is consulted before applying an IR with scheduled
+	// rules; an error refuses the apply because the rules would fire at the
+	// wrong time.
+	ClockCheck func() error
+
+	Hooks *hooks.Runner // operator scripts/webhooks around apply and rollback
+
+	// Limits caps the size of compiled rulesets; see policy.Limits.
+	Limits policy.Limits
+
+	// UnusedAfter is how long a rule must go without a hit before the hit
+	// analysis lists it as a removal candidate.
+	UnusedAfter time.Duration
+
+	// ApplyTimeout bounds each apply, rollback and flush; zero leaves only
+	// the caller's context.
+	ApplyTimeout time.Duration
+
+	// FQDNTimeout bounds the lookup of each name of an FQDN set.
+	FQDNTimeout time.Duration
+
+	// NetNSDirs are searched for the network namespaces policies target by
+	// name; empty means /run/netns and /var/run/docker/netns.
+	NetNSDirs []string
+
+	// StrictParsing refuses manifests with unknown keys; see
+	// policy.NewStrictParser.
+	StrictParsing bool
 }
 
 func NewService(cfg ServiceConfig, log *zap.Logger) *Service {
 	adapter := NewAdapter(cfg.TableName, cfg.RollbackDir, cfg.DryRun, log)
-	return &Service{
+	adapter.ips = cfg.IPS
+	adapter.tarpitPort = cfg.TarpitPort
+	adapter.scan = cfg.Scan
+	adapter.bans = cfg.Bans
+	adapter.blockLists = cfg.BlockLists
+	adapter.maxRenderBytes = cfg.Limits.MaxRenderBytes
+	parser := policy.NewParser()
+	if cfg.StrictParsing {
+		parser = policy.NewStrictParser()
+	}
+	s := &Service{
 		adapter: adapter,
 		engine:  policy.NewEngine(),
-		parser:  policy.NewParser(),
+		parser:  parser,
+		health:  health.NewMonitor(log),
+		wan:     wan.NewRouter(cfg.DryRun, log),
 		log:     log,
 		cfg:     cfg,
+		hits:    newHitTracker(),
+
+		nsLoaded:    make(map[string]netnsInstance),
+		expiryRearm: make(chan struct{}, 1),
 	}
+	s.engine.SetLimits(cfg.Limits)
+	s.engine.SetExternalTargets(s.isMonitor)
+	s.engine.SetInterfaces(hostInterfaces)
+	s.health.OnChange(s.onHealthChange)
+	return s
 }
 
-// ApplyManifests parses, compiles, and applies a set of manifests.
+// ApplyManifests parses, compiles, and applies a set of manifests. If ctx
+// ends while compiling, nothing is applied.
 func (s *Service) ApplyManifests(ctx context.Context, manifests []*policy.Manifest) error {
+	ctx, cancel := s.applyContext(ctx)
+	defer cancel()
+
+	start := time.Now()
 	ir, err := s.engine.Compile(manifests)
 	if err != nil {
+		metrics.ObserveApply(ctx, "invalid", start)
 		return fmt.Errorf("compile: %w", err)
 	}
 	return s.ApplyIR(ctx, ir)
 }
 
-// ApplyIR applies a pre-compiled IR to the dataplane.
-func (s *Service) ApplyIR(ctx context.Context, ir *policy.IR) error {
+// ApplyIR applies a pre-compiled IR to the dataplane. Pre-apply hooks may
+// veto it; post-apply hooks learn the outcome. The apply is abandoned if
+// ctx ends before the ruleset is loaded. Metrics are attributed to the
+// tenant set on ctx with metrics.WithTenant.
+func (s *Service) ApplyIR(ctx context.Context, ir *policy.IR) (err error) {
+	ctx, cancel := s.applyContext(ctx)
+	defer cancel()
+
+	start := time.Now()
+	defer func() {
+		status := "success"
+		if err != nil {
+			status = "failure"
+		}
+		metrics.ObserveApply(ctx, status, start)
+	}()
+
+	if err := ctx.Err(); err != nil {
+		return fmt.Errorf("apply abandoned: %w", err)
+	}
+	if s.cfg.ClockCheck != nil && ir.Scheduled() {
+		if err := s.cfg.ClockCheck(); err != nil {
+			return fmt.Errorf("refusing time-based rules: %w", err)
+		}
+	}
+	if err := s.cfg.Hooks.Run(ctx, hooks.PreApply, ir, nil); err != nil {
+		return fmt.Errorf("pre-apply %w", err)
+	}
+
+	err = s.apply(ctx, ir)
+	s.cfg.Hooks.Notify(hooks.PostApply, ir, err)
+	return err
+}
+
+func (s *Service) apply(ctx context.Context, ir *policy.IR) error {
 	s.mu.Lock()
 	defer s.mu.Unlock()
 
-	if err := s.adapter.Apply(ir); err != nil {
+	// Another apply may have held the lock until the deadline passed.
+	if err := ctx.Err(); err != nil {
+		return fmt.Errorf("apply abandoned: %w", err)
+	}
+	s.health.SetTargets(s.withMonitors(ir.HealthTargets))
+	if len(ir.FQDNSets) > 0 {
+		ir.FQDNSets = s.resolveFQDNs(ctx, ir.FQDNSets)
+	}
+	if err := s.wan.ApplyVRFs(ir.VRFs); err != nil {
+		return fmt.Errorf("vrfs: %w", err)
+	}
+	if err := s.wan.Apply(ir.WAN); err != nil {
+		return fmt.Errorf("wan routing: %w", err)
+	}
+	if err := s.applyGated(ctx, ir); err != nil {
 		return err
 	}
 	s.current = ir
+	setRulesActive(ir, metrics.TenantFrom(ctx))
+	for _, fn := range s.onApply {
+		fn(ir)
+	}
+	select {
+	case s.expiryRearm <- struct{}{}:
+	default:
+	}
 	return nil
 }
 
+// OnApply registers a callback run after each successful ApplyIR, for the
+// parts of the IR enforced outside nftables (e.g. Suricata rules).
+func (s *Service) OnApply(fn func(*policy.IR)) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.onApply = append(s.onApply, fn)
+}
+
 // ApplyPolicyDir reads all policies from the configured directory and applies them.
 func (s *Service) ApplyPolicyDir(ctx context.Context) error {
 	manifests, err := s.parser.ParseDir(s.cfg.PolicyDir)
@@ -71,36 +218,203 @@ func (s *Service) ApplyPolicyDir(ctx context.Context) error {
 	return s.ApplyManifests(ctx, manifests)
 }
 
-// DiffManifests returns what would change if manifests were applied.
-func (s *Service) DiffManifests(manifests []*policy.Manifest) (string, error) {
+// DiffManifests returns what would change if manifests were applied, both
+// as the backend's text diff against the live ruleset and as a structured
+// diff against the current IR, and the analysis warnings of their rules.
+func (s *Service) DiffManifests(manifests []*policy.Manifest) (string, *policy.IRDiff, []policy.Warning, error) {
 	ir, err := s.engine.Compile(manifests)
 	if err != nil {
-		return "", err
+		return "", nil, nil, err
 	}
-	return s.adapter.Diff(ir)
+	diff, err := s.adapter.Diff(gateIR(ir, s.health))
+	if err != nil {
+		return "", nil, nil, err
+	}
+	return diff, policy.DiffIR(s.CurrentIR(), ir), ir.Warnings, nil
+}
+
+// Compile compiles manifests with the engine Apply uses, so the IR carries
+// the same validation, ordering and limits.
+func (s *Service) Compile(manifests []*policy.Manifest) (*policy.IR, error) {
+	return s.engine.Compile(manifests)
 }
 
-// Rollback restores the previous ruleset.
+// CheckRawRules validates the rawRules snippets of manifests with `nft -c`
+// in a ruleset holding only them, so nft rejects a bad snippet when it is
+// written rather than when the policies are next applied. Snippets are
+// checked on their own, so they cannot refer to the sets of groups.
+func (s *Service) CheckRawRules(manifests []*policy.Manifest) error {
+	ir := &policy.IR{}
+	for _, m := range manifests {
+		for _, r := range policy.CompileRawRules(m) {
+			r.NetNS = ""
+			ir.RawRules = append(ir.RawRules, r)
+		}
+	}
+	if len(ir.RawRules) == 0 {
+		return nil
+	}
+	return s.adapter.Check(ir)
+}
+
+// Render returns the nft ruleset that applying ir would load, gated on the
+// current health state. Nothing touches the kernel.
+func (s *Service) Render(ir *policy.IR) (string, error) {
+	return s.adapter.Translate(gateIR(ir, s.health))
+}
+
+// TestFlows runs flows through the rules that applying manifests would
+// install right now, i.e. gated on the current health state. With no
+// manifests the running IR is used. Nothing touches the kernel.
+func (s *Service) TestFlows(manifests []*policy.Manifest, flows []policy.Flow) ([]policy.FlowResult, error) {
+	ir, err := s.simulationIR(manifests)
+	if err != nil {
+		return nil, err
+	}
+	results := make([]policy.FlowResult, len(flows))
+	for i, f := range flows {
+		results[i] = s.installedVerdict(ir.Simulate(f))
+	}
+	return results, nil
+}
+
+// Trace follows one packet through the NAT and firewall rules that
+// applying manifests would install right now, or the running IR's with no
+// manifests. Nothing touches the kernel.
+func (s *Service) Trace(manifests []*policy.Manifest, f policy.Flow) (policy.Trace, error) {
+	ir, err := s.simulationIR(manifests)
+	if err != nil {
+		return policy.Trace{}, err
+	}
+	t := ir.Trace(f)
+	t.FlowResult = s.installedVerdict(t.FlowResult)
+	return t, nil
+}
+
+// simulationIR compiles manifests, or takes the running IR when there are
+// none, and gates it on the current health state.
+func (s *Service) simulationIR(manifests []*policy.Manifest) (*policy.IR, error) {
+	ir := s.CurrentIR()
+	if len(manifests) > 0 {
+		compiled, err := s.engine.Compile(manifests)
+		if err != nil {
+			return nil, err
+		}
+		ir = compiled
+	}
+	if ir == nil {
+		return nil, fmt.Errorf("no policy applied and none supplied")
+	}
+	return gateIR(ir, s.health), nil
+}
+
+// installedVerdict adjusts res for how the rules are installed: without a
+// honeypot listener TARPIT rules are installed as drops.
+func (s *Service) installedVerdict(res policy.FlowResult) policy.FlowResult {
+	if res.Verdict == "tarpit" && s.cfg.TarpitPort == 0 {
+		res.Verdict = "drop"
+		if res.Flow.Expect != "" {
+			pass := strings.EqualFold(res.Flow.Expect, "drop")
+			res.Pass = &pass
+		}
+	}
+	return res
+}
+
+// Rollback restores the previous ruleset, on the host and in the network
+// namespaces holding the applied rules.
 func (s *Service) Rollback(ctx context.Context) error {
+	ctx, cancel := s.applyContext(ctx)
+	defer cancel()
+
 	s.mu.Lock()
-	defer s.mu.Unlock()
-	return s.adapter.Rollback()
+	err := s.adapter.Rollback(ctx)
+	for name, inst := range s.nsLoaded {
+		if nsErr := s.adapter.inNetNS(name, inst.path).Rollback(ctx); nsErr != nil {
+			s.log.Error("rollback network namespace", zap.String("netns", name), zap.Error(nsErr))
+		}
+	}
+	s.mu.Unlock()
+	s.cfg.Hooks.Notify(hooks.PostRollback, nil, err)
+	return err
 }
 
-// Flush removes all AegisX rules.
+// Flush removes all AegisX rules, including those loaded into network
+// namespaces.
 func (s *Service) Flush(ctx context.Context) error {
+	ctx, cancel := s.applyContext(ctx)
+	defer cancel()
+
 	s.mu.Lock()
 	defer s.mu.Unlock()
-	return s.adapter.Flush()
+	for name, inst := range s.nsLoaded {
+		if err := s.adapter.inNetNS(name, inst.path).Flush(ctx); err != nil {
+			return fmt.Errorf("network namespace %s: %w", name, err)
+		}
+		delete(s.nsLoaded, name)
+	}
+	return s.adapter.Flush(ctx)
 }
 
-// Status returns the currently applied ruleset as text.
-func (s *Service) Status() (string, error) {
+// setRulesActive replaces the rule counts with those of ir: an apply
+// replaces the whole ruleset, so the previous tenant's series go away.
+func setRulesActive(ir *policy.IR, tenant string) {
+	counts := map[string]int{"input": 0, "forward": 0, "output": 0}
+	for _, r := range ir.FirewallRules {
+		switch r.Chain {
+		case "input", "output":
+			counts[r.Chain]++
+		default:
+			counts["forward"]++
+		}
+	}
+	metrics.FirewallRulesActive.Reset()
+	for chain, n := range counts {
+		metrics.FirewallRulesActive.WithLabelValues(chain, tenant).Set(float64(n))
+	}
+}
+
+// hostInterfaces returns the names of the host's network interfaces.
+func hostInterfaces() []string {
+	ifs, err := net.Interfaces()
+	if err != nil {
+		return nil
+	}
+	names := make([]string, len(ifs))
+	for i, ifc := range ifs {
+		names[i] = ifc.Name
+	}
+	return names
+}
+
+// applyContext bounds ctx by the configured apply timeout.
+func (s *Service) applyContext(ctx context.Context) (context.Context, context.CancelFunc) {
+	if s.cfg.ApplyTimeout <= 0 {
+		return context.WithCancel(ctx)
+	}
+	return context.WithTimeout(ctx, s.cfg.ApplyTimeout)
+}
+
+// Status returns the currently applied table as parsed nft JSON.
+func (s *Service) Status() (*Ruleset, error) {
 	s.mu.RLock()
 	defer s.mu.RUnlock()
 	return s.adapter.Status()
 }
 
+// StatusText returns the currently applied ruleset as nft text.
+func (s *Service) StatusText() (string, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.adapter.StatusText()
+}
+
+// Parser returns the parser manifests are read with, strict or not as
+// configured.
+func (s *Service) Parser() *policy.Parser {
+	return s.parser
+}
+
 // CurrentIR returns the in-memory copy of the last applied IR.
 func (s *Service) CurrentIR() *policy.IR {
 	s.mu.RLock()
@@ -108,6 +422,44 @@ func (s *Service) CurrentIR() *policy.IR {
 	return s.current
 }
 
+// HealthStatus returns the state of every health-check target.
+func (s *Service) HealthStatus() []health.TargetStatus {
+	return s.health.Snapshot()
+}
+
+// WANStatus returns the state of each uplink of the applied WANPolicy.
+func (s *Service) WANStatus() []wan.UplinkStatus {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	if s.current == nil || s.current.WAN == nil {
+		return []wan.UplinkStatus{}
+	}
+	out := make([]wan.UplinkStatus, 0, len(s.current.WAN.Uplinks))
+	for _, u := range s.current.WAN.Uplinks {
+		healthy := true
+		if u.Check != "" {
+			up, known := s.health.State(u.Check)
+			healthy = up || !known
+		}
+		out = append(out, wan.NewUplinkStatus(u, healthy, s.uplinkActive[u.Name]))
+	}
+	return out
+}
+
+// VRFStatus returns the VRFs of the applied VRFPolicies.
+func (s *Service) VRFStatus() []wan.VRFStatus {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	if s.current == nil {
+		return []wan.VRFStatus{}
+	}
+	out := make([]wan.VRFStatus, 0, len(s.current.VRFs))
+	for _, v := range s.current.VRFs {
+		out = append(out, wan.NewVRFStatus(v))
+	}
+	return out
+}
+
 // WatchAndReload watches the policy directory for changes and hot-reloads.
 // Call this in a goroutine.
 func (s *Service) WatchAndReload(ctx context.Context) {
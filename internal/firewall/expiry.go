@@ -0,0 +1,77 @@
+package firewall
+
+import (
+	"context"
+	"time"
+
+	"go.uber.org/zap"
+
+	"github.com/aegisx/aegisx/internal/policy"
+)
+
+// WatchExpiry re-applies the current IR whenever one of its firewall rules
+// reaches its expiresAt, so the rule leaves the ruleset without a new apply
+// of the policy. Call this in a goroutine; it blocks until ctx is
+// cancelled.
+func (s *Service) WatchExpiry(ctx context.Context) {
+	for {
+		s.mu.RLock()
+		next, ok := nextExpiry(s.current, time.Now())
+		s.mu.RUnlock()
+
+		var fire <-chan time.Time
+		stop := func() bool { return false }
+		if ok {
+			timer := time.NewTimer(time.Until(next))
+			fire, stop = timer.C, timer.Stop
+		}
+		select {
+		case <-ctx.Done():
+			stop()
+			return
+		case <-s.expiryRearm:
+			stop()
+		case <-fire:
+			s.expire(ctx, next)
+		}
+	}
+}
+
+// ─── Private helpers ──────────────────────────────────────────────────────
+
+// expire re-applies the current IR without its expired rules and logs
+// those that expired since since.
+func (s *Service) expire(ctx context.Context, since time.Time) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.current == nil {
+		return
+	}
+	now := time.Now()
+	for _, r := range s.current.FirewallRules {
+		if r.ExpiresAt != nil && !r.ExpiresAt.Before(since) && !r.ExpiresAt.After(now) {
+			s.log.Info("firewall rule expired", zap.String("rule", r.Comment),
+				zap.Time("expiresAt", *r.ExpiresAt))
+		}
+	}
+	actx, cancel := s.applyContext(ctx)
+	defer cancel()
+	if err := s.applyGated(actx, s.current); err != nil {
+		s.log.Error("rule expiry re-apply failed", zap.Error(err))
+	}
+}
+
+// nextExpiry returns the earliest expiresAt of ir's firewall rules that is
+// after now.
+func nextExpiry(ir *policy.IR, now time.Time) (time.Time, bool) {
+	var next time.Time
+	if ir == nil {
+		return next, false
+	}
+	for _, r := range ir.FirewallRules {
+		if r.ExpiresAt != nil && r.ExpiresAt.After(now) && (next.IsZero() || r.ExpiresAt.Before(next)) {
+			next = *r.ExpiresAt
+		}
+	}
+	return next, !next.IsZero()
+}
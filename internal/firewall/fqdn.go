@@ -0,0 +1,165 @@
+package firewall
+
+import (
+	"context"
+	"net"
+	"net/netip"
+	"reflect"
+	"sort"
+	"sync"
+	"time"
+
+	"go.uber.org/zap"
+
+	"github.com/aegisx/aegisx/internal/policy"
+)
+
+// defaultFQDNTimeout bounds the lookup of each name when ServiceConfig
+// leaves FQDNTimeout unset.
+const defaultFQDNTimeout = 5 * time.Second
+
+// fqdnCache holds the last answer for every name, so a lookup that fails
+// keeps the addresses a set already matches instead of emptying it.
+type fqdnCache struct {
+	mu    sync.Mutex
+	addrs map[string][]string
+}
+
+// WatchFQDNs resolves the names of the applied IR's FQDN sets every
+// interval and swaps the elements of the sets whose addresses changed, in
+// one nft transaction, without reloading the ruleset.
+// Call this in a goroutine; it blocks until ctx is cancelled.
+func (s *Service) WatchFQDNs(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+		if err := s.refreshFQDNs(ctx); err != nil {
+			s.log.Error("refresh fqdn sets", zap.Error(err))
+		}
+	}
+}
+
+// refreshFQDNs re-resolves the FQDN sets of the applied IR. The lookups run
+// without the lock; an apply in the meantime wins and the answers wait for
+// the next round.
+func (s *Service) refreshFQDNs(ctx context.Context) error {
+	cur := s.CurrentIR()
+	if cur == nil || len(cur.FQDNSets) == 0 {
+		return nil
+	}
+	sets := s.resolveFQDNs(ctx, cur.FQDNSets)
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.current != cur {
+		return nil
+	}
+	changed := make(map[string][]string)
+	for i, fs := range sets {
+		if !reflect.DeepEqual(fs.Addresses, cur.FQDNSets[i].Addresses) {
+			changed[fs.Set] = fs.Addresses
+		}
+	}
+	if len(changed) == 0 {
+		return nil
+	}
+	if err := s.adapter.ReplaceSetElements(ctx, changed); err != nil {
+		return err
+	}
+	for name, inst := range s.nsLoaded {
+		if err := s.adapter.inNetNS(name, inst.path).ReplaceSetElements(ctx, changed); err != nil {
+			s.log.Warn("refresh fqdn sets in network namespace", zap.String("netns", name), zap.Error(err))
+		}
+	}
+
+	// Readers may hold the old IR; publish a copy rather than change it.
+	next := *cur
+	next.FQDNSets = sets
+	s.current = &next
+	s.log.Info("fqdn sets refreshed", zap.Int("changed", len(changed)))
+	return nil
+}
+
+// resolveFQDNs returns sets with Addresses filled from fresh lookups of
+// their names, or the last answer for names that fail to resolve.
+func (s *Service) resolveFQDNs(ctx context.Context, sets []policy.CompiledFQDNSet) []policy.CompiledFQDNSet {
+	var names []string
+	seen := make(map[string]bool)
+	for _, fs := range sets {
+		for _, n := range fs.FQDNs {
+			if !seen[n] {
+				seen[n] = true
+				names = append(names, n)
+			}
+		}
+	}
+
+	timeout := s.cfg.FQDNTimeout
+	if timeout <= 0 {
+		timeout = defaultFQDNTimeout
+	}
+	answers := make([][]string, len(names))
+	errs := make([]error, len(names))
+	var wg sync.WaitGroup
+	for i, n := range names {
+		wg.Add(1)
+		go func(i int, n string) {
+			defer wg.Done()
+			lctx, cancel := context.WithTimeout(ctx, timeout)
+			defer cancel()
+			addrs, err := net.DefaultResolver.LookupNetIP(lctx, "ip", n)
+			if err != nil {
+				errs[i] = err
+				return
+			}
+			for _, a := range addrs {
+				answers[i] = append(answers[i], a.Unmap().String())
+			}
+		}(i, n)
+	}
+	wg.Wait()
+
+	s.fqdns.mu.Lock()
+	if s.fqdns.addrs == nil {
+		s.fqdns.addrs = make(map[string][]string)
+	}
+	byName := make(map[string][]string, len(names))
+	for i, n := range names {
+		if errs[i] != nil {
+			s.log.Warn("resolve fqdn; keeping its last addresses",
+				zap.String("fqdn", n), zap.Int("addresses", len(s.fqdns.addrs[n])), zap.Error(errs[i]))
+			byName[n] = s.fqdns.addrs[n]
+			continue
+		}
+		s.fqdns.addrs[n] = answers[i]
+		byName[n] = answers[i]
+	}
+	s.fqdns.mu.Unlock()
+
+	out := make([]policy.CompiledFQDNSet, len(sets))
+	for i, fs := range sets {
+		fs.Addresses = nil
+		dedup := make(map[string]bool)
+		for _, n := range fs.FQDNs {
+			for _, a := range byName[n] {
+				if addrFamily(a) == fs.Family && !dedup[a] {
+					dedup[a] = true
+					fs.Addresses = append(fs.Addresses, a)
+				}
+			}
+		}
+		sort.Slice(fs.Addresses, func(a, b int) bool {
+			x, _ := netip.ParseAddr(fs.Addresses[a])
+			y, _ := netip.ParseAddr(fs.Addresses[b])
+			return x.Less(y)
+		})
+		out[i] = fs
+	}
+	return out
+}
@@ -0,0 +1,242 @@
+package firewall
+
+import (
+	"context"
+	"time"
+
+	"go.uber.org/zap"
+
+	"github.com/aegisx/aegisx/internal/health"
+	"github.com/aegisx/aegisx/internal/hooks"
+	"github.com/aegisx/aegisx/internal/metrics"
+	"github.com/aegisx/aegisx/internal/policy"
+)
+
+// onHealthChange re-applies the current IR so rules gated on the target are
+// installed or removed. The full ruleset is replaced atomically, so a flip
+// never leaves a half-applied state.
+func (s *Service) onHealthChange(name string, up bool) {
+	n := hooks.HealthNotice{Target: name, Up: up}
+	if st, ok := s.health.Status(name); ok {
+		n.Error, n.LatencyMs = st.LastError, st.LatencyMs
+	}
+	s.cfg.Hooks.NotifyHealth(n)
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.current == nil {
+		return
+	}
+	ctx, cancel := s.applyContext(context.Background())
+	defer cancel()
+	if err := s.applyGated(ctx, s.current); err != nil {
+		s.log.Error("failover re-apply failed",
+			zap.String("target", name), zap.Bool("up", up), zap.Error(err))
+		return
+	}
+	s.log.Info("failover rules re-applied", zap.String("target", name), zap.Bool("up", up))
+}
+
+// SetMonitors replaces the monitors: health targets managed through the API
+// rather than a HealthCheckPolicy. They are probed alongside the applied
+// policy's targets, and rule conditions may name them.
+func (s *Service) SetMonitors(targets []policy.HealthTarget) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.monMu.Lock()
+	s.monitors = append([]policy.HealthTarget(nil), targets...)
+	s.monMu.Unlock()
+
+	var own []policy.HealthTarget
+	if s.current != nil {
+		own = s.current.HealthTargets
+	}
+	s.health.SetTargets(s.withMonitors(own))
+}
+
+// OnHealthChange registers a callback run whenever a health target or
+// monitor flips between up and down.
+func (s *Service) OnHealthChange(fn func(name string, up bool)) {
+	s.health.OnChange(fn)
+}
+
+// PolicyTarget reports whether the applied policy defines a health target
+// called name.
+func (s *Service) PolicyTarget(name string) bool {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	if s.current == nil {
+		return false
+	}
+	for _, t := range s.current.HealthTargets {
+		if t.Name == name {
+			return true
+		}
+	}
+	return false
+}
+
+// TargetInUse reports whether a rule of the applied policy is conditioned
+// on the health target called name.
+func (s *Service) TargetInUse(name string) bool {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	if s.current == nil {
+		return false
+	}
+	for _, r := range s.current.FirewallRules {
+		if r.When != nil && r.When.Target == name {
+			return true
+		}
+	}
+	for _, r := range s.current.NATRules {
+		if r.When != nil && r.When.Target == name {
+			return true
+		}
+	}
+	return false
+}
+
+// isMonitor reports whether name is a monitor; the engine consults it so
+// rule conditions can refer to monitors.
+func (s *Service) isMonitor(name string) bool {
+	s.monMu.RLock()
+	defer s.monMu.RUnlock()
+	for _, t := range s.monitors {
+		if t.Name == name {
+			return true
+		}
+	}
+	return false
+}
+
+// withMonitors returns targets followed by the monitors.
+func (s *Service) withMonitors(targets []policy.HealthTarget) []policy.HealthTarget {
+	s.monMu.RLock()
+	defer s.monMu.RUnlock()
+	out := make([]policy.HealthTarget, 0, len(targets)+len(s.monitors))
+	out = append(out, targets...)
+	return append(out, s.monitors...)
+}
+
+// applyGated applies the gated view of ir and records which WAN uplinks it
+// steers to, and over which uplink each path goes. Callers hold s.mu.
+func (s *Service) applyGated(ctx context.Context, ir *policy.IR) error {
+	gated := gateIR(ir, s.health)
+	var paths []policy.CompiledWANPath
+	if ir.WAN != nil && len(ir.WAN.Paths) > 0 {
+		paths = selectPaths(ir.WAN, s.health, s.wanPaths)
+		w := *gated.WAN
+		w.Paths = paths
+		g := *gated
+		g.WAN = &w
+		gated = &g
+	}
+	loaded, err := s.applyNetNS(ctx, gated)
+	if err != nil {
+		return err
+	}
+	if err := s.adapter.Apply(ctx, gated); err != nil {
+		s.undoNetNS(ctx, loaded)
+		return err
+	}
+	s.commitNetNS(ctx, loaded)
+
+	active := make(map[string]bool)
+	if gated.WAN != nil {
+		for _, u := range gated.WAN.Uplinks {
+			active[u.Name] = true
+		}
+	}
+	if ir.WAN != nil {
+		for _, u := range ir.WAN.Uplinks {
+			if s.uplinkActive[u.Name] && !active[u.Name] {
+				metrics.WANFailoverTotal.WithLabelValues(u.Name).Inc()
+				s.log.Warn("wan uplink failed over", zap.String("uplink", u.Name))
+			}
+			v := 0.0
+			if active[u.Name] {
+				v = 1
+			}
+			metrics.WANUplinkActive.WithLabelValues(u.Name).Set(v)
+		}
+	}
+	s.uplinkActive = active
+	s.steerPaths(paths)
+	return nil
+}
+
+// gateIR returns a shallow copy of ir with only the firewall and NAT rules
+// whose condition currently holds, without the firewall rules that have
+// expired, and with only the WAN uplinks whose check passes. A target that
+// has not settled yet counts as up, so primary paths are used until proven
+// dead. If every uplink not reserved for paths is down all of them are
+// kept: there is nothing better to fail over to.
+func gateIR(ir *policy.IR, mon *health.Monitor) *policy.IR {
+	if len(ir.HealthTargets) == 0 && !conditioned(ir) {
+		return ir
+	}
+	isUp := func(target string) bool {
+		up, known := mon.State(target)
+		return up || !known
+	}
+	holds := func(c *policy.RuleCondition) bool {
+		return c == nil || isUp(c.Target) == (c.State == "up")
+	}
+	now := time.Now()
+
+	out := *ir
+	out.FirewallRules = nil
+	for _, r := range ir.FirewallRules {
+		if holds(r.When) && (r.ExpiresAt == nil || now.Before(*r.ExpiresAt)) {
+			out.FirewallRules = append(out.FirewallRules, r)
+		}
+	}
+	out.NATRules = nil
+	for _, r := range ir.NATRules {
+		if holds(r.When) {
+			out.NATRules = append(out.NATRules, r)
+		}
+	}
+	if ir.WAN != nil {
+		w := *ir.WAN
+		w.Uplinks = nil
+		for _, u := range ir.WAN.Uplinks {
+			if u.Check == "" || isUp(u.Check) {
+				w.Uplinks = append(w.Uplinks, u)
+			}
+		}
+		if !hasShared(w.Uplinks) {
+			w.Uplinks = ir.WAN.Uplinks
+		}
+		out.WAN = &w
+	}
+	return &out
+}
+
+// hasShared reports whether uplinks holds one not reserved for paths.
+func hasShared(uplinks []policy.CompiledWANUplink) bool {
+	for _, u := range uplinks {
+		if !u.PathsOnly {
+			return true
+		}
+	}
+	return false
+}
+
+// conditioned reports whether any rule of ir depends on a health target or
+// expires; without policy targets such rules can only name monitors.
+func conditioned(ir *policy.IR) bool {
+	for _, r := range ir.FirewallRules {
+		if r.When != nil || r.ExpiresAt != nil {
+			return true
+		}
+	}
+	for _, r := range ir.NATRules {
+		if r.When != nil {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,283 @@
+package firewall
+
+import (
+	"context"
+	"sort"
+	"sync"
+	"time"
+
+	"go.uber.org/zap"
+
+	"github.com/aegisx/aegisx/internal/policy"
+)
+
+// HitAnalysis is the result of the rule hit-rate analysis.
+type HitAnalysis struct {
+	Since       time.Time           `json:"since"` // first counter sample; zero before sampling started
+	UnusedAfter string              `json:"unusedAfter"`
+	Reorder     []ReorderSuggestion `json:"reorder"`
+	Unused      []UnusedRule        `json:"unused"`
+}
+
+// ReorderSuggestion proposes a new priority that moves a frequently hit rule
+// ahead of rules that are hit less often.
+type ReorderSuggestion struct {
+	Rule              string  `json:"rule"` // namespace/policy/rule
+	Chain             string  `json:"chain"`
+	Action            string  `json:"action"`
+	Packets           uint64  `json:"packets"`
+	PacketsPerHour    float64 `json:"packetsPerHour"`
+	Priority          int     `json:"priority"`
+	SuggestedPriority int     `json:"suggestedPriority"`
+}
+
+// UnusedRule is a rule that has not matched a packet for the whole period.
+type UnusedRule struct {
+	Rule          string     `json:"rule"`
+	Chain         string     `json:"chain"`
+	Action        string     `json:"action"`
+	Priority      int        `json:"priority"`
+	ObservedSince time.Time  `json:"observedSince"`
+	LastHit       *time.Time `json:"lastHit,omitempty"`
+}
+
+// ruleCounter is the packet and byte count of one rule, summed over every
+// nft rule carrying its comment.
+type ruleCounter struct {
+	Packets uint64
+	Bytes   uint64
+}
+
+// ruleHits accumulates the counters of a rule across samples. Every apply
+// replaces the table and resets the kernel counters, so totals are built
+// from the increase between samples.
+type ruleHits struct {
+	firstSeen time.Time
+	lastHit   time.Time
+	last      ruleCounter // raw counter at the previous sample
+	packets   uint64
+	bytes     uint64
+}
+
+// hitTracker keeps the accumulated counters of every rule seen so far.
+type hitTracker struct {
+	mu    sync.Mutex
+	since time.Time
+	rules map[string]*ruleHits
+}
+
+func newHitTracker() *hitTracker {
+	return &hitTracker{rules: make(map[string]*ruleHits)}
+}
+
+func (t *hitTracker) record(now time.Time, counters map[string]ruleCounter) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.since.IsZero() {
+		t.since = now
+	}
+	for name, c := range counters {
+		h, ok := t.rules[name]
+		if !ok {
+			h = &ruleHits{firstSeen: now}
+			t.rules[name] = h
+		}
+		dp, db := c.Packets, c.Bytes
+		if c.Packets >= h.last.Packets {
+			dp -= h.last.Packets
+			db -= h.last.Bytes
+		}
+		if dp > 0 {
+			h.lastHit = now
+		}
+		h.packets += dp
+		h.bytes += db
+		h.last = c
+	}
+}
+
+func (t *hitTracker) snapshot() (time.Time, map[string]ruleHits) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	out := make(map[string]ruleHits, len(t.rules))
+	for name, h := range t.rules {
+		out[name] = *h
+	}
+	return t.since, out
+}
+
+// ruleCounters reads the counter of every commented rule in the table.
+func (a *Adapter) ruleCounters() (map[string]ruleCounter, error) {
+	rs, err := a.dumpCurrent()
+	if err != nil {
+		return nil, err
+	}
+	counters := make(map[string]ruleCounter)
+	for _, r := range rs.Rules {
+		if r.Comment == "" {
+			continue
+		}
+		rc, ok := r.Counter()
+		if !ok {
+			continue
+		}
+		c := counters[r.Comment]
+		c.Packets += rc.Packets
+		c.Bytes += rc.Bytes
+		counters[r.Comment] = c
+	}
+	return counters, nil
+}
+
+// WatchHits samples the per-rule counters every interval for HitAnalysis.
+// Call this in a goroutine; it blocks until ctx is cancelled.
+func (s *Service) WatchHits(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		s.mu.RLock()
+		counters, err := s.adapter.ruleCounters()
+		s.mu.RUnlock()
+		if err != nil {
+			s.log.Debug("read rule counters", zap.Error(err))
+			continue
+		}
+		s.hits.record(time.Now(), counters)
+	}
+}
+
+// HitAnalysis suggests priority changes that put frequently hit rules first
+// and lists rules without a hit for unusedAfter. A zero unusedAfter uses the
+// configured period.
+func (s *Service) HitAnalysis(unusedAfter time.Duration) *HitAnalysis {
+	if unusedAfter <= 0 {
+		unusedAfter = s.cfg.UnusedAfter
+	}
+	since, hits := s.hits.snapshot()
+	return analyzeHits(s.CurrentIR(), since, hits, unusedAfter, time.Now())
+}
+
+// analyzeHits ranks the rules of ir by hit rate. Only rules whose relative
+// order cannot change a verdict are reordered: a run of consecutive rules in
+// a chain with the same action, none of which logs, rate-limits, or matches
+// everything. Within a run the rules keep the run's priority values, handed
+// out again by descending hit rate.
+func analyzeHits(ir *policy.IR, since time.Time, hits map[string]ruleHits, unusedAfter time.Duration, now time.Time) *HitAnalysis {
+	res := &HitAnalysis{
+		Since:       since,
+		UnusedAfter: unusedAfter.String(),
+		Reorder:     []ReorderSuggestion{},
+		Unused:      []UnusedRule{},
+	}
+	if ir == nil {
+		return res
+	}
+
+	rate := func(r policy.CompiledFirewallRule) float64 {
+		h, ok := hits[nftComment(r.Comment)]
+		if !ok {
+			return 0
+		}
+		hours := now.Sub(h.firstSeen).Hours()
+		if hours <= 0 {
+			return 0
+		}
+		return float64(h.packets) / hours
+	}
+
+	// IR rules are sorted by priority, so per chain they are in nft order.
+	// Namespaces, VRFs and user-defined chains have chains of their own.
+	chains := make(map[string][]policy.CompiledFirewallRule)
+	var order []string
+	for _, r := range ir.FirewallRules {
+		key := r.NetNS + "/" + r.VRF + "/" + r.Chain + "/" + r.UserChain
+		if _, ok := chains[key]; !ok {
+			order = append(order, key)
+		}
+		chains[key] = append(chains[key], r)
+	}
+
+	for _, chain := range order {
+		rules := chains[chain]
+		for start := 0; start < len(rules); {
+			end := start + 1
+			if reorderable(rules[start]) {
+				for end < len(rules) && reorderable(rules[end]) && rules[end].Action == rules[start].Action {
+					end++
+				}
+			}
+			res.Reorder = append(res.Reorder, reorderRun(rules[start:end], hits, rate)...)
+			start = end
+		}
+	}
+
+	cutoff := now.Add(-unusedAfter)
+	for _, r := range ir.FirewallRules {
+		h, ok := hits[nftComment(r.Comment)]
+		if !ok || h.firstSeen.After(cutoff) || h.lastHit.After(cutoff) {
+			continue
+		}
+		u := UnusedRule{Rule: r.Comment, Chain: r.Chain, Action: r.Action, Priority: r.Priority, ObservedSince: h.firstSeen}
+		if !h.lastHit.IsZero() {
+			last := h.lastHit
+			u.LastHit = &last
+		}
+		res.Unused = append(res.Unused, u)
+	}
+	return res
+}
+
+// reorderRun returns the suggestions for one run of interchangeable rules.
+func reorderRun(run []policy.CompiledFirewallRule, hits map[string]ruleHits, rate func(policy.CompiledFirewallRule) float64) []ReorderSuggestion {
+	if len(run) < 2 {
+		return nil
+	}
+	priorities := make([]int, len(run))
+	for i, r := range run {
+		priorities[i] = r.Priority
+	}
+	ranked := append([]policy.CompiledFirewallRule(nil), run...)
+	sort.SliceStable(ranked, func(i, j int) bool { return rate(ranked[i]) > rate(ranked[j]) })
+
+	var out []ReorderSuggestion
+	for i, r := range ranked {
+		if priorities[i] == r.Priority {
+			continue
+		}
+		out = append(out, ReorderSuggestion{
+			Rule:              r.Comment,
+			Chain:             r.Chain,
+			Action:            r.Action,
+			Packets:           hits[nftComment(r.Comment)].packets,
+			PacketsPerHour:    rate(r),
+			Priority:          r.Priority,
+			SuggestedPriority: priorities[i],
+		})
+	}
+	return out
+}
+
+// reorderable reports whether a rule may be moved among rules with the same
+// action without changing what the ruleset does.
+func reorderable(r policy.CompiledFirewallRule) bool {
+	if r.Comment == "" || r.Log || r.RateLimit != "" || r.ConnLimit > 0 || r.Action == "tarpit" {
+		return false
+	}
+	// Jumps to different chains do not commute, nor do marks, where the
+	// last rule to match wins.
+	switch r.Action {
+	case "jump", "goto", "return", "mark", "dscp":
+		return false
+	}
+	// A rule without a match takes every packet; moving it would shadow the
+	// rules after it.
+	return r.Protocol != "" || len(r.SrcAddrs) > 0 || len(r.DstAddrs) > 0 || len(r.SrcMACs) > 0 ||
+		len(r.SrcPorts) > 0 || len(r.DstPorts) > 0 || len(r.States) > 0 || r.Schedule != nil
+}
@@ -0,0 +1,121 @@
+package firewall
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/aegisx/aegisx/internal/policy"
+)
+
+// ipsPriority places the queue chains after the filter chains (priority 0):
+// a packet dropped by the firewall never reaches Suricata, and one accepted
+// there is still inspected.
+const ipsPriority = 10
+
+// IPSQueue describes how Suricata consumes packets in inline (ips) mode. It
+// must match the queues Suricata was started with (`suricata -q <num>`).
+type IPSQueue struct {
+	Num      int      // first NFQUEUE number
+	Count    int      // queues to fan out over, one per Suricata thread; default 1
+	FailOpen bool     // accept packets while no Suricata is attached instead of dropping them
+	Hooks    []string // input | forward | output; default forward
+}
+
+type ipsChain struct {
+	Hook  string
+	Rules []string
+}
+
+// translateIPS builds one queue chain per hook. Bypass entries from the IR are
+// accepted before the queue statement, in both directions.
+func (a *Adapter) translateIPS(q *IPSQueue, bypass []policy.CompiledIPSBypass) []ipsChain {
+	hooks := q.Hooks
+	if len(hooks) == 0 {
+		hooks = []string{"forward"}
+	}
+
+	var common []string
+	for _, b := range bypass {
+		common = append(common, translateBypass(b)...)
+	}
+	queue := translateQueue(q)
+
+	var chains []ipsChain
+	for _, hook := range hooks {
+		var rules []string
+		if hook == "input" {
+			rules = append(rules, `iif lo accept comment "loopback"`)
+		} else if hook == "output" {
+			rules = append(rules, `oif lo accept comment "loopback"`)
+		}
+		rules = append(rules, common...)
+		chains = append(chains, ipsChain{Hook: hook, Rules: append(rules, queue)})
+	}
+	return chains
+}
+
+// translateBypass accepts matching traffic in both directions. Addresses of
+// both families need a pair of rules each.
+func translateBypass(b policy.CompiledIPSBypass) []string {
+	match := func(family string, addrs []string, dir string) string {
+		var parts []string
+		if len(addrs) > 0 {
+			parts = append(parts, family+" "+dir+"addr "+nftSet(addrs))
+		}
+		if len(b.Ports) > 0 {
+			parts = append(parts, b.Protocol+" "+dir+"port "+nftSet(b.Ports))
+		} else if b.Protocol != "" {
+			parts = append(parts, "meta l4proto "+b.Protocol)
+		}
+		return strings.Join(parts, " ")
+	}
+	comment := fmt.Sprintf(`accept comment "%s"`, nftComment("ips bypass "+b.Comment))
+
+	var v4, v6 []string
+	for _, a := range b.Addrs {
+		if addrFamily(a) == policy.FamilyIPv6 {
+			v6 = append(v6, a)
+		} else {
+			v4 = append(v4, a)
+		}
+	}
+	var rules []string
+	for _, f := range []struct {
+		family string
+		addrs  []string
+	}{{policy.FamilyIPv4, v4}, {policy.FamilyIPv6, v6}} {
+		if len(f.addrs) == 0 && len(b.Addrs) > 0 {
+			continue
+		}
+		rules = append(rules,
+			match(f.family, f.addrs, "d")+" "+comment,
+			match(f.family, f.addrs, "s")+" "+comment)
+		if len(b.Addrs) == 0 {
+			break // ports only: one pair matches both families
+		}
+	}
+	return rules
+}
+
+func translateQueue(q *IPSQueue) string {
+	stmt := fmt.Sprintf("queue num %d", q.Num)
+	var flags []string
+	if q.Count > 1 {
+		stmt = fmt.Sprintf("queue num %d-%d", q.Num, q.Num+q.Count-1)
+		flags = append(flags, "fanout")
+	}
+	if q.FailOpen {
+		flags = append(flags, "bypass")
+	}
+	if len(flags) > 0 {
+		stmt += " " + strings.Join(flags, ",")
+	}
+	return stmt + ` comment "ips"`
+}
+
+func nftSet(items []string) string {
+	if len(items) == 1 {
+		return items[0]
+	}
+	return "{ " + strings.Join(items, ", ") + " }"
+}
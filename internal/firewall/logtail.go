@@ -0,0 +1,172 @@
+package firewall
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// LogEvent is one packet logged by a rule with log enabled, as read back
+// from the kernel log.
+type LogEvent struct {
+	At       time.Time `json:"at"`
+	Rule     string    `json:"rule"`   // the rule comment, e.g. "ns/policy/rule"
+	Action   string    `json:"action"` // the rule's verdict; "log" when unknown
+	InIface  string    `json:"inIface,omitempty"`
+	OutIface string    `json:"outIface,omitempty"`
+	SrcIP    string    `json:"srcIp"`
+	DstIP    string    `json:"dstIp"`
+	Protocol string    `json:"protocol"`
+	SrcPort  int       `json:"srcPort,omitempty"`
+	DstPort  int       `json:"dstPort,omitempty"`
+}
+
+// Blocked reports whether the logged packet was refused.
+func (e LogEvent) Blocked() bool {
+	switch e.Action {
+	case "drop", "reject", "tarpit":
+		return true
+	}
+	return false
+}
+
+// logLineRe matches the prefix the ruleset puts on logged packets and
+// captures the rule comment and the netfilter key=value fields.
+var logLineRe = regexp.MustCompile(`\[aegisx\] (.+?): (IN=.*)$`)
+
+// ParseLogLine parses one kernel log line written by a ruleset log
+// statement. The action is left empty.
+func ParseLogLine(line string) (LogEvent, bool) {
+	m := logLineRe.FindStringSubmatch(strings.TrimRight(line, "\r\n"))
+	if m == nil {
+		return LogEvent{}, false
+	}
+	ev := LogEvent{Rule: m[1]}
+	for _, f := range strings.Fields(m[2]) {
+		k, v, ok := strings.Cut(f, "=")
+		if !ok {
+			continue
+		}
+		switch k {
+		case "IN":
+			ev.InIface = v
+		case "OUT":
+			ev.OutIface = v
+		case "SRC":
+			ev.SrcIP = v
+		case "DST":
+			ev.DstIP = v
+		case "PROTO":
+			ev.Protocol = strings.ToLower(v)
+		case "SPT":
+			ev.SrcPort, _ = strconv.Atoi(v)
+		case "DPT":
+			ev.DstPort, _ = strconv.Atoi(v)
+		}
+	}
+	if ev.SrcIP == "" || ev.DstIP == "" {
+		return LogEvent{}, false
+	}
+	return ev, true
+}
+
+// TailLog follows the kernel log at path and passes every packet logged by
+// the ruleset to fn, with the action of the rule that logged it taken from
+// the applied IR. It starts at the end of the file and reopens it after
+// rotation. Call this in a goroutine; it blocks until ctx is cancelled.
+func (s *Service) TailLog(ctx context.Context, path string, fn func(LogEvent)) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return fmt.Errorf("open kernel log: %w", err)
+	}
+	defer func() { f.Close() }()
+	if _, err := f.Seek(0, io.SeekEnd); err != nil {
+		return err
+	}
+
+	reader := bufio.NewReader(f)
+	var partial []byte
+	ticker := time.NewTicker(time.Second)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-ticker.C:
+		}
+
+		var actions map[string]string
+		for {
+			chunk, err := reader.ReadBytes('\n')
+			partial = append(partial, chunk...)
+			if err != nil {
+				break
+			}
+			if ev, ok := ParseLogLine(string(partial)); ok {
+				if actions == nil {
+					actions = s.ruleActions()
+				}
+				ev.At = time.Now()
+				ev.Action = actions[ev.Rule]
+				if ev.Action == "" {
+					ev.Action = "log"
+				}
+				fn(ev)
+			}
+			partial = nil
+		}
+
+		// Reopen when logrotate moved the file away or truncated it.
+		if logRotated(f, path) {
+			nf, err := os.Open(path)
+			if err != nil {
+				continue // not recreated yet
+			}
+			f.Close()
+			f = nf
+			reader.Reset(f)
+			partial = nil
+		}
+	}
+}
+
+// ruleActions maps the comment of every applied firewall rule, as its log
+// prefix holds it, to its action. Comments carry the policy and rule name,
+// so they identify rules.
+func (s *Service) ruleActions() map[string]string {
+	out := make(map[string]string)
+	ir := s.CurrentIR()
+	if ir == nil {
+		return out
+	}
+	for _, r := range ir.FirewallRules {
+		name := logRule(r.Comment)
+		if _, ok := out[name]; !ok {
+			out[name] = r.Action
+		}
+	}
+	return out
+}
+
+func logRotated(f *os.File, path string) bool {
+	cur, err := f.Stat()
+	if err != nil {
+		return true
+	}
+	onDisk, err := os.Stat(path)
+	if err != nil {
+		return false
+	}
+	if !os.SameFile(cur, onDisk) {
+		return true
+	}
+	pos, err := f.Seek(0, io.SeekCurrent)
+	return err == nil && onDisk.Size() < pos
+}
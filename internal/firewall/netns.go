@@ -0,0 +1,257 @@
+package firewall
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"time"
+
+	"go.uber.org/zap"
+
+	"github.com/aegisx/aegisx/internal/policy"
+)
+
+// defaultNetNSDirs are where `ip netns` and Docker bind-mount the network
+// namespaces they create.
+var defaultNetNSDirs = []string{"/run/netns", "/var/run/docker/netns"}
+
+// NetNS is a network namespace found on the host.
+type NetNS struct {
+	Name   string `json:"name"`
+	Path   string `json:"path"`
+	Rules  int    `json:"rules"`  // firewall and NAT rules of the applied IR targeting it
+	Loaded bool   `json:"loaded"` // holds the applied IR's rules
+}
+
+// netnsInstance is one namespace behind a name. A namespace recreated
+// under the same name, e.g. by a container restart, is a new instance.
+type netnsInstance struct {
+	path string
+	info os.FileInfo
+}
+
+func (i netnsInstance) same(o netnsInstance) bool {
+	return i.info != nil && o.info != nil && os.SameFile(i.info, o.info)
+}
+
+// NetNamespaces lists the network namespaces in the configured directories
+// and how many of the applied IR's rules target each. Namespaces the IR
+// targets that do not exist yet are listed without a path.
+func (s *Service) NetNamespaces() []NetNS {
+	found := s.discoverNetNS()
+
+	s.mu.RLock()
+	rules := make(map[string]int)
+	if s.current != nil {
+		for _, r := range s.current.FirewallRules {
+			rules[r.NetNS]++
+		}
+		for _, r := range s.current.NATRules {
+			rules[r.NetNS]++
+		}
+		for _, r := range s.current.RawRules {
+			rules[r.NetNS] += len(r.Rules)
+		}
+	}
+	loaded := make(map[string]bool, len(s.nsLoaded))
+	for name, inst := range s.nsLoaded {
+		loaded[name] = inst.same(found[name])
+	}
+	s.mu.RUnlock()
+
+	out := make([]NetNS, 0, len(found))
+	for name, inst := range found {
+		out = append(out, NetNS{Name: name, Path: inst.path, Rules: rules[name], Loaded: loaded[name]})
+	}
+	for name, n := range rules {
+		if _, ok := found[name]; !ok && name != "" {
+			out = append(out, NetNS{Name: name, Rules: n})
+		}
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].Name < out[j].Name })
+	return out
+}
+
+// WatchNetNS looks for the network namespaces the applied IR targets every
+// interval, and loads its rules into those that appeared or were recreated
+// since the last look, so a container started after an apply is covered.
+// Call this in a goroutine; it blocks until ctx is cancelled.
+func (s *Service) WatchNetNS(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+		s.syncNetNS(ctx)
+	}
+}
+
+// ─── Private helpers ──────────────────────────────────────────────────────
+
+// nft returns the command running nft with args in the adapter's network
+// namespace.
+func (a *Adapter) nft(ctx context.Context, args ...string) *exec.Cmd {
+	if a.nsPath == "" {
+		return exec.CommandContext(ctx, "nft", args...)
+	}
+	return exec.CommandContext(ctx, "nsenter", append([]string{"--net=" + a.nsPath, "--", "nft"}, args...)...)
+}
+
+// inNetNS returns an adapter for the same table inside the namespace at
+// path. It installs only the rules targeting the namespace; the runtime
+// sets, scan detection, IPS queue, tarpit and WAN steering stay on the host.
+func (a *Adapter) inNetNS(name, path string) *Adapter {
+	return &Adapter{
+		tableName:      a.tableName,
+		rollbackDir:    filepath.Join(a.rollbackDir, "netns", name),
+		dryRun:         a.dryRun,
+		log:            a.log.With(zap.String("netns", name)),
+		maxRenderBytes: a.maxRenderBytes,
+		netns:          name,
+		nsPath:         path,
+	}
+}
+
+// discoverNetNS returns the namespaces in the configured directories by
+// name. A name found in several directories resolves to the first.
+func (s *Service) discoverNetNS() map[string]netnsInstance {
+	dirs := s.cfg.NetNSDirs
+	if len(dirs) == 0 {
+		dirs = defaultNetNSDirs
+	}
+	found := make(map[string]netnsInstance)
+	for _, dir := range dirs {
+		entries, err := os.ReadDir(dir)
+		if err != nil {
+			if !os.IsNotExist(err) {
+				s.log.Warn("list network namespaces", zap.String("dir", dir), zap.Error(err))
+			}
+			continue
+		}
+		for _, e := range entries {
+			if e.IsDir() {
+				continue
+			}
+			if _, ok := found[e.Name()]; ok {
+				continue
+			}
+			path := filepath.Join(dir, e.Name())
+			info, err := os.Stat(path)
+			if err != nil {
+				continue
+			}
+			found[e.Name()] = netnsInstance{path: path, info: info}
+		}
+	}
+	return found
+}
+
+// applyNetNS loads the rules of ir into every namespace it targets that
+// exists; the others are loaded by WatchNetNS once they appear. If one
+// namespace fails, those already loaded are restored and the error
+// returned. s.mu must be held.
+func (s *Service) applyNetNS(ctx context.Context, ir *policy.IR) (map[string]netnsInstance, error) {
+	targets := ir.NetNamespaces()
+	loaded := make(map[string]netnsInstance, len(targets))
+	if len(targets) == 0 {
+		return loaded, nil
+	}
+	found := s.discoverNetNS()
+	for _, name := range targets {
+		inst, ok := found[name]
+		if !ok {
+			s.log.Info("network namespace not found; its rules load when it appears", zap.String("netns", name))
+			continue
+		}
+		if err := s.adapter.inNetNS(name, inst.path).Apply(ctx, ir); err != nil {
+			s.undoNetNS(ctx, loaded)
+			return nil, fmt.Errorf("network namespace %s: %w", name, err)
+		}
+		loaded[name] = inst
+	}
+	return loaded, nil
+}
+
+// undoNetNS puts back what the namespaces of loaded held before the
+// current apply: the previous ruleset, or nothing. s.mu must be held.
+func (s *Service) undoNetNS(ctx context.Context, loaded map[string]netnsInstance) {
+	ctx = context.WithoutCancel(ctx)
+	for name, inst := range loaded {
+		a := s.adapter.inNetNS(name, inst.path)
+		var err error
+		if prev, ok := s.nsLoaded[name]; ok && prev.same(inst) {
+			err = a.Rollback(ctx)
+		} else {
+			err = a.Flush(ctx)
+		}
+		if err != nil {
+			s.log.Error("restore network namespace", zap.String("netns", name), zap.Error(err))
+		}
+	}
+}
+
+// commitNetNS records loaded as the namespaces holding the applied rules
+// and removes the table from those that held the previous rules and are
+// no longer targeted. s.mu must be held.
+func (s *Service) commitNetNS(ctx context.Context, loaded map[string]netnsInstance) {
+	found := s.discoverNetNS()
+	for name, prev := range s.nsLoaded {
+		if _, ok := loaded[name]; ok {
+			continue
+		}
+		if inst, ok := found[name]; !ok || !inst.same(prev) {
+			continue // gone, and its rules with it
+		}
+		if err := s.adapter.inNetNS(name, prev.path).Flush(ctx); err != nil {
+			s.log.Error("remove rules from network namespace", zap.String("netns", name), zap.Error(err))
+		}
+	}
+	s.nsLoaded = loaded
+}
+
+// syncNetNS loads the applied rules into the targeted namespaces that do
+// not hold them yet, and forgets those that went away.
+func (s *Service) syncNetNS(ctx context.Context) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.current == nil {
+		return
+	}
+	targets := s.current.NetNamespaces()
+	if len(targets) == 0 && len(s.nsLoaded) == 0 {
+		return
+	}
+	found := s.discoverNetNS()
+	for name := range s.nsLoaded {
+		if inst, ok := found[name]; !ok || !inst.same(s.nsLoaded[name]) {
+			delete(s.nsLoaded, name)
+		}
+	}
+
+	var gated *policy.IR
+	for _, name := range targets {
+		inst, ok := found[name]
+		if _, loaded := s.nsLoaded[name]; !ok || loaded {
+			continue
+		}
+		if gated == nil {
+			gated = gateIR(s.current, s.health)
+		}
+		actx, cancel := s.applyContext(ctx)
+		err := s.adapter.inNetNS(name, inst.path).Apply(actx, gated)
+		cancel()
+		if err != nil {
+			s.log.Error("load rules into network namespace", zap.String("netns", name), zap.Error(err))
+			continue
+		}
+		s.nsLoaded[name] = inst
+		s.log.Info("rules loaded into network namespace", zap.String("netns", name), zap.String("path", inst.path))
+	}
+}
@@ -4,13 +4,18 @@ package firewall
 
 import (
 	"bytes"
+	"context"
 	"fmt"
+	"hash/fnv"
+	"net"
 	"os"
-	"os/exec"
 	"path/filepath"
+	"sort"
 	"strings"
 	"text/template"
 	"time"
+	"unicode"
+	"unicode/utf8"
 
 	"go.uber.org/zap"
 
@@ -26,12 +31,87 @@ table inet {{ .TableName }} {
         ct state invalid drop comment "drop invalid"
         ct state { established, related } accept comment "accept established"
     }
+{{- if .GroupSets }}
+
+    # ── Address and service groups ────────────────────────────────────
+    {{ range .GroupSets }}{{ . }}
+    {{ end }}
+{{- end }}
+{{- if .CountrySets }}
+
+    # ── Country sets (elements are resolved from GeoIP at runtime) ────
+    {{ range .CountrySets }}{{ . }}
+    {{ end }}
+{{- end }}
+{{- if .ConnLimitSets }}
+
+    # ── Connection limits (elements are counted by conntrack) ─────────
+    {{ range .ConnLimitSets }}{{ . }}
+    {{ end }}
+{{- end }}
+{{- if .Bans }}
+
+    # ── Brute-force bans (elements are managed at runtime) ────────────
+    set ban_list { type ipv4_addr; flags timeout; }
+    set ban_list6 { type ipv6_addr; flags timeout; }
+{{- end }}
+{{- if .BlockLists }}
+
+    # ── Block lists (elements are managed at runtime) ─────────────────
+    set blocklist4 { type ipv4_addr; flags interval; counter; }
+    set blocklist6 { type ipv6_addr; flags interval; counter; }
+{{- end }}
+{{- if .ScanRules }}
+
+    # ── Port scan detection ───────────────────────────────────────────
+    {{ range .ScanSets }}{{ . }}
+    {{ end }}
+    chain scan_detect {
+        {{ range .ScanRules }}{{ . }}
+        {{ end }}
+    }
+{{- end }}
+{{- range .UserChains }}
+
+    # ── Chain {{ .ID }} ──────────────────────────────────────
+    chain {{ .Name }} {
+        {{ range .Rules }}{{ . }}
+        {{ end }}
+    }
+{{- end }}
+{{- range .VRFChains }}
+
+    # ── VRF {{ .VRF }} ({{ .Hook }}) ──────────────────────────────────────
+    chain {{ .Name }} {
+        {{ range .Rules }}{{ . }}
+        {{ end }}
+    }
+{{- end }}
+{{- range .RawChains }}
+
+    # ── Raw rules ({{ .Hook }}) ───────────────────────────────────────────
+    chain {{ .Name }} {
+        {{ range .Rules }}{{ . }}
+        {{ end }}
+    }
+{{- end }}
 
     # ── Input chain ────────────────────────────────────────────────────
     chain input {
         type filter hook input priority 0; policy {{ .DefaultInputPolicy }};
+        {{- if .Bans }}
+        ip saddr @ban_list drop comment "banned"
+        ip6 saddr @ban_list6 drop comment "banned"
+        {{- end }}
+        {{- if .BlockLists }}
+        ip saddr @blocklist4 drop comment "blocklist"
+        ip6 saddr @blocklist6 drop comment "blocklist"
+        {{- end }}
         jump ct_state
         iif lo accept comment "loopback"
+        {{- if .ScanRules }}
+        jump scan_detect
+        {{- end }}
         {{ range .InputRules }}{{ . }}
         {{ end }}
     }
@@ -39,7 +119,20 @@ table inet {{ .TableName }} {
     # ── Forward chain ──────────────────────────────────────────────────
     chain forward {
         type filter hook forward priority 0; policy {{ .DefaultForwardPolicy }};
+        {{- if .Bans }}
+        ip saddr @ban_list drop comment "banned"
+        ip6 saddr @ban_list6 drop comment "banned"
+        {{- end }}
+        {{- if .BlockLists }}
+        ip saddr @blocklist4 drop comment "blocklist"
+        ip6 saddr @blocklist6 drop comment "blocklist"
+        ip daddr @blocklist4 drop comment "blocklist"
+        ip6 daddr @blocklist6 drop comment "blocklist"
+        {{- end }}
         jump ct_state
+        {{- if .ScanRules }}
+        jump scan_detect
+        {{- end }}
         {{ range .ForwardRules }}{{ . }}
         {{ end }}
     }
@@ -47,10 +140,23 @@ table inet {{ .TableName }} {
     # ── Output chain ───────────────────────────────────────────────────
     chain output {
         type filter hook output priority 0; policy {{ .DefaultOutputPolicy }};
+        {{- if .BlockLists }}
+        ip daddr @blocklist4 drop comment "blocklist"
+        ip6 daddr @blocklist6 drop comment "blocklist"
+        {{- end }}
         ct state { established, related } accept
         {{ range .OutputRules }}{{ . }}
         {{ end }}
     }
+{{- range .IPSChains }}
+
+    # ── IPS queue ({{ .Hook }}) ──────────────────────────────────────────
+    chain ips_{{ .Hook }} {
+        type filter hook {{ .Hook }} priority {{ $.IPSPriority }}; policy accept;
+        {{ range .Rules }}{{ . }}
+        {{ end }}
+    }
+{{- end }}
 
     # ── NAT prerouting ────────────────────────────────────────────────
     chain prerouting {
@@ -59,6 +165,34 @@ table inet {{ .TableName }} {
         {{ end }}
     }
 
+{{- if .MarkPreRules }}
+
+    # ── Packet marks and DSCP ─────────────────────────────────────────
+    chain mark_prerouting {
+        type filter hook prerouting priority dstnat + 1; policy accept;
+        {{ range .MarkPreRules }}{{ . }}
+        {{ end }}
+    }
+{{- end }}
+{{- if .MarkOutputRules }}
+
+    chain mark_output {
+        type route hook output priority mangle; policy accept;
+        {{ range .MarkOutputRules }}{{ . }}
+        {{ end }}
+    }
+{{- end }}
+
+{{- if .WANMarkRules }}
+
+    # ── Multi-WAN steering ────────────────────────────────────────────
+    chain wan_mark {
+        type filter hook prerouting priority mangle; policy accept;
+        {{ range .WANMarkRules }}{{ . }}
+        {{ end }}
+    }
+{{- end }}
+
     # ── NAT postrouting ───────────────────────────────────────────────
     chain postrouting {
         type nat hook postrouting priority srcnat;
@@ -73,7 +207,20 @@ type Adapter struct {
 	tableName   string
 	rollbackDir string
 	dryRun      bool
+	ips         *IPSQueue // nil unless Suricata runs inline
+	tarpitPort  int       // honeypot listener; 0 turns TARPIT into DROP
+	scan        *ScanDetection
+	bans        bool // declare and enforce the runtime ban sets
+	blockLists  bool // declare and enforce the runtime block list sets
 	log         *zap.Logger
+
+	maxRenderBytes int // refuse larger rulesets; 0 means no limit
+
+	// netns is set on the adapters of network namespaces; see inNetNS.
+	netns  string
+	nsPath string
+
+	lastApply *ApplyRecord
 }
 
 // NewAdapter creates an nftables adapter.
@@ -87,8 +234,17 @@ func NewAdapter(tableName, rollbackDir string, dryRun bool, log *zap.Logger) *Ad
 }
 
 // Apply translates ir and atomically applies the ruleset.
-// On failure it attempts an automatic rollback.
-func (a *Adapter) Apply(ir *policy.IR) error {
+// On failure it attempts an automatic rollback. If ctx ends while nft runs,
+// nft is killed; its transaction is then either fully loaded or not at all.
+func (a *Adapter) Apply(ctx context.Context, ir *policy.IR) (err error) {
+	start := time.Now()
+	defer func() {
+		a.lastApply = &ApplyRecord{At: start, Duration: time.Since(start).String(), IRID: ir.ID}
+		if err != nil {
+			a.lastApply.Error = err.Error()
+		}
+	}()
+
 	ruleset, err := a.Translate(ir)
 	if err != nil {
 		return fmt.Errorf("translate: %w", err)
@@ -117,11 +273,12 @@ func (a *Adapter) Apply(ir *policy.IR) error {
 	tmpFile.Close()
 
 	// Flush + replace atomically.
-	out, err := exec.Command("nft", "-f", tmpFile.Name()).CombinedOutput()
+	out, err := a.nft(ctx, "-f", tmpFile.Name()).CombinedOutput()
 	if err != nil {
 		a.log.Error("nft apply failed, attempting rollback",
 			zap.Error(err), zap.String("output", string(out)))
-		if rbErr := a.Rollback(); rbErr != nil {
+		// The rollback must run even when ctx has ended.
+		if rbErr := a.Rollback(context.WithoutCancel(ctx)); rbErr != nil {
 			a.log.Error("rollback also failed", zap.Error(rbErr))
 		}
 		return fmt.Errorf("nft -f failed: %w (output: %s)", err, out)
@@ -144,6 +301,21 @@ func (a *Adapter) Translate(ir *policy.IR) (string, error) {
 		OutputRules          []string
 		DNATRules            []string
 		SNATRules            []string
+		WANMarkRules         []string
+		MarkPreRules         []string
+		MarkOutputRules      []string
+		IPSChains            []ipsChain
+		VRFChains            []*vrfChain
+		UserChains           []*userChain
+		RawChains            []*rawChain
+		Bans                 bool
+		BlockLists           bool
+		GroupSets            []string
+		CountrySets          []string
+		ConnLimitSets        []string
+		ScanSets             []string
+		ScanRules            []string
+		IPSPriority          int
 	}
 
 	data := templateData{
@@ -152,33 +324,204 @@ func (a *Adapter) Translate(ir *policy.IR) (string, error) {
 		DefaultInputPolicy:   "drop",
 		DefaultForwardPolicy: "drop",
 		DefaultOutputPolicy:  "accept",
+		IPSPriority:          ipsPriority,
+		Bans:                 a.bans,
+		BlockLists:           a.blockLists,
+	}
+
+	if a.scan != nil {
+		data.ScanSets, data.ScanRules = a.translateScan(a.scan)
+	}
+	for _, g := range ir.AddressGroups {
+		typ := "ipv4_addr"
+		if g.Family == policy.FamilyIPv6 {
+			typ = "ipv6_addr"
+		}
+		data.GroupSets = append(data.GroupSets, groupSet(g, typ))
+	}
+	for _, g := range ir.ServiceGroups {
+		data.GroupSets = append(data.GroupSets, groupSet(g, "inet_service"))
+	}
+	for _, cs := range ir.CountrySets {
+		typ := "ipv4_addr"
+		if cs.Family == policy.FamilyIPv6 {
+			typ = "ipv6_addr"
+		}
+		data.CountrySets = append(data.CountrySets, fmt.Sprintf("set %s { type %s; flags interval; }", cs.Set, typ))
+	}
+	for _, fs := range ir.FQDNSets {
+		typ := "ipv4_addr"
+		if fs.Family == policy.FamilyIPv6 {
+			typ = "ipv6_addr"
+		}
+		set := fmt.Sprintf("set %s { type %s; flags interval; }", fs.Set, typ)
+		if len(fs.Addresses) > 0 {
+			set = fmt.Sprintf("set %s { type %s; flags interval; elements = { %s } }", fs.Set, typ, strings.Join(fs.Addresses, ", "))
+		}
+		data.GroupSets = append(data.GroupSets, set)
 	}
 
-	// Translate firewall rules into nft rule strings.
+	// Translate firewall rules into nft rule strings. The rules of a VRF
+	// go to its own chains, jumped to from the base chains; they sort
+	// first, so the jumps precede the other rules.
+	tarpit := false
+	vrfChains := make(map[string]*vrfChain)
+	userChains := make(map[string]*userChain)
+	var userOrder []string
+	addUserChain := func(id string) *userChain {
+		c, ok := userChains[id]
+		if !ok {
+			c = &userChain{ID: id, Name: userChainName(id)}
+			userChains[id] = c
+			userOrder = append(userOrder, id)
+		}
+		return c
+	}
 	for _, r := range ir.FirewallRules {
+		if r.NetNS != a.netns {
+			continue
+		}
+		if r.Target != "" {
+			addUserChain(r.Target)
+		}
+		if r.ConnLimit > 0 {
+			typ := "ipv4_addr"
+			if r.Family == policy.FamilyIPv6 {
+				typ = "ipv6_addr"
+			}
+			data.ConnLimitSets = append(data.ConnLimitSets,
+				fmt.Sprintf("set %s { type %s; size 65535; flags dynamic; }", connLimitSet(r), typ))
+		}
+		// Marks are set on every packet, established or not, in chains of
+		// their own. Prerouting runs after destination NAT, so rules see
+		// the address the filter chains do, and after WAN steering, whose
+		// uplink mark a MARK rule replaces.
+		if r.Action == "mark" || r.Action == "dscp" {
+			rules, prefix := &data.MarkPreRules, vrfMatch(ir, r.VRF, "iifname")
+			switch r.Chain {
+			case "input":
+				prefix += "fib daddr type local "
+			case "output":
+				rules, prefix = &data.MarkOutputRules, vrfMatch(ir, r.VRF, "oifname")
+			default:
+				prefix += "fib daddr type != local "
+			}
+			*rules = append(*rules, prefix+a.translateFirewallRule(r))
+			// ip dscp only sets IPv4 packets; a rule of both families
+			// needs ip6 dscp for the rest.
+			if r.Action == "dscp" && r.Family == "" {
+				r.Family = policy.FamilyIPv6
+				*rules = append(*rules, prefix+a.translateFirewallRule(r))
+			}
+			continue
+		}
+		if r.Action == "tarpit" {
+			if a.tarpitPort != 0 {
+				data.DNATRules = append(data.DNATRules, vrfMatch(ir, r.VRF, "iifname")+a.translateTarpit(r))
+				tarpit = true
+				continue
+			}
+			r.Action = "drop"
+		}
 		stmt := a.translateFirewallRule(r)
+		if r.UserChain != "" {
+			c := addUserChain(r.UserChain)
+			c.Rules = append(c.Rules, stmt)
+			if r.Target != "" {
+				c.targets = append(c.targets, r.Target)
+			}
+			continue
+		}
+		base := &data.ForwardRules
 		switch r.Chain {
 		case "input":
-			data.InputRules = append(data.InputRules, stmt)
+			base = &data.InputRules
 		case "output":
-			data.OutputRules = append(data.OutputRules, stmt)
-		default:
-			data.ForwardRules = append(data.ForwardRules, stmt)
+			base = &data.OutputRules
+		}
+		if r.VRF != "" {
+			hook := ruleHook(r.Chain)
+			c, ok := vrfChains[r.VRF+"/"+hook]
+			if !ok {
+				v, _ := ir.VRF(r.VRF)
+				c = &vrfChain{VRF: v.Name, Hook: hook, Name: fmt.Sprintf("vrf%d_%s", v.Table, hook)}
+				vrfChains[r.VRF+"/"+hook] = c
+				data.VRFChains = append(data.VRFChains, c)
+				*base = append(*base, c.dispatch(v))
+			}
+			c.Rules = append(c.Rules, stmt)
+			continue
 		}
+		*base = append(*base, stmt)
+	}
+
+	data.UserChains = orderUserChains(userChains, userOrder)
+
+	if tarpit {
+		accept := fmt.Sprintf(`tcp dport %d ct status dnat accept comment "tarpit"`, a.tarpitPort)
+		data.InputRules = append([]string{accept}, data.InputRules...)
+	}
+
+	// Raw rules go verbatim into chains of their own, jumped to ahead of
+	// the generated rules.
+	rawChains := make(map[string]*rawChain)
+	for _, r := range ir.RawRules {
+		if r.NetNS != a.netns {
+			continue
+		}
+		c, ok := rawChains[r.Chain]
+		if !ok {
+			c = &rawChain{Hook: r.Chain, Name: "raw_" + r.Chain}
+			rawChains[r.Chain] = c
+			data.RawChains = append(data.RawChains, c)
+		}
+		c.Rules = append(c.Rules, "# "+strings.Join(strings.Fields(r.Policy), " "))
+		c.Rules = append(c.Rules, r.Rules...)
+	}
+	for _, c := range data.RawChains {
+		base := &data.ForwardRules
+		switch c.Hook {
+		case "input":
+			base = &data.InputRules
+		case "output":
+			base = &data.OutputRules
+		}
+		*base = append([]string{fmt.Sprintf(`jump %s comment "raw rules"`, c.Name)}, *base...)
 	}
 
 	// Translate NAT rules.
 	for _, r := range ir.NATRules {
+		if r.NetNS != a.netns {
+			continue
+		}
+		in, out := vrfMatch(ir, r.VRF, "iifname"), vrfMatch(ir, r.VRF, "oifname")
 		switch r.Type {
 		case "DNAT":
-			data.DNATRules = append(data.DNATRules, a.translateDNAT(r))
+			data.DNATRules = append(data.DNATRules, in+a.translateDNAT(r))
 		case "SNAT":
-			data.SNATRules = append(data.SNATRules, a.translateSNAT(r))
+			data.SNATRules = append(data.SNATRules, out+a.translateSNAT(r))
 		case "MASQUERADE":
-			data.SNATRules = append(data.SNATRules, a.translateMasquerade(r))
+			data.SNATRules = append(data.SNATRules, out+a.translateMasquerade(r))
+		case "NETMAP":
+			dnat, snat := a.translateNetmap(r)
+			data.DNATRules = append(data.DNATRules, in+dnat)
+			data.SNATRules = append(data.SNATRules, out+snat)
+		}
+	}
+
+	// Steer new connections across uplinks and NAT them per uplink.
+	if ir.WAN != nil && a.netns == "" {
+		data.WANMarkRules = a.translateWAN(ir.WAN)
+		for _, u := range ir.WAN.Uplinks {
+			data.SNATRules = append(data.SNATRules, a.translateUplinkSNAT(u))
 		}
 	}
 
+	// Hand accepted traffic to Suricata for inline inspection.
+	if a.ips != nil {
+		data.IPSChains = a.translateIPS(a.ips, ir.IPSBypass)
+	}
+
 	tmpl, err := template.New("nft").Parse(nftTableTemplate)
 	if err != nil {
 		return "", fmt.Errorf("parse template: %w", err)
@@ -188,6 +531,11 @@ func (a *Adapter) Translate(ir *policy.IR) (string, error) {
 	if err := tmpl.Execute(&buf, data); err != nil {
 		return "", fmt.Errorf("execute template: %w", err)
 	}
+	if a.maxRenderBytes > 0 && buf.Len() > a.maxRenderBytes {
+		return "", &policy.LimitError{Violations: []string{
+			fmt.Sprintf("rendered ruleset is %d bytes, limit is %d", buf.Len(), a.maxRenderBytes),
+		}}
+	}
 
 	return buf.String(), nil
 }
@@ -196,99 +544,607 @@ func (a *Adapter) Translate(ir *policy.IR) (string, error) {
 func (a *Adapter) translateFirewallRule(r policy.CompiledFirewallRule) string {
 	var parts []string
 
+	// Address family; the compiler split dual-stack rules in two
+	family := r.Family
+	if family == "" {
+		family = policy.FamilyIPv4
+	}
+
+	// Interfaces
+	if m := ifaceMatch("iifname", r.InIfaces); m != "" {
+		parts = append(parts, m)
+	}
+	if m := ifaceMatch("oifname", r.OutIfaces); m != "" {
+		parts = append(parts, m)
+	}
+
+	// Source MAC addresses
+	if len(r.SrcMACs) == 1 {
+		parts = append(parts, "ether saddr "+r.SrcMACs[0])
+	} else if len(r.SrcMACs) > 1 {
+		parts = append(parts, "ether saddr { "+strings.Join(r.SrcMACs, ", ")+" }")
+	}
+
 	// Protocol
-	if r.Protocol != "" {
+	if r.Protocol == "icmp" && family == policy.FamilyIPv6 {
+		parts = append(parts, "meta l4proto ipv6-icmp")
+	} else if r.Protocol != "" {
 		parts = append(parts, "meta l4proto "+r.Protocol)
 	}
 
 	// Source addresses
-	if len(r.SrcAddrs) == 1 {
-		parts = append(parts, "ip saddr "+r.SrcAddrs[0])
+	if r.SrcSet != "" {
+		parts = append(parts, family+" saddr @"+r.SrcSet)
+	} else if len(r.SrcAddrs) == 1 {
+		parts = append(parts, family+" saddr "+r.SrcAddrs[0])
 	} else if len(r.SrcAddrs) > 1 {
-		parts = append(parts, "ip saddr { "+strings.Join(r.SrcAddrs, ", ")+" }")
+		parts = append(parts, family+" saddr { "+strings.Join(r.SrcAddrs, ", ")+" }")
 	}
 
 	// Destination addresses
-	if len(r.DstAddrs) == 1 {
-		parts = append(parts, "ip daddr "+r.DstAddrs[0])
+	if r.DstSet != "" {
+		parts = append(parts, family+" daddr @"+r.DstSet)
+	} else if len(r.DstAddrs) == 1 {
+		parts = append(parts, family+" daddr "+r.DstAddrs[0])
 	} else if len(r.DstAddrs) > 1 {
-		parts = append(parts, "ip daddr { "+strings.Join(r.DstAddrs, ", ")+" }")
+		parts = append(parts, family+" daddr { "+strings.Join(r.DstAddrs, ", ")+" }")
 	}
 
 	// Source ports
-	if len(r.SrcPorts) == 1 {
+	if r.SrcPortSet != "" {
+		parts = append(parts, r.Protocol+" sport @"+r.SrcPortSet)
+	} else if len(r.SrcPorts) == 1 {
 		parts = append(parts, r.Protocol+" sport "+r.SrcPorts[0])
 	} else if len(r.SrcPorts) > 1 {
 		parts = append(parts, r.Protocol+" sport { "+strings.Join(r.SrcPorts, ", ")+" }")
 	}
 
 	// Destination ports
-	if len(r.DstPorts) == 1 {
+	if r.DstPortSet != "" {
+		parts = append(parts, r.Protocol+" dport @"+r.DstPortSet)
+	} else if len(r.DstPorts) == 1 {
 		parts = append(parts, r.Protocol+" dport "+r.DstPorts[0])
 	} else if len(r.DstPorts) > 1 {
 		parts = append(parts, r.Protocol+" dport { "+strings.Join(r.DstPorts, ", ")+" }")
 	}
 
+	// Time window
+	if r.Schedule != nil {
+		parts = append(parts, scheduleMatch(r.Schedule)...)
+	}
+
 	// Connection state
 	if len(r.States) > 0 {
 		parts = append(parts, "ct state { "+strings.Join(r.States, ", ")+" }")
 	}
 
+	// Concurrent connections per source address
+	if r.ConnLimit > 0 {
+		over := ""
+		if r.Action != "accept" {
+			over = "over "
+		}
+		parts = append(parts, fmt.Sprintf("add @%s { %s saddr ct count %s%d }", connLimitSet(r), family, over, r.ConnLimit))
+	}
+
 	// Rate limiting
 	if r.RateLimit != "" {
 		parts = append(parts, "limit rate "+r.RateLimit)
 	}
 
+	// Per-rule counter, read back by the hit-rate analysis
+	parts = append(parts, "counter")
+
 	// Log before action
 	if r.Log {
-		parts = append(parts, fmt.Sprintf(`log prefix "[aegisx] %s: "`, r.Comment))
+		parts = append(parts, fmt.Sprintf(`log prefix "%s%s: "`, logPrefix, logRule(r.Comment)))
 	}
 
 	// Verdict
-	parts = append(parts, r.Action)
+	switch r.Action {
+	case "jump", "goto":
+		parts = append(parts, r.Action+" "+userChainName(r.Target))
+	case "reject":
+		parts = append(parts, rejectStmt(r.RejectWith, family, r.Family == ""))
+	case "mark":
+		parts = append(parts, "meta mark set "+r.Mark)
+	case "dscp":
+		parts = append(parts, family+" dscp set "+r.DSCP)
+	default:
+		parts = append(parts, r.Action)
+	}
 
 	// Comment
 	if r.Comment != "" {
-		parts = append(parts, fmt.Sprintf(`comment "%s"`, r.Comment))
+		parts = append(parts, fmt.Sprintf(`comment "%s"`, nftComment(r.Comment)))
 	}
 
 	return strings.Join(parts, " ")
 }
 
+// nft refuses comments longer than maxCommentLen bytes; the kernel keeps
+// log prefixes of up to maxLogPrefixLen.
+const (
+	maxCommentLen   = 128
+	maxLogPrefixLen = 127
+	logPrefix       = "[aegisx] "
+)
+
+// nftComment returns s as the comment of a rule in the ruleset and the
+// kernel. Comments come from policy and rule names, so lookups of a rule
+// by the comment the kernel holds must go through it too.
+func nftComment(s string) string {
+	return nftString(s, maxCommentLen)
+}
+
+// logRule is nftComment for the rule name in a log prefix, which the log
+// tail reads back as LogEvent.Rule.
+func logRule(s string) string {
+	return nftString(s, maxLogPrefixLen-len(logPrefix)-len(": "))
+}
+
+// nftString makes s safe between the quotes of an nft string of at most
+// max bytes. Quotes and backslashes would end the string or escape its
+// end, and control characters the statement, so each is replaced by an
+// underscore; an overlong s is cut at a character boundary. A changed s
+// ends in a hash of the original, so names that differ only in what was
+// replaced or cut still tell their rules apart.
+func nftString(s string, max int) string {
+	var b strings.Builder
+	changed := false
+	for _, c := range s {
+		if c == '"' || c == '\\' || c == utf8.RuneError || unicode.IsControl(c) {
+			c, changed = '_', true
+		}
+		b.WriteRune(c)
+	}
+	out := b.String()
+	if !changed && len(out) <= max {
+		return out
+	}
+	h := fnv.New32a()
+	h.Write([]byte(s))
+	suffix := fmt.Sprintf("~%08x", h.Sum32())
+	cut := max - len(suffix)
+	if cut < len(out) {
+		for cut > 0 && !utf8.RuneStart(out[cut]) {
+			cut--
+		}
+		out = out[:cut]
+	}
+	return out + suffix
+}
+
+// icmpRejectTypes are the ICMP and ICMPv6 types nft rejects with for each
+// of policy.RejectICMPTypes; icmpx uses the names as they are.
+var icmpRejectTypes = map[string][2]string{
+	"port-unreachable": {"port-unreachable", "port-unreachable"},
+	"host-unreachable": {"host-unreachable", "addr-unreachable"},
+	"no-route":         {"net-unreachable", "no-route"},
+	"admin-prohibited": {"admin-prohibited", "admin-prohibited"},
+}
+
+// rejectStmt is the reject verdict for rejectWith. A rule of both families
+// can only send icmpx errors, which take the family of the packet.
+func rejectStmt(rejectWith, family string, dual bool) string {
+	if rejectWith == policy.RejectTCPReset {
+		return "reject with tcp reset"
+	}
+	t, icmpx := strings.CutPrefix(rejectWith, "icmpx-")
+	if !icmpx {
+		t, _ = strings.CutPrefix(rejectWith, "icmp-")
+	}
+	types, ok := icmpRejectTypes[t]
+	switch {
+	case !ok:
+		return "reject"
+	case icmpx || dual:
+		return "reject with icmpx type " + t
+	case family == policy.FamilyIPv6:
+		return "reject with icmpv6 type " + types[1]
+	}
+	return "reject with icmp type " + types[0]
+}
+
+// connLimitSet names the set counting the connections of r per source
+// address. The halves of a dual-stack rule share a comment, so the family is
+// part of the name.
+func connLimitSet(r policy.CompiledFirewallRule) string {
+	h := fnv.New32a()
+	h.Write([]byte(r.Family + "/" + r.Comment))
+	return fmt.Sprintf("connlimit_%08x", h.Sum32())
+}
+
+// ifaceMatch matches the interface name against names, which may end in a
+// * wildcard.
+func ifaceMatch(key string, names []string) string {
+	switch len(names) {
+	case 0:
+		return ""
+	case 1:
+		return fmt.Sprintf("%s %q", key, names[0])
+	}
+	quoted := make([]string, len(names))
+	for i, n := range names {
+		quoted[i] = fmt.Sprintf("%q", n)
+	}
+	return key + " { " + strings.Join(quoted, ", ") + " }"
+}
+
+// vrfChain holds the firewall rules of one VRF for one hook.
+type vrfChain struct {
+	VRF   string
+	Hook  string // input | forward | output
+	Name  string // vrf<table>_<hook>; VRF names may not be valid chain names
+	Rules []string
+}
+
+// dispatch is the base chain rule sending the VRF's traffic to c. Packets
+// of a VRF pass the hooks with the VRF device as input interface, and on
+// output first with it and then with the enslaved interface, so both are
+// matched.
+func (c *vrfChain) dispatch(v policy.VRF) string {
+	key := "iifname"
+	if c.Hook == "output" {
+		key = "oifname"
+	}
+	return fmt.Sprintf(`%s jump %s comment "%s"`, ifaceMatch(key, v.Devices()), c.Name, nftComment("vrf "+v.Name))
+}
+
+// rawChain holds the raw rules of the FirewallPolicies for one base chain.
+type rawChain struct {
+	Hook  string // input | forward | output
+	Name  string // raw_<hook>
+	Rules []string
+}
+
+// userChain holds the rules of a user-defined chain of a FirewallPolicy.
+type userChain struct {
+	ID      string // namespace/policy/chain
+	Name    string // user_<hash of ID>; IDs are not valid chain names
+	Rules   []string
+	targets []string // chains its rules jump or go to
+}
+
+// userChainName names the nft chain of the user-defined chain id.
+func userChainName(id string) string {
+	h := fnv.New32a()
+	h.Write([]byte(id))
+	return fmt.Sprintf("user_%08x", h.Sum32())
+}
+
+// orderUserChains lists the chains of order so that every chain follows
+// the chains it jumps to, and is declared before the rules referring to
+// it. The validator rejects loops of chains.
+func orderUserChains(chains map[string]*userChain, order []string) []*userChain {
+	out := make([]*userChain, 0, len(order))
+	seen := make(map[string]bool, len(order))
+	var visit func(id string)
+	visit = func(id string) {
+		if seen[id] {
+			return
+		}
+		seen[id] = true
+		for _, t := range chains[id].targets {
+			visit(t)
+		}
+		out = append(out, chains[id])
+	}
+	for _, id := range order {
+		visit(id)
+	}
+	return out
+}
+
+// ruleHook is the base chain a rule of chain is installed in.
+func ruleHook(chain string) string {
+	if chain != "input" && chain != "output" {
+		return "forward"
+	}
+	return chain
+}
+
+// vrfMatch restricts a rule to the devices of the named VRF, and matches
+// everything for "".
+func vrfMatch(ir *policy.IR, name, key string) string {
+	v, ok := ir.VRF(name)
+	if name == "" || !ok {
+		return ""
+	}
+	return ifaceMatch(key, v.Devices()) + " "
+}
+
+// ReplaceSetElements swaps the elements of the given sets of the live
+// ruleset in one transaction, so no packet sees a set half filled.
+func (a *Adapter) ReplaceSetElements(ctx context.Context, sets map[string][]string) error {
+	names := make([]string, 0, len(sets))
+	for name := range sets {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var sb strings.Builder
+	for _, name := range names {
+		fmt.Fprintf(&sb, "flush set inet %s %s\n", a.tableName, name)
+		if elems := sets[name]; len(elems) > 0 {
+			fmt.Fprintf(&sb, "add element inet %s %s { %s }\n", a.tableName, name, strings.Join(elems, ", "))
+		}
+	}
+	if a.dryRun {
+		a.log.Info("dry-run: nftables set update", zap.String("script", sb.String()))
+		return nil
+	}
+	cmd := a.nft(ctx, "-f", "-")
+	cmd.Stdin = strings.NewReader(sb.String())
+	if out, err := cmd.CombinedOutput(); err != nil {
+		return fmt.Errorf("nft: %w (output: %s)", err, out)
+	}
+	return nil
+}
+
+// groupSet declares a named set for an address or service group. Rules
+// refer to it by name, so a group can change without touching them.
+func groupSet(g policy.CompiledGroup, typ string) string {
+	return fmt.Sprintf("set %s { type %s; flags interval; auto-merge; elements = { %s } }",
+		g.Set, typ, strings.Join(g.Elements, ", "))
+}
+
+// scheduleMatch returns the meta day / meta hour expressions of a schedule.
+// nftables evaluates them in the system time zone.
+func scheduleMatch(s *policy.RuleSchedule) []string {
+	var parts []string
+	if len(s.Days) > 0 {
+		days := make([]string, len(s.Days))
+		for i, d := range s.Days {
+			days[i] = `"` + d + `"`
+		}
+		parts = append(parts, "meta day { "+strings.Join(days, ", ")+" }")
+	}
+	if s.Start != "" {
+		parts = append(parts, fmt.Sprintf(`meta hour "%s"-"%s"`, s.Start, s.End))
+	}
+	return parts
+}
+
+// translateTarpit redirects matching new connections to the local honeypot
+// listener. It runs in prerouting, so it takes effect before any filter rule.
+func (a *Adapter) translateTarpit(r policy.CompiledFirewallRule) string {
+	r.States = nil
+	r.Action = fmt.Sprintf("redirect to :%d", a.tarpitPort)
+	return a.translateFirewallRule(r)
+}
+
 func (a *Adapter) translateDNAT(r policy.CompiledNATRule) string {
 	stmt := ""
 	if r.SrcAddr != "" {
-		stmt += "ip saddr " + r.SrcAddr + " "
+		stmt += addrFamily(r.SrcAddr) + " saddr " + r.SrcAddr + " "
 	}
 	if r.DstAddr != "" {
-		stmt += "ip daddr " + r.DstAddr + " "
+		stmt += addrFamily(r.DstAddr) + " daddr " + r.DstAddr + " "
+	}
+	stmt += natProtocol(r)
+	if r.DstPorts != "" {
+		stmt += "th dport " + r.DstPorts + " "
+	}
+	if len(r.Targets) > 0 {
+		return stmt + dnatSpread(r) + natComment(r)
 	}
-	stmt += "dnat to " + r.ToAddr
-	return stmt
+	stmt += "dnat " + addrFamily(r.ToAddr) + " to " + natAddr(r.ToAddr) + natPorts(r)
+	return stmt + natComment(r)
 }
 
 func (a *Adapter) translateSNAT(r policy.CompiledNATRule) string {
 	stmt := ""
 	if r.SrcAddr != "" {
-		stmt += "ip saddr " + r.SrcAddr + " "
+		stmt += addrFamily(r.SrcAddr) + " saddr " + r.SrcAddr + " "
 	}
 	if r.OutIface != "" {
-		stmt += "oif " + r.OutIface + " "
+		stmt += ifaceMatch("oifname", []string{r.OutIface}) + " "
 	}
-	stmt += "snat to " + r.ToAddr
-	return stmt
+	stmt += natProtocol(r)
+	stmt += "snat " + addrFamily(r.ToAddr) + " to " + natAddr(r.ToAddr) + natPorts(r) + natFlags(r)
+	return stmt + natComment(r)
 }
 
 func (a *Adapter) translateMasquerade(r policy.CompiledNATRule) string {
 	stmt := ""
 	if r.SrcAddr != "" {
-		stmt += "ip saddr " + r.SrcAddr + " "
+		stmt += addrFamily(r.SrcAddr) + " saddr " + r.SrcAddr + " "
 	}
 	if r.OutIface != "" {
-		stmt += "oif " + r.OutIface + " "
+		stmt += ifaceMatch("oifname", []string{r.OutIface}) + " "
 	}
+	stmt += natProtocol(r)
 	stmt += "masquerade"
-	return stmt
+	if r.ToPorts != "" {
+		stmt += " to " + natPorts(r)
+	}
+	stmt += natFlags(r)
+	return stmt + natComment(r)
+}
+
+// dnatSpread picks the target by a hash of the client address, so a client
+// keeps landing on the same target. Each target owns weight consecutive
+// hash buckets.
+func dnatSpread(r policy.CompiledNATRule) string {
+	family := "ip"
+	if strings.Contains(r.Targets[0].Address, ":") {
+		family = "ip6"
+	}
+	// nft cannot append a port to a mapped address; map to addr . port.
+	to, suffix := "to", ""
+	if r.ToPorts != "" {
+		to, suffix = "addr . port to", " . "+r.ToPorts
+	}
+
+	total := 0
+	var slots []string
+	for _, t := range r.Targets {
+		lo := total
+		total += t.Weight
+		if lo == total-1 {
+			slots = append(slots, fmt.Sprintf("%d : %s%s", lo, t.Address, suffix))
+		} else {
+			slots = append(slots, fmt.Sprintf("%d-%d : %s%s", lo, total-1, t.Address, suffix))
+		}
+	}
+	return fmt.Sprintf("dnat %s %s jhash %s saddr mod %d map { %s }",
+		family, to, family, total, strings.Join(slots, ", "))
+}
+
+// translateNetmap maps the internal prefix 1:1 onto the external one:
+// inbound traffic to an external address is sent to the internal address
+// with the same host part, and outbound traffic is rewritten the other way.
+func (a *Adapter) translateNetmap(r policy.CompiledNATRule) (dnat, snat string) {
+	family := "ip"
+	if strings.Contains(r.SrcAddr, ":") {
+		family = "ip6"
+	}
+	dnat = fmt.Sprintf("%s daddr %s dnat %s prefix to %s daddr map { %s : %s }",
+		family, r.ToAddr, family, family, r.ToAddr, r.SrcAddr)
+	snat = fmt.Sprintf("%s saddr %s ", family, r.SrcAddr)
+	if r.OutIface != "" {
+		snat += ifaceMatch("oifname", []string{r.OutIface}) + " "
+	}
+	snat += fmt.Sprintf("snat %s prefix to %s saddr map { %s : %s }",
+		family, family, r.SrcAddr, r.ToAddr)
+	return dnat + natComment(r), snat + natComment(r)
+}
+
+// natProtocol matches the transport protocol, which nft requires before a
+// port match or port mapping.
+func natProtocol(r policy.CompiledNATRule) string {
+	switch {
+	case r.Protocol != "":
+		return "meta l4proto " + r.Protocol + " "
+	case r.DstPorts != "" || r.ToPorts != "":
+		return "meta l4proto { tcp, udp } "
+	}
+	return ""
+}
+
+// addrFamily returns the nft payload family of an address or CIDR, which
+// may carry a port as "1.2.3.4:80" or "[2001:db8::1]:80".
+func addrFamily(addr string) string {
+	if host, _, err := net.SplitHostPort(addr); err == nil {
+		addr = host
+	}
+	if strings.Contains(addr, ":") {
+		return policy.FamilyIPv6
+	}
+	return policy.FamilyIPv4
+}
+
+// natAddr brackets a bare IPv6 address so a port can follow it.
+func natAddr(addr string) string {
+	if ip := net.ParseIP(addr); ip != nil && ip.To4() == nil {
+		return "[" + addr + "]"
+	}
+	return addr
+}
+
+func natPorts(r policy.CompiledNATRule) string {
+	if r.ToPorts == "" {
+		return ""
+	}
+	return ":" + r.ToPorts
+}
+
+func natComment(r policy.CompiledNATRule) string {
+	if r.Comment == "" {
+		return ""
+	}
+	return fmt.Sprintf(` comment "%s"`, nftComment(r.Comment))
+}
+
+func natFlags(r policy.CompiledNATRule) string {
+	if len(r.Flags) == 0 {
+		return ""
+	}
+	return " " + strings.Join(r.Flags, ",")
+}
+
+// translateWAN marks new connections from the steered sources with the fwmark
+// of the chosen uplink and pins the mark to the connection, so every later
+// packet follows the same uplink. In balance mode the uplink is drawn by
+// weight; in failover mode the lowest-priority uplink takes everything.
+// Traffic of a path goes to the uplink chosen for it first; uplinks
+// reserved for paths are never drawn otherwise.
+func (a *Adapter) translateWAN(w *policy.CompiledWAN) []string {
+	if len(w.Uplinks) == 0 {
+		return nil
+	}
+	var marks, ifaces []string
+	var shared []policy.CompiledWANUplink
+	mark := make(map[string]int)
+	for _, u := range w.Uplinks {
+		marks = append(marks, fmt.Sprintf("%#x", u.Mark))
+		ifaces = append(ifaces, fmt.Sprintf("%q", u.Interface))
+		mark[u.Name] = u.Mark
+		if !u.PathsOnly {
+			shared = append(shared, u)
+		}
+	}
+	if len(shared) == 0 {
+		shared = w.Uplinks
+	}
+
+	// Only outbound traffic: not arriving from an uplink, not for the box.
+	sel := "iifname != { " + strings.Join(ifaces, ", ") + " } fib daddr type != local "
+	if len(w.Sources) > 0 {
+		sel += "ip saddr { " + strings.Join(w.Sources, ", ") + " } "
+	}
+
+	var pick string
+	if w.Mode == "failover" {
+		best := shared[0]
+		for _, u := range shared[1:] {
+			if u.Priority < best.Priority {
+				best = u
+			}
+		}
+		pick = fmt.Sprintf("meta mark set %#x", best.Mark)
+	} else {
+		total := 0
+		var slots []string
+		for _, u := range shared {
+			lo := total
+			total += u.Weight
+			if lo == total-1 {
+				slots = append(slots, fmt.Sprintf("%d : %#x", lo, u.Mark))
+			} else {
+				slots = append(slots, fmt.Sprintf("%d-%d : %#x", lo, total-1, u.Mark))
+			}
+		}
+		pick = fmt.Sprintf("meta mark set numgen random mod %d map { %s }", total, strings.Join(slots, ", "))
+	}
+
+	rules := []string{
+		// Established connections keep their uplink while it is still in use.
+		"ct mark { " + strings.Join(marks, ", ") + " } meta mark set ct mark return",
+	}
+	for _, p := range w.Paths {
+		m, ok := mark[p.Uplink]
+		if !ok {
+			continue
+		}
+		for _, r := range p.Match {
+			r.States = nil
+			r.Action = fmt.Sprintf("meta mark set %#x ct mark set meta mark return", m)
+			rules = append(rules, sel+"ct state new "+a.translateFirewallRule(r))
+		}
+	}
+	return append(rules,
+		sel+"ct state new "+pick,
+		sel+"ct state new ct mark set meta mark",
+	)
+}
+
+func (a *Adapter) translateUplinkSNAT(u policy.CompiledWANUplink) string {
+	if u.SNAT == "" {
+		return fmt.Sprintf("oifname %q masquerade", u.Interface)
+	}
+	return fmt.Sprintf("oifname %q snat ip to %s", u.Interface, u.SNAT)
 }
 
 // Diff returns a human-readable diff between current live rules and proposed IR.
@@ -298,7 +1154,7 @@ func (a *Adapter) Diff(ir *policy.IR) (string, error) {
 		return "", err
 	}
 
-	current, err := a.dumpCurrent()
+	current, err := a.dumpText()
 	if err != nil {
 		// Current ruleset may not exist yet.
 		return fmt.Sprintf("--- current (empty)\n+++ proposed\n%s", proposed), nil
@@ -307,14 +1163,38 @@ func (a *Adapter) Diff(ir *policy.IR) (string, error) {
 	return simpleDiff(current, proposed), nil
 }
 
+// Check translates ir and validates it with `nft -c` without touching the
+// live ruleset.
+func (a *Adapter) Check(ir *policy.IR) error {
+	ruleset, err := a.Translate(ir)
+	if err != nil {
+		return fmt.Errorf("translate: %w", err)
+	}
+	tmpFile, err := os.CreateTemp("", "aegisx-nft-check-*.conf")
+	if err != nil {
+		return fmt.Errorf("create temp file: %w", err)
+	}
+	defer os.Remove(tmpFile.Name())
+	if _, err := tmpFile.WriteString(ruleset); err != nil {
+		return fmt.Errorf("write temp file: %w", err)
+	}
+	tmpFile.Close()
+
+	out, err := a.nft(context.Background(), "-c", "-f", tmpFile.Name()).CombinedOutput()
+	if err != nil {
+		return fmt.Errorf("nft -c failed: %w (output: %s)", err, out)
+	}
+	return nil
+}
+
 // Rollback restores the most recent saved ruleset.
-func (a *Adapter) Rollback() error {
+func (a *Adapter) Rollback(ctx context.Context) error {
 	latest, err := a.latestRollbackFile()
 	if err != nil {
 		return fmt.Errorf("find rollback file: %w", err)
 	}
 
-	out, err := exec.Command("nft", "-f", latest).CombinedOutput()
+	out, err := a.nft(ctx, "-f", latest).CombinedOutput()
 	if err != nil {
 		return fmt.Errorf("rollback apply failed: %w (output: %s)", err, out)
 	}
@@ -323,23 +1203,38 @@ func (a *Adapter) Rollback() error {
 }
 
 // Flush removes all AegisX rules from the kernel.
-func (a *Adapter) Flush() error {
-	out, err := exec.Command("nft", "delete", "table", "inet", a.tableName).CombinedOutput()
+func (a *Adapter) Flush(ctx context.Context) error {
+	out, err := a.nft(ctx, "delete", "table", "inet", a.tableName).CombinedOutput()
 	if err != nil && !strings.Contains(string(out), "No such file") {
 		return fmt.Errorf("flush table: %w (output: %s)", err, out)
 	}
 	return nil
 }
 
-// Status returns the currently active nftables ruleset.
-func (a *Adapter) Status() (string, error) {
+// Status returns the currently active table, parsed from nft's JSON output.
+func (a *Adapter) Status() (*Ruleset, error) {
 	return a.dumpCurrent()
 }
 
+// StatusText returns the currently active table in nft syntax.
+func (a *Adapter) StatusText() (string, error) {
+	return a.dumpText()
+}
+
 // ─── Private helpers ──────────────────────────────────────────────────────
 
-func (a *Adapter) dumpCurrent() (string, error) {
-	out, err := exec.Command("nft", "-s", "list", "table", "inet", a.tableName).CombinedOutput()
+func (a *Adapter) dumpCurrent() (*Ruleset, error) {
+	out, err := a.nft(context.Background(), "-j", "list", "table", "inet", a.tableName).Output()
+	if err != nil {
+		return nil, fmt.Errorf("nft list table: %w", err)
+	}
+	return parseRuleset(out)
+}
+
+// dumpText lists the table in nft syntax, the form `nft -f` restores from
+// and the rendered ruleset is diffed against.
+func (a *Adapter) dumpText() (string, error) {
+	out, err := a.nft(context.Background(), "-s", "list", "table", "inet", a.tableName).CombinedOutput()
 	if err != nil {
 		return "", fmt.Errorf("nft list table: %w", err)
 	}
@@ -351,7 +1246,7 @@ func (a *Adapter) saveRollback() error {
 		return err
 	}
 
-	current, err := a.dumpCurrent()
+	current, err := a.dumpText()
 	if err != nil {
 		return nil // nothing to save if no rules exist
 	}
@@ -366,37 +1261,110 @@ func (a *Adapter) latestRollbackFile() (string, error) {
 	if err != nil {
 		return "", err
 	}
-	if len(entries) == 0 {
-		return "", fmt.Errorf("no rollback snapshots found")
-	}
 	// Files are named with timestamps; the last one is the most recent.
-	return filepath.Join(a.rollbackDir, entries[len(entries)-1].Name()), nil
+	// Directories hold the snapshots of network namespaces.
+	for i := len(entries) - 1; i >= 0; i-- {
+		if !entries[i].IsDir() {
+			return filepath.Join(a.rollbackDir, entries[i].Name()), nil
+		}
+	}
+	return "", fmt.Errorf("no rollback snapshots found")
 }
 
-// simpleDiff produces a basic unified-diff-style comparison.
+// simpleDiff produces a unified-diff-style comparison: the lines removed
+// from current and added in proposed, in order, from a longest common
+// subsequence of the two. Lines are compared without their indentation.
 func simpleDiff(current, proposed string) string {
 	cLines := strings.Split(current, "\n")
 	pLines := strings.Split(proposed, "\n")
-
-	cSet := make(map[string]bool)
-	pSet := make(map[string]bool)
-	for _, l := range cLines {
-		cSet[strings.TrimSpace(l)] = true
+	cKeys := make([]string, len(cLines))
+	for i, l := range cLines {
+		cKeys[i] = strings.TrimSpace(l)
 	}
-	for _, l := range pLines {
-		pSet[strings.TrimSpace(l)] = true
+	pKeys := make([]string, len(pLines))
+	for i, l := range pLines {
+		pKeys[i] = strings.TrimSpace(l)
 	}
 
 	var out strings.Builder
-	for _, l := range cLines {
-		if !pSet[strings.TrimSpace(l)] {
-			out.WriteString("- " + l + "\n")
+	var walk func(c0, c1, p0, p1 int)
+	walk = func(c0, c1, p0, p1 int) {
+		for c0 < c1 && p0 < p1 && cKeys[c0] == pKeys[p0] {
+			c0, p0 = c0+1, p0+1
 		}
-	}
-	for _, l := range pLines {
-		if !cSet[strings.TrimSpace(l)] {
-			out.WriteString("+ " + l + "\n")
+		for c0 < c1 && p0 < p1 && cKeys[c1-1] == pKeys[p1-1] {
+			c1, p1 = c1-1, p1-1
+		}
+		switch {
+		case c0 == c1 || p0 == p1:
+			for _, l := range cLines[c0:c1] {
+				out.WriteString("- " + l + "\n")
+			}
+			for _, l := range pLines[p0:p1] {
+				out.WriteString("+ " + l + "\n")
+			}
+		case c1-c0 == 1:
+			// One line left in current: keep it if proposed has it.
+			k := -1
+			for i := p0; i < p1 && k < 0; i++ {
+				if pKeys[i] == cKeys[c0] {
+					k = i
+				}
+			}
+			if k < 0 {
+				out.WriteString("- " + cLines[c0] + "\n")
+				k = p0 - 1
+			}
+			for i := p0; i < p1; i++ {
+				if i != k {
+					out.WriteString("+ " + pLines[i] + "\n")
+				}
+			}
+		default:
+			// Hirschberg: split current in half and proposed where the
+			// common subsequences of both halves are longest together.
+			mid := (c0 + c1) / 2
+			fwd := lcsLengths(cKeys[c0:mid], pKeys[p0:p1], false)
+			bwd := lcsLengths(cKeys[mid:c1], pKeys[p0:p1], true)
+			split, best := 0, -1
+			for k := 0; k <= p1-p0; k++ {
+				if n := fwd[k] + bwd[p1-p0-k]; n > best {
+					split, best = k, n
+				}
+			}
+			walk(c0, mid, p0, p0+split)
+			walk(mid, c1, p0+split, p1)
 		}
 	}
+	walk(0, len(cKeys), 0, len(pKeys))
 	return out.String()
 }
+
+// lcsLengths returns, for each prefix of b (each suffix if reverse), the
+// length of the longest common subsequence of a and it, in linear space.
+func lcsLengths(a, b []string, reverse bool) []int {
+	prev := make([]int, len(b)+1)
+	cur := make([]int, len(b)+1)
+	for i := range a {
+		x := a[i]
+		if reverse {
+			x = a[len(a)-1-i]
+		}
+		for j := 1; j <= len(b); j++ {
+			y := b[j-1]
+			if reverse {
+				y = b[len(b)-j]
+			}
+			switch {
+			case x == y:
+				cur[j] = prev[j-1] + 1
+			case prev[j] >= cur[j-1]:
+				cur[j] = prev[j]
+			default:
+				cur[j] = cur[j-1]
+			}
+		}
+		prev, cur = cur, prev
+	}
+	return prev
+}
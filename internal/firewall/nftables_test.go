@@ -0,0 +1,131 @@
+package firewall
+
+import (
+	"strings"
+	"testing"
+	"unicode"
+	"unicode/utf8"
+
+	"go.uber.org/zap"
+
+	"github.com/aegisx/aegisx/internal/policy"
+)
+
+func TestNFTString(t *testing.T) {
+	long := strings.Repeat("a", 200)
+	tests := []struct {
+		name string
+		in   string
+		same bool // returned unchanged
+	}{
+		{"plain", "default/web/allow-http", true},
+		{"empty", "", true},
+		{"exactly max", strings.Repeat("b", maxCommentLen), true},
+		{"multibyte", "default/wëb/erlaubt-über", true},
+		{"quote", `default/web/a"b`, false},
+		{"quote breakout", `x" accept; chain evil { type filter hook input priority -500; policy accept; } #`, false},
+		{"backslash", `default/web/a\b`, false},
+		{"trailing backslash", `default/web/a\`, false},
+		{"newline", "default/web/a\nb", false},
+		{"carriage return", "default/web/a\rb", false},
+		{"tab", "default/web/a\tb", false},
+		{"nul", "default/web/a\x00b", false},
+		{"escape", "default/web/a\x1b[31mb", false},
+		{"del", "default/web/a\x7fb", false},
+		{"c1 control", "default/web/a\u0085b", false},
+		{"invalid utf-8", "default/web/a\xffb", false},
+		{"truncated rune", "default/web/a\xe2\x82", false},
+		{"over max", long, false},
+		{"over max, other tail", long[:199] + "b", false},
+		{"one over max", strings.Repeat("c", maxCommentLen+1), false},
+		{"cut inside 2-byte rune", strings.Repeat("d", maxCommentLen-10) + strings.Repeat("é", 20), false},
+		{"cut inside 3-byte rune", strings.Repeat("e", maxCommentLen-10) + strings.Repeat("€", 20), false},
+		{"cut inside 4-byte rune", strings.Repeat("f", maxCommentLen-10) + strings.Repeat("😀", 20), false},
+		{"over max and hostile", strings.Repeat("\"\\\n", 100), false},
+	}
+
+	seen := make(map[string]string)
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := nftComment(tt.in)
+			checkNFTString(t, got, maxCommentLen)
+			if tt.same && got != tt.in {
+				t.Errorf("nftComment(%q) = %q, want it unchanged", tt.in, got)
+			}
+			if !tt.same && got == tt.in {
+				t.Errorf("nftComment(%q) returned it unchanged", tt.in)
+			}
+			if other, dup := seen[got]; dup {
+				t.Errorf("nftComment(%q) = nftComment(%q) = %q", tt.in, other, got)
+			}
+			seen[got] = tt.in
+
+			prefix := logPrefix + logRule(tt.in) + ": "
+			checkNFTString(t, prefix, maxLogPrefixLen)
+		})
+	}
+}
+
+// TestNFTStringDistinct checks that inputs differing only in what is
+// replaced or cut keep distinct results.
+func TestNFTStringDistinct(t *testing.T) {
+	long := strings.Repeat("x", maxCommentLen)
+	pairs := [][2]string{
+		{`a"b`, `a\b`},
+		{`a"b`, "a_b"},
+		{"a\nb", "a\rb"},
+		{"a\xffb", "a\xfeb"},
+		{long + "1", long + "2"},
+		{long + "é", long + "è"},
+	}
+	for _, p := range pairs {
+		if a, b := nftComment(p[0]), nftComment(p[1]); a == b {
+			t.Errorf("nftComment(%q) = nftComment(%q) = %q", p[0], p[1], a)
+		}
+		if a, b := logRule(p[0]), logRule(p[1]); a == b {
+			t.Errorf("logRule(%q) = logRule(%q) = %q", p[0], p[1], a)
+		}
+	}
+}
+
+// TestTranslateHostileComment checks that a rule comment cannot add
+// statements or chains to the ruleset.
+func TestTranslateHostileComment(t *testing.T) {
+	comment := "default/web/x\" accept\n    }\n    chain evil {\n        type filter hook input priority -500; policy accept;\n#"
+	ir := &policy.IR{FirewallRules: []policy.CompiledFirewallRule{{
+		Chain:    "input",
+		Action:   "accept",
+		Protocol: "tcp",
+		Log:      true,
+		Comment:  comment,
+	}}}
+	out, err := NewAdapter("aegisx", t.TempDir(), true, zap.NewNop()).Translate(ir)
+	if err != nil {
+		t.Fatalf("Translate: %v", err)
+	}
+	for _, line := range strings.Split(out, "\n") {
+		if strings.HasPrefix(strings.TrimSpace(line), "chain evil") {
+			t.Errorf("comment escaped its string:\n%s", out)
+		}
+		if strings.Count(line, `"`)%2 != 0 {
+			t.Errorf("unbalanced quotes in %q", line)
+		}
+	}
+}
+
+// checkNFTString fails unless s fits in max bytes and can sit between the
+// quotes of an nft string.
+func checkNFTString(t *testing.T, s string, max int) {
+	t.Helper()
+	if len(s) > max {
+		t.Errorf("%q is %d bytes, more than %d", s, len(s), max)
+	}
+	if !utf8.ValidString(s) {
+		t.Errorf("%q is not valid UTF-8", s)
+	}
+	for _, c := range s {
+		if c == '"' || c == '\\' || unicode.IsControl(c) {
+			t.Errorf("%q holds %q", s, c)
+		}
+	}
+}
@@ -0,0 +1,118 @@
+package firewall
+
+import (
+	"encoding/json"
+	"fmt"
+)
+
+// Ruleset is the AegisX table as `nft -j list table` reports it. Rule
+// expressions and set elements keep nft's JSON form, which varies by
+// statement; callers decode the parts they need.
+type Ruleset struct {
+	NftVersion string     `json:"nftVersion,omitempty"`
+	Table      *NftTable  `json:"table,omitempty"`
+	Chains     []NftChain `json:"chains"`
+	Rules      []NftRule  `json:"rules"`
+	Sets       []NftSet   `json:"sets"`
+}
+
+type NftTable struct {
+	Family string `json:"family"`
+	Name   string `json:"name"`
+	Handle int    `json:"handle"`
+}
+
+type NftChain struct {
+	Family string `json:"family"`
+	Table  string `json:"table"`
+	Name   string `json:"name"`
+	Handle int    `json:"handle"`
+	Type   string `json:"type,omitempty"`
+	Hook   string `json:"hook,omitempty"`
+	Prio   *int   `json:"prio,omitempty"`
+	Policy string `json:"policy,omitempty"`
+}
+
+type NftRule struct {
+	Family  string            `json:"family"`
+	Table   string            `json:"table"`
+	Chain   string            `json:"chain"`
+	Handle  int               `json:"handle"`
+	Comment string            `json:"comment,omitempty"`
+	Expr    []json.RawMessage `json:"expr"`
+}
+
+type NftSet struct {
+	Family string            `json:"family"`
+	Table  string            `json:"table"`
+	Name   string            `json:"name"`
+	Handle int               `json:"handle"`
+	Type   json.RawMessage   `json:"type"` // a type name, or a list of them for concatenations
+	Flags  []string          `json:"flags,omitempty"`
+	Elem   []json.RawMessage `json:"elem,omitempty"`
+}
+
+// Counter returns the packet and byte count of the rule's counter
+// statements, and false when it has none.
+func (r NftRule) Counter() (ruleCounter, bool) {
+	var total ruleCounter
+	found := false
+	for _, raw := range r.Expr {
+		var e struct {
+			Counter *ruleCounter `json:"counter"`
+		}
+		if json.Unmarshal(raw, &e) != nil || e.Counter == nil {
+			continue
+		}
+		total.Packets += e.Counter.Packets
+		total.Bytes += e.Counter.Bytes
+		found = true
+	}
+	return total, found
+}
+
+// Rule returns the rule with the given handle, and false when the table
+// has none.
+func (rs *Ruleset) Rule(handle int) (NftRule, bool) {
+	for _, r := range rs.Rules {
+		if r.Handle == handle {
+			return r, true
+		}
+	}
+	return NftRule{}, false
+}
+
+// parseRuleset decodes the output of `nft -j list …`.
+func parseRuleset(data []byte) (*Ruleset, error) {
+	var doc struct {
+		Nftables []struct {
+			Metainfo *struct {
+				Version string `json:"version"`
+			} `json:"metainfo"`
+			Table *NftTable `json:"table"`
+			Chain *NftChain `json:"chain"`
+			Rule  *NftRule  `json:"rule"`
+			Set   *NftSet   `json:"set"`
+		} `json:"nftables"`
+	}
+	if err := json.Unmarshal(data, &doc); err != nil {
+		return nil, fmt.Errorf("parse nft json: %w", err)
+	}
+
+	rs := &Ruleset{Chains: []NftChain{}, Rules: []NftRule{}, Sets: []NftSet{}}
+	for _, obj := range doc.Nftables {
+		switch {
+		case obj.Metainfo != nil:
+			rs.NftVersion = obj.Metainfo.Version
+		case obj.Table != nil:
+			rs.Table = obj.Table
+		case obj.Chain != nil:
+			rs.Chains = append(rs.Chains, *obj.Chain)
+		case obj.Rule != nil:
+			rs.Rules = append(rs.Rules, *obj.Rule)
+		case obj.Set != nil:
+			rs.Sets = append(rs.Sets, *obj.Set)
+		}
+	}
+	return rs, nil
+}
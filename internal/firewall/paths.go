@@ -0,0 +1,155 @@
+package firewall
+
+import (
+	"context"
+	"time"
+
+	"go.uber.org/zap"
+
+	"github.com/aegisx/aegisx/internal/health"
+	"github.com/aegisx/aegisx/internal/metrics"
+	"github.com/aegisx/aegisx/internal/policy"
+)
+
+// Reasons a WAN path is steered the way it is.
+const (
+	pathWithin    = "within thresholds"
+	pathUnchecked = "uplink has no check"
+	pathDegraded  = "no uplink within thresholds; least loss and latency"
+	pathKept      = "no uplink within thresholds; kept"
+	pathNoUplink  = "no healthy uplink"
+)
+
+// WANPaths returns the paths of the applied WANPolicy with the uplink each
+// currently steers over.
+func (s *Service) WANPaths() []policy.CompiledWANPath {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return append([]policy.CompiledWANPath{}, s.wanPaths...)
+}
+
+// WatchWANPaths re-evaluates the WAN paths every interval against the
+// latest latency and loss of their uplinks, and re-applies the ruleset when
+// a path should move. Call this in a goroutine; it blocks until ctx is
+// cancelled.
+func (s *Service) WatchWANPaths(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+		s.resteer(ctx)
+	}
+}
+
+// ─── Private helpers ──────────────────────────────────────────────────────
+
+// resteer re-applies the current IR if a path's uplink choice changed.
+func (s *Service) resteer(ctx context.Context) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.current == nil || s.current.WAN == nil || len(s.current.WAN.Paths) == 0 {
+		return
+	}
+	if samePaths(selectPaths(s.current.WAN, s.health, s.wanPaths), s.wanPaths) {
+		return
+	}
+	actx, cancel := s.applyContext(ctx)
+	defer cancel()
+	if err := s.applyGated(actx, s.current); err != nil {
+		s.log.Error("wan path re-steer failed", zap.Error(err))
+	}
+}
+
+// selectPaths chooses an uplink for each path of w: the first healthy one
+// in the path's order whose check is within its thresholds. Failing that,
+// a path stays on its previous uplink in prev while it is healthy, and
+// otherwise goes to the healthy uplink with the least loss, then latency.
+// A target that has not settled yet counts as healthy, as in gateIR.
+func selectPaths(w *policy.CompiledWAN, mon *health.Monitor, prev []policy.CompiledWANPath) []policy.CompiledWANPath {
+	uplinks := make(map[string]policy.CompiledWANUplink, len(w.Uplinks))
+	for _, u := range w.Uplinks {
+		uplinks[u.Name] = u
+	}
+	was := make(map[string]string, len(prev))
+	for _, p := range prev {
+		was[p.Name] = p.Uplink
+	}
+
+	out := make([]policy.CompiledWANPath, len(w.Paths))
+	for i, p := range w.Paths {
+		p.Uplink, p.Reason = "", pathNoUplink
+		var best string
+		var bestSt health.TargetStatus
+		kept := false
+		for _, name := range p.Uplinks {
+			u, ok := uplinks[name]
+			if !ok {
+				continue
+			}
+			if u.Check == "" {
+				p.Uplink, p.Reason = name, pathUnchecked
+				break
+			}
+			st, _ := mon.Status(u.Check)
+			if st.Known && !st.Up {
+				continue
+			}
+			if (p.MaxLatencyMs == 0 || st.AvgLatencyMs <= p.MaxLatencyMs) &&
+				(p.MaxLossPct == 0 || st.LossPct <= p.MaxLossPct) {
+				p.Uplink, p.Reason = name, pathWithin
+				break
+			}
+			kept = kept || was[p.Name] == name
+			if best == "" || st.LossPct < bestSt.LossPct ||
+				st.LossPct == bestSt.LossPct && st.AvgLatencyMs < bestSt.AvgLatencyMs {
+				best, bestSt = name, st
+			}
+		}
+		switch {
+		case p.Uplink != "":
+		case kept:
+			p.Uplink, p.Reason = was[p.Name], pathKept
+		case best != "":
+			p.Uplink, p.Reason = best, pathDegraded
+		}
+		out[i] = p
+	}
+	return out
+}
+
+// samePaths reports whether a and b steer every path over the same uplink.
+func samePaths(a, b []policy.CompiledWANPath) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i].Name != b[i].Name || a[i].Uplink != b[i].Uplink {
+			return false
+		}
+	}
+	return true
+}
+
+// steerPaths records paths as applied and logs every path that moved to
+// another uplink since the last apply. s.mu must be held.
+func (s *Service) steerPaths(paths []policy.CompiledWANPath) {
+	was := make(map[string]string, len(s.wanPaths))
+	for _, p := range s.wanPaths {
+		was[p.Name] = p.Uplink
+	}
+	for _, p := range paths {
+		from, ok := was[p.Name]
+		if !ok || from == p.Uplink {
+			continue
+		}
+		metrics.WANPathSteersTotal.WithLabelValues(p.Name, p.Uplink).Inc()
+		s.log.Warn("wan path re-steered", zap.String("path", p.Name),
+			zap.String("from", from), zap.String("to", p.Uplink), zap.String("reason", p.Reason))
+	}
+	s.wanPaths = paths
+}
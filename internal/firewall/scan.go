@@ -0,0 +1,172 @@
+package firewall
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os/exec"
+	"strings"
+	"time"
+
+	"go.uber.org/zap"
+
+	"github.com/aegisx/aegisx/internal/metrics"
+)
+
+// ScanDetection flags sources that open connections to many distinct ports.
+// nftables does the counting: each new source/port pair is remembered for a
+// minute, and a per-source meter trips once more than PortsPerMinute pairs
+// were new. A tripped source stays flagged for Cooldown.
+type ScanDetection struct {
+	PortsPerMinute int
+	Action         string // log | throttle | block
+	Cooldown       time.Duration
+	ThrottleRate   string   // new connections a flagged source may open in throttle mode, e.g. "10/minute"
+	Exempt         []string // CIDRs never flagged (monitoring, vulnerability scanners)
+}
+
+// ScanEvent reports a newly flagged source.
+type ScanEvent struct {
+	At     time.Time `json:"at"`
+	SrcIP  string    `json:"srcIp"`
+	Action string    `json:"action"`
+}
+
+// FlaggedSource is an entry of the scan_flagged set.
+type FlaggedSource struct {
+	Address   string `json:"address"`
+	ExpiresIn int    `json:"expiresIn"` // seconds
+}
+
+const scanFlaggedSet = "scan_flagged"
+
+// translateScan returns the sets and the scan_detect chain body.
+func (a *Adapter) translateScan(sd *ScanDetection) (sets, rules []string) {
+	cooldown := int(sd.Cooldown.Seconds())
+	if cooldown < 1 {
+		cooldown = 600
+	}
+	sets = []string{
+		"set scan_seen { type ipv4_addr . inet_service; flags dynamic, timeout; timeout 1m; size 65535; }",
+		"set scan_meter { type ipv4_addr; flags dynamic, timeout; timeout 1m; size 65535; }",
+		fmt.Sprintf("set %s { type ipv4_addr; flags dynamic, timeout; timeout %ds; size 65535; }", scanFlaggedSet, cooldown),
+	}
+
+	switch sd.Action {
+	case "block":
+		rules = append(rules, `ip saddr @scan_flagged drop comment "port scan: blocked"`)
+	case "throttle":
+		sets = append(sets, "set scan_throttle { type ipv4_addr; flags dynamic, timeout; timeout 1m; size 65535; }")
+		rules = append(rules,
+			fmt.Sprintf(`ip saddr @scan_flagged ct state new add @scan_throttle { ip saddr limit rate over %s } drop comment "port scan: throttled"`, sd.ThrottleRate),
+			"ip saddr @scan_flagged return")
+	default:
+		rules = append(rules, "ip saddr @scan_flagged return")
+	}
+	if len(sd.Exempt) > 0 {
+		rules = append(rules, "ip saddr "+nftSet(sd.Exempt)+" return")
+	}
+
+	detect := fmt.Sprintf("meta l4proto { tcp, udp } ip saddr . th dport != @scan_seen "+
+		"add @scan_seen { ip saddr . th dport } "+
+		"add @scan_meter { ip saddr limit rate over %d/minute } "+
+		`add @scan_flagged { ip saddr } log prefix "[aegisx] port scan: "`, sd.PortsPerMinute)
+	if sd.Action == "block" {
+		detect += " drop"
+	}
+	rules = append(rules, detect+` comment "port scan: detected"`)
+	return sets, rules
+}
+
+// FlaggedSources lists the sources currently flagged as scanners.
+func (a *Adapter) FlaggedSources() ([]FlaggedSource, error) {
+	out, err := exec.Command("nft", "-j", "list", "set", "inet", a.tableName, scanFlaggedSet).Output()
+	if err != nil {
+		return nil, fmt.Errorf("nft list set: %w", err)
+	}
+	var doc struct {
+		Nftables []struct {
+			Set *struct {
+				Elem []json.RawMessage `json:"elem"`
+			} `json:"set"`
+		} `json:"nftables"`
+	}
+	if err := json.Unmarshal(out, &doc); err != nil {
+		return nil, fmt.Errorf("parse nft set: %w", err)
+	}
+
+	items := []FlaggedSource{}
+	for _, obj := range doc.Nftables {
+		if obj.Set == nil {
+			continue
+		}
+		for _, raw := range obj.Set.Elem {
+			// Elements with a timeout are objects; plain ones are bare strings.
+			var e struct {
+				Elem struct {
+					Val     string `json:"val"`
+					Expires int    `json:"expires"`
+				} `json:"elem"`
+			}
+			var fs FlaggedSource
+			if json.Unmarshal(raw, &e) == nil && e.Elem.Val != "" {
+				fs = FlaggedSource{Address: e.Elem.Val, ExpiresIn: e.Elem.Expires}
+			} else if json.Unmarshal(raw, &fs.Address) != nil {
+				continue
+			}
+			items = append(items, fs)
+		}
+	}
+	return items, nil
+}
+
+// ScanStatus returns the currently flagged sources; empty when detection is off.
+func (s *Service) ScanStatus() ([]FlaggedSource, error) {
+	if s.cfg.Scan == nil {
+		return []FlaggedSource{}, nil
+	}
+	return s.adapter.FlaggedSources()
+}
+
+// WatchScans polls the flagged set every interval and calls onScan for each
+// source that was not flagged at the previous poll.
+// Call this in a goroutine; it blocks until ctx is cancelled.
+func (s *Service) WatchScans(ctx context.Context, interval time.Duration, onScan func(ScanEvent)) {
+	if s.cfg.Scan == nil {
+		return
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	seen := make(map[string]bool)
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		items, err := s.adapter.FlaggedSources()
+		if err != nil {
+			// The set only exists once a ruleset has been applied.
+			if !strings.Contains(err.Error(), "exit status") {
+				s.log.Warn("poll port scan set", zap.Error(err))
+			}
+			continue
+		}
+		now := make(map[string]bool, len(items))
+		for _, it := range items {
+			now[it.Address] = true
+			if seen[it.Address] {
+				continue
+			}
+			metrics.FirewallScansDetectedTotal.WithLabelValues(s.cfg.Scan.Action).Inc()
+			s.log.Warn("port scan detected",
+				zap.String("src_ip", it.Address), zap.String("action", s.cfg.Scan.Action))
+			if onScan != nil {
+				onScan(ScanEvent{At: time.Now(), SrcIP: it.Address, Action: s.cfg.Scan.Action})
+			}
+		}
+		seen = now
+	}
+}
@@ -0,0 +1,120 @@
+package firewall
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/aegisx/aegisx/pkg/version"
+)
+
+// KernelStatus describes the AegisX table as the kernel holds it.
+type KernelStatus struct {
+	NftVersion  string         `json:"nftVersion"`
+	Table       string         `json:"table"`
+	TableHandle *int           `json:"tableHandle,omitempty"` // nil when the table does not exist
+	Chains      []ChainStatus  `json:"chains"`
+	LastApply   *ApplyRecord   `json:"lastApply,omitempty"` // nil before the first apply
+	Rollback    RollbackStatus `json:"rollback"`
+	DryRun      bool           `json:"dryRun,omitempty"`
+	Error       string         `json:"error,omitempty"` // why the table could not be read
+}
+
+// ChainStatus is one chain of the table with its rule count.
+type ChainStatus struct {
+	Name     string `json:"name"`
+	Handle   int    `json:"handle"`
+	Type     string `json:"type,omitempty"` // filter|nat|route; empty for regular chains
+	Hook     string `json:"hook,omitempty"`
+	Priority *int   `json:"priority,omitempty"`
+	Policy   string `json:"policy,omitempty"`
+	Rules    int    `json:"rules"`
+}
+
+// ApplyRecord is the outcome of the last ruleset load.
+type ApplyRecord struct {
+	At       time.Time `json:"at"`
+	Duration string    `json:"duration"`
+	IRID     string    `json:"irId"`
+	Error    string    `json:"error,omitempty"`
+}
+
+// RollbackStatus reports the saved snapshots Rollback can restore.
+type RollbackStatus struct {
+	Available bool       `json:"available"`
+	Snapshots int        `json:"snapshots"`
+	Latest    *time.Time `json:"latest,omitempty"`
+}
+
+// KernelStatus reads the table metadata from the kernel. A missing table
+// is reported in Error rather than as a failure, so the caller still gets
+// the apply and rollback state.
+func (s *Service) KernelStatus(ctx context.Context) *KernelStatus {
+	nft := version.Backends(ctx, "nftables")["nftables"]
+
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	a := s.adapter
+	st := &KernelStatus{
+		NftVersion: nft,
+		Table:      a.tableName,
+		Chains:     []ChainStatus{},
+		Rollback:   a.rollbackStatus(),
+		DryRun:     a.dryRun,
+	}
+	if a.lastApply != nil {
+		rec := *a.lastApply
+		st.LastApply = &rec
+	}
+	if err := a.readTable(st); err != nil {
+		st.Error = err.Error()
+	}
+	return st
+}
+
+// readTable fills the table handle and chains of st from `nft -j`.
+func (a *Adapter) readTable(st *KernelStatus) error {
+	rs, err := a.dumpCurrent()
+	if err != nil {
+		return err
+	}
+	if rs.Table != nil {
+		h := rs.Table.Handle
+		st.TableHandle = &h
+	}
+	index := make(map[string]int, len(rs.Chains))
+	for _, ch := range rs.Chains {
+		index[ch.Name] = len(st.Chains)
+		st.Chains = append(st.Chains, ChainStatus{
+			Name: ch.Name, Handle: ch.Handle, Type: ch.Type, Hook: ch.Hook, Priority: ch.Prio, Policy: ch.Policy,
+		})
+	}
+	for _, r := range rs.Rules {
+		if i, ok := index[r.Chain]; ok {
+			st.Chains[i].Rules++
+		}
+	}
+	return nil
+}
+
+func (a *Adapter) rollbackStatus() RollbackStatus {
+	var rs RollbackStatus
+	entries, err := os.ReadDir(a.rollbackDir)
+	if err != nil {
+		return rs
+	}
+	for _, e := range entries {
+		if e.IsDir() || filepath.Ext(e.Name()) != ".conf" {
+			continue
+		}
+		rs.Snapshots++
+		if info, err := e.Info(); err == nil {
+			if t := info.ModTime(); rs.Latest == nil || t.After(*rs.Latest) {
+				rs.Latest = &t
+			}
+		}
+	}
+	rs.Available = rs.Snapshots > 0
+	return rs
+}
@@ -0,0 +1,293 @@
+package firewall
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"go.uber.org/zap"
+
+	"github.com/aegisx/aegisx/internal/hooks"
+	"github.com/aegisx/aegisx/internal/metrics"
+)
+
+// VerifyReport is the outcome of comparing the live table with the ruleset
+// the current IR renders to.
+type VerifyReport struct {
+	At         time.Time `json:"at"`
+	IRID       string    `json:"irId"`
+	Mismatches []string  `json:"mismatches"`
+}
+
+// OK reports whether the live table matched.
+func (r *VerifyReport) OK() bool { return len(r.Mismatches) == 0 }
+
+// Err returns the mismatches as one error, or nil when the table matched.
+func (r *VerifyReport) Err() error {
+	if r.OK() {
+		return nil
+	}
+	return fmt.Errorf("live ruleset differs from IR %s:\n  - %s", r.IRID, strings.Join(r.Mismatches, "\n  - "))
+}
+
+// Verify re-renders the current IR and checks the live table against it:
+// every chain and named set must exist, every chain must hold the same
+// commented rules, and each of those rules its counter and the same
+// anonymous set elements. It returns nil before the first apply.
+func (s *Service) Verify() (*VerifyReport, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	if s.current == nil {
+		return nil, nil
+	}
+	text, err := s.adapter.Translate(gateIR(s.current, s.health))
+	if err != nil {
+		return nil, fmt.Errorf("render: %w", err)
+	}
+	live, err := s.adapter.dumpCurrent()
+	if err != nil {
+		return nil, err
+	}
+	return &VerifyReport{
+		At:         time.Now(),
+		IRID:       s.current.ID,
+		Mismatches: compareRuleset(parseRendered(text), live),
+	}, nil
+}
+
+// WatchVerify runs Verify every interval. A mismatch is counted, logged and
+// reported to the verify-mismatch hooks.
+// Call this in a goroutine; it blocks until ctx is cancelled.
+func (s *Service) WatchVerify(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		rep, err := s.Verify()
+		if err != nil {
+			metrics.FirewallVerifyTotal.WithLabelValues("error").Inc()
+			s.log.Warn("ruleset verification failed", zap.Error(err))
+			continue
+		}
+		if rep == nil {
+			continue
+		}
+		if rep.OK() {
+			metrics.FirewallVerifyTotal.WithLabelValues("ok").Inc()
+			continue
+		}
+		metrics.FirewallVerifyTotal.WithLabelValues("mismatch").Inc()
+		s.log.Error("live ruleset does not match the applied IR",
+			zap.String("ir_id", rep.IRID), zap.Strings("mismatches", rep.Mismatches))
+		s.cfg.Hooks.Notify(hooks.VerifyMismatch, s.CurrentIR(), rep.Err())
+	}
+}
+
+// expectedRuleset is what verification needs from a rendered ruleset.
+type expectedRuleset struct {
+	chains []string
+	sets   []string
+	rules  map[string]map[string]int // chain → comment → count
+	elems  map[string][]string       // chain/comment → sorted anonymous set elements
+}
+
+var (
+	renderedChain   = regexp.MustCompile(`^chain (\S+) \{`)
+	renderedSet     = regexp.MustCompile(`^set (\S+) \{`)
+	renderedComment = regexp.MustCompile(`comment "([^"]*)"$`)
+	renderedAnonSet = regexp.MustCompile(`\{ ([^{}]*) \}`)
+)
+
+// parseRendered reads the chains, named sets and commented rules of a
+// ruleset produced by Translate. It only has to understand our own output.
+func parseRendered(text string) *expectedRuleset {
+	exp := &expectedRuleset{rules: make(map[string]map[string]int), elems: make(map[string][]string)}
+	chain := ""
+	for _, line := range strings.Split(text, "\n") {
+		line = strings.TrimSpace(line)
+		switch {
+		case line == "" || strings.HasPrefix(line, "#"):
+			continue
+		case line == "}":
+			chain = ""
+			continue
+		}
+		if m := renderedChain.FindStringSubmatch(line); m != nil {
+			chain = m[1]
+			exp.chains = append(exp.chains, chain)
+			exp.rules[chain] = make(map[string]int)
+			continue
+		}
+		if m := renderedSet.FindStringSubmatch(line); m != nil {
+			exp.sets = append(exp.sets, m[1])
+			continue
+		}
+		m := renderedComment.FindStringSubmatch(line)
+		if chain == "" || m == nil {
+			continue
+		}
+		exp.rules[chain][m[1]]++
+
+		var elems []string
+		for _, set := range renderedAnonSet.FindAllStringSubmatch(line, -1) {
+			if strings.Contains(set[1], " : ") {
+				continue // a map, not a set
+			}
+			for _, e := range strings.Split(set[1], ",") {
+				elems = append(elems, normElem(e))
+			}
+		}
+		sort.Strings(elems)
+		exp.elems[chain+"/"+m[1]] = elems
+	}
+	return exp
+}
+
+// compareRuleset lists every difference between exp and the live table.
+func compareRuleset(exp *expectedRuleset, live *Ruleset) []string {
+	var out []string
+
+	liveChains := make(map[string]bool, len(live.Chains))
+	for _, c := range live.Chains {
+		liveChains[c.Name] = true
+	}
+	for _, c := range exp.chains {
+		if !liveChains[c] {
+			out = append(out, fmt.Sprintf("chain %s is missing", c))
+		}
+	}
+	liveSets := make(map[string]bool, len(live.Sets))
+	for _, s := range live.Sets {
+		liveSets[s.Name] = true
+	}
+	for _, s := range exp.sets {
+		if !liveSets[s] {
+			out = append(out, fmt.Sprintf("set %s is missing", s))
+		}
+	}
+
+	liveRules := make(map[string]map[string][]NftRule)
+	for _, r := range live.Rules {
+		if r.Comment == "" {
+			continue
+		}
+		if liveRules[r.Chain] == nil {
+			liveRules[r.Chain] = make(map[string][]NftRule)
+		}
+		liveRules[r.Chain][r.Comment] = append(liveRules[r.Chain][r.Comment], r)
+	}
+
+	for _, chain := range exp.chains {
+		want, have := exp.rules[chain], liveRules[chain]
+		comments := make([]string, 0, len(want))
+		for c := range want {
+			comments = append(comments, c)
+		}
+		sort.Strings(comments)
+		for _, comment := range comments {
+			rules := have[comment]
+			if len(rules) != want[comment] {
+				out = append(out, fmt.Sprintf("chain %s: rule %q appears %d times, expected %d", chain, comment, len(rules), want[comment]))
+				continue
+			}
+			if len(rules) != 1 {
+				continue // duplicates cannot be told apart
+			}
+			if _, ok := rules[0].Counter(); !ok && strings.Count(comment, "/") == 2 {
+				out = append(out, fmt.Sprintf("chain %s: rule %q has no counter", chain, comment))
+			}
+			if got, exp := anonSetElems(rules[0]), exp.elems[chain+"/"+comment]; strings.Join(got, ",") != strings.Join(exp, ",") {
+				out = append(out, fmt.Sprintf("chain %s: rule %q set elements are [%s], expected [%s]",
+					chain, comment, strings.Join(got, ", "), strings.Join(exp, ", ")))
+			}
+		}
+	}
+	reported := make(map[string]bool)
+	for _, r := range live.Rules {
+		want, ok := exp.rules[r.Chain]
+		if r.Comment == "" || !ok || want[r.Comment] > 0 || reported[r.Chain+"/"+r.Comment] {
+			continue
+		}
+		reported[r.Chain+"/"+r.Comment] = true
+		out = append(out, fmt.Sprintf("chain %s: unexpected rule %q (handle %d)", r.Chain, r.Comment, r.Handle))
+	}
+	return out
+}
+
+// anonSetElems returns the sorted elements of the anonymous sets a rule
+// matches against, in the notation Translate writes them.
+func anonSetElems(r NftRule) []string {
+	var elems []string
+	for _, raw := range r.Expr {
+		var e struct {
+			Match *struct {
+				Right json.RawMessage `json:"right"`
+			} `json:"match"`
+		}
+		if json.Unmarshal(raw, &e) != nil || e.Match == nil {
+			continue
+		}
+		// Sets of addresses and ports come as {"set": [...]}, flag sets
+		// such as ct state as a plain list.
+		var right struct {
+			Set []json.RawMessage `json:"set"`
+		}
+		var list []json.RawMessage
+		if json.Unmarshal(e.Match.Right, &right) == nil {
+			list = right.Set
+		} else if json.Unmarshal(e.Match.Right, &list) != nil {
+			continue
+		}
+		for _, el := range list {
+			elems = append(elems, normElem(elemString(el)))
+		}
+	}
+	sort.Strings(elems)
+	return elems
+}
+
+// elemString formats one nft JSON set element: an address or port, a
+// prefix or a range.
+func elemString(raw json.RawMessage) string {
+	var s string
+	if json.Unmarshal(raw, &s) == nil {
+		return s
+	}
+	var n json.Number
+	if json.Unmarshal(raw, &n) == nil {
+		return n.String()
+	}
+	var v struct {
+		Prefix *struct {
+			Addr string `json:"addr"`
+			Len  int    `json:"len"`
+		} `json:"prefix"`
+		Range []json.RawMessage `json:"range"`
+	}
+	if json.Unmarshal(raw, &v) == nil {
+		switch {
+		case v.Prefix != nil:
+			return v.Prefix.Addr + "/" + strconv.Itoa(v.Prefix.Len)
+		case len(v.Range) == 2:
+			return elemString(v.Range[0]) + "-" + elemString(v.Range[1])
+		}
+	}
+	return string(raw)
+}
+
+// normElem makes rendered and reported elements comparable: day names are
+// quoted in the ruleset and nft may change their case.
+func normElem(e string) string {
+	return strings.ToLower(strings.Trim(strings.TrimSpace(e), `"`))
+}
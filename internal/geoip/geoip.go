@@ -0,0 +1,213 @@
+// Package geoip resolves addresses to their country and autonomous system
+// using MaxMind DB files (GeoLite2/GeoIP2 Country or City, and ASN), so
+// firewall events and IDS alerts can be enriched when they are stored, and
+// fills the country sets firewall rules match against.
+package geoip
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"sync"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+// Info is what is known about an address. Empty fields mean unknown.
+type Info struct {
+	Country string `json:"country,omitempty"` // ISO 3166-1 alpha-2
+	ASN     uint32 `json:"asn,omitempty"`
+	ASOrg   string `json:"asOrg,omitempty"`
+}
+
+// Config names the database files; either may be empty.
+type Config struct {
+	CountryDB string
+	ASNDB     string
+}
+
+// Resolver looks addresses up in the loaded databases. A nil Resolver, or
+// one without databases, resolves nothing.
+type Resolver struct {
+	mu      sync.RWMutex
+	cfg     Config
+	country *mmdb
+	asn     *mmdb
+	mtimes  map[string]time.Time // of the files as last loaded
+	log     *zap.Logger
+}
+
+// NewResolver loads the configured databases. A database that cannot be
+// read is logged and left out rather than failing startup: enrichment is
+// best effort.
+func NewResolver(cfg Config, log *zap.Logger) *Resolver {
+	r := &Resolver{cfg: cfg, mtimes: make(map[string]time.Time), log: log}
+	if err := r.Reload(); err != nil {
+		log.Warn("geoip databases not loaded", zap.Error(err))
+	}
+	return r
+}
+
+// Reload rereads the database files, e.g. after geoipupdate replaced them.
+// A database that fails to load keeps its previous copy.
+func (r *Resolver) Reload() error {
+	var errs []error
+	load := func(path string, dst **mmdb) {
+		if path == "" {
+			return
+		}
+		fi, err := os.Stat(path)
+		if err != nil {
+			errs = append(errs, err)
+			return
+		}
+		db, err := openMMDB(path)
+		if err != nil {
+			errs = append(errs, err)
+			return
+		}
+		r.mu.Lock()
+		*dst = db
+		r.mtimes[path] = fi.ModTime()
+		r.mu.Unlock()
+		r.log.Info("geoip database loaded", zap.String("path", path), zap.String("type", db.dbType))
+	}
+	load(r.cfg.CountryDB, &r.country)
+	load(r.cfg.ASNDB, &r.asn)
+	return errors.Join(errs...)
+}
+
+// ReloadIfChanged rereads the databases when a file was replaced or
+// modified since it was last loaded, and reports whether it did.
+func (r *Resolver) ReloadIfChanged() (bool, error) {
+	changed := false
+	r.mu.RLock()
+	for _, path := range []string{r.cfg.CountryDB, r.cfg.ASNDB} {
+		if path == "" {
+			continue
+		}
+		if fi, err := os.Stat(path); err == nil && !fi.ModTime().Equal(r.mtimes[path]) {
+			changed = true
+		}
+	}
+	r.mu.RUnlock()
+	if !changed {
+		return false, nil
+	}
+	return true, r.Reload()
+}
+
+// CountryNetworks returns the networks the country database places in each
+// of the given countries, keyed by upper-case ISO code. IPv4 networks have
+// 4-byte addresses. A country the database does not know gets none.
+func (r *Resolver) CountryNetworks(countries []string) (map[string][]*net.IPNet, error) {
+	if r == nil {
+		return nil, errors.New("geoip is not configured")
+	}
+	r.mu.RLock()
+	db := r.country
+	r.mu.RUnlock()
+	if db == nil {
+		return nil, errors.New("no country database loaded")
+	}
+
+	out := make(map[string][]*net.IPNet, len(countries))
+	for _, c := range countries {
+		out[c] = nil
+	}
+	codes := make(map[uint]string) // record offset → country; records are shared
+	var decodeErr error
+	err := db.networks(func(n *net.IPNet, off uint) {
+		code, ok := codes[off]
+		if !ok {
+			v, _, err := db.decode(off)
+			if err != nil && decodeErr == nil {
+				decodeErr = err
+			}
+			rec, _ := v.(map[string]any)
+			if code = isoCode(rec, "country"); code == "" {
+				code = isoCode(rec, "registered_country")
+			}
+			codes[off] = code
+		}
+		if _, want := out[code]; want && code != "" {
+			out[code] = append(out[code], n)
+		}
+	})
+	if err == nil && decodeErr != nil {
+		err = decodeErr
+	}
+	if err != nil {
+		return nil, fmt.Errorf("walk country database: %w", err)
+	}
+	return out, nil
+}
+
+// Enabled reports whether any database is loaded.
+func (r *Resolver) Enabled() bool {
+	if r == nil {
+		return false
+	}
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return r.country != nil || r.asn != nil
+}
+
+// Lookup resolves addr. Private, loopback and otherwise unroutable
+// addresses, and addresses the databases do not cover, yield an empty Info.
+func (r *Resolver) Lookup(addr string) Info {
+	var info Info
+	if r == nil {
+		return info
+	}
+	ip := net.ParseIP(addr)
+	if ip == nil || !routable(ip) {
+		return info
+	}
+
+	r.mu.RLock()
+	country, asn := r.country, r.asn
+	r.mu.RUnlock()
+
+	if country != nil {
+		rec, err := country.lookup(ip)
+		if err != nil {
+			r.log.Debug("geoip country lookup", zap.String("addr", addr), zap.Error(err))
+		}
+		info.Country = isoCode(rec, "country")
+		if info.Country == "" {
+			info.Country = isoCode(rec, "registered_country")
+		}
+	}
+	if asn != nil {
+		rec, err := asn.lookup(ip)
+		if err != nil {
+			r.log.Debug("geoip asn lookup", zap.String("addr", addr), zap.Error(err))
+		}
+		info.ASN = uint32(asUint(rec["autonomous_system_number"]))
+		info.ASOrg, _ = rec["autonomous_system_organization"].(string)
+	}
+	return info
+}
+
+// Remote resolves the remote end of a flow: the source, or the destination
+// when the source is a local address.
+func (r *Resolver) Remote(src, dst string) Info {
+	if ip := net.ParseIP(src); ip != nil && routable(ip) {
+		return r.Lookup(src)
+	}
+	return r.Lookup(dst)
+}
+
+func isoCode(rec map[string]any, field string) string {
+	m, _ := rec[field].(map[string]any)
+	code, _ := m["iso_code"].(string)
+	return code
+}
+
+func routable(ip net.IP) bool {
+	return !ip.IsPrivate() && !ip.IsLoopback() && !ip.IsLinkLocalUnicast() &&
+		!ip.IsMulticast() && !ip.IsUnspecified()
+}
@@ -0,0 +1,340 @@
+package geoip
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"math"
+	"net"
+	"os"
+)
+
+// metadataMarker precedes the metadata map at the end of a MaxMind DB file.
+var metadataMarker = []byte("\xAB\xCD\xEFMaxMind.com")
+
+// dataSectionSeparator is the run of zero bytes between the search tree and
+// the data section.
+const dataSectionSeparator = 16
+
+// mmdb is a MaxMind DB file held in memory. Only the parts of the format
+// the GeoLite2/GeoIP2 Country, City and ASN databases use are decoded.
+type mmdb struct {
+	buf        []byte
+	data       []byte // the data section; pointers are relative to it
+	nodeCount  uint
+	recordSize uint
+	ipVersion  uint
+	dbType     string
+	ipv4Start  uint // node reached after the 96 zero bits of ::/96
+}
+
+func openMMDB(path string) (*mmdb, error) {
+	buf, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	i := bytes.LastIndex(buf, metadataMarker)
+	if i < 0 {
+		return nil, fmt.Errorf("%s: not a MaxMind DB file", path)
+	}
+	meta := &mmdb{data: buf[i+len(metadataMarker):]}
+	raw, _, err := meta.decode(0)
+	if err != nil {
+		return nil, fmt.Errorf("%s: metadata: %w", path, err)
+	}
+	md, ok := raw.(map[string]any)
+	if !ok {
+		return nil, fmt.Errorf("%s: metadata is not a map", path)
+	}
+
+	db := &mmdb{
+		buf:        buf,
+		nodeCount:  uint(asUint(md["node_count"])),
+		recordSize: uint(asUint(md["record_size"])),
+		ipVersion:  uint(asUint(md["ip_version"])),
+	}
+	db.dbType, _ = md["database_type"].(string)
+	switch db.recordSize {
+	case 24, 28, 32:
+	default:
+		return nil, fmt.Errorf("%s: unsupported record size %d", path, db.recordSize)
+	}
+	treeSize := db.nodeCount * db.recordSize / 4
+	if treeSize+dataSectionSeparator > uint(i) {
+		return nil, fmt.Errorf("%s: search tree exceeds file", path)
+	}
+	db.data = buf[treeSize+dataSectionSeparator : i]
+
+	if db.ipVersion == 6 {
+		node := uint(0)
+		for n := 0; n < 96 && node < db.nodeCount; n++ {
+			node = db.record(node, 0)
+		}
+		db.ipv4Start = node
+	}
+	return db, nil
+}
+
+// lookup returns the record for ip, or nil when the database has none.
+func (db *mmdb) lookup(ip net.IP) (map[string]any, error) {
+	node := uint(0)
+	bits := 128
+	if v4 := ip.To4(); v4 != nil {
+		ip, bits = v4, 32
+		if db.ipVersion == 6 {
+			node = db.ipv4Start
+		}
+	} else if db.ipVersion == 4 {
+		return nil, nil
+	}
+
+	for i := 0; i < bits && node < db.nodeCount; i++ {
+		bit := uint(ip[i>>3]>>(7-uint(i&7))) & 1
+		node = db.record(node, bit)
+	}
+	if node <= db.nodeCount {
+		return nil, nil // == nodeCount marks "no data"
+	}
+	off := node - db.nodeCount - dataSectionSeparator
+	if off >= uint(len(db.data)) {
+		return nil, fmt.Errorf("corrupt search tree: data offset %d", off)
+	}
+	v, _, err := db.decode(off)
+	if err != nil {
+		return nil, err
+	}
+	m, _ := v.(map[string]any)
+	return m, nil
+}
+
+// networks calls fn with every network of the search tree that has data,
+// and the offset of its record. IPv4 networks of an IPv6 tree are reported
+// once as IPv4, not again under ::/96 or the other subtrees aliased to them.
+func (db *mmdb) networks(fn func(n *net.IPNet, off uint)) error {
+	if db.ipVersion == 4 {
+		return db.walk(0, make(net.IP, 4), 0, fn)
+	}
+	if err := db.walk(db.ipv4Start, make(net.IP, 4), 0, fn); err != nil {
+		return err
+	}
+	return db.walk(0, make(net.IP, 16), 0, fn)
+}
+
+// walk visits the subtree of node, which ip reaches after depth bits.
+func (db *mmdb) walk(node uint, ip net.IP, depth int, fn func(*net.IPNet, uint)) error {
+	bits := len(ip) * 8
+	switch {
+	case node == db.nodeCount:
+		return nil // no data
+	case node > db.nodeCount:
+		off := node - db.nodeCount - dataSectionSeparator
+		if off >= uint(len(db.data)) {
+			return fmt.Errorf("corrupt search tree: data offset %d", off)
+		}
+		n := &net.IPNet{IP: append(net.IP(nil), ip...), Mask: net.CIDRMask(depth, bits)}
+		fn(n, off)
+		return nil
+	case depth == bits:
+		return fmt.Errorf("corrupt search tree: node %d below a full address", node)
+	case bits == 128 && depth > 0 && node == db.ipv4Start:
+		return nil // reported as IPv4
+	}
+	for bit := uint(0); bit < 2; bit++ {
+		if bit == 1 {
+			ip[depth>>3] |= 0x80 >> uint(depth&7)
+		}
+		if err := db.walk(db.record(node, bit), ip, depth+1, fn); err != nil {
+			return err
+		}
+	}
+	ip[depth>>3] &^= 0x80 >> uint(depth&7)
+	return nil
+}
+
+// record reads the left (bit 0) or right (bit 1) record of a tree node.
+func (db *mmdb) record(node, bit uint) uint {
+	switch db.recordSize {
+	case 24:
+		b := db.buf[node*6+bit*3:]
+		return uint(b[0])<<16 | uint(b[1])<<8 | uint(b[2])
+	case 28:
+		b := db.buf[node*7:]
+		if bit == 0 {
+			return uint(b[3]&0xF0)<<20 | uint(b[0])<<16 | uint(b[1])<<8 | uint(b[2])
+		}
+		return uint(b[3]&0x0F)<<24 | uint(b[4])<<16 | uint(b[5])<<8 | uint(b[6])
+	default:
+		return uint(binary.BigEndian.Uint32(db.buf[node*8+bit*4:]))
+	}
+}
+
+// Data section field types.
+const (
+	typeExtended = 0
+	typePointer  = 1
+	typeString   = 2
+	typeDouble   = 3
+	typeBytes    = 4
+	typeUint16   = 5
+	typeUint32   = 6
+	typeMap      = 7
+	typeInt32    = 8
+	typeUint64   = 9
+	typeUint128  = 10
+	typeArray    = 11
+	typeBool     = 14
+	typeFloat    = 15
+)
+
+// decode reads the field at off and returns it with the offset just past
+// it. Pointers are followed; maps become map[string]any, arrays []any and
+// integers uint64 (int32 stays int32, uint128 is kept as raw bytes).
+func (db *mmdb) decode(off uint) (any, uint, error) {
+	if off >= uint(len(db.data)) {
+		return nil, 0, fmt.Errorf("offset %d out of range", off)
+	}
+	ctrl := db.data[off]
+	off++
+	typ := uint(ctrl >> 5)
+	if typ == typePointer {
+		ptr, next, err := db.pointer(ctrl, off)
+		if err != nil {
+			return nil, 0, err
+		}
+		v, _, err := db.decode(ptr)
+		return v, next, err
+	}
+	if typ == typeExtended {
+		if off >= uint(len(db.data)) {
+			return nil, 0, fmt.Errorf("truncated field at %d", off)
+		}
+		typ = 7 + uint(db.data[off])
+		off++
+	}
+	size, off, err := db.size(ctrl, off)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	switch typ {
+	case typeMap:
+		m := make(map[string]any, size)
+		for i := uint(0); i < size; i++ {
+			k, next, err := db.decode(off)
+			if err != nil {
+				return nil, 0, err
+			}
+			key, ok := k.(string)
+			if !ok {
+				return nil, 0, fmt.Errorf("map key at %d is not a string", off)
+			}
+			v, next, err := db.decode(next)
+			if err != nil {
+				return nil, 0, err
+			}
+			m[key] = v
+			off = next
+		}
+		return m, off, nil
+	case typeArray:
+		a := make([]any, 0, size)
+		for i := uint(0); i < size; i++ {
+			v, next, err := db.decode(off)
+			if err != nil {
+				return nil, 0, err
+			}
+			a = append(a, v)
+			off = next
+		}
+		return a, off, nil
+	case typeBool:
+		return size != 0, off, nil
+	}
+
+	end := off + size
+	if end > uint(len(db.data)) {
+		return nil, 0, fmt.Errorf("field at %d runs past the data section", off)
+	}
+	b := db.data[off:end]
+	switch typ {
+	case typeString:
+		return string(b), end, nil
+	case typeBytes, typeUint128:
+		return append([]byte(nil), b...), end, nil
+	case typeDouble:
+		if size != 8 {
+			return nil, 0, fmt.Errorf("double of size %d", size)
+		}
+		return math.Float64frombits(binary.BigEndian.Uint64(b)), end, nil
+	case typeFloat:
+		if size != 4 {
+			return nil, 0, fmt.Errorf("float of size %d", size)
+		}
+		return float64(math.Float32frombits(binary.BigEndian.Uint32(b))), end, nil
+	case typeUint16, typeUint32, typeUint64:
+		var n uint64
+		for _, c := range b {
+			n = n<<8 | uint64(c)
+		}
+		return n, end, nil
+	case typeInt32:
+		var n uint32
+		for _, c := range b {
+			n = n<<8 | uint32(c)
+		}
+		return int32(n), end, nil
+	}
+	return nil, 0, fmt.Errorf("unsupported field type %d at %d", typ, off)
+}
+
+// pointer resolves the pointer whose control byte is ctrl and whose payload
+// starts at off.
+func (db *mmdb) pointer(ctrl byte, off uint) (ptr, next uint, err error) {
+	n := uint((ctrl>>3)&3) + 1
+	if off+n > uint(len(db.data)) {
+		return 0, 0, fmt.Errorf("truncated pointer at %d", off)
+	}
+	b := db.data[off : off+n]
+	v := uint(ctrl & 7)
+	switch n {
+	case 1:
+		ptr = v<<8 | uint(b[0])
+	case 2:
+		ptr = (v<<16 | uint(b[0])<<8 | uint(b[1])) + 2048
+	case 3:
+		ptr = (v<<24 | uint(b[0])<<16 | uint(b[1])<<8 | uint(b[2])) + 526336
+	default:
+		ptr = uint(binary.BigEndian.Uint32(b))
+	}
+	return ptr, off + n, nil
+}
+
+// size decodes the payload size of a non-pointer field.
+func (db *mmdb) size(ctrl byte, off uint) (uint, uint, error) {
+	size := uint(ctrl & 0x1f)
+	if size < 29 {
+		return size, off, nil
+	}
+	n := size - 28
+	if off+n > uint(len(db.data)) {
+		return 0, 0, fmt.Errorf("truncated size at %d", off)
+	}
+	var v uint
+	for _, c := range db.data[off : off+n] {
+		v = v<<8 | uint(c)
+	}
+	switch size {
+	case 29:
+		v += 29
+	case 30:
+		v += 285
+	default:
+		v += 65821
+	}
+	return v, off + n, nil
+}
+
+func asUint(v any) uint64 {
+	n, _ := v.(uint64)
+	return n
+}
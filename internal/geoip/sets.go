@@ -0,0 +1,132 @@
+package geoip
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"os/exec"
+	"strings"
+	"sync"
+	"time"
+
+	"go.uber.org/zap"
+
+	"github.com/aegisx/aegisx/internal/policy"
+)
+
+// SetsConfig tells CountrySets where the sets live and how often to look
+// for an updated database.
+type SetsConfig struct {
+	Table         string
+	DryRun        bool
+	CheckInterval time.Duration
+}
+
+// CountrySets fills the country sets of the applied ruleset with the
+// networks the country database places in their countries. The ruleset
+// declares them empty, so they are filled after every apply and again
+// whenever the database file is updated.
+type CountrySets struct {
+	mu      sync.Mutex // serializes Sync
+	geo     *Resolver
+	cfg     SetsConfig
+	current func() *policy.IR
+	log     *zap.Logger
+}
+
+// NewCountrySets returns a CountrySets resolving against geo; current
+// returns the applied IR, or nil before the first apply.
+func NewCountrySets(geo *Resolver, cfg SetsConfig, current func() *policy.IR, log *zap.Logger) *CountrySets {
+	return &CountrySets{geo: geo, cfg: cfg, current: current, log: log}
+}
+
+// Run reloads the databases when their files change and refills the sets,
+// checking every CheckInterval.
+// Call this in a goroutine; it blocks until ctx is cancelled.
+func (s *CountrySets) Run(ctx context.Context) {
+	ticker := time.NewTicker(s.cfg.CheckInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+		changed, err := s.geo.ReloadIfChanged()
+		if err != nil {
+			s.log.Warn("reload geoip databases", zap.Error(err))
+		}
+		if !changed {
+			continue
+		}
+		if err := s.Sync(ctx, s.current()); err != nil {
+			s.log.Error("refresh country sets", zap.Error(err))
+		}
+	}
+}
+
+// Sync replaces the elements of every country set of ir. Each set is
+// flushed and refilled in one transaction, so it is never seen half full.
+func (s *CountrySets) Sync(ctx context.Context, ir *policy.IR) error {
+	if ir == nil || len(ir.CountrySets) == 0 {
+		return nil
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var countries []string
+	seen := make(map[string]bool)
+	for _, cs := range ir.CountrySets {
+		for _, c := range cs.Countries {
+			if !seen[c] {
+				seen[c] = true
+				countries = append(countries, c)
+			}
+		}
+	}
+	nets, err := s.geo.CountryNetworks(countries)
+	if err != nil {
+		return err
+	}
+
+	var sb strings.Builder
+	for _, cs := range ir.CountrySets {
+		var elems []string
+		for _, c := range cs.Countries {
+			if len(nets[c]) == 0 {
+				s.log.Warn("country has no networks in the geoip database",
+					zap.String("country", c), zap.String("set", cs.Set))
+			}
+			for _, n := range nets[c] {
+				if (len(n.IP) == net.IPv6len) == (cs.Family == policy.FamilyIPv6) {
+					elems = append(elems, n.String())
+				}
+			}
+		}
+		if s.cfg.DryRun {
+			s.log.Info("dry-run: country set sync", zap.String("set", cs.Set), zap.Int("prefixes", len(elems)))
+			continue
+		}
+		fmt.Fprintf(&sb, "flush set inet %s %s\n", s.cfg.Table, cs.Set)
+		const batch = 1000
+		for i := 0; i < len(elems); i += batch {
+			j := i + batch
+			if j > len(elems) {
+				j = len(elems)
+			}
+			fmt.Fprintf(&sb, "add element inet %s %s { %s }\n", s.cfg.Table, cs.Set, strings.Join(elems[i:j], ", "))
+		}
+	}
+	if sb.Len() == 0 {
+		return nil
+	}
+
+	cmd := exec.CommandContext(ctx, "nft", "-f", "-")
+	cmd.Stdin = strings.NewReader(sb.String())
+	if out, err := cmd.CombinedOutput(); err != nil {
+		return fmt.Errorf("nft: %w (output: %s)", err, out)
+	}
+	s.log.Info("country sets filled", zap.Int("sets", len(ir.CountrySets)))
+	return nil
+}
@@ -0,0 +1,627 @@
+// Package graphql executes read-only GraphQL queries against resolvers that
+// return plain Go values. There is no type system: a resolver's result is
+// walked by reflection, struct fields answer to their JSON names, and any
+// value that marshals itself to JSON is a leaf. Only queries are supported;
+// introspection is limited to __typename.
+package graphql
+
+import (
+	"bytes"
+	"context"
+	"encoding"
+	"encoding/json"
+	"fmt"
+	"math"
+	"reflect"
+	"strings"
+	"sync"
+)
+
+// maxRootFields bounds the resolver calls of one request, aliases included,
+// and maxSelections the fields its fragments and merged selections expand
+// to, so a query cannot make collect do exponential work.
+const (
+	maxRootFields = 20
+	maxSelections = 10000
+)
+
+// Location is a position in the query, counted from 1.
+type Location struct {
+	Line   int `json:"line"`
+	Column int `json:"column"`
+}
+
+// Error is one entry of a response's errors.
+type Error struct {
+	Message   string     `json:"message"`
+	Locations []Location `json:"locations,omitempty"`
+	Path      []any      `json:"path,omitempty"`
+}
+
+// Request is a query as clients post it.
+type Request struct {
+	Query         string         `json:"query"`
+	OperationName string         `json:"operationName"`
+	Variables     map[string]any `json:"variables"`
+}
+
+// Response is the result of a request. Data is absent when the query did
+// not parse or validate, and holds null for every field that failed.
+type Response struct {
+	Data   any     `json:"data,omitempty"`
+	Errors []Error `json:"errors,omitempty"`
+}
+
+// Args are the arguments of a field, with variables substituted.
+type Args map[string]any
+
+// String returns the string argument name, or "" when it is absent.
+func (a Args) String(name string) (string, error) {
+	switch v := a[name].(type) {
+	case nil:
+		return "", nil
+	case string:
+		return v, nil
+	}
+	return "", fmt.Errorf("argument %q must be a string", name)
+}
+
+// Int returns the integer argument name, or def when it is absent.
+func (a Args) Int(name string, def int) (int, error) {
+	switch v := a[name].(type) {
+	case nil:
+		return def, nil
+	case int64:
+		return int(v), nil
+	case float64: // variables decoded from JSON
+		if v == math.Trunc(v) && math.Abs(v) <= math.MaxInt32 {
+			return int(v), nil
+		}
+	}
+	return 0, fmt.Errorf("argument %q must be an integer", name)
+}
+
+// Selection names the subfields a query selects on a field's result, so a
+// resolver can skip loading what nobody asked for.
+type Selection []string
+
+// Has reports whether name is selected.
+func (s Selection) Has(name string) bool {
+	for _, n := range s {
+		if n == name {
+			return true
+		}
+	}
+	return false
+}
+
+// Resolver computes a field of the query root.
+type Resolver func(ctx context.Context, args Args, sel Selection) (any, error)
+
+// Field is a field of the query root and the arguments it accepts.
+type Field struct {
+	Args    []string
+	Resolve Resolver
+}
+
+// Schema is the query root. Schemas are typically built per request, with
+// resolvers closing over the caller's identity.
+type Schema struct {
+	Query map[string]Field
+}
+
+// Execute runs the query of req.
+func (s *Schema) Execute(ctx context.Context, req Request) *Response {
+	doc, err := parse(req.Query)
+	if err != nil {
+		return errorResponse(err, nil)
+	}
+	op, err := doc.operation(req.OperationName)
+	if err != nil {
+		return errorResponse(err, nil)
+	}
+	if op.kind != "query" {
+		return errorResponse(fmt.Errorf("%s operations are not supported", op.kind), &op.loc)
+	}
+	e := &executor{doc: doc}
+	if e.vars, err = variables(op, req.Variables); err != nil {
+		return errorResponse(err, &op.loc)
+	}
+	fields, err := e.collect(op.selection, nil)
+	if err != nil {
+		return errorResponse(err, nil)
+	}
+
+	// Validate the root before anything runs, so a typo costs no queries.
+	type call struct {
+		sel  selection
+		def  Field
+		args Args
+	}
+	var calls []call
+	for _, f := range fields {
+		if f.name == "__typename" {
+			calls = append(calls, call{sel: f})
+			continue
+		}
+		def, ok := s.Query[f.name]
+		if !ok {
+			return errorResponse(fmt.Errorf("unknown field %q on Query", f.name), &f.loc)
+		}
+		args, err := e.args(f.args, def.Args)
+		if err != nil {
+			return errorResponse(fmt.Errorf("field %q: %w", f.name, err), &f.loc)
+		}
+		calls = append(calls, call{sel: f, def: def, args: args})
+	}
+	if len(calls) > maxRootFields {
+		return errorResponse(fmt.Errorf("query selects %d root fields, more than %d", len(calls), maxRootFields), nil)
+	}
+
+	data := &object{}
+	for _, c := range calls {
+		path := []any{c.sel.alias}
+		if c.def.Resolve == nil {
+			data.set(c.sel.alias, "Query")
+			continue
+		}
+		sub, err := e.collect(c.sel.selection, nil)
+		if err != nil {
+			e.fail(err, c.sel.loc, path)
+			data.set(c.sel.alias, nil)
+			continue
+		}
+		names := make(Selection, len(sub))
+		for i, f := range sub {
+			names[i] = f.name
+		}
+		v, err := c.def.Resolve(ctx, c.args, names)
+		if err == nil {
+			v, err = e.complete(reflect.ValueOf(v), c.sel.selection, path)
+		}
+		if err != nil {
+			e.fail(err, c.sel.loc, path)
+			v = nil
+		}
+		data.set(c.sel.alias, v)
+	}
+	if e.selections > maxSelections {
+		return errorResponse(errTooManySelections, nil)
+	}
+	return &Response{Data: data, Errors: e.errors}
+}
+
+func errorResponse(err error, loc *Location) *Response {
+	e := Error{Message: err.Error()}
+	if loc != nil {
+		e.Locations = []Location{*loc}
+	}
+	return &Response{Errors: []Error{e}}
+}
+
+// operation picks the operation to run: the one named, or the only one.
+func (d *document) operation(name string) (*operation, error) {
+	if name == "" {
+		if len(d.operations) > 1 {
+			return nil, fmt.Errorf("operationName is required when the document has several operations")
+		}
+		return d.operations[0], nil
+	}
+	for _, op := range d.operations {
+		if op.name == name {
+			return op, nil
+		}
+	}
+	return nil, fmt.Errorf("no operation named %q", name)
+}
+
+// variables returns the values of op's variables: those given, else the
+// defaults, else null.
+func variables(op *operation, given map[string]any) (map[string]any, error) {
+	vars := make(map[string]any, len(op.variables))
+	for _, d := range op.variables {
+		v, ok := given[d.name]
+		if !ok && d.def != nil {
+			var err error
+			if v, err = (&executor{}).literal(d.def); err != nil {
+				return nil, err
+			}
+		}
+		vars[d.name] = v
+	}
+	return vars, nil
+}
+
+// ─── Execution ────────────────────────────────────────────────────────────
+
+type executor struct {
+	doc    *document
+	vars   map[string]any
+	errors []Error
+
+	// collected caches what collect made of each selection set, and
+	// selections counts the fields it expanded.
+	collected  map[selectionSet][]selection
+	selections int
+}
+
+var errTooManySelections = fmt.Errorf("query expands to more than %d fields", maxSelections)
+
+// selectionSet identifies a parsed or merged selection set: every item of
+// a list shares the set of its field, and every spread of a fragment the
+// fragment's.
+type selectionSet struct {
+	first *selection
+	n     int
+}
+
+func (e *executor) fail(err error, loc Location, path []any) {
+	e.errors = append(e.errors, Error{
+		Message:   err.Error(),
+		Locations: []Location{loc},
+		Path:      append([]any(nil), path...),
+	})
+}
+
+// collect flattens sel to its fields, expanding fragments and dropping what
+// @skip and @include leave out. Fields sharing a response key are merged.
+// visiting holds the fragments being expanded, to refuse cycles. Results
+// are cached per selection set, and the fields expanded are counted
+// against maxSelections.
+func (e *executor) collect(sel []selection, visiting []string) ([]selection, error) {
+	if len(sel) == 0 {
+		return nil, nil
+	}
+	key := selectionSet{&sel[0], len(sel)}
+	if out, ok := e.collected[key]; ok {
+		return out, nil
+	}
+	var out []selection
+	index := make(map[string]int)
+	owned := make(map[int]bool) // merged selections copied, so appends are cheap
+	for _, s := range sel {
+		ok, err := e.included(s.directives)
+		if err != nil {
+			return nil, err
+		}
+		if !ok {
+			continue
+		}
+		var fields []selection
+		switch {
+		case s.spread != "":
+			f, ok := e.doc.fragments[s.spread]
+			if !ok {
+				return nil, fmt.Errorf("unknown fragment %q", s.spread)
+			}
+			for _, name := range visiting {
+				if name == s.spread {
+					return nil, fmt.Errorf("fragment %q spreads itself", s.spread)
+				}
+			}
+			if fields, err = e.collect(f.selection, append(visiting, s.spread)); err != nil {
+				return nil, err
+			}
+		case s.inline:
+			if fields, err = e.collect(s.selection, visiting); err != nil {
+				return nil, err
+			}
+		default:
+			fields = []selection{s}
+		}
+		for _, f := range fields {
+			if e.selections++; e.selections > maxSelections {
+				return nil, errTooManySelections
+			}
+			if i, dup := index[f.alias]; dup {
+				if out[i].name != f.name {
+					return nil, fmt.Errorf("%q selects both %q and %q", f.alias, out[i].name, f.name)
+				}
+				if !owned[i] {
+					out[i].selection = append([]selection(nil), out[i].selection...)
+					owned[i] = true
+				}
+				out[i].selection = append(out[i].selection, f.selection...)
+				continue
+			}
+			index[f.alias] = len(out)
+			out = append(out, f)
+		}
+	}
+	if e.collected == nil {
+		e.collected = make(map[selectionSet][]selection)
+	}
+	e.collected[key] = out
+	return out, nil
+}
+
+// included evaluates @skip and @include.
+func (e *executor) included(dirs []directive) (bool, error) {
+	for _, d := range dirs {
+		if d.name != "skip" && d.name != "include" {
+			return false, fmt.Errorf("unknown directive @%s", d.name)
+		}
+		args, err := e.args(d.args, []string{"if"})
+		if err != nil {
+			return false, fmt.Errorf("@%s: %w", d.name, err)
+		}
+		cond, ok := args["if"].(bool)
+		if !ok {
+			return false, fmt.Errorf("@%s needs a boolean if", d.name)
+		}
+		if cond == (d.name == "skip") {
+			return false, nil
+		}
+	}
+	return true, nil
+}
+
+// args evaluates args, refusing those not in allowed.
+func (e *executor) args(args []argument, allowed []string) (Args, error) {
+	out := make(Args, len(args))
+	for _, a := range args {
+		known := false
+		for _, n := range allowed {
+			known = known || n == a.name
+		}
+		if !known {
+			return nil, fmt.Errorf("unknown argument %q", a.name)
+		}
+		v, err := e.literal(a.val)
+		if err != nil {
+			return nil, err
+		}
+		out[a.name] = v
+	}
+	return out, nil
+}
+
+// literal converts a parsed value to the Go value resolvers see: enums
+// become strings, lists []any and objects map[string]any.
+func (e *executor) literal(v value) (any, error) {
+	switch v := v.(type) {
+	case variable:
+		val, ok := e.vars[string(v)]
+		if !ok {
+			return nil, fmt.Errorf("variable $%s is not defined", v)
+		}
+		return val, nil
+	case enum:
+		return string(v), nil
+	case []value:
+		out := make([]any, len(v))
+		for i, item := range v {
+			var err error
+			if out[i], err = e.literal(item); err != nil {
+				return nil, err
+			}
+		}
+		return out, nil
+	case []argument:
+		out := make(map[string]any, len(v))
+		for _, a := range v {
+			val, err := e.literal(a.val)
+			if err != nil {
+				return nil, err
+			}
+			out[a.name] = val
+		}
+		return out, nil
+	}
+	return v, nil
+}
+
+var (
+	jsonMarshaler = reflect.TypeOf((*json.Marshaler)(nil)).Elem()
+	textMarshaler = reflect.TypeOf((*encoding.TextMarshaler)(nil)).Elem()
+)
+
+// complete shapes v after sel: objects keep the selected fields only, lists
+// are completed item by item and leaves are returned as they are.
+func (e *executor) complete(v reflect.Value, sel []selection, path []any) (any, error) {
+	if e.selections > maxSelections {
+		return nil, errTooManySelections
+	}
+	for v.IsValid() && (v.Kind() == reflect.Pointer || v.Kind() == reflect.Interface) {
+		if leaf, ok := marshaler(v); ok {
+			return leafOf(leaf, sel)
+		}
+		if v.IsNil() {
+			return nil, nil
+		}
+		v = v.Elem()
+	}
+	if !v.IsValid() {
+		return nil, nil
+	}
+	if leaf, ok := marshaler(v); ok {
+		return leafOf(leaf, sel)
+	}
+
+	switch v.Kind() {
+	case reflect.Slice, reflect.Array:
+		if v.Type().Elem().Kind() == reflect.Uint8 {
+			return leafOf(v.Interface(), sel)
+		}
+		if v.Kind() == reflect.Slice && v.IsNil() {
+			return nil, nil
+		}
+		out := make([]any, v.Len())
+		for i := range out {
+			item, err := e.complete(v.Index(i), sel, append(path[:len(path):len(path)], i))
+			if err != nil {
+				return nil, err
+			}
+			out[i] = item
+		}
+		return out, nil
+
+	case reflect.Struct:
+		if len(sel) == 0 {
+			return nil, fmt.Errorf("%s needs a selection of subfields", typeName(v.Type()))
+		}
+		return e.object(sel, path, typeName(v.Type()), func(name string) (reflect.Value, bool) {
+			index, ok := jsonFields(v.Type())[name]
+			if !ok {
+				return reflect.Value{}, false
+			}
+			f, err := v.FieldByIndexErr(index)
+			if err != nil { // through a nil embedded pointer
+				return reflect.Value{}, true
+			}
+			return f, true
+		})
+
+	case reflect.Map:
+		if len(sel) == 0 || v.Type().Key().Kind() != reflect.String {
+			return leafOf(v.Interface(), sel)
+		}
+		return e.object(sel, path, "Object", func(name string) (reflect.Value, bool) {
+			return v.MapIndex(reflect.ValueOf(name).Convert(v.Type().Key())), true
+		})
+	}
+	return leafOf(v.Interface(), sel)
+}
+
+// object completes the fields sel selects, which lookup finds on the value;
+// a field lookup does not know is an error, one that is known but invalid
+// is null.
+func (e *executor) object(sel []selection, path []any, typ string, lookup func(string) (reflect.Value, bool)) (any, error) {
+	fields, err := e.collect(sel, nil)
+	if err != nil {
+		return nil, err
+	}
+	out := &object{}
+	for _, f := range fields {
+		if f.name == "__typename" {
+			out.set(f.alias, typ)
+			continue
+		}
+		if len(f.args) > 0 {
+			return nil, fmt.Errorf("field %q of %s takes no arguments", f.name, typ)
+		}
+		fv, ok := lookup(f.name)
+		if !ok {
+			return nil, fmt.Errorf("unknown field %q on %s", f.name, typ)
+		}
+		p := append(path[:len(path):len(path)], f.alias)
+		val, err := e.complete(fv, f.selection, p)
+		if err != nil {
+			e.fail(err, f.loc, p)
+			val = nil
+		}
+		out.set(f.alias, val)
+	}
+	return out, nil
+}
+
+// marshaler returns the value to encode when v marshals itself.
+func marshaler(v reflect.Value) (any, bool) {
+	t := v.Type()
+	if t.Kind() == reflect.Interface {
+		return nil, false
+	}
+	if t.Implements(jsonMarshaler) || t.Implements(textMarshaler) {
+		if t.Kind() == reflect.Pointer && v.IsNil() {
+			return nil, true
+		}
+		return v.Interface(), true
+	}
+	if t.Kind() != reflect.Pointer {
+		pt := reflect.PointerTo(t)
+		if pt.Implements(jsonMarshaler) || pt.Implements(textMarshaler) {
+			p := reflect.New(t)
+			p.Elem().Set(v)
+			return p.Interface(), true
+		}
+	}
+	return nil, false
+}
+
+func leafOf(v any, sel []selection) (any, error) {
+	if len(sel) > 0 {
+		return nil, fmt.Errorf("field %q selected on a scalar", sel[0].name)
+	}
+	return v, nil
+}
+
+func typeName(t reflect.Type) string {
+	if t.Name() == "" {
+		return "Object"
+	}
+	return t.Name()
+}
+
+var fieldCache sync.Map // reflect.Type → map[string][]int
+
+// jsonFields maps the JSON names of t's fields, including those promoted
+// from embedded structs, to their index.
+func jsonFields(t reflect.Type) map[string][]int {
+	if m, ok := fieldCache.Load(t); ok {
+		return m.(map[string][]int)
+	}
+	m := make(map[string][]int)
+	var walk func(t reflect.Type, index []int)
+	walk = func(t reflect.Type, index []int) {
+		for i := 0; i < t.NumField(); i++ {
+			f := t.Field(i)
+			tag := f.Tag.Get("json")
+			if tag == "-" {
+				continue
+			}
+			name, _, _ := strings.Cut(tag, ",")
+			idx := append(index[:len(index):len(index)], i)
+			ft := f.Type
+			if ft.Kind() == reflect.Pointer {
+				ft = ft.Elem()
+			}
+			if f.Anonymous && name == "" && ft.Kind() == reflect.Struct {
+				walk(ft, idx)
+				continue
+			}
+			if !f.IsExported() {
+				continue
+			}
+			if name == "" {
+				name = f.Name
+			}
+			if _, shadowed := m[name]; !shadowed || len(idx) < len(m[name]) {
+				m[name] = idx
+			}
+		}
+	}
+	walk(t, nil)
+	fieldCache.Store(t, m)
+	return m
+}
+
+// object is a JSON object that keeps its keys in selection order.
+type object struct {
+	keys []string
+	vals []any
+}
+
+func (o *object) set(key string, v any) {
+	o.keys = append(o.keys, key)
+	o.vals = append(o.vals, v)
+}
+
+func (o *object) MarshalJSON() ([]byte, error) {
+	var buf bytes.Buffer
+	buf.WriteByte('{')
+	for i, k := range o.keys {
+		if i > 0 {
+			buf.WriteByte(',')
+		}
+		key, _ := json.Marshal(k)
+		buf.Write(key)
+		buf.WriteByte(':')
+		val, err := json.Marshal(o.vals[i])
+		if err != nil {
+			return nil, err
+		}
+		buf.Write(val)
+	}
+	buf.WriteByte('}')
+	return buf.Bytes(), nil
+}
@@ -0,0 +1,583 @@
+package graphql
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+// document is a parsed request: its operations and named fragments.
+type document struct {
+	operations []*operation
+	fragments  map[string]*fragment
+}
+
+type operation struct {
+	kind      string // query | mutation | subscription
+	name      string
+	variables []variableDef
+	selection []selection
+	loc       Location
+}
+
+type variableDef struct {
+	name string
+	def  value // nil when there is no default
+}
+
+type fragment struct {
+	name      string
+	selection []selection
+}
+
+// selection is a field, a fragment spread (spread set) or an inline
+// fragment (inline set).
+type selection struct {
+	alias, name string
+	args        []argument
+	directives  []directive
+	selection   []selection
+	spread      string
+	inline      bool
+	loc         Location
+}
+
+type argument struct {
+	name string
+	val  value
+}
+
+type directive struct {
+	name string
+	args []argument
+}
+
+// value is a literal: nil, bool, string, int64, float64, enum, variable,
+// []value or []argument for an object.
+type value any
+
+type enum string
+
+type variable string
+
+// ─── Lexer ────────────────────────────────────────────────────────────────
+
+type tokenKind int
+
+const (
+	tokEOF tokenKind = iota
+	tokPunct
+	tokName
+	tokInt
+	tokFloat
+	tokString
+)
+
+type token struct {
+	kind tokenKind
+	text string
+	loc  Location
+}
+
+type lexer struct {
+	src       string
+	pos       int
+	line, col int
+}
+
+func (l *lexer) next() (token, error) {
+	l.skipIgnored()
+	loc := Location{Line: l.line, Column: l.col}
+	if l.pos >= len(l.src) {
+		return token{kind: tokEOF, loc: loc}, nil
+	}
+	c := l.src[l.pos]
+	switch {
+	case strings.IndexByte("!$&():=@[]{}|", c) >= 0:
+		l.advance(1)
+		return token{kind: tokPunct, text: string(c), loc: loc}, nil
+	case strings.HasPrefix(l.src[l.pos:], "..."):
+		l.advance(3)
+		return token{kind: tokPunct, text: "...", loc: loc}, nil
+	case c == '_' || isLetter(c):
+		start := l.pos
+		for l.pos < len(l.src) && (l.src[l.pos] == '_' || isLetter(l.src[l.pos]) || isDigit(l.src[l.pos])) {
+			l.advance(1)
+		}
+		return token{kind: tokName, text: l.src[start:l.pos], loc: loc}, nil
+	case c == '-' || isDigit(c):
+		return l.number(loc)
+	case c == '"':
+		s, err := l.str()
+		if err != nil {
+			return token{}, fmt.Errorf("%d:%d: %w", loc.Line, loc.Column, err)
+		}
+		return token{kind: tokString, text: s, loc: loc}, nil
+	}
+	r, _ := utf8.DecodeRuneInString(l.src[l.pos:])
+	return token{}, fmt.Errorf("%d:%d: unexpected character %q", loc.Line, loc.Column, r)
+}
+
+// skipIgnored skips whitespace, commas and comments.
+func (l *lexer) skipIgnored() {
+	for l.pos < len(l.src) {
+		switch c := l.src[l.pos]; c {
+		case ' ', '\t', ',', '\r':
+			l.advance(1)
+		case '\n':
+			l.pos++
+			l.line, l.col = l.line+1, 1
+		case '#':
+			for l.pos < len(l.src) && l.src[l.pos] != '\n' {
+				l.advance(1)
+			}
+		default:
+			return
+		}
+	}
+}
+
+func (l *lexer) advance(n int) {
+	l.pos += n
+	l.col += n
+}
+
+func (l *lexer) number(loc Location) (token, error) {
+	start := l.pos
+	if l.src[l.pos] == '-' {
+		l.advance(1)
+	}
+	kind := tokInt
+	for l.pos < len(l.src) {
+		c := l.src[l.pos]
+		switch {
+		case isDigit(c):
+		case c == '.' || c == 'e' || c == 'E':
+			kind = tokFloat
+		case (c == '+' || c == '-') && kind == tokFloat:
+		default:
+			return token{kind: kind, text: l.src[start:l.pos], loc: loc}, nil
+		}
+		l.advance(1)
+	}
+	return token{kind: kind, text: l.src[start:l.pos], loc: loc}, nil
+}
+
+// str reads a quoted string, or a """block string""" taken verbatim.
+func (l *lexer) str() (string, error) {
+	if strings.HasPrefix(l.src[l.pos:], `"""`) {
+		end := strings.Index(l.src[l.pos+3:], `"""`)
+		if end < 0 {
+			return "", fmt.Errorf("unterminated block string")
+		}
+		s := l.src[l.pos+3 : l.pos+3+end]
+		for _, c := range l.src[l.pos : l.pos+end+6] {
+			if c == '\n' {
+				l.line, l.col = l.line+1, 0
+			}
+			l.col++
+		}
+		l.pos += end + 6
+		return s, nil
+	}
+	for i := l.pos + 1; i < len(l.src); i++ {
+		switch l.src[i] {
+		case '\\':
+			i++
+		case '\n':
+			return "", fmt.Errorf("unterminated string")
+		case '"':
+			s, err := strconv.Unquote(strings.ReplaceAll(l.src[l.pos:i+1], `\/`, `/`))
+			if err != nil {
+				return "", fmt.Errorf("invalid string %s", l.src[l.pos:i+1])
+			}
+			l.advance(i + 1 - l.pos)
+			return s, nil
+		}
+	}
+	return "", fmt.Errorf("unterminated string")
+}
+
+func isLetter(c byte) bool { return c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' }
+func isDigit(c byte) bool  { return c >= '0' && c <= '9' }
+
+// ─── Parser ───────────────────────────────────────────────────────────────
+
+type parser struct {
+	lex   lexer
+	tok   token
+	err   error // the first lexer error; tok is then EOF
+	depth int
+}
+
+// maxDepth bounds the nesting of selections and values.
+const maxDepth = 32
+
+func parse(src string) (*document, error) {
+	p := &parser{lex: lexer{src: src, line: 1, col: 1}}
+	if err := p.advance(); err != nil {
+		return nil, err
+	}
+	doc := &document{fragments: make(map[string]*fragment)}
+	for p.tok.kind != tokEOF {
+		switch {
+		case p.peek("{"):
+			sel, err := p.selectionSet()
+			if err != nil {
+				return nil, err
+			}
+			doc.operations = append(doc.operations, &operation{kind: "query", selection: sel, loc: sel[0].loc})
+		case p.tok.kind == tokName && p.tok.text == "fragment":
+			f, err := p.fragment()
+			if err != nil {
+				return nil, err
+			}
+			if _, dup := doc.fragments[f.name]; dup {
+				return nil, fmt.Errorf("fragment %q is defined more than once", f.name)
+			}
+			doc.fragments[f.name] = f
+		case p.tok.kind == tokName:
+			op, err := p.operation()
+			if err != nil {
+				return nil, err
+			}
+			doc.operations = append(doc.operations, op)
+		default:
+			return nil, p.unexpected()
+		}
+	}
+	if p.err != nil {
+		return nil, p.err
+	}
+	if len(doc.operations) == 0 {
+		return nil, fmt.Errorf("document has no operation")
+	}
+	return doc, nil
+}
+
+func (p *parser) advance() error {
+	if p.err != nil {
+		return p.err
+	}
+	t, err := p.lex.next()
+	if err != nil {
+		p.err, t = err, token{kind: tokEOF, loc: p.tok.loc}
+	}
+	p.tok = t
+	return err
+}
+
+func (p *parser) peek(punct string) bool {
+	return p.tok.kind == tokPunct && p.tok.text == punct
+}
+
+func (p *parser) expect(punct string) error {
+	if !p.peek(punct) {
+		return p.unexpected()
+	}
+	return p.advance()
+}
+
+func (p *parser) name() (string, error) {
+	if p.tok.kind != tokName {
+		return "", p.unexpected()
+	}
+	n := p.tok.text
+	return n, p.advance()
+}
+
+func (p *parser) unexpected() error {
+	if p.err != nil {
+		return p.err
+	}
+	what := strconv.Quote(p.tok.text)
+	if p.tok.kind == tokEOF {
+		what = "end of document"
+	}
+	return fmt.Errorf("%d:%d: unexpected %s", p.tok.loc.Line, p.tok.loc.Column, what)
+}
+
+func (p *parser) operation() (*operation, error) {
+	op := &operation{loc: p.tok.loc}
+	var err error
+	if op.kind, err = p.name(); err != nil {
+		return nil, err
+	}
+	switch op.kind {
+	case "query", "mutation", "subscription":
+	default:
+		return nil, fmt.Errorf("%d:%d: unknown operation type %q", op.loc.Line, op.loc.Column, op.kind)
+	}
+	if p.tok.kind == tokName {
+		op.name, _ = p.name()
+	}
+	if p.peek("(") {
+		if op.variables, err = p.variableDefs(); err != nil {
+			return nil, err
+		}
+	}
+	if _, err := p.directives(); err != nil {
+		return nil, err
+	}
+	if op.selection, err = p.selectionSet(); err != nil {
+		return nil, err
+	}
+	return op, nil
+}
+
+func (p *parser) variableDefs() ([]variableDef, error) {
+	var defs []variableDef
+	if err := p.expect("("); err != nil {
+		return nil, err
+	}
+	for !p.peek(")") {
+		if err := p.expect("$"); err != nil {
+			return nil, err
+		}
+		name, err := p.name()
+		if err != nil {
+			return nil, err
+		}
+		if err := p.expect(":"); err != nil {
+			return nil, err
+		}
+		if err := p.typeRef(); err != nil {
+			return nil, err
+		}
+		d := variableDef{name: name}
+		if p.peek("=") {
+			p.advance()
+			if d.def, err = p.value(true); err != nil {
+				return nil, err
+			}
+		}
+		defs = append(defs, d)
+	}
+	return defs, p.advance()
+}
+
+// typeRef skips a variable type; values are not checked against it.
+func (p *parser) typeRef() error {
+	if p.peek("[") {
+		p.advance()
+		if err := p.typeRef(); err != nil {
+			return err
+		}
+		if err := p.expect("]"); err != nil {
+			return err
+		}
+	} else if _, err := p.name(); err != nil {
+		return err
+	}
+	if p.peek("!") {
+		return p.advance()
+	}
+	return nil
+}
+
+func (p *parser) fragment() (*fragment, error) {
+	p.advance() // fragment
+	name, err := p.name()
+	if err != nil {
+		return nil, err
+	}
+	if p.tok.kind != tokName || p.tok.text != "on" {
+		return nil, fmt.Errorf("fragment %q needs a type condition", name)
+	}
+	p.advance()
+	if _, err := p.name(); err != nil {
+		return nil, err
+	}
+	if _, err := p.directives(); err != nil {
+		return nil, err
+	}
+	sel, err := p.selectionSet()
+	if err != nil {
+		return nil, err
+	}
+	return &fragment{name: name, selection: sel}, nil
+}
+
+func (p *parser) selectionSet() ([]selection, error) {
+	if p.depth++; p.depth > maxDepth {
+		return nil, fmt.Errorf("%d:%d: selections nest deeper than %d", p.tok.loc.Line, p.tok.loc.Column, maxDepth)
+	}
+	defer func() { p.depth-- }()
+
+	if err := p.expect("{"); err != nil {
+		return nil, err
+	}
+	var out []selection
+	for !p.peek("}") {
+		s, err := p.selection()
+		if err != nil {
+			return nil, err
+		}
+		out = append(out, s)
+	}
+	if len(out) == 0 {
+		return nil, p.unexpected()
+	}
+	return out, p.advance()
+}
+
+func (p *parser) selection() (selection, error) {
+	s := selection{loc: p.tok.loc}
+	var err error
+	if p.peek("...") {
+		p.advance()
+		switch {
+		case p.tok.kind == tokName && p.tok.text == "on":
+			p.advance()
+			if _, err := p.name(); err != nil {
+				return s, err
+			}
+			s.inline = true
+		case p.tok.kind == tokName:
+			s.spread, _ = p.name()
+		default:
+			s.inline = true
+		}
+		if s.directives, err = p.directives(); err != nil {
+			return s, err
+		}
+		if s.inline {
+			s.selection, err = p.selectionSet()
+		}
+		return s, err
+	}
+
+	if s.name, err = p.name(); err != nil {
+		return s, err
+	}
+	s.alias = s.name
+	if p.peek(":") {
+		p.advance()
+		if s.name, err = p.name(); err != nil {
+			return s, err
+		}
+	}
+	if p.peek("(") {
+		if s.args, err = p.arguments(); err != nil {
+			return s, err
+		}
+	}
+	if s.directives, err = p.directives(); err != nil {
+		return s, err
+	}
+	if p.peek("{") {
+		s.selection, err = p.selectionSet()
+	}
+	return s, err
+}
+
+func (p *parser) arguments() ([]argument, error) {
+	p.advance() // (
+	var args []argument
+	for !p.peek(")") {
+		name, err := p.name()
+		if err != nil {
+			return nil, err
+		}
+		if err := p.expect(":"); err != nil {
+			return nil, err
+		}
+		v, err := p.value(false)
+		if err != nil {
+			return nil, err
+		}
+		args = append(args, argument{name: name, val: v})
+	}
+	return args, p.advance()
+}
+
+func (p *parser) directives() ([]directive, error) {
+	var out []directive
+	for p.peek("@") {
+		p.advance()
+		name, err := p.name()
+		if err != nil {
+			return nil, err
+		}
+		d := directive{name: name}
+		if p.peek("(") {
+			if d.args, err = p.arguments(); err != nil {
+				return nil, err
+			}
+		}
+		out = append(out, d)
+	}
+	return out, nil
+}
+
+// value parses a literal; const forbids variables, as in defaults.
+func (p *parser) value(isConst bool) (value, error) {
+	if p.depth++; p.depth > maxDepth {
+		return nil, fmt.Errorf("%d:%d: values nest deeper than %d", p.tok.loc.Line, p.tok.loc.Column, maxDepth)
+	}
+	defer func() { p.depth-- }()
+
+	t := p.tok
+	switch {
+	case t.kind == tokPunct && t.text == "$" && !isConst:
+		p.advance()
+		name, err := p.name()
+		return variable(name), err
+	case t.kind == tokPunct && t.text == "[":
+		p.advance()
+		list := []value{}
+		for !p.peek("]") {
+			v, err := p.value(isConst)
+			if err != nil {
+				return nil, err
+			}
+			list = append(list, v)
+		}
+		return list, p.advance()
+	case t.kind == tokPunct && t.text == "{":
+		p.advance()
+		obj := []argument{}
+		for !p.peek("}") {
+			name, err := p.name()
+			if err != nil {
+				return nil, err
+			}
+			if err := p.expect(":"); err != nil {
+				return nil, err
+			}
+			v, err := p.value(isConst)
+			if err != nil {
+				return nil, err
+			}
+			obj = append(obj, argument{name: name, val: v})
+		}
+		return obj, p.advance()
+	case t.kind == tokInt:
+		n, err := strconv.ParseInt(t.text, 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("%d:%d: invalid int %s", t.loc.Line, t.loc.Column, t.text)
+		}
+		return n, p.advance()
+	case t.kind == tokFloat:
+		f, err := strconv.ParseFloat(t.text, 64)
+		if err != nil {
+			return nil, fmt.Errorf("%d:%d: invalid float %s", t.loc.Line, t.loc.Column, t.text)
+		}
+		return f, p.advance()
+	case t.kind == tokString:
+		return t.text, p.advance()
+	case t.kind == tokName:
+		p.advance()
+		switch t.text {
+		case "true":
+			return true, nil
+		case "false":
+			return false, nil
+		case "null":
+			return nil, nil
+		}
+		return enum(t.text), nil
+	}
+	return nil, p.unexpected()
+}
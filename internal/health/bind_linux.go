@@ -0,0 +1,21 @@
+//go:build linux
+
+package health
+
+import "syscall"
+
+// BindToDevice pins a probe socket to an interface so a WAN link is checked
+// or measured over that link even when the default route points elsewhere.
+// It is the Control of a net.Dialer.
+func BindToDevice(iface string) func(network, address string, c syscall.RawConn) error {
+	return func(network, address string, c syscall.RawConn) error {
+		var serr error
+		err := c.Control(func(fd uintptr) {
+			serr = syscall.SetsockoptString(int(fd), syscall.SOL_SOCKET, syscall.SO_BINDTODEVICE, iface)
+		})
+		if err != nil {
+			return err
+		}
+		return serr
+	}
+}
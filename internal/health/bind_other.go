@@ -0,0 +1,10 @@
+//go:build !linux
+
+package health
+
+import "syscall"
+
+// BindToDevice is a no-op where SO_BINDTODEVICE is unavailable.
+func BindToDevice(string) func(network, address string, c syscall.RawConn) error {
+	return nil
+}
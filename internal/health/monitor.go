@@ -0,0 +1,408 @@
+// Package health probes monitored targets (ICMP, TCP, HTTP, DNS) and tracks their
+// up/down state with rise/fall hysteresis so other subsystems can react to
+// link and server failures.
+package health
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"net/http"
+	"os/exec"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"go.uber.org/zap"
+
+	"github.com/aegisx/aegisx/internal/metrics"
+	"github.com/aegisx/aegisx/internal/policy"
+)
+
+const (
+	defaultInterval = 5 * time.Second
+	defaultTimeout  = 2 * time.Second
+	defaultRise     = 2
+	defaultFall     = 3
+
+	// windowSize is how many recent probes latency and loss are averaged
+	// over.
+	windowSize = 20
+)
+
+// TargetStatus is the externally visible state of one target.
+type TargetStatus struct {
+	Name        string    `json:"name"`
+	Type        string    `json:"type"`
+	Address     string    `json:"address"`
+	Up          bool      `json:"up"`
+	Known       bool      `json:"known"` // false until rise/fall has been reached once
+	LastCheck   time.Time `json:"lastCheck,omitempty"`
+	LastChange  time.Time `json:"lastChange,omitempty"`
+	LastError   string    `json:"lastError,omitempty"`
+	LatencyMs   float64   `json:"latencyMs"`
+	Consecutive int       `json:"consecutive"` // successes (up) or failures (down) in a row
+
+	// Averaged over the last windowSize probes.
+	AvgLatencyMs float64 `json:"avgLatencyMs"` // of the successful ones
+	LossPct      float64 `json:"lossPct"`
+}
+
+// Monitor runs one prober goroutine per target.
+type Monitor struct {
+	log *zap.Logger
+
+	mu       sync.Mutex
+	probers  map[string]*prober
+	onChange []func(name string, up bool)
+}
+
+func NewMonitor(log *zap.Logger) *Monitor {
+	return &Monitor{log: log, probers: make(map[string]*prober)}
+}
+
+// OnChange registers a callback invoked (in its own goroutine) whenever a
+// target flips between up and down.
+func (m *Monitor) OnChange(fn func(name string, up bool)) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.onChange = append(m.onChange, fn)
+}
+
+// SetTargets reconciles running probers with the given targets. Unchanged
+// targets keep their state; changed ones restart from unknown.
+func (m *Monitor) SetTargets(targets []policy.HealthTarget) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	wanted := make(map[string]policy.HealthTarget, len(targets))
+	for _, t := range targets {
+		wanted[t.Name] = t
+	}
+	for name, p := range m.probers {
+		if t, ok := wanted[name]; !ok || t != p.target {
+			p.cancel()
+			delete(m.probers, name)
+			metrics.HealthTargetUp.DeleteLabelValues(name)
+			metrics.HealthProbeDuration.DeleteLabelValues(name)
+			metrics.HealthProbeFailuresTotal.DeleteLabelValues(name)
+		}
+	}
+	for name, t := range wanted {
+		if _, ok := m.probers[name]; ok {
+			continue
+		}
+		ctx, cancel := context.WithCancel(context.Background())
+		p := &prober{target: t, cancel: cancel, monitor: m}
+		p.status = TargetStatus{Name: t.Name, Type: t.Type, Address: t.Address}
+		m.probers[name] = p
+		go p.run(ctx)
+	}
+}
+
+// State reports whether a target is up. Unknown targets and targets that
+// have not settled yet report known=false.
+func (m *Monitor) State(name string) (up, known bool) {
+	st, ok := m.Status(name)
+	if !ok {
+		return false, false
+	}
+	return st.Up, st.Known
+}
+
+// Status returns the status of one target.
+func (m *Monitor) Status(name string) (TargetStatus, bool) {
+	m.mu.Lock()
+	p, ok := m.probers[name]
+	m.mu.Unlock()
+	if !ok {
+		return TargetStatus{}, false
+	}
+	return p.snapshot(), true
+}
+
+// Snapshot returns the status of every target, sorted by name.
+func (m *Monitor) Snapshot() []TargetStatus {
+	m.mu.Lock()
+	probers := make([]*prober, 0, len(m.probers))
+	for _, p := range m.probers {
+		probers = append(probers, p)
+	}
+	m.mu.Unlock()
+
+	out := make([]TargetStatus, len(probers))
+	for i, p := range probers {
+		out[i] = p.snapshot()
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].Name < out[j].Name })
+	return out
+}
+
+// Stop cancels all probers.
+func (m *Monitor) Stop() {
+	m.SetTargets(nil)
+}
+
+func (m *Monitor) notify(name string, up bool) {
+	m.mu.Lock()
+	fns := append([]func(string, bool){}, m.onChange...)
+	m.mu.Unlock()
+	for _, fn := range fns {
+		go fn(name, up)
+	}
+}
+
+// ─── Prober ───────────────────────────────────────────────────────────────
+
+type prober struct {
+	target  policy.HealthTarget
+	cancel  context.CancelFunc
+	monitor *Monitor
+
+	mu     sync.Mutex
+	status TargetStatus
+	streak int // >0 consecutive successes, <0 consecutive failures
+
+	window []time.Duration // recent probes, oldest first; <0 for a failure
+}
+
+func (p *prober) run(ctx context.Context) {
+	interval := parseDuration(p.target.Interval, defaultInterval)
+	timeout := parseDuration(p.target.Timeout, defaultTimeout)
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		start := time.Now()
+		err := probe(ctx, p.target, timeout)
+		if ctx.Err() != nil {
+			return
+		}
+		p.record(err, time.Since(start))
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+func (p *prober) record(err error, latency time.Duration) {
+	rise, fall := p.target.Rise, p.target.Fall
+	if rise <= 0 {
+		rise = defaultRise
+	}
+	if fall <= 0 {
+		fall = defaultFall
+	}
+
+	if err == nil {
+		metrics.HealthProbeDuration.WithLabelValues(p.target.Name).Observe(latency.Seconds())
+	} else {
+		metrics.HealthProbeFailuresTotal.WithLabelValues(p.target.Name).Inc()
+	}
+
+	p.mu.Lock()
+	st := &p.status
+	st.LastCheck = time.Now()
+	sample := latency
+	if err != nil {
+		sample = -1
+	}
+	if p.window = append(p.window, sample); len(p.window) > windowSize {
+		p.window = p.window[1:]
+	}
+	st.AvgLatencyMs, st.LossPct = windowStats(p.window)
+	if err == nil {
+		st.LastError = ""
+		st.LatencyMs = float64(latency) / float64(time.Millisecond)
+		if p.streak < 0 {
+			p.streak = 0
+		}
+		p.streak++
+	} else {
+		st.LastError = err.Error()
+		if p.streak > 0 {
+			p.streak = 0
+		}
+		p.streak--
+	}
+
+	changed := false
+	switch {
+	case p.streak >= rise && (!st.Up || !st.Known):
+		st.Up, changed = true, true
+	case -p.streak >= fall && (st.Up || !st.Known):
+		st.Up, changed = false, true
+	}
+	if changed {
+		st.Known = true
+		st.LastChange = st.LastCheck
+	}
+	if p.streak >= 0 {
+		st.Consecutive = p.streak
+	} else {
+		st.Consecutive = -p.streak
+	}
+	up := st.Up
+	p.mu.Unlock()
+
+	if changed {
+		v := 0.0
+		if up {
+			v = 1
+		}
+		metrics.HealthTargetUp.WithLabelValues(p.target.Name).Set(v)
+		p.monitor.log.Info("health target state changed",
+			zap.String("target", p.target.Name), zap.Bool("up", up), zap.Error(err))
+		p.monitor.notify(p.target.Name, up)
+	}
+}
+
+func (p *prober) snapshot() TargetStatus {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.status
+}
+
+// ─── Probes ───────────────────────────────────────────────────────────────
+
+func probe(ctx context.Context, t policy.HealthTarget, timeout time.Duration) error {
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	switch t.Type {
+	case "icmp":
+		secs := int(timeout.Seconds())
+		if secs < 1 {
+			secs = 1
+		}
+		args := []string{"-c", "1", "-W", strconv.Itoa(secs)}
+		if t.Interface != "" {
+			args = append(args, "-I", t.Interface)
+		}
+		args = append(args, t.Address)
+		if out, err := exec.CommandContext(ctx, "ping", args...).CombinedOutput(); err != nil {
+			return fmt.Errorf("ping: %w (%s)", err, lastLine(out))
+		}
+		return nil
+
+	case "tcp":
+		d := net.Dialer{}
+		if t.Interface != "" {
+			d.Control = BindToDevice(t.Interface)
+		}
+		conn, err := d.DialContext(ctx, "tcp", t.Address)
+		if err != nil {
+			return err
+		}
+		return conn.Close()
+
+	case "http":
+		req, err := http.NewRequestWithContext(ctx, http.MethodGet, t.Address, nil)
+		if err != nil {
+			return err
+		}
+		transport := http.DefaultTransport.(*http.Transport).Clone()
+		transport.DisableKeepAlives = true
+		if t.Interface != "" {
+			transport.DialContext = (&net.Dialer{Control: BindToDevice(t.Interface)}).DialContext
+		}
+		client := &http.Client{
+			Transport: transport,
+			// A redirect is an answer; don't chase it.
+			CheckRedirect: func(*http.Request, []*http.Request) error { return http.ErrUseLastResponse },
+		}
+		resp, err := client.Do(req)
+		if err != nil {
+			return err
+		}
+		resp.Body.Close()
+		if t.ExpectStatus != 0 {
+			if resp.StatusCode != t.ExpectStatus {
+				return fmt.Errorf("status %d, want %d", resp.StatusCode, t.ExpectStatus)
+			}
+		} else if resp.StatusCode >= 400 {
+			return fmt.Errorf("status %d", resp.StatusCode)
+		}
+		return nil
+
+	case "dns":
+		return probeDNS(ctx, t)
+	}
+	return fmt.Errorf("unknown check type %q", t.Type)
+}
+
+// probeDNS asks the server at t.Address for t.Query. Any answer passes
+// unless t.ExpectAddress is set, in which case it must be among them.
+func probeDNS(ctx context.Context, t policy.HealthTarget) error {
+	server := t.Address
+	if _, _, err := net.SplitHostPort(server); err != nil {
+		server = net.JoinHostPort(server, "53")
+	}
+	d := net.Dialer{}
+	if t.Interface != "" {
+		d.Control = BindToDevice(t.Interface)
+	}
+	r := &net.Resolver{
+		PreferGo: true,
+		Dial: func(ctx context.Context, network, _ string) (net.Conn, error) {
+			return d.DialContext(ctx, network, server)
+		},
+	}
+	network := "ip4"
+	if strings.EqualFold(t.RecordType, "AAAA") {
+		network = "ip6"
+	}
+	ips, err := r.LookupIP(ctx, network, t.Query)
+	if err != nil {
+		return err
+	}
+	if t.ExpectAddress == "" {
+		return nil
+	}
+	want := net.ParseIP(t.ExpectAddress)
+	for _, ip := range ips {
+		if ip.Equal(want) {
+			return nil
+		}
+	}
+	return fmt.Errorf("%s did not resolve to %s", t.Query, t.ExpectAddress)
+}
+
+// ─── Private helpers ──────────────────────────────────────────────────────
+
+// windowStats returns the average latency of the successful probes of
+// window in milliseconds, and the percentage that failed.
+func windowStats(window []time.Duration) (avgMs, lossPct float64) {
+	var sum time.Duration
+	ok := 0
+	for _, d := range window {
+		if d >= 0 {
+			sum += d
+			ok++
+		}
+	}
+	if ok > 0 {
+		avgMs = float64(sum) / float64(ok) / float64(time.Millisecond)
+	}
+	if len(window) > 0 {
+		lossPct = float64(len(window)-ok) * 100 / float64(len(window))
+	}
+	return avgMs, lossPct
+}
+
+func parseDuration(s string, def time.Duration) time.Duration {
+	if d, err := time.ParseDuration(s); err == nil && d > 0 {
+		return d
+	}
+	return def
+}
+
+func lastLine(out []byte) string {
+	s := strings.TrimSpace(string(out))
+	return s[strings.LastIndex(s, "\n")+1:]
+}
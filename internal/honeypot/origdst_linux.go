@@ -0,0 +1,34 @@
+//go:build linux
+
+package honeypot
+
+import (
+	"net"
+	"syscall"
+)
+
+// soOriginalDst is SO_ORIGINAL_DST from linux/netfilter_ipv4.h.
+const soOriginalDst = 80
+
+// originalPort returns the destination port a redirected IPv4 connection
+// was addressed to, as recorded by conntrack.
+func originalPort(conn *net.TCPConn) (int, error) {
+	raw, err := conn.SyscallConn()
+	if err != nil {
+		return 0, err
+	}
+	var port int
+	var serr error
+	err = raw.Control(func(fd uintptr) {
+		// The result is a sockaddr_in; IPv6Mreq is merely a large enough buffer.
+		var mreq *syscall.IPv6Mreq
+		mreq, serr = syscall.GetsockoptIPv6Mreq(int(fd), syscall.IPPROTO_IP, soOriginalDst)
+		if serr == nil {
+			port = int(mreq.Multiaddr[2])<<8 | int(mreq.Multiaddr[3])
+		}
+	})
+	if err != nil {
+		return 0, err
+	}
+	return port, serr
+}
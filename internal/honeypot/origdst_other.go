@@ -0,0 +1,12 @@
+//go:build !linux
+
+package honeypot
+
+import (
+	"errors"
+	"net"
+)
+
+func originalPort(conn *net.TCPConn) (int, error) {
+	return 0, errors.New("original destination is only available on linux")
+}
@@ -0,0 +1,160 @@
+// Package honeypot provides a low-interaction tarpit that firewall rules with
+// the TARPIT action redirect probes to. It holds connections open while
+// trickling out junk, and reports every attempt so the source can be blocked.
+package honeypot
+
+import (
+	"context"
+	"fmt"
+	"math/rand"
+	"net"
+	"strconv"
+	"sync"
+	"time"
+
+	"go.uber.org/zap"
+
+	"github.com/aegisx/aegisx/internal/metrics"
+)
+
+// Probe is one connection caught by the tarpit.
+type Probe struct {
+	At         time.Time `json:"at"`
+	SrcIP      string    `json:"srcIp"`
+	SrcPort    int       `json:"srcPort"`
+	Port       int       `json:"port"`       // port the attacker tried, before the redirect; 0 if unknown
+	Overloaded bool      `json:"overloaded"` // closed immediately because MaxConns was reached
+}
+
+type Config struct {
+	Port        int           // local listener that probes are redirected to
+	Delay       time.Duration // between junk lines, default 10s
+	MaxConns    int           // connections held at once, default 512
+	MaxDuration time.Duration // per connection, default 15m
+}
+
+// Tarpit accepts redirected probes and keeps them busy.
+type Tarpit struct {
+	cfg      Config
+	sem      chan struct{}
+	mu       sync.Mutex
+	handlers []func(Probe)
+	log      *zap.Logger
+}
+
+func NewTarpit(cfg Config, log *zap.Logger) *Tarpit {
+	if cfg.Delay <= 0 {
+		cfg.Delay = 10 * time.Second
+	}
+	if cfg.MaxConns <= 0 {
+		cfg.MaxConns = 512
+	}
+	if cfg.MaxDuration <= 0 {
+		cfg.MaxDuration = 15 * time.Minute
+	}
+	return &Tarpit{cfg: cfg, sem: make(chan struct{}, cfg.MaxConns), log: log}
+}
+
+// OnProbe registers a callback for every caught connection. Callbacks run
+// off the accept loop, so they may block briefly (e.g. on a database write).
+func (t *Tarpit) OnProbe(fn func(Probe)) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.handlers = append(t.handlers, fn)
+}
+
+// Run listens until ctx is cancelled.
+// Call this in a goroutine.
+func (t *Tarpit) Run(ctx context.Context) error {
+	ln, err := net.Listen("tcp", ":"+strconv.Itoa(t.cfg.Port))
+	if err != nil {
+		return fmt.Errorf("tarpit listen: %w", err)
+	}
+	go func() {
+		<-ctx.Done()
+		ln.Close()
+	}()
+
+	for {
+		conn, err := ln.Accept()
+		if err != nil {
+			if ctx.Err() != nil {
+				return nil
+			}
+			t.log.Warn("tarpit accept", zap.Error(err))
+			continue
+		}
+		tcp := conn.(*net.TCPConn)
+		p := t.probe(tcp)
+
+		select {
+		case t.sem <- struct{}{}:
+		default:
+			p.Overloaded = true
+			conn.Close()
+		}
+		go t.emit(p)
+		if !p.Overloaded {
+			go t.hold(ctx, tcp)
+		}
+	}
+}
+
+// ─── Private helpers ──────────────────────────────────────────────────────
+
+func (t *Tarpit) probe(conn *net.TCPConn) Probe {
+	p := Probe{At: time.Now()}
+	if ra, ok := conn.RemoteAddr().(*net.TCPAddr); ok {
+		p.SrcIP = ra.IP.String()
+		p.SrcPort = ra.Port
+	}
+	if port, err := originalPort(conn); err == nil {
+		p.Port = port
+	}
+	return p
+}
+
+func (t *Tarpit) emit(p Probe) {
+	metrics.HoneypotProbesTotal.WithLabelValues(strconv.Itoa(p.Port)).Inc()
+	t.mu.Lock()
+	handlers := t.handlers
+	t.mu.Unlock()
+	for _, fn := range handlers {
+		fn(p)
+	}
+}
+
+// hold trickles one random line per delay, which SSH clients accept ahead of
+// the version banner and most other clients simply wait on.
+func (t *Tarpit) hold(ctx context.Context, conn *net.TCPConn) {
+	metrics.HoneypotConnections.Inc()
+	defer func() {
+		conn.Close()
+		<-t.sem
+		metrics.HoneypotConnections.Dec()
+	}()
+
+	deadline := time.Now().Add(t.cfg.MaxDuration)
+	ticker := time.NewTicker(t.cfg.Delay)
+	defer ticker.Stop()
+	for time.Now().Before(deadline) {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+		conn.SetWriteDeadline(time.Now().Add(t.cfg.Delay))
+		if _, err := conn.Write(junkLine()); err != nil {
+			return
+		}
+	}
+}
+
+func junkLine() []byte {
+	const chars = "abcdefghijklmnopqrstuvwxyz0123456789"
+	line := make([]byte, 3+rand.Intn(30), 35)
+	for i := range line {
+		line[i] = chars[rand.Intn(len(chars))]
+	}
+	return append(line, '\r', '\n')
+}
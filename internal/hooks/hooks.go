@@ -0,0 +1,249 @@
+// Package hooks runs operator scripts and webhooks around the apply
+// lifecycle. Every hook receives the same JSON payload: on stdin for
+// commands, as the request body for webhooks.
+package hooks
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"os/exec"
+	"time"
+
+	"github.com/google/uuid"
+	"go.uber.org/zap"
+
+	"github.com/aegisx/aegisx/internal/policy"
+	"github.com/aegisx/aegisx/internal/redact"
+)
+
+// Lifecycle events.
+const (
+	PreApply     = "pre-apply"     // a failing hook aborts the apply
+	PostApply    = "post-apply"    // after every apply attempt, successful or not
+	PostRollback = "post-rollback" // after a rollback attempt
+	BreakGlass   = "break-glass"   // emergency access was requested, granted or ended
+
+	VerifyMismatch = "verify-mismatch" // the live ruleset no longer matches the applied IR
+	HealthChange   = "health-change"   // a health target or monitor went up or down
+	DaemonCrash    = "daemon-crash"    // a supervised daemon exited and is being restarted
+)
+
+// Hook is one script or webhook. Exactly one of Command and URL is set.
+type Hook struct {
+	Name    string
+	Event   string
+	Command []string
+	URL     string
+	Timeout time.Duration // default 30s
+}
+
+// Payload is what hooks receive.
+type Payload struct {
+	Event   string     `json:"event"`
+	At      time.Time  `json:"at"`
+	IR      *IRSummary `json:"ir,omitempty"`
+	Success bool       `json:"success"`
+	Error   string     `json:"error,omitempty"`
+
+	BreakGlass *BreakGlassNotice `json:"breakGlass,omitempty"`
+	Health     *HealthNotice     `json:"health,omitempty"`
+	Daemon     *DaemonNotice     `json:"daemon,omitempty"`
+}
+
+// BreakGlassNotice describes a break-glass event.
+type BreakGlassNotice struct {
+	SessionID uuid.UUID  `json:"sessionId"`
+	TenantID  uuid.UUID  `json:"tenantId"`
+	UserID    uuid.UUID  `json:"userId"`
+	Action    string     `json:"action"` // requested|granted|denied|ended
+	Method    string     `json:"method"` // code|approval
+	Reason    string     `json:"reason"`
+	By        *uuid.UUID `json:"by,omitempty"` // approver or the user who ended it
+	ExpiresAt *time.Time `json:"expiresAt,omitempty"`
+}
+
+// HealthNotice describes a health target changing state.
+type HealthNotice struct {
+	Target    string  `json:"target"`
+	Up        bool    `json:"up"`
+	Error     string  `json:"error,omitempty"` // of the probe that tipped a target down
+	LatencyMs float64 `json:"latencyMs"`
+}
+
+// DaemonNotice describes a supervised daemon that exited.
+type DaemonNotice struct {
+	Daemon    string `json:"daemon"`
+	Error     string `json:"error"`     // how it exited, e.g. "exit status 1"
+	Restarts  int    `json:"restarts"`  // since AegisX started
+	RestartIn string `json:"restartIn"` // backoff before the next start
+}
+
+// IRSummary is the IR metadata; rule contents are left out so payloads stay
+// small and free of secrets such as VPN keys.
+type IRSummary struct {
+	ID             string    `json:"id"`
+	Version        int64     `json:"version"`
+	CreatedAt      time.Time `json:"createdAt"`
+	FirewallRules  int       `json:"firewallRules"`
+	NATRules       int       `json:"natRules"`
+	LoadBalancers  int       `json:"loadBalancers"`
+	VPNConfigs     int       `json:"vpnConfigs"`
+	IDSRules       int       `json:"idsRules"`
+	HealthTargets  int       `json:"healthTargets"`
+	ScheduledRules bool      `json:"scheduledRules"`
+}
+
+// Runner runs the configured hooks. A nil Runner runs nothing.
+type Runner struct {
+	hooks  map[string][]Hook
+	client *http.Client
+	log    *zap.Logger
+}
+
+func NewRunner(hooks []Hook, log *zap.Logger) (*Runner, error) {
+	r := &Runner{hooks: make(map[string][]Hook), client: &http.Client{}, log: log}
+	for _, h := range hooks {
+		switch h.Event {
+		case PreApply, PostApply, PostRollback, BreakGlass, VerifyMismatch, HealthChange, DaemonCrash:
+		default:
+			return nil, fmt.Errorf("hook %q: unknown event %q", h.Name, h.Event)
+		}
+		if (len(h.Command) == 0) == (h.URL == "") {
+			return nil, fmt.Errorf("hook %q: set exactly one of command and url", h.Name)
+		}
+		if h.Timeout <= 0 {
+			h.Timeout = 30 * time.Second
+		}
+		r.hooks[h.Event] = append(r.hooks[h.Event], h)
+	}
+	return r, nil
+}
+
+// Run runs the hooks of event in order and stops at the first failure.
+func (r *Runner) Run(ctx context.Context, event string, ir *policy.IR, result error) error {
+	if r == nil || len(r.hooks[event]) == 0 {
+		return nil
+	}
+	p := Payload{Event: event, At: time.Now(), IR: Summarize(ir), Success: result == nil}
+	if result != nil {
+		p.Error = redact.String(result.Error())
+	}
+	return r.send(ctx, p)
+}
+
+// Notify runs the hooks of a post-* event in the background; failures are
+// only logged since the operation they report on has already happened.
+func (r *Runner) Notify(event string, ir *policy.IR, result error) {
+	if r == nil || len(r.hooks[event]) == 0 {
+		return
+	}
+	go r.Run(context.Background(), event, ir, result)
+}
+
+// NotifyBreakGlass runs the break-glass hooks in the background.
+func (r *Runner) NotifyBreakGlass(n BreakGlassNotice) {
+	if r == nil || len(r.hooks[BreakGlass]) == 0 {
+		return
+	}
+	p := Payload{Event: BreakGlass, At: time.Now(), Success: n.Action != "denied", BreakGlass: &n}
+	go r.send(context.Background(), p)
+}
+
+// NotifyHealth runs the health-change hooks in the background. Success
+// mirrors the new state, so a receiver can alert on failures alone.
+func (r *Runner) NotifyHealth(n HealthNotice) {
+	if r == nil || len(r.hooks[HealthChange]) == 0 {
+		return
+	}
+	if n.Error != "" {
+		n.Error = redact.String(n.Error)
+	}
+	p := Payload{Event: HealthChange, At: time.Now(), Success: n.Up, Health: &n}
+	go r.send(context.Background(), p)
+}
+
+// NotifyDaemon runs the daemon-crash hooks in the background.
+func (r *Runner) NotifyDaemon(n DaemonNotice) {
+	if r == nil || len(r.hooks[DaemonCrash]) == 0 {
+		return
+	}
+	p := Payload{Event: DaemonCrash, At: time.Now(), Success: false, Daemon: &n}
+	go r.send(context.Background(), p)
+}
+
+// send runs the hooks of p.Event in order and stops at the first failure.
+func (r *Runner) send(ctx context.Context, p Payload) error {
+	event := p.Event
+	body, err := json.Marshal(p)
+	if err != nil {
+		return err
+	}
+
+	for _, h := range r.hooks[event] {
+		start := time.Now()
+		if err := r.run(ctx, h, body); err != nil {
+			r.log.Warn("hook failed", zap.String("hook", h.Name), zap.String("event", event), zap.Error(err))
+			return fmt.Errorf("hook %s: %w", h.Name, err)
+		}
+		r.log.Debug("hook ran", zap.String("hook", h.Name), zap.String("event", event),
+			zap.Duration("took", time.Since(start)))
+	}
+	return nil
+}
+
+// Summarize returns the metadata of ir, or nil.
+func Summarize(ir *policy.IR) *IRSummary {
+	if ir == nil {
+		return nil
+	}
+	return &IRSummary{
+		ID:             ir.ID,
+		Version:        ir.Version,
+		CreatedAt:      ir.CreatedAt,
+		FirewallRules:  len(ir.FirewallRules),
+		NATRules:       len(ir.NATRules),
+		LoadBalancers:  len(ir.LoadBalancers),
+		VPNConfigs:     len(ir.VPNConfigs),
+		IDSRules:       len(ir.IDSRules),
+		HealthTargets:  len(ir.HealthTargets),
+		ScheduledRules: ir.Scheduled(),
+	}
+}
+
+// ─── Private helpers ──────────────────────────────────────────────────────
+
+func (r *Runner) run(ctx context.Context, h Hook, body []byte) error {
+	ctx, cancel := context.WithTimeout(ctx, h.Timeout)
+	defer cancel()
+
+	if h.URL != "" {
+		req, err := http.NewRequestWithContext(ctx, http.MethodPost, h.URL, bytes.NewReader(body))
+		if err != nil {
+			return err
+		}
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set("X-AegisX-Event", h.Event)
+		resp, err := r.client.Do(req)
+		if err != nil {
+			return err
+		}
+		resp.Body.Close()
+		if resp.StatusCode < 200 || resp.StatusCode > 299 {
+			return fmt.Errorf("webhook returned %s", resp.Status)
+		}
+		return nil
+	}
+
+	cmd := exec.CommandContext(ctx, h.Command[0], h.Command[1:]...)
+	cmd.Stdin = bytes.NewReader(body)
+	cmd.Env = append(os.Environ(), "AEGISX_EVENT="+h.Event)
+	out, err := cmd.CombinedOutput()
+	if err != nil {
+		return fmt.Errorf("%w (output: %s)", err, bytes.TrimSpace(out))
+	}
+	return nil
+}
@@ -0,0 +1,155 @@
+package ids
+
+import (
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/aegisx/aegisx/internal/policy"
+)
+
+const (
+	customRulesFile     = "aegisx-custom.rules"
+	appControlRulesFile = "aegisx-appcontrol.rules"
+	datasetDir          = "datasets"
+
+	// appControlSIDBase starts the generated rules inside the local SID range.
+	appControlSIDBase = 1900000
+)
+
+// appFields maps a compiled field to the app-layer protocol of the rule, the
+// sticky buffer with its transforms, and the dataset type.
+var appFields = map[string]struct {
+	proto, buffer, dataType string
+}{
+	"tls.sni":   {"tls", "tls.sni; to_lowercase;", "string"},
+	"ja3.hash":  {"tls", "ja3.hash;", "md5"},
+	"http.host": {"http", "http.host;", "string"}, // already normalised to lower case
+	"dns.query": {"dns", "dns.query; to_lowercase;", "string"},
+}
+
+// ApplyIR writes the IDS-enforced parts of ir (custom rules and app control)
+// and reloads Suricata once.
+func (a *Adapter) ApplyIR(ir *policy.IR) error {
+	if err := a.writeCustomRules(ir.IDSRules); err != nil {
+		return err
+	}
+	if err := a.writeAppControl(ir.AppRules); err != nil {
+		return err
+	}
+	return a.ReloadRules()
+}
+
+// Render returns the files ApplyIR would write for ir, each introduced by
+// a "# ==> path <==" line: the custom rules, the app control rules and
+// their datasets.
+func (a *Adapter) Render(ir *policy.IR) (string, error) {
+	appRules, datasets, err := a.renderAppControl(ir.AppRules)
+	if err != nil {
+		return "", err
+	}
+	var sb strings.Builder
+	file := func(path, content string) {
+		fmt.Fprintf(&sb, "# ==> %s <==\n%s", path, content)
+	}
+	file(filepath.Join(a.rulesPath, customRulesFile), customRules(ir.IDSRules))
+	file(filepath.Join(a.rulesPath, appControlRulesFile), appRules)
+	for _, ds := range datasets {
+		file(ds.path, ds.content)
+	}
+	return sb.String(), nil
+}
+
+// writeAppControl writes the app control rules and the datasets holding
+// their exact-match values, and removes datasets no longer referenced.
+func (a *Adapter) writeAppControl(rules []policy.CompiledAppRule) error {
+	dir := filepath.Join(a.rulesPath, datasetDir)
+	if err := os.MkdirAll(dir, 0750); err != nil {
+		return fmt.Errorf("create dataset dir: %w", err)
+	}
+	stale, _ := filepath.Glob(filepath.Join(dir, "aegisx-app-*.lst"))
+
+	text, datasets, err := a.renderAppControl(rules)
+	if err != nil {
+		return err
+	}
+	for _, ds := range datasets {
+		if err := writeAtomic(ds.path, ds.content); err != nil {
+			return fmt.Errorf("write dataset %s: %w", filepath.Base(ds.path), err)
+		}
+	}
+
+	if err := writeAtomic(filepath.Join(a.rulesPath, appControlRulesFile), text); err != nil {
+		return fmt.Errorf("write app control rules: %w", err)
+	}
+	keep := make(map[string]bool, len(datasets))
+	for _, ds := range datasets {
+		keep[ds.path] = true
+	}
+	for _, path := range stale {
+		if !keep[path] {
+			os.Remove(path)
+		}
+	}
+	return nil
+}
+
+// dataset is one exact-match list loaded by an app control rule.
+type dataset struct {
+	path, content string
+}
+
+// renderAppControl renders the app control rules and the datasets they
+// load. Every dataset name carries a hash of its content, so a changed
+// list is loaded as a new set on the next reload.
+func (a *Adapter) renderAppControl(rules []policy.CompiledAppRule) (string, []dataset, error) {
+	dir := filepath.Join(a.rulesPath, datasetDir)
+	var datasets []dataset
+
+	var sb strings.Builder
+	sb.WriteString("# AegisX app control rules — DO NOT EDIT MANUALLY\n")
+	sid := appControlSIDBase
+	for _, r := range rules {
+		f, ok := appFields[r.Field]
+		if !ok {
+			return "", nil, fmt.Errorf("app control: unsupported field %q", r.Field)
+		}
+		head := fmt.Sprintf("%s %s any any -> any any", r.Action, f.proto)
+
+		if len(r.Values) > 0 {
+			content := datasetContent(f.dataType, r.Values)
+			sum := sha256.Sum256([]byte(content))
+			name := "aegisx-app-" + hex.EncodeToString(sum[:6])
+			path := filepath.Join(dir, name+".lst")
+			datasets = append(datasets, dataset{path: path, content: content})
+			fmt.Fprintf(&sb, "%s (msg:\"AegisX app control %s (%s)\"; %s dataset:isset,%s,type %s,load %s; sid:%d; rev:1;)\n",
+				head, r.Comment, r.Field, f.buffer, name, f.dataType, path, sid)
+			sid++
+		}
+		for _, suffix := range r.Suffixes {
+			// dotprefix makes ".example.com" match the apex and any subdomain,
+			// but not notexample.com.
+			fmt.Fprintf(&sb, "%s (msg:\"AegisX app control %s (%s *.%s)\"; %s dotprefix; content:\".%s\"; endswith; sid:%d; rev:1;)\n",
+				head, r.Comment, r.Field, suffix, f.buffer, suffix, sid)
+			sid++
+		}
+	}
+	return sb.String(), datasets, nil
+}
+
+// datasetContent renders one dataset file: string sets are base64 encoded
+// per line, md5 sets are plain hex.
+func datasetContent(dataType string, values []string) string {
+	var sb strings.Builder
+	for _, v := range values {
+		if dataType == "string" {
+			v = base64.StdEncoding.EncodeToString([]byte(v))
+		}
+		sb.WriteString(v + "\n")
+	}
+	return sb.String()
+}
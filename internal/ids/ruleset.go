@@ -0,0 +1,151 @@
+package ids
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/aegisx/aegisx/internal/store"
+)
+
+// upstreamRulesFile is the merged, tuned output of the downloaded rulesets.
+const upstreamRulesFile = "aegisx-upstream.rules"
+
+var (
+	sidRe    = regexp.MustCompile(`\bsid\s*:\s*(\d+)`)
+	gidRe    = regexp.MustCompile(`\bgid\s*:\s*(\d+)`)
+	actionRe = regexp.MustCompile(`^(alert|drop|reject|rejectsrc|rejectdst|rejectboth|pass)\s`)
+)
+
+// BuildStats summarises one ruleset build.
+type BuildStats struct {
+	Rules      int `json:"rules"`      // rules read from the source rulesets
+	Enabled    int `json:"enabled"`    // rules active in the output
+	Modified   int `json:"modified"`   // rules changed by an override
+	Thresholds int `json:"thresholds"` // threshold/suppress lines written
+}
+
+// BuildRuleset merges the downloaded rule files from the source directory
+// into one file in the rules directory, applying SID overrides on the way,
+// and writes the overrides' thresholds to the threshold file. Because it
+// always starts from the pristine downloads, tuning survives updates.
+func (a *Adapter) BuildRuleset(overrides []*store.SIDOverride) (BuildStats, error) {
+	var stats BuildStats
+	byKey := make(map[[2]int64]*store.SIDOverride, len(overrides))
+	for _, o := range overrides {
+		byKey[[2]int64{int64(o.GID), o.SID}] = o
+	}
+
+	files, err := filepath.Glob(filepath.Join(a.sourceDir, "*.rules"))
+	if err != nil {
+		return stats, err
+	}
+	sort.Strings(files)
+
+	var out strings.Builder
+	out.WriteString("# AegisX tuned upstream rules — DO NOT EDIT MANUALLY\n")
+	for _, path := range files {
+		if err := tuneFile(path, byKey, &out, &stats); err != nil {
+			return stats, err
+		}
+	}
+	dst := filepath.Join(a.rulesPath, upstreamRulesFile)
+	if err := writeAtomic(dst, out.String()); err != nil {
+		return stats, fmt.Errorf("write tuned rules: %w", err)
+	}
+
+	if a.thresholdPath != "" {
+		var th strings.Builder
+		th.WriteString("# AegisX SID thresholds — DO NOT EDIT MANUALLY\n")
+		for _, o := range overrides {
+			if line := thresholdLine(o); line != "" {
+				th.WriteString(line + "\n")
+				stats.Thresholds++
+			}
+		}
+		if err := writeAtomic(a.thresholdPath, th.String()); err != nil {
+			return stats, fmt.Errorf("write thresholds: %w", err)
+		}
+	}
+	return stats, nil
+}
+
+func tuneFile(path string, overrides map[[2]int64]*store.SIDOverride, out *strings.Builder, stats *BuildStats) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	fmt.Fprintf(out, "\n# ── %s\n", filepath.Base(path))
+	sc := bufio.NewScanner(f)
+	sc.Buffer(make([]byte, 64*1024), 1024*1024) // some rules are very long
+	for sc.Scan() {
+		line := sc.Text()
+		body := strings.TrimSpace(line)
+		disabled := strings.HasPrefix(body, "#")
+		body = strings.TrimSpace(strings.TrimLeft(body, "#"))
+		if !actionRe.MatchString(body) {
+			out.WriteString(line + "\n")
+			continue
+		}
+		stats.Rules++
+
+		if o := overrides[ruleKey(body)]; o != nil {
+			switch o.Action {
+			case "disable":
+				disabled = true
+			case "enable":
+				disabled = false
+			case "alert", "drop":
+				body = actionRe.ReplaceAllString(body, o.Action+" ")
+			}
+			if o.Action != "" {
+				stats.Modified++
+			}
+		}
+		if disabled {
+			out.WriteString("# " + body + "\n")
+			continue
+		}
+		stats.Enabled++
+		out.WriteString(body + "\n")
+	}
+	return sc.Err()
+}
+
+func ruleKey(rule string) [2]int64 {
+	key := [2]int64{1, 0}
+	if m := gidRe.FindStringSubmatch(rule); m != nil {
+		key[0], _ = strconv.ParseInt(m[1], 10, 64)
+	}
+	if m := sidRe.FindStringSubmatch(rule); m != nil {
+		key[1], _ = strconv.ParseInt(m[1], 10, 64)
+	}
+	return key
+}
+
+func thresholdLine(o *store.SIDOverride) string {
+	switch o.ThresholdType {
+	case "":
+		return ""
+	case "suppress":
+		return fmt.Sprintf("suppress gen_id %d, sig_id %d", o.GID, o.SID)
+	default:
+		return fmt.Sprintf("threshold gen_id %d, sig_id %d, type %s, track %s, count %d, seconds %d",
+			o.GID, o.SID, o.ThresholdType, o.Track, o.Count, o.Seconds)
+	}
+}
+
+func writeAtomic(path, content string) error {
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, []byte(content), 0640); err != nil {
+		return err
+	}
+	return os.Rename(tmp, path)
+}
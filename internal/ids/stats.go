@@ -0,0 +1,144 @@
+package ids
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"go.uber.org/zap"
+
+	"github.com/aegisx/aegisx/internal/metrics"
+)
+
+// Stats is one poll of Suricata's performance counters.
+type Stats struct {
+	At            time.Time        `json:"at"`
+	Uptime        int64            `json:"uptime"`
+	KernelPackets int64            `json:"kernelPackets"`
+	KernelDrops   int64            `json:"kernelDrops"`
+	LossRatio     float64          `json:"lossRatio"` // drops/packets since the previous poll
+	Decoder       map[string]int64 `json:"decoder"`
+	Memuse        map[string]int64 `json:"memuse"` // bytes per component
+}
+
+// counterResponse is the envelope of the dump-counters command.
+type counterResponse struct {
+	Return  string                     `json:"return"`
+	Message map[string]json.RawMessage `json:"message"`
+}
+
+// Counters fetches and parses the current counters.
+func (a *Adapter) Counters() (*Stats, error) {
+	raw, err := a.sendCommandResponse(`{"command":"dump-counters"}`)
+	if err != nil {
+		return nil, err
+	}
+	var resp counterResponse
+	if err := json.Unmarshal([]byte(raw), &resp); err != nil {
+		return nil, fmt.Errorf("parse counters: %w", err)
+	}
+	if resp.Return != "OK" {
+		return nil, fmt.Errorf("dump-counters returned %q", resp.Return)
+	}
+
+	st := &Stats{At: time.Now(), Decoder: map[string]int64{}, Memuse: map[string]int64{}}
+	json.Unmarshal(resp.Message["uptime"], &st.Uptime)
+	for section, body := range resp.Message {
+		var fields map[string]json.RawMessage
+		if json.Unmarshal(body, &fields) != nil {
+			continue
+		}
+		for name, v := range fields {
+			var n int64
+			if json.Unmarshal(v, &n) != nil {
+				continue // nested group, e.g. decoder.event
+			}
+			switch {
+			case section == "capture" && name == "kernel_packets":
+				st.KernelPackets = n
+			case section == "capture" && name == "kernel_drops":
+				st.KernelDrops = n
+			case section == "decoder":
+				st.Decoder[name] = n
+			case name == "memuse":
+				st.Memuse[section] = n
+			case name == "reassembly_memuse":
+				st.Memuse[section+"_reassembly"] = n
+			}
+		}
+	}
+	return st, nil
+}
+
+// LastStats returns the most recent poll, or nil before the first one.
+func (a *Adapter) LastStats() *Stats {
+	a.statsMu.Lock()
+	defer a.statsMu.Unlock()
+	return a.lastStats
+}
+
+// PollStats polls the counters every interval, exports them as metrics and
+// calls onLoss when the capture loss of an interval rises above threshold
+// (a ratio, e.g. 0.01 for 1%). onLoss fires once per episode: it is armed
+// again only after loss drops back below the threshold.
+// Call this in a goroutine; it blocks until ctx is cancelled.
+func (a *Adapter) PollStats(ctx context.Context, interval time.Duration, threshold float64, onLoss func(*Stats)) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	inLoss := false
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		st, err := a.Counters()
+		if err != nil {
+			a.log.Warn("poll suricata counters", zap.Error(err))
+			continue
+		}
+
+		a.statsMu.Lock()
+		prev := a.lastStats
+		a.lastStats = st
+		a.statsMu.Unlock()
+
+		// Counters restart from zero when Suricata does.
+		if prev != nil && st.KernelPackets >= prev.KernelPackets && st.KernelDrops >= prev.KernelDrops {
+			if pkts := st.KernelPackets - prev.KernelPackets; pkts > 0 {
+				st.LossRatio = float64(st.KernelDrops-prev.KernelDrops) / float64(pkts)
+			}
+		}
+		exportStats(st)
+
+		switch {
+		case st.LossRatio > threshold && !inLoss:
+			inLoss = true
+			metrics.IDSCaptureLossEventsTotal.Inc()
+			a.log.Warn("ids capture loss above threshold",
+				zap.Float64("loss_ratio", st.LossRatio), zap.Float64("threshold", threshold),
+				zap.Int64("kernel_drops", st.KernelDrops))
+			if onLoss != nil {
+				onLoss(st)
+			}
+		case st.LossRatio <= threshold && inLoss:
+			inLoss = false
+			a.log.Info("ids capture loss recovered", zap.Float64("loss_ratio", st.LossRatio))
+		}
+	}
+}
+
+func exportStats(st *Stats) {
+	metrics.IDSKernelPackets.Set(float64(st.KernelPackets))
+	metrics.IDSKernelDrops.Set(float64(st.KernelDrops))
+	metrics.IDSCaptureLossRatio.Set(st.LossRatio)
+	for name, n := range st.Decoder {
+		metrics.IDSDecoderCounters.WithLabelValues(name).Set(float64(n))
+	}
+	for component, n := range st.Memuse {
+		metrics.IDSMemuseBytes.WithLabelValues(component).Set(float64(n))
+	}
+}
@@ -0,0 +1,321 @@
+package ids
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net"
+	"strconv"
+	"strings"
+	"time"
+
+	"go.uber.org/zap"
+	"gopkg.in/yaml.v3"
+
+	"github.com/aegisx/aegisx/internal/firewall"
+	"github.com/aegisx/aegisx/internal/geoip"
+	"github.com/aegisx/aegisx/internal/honeypot"
+	"github.com/aegisx/aegisx/internal/policy"
+	"github.com/aegisx/aegisx/internal/store"
+)
+
+const (
+	SuggestBlockSource = "block_source"
+	SuggestClosePort   = "close_port"
+
+	// minDistinctSources is how many different attackers must hit a /24 or
+	// a port before the broader block is proposed instead of single hosts.
+	minDistinctSources = 3
+)
+
+// Suggester persists alerts and periodically turns recurring ones into
+// firewall rule suggestions.
+type Suggester struct {
+	store     *store.IDSStore
+	geo       *geoip.Resolver // nil leaves alerts unenriched
+	minAlerts int
+	window    time.Duration
+	interval  time.Duration
+	log       *zap.Logger
+}
+
+func NewSuggester(s *store.IDSStore, geo *geoip.Resolver, minAlerts int, window, interval time.Duration, log *zap.Logger) *Suggester {
+	return &Suggester{store: s, geo: geo, minAlerts: minAlerts, window: window, interval: interval, log: log}
+}
+
+// Record stores an alert; register it with Adapter.OnAlert.
+func (s *Suggester) Record(a Alert) {
+	raw, _ := json.Marshal(a)
+	rec := &store.IDSAlert{
+		Timestamp:    a.Timestamp,
+		SignatureID:  a.AlertDetail.SID,
+		SignatureMsg: a.AlertDetail.Message,
+		Severity:     a.AlertDetail.Severity,
+		Category:     a.AlertDetail.Category,
+		Action:       a.AlertDetail.Action,
+		SrcIP:        a.SrcIP,
+		DstIP:        a.DstIP,
+		SrcPort:      a.SrcPort,
+		DstPort:      a.DstPort,
+		Protocol:     a.Protocol,
+		FlowID:       a.FlowID,
+		Raw:          raw,
+	}
+	s.insert(rec, "store ids alert")
+}
+
+// RecordLoss stores a capture-loss episode as a high-severity alert so it
+// shows up next to the detections it may have hidden; pass it to PollStats.
+func (s *Suggester) RecordLoss(st *Stats) {
+	raw, _ := json.Marshal(st)
+	rec := &store.IDSAlert{
+		Timestamp: st.At,
+		SignatureMsg: fmt.Sprintf("AegisX: capture loss %.2f%% (%d kernel drops)",
+			st.LossRatio*100, st.KernelDrops),
+		Severity: 1,
+		Category: "Capture Loss",
+		Action:   "allowed",
+		Raw:      raw,
+	}
+	s.insert(rec, "store capture loss event")
+}
+
+// RecordProbe stores a tarpit hit as an alert, so sources that keep probing
+// are proposed for blocking like any other noisy source; pass it to
+// Tarpit.OnProbe.
+func (s *Suggester) RecordProbe(p honeypot.Probe) {
+	raw, _ := json.Marshal(p)
+	rec := &store.IDSAlert{
+		Timestamp:    p.At,
+		SignatureMsg: fmt.Sprintf("AegisX: honeypot probe on port %d", p.Port),
+		Severity:     2,
+		Category:     "Honeypot",
+		Action:       "blocked",
+		SrcIP:        p.SrcIP,
+		SrcPort:      p.SrcPort,
+		DstPort:      p.Port,
+		Protocol:     "TCP",
+		Raw:          raw,
+	}
+	s.insert(rec, "store honeypot probe")
+}
+
+// RecordScan stores a port scan flagged by the firewall; pass it to
+// Service.WatchScans.
+func (s *Suggester) RecordScan(ev firewall.ScanEvent) {
+	raw, _ := json.Marshal(ev)
+	action := "allowed"
+	if ev.Action != "log" {
+		action = "blocked"
+	}
+	rec := &store.IDSAlert{
+		Timestamp:    ev.At,
+		SignatureMsg: fmt.Sprintf("AegisX: port scan (%s)", ev.Action),
+		Severity:     2,
+		Category:     "Port Scan",
+		Action:       action,
+		SrcIP:        ev.SrcIP,
+		Raw:          raw,
+	}
+	s.insert(rec, "store port scan event")
+}
+
+// insert enriches rec with the country and AS of its remote address and
+// stores it; failures are logged with msg.
+func (s *Suggester) insert(rec *store.IDSAlert, msg string) {
+	info := s.geo.Remote(rec.SrcIP, rec.DstIP)
+	rec.Country, rec.ASN, rec.ASOrg = info.Country, info.ASN, info.ASOrg
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if err := s.store.InsertAlert(ctx, rec); err != nil {
+		s.log.Warn(msg, zap.Error(err))
+	}
+}
+
+// Run refreshes suggestions every interval until ctx is cancelled.
+func (s *Suggester) Run(ctx context.Context) {
+	ticker := time.NewTicker(s.interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if err := s.Refresh(ctx); err != nil {
+				s.log.Error("refresh ids suggestions", zap.Error(err))
+			}
+		}
+	}
+}
+
+// Refresh derives suggestions from the alerts of the last window.
+func (s *Suggester) Refresh(ctx context.Context) error {
+	since := time.Now().Add(-s.window)
+
+	sources, err := s.store.SourceAggregates(ctx, since)
+	if err != nil {
+		return fmt.Errorf("source aggregates: %w", err)
+	}
+	ports, err := s.store.PortAggregates(ctx, since)
+	if err != nil {
+		return fmt.Errorf("port aggregates: %w", err)
+	}
+
+	suggestions := s.blockSources(sources)
+	for _, p := range ports {
+		if p.Count < int64(s.minAlerts) || p.Sources < minDistinctSources {
+			continue
+		}
+		suggestions = append(suggestions, &store.IDSSuggestion{
+			Kind:        SuggestClosePort,
+			Target:      fmt.Sprintf("%s/%d", p.Protocol, p.DstPort),
+			AlertCount:  p.Count,
+			SourceCount: p.Sources,
+			Signatures:  p.Signatures,
+			FirstSeen:   p.FirstSeen,
+			LastSeen:    p.LastSeen,
+		})
+	}
+
+	for _, sg := range suggestions {
+		if err := s.store.UpsertSuggestion(ctx, sg); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// blockSources proposes a /24 when enough distinct IPv4 hosts of it alert
+// together, and single-host blocks for the remaining noisy sources.
+func (s *Suggester) blockSources(aggs []*store.AlertAggregate) []*store.IDSSuggestion {
+	nets := make(map[string][]*store.AlertAggregate)
+	var hosts []*store.AlertAggregate
+	for _, a := range aggs {
+		ip := net.ParseIP(a.SrcIP)
+		if ip == nil {
+			continue
+		}
+		if v4 := ip.To4(); v4 != nil {
+			cidr := (&net.IPNet{IP: v4.Mask(net.CIDRMask(24, 32)), Mask: net.CIDRMask(24, 32)}).String()
+			nets[cidr] = append(nets[cidr], a)
+		} else {
+			hosts = append(hosts, a)
+		}
+	}
+
+	var out []*store.IDSSuggestion
+	for cidr, members := range nets {
+		merged := mergeAggregates(members)
+		if len(members) >= minDistinctSources && merged.Count >= int64(s.minAlerts) {
+			out = append(out, blockSuggestion(cidr, merged))
+			continue
+		}
+		hosts = append(hosts, members...)
+	}
+	for _, h := range hosts {
+		if h.Count < int64(s.minAlerts) {
+			continue
+		}
+		bits := 32
+		if net.ParseIP(h.SrcIP).To4() == nil {
+			bits = 128
+		}
+		out = append(out, blockSuggestion(fmt.Sprintf("%s/%d", h.SrcIP, bits), h))
+	}
+	return out
+}
+
+// ─── Policy rendering ─────────────────────────────────────────────────────
+
+// SuggestionManifest renders the FirewallPolicy that implements sg. Each
+// suggestion becomes its own small policy so it can be reviewed, applied
+// and later deleted independently of hand-written rules.
+func SuggestionManifest(sg *store.IDSSuggestion, namespace string) (*policy.Manifest, string, error) {
+	rule := policy.FirewallRule{
+		Priority: 10,
+		Action:   "DROP",
+		Log:      true,
+		Comment:  fmt.Sprintf("IDS suggestion: %d alerts", sg.AlertCount),
+	}
+	switch sg.Kind {
+	case SuggestBlockSource:
+		rule.Name = "block-source"
+		rule.Protocol = "any"
+		rule.Source.Addresses = []string{sg.Target}
+	case SuggestClosePort:
+		proto, port, ok := strings.Cut(sg.Target, "/")
+		n, err := strconv.Atoi(port)
+		if !ok || err != nil {
+			return nil, "", fmt.Errorf("invalid port target %q", sg.Target)
+		}
+		rule.Name = "close-port"
+		rule.Protocol = proto
+		rule.Dest.Ports = []int{n}
+	default:
+		return nil, "", fmt.Errorf("unknown suggestion kind %q", sg.Kind)
+	}
+
+	m := &policy.Manifest{
+		APIVersion: policy.APIVersion,
+		Kind:       policy.KindFirewallPolicy,
+		Metadata: policy.Metadata{
+			Name:      "ids-" + strings.ReplaceAll(sg.Kind, "_", "-") + "-" + sanitizeName(sg.Target),
+			Namespace: namespace,
+		},
+		FirewallSpec: &policy.FirewallPolicySpec{Rules: []policy.FirewallRule{rule}},
+	}
+	raw, err := yaml.Marshal(m)
+	if err != nil {
+		return nil, "", fmt.Errorf("marshal manifest: %w", err)
+	}
+	return m, string(raw), nil
+}
+
+// ─── Private helpers ──────────────────────────────────────────────────────
+
+func blockSuggestion(cidr string, a *store.AlertAggregate) *store.IDSSuggestion {
+	return &store.IDSSuggestion{
+		Kind:        SuggestBlockSource,
+		Target:      cidr,
+		AlertCount:  a.Count,
+		SourceCount: a.Sources,
+		Signatures:  a.Signatures,
+		FirstSeen:   a.FirstSeen,
+		LastSeen:    a.LastSeen,
+	}
+}
+
+func mergeAggregates(aggs []*store.AlertAggregate) *store.AlertAggregate {
+	m := &store.AlertAggregate{FirstSeen: aggs[0].FirstSeen, LastSeen: aggs[0].LastSeen}
+	seen := make(map[string]bool)
+	for _, a := range aggs {
+		m.Count += a.Count
+		m.Sources += a.Sources
+		if a.FirstSeen.Before(m.FirstSeen) {
+			m.FirstSeen = a.FirstSeen
+		}
+		if a.LastSeen.After(m.LastSeen) {
+			m.LastSeen = a.LastSeen
+		}
+		for _, sig := range a.Signatures {
+			if !seen[sig] && len(m.Signatures) < 5 {
+				seen[sig] = true
+				m.Signatures = append(m.Signatures, sig)
+			}
+		}
+	}
+	return m
+}
+
+func sanitizeName(s string) string {
+	return strings.Map(func(r rune) rune {
+		if r >= 'a' && r <= 'z' || r >= '0' && r <= '9' {
+			return r
+		}
+		if r >= 'A' && r <= 'Z' {
+			return r + 'a' - 'A'
+		}
+		return '-'
+	}, s)
+}
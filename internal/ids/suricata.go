@@ -11,11 +11,13 @@ import (
 	"os/exec"
 	"path/filepath"
 	"strings"
+	"sync"
 	"time"
 
 	"go.uber.org/zap"
 
 	"github.com/aegisx/aegisx/internal/policy"
+	"github.com/aegisx/aegisx/internal/store"
 )
 
 // Alert is a parsed Suricata EVE JSON alert event.
@@ -41,32 +43,41 @@ type Alert struct {
 
 // Adapter manages a running Suricata instance.
 type Adapter struct {
-	configPath string
-	rulesPath  string
-	socketPath string
-	logPath    string
-	mode       string // "ids" | "ips"
-	log        *zap.Logger
+	configPath    string
+	rulesPath     string
+	sourceDir     string // downloaded upstream rulesets
+	thresholdPath string
+	socketPath    string
+	logPath       string
+	mode          string // "ids" | "ips"
+	log           *zap.Logger
 
 	alertHandlers []func(Alert)
+
+	statsMu   sync.Mutex
+	lastStats *Stats
 }
 
 type Config struct {
-	ConfigPath string
-	RulesPath  string
-	SocketPath string
-	LogPath    string
-	Mode       string
+	ConfigPath    string
+	RulesPath     string
+	SourceDir     string
+	ThresholdPath string
+	SocketPath    string
+	LogPath       string
+	Mode          string
 }
 
 func NewAdapter(cfg Config, log *zap.Logger) *Adapter {
 	return &Adapter{
-		configPath: cfg.ConfigPath,
-		rulesPath:  cfg.RulesPath,
-		socketPath: cfg.SocketPath,
-		logPath:    cfg.LogPath,
-		mode:       cfg.Mode,
-		log:        log,
+		configPath:    cfg.ConfigPath,
+		rulesPath:     cfg.RulesPath,
+		sourceDir:     cfg.SourceDir,
+		thresholdPath: cfg.ThresholdPath,
+		socketPath:    cfg.SocketPath,
+		logPath:       cfg.LogPath,
+		mode:          cfg.Mode,
+		log:           log,
 	}
 }
 
@@ -77,8 +88,23 @@ func (a *Adapter) OnAlert(fn func(Alert)) {
 
 // ApplyRules writes compiled IDS rules to the rules directory and reloads.
 func (a *Adapter) ApplyRules(rules []policy.CompiledIDSRule) error {
-	customRulesPath := filepath.Join(a.rulesPath, "aegisx-custom.rules")
+	if err := a.writeCustomRules(rules); err != nil {
+		return err
+	}
+	return a.ReloadRules()
+}
+
+func (a *Adapter) writeCustomRules(rules []policy.CompiledIDSRule) error {
+	customRulesPath := filepath.Join(a.rulesPath, customRulesFile)
 
+	if err := os.WriteFile(customRulesPath, []byte(customRules(rules)), 0640); err != nil {
+		return fmt.Errorf("write custom rules: %w", err)
+	}
+	return nil
+}
+
+// customRules renders the enabled rules, one per line.
+func customRules(rules []policy.CompiledIDSRule) string {
 	var sb strings.Builder
 	for _, r := range rules {
 		if r.Enabled {
@@ -86,12 +112,20 @@ func (a *Adapter) ApplyRules(rules []policy.CompiledIDSRule) error {
 			sb.WriteString("\n")
 		}
 	}
+	return sb.String()
+}
 
-	if err := os.WriteFile(customRulesPath, []byte(sb.String()), 0640); err != nil {
-		return fmt.Errorf("write custom rules: %w", err)
+// ApplyOverrides rebuilds the tuned upstream ruleset with the given SID
+// overrides and reloads Suricata.
+func (a *Adapter) ApplyOverrides(overrides []*store.SIDOverride) (BuildStats, error) {
+	stats, err := a.BuildRuleset(overrides)
+	if err != nil {
+		return stats, err
 	}
-
-	return a.ReloadRules()
+	a.log.Info("ids ruleset rebuilt",
+		zap.Int("rules", stats.Rules), zap.Int("enabled", stats.Enabled),
+		zap.Int("modified", stats.Modified), zap.Int("thresholds", stats.Thresholds))
+	return stats, a.ReloadRules()
 }
 
 // ReloadRules sends a reload command to Suricata via its Unix socket.
@@ -99,6 +133,11 @@ func (a *Adapter) ReloadRules() error {
 	return a.sendCommand(`{"command":"reload-rules"}`)
 }
 
+// Ping checks that Suricata answers on its command socket.
+func (a *Adapter) Ping() error {
+	return a.sendCommand(`{"command":"version"}`)
+}
+
 // Status returns Suricata stats via socket.
 func (a *Adapter) Status() (map[string]interface{}, error) {
 	resp, err := a.sendCommandResponse(`{"command":"dump-counters"}`)
@@ -128,7 +167,10 @@ func (a *Adapter) TailAlerts(ctx context.Context) error {
 		return err
 	}
 
-	scanner := bufio.NewScanner(f)
+	// A Scanner stops for good at the first EOF, so read with a Reader and
+	// carry a partially written line over to the next tick.
+	reader := bufio.NewReader(f)
+	var partial []byte
 	ticker := time.NewTicker(500 * time.Millisecond)
 	defer ticker.Stop()
 
@@ -137,13 +179,19 @@ func (a *Adapter) TailAlerts(ctx context.Context) error {
 		case <-ctx.Done():
 			return ctx.Err()
 		case <-ticker.C:
-			for scanner.Scan() {
-				line := scanner.Text()
-				if !strings.Contains(line, `"alert"`) {
+			for {
+				chunk, err := reader.ReadBytes('\n')
+				partial = append(partial, chunk...)
+				if err != nil {
+					break
+				}
+				line := partial
+				partial = nil
+				if !strings.Contains(string(line), `"alert"`) {
 					continue
 				}
 				var alert Alert
-				if err := json.Unmarshal([]byte(line), &alert); err != nil {
+				if err := json.Unmarshal(line, &alert); err != nil {
 					a.log.Warn("parse alert", zap.Error(err))
 					continue
 				}
@@ -177,14 +225,23 @@ func (a *Adapter) sendCommandResponse(cmd string) (string, error) {
 
 	conn.SetDeadline(time.Now().Add(5 * time.Second))
 
+	// The socket protocol starts with a version handshake, and responses are
+	// bare JSON values without a terminator, so decode rather than scan.
+	dec := json.NewDecoder(conn)
+	var hello json.RawMessage
+	if _, err := fmt.Fprintln(conn, `{"version": "0.2"}`); err != nil {
+		return "", fmt.Errorf("send handshake: %w", err)
+	}
+	if err := dec.Decode(&hello); err != nil {
+		return "", fmt.Errorf("read handshake: %w", err)
+	}
+
 	if _, err := fmt.Fprintln(conn, cmd); err != nil {
 		return "", fmt.Errorf("send command: %w", err)
 	}
-
-	var sb strings.Builder
-	scanner := bufio.NewScanner(conn)
-	for scanner.Scan() {
-		sb.WriteString(scanner.Text())
+	var resp json.RawMessage
+	if err := dec.Decode(&resp); err != nil {
+		return "", fmt.Errorf("read response: %w", err)
 	}
-	return sb.String(), scanner.Err()
+	return string(resp), nil
 }
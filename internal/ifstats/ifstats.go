@@ -0,0 +1,219 @@
+// Package ifstats samples the traffic counters of the network interfaces
+// from sysfs and keeps a short history of their byte and packet rates in
+// memory, so the dashboard can show live link utilization without an agent
+// on the host.
+package ifstats
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+// sysfsNet is where the kernel lists the network interfaces.
+const sysfsNet = "/sys/class/net"
+
+// subscriberBuffer is how many samples a slow watcher may fall behind
+// before it misses some.
+const subscriberBuffer = 16
+
+// Config is how often the counters are read and how much of their history
+// is kept.
+type Config struct {
+	Interval time.Duration
+	History  time.Duration
+}
+
+// Sample is the traffic of one interface over one sampling interval.
+type Sample struct {
+	Time            time.Time `json:"time"`
+	RxBytesPerSec   float64   `json:"rxBytesPerSec"`
+	TxBytesPerSec   float64   `json:"txBytesPerSec"`
+	RxPacketsPerSec float64   `json:"rxPacketsPerSec"`
+	TxPacketsPerSec float64   `json:"txPacketsPerSec"`
+}
+
+// counters is one reading of an interface's statistics.
+type counters struct {
+	at                                     time.Time
+	rxBytes, txBytes, rxPackets, txPackets uint64
+}
+
+// Sampler reads the counters of every interface each Interval.
+type Sampler struct {
+	cfg  Config
+	keep int // samples kept per interface
+	log  *zap.Logger
+
+	mu      sync.Mutex
+	last    map[string]counters
+	history map[string][]Sample // oldest first
+	subs    map[string]map[chan Sample]struct{}
+}
+
+func NewSampler(cfg Config, log *zap.Logger) *Sampler {
+	keep := 1
+	if cfg.Interval > 0 && cfg.History > cfg.Interval {
+		keep = int(cfg.History / cfg.Interval)
+	}
+	return &Sampler{
+		cfg:     cfg,
+		keep:    keep,
+		log:     log,
+		last:    make(map[string]counters),
+		history: make(map[string][]Sample),
+		subs:    make(map[string]map[chan Sample]struct{}),
+	}
+}
+
+// Interval returns the time between two samples.
+func (s *Sampler) Interval() time.Duration { return s.cfg.Interval }
+
+// Run reads the counters at once and then every Interval.
+// Call this in a goroutine; it blocks until ctx is cancelled.
+func (s *Sampler) Run(ctx context.Context) {
+	s.sample()
+	ticker := time.NewTicker(s.cfg.Interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+		s.sample()
+	}
+}
+
+// History returns the samples kept for the interface, oldest first, and
+// whether the interface exists. It is empty until the second reading.
+func (s *Sampler) History(name string) ([]Sample, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, ok := s.last[name]; !ok {
+		return nil, false
+	}
+	return append([]Sample{}, s.history[name]...), true
+}
+
+// Subscribe returns a channel receiving each new sample of the interface,
+// and the function that ends the subscription. The channel is closed when
+// the interface goes away; a receiver that falls behind misses samples.
+func (s *Sampler) Subscribe(name string) (<-chan Sample, func(), bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, ok := s.last[name]; !ok {
+		return nil, nil, false
+	}
+	ch := make(chan Sample, subscriberBuffer)
+	if s.subs[name] == nil {
+		s.subs[name] = make(map[chan Sample]struct{})
+	}
+	s.subs[name][ch] = struct{}{}
+	cancel := func() {
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		if _, ok := s.subs[name][ch]; ok {
+			delete(s.subs[name], ch)
+			close(ch)
+		}
+	}
+	return ch, cancel, true
+}
+
+// ─── Private helpers ──────────────────────────────────────────────────────
+
+// sample reads every interface and appends a sample for each that was
+// read before. A counter that went backwards, after the interface was
+// recreated or its driver reset it, starts the rates over.
+func (s *Sampler) sample() {
+	entries, err := os.ReadDir(sysfsNet)
+	if err != nil {
+		s.log.Warn("list network interfaces", zap.Error(err))
+		return
+	}
+	now := time.Now()
+	seen := make(map[string]bool, len(entries))
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, e := range entries {
+		name := e.Name()
+		cur, err := read(name, now)
+		if err != nil {
+			continue
+		}
+		seen[name] = true
+		prev, ok := s.last[name]
+		s.last[name] = cur
+		if !ok || cur.rxBytes < prev.rxBytes || cur.txBytes < prev.txBytes ||
+			cur.rxPackets < prev.rxPackets || cur.txPackets < prev.txPackets {
+			continue
+		}
+		secs := cur.at.Sub(prev.at).Seconds()
+		if secs <= 0 {
+			continue
+		}
+		smp := Sample{
+			Time:            now,
+			RxBytesPerSec:   float64(cur.rxBytes-prev.rxBytes) / secs,
+			TxBytesPerSec:   float64(cur.txBytes-prev.txBytes) / secs,
+			RxPacketsPerSec: float64(cur.rxPackets-prev.rxPackets) / secs,
+			TxPacketsPerSec: float64(cur.txPackets-prev.txPackets) / secs,
+		}
+		h := append(s.history[name], smp)
+		if len(h) > s.keep {
+			h = h[len(h)-s.keep:]
+		}
+		s.history[name] = h
+		for ch := range s.subs[name] {
+			select {
+			case ch <- smp:
+			default:
+			}
+		}
+	}
+
+	for name := range s.last {
+		if seen[name] {
+			continue
+		}
+		delete(s.last, name)
+		delete(s.history, name)
+		for ch := range s.subs[name] {
+			close(ch)
+		}
+		delete(s.subs, name)
+	}
+}
+
+// read returns the statistics of the interface.
+func read(name string, at time.Time) (counters, error) {
+	dir := filepath.Join(sysfsNet, name, "statistics")
+	c := counters{at: at}
+	for _, f := range []struct {
+		file string
+		dst  *uint64
+	}{
+		{"rx_bytes", &c.rxBytes},
+		{"tx_bytes", &c.txBytes},
+		{"rx_packets", &c.rxPackets},
+		{"tx_packets", &c.txPackets},
+	} {
+		b, err := os.ReadFile(filepath.Join(dir, f.file))
+		if err != nil {
+			return counters{}, err
+		}
+		if *f.dst, err = strconv.ParseUint(strings.TrimSpace(string(b)), 10, 64); err != nil {
+			return counters{}, err
+		}
+	}
+	return c, nil
+}
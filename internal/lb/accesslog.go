@@ -0,0 +1,297 @@
+package lb
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"go.uber.org/zap"
+
+	"github.com/aegisx/aegisx/internal/metrics"
+)
+
+// accessLogTag prefixes every access log line emitted by generated frontends
+// so the collector can ignore anything else arriving on its socket.
+const accessLogTag = "aegisx-access"
+
+// HAProxy log-formats for the collector. Fields, in order: frontend, backend,
+// server, status, total time (ms), bytes read from server, bytes uploaded,
+// HTTP method. TCP frontends have no status or method.
+const (
+	httpAccessLogFormat = accessLogTag + " %ft %b %s %ST %Ta %B %U %HM"
+	tcpAccessLogFormat  = accessLogTag + " %ft %b %s - %Tt %B %U -"
+)
+
+const (
+	analyticsBucket = 10 * time.Second
+	analyticsSpan   = time.Hour
+	analyticsSlots  = int(analyticsSpan / analyticsBucket)
+)
+
+// latencyBoundsMs are the upper bounds of the in-memory latency histogram used
+// for percentile estimates. The last slot catches everything above.
+var latencyBoundsMs = []float64{5, 10, 25, 50, 100, 250, 500, 1000, 2500, 5000, 10000}
+
+// AccessLogEntry is one parsed HAProxy access log line.
+type AccessLogEntry struct {
+	Frontend  string
+	Backend   string
+	Server    string
+	Status    int // 0 for TCP
+	Duration  time.Duration
+	BytesOut  int64
+	BytesIn   int64
+	Method    string
+	Timestamp time.Time
+}
+
+// TrafficStats summarises one frontend/backend pair over a window.
+type TrafficStats struct {
+	Frontend     string           `json:"frontend"`
+	Backend      string           `json:"backend"`
+	Requests     int64            `json:"requests"`
+	RequestRate  float64          `json:"requestRate"` // per second
+	StatusCodes  map[string]int64 `json:"statusCodes"` // "2xx" → count
+	ErrorRate    float64          `json:"errorRate"`   // share of 5xx
+	LatencyAvgMs float64          `json:"latencyAvgMs"`
+	LatencyP50Ms float64          `json:"latencyP50Ms"`
+	LatencyP95Ms float64          `json:"latencyP95Ms"`
+	LatencyP99Ms float64          `json:"latencyP99Ms"`
+	BytesOut     int64            `json:"bytesOut"`
+	BytesIn      int64            `json:"bytesIn"`
+}
+
+// AccessLogCollector receives HAProxy access logs over syslog/UDP, exports
+// them as Prometheus metrics and keeps an hour of in-memory aggregates for
+// the analytics API.
+type AccessLogCollector struct {
+	addr     string
+	maxPairs int // frontend/backend pairs kept for analytics; 0 means no limit
+	log      *zap.Logger
+
+	mu    sync.Mutex
+	pairs map[string]*pairSeries
+}
+
+func NewAccessLogCollector(addr string, maxPairs int, log *zap.Logger) *AccessLogCollector {
+	return &AccessLogCollector{addr: addr, maxPairs: maxPairs, log: log, pairs: make(map[string]*pairSeries)}
+}
+
+// Run listens until ctx is cancelled. Call this in a goroutine.
+func (c *AccessLogCollector) Run(ctx context.Context) error {
+	conn, err := net.ListenPacket("udp", c.addr)
+	if err != nil {
+		return fmt.Errorf("listen %s: %w", c.addr, err)
+	}
+	go func() {
+		<-ctx.Done()
+		conn.Close()
+	}()
+
+	buf := make([]byte, 8192)
+	for {
+		n, _, err := conn.ReadFrom(buf)
+		if err != nil {
+			if ctx.Err() != nil {
+				return nil
+			}
+			c.log.Warn("access log read failed", zap.Error(err))
+			continue
+		}
+		entry, ok := ParseAccessLog(string(buf[:n]))
+		if !ok {
+			continue
+		}
+		entry.Timestamp = time.Now()
+		c.Record(entry)
+	}
+}
+
+// Record feeds one entry into the metrics and the analytics window.
+func (c *AccessLogCollector) Record(e *AccessLogEntry) {
+	code := statusClass(e.Status)
+	metrics.LBRequestsTotal.WithLabelValues(e.Frontend, e.Backend, code).Inc()
+	metrics.LBRequestDuration.WithLabelValues(e.Frontend, e.Backend).Observe(e.Duration.Seconds())
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	key := e.Frontend + "\x00" + e.Backend
+	s, ok := c.pairs[key]
+	if !ok {
+		if c.maxPairs > 0 && len(c.pairs) >= c.maxPairs {
+			return
+		}
+		s = &pairSeries{frontend: e.Frontend, backend: e.Backend}
+		c.pairs[key] = s
+	}
+	s.add(e, code)
+}
+
+// Analytics returns per frontend/backend statistics over the trailing window
+// (capped at one hour). An empty frontend selects all.
+func (c *AccessLogCollector) Analytics(window time.Duration, frontend string) []TrafficStats {
+	if window <= 0 || window > analyticsSpan {
+		window = analyticsSpan
+	}
+	now := time.Now()
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	out := make([]TrafficStats, 0, len(c.pairs))
+	for _, s := range c.pairs {
+		if frontend != "" && s.frontend != frontend {
+			continue
+		}
+		st := s.summarise(now, window)
+		if st.Requests > 0 {
+			out = append(out, st)
+		}
+	}
+	sort.Slice(out, func(i, j int) bool {
+		if out[i].Frontend != out[j].Frontend {
+			return out[i].Frontend < out[j].Frontend
+		}
+		return out[i].Backend < out[j].Backend
+	})
+	return out
+}
+
+// ParseAccessLog extracts an entry from a raw syslog datagram. Lines without
+// the collector tag are rejected.
+func ParseAccessLog(line string) (*AccessLogEntry, bool) {
+	i := strings.Index(line, accessLogTag+" ")
+	if i < 0 {
+		return nil, false
+	}
+	f := strings.Fields(line[i+len(accessLogTag):])
+	if len(f) < 8 {
+		return nil, false
+	}
+
+	e := &AccessLogEntry{
+		Frontend: lbName(f[0]),
+		Backend:  lbName(f[1]),
+		Server:   f[2],
+		Method:   strings.Trim(f[7], "-"),
+	}
+	e.Status, _ = strconv.Atoi(f[3])
+	ms, err := strconv.ParseInt(f[4], 10, 64)
+	if err != nil || ms < 0 {
+		// HAProxy logs -1 for aborted requests; count them with no latency.
+		ms = 0
+	}
+	e.Duration = time.Duration(ms) * time.Millisecond
+	e.BytesOut, _ = strconv.ParseInt(f[5], 10, 64)
+	e.BytesIn, _ = strconv.ParseInt(f[6], 10, 64)
+	return e, true
+}
+
+// ─── Private helpers ──────────────────────────────────────────────────────
+
+type analyticsSlot struct {
+	epoch     int64 // bucket index since the Unix epoch; detects stale slots
+	requests  int64
+	status    map[string]int64
+	latency   [12]int64 // len(latencyBoundsMs)+1
+	latencyMs float64
+	bytesOut  int64
+	bytesIn   int64
+}
+
+type pairSeries struct {
+	frontend string
+	backend  string
+	slots    [analyticsSlots]analyticsSlot
+}
+
+func (s *pairSeries) add(e *AccessLogEntry, code string) {
+	epoch := e.Timestamp.UnixNano() / int64(analyticsBucket)
+	slot := &s.slots[epoch%int64(analyticsSlots)]
+	if slot.epoch != epoch {
+		*slot = analyticsSlot{epoch: epoch, status: make(map[string]int64)}
+	}
+
+	ms := float64(e.Duration) / float64(time.Millisecond)
+	slot.requests++
+	slot.status[code]++
+	slot.latency[sort.SearchFloat64s(latencyBoundsMs, ms)]++
+	slot.latencyMs += ms
+	slot.bytesOut += e.BytesOut
+	slot.bytesIn += e.BytesIn
+}
+
+func (s *pairSeries) summarise(now time.Time, window time.Duration) TrafficStats {
+	st := TrafficStats{Frontend: s.frontend, Backend: s.backend, StatusCodes: make(map[string]int64)}
+
+	newest := now.UnixNano() / int64(analyticsBucket)
+	oldest := now.Add(-window).UnixNano() / int64(analyticsBucket)
+
+	var (
+		hist  [12]int64
+		latMs float64
+	)
+	for i := range s.slots {
+		slot := &s.slots[i]
+		if slot.epoch < oldest || slot.epoch > newest || slot.requests == 0 {
+			continue
+		}
+		st.Requests += slot.requests
+		for k, v := range slot.status {
+			st.StatusCodes[k] += v
+		}
+		for b, v := range slot.latency {
+			hist[b] += v
+		}
+		latMs += slot.latencyMs
+		st.BytesOut += slot.bytesOut
+		st.BytesIn += slot.bytesIn
+	}
+	if st.Requests == 0 {
+		return st
+	}
+
+	st.RequestRate = float64(st.Requests) / window.Seconds()
+	st.ErrorRate = float64(st.StatusCodes["5xx"]) / float64(st.Requests)
+	st.LatencyAvgMs = latMs / float64(st.Requests)
+	st.LatencyP50Ms = histQuantile(hist[:], st.Requests, 0.50)
+	st.LatencyP95Ms = histQuantile(hist[:], st.Requests, 0.95)
+	st.LatencyP99Ms = histQuantile(hist[:], st.Requests, 0.99)
+	return st
+}
+
+// histQuantile returns the upper bound of the bucket holding quantile q. The
+// overflow bucket reports the largest finite bound.
+func histQuantile(hist []int64, total int64, q float64) float64 {
+	target := int64(q * float64(total))
+	var cum int64
+	for i, n := range hist {
+		cum += n
+		if cum > target || cum == total {
+			if i < len(latencyBoundsMs) {
+				return latencyBoundsMs[i]
+			}
+			break
+		}
+	}
+	return latencyBoundsMs[len(latencyBoundsMs)-1]
+}
+
+func statusClass(code int) string {
+	if code < 100 || code > 599 {
+		return "other"
+	}
+	return fmt.Sprintf("%dxx", code/100)
+}
+
+// lbName maps generated HAProxy section names back to policy names.
+func lbName(s string) string {
+	s = strings.TrimSuffix(s, "~") // TLS frontends
+	s = strings.TrimSuffix(s, "_frontend")
+	return strings.TrimSuffix(s, "_backend")
+}
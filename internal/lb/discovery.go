@@ -0,0 +1,412 @@
+package lb
+
+import (
+	"bufio"
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/json"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"go.uber.org/zap"
+
+	"github.com/aegisx/aegisx/internal/policy"
+)
+
+const (
+	defaultDiscoverySlots    = 16
+	defaultDiscoveryInterval = 30 * time.Second
+	defaultConsulAddress     = "http://127.0.0.1:8500"
+	k8sServiceAccountDir     = "/var/run/secrets/kubernetes.io/serviceaccount"
+)
+
+// Endpoint is one discovered backend address.
+type Endpoint struct {
+	IP   string `json:"ip"`
+	Port int    `json:"port"`
+}
+
+func (e Endpoint) String() string { return net.JoinHostPort(e.IP, strconv.Itoa(e.Port)) }
+
+// discoverySlots is the number of server-template slots rendered for a
+// backend; discovered endpoints beyond it are dropped with a warning.
+func discoverySlots(d *policy.LBDiscovery) int {
+	if d.MaxServers > 0 {
+		return d.MaxServers
+	}
+	return defaultDiscoverySlots
+}
+
+// DiscoveredServers returns the last endpoints found for each discovered
+// backend, keyed by policy name.
+func (a *Adapter) DiscoveredServers() map[string][]Endpoint {
+	a.discMu.Lock()
+	defer a.discMu.Unlock()
+	out := make(map[string][]Endpoint, len(a.discovered))
+	for k, v := range a.discovered {
+		out[k] = append([]Endpoint(nil), v...)
+	}
+	return out
+}
+
+// syncDiscovery starts watchers for newly discovered backends, stops those
+// whose policy went away and re-pushes cached endpoints, since a reload
+// resets all server-template slots to disabled.
+func (a *Adapter) syncDiscovery(ir *policy.IR) {
+	a.discMu.Lock()
+	defer a.discMu.Unlock()
+
+	wanted := make(map[string]*policy.LBDiscovery)
+	for _, lb := range ir.LoadBalancers {
+		if d := lb.Backend.Discovery; d != nil {
+			wanted[lb.Name] = d
+		}
+	}
+
+	for name, w := range a.watchers {
+		if d, ok := wanted[name]; !ok || *d != w.spec {
+			w.cancel()
+			delete(a.watchers, name)
+			delete(a.discovered, name)
+		}
+	}
+	for name, d := range wanted {
+		if _, ok := a.watchers[name]; ok {
+			if eps, ok := a.discovered[name]; ok {
+				go a.pushEndpoints(name, discoverySlots(d), eps)
+			}
+			continue
+		}
+		ctx, cancel := context.WithCancel(context.Background())
+		a.watchers[name] = &discoveryWatcher{spec: *d, cancel: cancel}
+		go a.watch(ctx, name, *d)
+	}
+}
+
+type discoveryWatcher struct {
+	spec   policy.LBDiscovery
+	cancel context.CancelFunc
+}
+
+// watch runs the source-specific discovery loop until ctx is cancelled,
+// pushing every membership change to HAProxy.
+func (a *Adapter) watch(ctx context.Context, name string, d policy.LBDiscovery) {
+	log := a.log.With(zap.String("backend", name), zap.String("discovery", d.Type))
+	update := func(eps []Endpoint) {
+		sort.Slice(eps, func(i, j int) bool { return eps[i].String() < eps[j].String() })
+		a.discMu.Lock()
+		if ctx.Err() != nil {
+			a.discMu.Unlock()
+			return
+		}
+		prev, seen := a.discovered[name]
+		a.discovered[name] = eps
+		a.discMu.Unlock()
+		if seen && sameEndpoints(prev, eps) {
+			return
+		}
+		log.Info("backend membership changed", zap.Int("servers", len(eps)))
+		a.pushEndpoints(name, discoverySlots(&d), eps)
+	}
+
+	var err error
+	switch d.Type {
+	case "dns":
+		err = watchDNS(ctx, d, update)
+	case "consul":
+		err = watchConsul(ctx, d, update, log)
+	case "kubernetes":
+		err = watchKubernetes(ctx, d, update, log)
+	default:
+		err = fmt.Errorf("unknown discovery type %q", d.Type)
+	}
+	if err != nil && ctx.Err() == nil {
+		log.Error("service discovery stopped", zap.Error(err))
+	}
+}
+
+// pushEndpoints fills the first len(eps) slots and puts the rest in maint.
+func (a *Adapter) pushEndpoints(name string, slots int, eps []Endpoint) {
+	if len(eps) > slots {
+		a.log.Warn("more endpoints than discovery slots; extra ignored",
+			zap.String("backend", name), zap.Int("endpoints", len(eps)), zap.Int("slots", slots))
+		eps = eps[:slots]
+	}
+	maint := a.InMaintenance(name)
+	backend := name + "_backend"
+	for i := 1; i <= slots; i++ {
+		srv := fmt.Sprintf("%s/%s_sd%d", backend, name, i)
+		var cmds []string
+		if i <= len(eps) {
+			ep := eps[i-1]
+			state := "ready"
+			if maint {
+				state = "maint"
+			}
+			cmds = []string{
+				fmt.Sprintf("set server %s addr %s port %d", srv, ep.IP, ep.Port),
+				fmt.Sprintf("set server %s state %s", srv, state),
+			}
+		} else {
+			cmds = []string{fmt.Sprintf("set server %s state maint", srv)}
+		}
+		for _, cmd := range cmds {
+			if _, err := a.runtimeCommand(cmd); err != nil {
+				a.log.Warn("runtime update failed", zap.String("cmd", cmd), zap.Error(err))
+				return
+			}
+		}
+	}
+}
+
+// ─── Sources ──────────────────────────────────────────────────────────────
+
+func watchDNS(ctx context.Context, d policy.LBDiscovery, update func([]Endpoint)) error {
+	interval := defaultDiscoveryInterval
+	if d.Interval != "" {
+		if iv, err := time.ParseDuration(d.Interval); err == nil && iv > 0 {
+			interval = iv
+		}
+	}
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		if eps, err := resolveSRV(ctx, d.Name); err == nil {
+			update(eps)
+		}
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-ticker.C:
+		}
+	}
+}
+
+func resolveSRV(ctx context.Context, name string) ([]Endpoint, error) {
+	_, srvs, err := net.DefaultResolver.LookupSRV(ctx, "", "", name)
+	if err != nil {
+		return nil, err
+	}
+	var eps []Endpoint
+	for _, srv := range srvs {
+		addrs, err := net.DefaultResolver.LookupHost(ctx, strings.TrimSuffix(srv.Target, "."))
+		if err != nil || len(addrs) == 0 {
+			continue
+		}
+		eps = append(eps, Endpoint{IP: addrs[0], Port: int(srv.Port)})
+	}
+	return eps, nil
+}
+
+// watchConsul uses blocking queries so changes arrive as soon as Consul
+// sees them. Only passing instances are returned.
+func watchConsul(ctx context.Context, d policy.LBDiscovery, update func([]Endpoint), log *zap.Logger) error {
+	base := d.Address
+	if base == "" {
+		base = defaultConsulAddress
+	}
+	client := &http.Client{Timeout: 6 * time.Minute}
+	index := "0"
+	for {
+		q := url.Values{"passing": {"true"}, "index": {index}, "wait": {"5m"}}
+		if d.Tag != "" {
+			q.Set("tag", d.Tag)
+		}
+		u := fmt.Sprintf("%s/v1/health/service/%s?%s", strings.TrimRight(base, "/"), url.PathEscape(d.Name), q.Encode())
+
+		eps, next, err := consulQuery(ctx, client, u)
+		if ctx.Err() != nil {
+			return nil
+		}
+		if err != nil {
+			log.Warn("consul query failed", zap.Error(err))
+			index = "0"
+			if !sleepCtx(ctx, defaultDiscoveryInterval) {
+				return nil
+			}
+			continue
+		}
+		// Consul asks clients to reset when the index goes backwards.
+		cur, _ := strconv.ParseUint(index, 10, 64)
+		if n, err := strconv.ParseUint(next, 10, 64); err != nil || n < cur {
+			next = "0"
+		}
+		index = next
+		update(eps)
+	}
+}
+
+func consulQuery(ctx context.Context, client *http.Client, u string) ([]Endpoint, string, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, u, nil)
+	if err != nil {
+		return nil, "", err
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, "", err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, "", fmt.Errorf("consul: %s", resp.Status)
+	}
+
+	var entries []struct {
+		Node    struct{ Address string }
+		Service struct {
+			Address string
+			Port    int
+		}
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&entries); err != nil {
+		return nil, "", fmt.Errorf("decode consul response: %w", err)
+	}
+	eps := make([]Endpoint, 0, len(entries))
+	for _, e := range entries {
+		addr := e.Service.Address
+		if addr == "" {
+			addr = e.Node.Address
+		}
+		eps = append(eps, Endpoint{IP: addr, Port: e.Service.Port})
+	}
+	return eps, resp.Header.Get("X-Consul-Index"), nil
+}
+
+// k8sEndpoints is the subset of a core/v1 Endpoints object we need.
+type k8sEndpoints struct {
+	Subsets []struct {
+		Addresses []struct {
+			IP string `json:"ip"`
+		} `json:"addresses"`
+		Ports []struct {
+			Name string `json:"name"`
+			Port int    `json:"port"`
+		} `json:"ports"`
+	} `json:"subsets"`
+}
+
+func (e *k8sEndpoints) endpoints(portName string) []Endpoint {
+	var eps []Endpoint
+	for _, ss := range e.Subsets {
+		port := 0
+		for _, p := range ss.Ports {
+			if portName == "" || p.Name == portName {
+				port = p.Port
+				break
+			}
+		}
+		if port == 0 {
+			continue
+		}
+		for _, a := range ss.Addresses {
+			eps = append(eps, Endpoint{IP: a.IP, Port: port})
+		}
+	}
+	return eps
+}
+
+// watchKubernetes watches a single Endpoints object using the in-cluster
+// service account, re-establishing the watch whenever the stream ends.
+func watchKubernetes(ctx context.Context, d policy.LBDiscovery, update func([]Endpoint), log *zap.Logger) error {
+	host, port := os.Getenv("KUBERNETES_SERVICE_HOST"), os.Getenv("KUBERNETES_SERVICE_PORT")
+	if host == "" {
+		return fmt.Errorf("not running in a kubernetes cluster")
+	}
+	token, err := os.ReadFile(k8sServiceAccountDir + "/token")
+	if err != nil {
+		return fmt.Errorf("read service account token: %w", err)
+	}
+	ca, err := os.ReadFile(k8sServiceAccountDir + "/ca.crt")
+	if err != nil {
+		return fmt.Errorf("read cluster CA: %w", err)
+	}
+	pool := x509.NewCertPool()
+	pool.AppendCertsFromPEM(ca)
+	client := &http.Client{Transport: &http.Transport{TLSClientConfig: &tls.Config{RootCAs: pool}}}
+
+	ns := d.Namespace
+	if ns == "" {
+		ns = "default"
+	}
+	u := fmt.Sprintf("https://%s/api/v1/namespaces/%s/endpoints?watch=true&fieldSelector=%s",
+		net.JoinHostPort(host, port), url.PathEscape(ns), url.QueryEscape("metadata.name="+d.Name))
+
+	for {
+		err := k8sWatch(ctx, client, u, strings.TrimSpace(string(token)), d.Port, update)
+		if ctx.Err() != nil {
+			return nil
+		}
+		if err != nil {
+			log.Warn("kubernetes watch interrupted", zap.Error(err))
+		}
+		if !sleepCtx(ctx, 5*time.Second) {
+			return nil
+		}
+	}
+}
+
+func k8sWatch(ctx context.Context, client *http.Client, u, token, portName string, update func([]Endpoint)) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, u, nil)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Authorization", "Bearer "+token)
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("kubernetes: %s", resp.Status)
+	}
+
+	sc := bufio.NewScanner(resp.Body)
+	sc.Buffer(make([]byte, 64*1024), 4*1024*1024)
+	for sc.Scan() {
+		var ev struct {
+			Type   string       `json:"type"`
+			Object k8sEndpoints `json:"object"`
+		}
+		if err := json.Unmarshal(sc.Bytes(), &ev); err != nil {
+			return fmt.Errorf("decode watch event: %w", err)
+		}
+		switch ev.Type {
+		case "ADDED", "MODIFIED":
+			update(ev.Object.endpoints(portName))
+		case "DELETED":
+			update(nil)
+		}
+	}
+	return sc.Err()
+}
+
+// ─── Private helpers ──────────────────────────────────────────────────────
+
+func sameEndpoints(a, b []Endpoint) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
+
+func sleepCtx(ctx context.Context, d time.Duration) bool {
+	select {
+	case <-ctx.Done():
+		return false
+	case <-time.After(d):
+		return true
+	}
+}
@@ -7,7 +7,9 @@ import (
 	"net"
 	"os"
 	"os/exec"
+	"sort"
 	"strings"
+	"sync"
 	"text/template"
 	"time"
 
@@ -59,10 +61,15 @@ frontend stats
 {{ range .Frontends }}
 # ── Frontend: {{ .Name }} ─────────────────────────────────────────────────
 frontend {{ .Name }}_frontend
-    bind {{ .Bind }}{{ if .TLS }} ssl crt {{ .TLS.Cert }}{{ if .TLS.MinVersion }} {{ .TLS.MinVersion }}{{ end }}{{ end }}
+    bind {{ .Bind }}{{ if .AcceptProxy }} accept-proxy{{ end }}{{ if .TLS }} ssl crt {{ .TLS.Cert }}{{ if .TLS.MinVersion }} {{ .TLS.MinVersion }}{{ end }}{{ end }}
     mode {{ .Mode }}
     default_backend {{ .Name }}_backend
     option {{ if eq .Mode "http" }}httplog{{ else }}tcplog{{ end }}
+    {{ if $.AccessLogAddr }}
+    log global
+    log {{ $.AccessLogAddr }} local2
+    log-format "{{ if eq .Mode "http" }}{{ $.HTTPLogFormat }}{{ else }}{{ $.TCPLogFormat }}{{ end }}"
+    {{ end }}
     maxconn {{ if gt .MaxConn 0 }}{{ .MaxConn }}{{ else }}10000{{ end }}
     {{ if eq .Mode "http" }}
     http-request set-header X-Forwarded-Proto https if { ssl_fc }
@@ -75,26 +82,51 @@ backend {{ .Name }}_backend
     {{ if .HealthCheck }}
     option {{ if eq .Mode "http" }}httpchk GET {{ .HealthCheck.Path }}{{ else }}tcp-check{{ end }}
     {{ end }}
+    {{ range .ErrorFiles }}
+    errorfile {{ .Code }} {{ .Path }}
+    {{ end }}
+    {{ $proxy := .ProxyProtocol }}{{ $hc := .HealthCheck }}{{ $maint := .Maintenance }}
     {{ range .Servers }}
-    server {{ .Name }} {{ .Address }} weight {{ .Weight }}{{ if gt .MaxConn 0 }} maxconn {{ .MaxConn }}{{ end }}{{ if .Backup }} backup{{ end }} check{{ if $.HealthCheck }} inter {{ $.HealthCheck.Interval }} rise {{ $.HealthCheck.Rise }} fall {{ $.HealthCheck.Fall }}{{ end }}
+    server {{ .Name }} {{ .Address }} weight {{ .Weight }}{{ if gt .MaxConn 0 }} maxconn {{ .MaxConn }}{{ end }}{{ if .Backup }} backup{{ end }}{{ if eq $proxy "v1" }} send-proxy{{ else if eq $proxy "v2" }} send-proxy-v2{{ end }} check{{ if $hc }} inter {{ $hc.Interval }} rise {{ $hc.Rise }} fall {{ $hc.Fall }}{{ end }}{{ if $maint }} disabled{{ end }}
+    {{ end }}
+    {{ if gt .DiscoverySlots 0 }}
+    server-template {{ .Name }}_sd 1-{{ .DiscoverySlots }} 127.0.0.1:1 weight 1{{ if eq $proxy "v1" }} send-proxy{{ else if eq $proxy "v2" }} send-proxy-v2{{ end }} check{{ if $hc }} inter {{ $hc.Interval }} rise {{ $hc.Rise }} fall {{ $hc.Fall }}{{ end }} disabled
     {{ end }}
 {{ end }}
 `
 
 // Adapter manages HAProxy configuration.
 type Adapter struct {
-	configPath  string
-	statsSocket string
-	statsPass   string
-	log         *zap.Logger
+	configPath    string
+	statsSocket   string
+	statsPass     string
+	accessLogAddr string // host:port of the AccessLogCollector; "" disables
+	udpConfigPath string // nginx stream snippet for UDP frontends
+	errorsDir     string // rendered errorfiles, one per backend and status
+	log           *zap.Logger
+
+	reload func() error // set when AegisX runs HAProxy itself; see SetReloader
+
+	maintMu     sync.Mutex
+	maintenance map[string]bool // policy names of backends in maintenance
+
+	discMu     sync.Mutex
+	watchers   map[string]*discoveryWatcher
+	discovered map[string][]Endpoint
 }
 
-func NewAdapter(configPath, statsSocket, statsPass string, log *zap.Logger) *Adapter {
+func NewAdapter(configPath, statsSocket, statsPass, accessLogAddr, udpConfigPath, errorsDir string, log *zap.Logger) *Adapter {
 	return &Adapter{
-		configPath:  configPath,
-		statsSocket: statsSocket,
-		statsPass:   statsPass,
-		log:         log,
+		configPath:    configPath,
+		statsSocket:   statsSocket,
+		statsPass:     statsPass,
+		accessLogAddr: accessLogAddr,
+		udpConfigPath: udpConfigPath,
+		errorsDir:     errorsDir,
+		log:           log,
+		maintenance:   make(map[string]bool),
+		watchers:      make(map[string]*discoveryWatcher),
+		discovered:    make(map[string][]Endpoint),
 	}
 }
 
@@ -105,6 +137,11 @@ func (a *Adapter) Apply(ir *policy.IR) error {
 		return fmt.Errorf("generate config: %w", err)
 	}
 
+	// Errorfiles must exist before HAProxy can validate the config.
+	if err := a.writeErrorPages(ir); err != nil {
+		return err
+	}
+
 	// Validate before writing.
 	if err := a.validate(cfg); err != nil {
 		return fmt.Errorf("config validation: %w", err)
@@ -114,34 +151,61 @@ func (a *Adapter) Apply(ir *policy.IR) error {
 		return fmt.Errorf("write config: %w", err)
 	}
 
-	return a.Reload()
+	if err := a.applyUDP(ir); err != nil {
+		return fmt.Errorf("udp proxy: %w", err)
+	}
+
+	if err := a.Reload(); err != nil {
+		return err
+	}
+	a.syncDiscovery(ir)
+	return nil
 }
 
 // Generate produces a HAProxy config string from the IR.
 func (a *Adapter) Generate(ir *policy.IR) (string, error) {
+	type errorFile struct {
+		Code int
+		Path string
+	}
 	type frontendData struct {
-		Name        string
-		Bind        string
-		Mode        string
-		MaxConn     int
-		Algorithm   string
-		Servers     []policy.LBServer
-		HealthCheck *policy.LBHealthCheck
-		TLS         *policy.LBTLSConfig
+		Name           string
+		Bind           string
+		Mode           string
+		MaxConn        int
+		AcceptProxy    bool
+		Algorithm      string
+		Servers        []policy.LBServer
+		HealthCheck    *policy.LBHealthCheck
+		ProxyProtocol  string
+		ErrorFiles     []errorFile
+		Maintenance    bool
+		DiscoverySlots int // server-template slots; 0 without discovery
+		TLS            *policy.LBTLSConfig
 	}
 
 	data := struct {
 		Timestamp     string
 		StatsSocket   string
 		StatsPassword string
+		AccessLogAddr string
+		HTTPLogFormat string
+		TCPLogFormat  string
 		Frontends     []frontendData
 	}{
 		Timestamp:     time.Now().UTC().Format(time.RFC3339),
 		StatsSocket:   a.statsSocket,
 		StatsPassword: a.statsPass,
+		AccessLogAddr: a.accessLogAddr,
+		HTTPLogFormat: httpAccessLogFormat,
+		TCPLogFormat:  tcpAccessLogFormat,
 	}
 
 	for _, lb := range ir.LoadBalancers {
+		// HAProxy cannot balance UDP; those frontends go to the stream proxy.
+		if lb.Frontend.Mode == "udp" {
+			continue
+		}
 		algo := lb.Backend.Algorithm
 		if algo == "" {
 			algo = "roundrobin"
@@ -149,16 +213,32 @@ func (a *Adapter) Generate(ir *policy.IR) (string, error) {
 		// HAProxy uses "leastconn" not "least_conn"
 		algo = strings.ReplaceAll(algo, "_", "")
 
-		data.Frontends = append(data.Frontends, frontendData{
-			Name:        lb.Name,
-			Bind:        lb.Frontend.Bind,
-			Mode:        lb.Frontend.Mode,
-			MaxConn:     lb.Frontend.MaxConn,
-			Algorithm:   algo,
-			Servers:     lb.Backend.Servers,
-			HealthCheck: lb.Backend.HealthCheck,
-			TLS:         lb.TLS,
-		})
+		var errorFiles []errorFile
+		if lb.Frontend.Mode == "http" {
+			for code := range a.errorPages(lb) {
+				errorFiles = append(errorFiles, errorFile{Code: code, Path: a.errorPagePath(lb.Name, code)})
+			}
+			sort.Slice(errorFiles, func(i, j int) bool { return errorFiles[i].Code < errorFiles[j].Code })
+		}
+
+		fe := frontendData{
+			Name:          lb.Name,
+			Bind:          lb.Frontend.Bind,
+			Mode:          lb.Frontend.Mode,
+			MaxConn:       lb.Frontend.MaxConn,
+			AcceptProxy:   lb.Frontend.AcceptProxy,
+			Algorithm:     algo,
+			Servers:       lb.Backend.Servers,
+			HealthCheck:   lb.Backend.HealthCheck,
+			ProxyProtocol: lb.Backend.ProxyProtocol,
+			ErrorFiles:    errorFiles,
+			Maintenance:   a.InMaintenance(lb.Name),
+			TLS:           lb.TLS,
+		}
+		if d := lb.Backend.Discovery; d != nil {
+			fe.DiscoverySlots = discoverySlots(d)
+		}
+		data.Frontends = append(data.Frontends, fe)
 	}
 
 	tmpl, err := template.New("haproxy").Parse(haproxyTemplate)
@@ -172,8 +252,19 @@ func (a *Adapter) Generate(ir *policy.IR) (string, error) {
 	return buf.String(), nil
 }
 
+// SetReloader replaces the systemd reload with fn, for an HAProxy run by
+// the supervisor in master-worker mode.
+func (a *Adapter) SetReloader(fn func() error) { a.reload = fn }
+
 // Reload sends SIGUSR2 to HAProxy for zero-downtime reload.
 func (a *Adapter) Reload() error {
+	if a.reload != nil {
+		if err := a.reload(); err != nil {
+			return fmt.Errorf("reload failed: %w", err)
+		}
+		a.log.Info("HAProxy reloaded")
+		return nil
+	}
 	out, err := exec.Command("systemctl", "reload", "haproxy").CombinedOutput()
 	if err != nil {
 		// Try direct signal
@@ -214,6 +305,15 @@ type ServerStatus struct {
 	Sessions int64
 }
 
+// CheckConfig validates the rendered config file currently on disk.
+func (a *Adapter) CheckConfig() error {
+	out, err := exec.Command("haproxy", "-c", "-f", a.configPath).CombinedOutput()
+	if err != nil {
+		return fmt.Errorf("haproxy validation error: %w\n%s", err, out)
+	}
+	return nil
+}
+
 // validate runs `haproxy -c -f` to check syntax.
 func (a *Adapter) validate(cfg string) error {
 	tmpFile, err := os.CreateTemp("", "aegisx-haproxy-*.cfg")
@@ -0,0 +1,180 @@
+package lb
+
+import (
+	"bufio"
+	"fmt"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"go.uber.org/zap"
+
+	"github.com/aegisx/aegisx/internal/policy"
+)
+
+// defaultMaintenancePage is served with 503 when a backend in maintenance has
+// no errorPages[503] of its own.
+const defaultMaintenancePage = `<!DOCTYPE html>
+<html><head><meta charset="utf-8"><title>Service under maintenance</title></head>
+<body style="font-family:sans-serif;text-align:center;padding-top:10%">
+<h1>We'll be back shortly</h1>
+<p>This service is undergoing scheduled maintenance.</p>
+</body></html>
+`
+
+// SetMaintenance puts a backend's servers into (or out of) maintenance via
+// the runtime API and remembers the state so regenerated configs keep it.
+// Clients then receive the backend's 503 page. name is the policy name.
+func (a *Adapter) SetMaintenance(name string, on bool) error {
+	if err := checkName(name); err != nil {
+		return err
+	}
+	backend := name + "_backend"
+	servers, err := a.backendServers(backend)
+	if err != nil {
+		return err
+	}
+	if len(servers) == 0 {
+		return fmt.Errorf("backend %q not found", name)
+	}
+
+	state := "ready"
+	if on {
+		state = "maint"
+	}
+	for _, srv := range servers {
+		out, err := a.runtimeCommand(fmt.Sprintf("set server %s/%s state %s", backend, srv, state))
+		if err != nil {
+			return err
+		}
+		if out = strings.TrimSpace(out); out != "" {
+			return fmt.Errorf("set server %s/%s: %s", backend, srv, out)
+		}
+	}
+
+	a.maintMu.Lock()
+	if on {
+		a.maintenance[name] = true
+	} else {
+		delete(a.maintenance, name)
+	}
+	a.maintMu.Unlock()
+
+	a.log.Info("LB maintenance changed", zap.String("backend", name), zap.Bool("maintenance", on))
+	return nil
+}
+
+// RestoreMaintenance seeds the maintenance set without touching HAProxy; used
+// at startup before the first Apply renders servers as disabled.
+func (a *Adapter) RestoreMaintenance(names []string) {
+	a.maintMu.Lock()
+	defer a.maintMu.Unlock()
+	for _, n := range names {
+		a.maintenance[n] = true
+	}
+}
+
+// InMaintenance reports whether a backend is in maintenance.
+func (a *Adapter) InMaintenance(name string) bool {
+	a.maintMu.Lock()
+	defer a.maintMu.Unlock()
+	return a.maintenance[name]
+}
+
+// ─── Private helpers ──────────────────────────────────────────────────────
+
+// errorPagePath is where the rendered HAProxy errorfile for a backend lives.
+// Callers check name with checkName first.
+func (a *Adapter) errorPagePath(name string, code int) string {
+	return filepath.Join(a.errorsDir, fmt.Sprintf("%s-%d.http", name, code))
+}
+
+// checkName refuses a load balancer name that could lead a file path out of
+// errorsDir or add to a runtime API command.
+func checkName(name string) error {
+	if !policy.ValidLBName(name) {
+		return fmt.Errorf("invalid load balancer name %q", name)
+	}
+	return nil
+}
+
+// writeErrorPages turns each backend's HTML error pages into raw HTTP
+// responses, the format HAProxy's errorfile directive expects. Every HTTP
+// backend gets a 503 page so maintenance always has something to show.
+func (a *Adapter) writeErrorPages(ir *policy.IR) error {
+	if err := os.MkdirAll(a.errorsDir, 0755); err != nil {
+		return fmt.Errorf("create errors dir: %w", err)
+	}
+	for _, lb := range ir.LoadBalancers {
+		if lb.Frontend.Mode != "http" {
+			continue
+		}
+		if err := checkName(lb.Name); err != nil {
+			return err
+		}
+		for code, body := range a.errorPages(lb) {
+			resp := fmt.Sprintf("HTTP/1.1 %d %s\r\nContent-Type: text/html; charset=utf-8\r\nCache-Control: no-store\r\nConnection: close\r\nContent-Length: %d\r\n\r\n%s",
+				code, http.StatusText(code), len(body), body)
+			if err := os.WriteFile(a.errorPagePath(lb.Name, code), []byte(resp), 0644); err != nil {
+				return fmt.Errorf("write error page: %w", err)
+			}
+		}
+	}
+	return nil
+}
+
+// errorPages returns the HTML bodies configured for a backend, with the
+// default maintenance page unless it sets its own 503.
+func (a *Adapter) errorPages(lb policy.CompiledLoadBalancer) map[int]string {
+	pages := map[int]string{503: defaultMaintenancePage}
+	for code, body := range lb.Backend.ErrorPages {
+		pages[code] = body
+	}
+	return pages
+}
+
+// backendServers lists a backend's server names from the runtime API so that
+// maintenance works even for servers added since the last Apply.
+func (a *Adapter) backendServers(backend string) ([]string, error) {
+	out, err := a.runtimeCommand("show servers state " + backend)
+	if err != nil {
+		return nil, err
+	}
+	var servers []string
+	sc := bufio.NewScanner(strings.NewReader(out))
+	for sc.Scan() {
+		line := sc.Text()
+		if strings.HasPrefix(line, "#") {
+			continue
+		}
+		// be_id be_name srv_id srv_name ...
+		f := strings.Fields(line)
+		if len(f) > 4 && f[1] == backend {
+			servers = append(servers, f[3])
+		}
+	}
+	return servers, sc.Err()
+}
+
+func (a *Adapter) runtimeCommand(cmd string) (string, error) {
+	conn, err := net.DialTimeout("unix", a.statsSocket, 3*time.Second)
+	if err != nil {
+		return "", fmt.Errorf("connect to stats socket: %w", err)
+	}
+	defer conn.Close()
+
+	conn.SetDeadline(time.Now().Add(5 * time.Second))
+	if _, err := fmt.Fprintln(conn, cmd); err != nil {
+		return "", fmt.Errorf("runtime command: %w", err)
+	}
+	var sb strings.Builder
+	sc := bufio.NewScanner(conn)
+	for sc.Scan() {
+		sb.WriteString(sc.Text())
+		sb.WriteByte('\n')
+	}
+	return sb.String(), sc.Err()
+}
@@ -0,0 +1,154 @@
+package lb
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"os/exec"
+	"strings"
+	"text/template"
+	"time"
+
+	"go.uber.org/zap"
+
+	"github.com/aegisx/aegisx/internal/policy"
+)
+
+// UDP frontends are served by nginx's stream module: community HAProxy has no
+// UDP proxying. The rendered file holds upstream/server blocks only and must
+// be included from inside the host's `stream {}` block.
+const udpStreamTemplate = `# nginx stream configuration for UDP load balancing — generated by AegisX {{ .Timestamp }}
+# DO NOT EDIT MANUALLY
+{{ range $fe := .Frontends }}
+# ── UDP frontend: {{ .Name }} ─────────────────────────────────────────────
+upstream {{ .Name }}_udp {
+    {{- if .Balance }}
+    {{ .Balance }};
+    {{- end }}
+    {{- range .Servers }}
+    server {{ .Address }} weight={{ if gt .Weight 0 }}{{ .Weight }}{{ else }}1{{ end }}{{ if gt .MaxConn 0 }} max_conns={{ .MaxConn }}{{ end }}{{ if .Backup }} backup{{ end }}{{ $fe.FailOpts }};
+    {{- end }}
+}
+
+server {
+    listen {{ .Bind }} udp reuseport;
+    proxy_pass {{ .Name }}_udp;
+    {{- if .Responses }}
+    proxy_responses {{ .Responses }};
+    {{- end }}
+    {{- if .Timeout }}
+    proxy_timeout {{ .Timeout }};
+    {{- end }}
+    {{- if .ProxyProtocol }}
+    proxy_protocol on;
+    {{- end }}
+}
+{{ end }}`
+
+type udpFrontend struct {
+	Name          string
+	Bind          string
+	Balance       string
+	Servers       []policy.LBServer
+	Responses     string
+	Timeout       string
+	ProxyProtocol bool
+	FailOpts      string // passive health-check options per server
+}
+
+// GenerateUDP renders the stream config for every UDP frontend in the IR.
+func (a *Adapter) GenerateUDP(ir *policy.IR) (string, error) {
+	data := struct {
+		Timestamp string
+		Frontends []udpFrontend
+	}{
+		Timestamp: time.Now().UTC().Format(time.RFC3339),
+	}
+
+	for _, lb := range ir.LoadBalancers {
+		if lb.Frontend.Mode != "udp" {
+			continue
+		}
+		fe := udpFrontend{
+			Name:          lb.Name,
+			Bind:          strings.TrimPrefix(lb.Frontend.Bind, ":"), // nginx: "53" not ":53"
+			Balance:       udpBalance(lb.Backend.Algorithm),
+			Servers:       lb.Backend.Servers,
+			Timeout:       lb.Backend.Timeout,
+			ProxyProtocol: lb.Backend.ProxyProtocol != "",
+		}
+		if r := lb.Frontend.UDPResponses; r != nil {
+			fe.Responses = fmt.Sprintf("%d", *r)
+		}
+		// nginx OSS only has passive checks: map fall/interval onto
+		// max_fails/fail_timeout.
+		if hc := lb.Backend.HealthCheck; hc != nil && hc.Fall > 0 {
+			fe.FailOpts = fmt.Sprintf(" max_fails=%d", hc.Fall)
+			if hc.Interval != "" {
+				fe.FailOpts += " fail_timeout=" + hc.Interval
+			}
+		}
+		data.Frontends = append(data.Frontends, fe)
+	}
+
+	tmpl, err := template.New("udp").Parse(udpStreamTemplate)
+	if err != nil {
+		return "", err
+	}
+	var buf bytes.Buffer
+	if err := tmpl.Execute(&buf, data); err != nil {
+		return "", err
+	}
+	return buf.String(), nil
+}
+
+// applyUDP writes the stream config and reloads nginx. It is a no-op when
+// there are no UDP frontends now and none were rendered before.
+func (a *Adapter) applyUDP(ir *policy.IR) error {
+	if a.udpConfigPath == "" {
+		return nil
+	}
+
+	hasUDP := false
+	for _, lb := range ir.LoadBalancers {
+		if lb.Frontend.Mode == "udp" {
+			hasUDP = true
+			break
+		}
+	}
+	if _, err := os.Stat(a.udpConfigPath); !hasUDP && os.IsNotExist(err) {
+		return nil
+	}
+
+	cfg, err := a.GenerateUDP(ir)
+	if err != nil {
+		return fmt.Errorf("generate config: %w", err)
+	}
+	if err := os.WriteFile(a.udpConfigPath, []byte(cfg), 0640); err != nil {
+		return fmt.Errorf("write config: %w", err)
+	}
+
+	if out, err := exec.Command("nginx", "-t").CombinedOutput(); err != nil {
+		return fmt.Errorf("nginx validation error: %w\n%s", err, out)
+	}
+	if out, err := exec.Command("nginx", "-s", "reload").CombinedOutput(); err != nil {
+		return fmt.Errorf("nginx reload: %w (output: %s)", err, out)
+	}
+	a.log.Info("UDP stream proxy reloaded", zap.String("path", a.udpConfigPath))
+	return nil
+}
+
+// udpBalance maps policy algorithms onto nginx upstream directives;
+// round-robin is nginx's default and needs none.
+func udpBalance(algo string) string {
+	switch algo {
+	case "leastconn", "least_conn":
+		return "least_conn"
+	case "source":
+		return "hash $remote_addr consistent"
+	case "random":
+		return "random"
+	default:
+		return ""
+	}
+}
@@ -0,0 +1,70 @@
+package metrics
+
+import (
+	"strings"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// Info describes one metric AegisX exports. Dashboards and alert rules are
+// generated against the catalog, so they cannot refer to a metric the code
+// does not emit.
+type Info struct {
+	Name   string   `json:"name"` // fully qualified, e.g. aegisx_policy_apply_total
+	Type   string   `json:"type"` // counter | gauge | histogram
+	Help   string   `json:"help"`
+	Labels []string `json:"labels,omitempty"`
+}
+
+var catalog []Info
+
+// Catalog returns every metric registered by this package, in declaration
+// order.
+func Catalog() []Info {
+	return append([]Info(nil), catalog...)
+}
+
+// Lookup returns the metric with the given fully qualified name.
+func Lookup(name string) (Info, bool) {
+	for _, m := range catalog {
+		if m.Name == name {
+			return m, true
+		}
+	}
+	return Info{}, false
+}
+
+func record(typ, namespace, subsystem, name, help string, labels []string) {
+	var parts []string
+	for _, p := range []string{namespace, subsystem, name} {
+		if p != "" {
+			parts = append(parts, p)
+		}
+	}
+	catalog = append(catalog, Info{Name: strings.Join(parts, "_"), Type: typ, Help: help, Labels: labels})
+}
+
+func newCounter(o prometheus.CounterOpts) prometheus.Counter {
+	record("counter", o.Namespace, o.Subsystem, o.Name, o.Help, nil)
+	return prometheus.NewCounter(o)
+}
+
+func newCounterVec(o prometheus.CounterOpts, labels []string) *prometheus.CounterVec {
+	record("counter", o.Namespace, o.Subsystem, o.Name, o.Help, labels)
+	return prometheus.NewCounterVec(o, labels)
+}
+
+func newGauge(o prometheus.GaugeOpts) prometheus.Gauge {
+	record("gauge", o.Namespace, o.Subsystem, o.Name, o.Help, nil)
+	return prometheus.NewGauge(o)
+}
+
+func newGaugeVec(o prometheus.GaugeOpts, labels []string) *prometheus.GaugeVec {
+	record("gauge", o.Namespace, o.Subsystem, o.Name, o.Help, labels)
+	return prometheus.NewGaugeVec(o, labels)
+}
+
+func newHistogramVec(o prometheus.HistogramOpts, labels []string) *prometheus.HistogramVec {
+	record("histogram", o.Namespace, o.Subsystem, o.Name, o.Help, labels)
+	return prometheus.NewHistogramVec(o, labels)
+}
@@ -11,53 +11,148 @@ import (
 // AegisX Prometheus metrics registry.
 var (
 	// Policy operations
-	PolicyApplyTotal = prometheus.NewCounterVec(prometheus.CounterOpts{
+	PolicyApplyTotal = newCounterVec(prometheus.CounterOpts{
 		Namespace: "aegisx",
 		Subsystem: "policy",
 		Name:      "apply_total",
-		Help:      "Total number of policy apply operations.",
-	}, []string{"status"})
+		Help:      "Total number of policy apply operations, by status (success, failure, invalid) and tenant.",
+	}, []string{"status", "tenant"})
 
-	PolicyApplyDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+	PolicyApplyDuration = newHistogramVec(prometheus.HistogramOpts{
 		Namespace: "aegisx",
 		Subsystem: "policy",
 		Name:      "apply_duration_seconds",
-		Help:      "Duration of policy apply operations.",
+		Help:      "Duration of policy apply operations. Exemplars carry the trace or request ID.",
 		Buckets:   prometheus.DefBuckets,
-	}, []string{"status"})
+	}, []string{"status", "tenant"})
 
 	// Firewall rule counts
-	FirewallRulesActive = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+	FirewallRulesActive = newGaugeVec(prometheus.GaugeOpts{
 		Namespace: "aegisx",
 		Subsystem: "firewall",
 		Name:      "rules_active",
-		Help:      "Number of active firewall rules.",
-	}, []string{"chain"})
+		Help:      "Number of active firewall rules, by chain and the tenant whose apply installed them.",
+	}, []string{"chain", "tenant"})
 
-	FirewallRollbackTotal = prometheus.NewCounter(prometheus.CounterOpts{
+	FirewallRollbackTotal = newCounter(prometheus.CounterOpts{
 		Namespace: "aegisx",
 		Subsystem: "firewall",
 		Name:      "rollback_total",
 		Help:      "Total number of automatic rollbacks.",
 	})
 
+	FirewallScansDetectedTotal = newCounterVec(prometheus.CounterOpts{
+		Namespace: "aegisx",
+		Subsystem: "firewall",
+		Name:      "scans_detected_total",
+		Help:      "Sources flagged as port scanners, by configured response.",
+	}, []string{"action"})
+
+	FirewallVerifyTotal = newCounterVec(prometheus.CounterOpts{
+		Namespace: "aegisx",
+		Subsystem: "firewall",
+		Name:      "verify_total",
+		Help:      "Scheduled comparisons of the live ruleset with the applied IR, by result (ok, mismatch, error).",
+	}, []string{"result"})
+
 	// IDS alerts
-	IDSAlertsTotal = prometheus.NewCounterVec(prometheus.CounterOpts{
+	IDSAlertsTotal = newCounterVec(prometheus.CounterOpts{
 		Namespace: "aegisx",
 		Subsystem: "ids",
 		Name:      "alerts_total",
 		Help:      "Total IDS/IPS alerts generated.",
 	}, []string{"severity", "action"})
 
+	// IDS engine performance, from Suricata's dump-counters
+	IDSKernelPackets = newGauge(prometheus.GaugeOpts{
+		Namespace: "aegisx",
+		Subsystem: "ids",
+		Name:      "capture_kernel_packets",
+		Help:      "Packets seen by the capture layer since Suricata started.",
+	})
+
+	IDSKernelDrops = newGauge(prometheus.GaugeOpts{
+		Namespace: "aegisx",
+		Subsystem: "ids",
+		Name:      "capture_kernel_drops",
+		Help:      "Packets dropped by the kernel before Suricata saw them.",
+	})
+
+	IDSCaptureLossRatio = newGauge(prometheus.GaugeOpts{
+		Namespace: "aegisx",
+		Subsystem: "ids",
+		Name:      "capture_loss_ratio",
+		Help:      "Kernel drops divided by packets over the last polling interval.",
+	})
+
+	IDSCaptureLossEventsTotal = newCounter(prometheus.CounterOpts{
+		Namespace: "aegisx",
+		Subsystem: "ids",
+		Name:      "capture_loss_events_total",
+		Help:      "Times capture loss rose above the configured threshold.",
+	})
+
+	IDSDecoderCounters = newGaugeVec(prometheus.GaugeOpts{
+		Namespace: "aegisx",
+		Subsystem: "ids",
+		Name:      "decoder",
+		Help:      "Suricata decoder counters (packets, bytes, per-protocol totals).",
+	}, []string{"counter"})
+
+	IDSMemuseBytes = newGaugeVec(prometheus.GaugeOpts{
+		Namespace: "aegisx",
+		Subsystem: "ids",
+		Name:      "memuse_bytes",
+		Help:      "Memory used by Suricata components (flow, tcp, app-layer parsers).",
+	}, []string{"component"})
+
+	// Honeypot metrics
+	HoneypotProbesTotal = newCounterVec(prometheus.CounterOpts{
+		Namespace: "aegisx",
+		Subsystem: "honeypot",
+		Name:      "probes_total",
+		Help:      "Connections caught by the tarpit, by originally targeted port.",
+	}, []string{"port"})
+
+	HoneypotConnections = newGauge(prometheus.GaugeOpts{
+		Namespace: "aegisx",
+		Subsystem: "honeypot",
+		Name:      "connections",
+		Help:      "Connections currently held open by the tarpit.",
+	})
+
+	// Brute-force protection
+	AuthFailuresTotal = newCounterVec(prometheus.CounterOpts{
+		Namespace: "aegisx",
+		Subsystem: "ban",
+		Name:      "auth_failures_total",
+		Help:      "Authentication failures seen, by jail.",
+	}, []string{"jail"})
+
+	BansTotal = newCounterVec(prometheus.CounterOpts{
+		Namespace: "aegisx",
+		Subsystem: "ban",
+		Name:      "bans_total",
+		Help:      "Addresses banned, by jail.",
+	}, []string{"jail"})
+
+	// Retention
+	RetentionRowsDeleted = newCounterVec(prometheus.CounterOpts{
+		Namespace: "aegisx",
+		Subsystem: "retention",
+		Name:      "rows_deleted_total",
+		Help:      "Rows pruned by the retention job, by table.",
+	}, []string{"table"})
+
 	// API request metrics
-	APIRequestsTotal = prometheus.NewCounterVec(prometheus.CounterOpts{
+	APIRequestsTotal = newCounterVec(prometheus.CounterOpts{
 		Namespace: "aegisx",
 		Subsystem: "api",
 		Name:      "requests_total",
 		Help:      "Total API requests.",
 	}, []string{"method", "path", "status"})
 
-	APIRequestDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+	APIRequestDuration = newHistogramVec(prometheus.HistogramOpts{
 		Namespace: "aegisx",
 		Subsystem: "api",
 		Name:      "request_duration_seconds",
@@ -65,13 +160,132 @@ var (
 		Buckets:   []float64{.005, .01, .025, .05, .1, .25, .5, 1, 2.5},
 	}, []string{"method", "path"})
 
+	// Load balancer traffic, fed by the HAProxy access log collector
+	LBRequestsTotal = newCounterVec(prometheus.CounterOpts{
+		Namespace: "aegisx",
+		Subsystem: "lb",
+		Name:      "requests_total",
+		Help:      "Requests seen in HAProxy access logs.",
+	}, []string{"frontend", "backend", "code"})
+
+	LBRequestDuration = newHistogramVec(prometheus.HistogramOpts{
+		Namespace: "aegisx",
+		Subsystem: "lb",
+		Name:      "request_duration_seconds",
+		Help:      "Total request time reported by HAProxy.",
+		Buckets:   []float64{.005, .01, .025, .05, .1, .25, .5, 1, 2.5, 5, 10},
+	}, []string{"frontend", "backend"})
+
 	// VPN connections
-	VPNPeersConnected = prometheus.NewGauge(prometheus.GaugeOpts{
+	VPNPeersConnected = newGauge(prometheus.GaugeOpts{
 		Namespace: "aegisx",
 		Subsystem: "vpn",
 		Name:      "peers_connected",
 		Help:      "Number of connected WireGuard peers.",
 	})
+
+	// Health-check targets
+	HealthTargetUp = newGaugeVec(prometheus.GaugeOpts{
+		Namespace: "aegisx",
+		Subsystem: "health",
+		Name:      "target_up",
+		Help:      "1 if the health-check target is up, 0 if down.",
+	}, []string{"target"})
+
+	HealthProbeDuration = newHistogramVec(prometheus.HistogramOpts{
+		Namespace: "aegisx",
+		Subsystem: "health",
+		Name:      "probe_duration_seconds",
+		Help:      "Round-trip time of successful probes, by target.",
+		Buckets:   []float64{.001, .005, .01, .025, .05, .1, .25, .5, 1, 2.5},
+	}, []string{"target"})
+
+	HealthProbeFailuresTotal = newCounterVec(prometheus.CounterOpts{
+		Namespace: "aegisx",
+		Subsystem: "health",
+		Name:      "probe_failures_total",
+		Help:      "Probes that failed or timed out, by target.",
+	}, []string{"target"})
+
+	// Multi-WAN
+	WANUplinkActive = newGaugeVec(prometheus.GaugeOpts{
+		Namespace: "aegisx",
+		Subsystem: "wan",
+		Name:      "uplink_active",
+		Help:      "1 if the uplink receives new connections, 0 if it was failed over.",
+	}, []string{"uplink"})
+
+	WANFailoverTotal = newCounterVec(prometheus.CounterOpts{
+		Namespace: "aegisx",
+		Subsystem: "wan",
+		Name:      "failover_total",
+		Help:      "Number of times an uplink was taken out of service.",
+	}, []string{"uplink"})
+
+	WANPathSteersTotal = newCounterVec(prometheus.CounterOpts{
+		Namespace: "aegisx",
+		Subsystem: "wan",
+		Name:      "path_steers_total",
+		Help:      "Number of times a path was re-steered, by path and the uplink it moved to.",
+	}, []string{"path", "uplink"})
+
+	WANTestLatencySeconds = newGaugeVec(prometheus.GaugeOpts{
+		Namespace: "aegisx",
+		Subsystem: "wan",
+		Name:      "test_latency_seconds",
+		Help:      "Mean round-trip time measured by the last WAN test, by uplink.",
+	}, []string{"uplink"})
+
+	WANTestJitterSeconds = newGaugeVec(prometheus.GaugeOpts{
+		Namespace: "aegisx",
+		Subsystem: "wan",
+		Name:      "test_jitter_seconds",
+		Help:      "Mean variation between consecutive round trips of the last WAN test, by uplink.",
+	}, []string{"uplink"})
+
+	WANTestLossRatio = newGaugeVec(prometheus.GaugeOpts{
+		Namespace: "aegisx",
+		Subsystem: "wan",
+		Name:      "test_loss_ratio",
+		Help:      "Share of latency samples of the last WAN test that got no answer, by uplink.",
+	}, []string{"uplink"})
+
+	WANTestThroughput = newGaugeVec(prometheus.GaugeOpts{
+		Namespace: "aegisx",
+		Subsystem: "wan",
+		Name:      "test_throughput_bits_per_second",
+		Help:      "Throughput measured by the last WAN test, by uplink and direction (download|upload).",
+	}, []string{"uplink", "direction"})
+
+	// Supervised daemons
+	DaemonUp = newGaugeVec(prometheus.GaugeOpts{
+		Namespace: "aegisx",
+		Subsystem: "daemon",
+		Name:      "up",
+		Help:      "1 while a daemon run by the supervisor is running, 0 while it is restarting or stopped.",
+	}, []string{"daemon"})
+
+	DaemonRestartsTotal = newCounterVec(prometheus.CounterOpts{
+		Namespace: "aegisx",
+		Subsystem: "daemon",
+		Name:      "restarts_total",
+		Help:      "Times a supervised daemon exited on its own and was restarted.",
+	}, []string{"daemon"})
+
+	// Clock
+	TimeOffsetSeconds = newGauge(prometheus.GaugeOpts{
+		Namespace: "aegisx",
+		Subsystem: "time",
+		Name:      "offset_seconds",
+		Help:      "Offset of the system clock from NTP time as reported by chrony.",
+	})
+
+	TimeSynchronized = newGauge(prometheus.GaugeOpts{
+		Namespace: "aegisx",
+		Subsystem: "time",
+		Name:      "synchronized",
+		Help:      "1 if the clock is NTP-synchronised within the allowed skew, 0 otherwise.",
+	})
 )
 
 func init() {
@@ -80,10 +294,39 @@ func init() {
 		PolicyApplyDuration,
 		FirewallRulesActive,
 		FirewallRollbackTotal,
+		FirewallScansDetectedTotal,
+		FirewallVerifyTotal,
 		IDSAlertsTotal,
+		IDSKernelPackets,
+		IDSKernelDrops,
+		IDSCaptureLossRatio,
+		IDSCaptureLossEventsTotal,
+		IDSDecoderCounters,
+		IDSMemuseBytes,
+		HoneypotProbesTotal,
+		HoneypotConnections,
+		AuthFailuresTotal,
+		BansTotal,
+		RetentionRowsDeleted,
 		APIRequestsTotal,
 		APIRequestDuration,
+		LBRequestsTotal,
+		LBRequestDuration,
 		VPNPeersConnected,
+		HealthTargetUp,
+		HealthProbeDuration,
+		HealthProbeFailuresTotal,
+		WANUplinkActive,
+		WANFailoverTotal,
+		WANPathSteersTotal,
+		WANTestLatencySeconds,
+		WANTestJitterSeconds,
+		WANTestLossRatio,
+		WANTestThroughput,
+		DaemonUp,
+		DaemonRestartsTotal,
+		TimeOffsetSeconds,
+		TimeSynchronized,
 	)
 }
 
@@ -102,6 +345,8 @@ func NewServer(port int, path string) *Server {
 
 func (s *Server) Start() error {
 	mux := http.NewServeMux()
-	mux.Handle(s.path, promhttp.Handler())
+	// OpenMetrics is the only format that carries exemplars; scrapers that
+	// don't ask for it get the text format as before.
+	mux.Handle(s.path, promhttp.HandlerFor(prometheus.DefaultGatherer, promhttp.HandlerOpts{EnableOpenMetrics: true}))
 	return http.ListenAndServe(fmt.Sprintf(":%d", s.port), mux)
 }
@@ -0,0 +1,106 @@
+package metrics
+
+import (
+	"context"
+	"sync"
+	"time"
+	"unicode/utf8"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// Tenant label values for tenants outside the allowlist and for work done
+// on behalf of no tenant (startup, hot reload, health-driven re-applies).
+const (
+	TenantOther  = "other"
+	TenantSystem = "system"
+)
+
+var (
+	tenantMu    sync.RWMutex
+	tenantAllow = map[string]bool{}
+)
+
+// SetTenantAllowlist sets the tenant IDs that get their own label value.
+// Every other tenant is counted as TenantOther, which bounds the number of
+// series no matter how many tenants exist.
+func SetTenantAllowlist(ids []string) {
+	allow := make(map[string]bool, len(ids))
+	for _, id := range ids {
+		allow[id] = true
+	}
+	tenantMu.Lock()
+	tenantAllow = allow
+	tenantMu.Unlock()
+}
+
+// TenantLabel returns the label value for a tenant ID.
+func TenantLabel(id string) string {
+	if id == "" {
+		return TenantSystem
+	}
+	tenantMu.RLock()
+	defer tenantMu.RUnlock()
+	if tenantAllow[id] {
+		return id
+	}
+	return TenantOther
+}
+
+type ctxKey int
+
+const (
+	tenantKey ctxKey = iota
+	exemplarKey
+)
+
+// WithTenant attributes the metrics recorded under ctx to a tenant.
+func WithTenant(ctx context.Context, tenantID string) context.Context {
+	return context.WithValue(ctx, tenantKey, tenantID)
+}
+
+// TenantFrom returns the label value of the tenant ctx is attributed to.
+func TenantFrom(ctx context.Context) string {
+	id, _ := ctx.Value(tenantKey).(string)
+	return TenantLabel(id)
+}
+
+// maxExemplarID keeps an exemplar within the 128 runes Prometheus allows
+// for its labels; a longer ID would make the observation panic.
+const maxExemplarID = 64
+
+// WithTrace sets the ID that histogram observations under ctx link to as
+// an exemplar: the trace ID, or the request ID when there is no trace.
+func WithTrace(ctx context.Context, traceID, requestID string) context.Context {
+	var ex prometheus.Labels
+	switch {
+	case validExemplarID(traceID):
+		ex = prometheus.Labels{"trace_id": traceID}
+	case validExemplarID(requestID):
+		ex = prometheus.Labels{"request_id": requestID}
+	default:
+		return ctx
+	}
+	return context.WithValue(ctx, exemplarKey, ex)
+}
+
+func validExemplarID(id string) bool {
+	return id != "" && len(id) <= maxExemplarID && utf8.ValidString(id)
+}
+
+// ObserveApply records one policy apply that began at start.
+func ObserveApply(ctx context.Context, status string, start time.Time) {
+	tenant := TenantFrom(ctx)
+	PolicyApplyTotal.WithLabelValues(status, tenant).Inc()
+	observe(ctx, PolicyApplyDuration.WithLabelValues(status, tenant), time.Since(start).Seconds())
+}
+
+// observe records v with the exemplar carried by ctx, if any.
+func observe(ctx context.Context, o prometheus.Observer, v float64) {
+	ex, _ := ctx.Value(exemplarKey).(prometheus.Labels)
+	if eo, ok := o.(prometheus.ExemplarObserver); ok && ex != nil {
+		eo.ObserveWithExemplar(v, ex)
+		return
+	}
+	o.Observe(v)
+}
@@ -0,0 +1,125 @@
+// Package monitor runs the synthetic monitors managed through the API. Each
+// enabled monitor is handed to the firewall's health prober as a target of
+// the same name, so its state gates rules and WAN uplinks like a
+// HealthCheckPolicy target, feeds the health metrics and hooks, and is
+// recorded as a history of state changes.
+package monitor
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"go.uber.org/zap"
+
+	"github.com/aegisx/aegisx/internal/firewall"
+	"github.com/aegisx/aegisx/internal/health"
+	"github.com/aegisx/aegisx/internal/policy"
+	"github.com/aegisx/aegisx/internal/store"
+)
+
+// Manager keeps the firewall's monitors in line with the store.
+type Manager struct {
+	store *store.MonitorStore
+	fw    *firewall.Service
+	log   *zap.Logger
+
+	mu    sync.Mutex
+	names map[string]bool // enabled monitors handed to the firewall
+}
+
+// NewManager returns a Manager that records the state changes of the
+// monitors it loads.
+func NewManager(s *store.MonitorStore, fw *firewall.Service, log *zap.Logger) *Manager {
+	m := &Manager{store: s, fw: fw, log: log, names: make(map[string]bool)}
+	fw.OnHealthChange(m.record)
+	return m
+}
+
+// Store returns the backing store, for the API.
+func (m *Manager) Store() *store.MonitorStore { return m.store }
+
+// Load hands the enabled monitors to the firewall. Unchanged monitors keep
+// their state; changed ones start over as unknown.
+func (m *Manager) Load(ctx context.Context) error {
+	mons, err := m.store.List(ctx)
+	if err != nil {
+		return fmt.Errorf("load monitors: %w", err)
+	}
+	names := make(map[string]bool, len(mons))
+	targets := make([]policy.HealthTarget, 0, len(mons))
+	for _, mon := range mons {
+		if !mon.Enabled {
+			continue
+		}
+		names[mon.Name] = true
+		targets = append(targets, Target(mon))
+	}
+
+	m.mu.Lock()
+	m.names = names
+	m.mu.Unlock()
+	m.fw.SetMonitors(targets)
+	return nil
+}
+
+// Status returns the probe state of every loaded monitor by name.
+func (m *Manager) Status() map[string]health.TargetStatus {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	out := make(map[string]health.TargetStatus, len(m.names))
+	for _, st := range m.fw.HealthStatus() {
+		if m.names[st.Name] {
+			out[st.Name] = st
+		}
+	}
+	return out
+}
+
+// Target is the health target that probes mon.
+func Target(mon *store.Monitor) policy.HealthTarget {
+	return policy.HealthTarget{
+		Name:          mon.Name,
+		Type:          mon.Type,
+		Address:       mon.Address,
+		Interface:     mon.Interface,
+		Interval:      (time.Duration(mon.IntervalMs) * time.Millisecond).String(),
+		Timeout:       (time.Duration(mon.TimeoutMs) * time.Millisecond).String(),
+		Rise:          mon.Rise,
+		Fall:          mon.Fall,
+		ExpectStatus:  mon.ExpectStatus,
+		Query:         mon.Query,
+		RecordType:    strings.ToUpper(mon.RecordType),
+		ExpectAddress: mon.ExpectAddress,
+	}
+}
+
+// ─── Private helpers ──────────────────────────────────────────────────────
+
+// record stores a state change of a monitor; other health targets are the
+// policy's business.
+func (m *Manager) record(name string, up bool) {
+	m.mu.Lock()
+	ours := m.names[name]
+	m.mu.Unlock()
+	if !ours {
+		return
+	}
+
+	e := &store.MonitorEvent{Up: up, At: time.Now()}
+	for _, st := range m.fw.HealthStatus() {
+		if st.Name == name {
+			e.Error, e.LatencyMs = st.LastError, st.LatencyMs
+			if !st.LastChange.IsZero() {
+				e.At = st.LastChange
+			}
+		}
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if err := m.store.RecordEvent(ctx, name, e); err != nil {
+		m.log.Warn("record monitor state change", zap.String("monitor", name), zap.Error(err))
+	}
+}
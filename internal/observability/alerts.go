@@ -0,0 +1,90 @@
+package observability
+
+import "fmt"
+
+// RuleFile is a Prometheus rule file. It marshals to the YAML Prometheus
+// reads, and its JSON form loads as well, JSON being a subset of YAML.
+type RuleFile struct {
+	Groups []RuleGroup `json:"groups" yaml:"groups"`
+}
+
+type RuleGroup struct {
+	Name  string `json:"name"  yaml:"name"`
+	Rules []Rule `json:"rules" yaml:"rules"`
+}
+
+// Rule is an alerting rule.
+type Rule struct {
+	Alert       string            `json:"alert"                 yaml:"alert"`
+	Expr        string            `json:"expr"                  yaml:"expr"`
+	For         string            `json:"for,omitempty"         yaml:"for,omitempty"`
+	Labels      map[string]string `json:"labels"                yaml:"labels"`
+	Annotations map[string]string `json:"annotations"           yaml:"annotations"`
+}
+
+func alert(name, expr, forDur, severity, summary, desc string) Rule {
+	return Rule{
+		Alert:       name,
+		Expr:        expr,
+		For:         forDur,
+		Labels:      map[string]string{"severity": severity},
+		Annotations: map[string]string{"summary": summary, "description": desc},
+	}
+}
+
+func alertRules(g *gen) RuleFile {
+	applies := g.m("aegisx_policy_apply_total", "status", "tenant")
+	verify := g.m("aegisx_firewall_verify_total", "result")
+	return RuleFile{Groups: []RuleGroup{
+		{Name: "aegisx-policy", Rules: []Rule{
+			alert("AegisXPolicyApplyFailing",
+				fmt.Sprintf(`sum by (tenant) (increase(%s{status="failure"}[15m])) > 0`, applies),
+				"", "warning",
+				"Policy applies are failing for tenant {{ $labels.tenant }}",
+				"The dataplane or a pre-apply hook rejected {{ $value }} applies in the last 15 minutes; the previous ruleset is still in place."),
+			alert("AegisXPolicyApplySlow",
+				fmt.Sprintf(`histogram_quantile(0.95, sum by (le) (rate(%s[15m]))) > 10`,
+					g.m("aegisx_policy_apply_duration_seconds_bucket", "le")),
+				"15m", "warning",
+				"Policy applies are slow",
+				"The 95th percentile apply takes {{ $value | humanizeDuration }}. Exemplars on the duration histogram link to the slow requests."),
+			alert("AegisXRulesetDrift",
+				fmt.Sprintf(`increase(%s{result="mismatch"}[15m]) > 0`, verify),
+				"", "critical",
+				"The kernel ruleset differs from the applied policy",
+				"Scheduled verification found the live nftables table out of step with the last apply. Something outside AegisX changed it."),
+			alert("AegisXRulesetVerifyErrors",
+				fmt.Sprintf(`increase(%s{result="error"}[30m]) > 2`, verify),
+				"", "warning",
+				"Ruleset verification keeps failing",
+				"The live ruleset could not be read for comparison {{ $value }} times in 30 minutes."),
+		}},
+		{Name: "aegisx-dataplane", Rules: []Rule{
+			alert("AegisXIDSCaptureLoss",
+				fmt.Sprintf(`%s > 0.05`, g.m("aegisx_ids_capture_loss_ratio")),
+				"10m", "warning",
+				"Suricata is dropping packets",
+				"The kernel dropped {{ $value | humanizePercentage }} of packets before Suricata saw them."),
+			alert("AegisXHealthTargetDown",
+				fmt.Sprintf(`%s == 0`, g.m("aegisx_health_target_up", "target")),
+				"5m", "warning",
+				"Health target {{ $labels.target }} is down",
+				"Rules conditioned on the target have switched to their down state."),
+			alert("AegisXUplinkOutOfService",
+				fmt.Sprintf(`%s == 0`, g.m("aegisx_wan_uplink_active", "uplink")),
+				"5m", "critical",
+				"Uplink {{ $labels.uplink }} is out of service",
+				"New connections are no longer steered to the uplink."),
+			alert("AegisXDaemonDown",
+				fmt.Sprintf(`%s == 0`, g.m("aegisx_daemon_up", "daemon")),
+				"5m", "critical",
+				"Supervised daemon {{ $labels.daemon }} is not running",
+				"The supervisor keeps restarting it after a backoff; its log lines are in the AegisX log under the daemon field."),
+			alert("AegisXClockUnsynchronised",
+				fmt.Sprintf(`%s == 0`, g.m("aegisx_time_synchronized")),
+				"15m", "warning",
+				"The system clock is not NTP-synchronised",
+				"Scheduled firewall rules are evaluated against this clock, and applies of time-based rules are refused while it is off."),
+		}},
+	}}
+}
@@ -0,0 +1,65 @@
+package observability
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"path"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Paths of the provisioning files, relative to the deploy directory that
+// docker-compose mounts into Grafana and Prometheus.
+const (
+	DashboardDir      = "grafana/dashboards"
+	DashboardProvider = "grafana/provisioning/dashboards/aegisx.yaml"
+	AlertRulesFile    = "prometheus/alerts.yml"
+)
+
+const providerYAML = `# Generated by ` + "`aegisx-api observability`" + `; do not edit.
+apiVersion: 1
+providers:
+  - name: aegisx
+    folder: AegisX
+    type: file
+    disableDeletion: true
+    allowUiUpdates: false
+    options:
+      path: /var/lib/grafana/dashboards
+`
+
+// Files renders the bundle as provisioning files keyed by relative path.
+func (b *Bundle) Files() (map[string][]byte, error) {
+	files := map[string][]byte{DashboardProvider: []byte(providerYAML)}
+	for _, d := range b.Dashboards {
+		data, err := json.MarshalIndent(d, "", "  ")
+		if err != nil {
+			return nil, fmt.Errorf("dashboard %s: %w", d.UID, err)
+		}
+		files[path.Join(DashboardDir, d.UID+".json")] = append(data, '\n')
+	}
+
+	var buf bytes.Buffer
+	fmt.Fprintf(&buf, "# Generated by `aegisx-api observability` (version %d); do not edit.\n", b.Version)
+	enc := yaml.NewEncoder(&buf)
+	enc.SetIndent(2)
+	if err := enc.Encode(b.AlertRules); err != nil {
+		return nil, fmt.Errorf("alert rules: %w", err)
+	}
+	if err := enc.Close(); err != nil {
+		return nil, fmt.Errorf("alert rules: %w", err)
+	}
+	files[AlertRulesFile] = buf.Bytes()
+	return files, nil
+}
+
+// Dashboard returns the dashboard with the given uid.
+func (b *Bundle) Dashboard(uid string) (*Dashboard, bool) {
+	for _, d := range b.Dashboards {
+		if d.UID == uid {
+			return d, true
+		}
+	}
+	return nil, false
+}
@@ -0,0 +1,261 @@
+package observability
+
+import "fmt"
+
+// Dashboard is the subset of the Grafana dashboard JSON model the generated
+// dashboards use. It imports as is, or through file provisioning.
+type Dashboard struct {
+	UID           string     `json:"uid"`
+	Title         string     `json:"title"`
+	Tags          []string   `json:"tags"`
+	Version       int        `json:"version"`
+	SchemaVersion int        `json:"schemaVersion"`
+	Editable      bool       `json:"editable"`
+	Refresh       string     `json:"refresh"`
+	Time          TimeRange  `json:"time"`
+	Templating    Templating `json:"templating"`
+	Panels        []Panel    `json:"panels"`
+}
+
+type TimeRange struct {
+	From string `json:"from"`
+	To   string `json:"to"`
+}
+
+type Templating struct {
+	List []Variable `json:"list"`
+}
+
+// Variable is a dashboard template variable.
+type Variable struct {
+	Name       string      `json:"name"`
+	Label      string      `json:"label,omitempty"`
+	Type       string      `json:"type"` // datasource | query
+	Query      string      `json:"query"`
+	Datasource *Datasource `json:"datasource,omitempty"`
+	Multi      bool        `json:"multi,omitempty"`
+	IncludeAll bool        `json:"includeAll,omitempty"`
+	AllValue   string      `json:"allValue,omitempty"`
+	Refresh    int         `json:"refresh,omitempty"` // 2: on time range change
+}
+
+type Datasource struct {
+	Type string `json:"type"`
+	UID  string `json:"uid"`
+}
+
+type Panel struct {
+	ID          int         `json:"id"`
+	Type        string      `json:"type"` // timeseries | stat | row
+	Title       string      `json:"title"`
+	Description string      `json:"description,omitempty"`
+	GridPos     GridPos     `json:"gridPos"`
+	Datasource  *Datasource `json:"datasource,omitempty"`
+	Targets     []Target    `json:"targets,omitempty"`
+	FieldConfig FieldConfig `json:"fieldConfig"`
+}
+
+type GridPos struct {
+	H int `json:"h"`
+	W int `json:"w"`
+	X int `json:"x"`
+	Y int `json:"y"`
+}
+
+type Target struct {
+	RefID        string `json:"refId"`
+	Expr         string `json:"expr"`
+	LegendFormat string `json:"legendFormat,omitempty"`
+	Exemplar     bool   `json:"exemplar,omitempty"`
+}
+
+type FieldConfig struct {
+	Defaults FieldDefaults `json:"defaults"`
+}
+
+type FieldDefaults struct {
+	Unit string `json:"unit,omitempty"`
+}
+
+var promDS = &Datasource{Type: "prometheus", UID: "${datasource}"}
+
+// board lays out panels two to a row; rows span the full width.
+type board struct {
+	d    *Dashboard
+	x, y int
+}
+
+func newBoard(uid, title string, vars ...Variable) *board {
+	list := append([]Variable{{Name: "datasource", Label: "Data source", Type: "datasource", Query: "prometheus"}}, vars...)
+	return &board{d: &Dashboard{
+		UID:           uid,
+		Title:         title,
+		Tags:          []string{"aegisx"},
+		Version:       Version,
+		SchemaVersion: 39,
+		Editable:      true,
+		Refresh:       "30s",
+		Time:          TimeRange{From: "now-6h", To: "now"},
+		Templating:    Templating{List: list},
+	}}
+}
+
+func (b *board) row(title string) {
+	if b.x != 0 {
+		b.x, b.y = 0, b.y+8
+	}
+	b.add(Panel{Type: "row", Title: title, GridPos: GridPos{H: 1, W: 24, Y: b.y}})
+	b.y++
+}
+
+// panel adds a time series; unit is a Grafana unit id such as "s" or "ops".
+func (b *board) panel(title, unit, desc string, targets ...Target) {
+	b.add(Panel{
+		Type:        "timeseries",
+		Title:       title,
+		Description: desc,
+		GridPos:     GridPos{H: 8, W: 12, X: b.x, Y: b.y},
+		Datasource:  promDS,
+		Targets:     refIDs(targets),
+		FieldConfig: FieldConfig{Defaults: FieldDefaults{Unit: unit}},
+	})
+	if b.x == 0 {
+		b.x = 12
+	} else {
+		b.x, b.y = 0, b.y+8
+	}
+}
+
+func (b *board) add(p Panel) {
+	p.ID = len(b.d.Panels) + 1
+	b.d.Panels = append(b.d.Panels, p)
+}
+
+func refIDs(targets []Target) []Target {
+	for i := range targets {
+		targets[i].RefID = string(rune('A' + i))
+	}
+	return targets
+}
+
+func tenantVar(g *gen) Variable {
+	return Variable{
+		Name:       "tenant",
+		Label:      "Tenant",
+		Type:       "query",
+		Datasource: promDS,
+		Query:      fmt.Sprintf("label_values(%s, tenant)", g.m("aegisx_policy_apply_total", "tenant")),
+		Multi:      true,
+		IncludeAll: true,
+		AllValue:   ".*",
+		Refresh:    2,
+	}
+}
+
+func q(expr, legend string) Target { return Target{Expr: expr, LegendFormat: legend} }
+
+// quantile is the q-quantile of a histogram over the dashboard's rate
+// interval, grouped by the given label (or not at all).
+func quantile(phi float64, bucket, selector, by string) string {
+	group := "le"
+	if by != "" {
+		group = by + ", le"
+	}
+	return fmt.Sprintf("histogram_quantile(%g, sum by (%s) (rate(%s%s[$__rate_interval])))", phi, group, bucket, selector)
+}
+
+func dashboards(g *gen) []*Dashboard {
+	return []*Dashboard{policyDashboard(g), threatDashboard(g), networkDashboard(g)}
+}
+
+func policyDashboard(g *gen) *Dashboard {
+	b := newBoard("aegisx-policy", "AegisX / Policy and firewall", tenantVar(g))
+	tenant := `{tenant=~"$tenant"}`
+
+	b.row("Policy applies")
+	applies := g.m("aegisx_policy_apply_total", "status", "tenant")
+	b.panel("Applies by status", "short", "Policy applies per interval: success, failure (rejected by the dataplane or a hook) and invalid (failed to compile).",
+		q(fmt.Sprintf("sum by (status) (increase(%s%s[$__rate_interval]))", applies, tenant), "{{status}}"))
+	b.panel("Applies by tenant", "short", "Tenants outside metrics.tenant_labels are counted as other; startup and reload applies as system.",
+		q(fmt.Sprintf("sum by (tenant) (increase(%s%s[$__rate_interval]))", applies, tenant), "{{tenant}}"))
+	bucket := g.m("aegisx_policy_apply_duration_seconds_bucket", "le", "status", "tenant")
+	lat := Target{Expr: quantile(0.95, bucket, tenant, "status"), LegendFormat: "p95 {{status}}", Exemplar: true}
+	b.panel("Apply duration", "s", "Exemplars link slow applies to the trace or request that made them.",
+		lat, q(quantile(0.5, bucket, tenant, ""), "p50"))
+	failed := fmt.Sprintf(`sum by (tenant) (increase(%s{status!="success",tenant=~"$tenant"}[$__rate_interval]))`, applies)
+	b.panel("Failed applies by tenant", "short", "", q(failed, "{{tenant}}"))
+
+	b.row("Dataplane")
+	b.panel("Active rules by chain", "short", "Firewall rules installed by the last successful apply.",
+		q(fmt.Sprintf("sum by (chain, tenant) (%s)", g.m("aegisx_firewall_rules_active", "chain", "tenant")), "{{chain}} ({{tenant}})"))
+	b.panel("Ruleset verification", "short", "Scheduled comparisons of the kernel ruleset with the applied one; any mismatch means drift.",
+		q(fmt.Sprintf("sum by (result) (increase(%s[$__rate_interval]))", g.m("aegisx_firewall_verify_total", "result")), "{{result}}"))
+	return b.d
+}
+
+func threatDashboard(g *gen) *Dashboard {
+	b := newBoard("aegisx-threats", "AegisX / Threats")
+
+	b.row("Intrusion detection")
+	b.panel("IDS alerts", "short", "",
+		q(fmt.Sprintf("sum by (severity, action) (increase(%s[$__rate_interval]))", g.m("aegisx_ids_alerts_total", "severity", "action")), "{{severity}} {{action}}"))
+	b.panel("Capture loss", "percentunit", "Share of packets the kernel dropped before Suricata saw them.",
+		q(g.m("aegisx_ids_capture_loss_ratio"), "loss"))
+	b.panel("Kernel packets and drops", "pps", "",
+		q(fmt.Sprintf("rate(%s[$__rate_interval])", g.m("aegisx_ids_capture_kernel_packets")), "packets"),
+		q(fmt.Sprintf("rate(%s[$__rate_interval])", g.m("aegisx_ids_capture_kernel_drops")), "drops"))
+	b.panel("Suricata memory", "bytes", "",
+		q(fmt.Sprintf("sum by (component) (%s)", g.m("aegisx_ids_memuse_bytes", "component")), "{{component}}"))
+
+	b.row("Scans, brute force and tarpit")
+	b.panel("Port scans detected", "short", "",
+		q(fmt.Sprintf("sum by (action) (increase(%s[$__rate_interval]))", g.m("aegisx_firewall_scans_detected_total", "action")), "{{action}}"))
+	b.panel("Authentication failures and bans", "short", "",
+		q(fmt.Sprintf("sum by (jail) (increase(%s[$__rate_interval]))", g.m("aegisx_ban_auth_failures_total", "jail")), "failures {{jail}}"),
+		q(fmt.Sprintf("sum by (jail) (increase(%s[$__rate_interval]))", g.m("aegisx_ban_bans_total", "jail")), "bans {{jail}}"))
+	b.panel("Tarpit probes by port", "short", "",
+		q(fmt.Sprintf("topk(10, sum by (port) (increase(%s[$__rate_interval])))", g.m("aegisx_honeypot_probes_total", "port")), "{{port}}"))
+	b.panel("Tarpit connections held", "short", "",
+		q(g.m("aegisx_honeypot_connections"), "connections"))
+	return b.d
+}
+
+func networkDashboard(g *gen) *Dashboard {
+	b := newBoard("aegisx-network", "AegisX / Network")
+
+	b.row("Load balancer")
+	b.panel("Requests by status code", "reqps", "",
+		q(fmt.Sprintf("sum by (code) (rate(%s[$__rate_interval]))", g.m("aegisx_lb_requests_total", "code")), "{{code}}"))
+	bucket := g.m("aegisx_lb_request_duration_seconds_bucket", "le", "backend")
+	b.panel("Request duration p95 by backend", "s", "", q(quantile(0.95, bucket, "", "backend"), "{{backend}}"))
+
+	b.row("Uplinks and health")
+	b.panel("Uplinks in service", "short", "1 while the uplink takes new connections.",
+		q(g.m("aegisx_wan_uplink_active", "uplink"), "{{uplink}}"))
+	b.panel("Uplink failovers", "short", "",
+		q(fmt.Sprintf("sum by (uplink) (increase(%s[$__rate_interval]))", g.m("aegisx_wan_failover_total", "uplink")), "{{uplink}}"))
+	b.panel("WAN test latency and jitter", "s", "As of the last on-demand or scheduled WAN test.",
+		q(g.m("aegisx_wan_test_latency_seconds", "uplink"), "latency {{uplink}}"),
+		q(g.m("aegisx_wan_test_jitter_seconds", "uplink"), "jitter {{uplink}}"))
+	b.panel("WAN test throughput", "bps", "As of the last on-demand or scheduled WAN test.",
+		q(g.m("aegisx_wan_test_throughput_bits_per_second", "uplink", "direction"), "{{direction}} {{uplink}}"))
+	b.panel("Health targets up", "short", "Policy health targets and API-managed monitors.",
+		q(g.m("aegisx_health_target_up", "target"), "{{target}}"))
+	probe := g.m("aegisx_health_probe_duration_seconds_bucket", "le", "target")
+	b.panel("Probe latency p95 by target", "s", "", q(quantile(0.95, probe, "", "target"), "{{target}}"))
+	b.panel("Probe failures", "short", "",
+		q(fmt.Sprintf("sum by (target) (increase(%s[$__rate_interval]))", g.m("aegisx_health_probe_failures_total", "target")), "{{target}}"))
+	b.panel("VPN peers connected", "short", "",
+		q(g.m("aegisx_vpn_peers_connected"), "peers"))
+
+	b.row("Housekeeping")
+	b.panel("Supervised daemons", "short", "Daemons AegisX runs itself: 1 while running. Restarts count crashes.",
+		q(g.m("aegisx_daemon_up", "daemon"), "up {{daemon}}"),
+		q(fmt.Sprintf("sum by (daemon) (increase(%s[$__rate_interval]))", g.m("aegisx_daemon_restarts_total", "daemon")), "restarts {{daemon}}"))
+	b.panel("Clock offset", "s", "Offset from NTP time as reported by chrony; scheduled rules depend on it.",
+		q(g.m("aegisx_time_offset_seconds"), "offset"),
+		q(g.m("aegisx_time_synchronized"), "synchronized"))
+	b.panel("Rows pruned by retention", "short", "",
+		q(fmt.Sprintf("sum by (table) (increase(%s[$__rate_interval]))", g.m("aegisx_retention_rows_deleted_total", "table")), "{{table}}"))
+	return b.d
+}
@@ -0,0 +1,98 @@
+// Package observability generates the Grafana dashboards and Prometheus
+// alerting rules for the metrics in package metrics. Every query is checked
+// against metrics.Catalog when it is built, so a renamed or relabelled
+// metric breaks generation instead of leaving a silently empty panel.
+package observability
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+
+	"github.com/aegisx/aegisx/internal/metrics"
+	"github.com/aegisx/aegisx/pkg/version"
+)
+
+// Version is bumped whenever a dashboard or rule changes, so provisioned
+// copies can tell they are stale. Grafana sees it as the dashboard version.
+const Version = 4
+
+// Bundle is everything needed to provision monitoring for AegisX.
+type Bundle struct {
+	Version       int          `json:"version"`
+	AegisXVersion string       `json:"aegisxVersion"`
+	Dashboards    []*Dashboard `json:"dashboards"`
+	AlertRules    RuleFile     `json:"alertRules"`
+}
+
+var (
+	once   sync.Once
+	bundle *Bundle
+	genErr error
+)
+
+// Generate builds the bundle once and returns it on every call.
+func Generate() (*Bundle, error) {
+	once.Do(func() {
+		g := &gen{}
+		b := &Bundle{
+			Version:       Version,
+			AegisXVersion: version.Version,
+			Dashboards:    dashboards(g),
+			AlertRules:    alertRules(g),
+		}
+		if len(g.errs) > 0 {
+			genErr = fmt.Errorf("observability: queries refer to unknown metrics or labels:\n  - %s", strings.Join(g.errs, "\n  - "))
+			return
+		}
+		bundle = b
+	})
+	return bundle, genErr
+}
+
+// gen collects the metric references that do not match the catalog.
+type gen struct {
+	errs []string
+}
+
+// histogramSuffixes are the series a histogram exposes besides its name.
+var histogramSuffixes = []string{"_bucket", "_sum", "_count"}
+
+// m returns name after checking that it is an exported metric carrying
+// every label in labels. Histogram series are named with their suffix; only
+// _bucket has the le label.
+func (g *gen) m(name string, labels ...string) string {
+	info, ok := metrics.Lookup(name)
+	suffix := ""
+	if !ok {
+		for _, s := range histogramSuffixes {
+			if base := strings.TrimSuffix(name, s); base != name {
+				if hi, found := metrics.Lookup(base); found && hi.Type == "histogram" {
+					info, ok, suffix = hi, true, s
+				}
+			}
+		}
+	}
+	if !ok {
+		g.errs = append(g.errs, "metric "+name)
+		return name
+	}
+	for _, l := range labels {
+		if l == "le" && suffix == "_bucket" {
+			continue
+		}
+		if !hasLabel(info.Labels, l) {
+			g.errs = append(g.errs, fmt.Sprintf("label %s of %s", l, name))
+		}
+	}
+	return name
+}
+
+func hasLabel(labels []string, l string) bool {
+	for _, v := range labels {
+		if v == l {
+			return true
+		}
+	}
+	return false
+}
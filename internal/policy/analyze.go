@@ -0,0 +1,367 @@
+package policy
+
+import (
+	"fmt"
+	"net/netip"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Analysis warning codes.
+const (
+	WarnShadowed = "shadowed" // an earlier rule takes all of the rule's traffic
+	WarnConflict = "conflict" // two rules match the same traffic with opposite verdicts
+
+	// WarnUnknownInterface is a rule naming an interface the host does not
+	// have. It may be created later, e.g. by a VLAN or tunnel coming up, so
+	// the rule still applies.
+	WarnUnknownInterface = "unknown-interface"
+)
+
+// Warning is a finding of the analysis pass: the policy compiles and
+// applies, but likely not as its author meant.
+type Warning struct {
+	Code    string `json:"code"` // shadowed | conflict | unknown-interface
+	Chain   string `json:"chain"`
+	Rule    string `json:"rule"` // namespace/policy/rule that never applies
+	By      string `json:"by"`   // the earlier rule that takes its traffic
+	Message string `json:"message"`
+}
+
+// Analyze looks for firewall rules of ir that can never match because an
+// earlier rule in the same chain matches all of their traffic, and for
+// pairs of rules that match the same traffic with opposite verdicts. Rules
+// limited by a rate, a schedule, an expiry or a health condition do not
+// always match, so they shadow nothing; selectors whose elements are only
+// known at runtime, such as countries and FQDNs, shadow only the same
+// selector.
+func (v *Validator) Analyze(ir *IR) []Warning {
+	// A rule split by address family compiles to several rules; it is
+	// only shadowed if every part is.
+	parts := make(map[string]int)
+	for _, r := range ir.FirewallRules {
+		parts[r.Comment]++
+	}
+	covered := make(map[string]int)
+	first := make(map[string]*CompiledFirewallRule)
+
+	var warns []Warning
+	for j := range ir.FirewallRules {
+		later := &ir.FirewallRules[j]
+		for i := 0; i < j; i++ {
+			earlier := &ir.FirewallRules[i]
+			if earlier.Comment == later.Comment || ruleChain(earlier) != ruleChain(later) ||
+				earlier.NetNS != later.NetNS || !earlier.unconditional() || !ir.covers(earlier, later) {
+				continue
+			}
+			covered[later.Comment]++
+			if first[later.Comment] == nil {
+				first[later.Comment] = earlier
+			}
+			break
+		}
+		if covered[later.Comment] != parts[later.Comment] || first[later.Comment] == nil {
+			continue
+		}
+		by := first[later.Comment]
+		delete(first, later.Comment) // report each rule once
+		w := Warning{Code: WarnShadowed, Chain: ruleChain(later), Rule: later.Comment, By: by.Comment}
+		if later.unconditional() && opposite(by.Action, later.Action) && ir.covers(later, by) {
+			w.Code = WarnConflict
+			w.Message = fmt.Sprintf("rules %q (%s) and %q (%s) match the same traffic; %q comes first at priority %d, so %q never applies",
+				by.Comment, by.Action, later.Comment, later.Action, by.Comment, by.Priority, later.Comment)
+		} else {
+			w.Message = fmt.Sprintf("rule %q can never match: %q at priority %d matches all of its traffic first",
+				later.Comment, by.Comment, by.Priority)
+		}
+		warns = append(warns, w)
+	}
+	return warns
+}
+
+// checkInterfaces warns about the interfaces host rules name that are
+// neither among host's nor created by the IR's VPN, WAN and VRF policies.
+// Rules inside network namespaces are not checked.
+func checkInterfaces(ir *IR, host []string) []Warning {
+	known := append([]string(nil), host...)
+	for _, v := range ir.VPNConfigs {
+		known = append(known, v.Interface)
+	}
+	for _, v := range ir.VRFs {
+		known = append(known, v.Name)
+	}
+	if ir.WAN != nil {
+		for _, u := range ir.WAN.Uplinks {
+			known = append(known, u.Interface)
+		}
+	}
+	exists := func(name string) bool {
+		prefix, wild := strings.CutSuffix(name, "*")
+		for _, k := range known {
+			if k == name || (wild && strings.HasPrefix(k, prefix)) {
+				return true
+			}
+		}
+		return false
+	}
+
+	var warns []Warning
+	reported := make(map[string]bool)
+	for _, r := range ir.FirewallRules {
+		if r.NetNS != "" {
+			continue
+		}
+		for _, name := range append(append([]string(nil), r.InIfaces...), r.OutIfaces...) {
+			key := r.Comment + "\x00" + name
+			if exists(name) || reported[key] {
+				continue
+			}
+			reported[key] = true
+			warns = append(warns, Warning{
+				Code:    WarnUnknownInterface,
+				Chain:   ruleChain(&r),
+				Rule:    r.Comment,
+				Message: fmt.Sprintf("rule %q matches interface %q, which does not exist on this host", r.Comment, name),
+			})
+		}
+	}
+	return warns
+}
+
+// ─── Private helpers ──────────────────────────────────────────────────────
+
+// ruleChain is the chain the rule is installed in, as Simulate sees it.
+func ruleChain(r *CompiledFirewallRule) string {
+	switch {
+	case r.marking() && r.Chain == "output":
+		return "mark_output"
+	case r.marking():
+		return "mark_prerouting"
+	}
+	if r.UserChain != "" {
+		return r.UserChain
+	}
+	if r.Chain != "input" && r.Chain != "output" {
+		return "forward"
+	}
+	return r.Chain
+}
+
+// marking reports whether r sets a mark or DSCP rather than a verdict.
+func (r *CompiledFirewallRule) marking() bool {
+	return r.Action == "mark" || r.Action == "dscp"
+}
+
+// unconditional reports whether r ends the evaluation of every packet it
+// matches, at any time. Packets a jump or goto sends to a chain may come
+// back from it.
+func (r *CompiledFirewallRule) unconditional() bool {
+	switch r.Action {
+	case "log", "jump", "goto", "mark", "dscp":
+		return false
+	}
+	return r.RateLimit == "" && r.ConnLimit == 0 && r.When == nil && r.Schedule == nil && r.ExpiresAt == nil
+}
+
+// opposite reports whether one action lets traffic through and the other
+// does not.
+func opposite(a, b string) bool {
+	return (a == "accept") != (b == "accept")
+}
+
+// covers reports whether every packet b matches is also matched by a.
+func (ir *IR) covers(a, b *CompiledFirewallRule) bool {
+	if a.Protocol != "" && a.Protocol != b.Protocol {
+		return false
+	}
+	if a.Family != "" && a.Family != b.Family {
+		return false
+	}
+	if a.VRF != "" && a.VRF != b.VRF {
+		return false
+	}
+	if len(a.States) > 0 && (len(b.States) == 0 || !subset(b.States, a.States)) {
+		return false
+	}
+	if !coversIfaces(a.InIfaces, b.InIfaces) || !coversIfaces(a.OutIfaces, b.OutIfaces) {
+		return false
+	}
+	if len(a.SrcMACs) > 0 && (len(b.SrcMACs) == 0 || !subset(b.SrcMACs, a.SrcMACs)) {
+		return false
+	}
+	return ir.coversAddrs(a.SrcSet, a.SrcAddrs, b.SrcSet, b.SrcAddrs) &&
+		ir.coversAddrs(a.DstSet, a.DstAddrs, b.DstSet, b.DstAddrs) &&
+		ir.coversPorts(a.SrcPortSet, a.SrcPorts, b.SrcPortSet, b.SrcPorts) &&
+		ir.coversPorts(a.DstPortSet, a.DstPorts, b.DstPortSet, b.DstPorts)
+}
+
+// coversAddrs reports whether the addresses of selector a include all of
+// those of b. Sets filled at runtime are compared by name.
+func (ir *IR) coversAddrs(aSet string, aList []string, bSet string, bList []string) bool {
+	if aSet == "" && len(aList) == 0 {
+		return true
+	}
+	if ir.runtimeSet(aSet) || ir.runtimeSet(bSet) {
+		return aSet == bSet
+	}
+	b := ir.setOr(bSet, bList)
+	if len(b) == 0 {
+		return false
+	}
+	return rangesCover(addrRanges(ir.setOr(aSet, aList)), addrRanges(b))
+}
+
+// coversPorts reports whether the ports of selector a include all of those
+// of b.
+func (ir *IR) coversPorts(aSet string, aList []string, bSet string, bList []string) bool {
+	a := ir.setOr(aSet, aList)
+	if len(a) == 0 {
+		return true
+	}
+	b := ir.setOr(bSet, bList)
+	if len(b) == 0 {
+		return false
+	}
+	return rangesCover(portRanges(a), portRanges(b))
+}
+
+// coversIfaces reports whether the interface names a match every
+// interface that b does. A wildcard covers the names and wildcards with
+// its prefix.
+func coversIfaces(a, b []string) bool {
+	if len(a) == 0 {
+		return true
+	}
+	if len(b) == 0 {
+		return false
+	}
+	for _, n := range b {
+		ok := false
+		for _, m := range a {
+			if prefix, wild := strings.CutSuffix(m, "*"); wild {
+				ok = strings.HasPrefix(n, prefix)
+			} else {
+				ok = m == n
+			}
+			if ok {
+				break
+			}
+		}
+		if !ok {
+			return false
+		}
+	}
+	return true
+}
+
+// runtimeSet reports whether set is filled at runtime rather than compiled.
+func (ir *IR) runtimeSet(set string) bool {
+	if _, ok := ir.CountrySet(set); ok {
+		return true
+	}
+	_, ok := ir.FQDNSet(set)
+	return ok
+}
+
+// span is an inclusive range of addresses or ports.
+type span struct{ lo, hi netip.Addr }
+
+// addrRanges converts addresses, CIDRs and "a-b" ranges to spans. Entries
+// that do not parse are left out.
+func addrRanges(list []string) []span {
+	var out []span
+	for _, s := range list {
+		if lo, hi, ok := strings.Cut(s, "-"); ok {
+			from, err1 := netip.ParseAddr(strings.TrimSpace(lo))
+			to, err2 := netip.ParseAddr(strings.TrimSpace(hi))
+			if err1 == nil && err2 == nil && from.BitLen() == to.BitLen() {
+				out = append(out, span{from, to})
+			}
+			continue
+		}
+		if p, err := netip.ParsePrefix(s); err == nil {
+			out = append(out, span{p.Masked().Addr(), lastAddr(p)})
+			continue
+		}
+		if a, err := netip.ParseAddr(s); err == nil {
+			out = append(out, span{a, a})
+		}
+	}
+	return out
+}
+
+// portRanges converts "80" and "8080-8090" entries to spans, with each
+// port encoded as an IPv4 address so one comparison serves both.
+func portRanges(list []string) []span {
+	var out []span
+	for _, s := range list {
+		lo, hi, ok := strings.Cut(s, "-")
+		if !ok {
+			hi = lo
+		}
+		from, err1 := strconv.Atoi(lo)
+		to, err2 := strconv.Atoi(hi)
+		if err1 == nil && err2 == nil {
+			out = append(out, span{portAddr(from), portAddr(to)})
+		}
+	}
+	return out
+}
+
+func portAddr(p int) netip.Addr {
+	return netip.AddrFrom4([4]byte{0, 0, byte(p >> 8), byte(p)})
+}
+
+// lastAddr returns the highest address of p.
+func lastAddr(p netip.Prefix) netip.Addr {
+	b := p.Masked().Addr().AsSlice()
+	for i := p.Bits(); i < len(b)*8; i++ {
+		b[i/8] |= 0x80 >> (i % 8)
+	}
+	a, _ := netip.AddrFromSlice(b)
+	return a
+}
+
+// rangesCover reports whether every span of b lies within the union of the
+// spans of a. Spans of different families never overlap.
+func rangesCover(a, b []span) bool {
+	if len(b) == 0 {
+		return false
+	}
+	sort.Slice(a, func(i, j int) bool { return a[i].lo.Less(a[j].lo) })
+	var merged []span
+	for _, s := range a {
+		if n := len(merged); n > 0 && merged[n-1].lo.BitLen() == s.lo.BitLen() &&
+			(s.lo.Compare(merged[n-1].hi) <= 0 || s.lo == merged[n-1].hi.Next()) {
+			if merged[n-1].hi.Less(s.hi) {
+				merged[n-1].hi = s.hi
+			}
+			continue
+		}
+		merged = append(merged, s)
+	}
+	for _, s := range b {
+		ok := false
+		for _, m := range merged {
+			if m.lo.BitLen() == s.lo.BitLen() && m.lo.Compare(s.lo) <= 0 && s.hi.Compare(m.hi) <= 0 {
+				ok = true
+				break
+			}
+		}
+		if !ok {
+			return false
+		}
+	}
+	return true
+}
+
+// subset reports whether every element of a is in b.
+func subset(a, b []string) bool {
+	for _, s := range a {
+		if !contains(b, s) {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,75 @@
+package policy
+
+import (
+	"fmt"
+	"hash/fnv"
+	"sort"
+	"strings"
+)
+
+// maxListIdent bounds the part of a set name made of the listed countries
+// or names; longer lists are named after a hash of it.
+const maxListIdent = 48
+
+// countrySets returns the sets matching the given countries, declaring them
+// on first use. Selectors listing the same countries share their sets.
+func (gs *groupSets) countrySets(countries []string) familySets {
+	codes := countryCodes(countries)
+	if len(codes) == 0 {
+		return familySets{}
+	}
+	ident := listIdent(strings.ToLower(strings.Join(codes, "_")))
+	fs := familySets{v4: "geo_" + ident, v6: "geo6_" + ident}
+	if _, ok := gs.countryIdx[ident]; !ok {
+		gs.countryIdx[ident] = fs
+		gs.countries = append(gs.countries,
+			CompiledCountrySet{Set: fs.v4, Family: FamilyIPv4, Countries: codes},
+			CompiledCountrySet{Set: fs.v6, Family: FamilyIPv6, Countries: codes})
+	}
+	return fs
+}
+
+// listIdent returns ident, or a hash of it when it is too long for a set
+// name.
+func listIdent(ident string) string {
+	if len(ident) <= maxListIdent {
+		return ident
+	}
+	return hashIdent(ident)
+}
+
+func hashIdent(ident string) string {
+	h := fnv.New32a()
+	h.Write([]byte(ident))
+	return fmt.Sprintf("%08x", h.Sum32())
+}
+
+// countryCodes upper-cases, sorts and deduplicates ISO country codes.
+func countryCodes(countries []string) []string {
+	seen := make(map[string]bool, len(countries))
+	var codes []string
+	for _, c := range countries {
+		c = strings.ToUpper(strings.TrimSpace(c))
+		if c == "" || seen[c] {
+			continue
+		}
+		seen[c] = true
+		codes = append(codes, c)
+	}
+	sort.Strings(codes)
+	return codes
+}
+
+// validCountry reports whether c looks like an ISO 3166-1 alpha-2 code.
+// Whether the GeoIP database knows the country is only found out at runtime.
+func validCountry(c string) bool {
+	if len(c) != 2 {
+		return false
+	}
+	for _, r := range strings.ToUpper(c) {
+		if r < 'A' || r > 'Z' {
+			return false
+		}
+	}
+	return true
+}
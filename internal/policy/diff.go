@@ -0,0 +1,218 @@
+package policy
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+)
+
+// IR change operations.
+const (
+	ChangeAdded    = "added"
+	ChangeRemoved  = "removed"
+	ChangeModified = "modified"
+)
+
+// IRDiff is what applying one IR in place of another changes, item by
+// item: rules, NAT rules, sets, load balancers and so on.
+type IRDiff struct {
+	Changes []Change    `json:"changes"`
+	Summary DiffSummary `json:"summary"`
+}
+
+// DiffSummary counts the changes of an IRDiff.
+type DiffSummary struct {
+	Added    int `json:"added"`
+	Removed  int `json:"removed"`
+	Modified int `json:"modified"`
+}
+
+// Change is one item of an IR added, removed or modified. Items are told
+// apart by their comment (namespace/policy/rule), name or set, as Key
+// shows; Before and After hold the whole item, and for a modified one
+// Fields lists each field that differs.
+type Change struct {
+	Section string        `json:"section"` // the IR field, e.g. firewallRules
+	Op      string        `json:"op"`      // added | removed | modified
+	Key     string        `json:"key"`
+	Fields  []FieldChange `json:"fields,omitempty"`
+	Before  any           `json:"before,omitempty"`
+	After   any           `json:"after,omitempty"`
+}
+
+// FieldChange is a field of a modified item, under its JSON name.
+type FieldChange struct {
+	Field  string `json:"field"`
+	Before any    `json:"before"`
+	After  any    `json:"after"`
+}
+
+// DiffIR compares the items of every section of two IRs; a nil IR has
+// none. The identity, version and analysis warnings of an IR are not
+// compared. Changes follow the order of the sections, and within one the
+// order of after, with the items only before has last.
+func DiffIR(before, after *IR) *IRDiff {
+	if before == nil {
+		before = &IR{}
+	}
+	if after == nil {
+		after = &IR{}
+	}
+	d := &IRDiff{Changes: []Change{}}
+	bv, av := reflect.ValueOf(before).Elem(), reflect.ValueOf(after).Elem()
+	t := bv.Type()
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		name, _, _ := strings.Cut(f.Tag.Get("json"), ",")
+		switch name {
+		case "id", "version", "createdAt", "warnings", "":
+			continue
+		}
+		d.section(name, bv.Field(i), av.Field(i))
+	}
+	for _, c := range d.Changes {
+		switch c.Op {
+		case ChangeAdded:
+			d.Summary.Added++
+		case ChangeRemoved:
+			d.Summary.Removed++
+		case ChangeModified:
+			d.Summary.Modified++
+		}
+	}
+	return d
+}
+
+// section diffs one field of the IR. Slices are compared item by item;
+// anything else, e.g. the WAN, as a single item.
+func (d *IRDiff) section(name string, before, after reflect.Value) {
+	if before.Kind() != reflect.Slice {
+		switch {
+		case isEmpty(before) && isEmpty(after):
+		case isEmpty(before):
+			d.Changes = append(d.Changes, Change{Section: name, Op: ChangeAdded, Key: name, After: after.Interface()})
+		case isEmpty(after):
+			d.Changes = append(d.Changes, Change{Section: name, Op: ChangeRemoved, Key: name, Before: before.Interface()})
+		default:
+			if fields := diffFields(before, after); len(fields) > 0 {
+				d.Changes = append(d.Changes, Change{Section: name, Op: ChangeModified, Key: name, Fields: fields,
+					Before: before.Interface(), After: after.Interface()})
+			}
+		}
+		return
+	}
+
+	// Items sharing a key, such as the rules of a policy's default action
+	// in both chains, are paired in order.
+	old := make(map[string][]int)
+	for i := 0; i < before.Len(); i++ {
+		k := itemKey(before.Index(i), i)
+		old[k] = append(old[k], i)
+	}
+	matched := make([]bool, before.Len())
+	for j := 0; j < after.Len(); j++ {
+		a := after.Index(j)
+		k := itemKey(a, j)
+		if len(old[k]) == 0 {
+			d.Changes = append(d.Changes, Change{Section: name, Op: ChangeAdded, Key: k, After: a.Interface()})
+			continue
+		}
+		i := old[k][0]
+		old[k] = old[k][1:]
+		matched[i] = true
+		b := before.Index(i)
+		if fields := diffFields(b, a); len(fields) > 0 {
+			d.Changes = append(d.Changes, Change{Section: name, Op: ChangeModified, Key: k, Fields: fields,
+				Before: b.Interface(), After: a.Interface()})
+		}
+	}
+	for i := 0; i < before.Len(); i++ {
+		if !matched[i] {
+			b := before.Index(i)
+			d.Changes = append(d.Changes, Change{Section: name, Op: ChangeRemoved, Key: itemKey(b, i), Before: b.Interface()})
+		}
+	}
+}
+
+// keyFields name the items of each section, tried in order.
+var keyFields = []string{"Comment", "Name", "Set", "Policy", "Interface"}
+
+// itemKey returns the key of the item v at index i of its section: its
+// first non-empty key field, or else its index. A family tells apart the
+// halves of a dual-stack rule, and a chain the raw rules of one policy.
+func itemKey(v reflect.Value, i int) string {
+	for v.Kind() == reflect.Pointer && !v.IsNil() {
+		v = v.Elem()
+	}
+	if v.Kind() != reflect.Struct {
+		return fmt.Sprintf("#%d", i)
+	}
+	key, field := "", ""
+	for _, name := range keyFields {
+		if f := v.FieldByName(name); f.IsValid() && f.Kind() == reflect.String && f.String() != "" {
+			key, field = f.String(), name
+			break
+		}
+	}
+	if key == "" {
+		return fmt.Sprintf("#%d", i)
+	}
+	if field == "Policy" {
+		if f := v.FieldByName("Chain"); f.IsValid() && f.Kind() == reflect.String {
+			key += " " + f.String()
+		}
+	}
+	if f := v.FieldByName("Family"); f.IsValid() && f.Kind() == reflect.String && f.String() != "" {
+		key += " (" + f.String() + ")"
+	}
+	return key
+}
+
+// diffFields lists the fields of the structs a and b that differ, by JSON
+// name; values that are not structs differ as a whole.
+func diffFields(a, b reflect.Value) []FieldChange {
+	for a.Kind() == reflect.Pointer && b.Kind() == reflect.Pointer && !a.IsNil() && !b.IsNil() {
+		a, b = a.Elem(), b.Elem()
+	}
+	if a.Kind() != reflect.Struct {
+		if same(a, b) {
+			return nil
+		}
+		return []FieldChange{{Field: "value", Before: a.Interface(), After: b.Interface()}}
+	}
+	var out []FieldChange
+	t := a.Type()
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		name, _, _ := strings.Cut(f.Tag.Get("json"), ",")
+		if !f.IsExported() || name == "-" {
+			continue
+		}
+		if name == "" {
+			name = f.Name
+		}
+		if !same(a.Field(i), b.Field(i)) {
+			out = append(out, FieldChange{Field: name, Before: a.Field(i).Interface(), After: b.Field(i).Interface()})
+		}
+	}
+	return out
+}
+
+// same reports whether a and b are deeply equal, taking nil and empty
+// slices and maps as equal, as they encode alike to the backends.
+func same(a, b reflect.Value) bool {
+	if isEmpty(a) && isEmpty(b) {
+		return true
+	}
+	return reflect.DeepEqual(a.Interface(), b.Interface())
+}
+
+func isEmpty(v reflect.Value) bool {
+	switch v.Kind() {
+	case reflect.Slice, reflect.Map:
+		return v.Len() == 0
+	case reflect.Pointer, reflect.Interface:
+		return v.IsNil()
+	}
+	return false
+}
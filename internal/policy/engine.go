@@ -2,8 +2,10 @@ package policy
 
 import (
 	"fmt"
+	"net"
 	"sort"
 	"strconv"
+	"strings"
 	"time"
 
 	"github.com/google/uuid"
@@ -12,6 +14,9 @@ import (
 // Engine compiles a slice of Manifests into an IR.
 type Engine struct {
 	validator *Validator
+	limits    Limits
+	external  func(name string) bool // health targets defined outside the manifests
+	ifaces    func() []string        // the host's interfaces; nil, or a nil list, skips the check
 }
 
 func NewEngine() *Engine {
@@ -24,20 +29,28 @@ func (e *Engine) Compile(manifests []*Manifest) (*IR, error) {
 		return nil, fmt.Errorf("validation failed: %w", err)
 	}
 
+	var tests []*Manifest
 	ir := &IR{
 		ID:        uuid.NewString(),
 		Version:   time.Now().UnixMilli(),
 		CreatedAt: time.Now(),
 	}
 
+	groups, addrGroups, svcGroups, err := compileGroups(manifests)
+	if err != nil {
+		return nil, err
+	}
+	ir.AddressGroups, ir.ServiceGroups = addrGroups, svcGroups
+
 	for _, m := range manifests {
 		switch m.Kind {
 		case KindFirewallPolicy:
-			rules, err := e.compileFirewall(m)
+			rules, err := e.compileFirewall(m, groups)
 			if err != nil {
 				return nil, fmt.Errorf("compiling firewall policy %s: %w", m.Metadata.Name, err)
 			}
 			ir.FirewallRules = append(ir.FirewallRules, rules...)
+			ir.RawRules = append(ir.RawRules, CompileRawRules(m)...)
 
 		case KindNATPolicy:
 			rules, err := e.compileNAT(m)
@@ -66,24 +79,210 @@ func (e *Engine) Compile(manifests []*Manifest) (*IR, error) {
 				return nil, err
 			}
 			ir.IDSRules = append(ir.IDSRules, rules...)
+			ir.IPSBypass = append(ir.IPSBypass, e.compileIPSBypass(m)...)
+
+		case KindHealthCheckPolicy:
+			ir.HealthTargets = append(ir.HealthTargets, m.HealthCheckSpec.Targets...)
+
+		case KindWANPolicy:
+			if ir.WAN != nil {
+				return nil, fmt.Errorf("WANPolicy %s: only one WANPolicy may be applied", m.Metadata.Name)
+			}
+			wan, targets, err := e.compileWAN(m, groups)
+			if err != nil {
+				return nil, err
+			}
+			ir.WAN = wan
+			ir.HealthTargets = append(ir.HealthTargets, targets...)
+
+		case KindVRFPolicy:
+			ir.VRFs = append(ir.VRFs, m.VRFSpec.VRFs...)
+
+		case KindAppControlPolicy:
+			ir.AppRules = append(ir.AppRules, e.compileAppControl(m)...)
+
+		case KindPolicyTest:
+			tests = append(tests, m)
+
+		case KindAddressGroup, KindServiceGroup, KindServiceDefinition:
+			// Compiled by compileGroups.
+
+		default:
+			k, ok := lookupKind(m.Kind)
+			if !ok {
+				continue
+			}
+			frag, err := k.Compile(pluginMetadata(m.Metadata), m.PluginSpec)
+			if err != nil {
+				return nil, fmt.Errorf("compiling %s %s: %w", m.Kind, m.Metadata.Name, err)
+			}
+			if ir.Extensions == nil {
+				ir.Extensions = make(map[string][]any)
+			}
+			ir.Extensions[m.Kind] = append(ir.Extensions[m.Kind], frag)
 		}
 	}
 
-	// Sort firewall rules by priority (lower number = higher priority).
-	sort.Slice(ir.FirewallRules, func(i, j int) bool {
-		return ir.FirewallRules[i].Priority < ir.FirewallRules[j].Priority
+	ir.CountrySets = groups.countries
+	sort.Slice(ir.CountrySets, func(i, j int) bool { return ir.CountrySets[i].Set < ir.CountrySets[j].Set })
+	ir.FQDNSets = groups.fqdns
+	sort.Slice(ir.FQDNSets, func(i, j int) bool { return ir.FQDNSets[i].Set < ir.FQDNSets[j].Set })
+
+	if err := checkConditions(ir, e.external); err != nil {
+		return nil, err
+	}
+	sort.Slice(ir.VRFs, func(i, j int) bool { return ir.VRFs[i].Name < ir.VRFs[j].Name })
+	if err := checkVRFs(ir); err != nil {
+		return nil, err
+	}
+
+	// Sort by priority (lower number = higher priority). Equal priorities
+	// fall back to namespace/policy/rule so the order never depends on the
+	// order manifests were read in. The rules of each VRF come first, as
+	// their chains are jumped to ahead of the other rules.
+	sort.SliceStable(ir.FirewallRules, func(i, j int) bool {
+		a, b := ir.FirewallRules[i], ir.FirewallRules[j]
+		if a.VRF != b.VRF {
+			return b.VRF == "" || (a.VRF != "" && a.VRF < b.VRF)
+		}
+		return rulePrecedes(a.Priority, a.Comment, b.Priority, b.Comment)
 	})
+	sort.SliceStable(ir.RawRules, func(i, j int) bool { return ir.RawRules[i].Policy < ir.RawRules[j].Policy })
+	sort.SliceStable(ir.NATRules, func(i, j int) bool {
+		a, b := ir.NATRules[i], ir.NATRules[j]
+		return rulePrecedes(a.Priority, a.Comment, b.Priority, b.Comment)
+	})
+
+	if err := checkLimits(ir, e.limits); err != nil {
+		return nil, err
+	}
 
+	if err := runPolicyTests(ir, tests); err != nil {
+		return nil, err
+	}
+
+	ir.Warnings = e.validator.Analyze(ir)
+	if e.ifaces != nil {
+		if host := e.ifaces(); host != nil {
+			ir.Warnings = append(ir.Warnings, checkInterfaces(ir, host)...)
+		}
+	}
 	return ir, nil
 }
 
+func rulePrecedes(prioA int, nameA string, prioB int, nameB string) bool {
+	if prioA != prioB {
+		return prioA < prioB
+	}
+	return nameA < nameB
+}
+
+// TestError reports the PolicyTest expectations a compiled IR broke.
+type TestError struct {
+	Failures []string
+}
+
+func (e *TestError) Error() string {
+	return fmt.Sprintf("policy tests failed:\n  - %s", strings.Join(e.Failures, "\n  - "))
+}
+
+// runPolicyTests simulates the flows of every PolicyTest against ir. Health
+// targets count as up, as they do before their first check settles, so
+// rules gated on a target being down are left out.
+func runPolicyTests(ir *IR, tests []*Manifest) error {
+	if len(tests) == 0 {
+		return nil
+	}
+	primary := *ir
+	primary.FirewallRules = nil
+	for _, r := range ir.FirewallRules {
+		if r.When == nil || r.When.State == "up" {
+			primary.FirewallRules = append(primary.FirewallRules, r)
+		}
+	}
+
+	var failures []string
+	for _, m := range tests {
+		for i, f := range m.PolicyTestSpec.Flows {
+			res := primary.Simulate(f)
+			if res.Pass != nil && *res.Pass {
+				continue
+			}
+			name := f.Name
+			if name == "" {
+				name = fmt.Sprintf("flow[%d]", i)
+			}
+			failures = append(failures, fmt.Sprintf("[%s/%s] %s: expected %s, got %s by %q",
+				m.Metadata.Namespace, m.Metadata.Name, name, strings.ToLower(f.Expect), res.Verdict, res.Rule))
+		}
+	}
+	if len(failures) > 0 {
+		return &TestError{Failures: failures}
+	}
+	return nil
+}
+
 // ─── Firewall compilation ─────────────────────────────────────────────────
 
-func (e *Engine) compileFirewall(m *Manifest) ([]CompiledFirewallRule, error) {
+func (e *Engine) compileFirewall(m *Manifest, groups *groupSets) ([]CompiledFirewallRule, error) {
+	spec := m.FirewallSpec
+	compiled, err := e.compileRules(m, groups, spec.Rules, "")
+	if err != nil {
+		return nil, err
+	}
+	for _, c := range spec.Chains {
+		rules, err := e.compileRules(m, groups, c.Rules, chainID(m, c.Name))
+		if err != nil {
+			return nil, err
+		}
+		compiled = append(compiled, rules...)
+	}
+
+	// If a default action is set, append a catch-all rule at max priority.
+	if spec.DefaultAction != "" {
+		compiled = append(compiled, CompiledFirewallRule{
+			Priority: 99999,
+			Chain:    "forward",
+			Action:   normalizeAction(spec.DefaultAction),
+			Comment:  fmt.Sprintf("%s/%s/default", m.Metadata.Namespace, m.Metadata.Name),
+			NetNS:    spec.TargetNamespace,
+			VRF:      spec.VRF,
+		})
+	}
+
+	return compiled, nil
+}
+
+// CompileRawRules returns the rawRules snippets of the FirewallPolicy m.
+func CompileRawRules(m *Manifest) []CompiledRawRules {
+	if m.FirewallSpec == nil {
+		return nil
+	}
+	var out []CompiledRawRules
+	for _, r := range m.FirewallSpec.RawRules {
+		c := CompiledRawRules{
+			Policy: m.Metadata.Namespace + "/" + m.Metadata.Name,
+			Chain:  r.Chain,
+			NetNS:  m.FirewallSpec.TargetNamespace,
+		}
+		for _, line := range strings.Split(r.Rules, "\n") {
+			if line = strings.TrimSpace(line); line != "" {
+				c.Rules = append(c.Rules, line)
+			}
+		}
+		out = append(out, c)
+	}
+	return out
+}
+
+// compileRules compiles the rules of the user-defined chain userChain of m,
+// or of its base chains if userChain is empty. Rules in user-defined chains
+// are reached through the jumps to them, so they carry no VRF.
+func (e *Engine) compileRules(m *Manifest, groups *groupSets, rules []FirewallRule, userChain string) ([]CompiledFirewallRule, error) {
 	spec := m.FirewallSpec
 	var compiled []CompiledFirewallRule
 
-	for i, r := range spec.Rules {
+	for i, r := range rules {
 		cr := CompiledFirewallRule{
 			Priority: r.Priority,
 			Action:   normalizeAction(r.Action),
@@ -91,6 +290,26 @@ func (e *Engine) compileFirewall(m *Manifest) ([]CompiledFirewallRule, error) {
 			States:   r.State,
 			Log:      r.Log,
 			Comment:  fmt.Sprintf("%s/%s/%s", m.Metadata.Namespace, m.Metadata.Name, r.Name),
+			When:     r.When,
+			Schedule: compileSchedule(r.Schedule),
+			NetNS:    spec.TargetNamespace,
+			VRF:      spec.VRF,
+
+			InIfaces:  r.Source.Interfaces,
+			OutIfaces: r.Dest.Interfaces,
+			SrcMACs:   compileMACs(r.Source.MACAddresses),
+
+			UserChain:  userChain,
+			RejectWith: r.RejectWith,
+			Mark:       compileMark(r.Mark),
+			DSCP:       strings.ToLower(r.DSCP),
+			ExpiresAt:  r.ExpiresAt,
+		}
+		if userChain != "" {
+			cr.VRF = ""
+		}
+		if r.Target != "" {
+			cr.Target = chainID(m, r.Target)
 		}
 
 		// Default priority is insertion order × 100
@@ -99,10 +318,26 @@ func (e *Engine) compileFirewall(m *Manifest) ([]CompiledFirewallRule, error) {
 		}
 
 		// Resolve source addresses / ports
-		cr.SrcAddrs = r.Source.Addresses
-		cr.DstAddrs = r.Dest.Addresses
+		ns := m.Metadata.Namespace
+		srcSets, err := groups.selectorSets(ns, r.Source)
+		if err != nil {
+			return nil, fmt.Errorf("rule %s: %w", cr.Comment, err)
+		}
+		dstSets, err := groups.selectorSets(ns, r.Dest)
+		if err != nil {
+			return nil, fmt.Errorf("rule %s: %w", cr.Comment, err)
+		}
 		cr.DstPorts = compilePorts(r.Dest.Ports, r.Dest.PortRanges)
 		cr.SrcPorts = compilePorts(r.Source.Ports, r.Source.PortRanges)
+		if cr.SrcPortSet, err = groups.serviceSet(ns, r.Source.ServiceGroup); err != nil {
+			return nil, fmt.Errorf("rule %s: %w", cr.Comment, err)
+		}
+		if cr.DstPortSet, err = groups.serviceSet(ns, r.Dest.ServiceGroup); err != nil {
+			return nil, fmt.Errorf("rule %s: %w", cr.Comment, err)
+		}
+		if err := groups.resolveServices(ns, &cr, r.Source, r.Dest); err != nil {
+			return nil, fmt.Errorf("rule %s: %w", cr.Comment, err)
+		}
 
 		// Determine chain based on traffic direction
 		cr.Chain = "forward" // default; refined by zone logic below
@@ -118,35 +353,250 @@ func (e *Engine) compileFirewall(m *Manifest) ([]CompiledFirewallRule, error) {
 		if r.RateLimit != nil {
 			cr.RateLimit = r.RateLimit.Rate
 		}
+		cr.ConnLimit = r.ConnLimit
 
-		compiled = append(compiled, cr)
+		split, err := splitFamilies(cr, newEndpoint(r.Source.Addresses, srcSets), newEndpoint(r.Dest.Addresses, dstSets))
+		if err != nil {
+			return nil, err
+		}
+		compiled = append(compiled, split...)
 	}
+	return compiled, nil
+}
 
-	// If a default action is set, append a catch-all rule at max priority.
-	if spec.DefaultAction != "" {
-		compiled = append(compiled, CompiledFirewallRule{
-			Priority: 99999,
-			Chain:    "forward",
-			Action:   normalizeAction(spec.DefaultAction),
-			Comment:  fmt.Sprintf("%s/%s/default", m.Metadata.Namespace, m.Metadata.Name),
-		})
+// chainID names the user-defined chain of m uniquely within an IR.
+func chainID(m *Manifest, chain string) string {
+	return fmt.Sprintf("%s/%s/%s", m.Metadata.Namespace, m.Metadata.Name, chain)
+}
+
+// SetExternalTargets lets rule conditions name health targets defined
+// outside the manifests, such as the monitors managed through the API.
+// known is consulted on every Compile and must be safe for concurrent use.
+func (e *Engine) SetExternalTargets(known func(name string) bool) {
+	e.external = known
+}
+
+// SetInterfaces lets Compile warn about rules naming interfaces the host
+// does not have. list is called on every Compile and must be safe for
+// concurrent use.
+func (e *Engine) SetInterfaces(list func() []string) {
+	e.ifaces = list
+}
+
+// checkConditions verifies that every rule condition names a health target
+// defined by some HealthCheckPolicy in the same compilation, or one known
+// to external. A manifest may not redefine an external target.
+func checkConditions(ir *IR, external func(string) bool) error {
+	targets := make(map[string]bool, len(ir.HealthTargets))
+	for _, t := range ir.HealthTargets {
+		if targets[t.Name] {
+			return fmt.Errorf("health target %q defined more than once", t.Name)
+		}
+		if external != nil && external(t.Name) {
+			return fmt.Errorf("health target %q is already defined as a monitor", t.Name)
+		}
+		targets[t.Name] = true
+	}
+	known := func(name string) bool {
+		return targets[name] || (external != nil && external(name))
 	}
+	for _, r := range ir.FirewallRules {
+		if r.When != nil && !known(r.When.Target) {
+			return fmt.Errorf("rule %s: unknown health target %q", r.Comment, r.When.Target)
+		}
+	}
+	for _, r := range ir.NATRules {
+		if r.When != nil && !known(r.When.Target) {
+			return fmt.Errorf("rule %s: unknown health target %q", r.Comment, r.When.Target)
+		}
+	}
+	return nil
+}
 
-	return compiled, nil
+// checkVRFs verifies that VRF names, tables and interfaces are not shared,
+// that no VRF takes a WAN uplink's table, and that every rule names a
+// defined VRF.
+func checkVRFs(ir *IR) error {
+	tables := make(map[int]string)
+	owner := make(map[string]string)
+	for i, v := range ir.VRFs {
+		if i > 0 && ir.VRFs[i-1].Name == v.Name {
+			return fmt.Errorf("VRF %q defined more than once", v.Name)
+		}
+		if other, ok := tables[v.Table]; ok {
+			return fmt.Errorf("VRFs %q and %q use the same table %d", other, v.Name, v.Table)
+		}
+		tables[v.Table] = v.Name
+		for _, n := range v.Interfaces {
+			if other, ok := owner[n]; ok {
+				return fmt.Errorf("interface %q is in VRFs %q and %q", n, other, v.Name)
+			}
+			owner[n] = v.Name
+		}
+	}
+	if ir.WAN != nil {
+		for _, u := range ir.WAN.Uplinks {
+			if name, ok := tables[u.Table]; ok {
+				return fmt.Errorf("VRF %q uses table %d of WAN uplink %q", name, u.Table, u.Name)
+			}
+		}
+	}
+	for _, r := range ir.FirewallRules {
+		if _, ok := ir.VRF(r.VRF); r.VRF != "" && !ok {
+			return fmt.Errorf("rule %s: unknown VRF %q", r.Comment, r.VRF)
+		}
+	}
+	for _, r := range ir.NATRules {
+		if _, ok := ir.VRF(r.VRF); r.VRF != "" && !ok {
+			return fmt.Errorf("rule %s: unknown VRF %q", r.Comment, r.VRF)
+		}
+	}
+	return nil
+}
+
+// ─── WAN compilation ──────────────────────────────────────────────────────
+
+const (
+	// wanTableBase and wanMarkBase are the first routing table and fwmark
+	// handed out to uplinks that don't pin their own table.
+	wanTableBase = 200
+	wanMarkBase  = 0x200
+)
+
+// compileWAN assigns a routing table and fwmark to each uplink and turns
+// uplink checks into health targets named "wan-<uplink>", probed through
+// the uplink's own interface. Path destinations compile to firewall rule
+// matches.
+func (e *Engine) compileWAN(m *Manifest, groups *groupSets) (*CompiledWAN, []HealthTarget, error) {
+	spec := m.WANSpec
+	mode := spec.Mode
+	if mode == "" {
+		mode = "balance"
+	}
+	wan := &CompiledWAN{Mode: mode, Sources: spec.Sources}
+	pinned := make(map[int]bool)
+	for _, u := range spec.Uplinks {
+		pinned[u.Table] = true
+	}
+	next := wanTableBase
+	var targets []HealthTarget
+	for i, u := range spec.Uplinks {
+		c := CompiledWANUplink{
+			Name:      u.Name,
+			Interface: u.Interface,
+			Gateway:   u.Gateway,
+			Weight:    u.Weight,
+			Priority:  u.Priority,
+			SNAT:      u.SNAT,
+			Table:     u.Table,
+			Mark:      wanMarkBase + i + 1,
+			PathsOnly: u.PathsOnly,
+		}
+		if c.Weight <= 0 {
+			c.Weight = 1
+		}
+		for c.Table == 0 {
+			if next++; !pinned[next] {
+				c.Table = next
+			}
+		}
+		if u.Check != nil {
+			t := *u.Check
+			t.Name = "wan-" + u.Name
+			if t.Interface == "" {
+				t.Interface = u.Interface
+			}
+			c.Check = t.Name
+			targets = append(targets, t)
+		}
+		wan.Uplinks = append(wan.Uplinks, c)
+	}
+
+	ns := m.Metadata.Namespace
+	for _, p := range spec.Paths {
+		cp := CompiledWANPath{
+			Name:       fmt.Sprintf("%s/%s/%s", ns, m.Metadata.Name, p.Name),
+			Uplinks:    p.Uplinks,
+			MaxLossPct: p.MaxLoss,
+		}
+		if d, err := time.ParseDuration(p.MaxLatency); err == nil {
+			cp.MaxLatencyMs = float64(d) / float64(time.Millisecond)
+		}
+		cr := CompiledFirewallRule{
+			Chain:    "forward",
+			Protocol: normalizeProtocol(p.Protocol),
+			Comment:  cp.Name,
+			DstPorts: compilePorts(p.Destination.Ports, p.Destination.PortRanges),
+		}
+		dstSets, err := groups.selectorSets(ns, p.Destination)
+		if err != nil {
+			return nil, nil, fmt.Errorf("path %s: %w", cp.Name, err)
+		}
+		if cr.DstPortSet, err = groups.serviceSet(ns, p.Destination.ServiceGroup); err != nil {
+			return nil, nil, fmt.Errorf("path %s: %w", cp.Name, err)
+		}
+		if err := groups.resolveServices(ns, &cr, TrafficSelector{}, p.Destination); err != nil {
+			return nil, nil, fmt.Errorf("path %s: %w", cp.Name, err)
+		}
+		if cp.Match, err = splitFamilies(cr, newEndpoint(nil, familySets{}), newEndpoint(p.Destination.Addresses, dstSets)); err != nil {
+			return nil, nil, err
+		}
+		wan.Paths = append(wan.Paths, cp)
+	}
+	return wan, targets, nil
 }
 
 // ─── NAT compilation ──────────────────────────────────────────────────────
 
 func (e *Engine) compileNAT(m *Manifest) ([]CompiledNATRule, error) {
 	var compiled []CompiledNATRule
-	for _, r := range m.NATSpec.Rules {
-		compiled = append(compiled, CompiledNATRule{
+	for i, r := range m.NATSpec.Rules {
+		cr := CompiledNATRule{
+			Priority: r.Priority,
+			Comment:  fmt.Sprintf("%s/%s/%s", m.Metadata.Namespace, m.Metadata.Name, r.Name),
 			Type:     r.Type,
 			SrcAddr:  r.Source,
 			DstAddr:  r.Dest,
-			ToAddr:   r.ToDest,
+			ToAddr:   r.ToDest.String(),
 			OutIface: r.OutIface,
-		})
+			DstPorts: r.Ports,
+			ToPorts:  r.ToPorts,
+			When:     r.When,
+			NetNS:    m.NATSpec.TargetNamespace,
+			VRF:      m.NATSpec.VRF,
+		}
+		if cr.Priority == 0 {
+			cr.Priority = (i + 1) * 100
+		}
+		switch r.Type {
+		case "DNAT":
+			if len(r.ToDest) > 1 {
+				for _, t := range r.ToDest {
+					if t.Weight == 0 {
+						t.Weight = 1
+					}
+					cr.Targets = append(cr.Targets, t)
+				}
+			}
+		case "SNAT":
+			cr.ToAddr = r.ToSource
+			// Without an address the interface's own is used.
+			if cr.ToAddr == "" {
+				cr.Type = "MASQUERADE"
+			}
+		case "NETMAP":
+			cr.SrcAddr, cr.ToAddr = r.Internal, r.External
+		}
+		if p := strings.ToLower(r.Protocol); p != "any" {
+			cr.Protocol = p
+		}
+		if r.Persistent {
+			cr.Flags = append(cr.Flags, "persistent")
+		}
+		if r.Random {
+			cr.Flags = append(cr.Flags, "random")
+		}
+		compiled = append(compiled, cr)
 	}
 	return compiled, nil
 }
@@ -167,11 +617,26 @@ func (e *Engine) compileLB(m *Manifest) (*CompiledLoadBalancer, error) {
 
 func (e *Engine) compileVPN(m *Manifest) (*CompiledVPNConfig, error) {
 	spec := m.VPNSpec
+
+	peers := make([]VPNPeer, len(spec.Peers))
+	copy(peers, spec.Peers)
+	if spec.DefaultKeepAlive > 0 {
+		for i := range peers {
+			if peers[i].KeepAlive == 0 && peers[i].Endpoint == "" {
+				peers[i].KeepAlive = spec.DefaultKeepAlive
+			}
+		}
+	}
+
 	return &CompiledVPNConfig{
-		Interface:  spec.Interface,
-		ListenPort: spec.ListenPort,
-		Address:    spec.Address,
-		Peers:      spec.Peers,
+		Interface:     spec.Interface,
+		ListenPort:    spec.ListenPort,
+		Address:       spec.Address,
+		MTU:           spec.MTU,
+		UplinkMTU:     spec.UplinkMTU,
+		Encapsulation: spec.Encapsulation,
+		Transport:     spec.Transport,
+		Peers:         peers,
 	}, nil
 }
 
@@ -188,8 +653,85 @@ func (e *Engine) compileIDS(m *Manifest) ([]CompiledIDSRule, error) {
 	return compiled, nil
 }
 
+func (e *Engine) compileIPSBypass(m *Manifest) []CompiledIPSBypass {
+	var compiled []CompiledIPSBypass
+	for i, b := range m.IDSSpec.Bypass {
+		cb := CompiledIPSBypass{
+			Protocol: normalizeProtocol(b.Protocol),
+			Addrs:    b.Addresses,
+			Ports:    compilePorts(b.Ports, nil),
+			Comment:  fmt.Sprintf("%s/%s/%s", m.Metadata.Namespace, m.Metadata.Name, b.Name),
+		}
+		if b.Name == "" {
+			cb.Comment = fmt.Sprintf("%s/%s/bypass-%d", m.Metadata.Namespace, m.Metadata.Name, i)
+		}
+		compiled = append(compiled, cb)
+	}
+	return compiled
+}
+
+// ─── App control compilation ──────────────────────────────────────────────
+
+func (e *Engine) compileAppControl(m *Manifest) []CompiledAppRule {
+	spec := m.AppControlSpec
+	var compiled []CompiledAppRule
+	for _, r := range spec.Rules {
+		action := r.Action
+		if action == "" {
+			action = spec.Action
+		}
+		if action == "" {
+			action = "drop"
+		}
+		comment := fmt.Sprintf("%s/%s/%s", m.Metadata.Namespace, m.Metadata.Name, r.Name)
+
+		fields := []struct {
+			name    string
+			values  []string
+			domains bool
+		}{
+			{"tls.sni", r.TLSSNI, true},
+			{"ja3.hash", r.JA3, false},
+			{"http.host", r.HTTPHosts, true},
+			{"dns.query", r.DNSDomains, true},
+		}
+		for _, f := range fields {
+			if len(f.values) == 0 {
+				continue
+			}
+			cr := CompiledAppRule{Action: action, Field: f.name, Comment: comment}
+			for _, v := range f.values {
+				v = strings.ToLower(strings.TrimSpace(v))
+				if f.domains && strings.HasPrefix(v, "*.") {
+					cr.Suffixes = append(cr.Suffixes, strings.TrimPrefix(v, "*."))
+					continue
+				}
+				cr.Values = append(cr.Values, strings.TrimSuffix(v, "."))
+			}
+			compiled = append(compiled, cr)
+		}
+	}
+	return compiled
+}
+
 // ─── Helpers ──────────────────────────────────────────────────────────────
 
+// compileSchedule spells day names the way nftables expects them.
+func compileSchedule(s *RuleSchedule) *RuleSchedule {
+	if s == nil {
+		return nil
+	}
+	out := &RuleSchedule{Start: s.Start, End: s.End}
+	for _, d := range s.Days {
+		if d == "" {
+			continue
+		}
+		d = strings.ToLower(d)
+		out.Days = append(out.Days, strings.ToUpper(d[:1])+d[1:])
+	}
+	return out
+}
+
 func normalizeAction(a string) string {
 	switch a {
 	case "ALLOW", "allow", "ACCEPT", "accept":
@@ -200,6 +742,18 @@ func normalizeAction(a string) string {
 		return "reject"
 	case "LOG", "log":
 		return "log"
+	case "TARPIT", "tarpit":
+		return "tarpit"
+	case "JUMP", "jump":
+		return "jump"
+	case "GOTO", "goto":
+		return "goto"
+	case "RETURN", "return":
+		return "return"
+	case "MARK", "mark":
+		return "mark"
+	case "DSCP", "dscp":
+		return "dscp"
 	default:
 		return "drop"
 	}
@@ -225,6 +779,27 @@ func compilePorts(ports []int, ranges []PortRange) []string {
 	return out
 }
 
+// compileMACs spells MAC addresses the way nftables prints them. The
+// validator has checked they parse.
+func compileMACs(macs []string) []string {
+	var out []string
+	for _, m := range macs {
+		if hw, err := net.ParseMAC(m); err == nil {
+			out = append(out, hw.String())
+		}
+	}
+	return out
+}
+
+// compileMark returns mark in hex, or "" if it does not parse.
+func compileMark(mark string) string {
+	n, err := strconv.ParseUint(mark, 0, 32)
+	if err != nil {
+		return ""
+	}
+	return fmt.Sprintf("%#x", n)
+}
+
 func hasZone(zones []string, target string) bool {
 	for _, z := range zones {
 		if z == target {
@@ -0,0 +1,90 @@
+package policy
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Address families of compiled rules and groups, named after the nft
+// payload expressions that match them.
+const (
+	FamilyIPv4 = "ip"
+	FamilyIPv6 = "ip6"
+)
+
+// addrFamily returns the family of an address, CIDR or "a-b" range.
+func addrFamily(s string) string {
+	if strings.Contains(s, ":") {
+		return FamilyIPv6
+	}
+	return FamilyIPv4
+}
+
+// splitAddrs separates IPv4 from IPv6 entries, keeping their order.
+func splitAddrs(addrs []string) (v4, v6 []string) {
+	for _, a := range addrs {
+		if addrFamily(a) == FamilyIPv6 {
+			v6 = append(v6, a)
+		} else {
+			v4 = append(v4, a)
+		}
+	}
+	return v4, v6
+}
+
+// endpoint is one side of a rule split by family: inline addresses or the
+// sets of an address group.
+type endpoint struct {
+	v4, v6       []string
+	set4, set6   string
+	hasSelection bool // addresses or a group were given
+}
+
+func newEndpoint(addrs []string, fs familySets) endpoint {
+	ep := endpoint{set4: fs.v4, set6: fs.v6}
+	ep.v4, ep.v6 = splitAddrs(addrs)
+	ep.hasSelection = len(addrs) > 0 || fs.v4 != "" || fs.v6 != ""
+	return ep
+}
+
+func (ep endpoint) has(family string) bool {
+	if family == FamilyIPv6 {
+		return len(ep.v6) > 0 || ep.set6 != ""
+	}
+	return len(ep.v4) > 0 || ep.set4 != ""
+}
+
+// apply sets one side of cr to the addresses of the given family.
+func (ep endpoint) apply(family string, addrs *[]string, set *string) {
+	if family == FamilyIPv6 {
+		*addrs, *set = ep.v6, ep.set6
+	} else {
+		*addrs, *set = ep.v4, ep.set4
+	}
+}
+
+// splitFamilies turns cr into one rule per address family its source and
+// destination share. A rule without addresses matches both families and is
+// returned as is, unless it counts connections per source address, which
+// is kept per family. nft cannot match IPv4 and IPv6 addresses in one rule,
+// so a dual-stack rule becomes two with the same comment.
+func splitFamilies(cr CompiledFirewallRule, src, dst endpoint) ([]CompiledFirewallRule, error) {
+	if !src.hasSelection && !dst.hasSelection && cr.ConnLimit == 0 {
+		return []CompiledFirewallRule{cr}, nil
+	}
+	var out []CompiledFirewallRule
+	for _, family := range []string{FamilyIPv4, FamilyIPv6} {
+		if (src.hasSelection && !src.has(family)) || (dst.hasSelection && !dst.has(family)) {
+			continue
+		}
+		r := cr
+		r.Family = family
+		src.apply(family, &r.SrcAddrs, &r.SrcSet)
+		dst.apply(family, &r.DstAddrs, &r.DstSet)
+		out = append(out, r)
+	}
+	if len(out) == 0 {
+		return nil, fmt.Errorf("rule %s: source and destination have no address family in common", cr.Comment)
+	}
+	return out, nil
+}
@@ -0,0 +1,74 @@
+package policy
+
+import (
+	"sort"
+	"strings"
+)
+
+// fqdnSets returns the sets matching the addresses of the given names,
+// declaring them on first use. Selectors listing the same names share their
+// sets.
+func (gs *groupSets) fqdnSets(names []string) familySets {
+	fqdns := normalizeFQDNs(names)
+	if len(fqdns) == 0 {
+		return familySets{}
+	}
+	// Dots become underscores, which a valid name cannot hold; a name with
+	// a hyphen would be ambiguous, so such lists are hashed.
+	ident := strings.ReplaceAll(strings.Join(fqdns, "_"), ".", "_")
+	if strings.Contains(ident, "-") {
+		ident = hashIdent(ident)
+	} else {
+		ident = listIdent(ident)
+	}
+	fs := familySets{v4: "fq_" + ident, v6: "fq6_" + ident}
+	if _, ok := gs.fqdnIdx[ident]; !ok {
+		gs.fqdnIdx[ident] = fs
+		gs.fqdns = append(gs.fqdns,
+			CompiledFQDNSet{Set: fs.v4, Family: FamilyIPv4, FQDNs: fqdns},
+			CompiledFQDNSet{Set: fs.v6, Family: FamilyIPv6, FQDNs: fqdns})
+	}
+	return fs
+}
+
+// normalizeFQDNs lower-cases, strips the trailing dot, sorts and
+// deduplicates names.
+func normalizeFQDNs(names []string) []string {
+	seen := make(map[string]bool, len(names))
+	var out []string
+	for _, n := range names {
+		n = strings.TrimSuffix(strings.ToLower(strings.TrimSpace(n)), ".")
+		if n == "" || seen[n] {
+			continue
+		}
+		seen[n] = true
+		out = append(out, n)
+	}
+	sort.Strings(out)
+	return out
+}
+
+// validFQDN reports whether name is a DNS name of at least two labels made
+// of letters, digits and inner hyphens. Wildcards cannot be resolved and
+// are refused.
+func validFQDN(name string) bool {
+	name = strings.TrimSuffix(name, ".")
+	if len(name) == 0 || len(name) > 253 {
+		return false
+	}
+	labels := strings.Split(name, ".")
+	if len(labels) < 2 {
+		return false
+	}
+	for _, l := range labels {
+		if len(l) == 0 || len(l) > 63 || l[0] == '-' || l[len(l)-1] == '-' {
+			return false
+		}
+		for _, r := range strings.ToLower(l) {
+			if (r < 'a' || r > 'z') && (r < '0' || r > '9') && r != '-' {
+				return false
+			}
+		}
+	}
+	return true
+}
@@ -0,0 +1,148 @@
+package policy
+
+import (
+	"fmt"
+	"strings"
+)
+
+// groupSets maps "namespace/name" of each group to its compiled sets. An
+// address group holding both families compiles to one set per family.
+type groupSets struct {
+	addrs    map[string]familySets
+	services map[string]CompiledGroup
+	defs     map[string]*ServiceDefinitionSpec // resolved inline, not sets
+
+	// Country and FQDN sets, declared as rules select them.
+	countryIdx map[string]familySets
+	countries  []CompiledCountrySet
+	fqdnIdx    map[string]familySets
+	fqdns      []CompiledFQDNSet
+}
+
+type familySets struct {
+	v4, v6 string // set names; empty when the group has no such addresses
+}
+
+// compileGroups collects the AddressGroup, ServiceGroup and
+// ServiceDefinition manifests ahead of the rules that reference them,
+// whatever order they were read in.
+func compileGroups(manifests []*Manifest) (*groupSets, []CompiledGroup, []CompiledGroup, error) {
+	gs := &groupSets{
+		addrs:      make(map[string]familySets),
+		services:   make(map[string]CompiledGroup),
+		defs:       compileServices(manifests),
+		countryIdx: make(map[string]familySets),
+		fqdnIdx:    make(map[string]familySets),
+	}
+	sets := make(map[string]string) // set name → group, to catch collisions
+	var addrs, services []CompiledGroup
+
+	add := func(kind, key string, g CompiledGroup) error {
+		if other, dup := sets[g.Set]; dup {
+			if other == key {
+				return fmt.Errorf("%s %s defined more than once", kind, key)
+			}
+			return fmt.Errorf("%s %s and %s map to the same set %s; rename one", kind, key, other, g.Set)
+		}
+		sets[g.Set] = key
+		return nil
+	}
+
+	for _, m := range manifests {
+		if m.Kind != KindAddressGroup && m.Kind != KindServiceGroup {
+			continue
+		}
+		key := m.Metadata.Namespace + "/" + m.Metadata.Name
+		ident := setIdent(m.Metadata.Namespace) + "_" + setIdent(m.Metadata.Name)
+
+		if m.Kind == KindServiceGroup {
+			g := CompiledGroup{Set: "sg_" + ident, Group: key,
+				Elements: compilePorts(m.ServiceGroupSpec.Ports, m.ServiceGroupSpec.PortRanges)}
+			if err := add(m.Kind, key, g); err != nil {
+				return nil, nil, nil, err
+			}
+			gs.services[key] = g
+			services = append(services, g)
+			continue
+		}
+
+		if _, dup := gs.addrs[key]; dup {
+			return nil, nil, nil, fmt.Errorf("%s %s defined more than once", m.Kind, key)
+		}
+		v4, v6 := splitAddrs(m.AddressGroupSpec.Addresses)
+		var fs familySets
+		if len(v4) > 0 {
+			g := CompiledGroup{Set: "ag_" + ident, Group: key, Family: FamilyIPv4, Elements: v4}
+			if err := add(m.Kind, key, g); err != nil {
+				return nil, nil, nil, err
+			}
+			fs.v4 = g.Set
+			addrs = append(addrs, g)
+		}
+		if len(v6) > 0 {
+			g := CompiledGroup{Set: "ag6_" + ident, Group: key, Family: FamilyIPv6, Elements: v6}
+			if err := add(m.Kind, key, g); err != nil {
+				return nil, nil, nil, err
+			}
+			fs.v6 = g.Set
+			addrs = append(addrs, g)
+		}
+		gs.addrs[key] = fs
+	}
+	return gs, addrs, services, nil
+}
+
+// selectorSets returns the sets an address selector matches against: those
+// of its address group, countries or FQDNs, if any.
+func (gs *groupSets) selectorSets(ns string, s TrafficSelector) (familySets, error) {
+	if len(s.Countries) > 0 {
+		return gs.countrySets(s.Countries), nil
+	}
+	if len(s.FQDNs) > 0 {
+		return gs.fqdnSets(s.FQDNs), nil
+	}
+	return gs.addrSets(ns, s.AddressGroup)
+}
+
+// addrSets returns the sets of the address group ref names, if any.
+func (gs *groupSets) addrSets(ns, ref string) (familySets, error) {
+	if ref == "" {
+		return familySets{}, nil
+	}
+	fs, ok := gs.addrs[groupKey(ns, ref)]
+	if !ok {
+		return familySets{}, fmt.Errorf("unknown %s %q", KindAddressGroup, ref)
+	}
+	return fs, nil
+}
+
+// serviceSet returns the set of the service group ref names, if any.
+func (gs *groupSets) serviceSet(ns, ref string) (string, error) {
+	if ref == "" {
+		return "", nil
+	}
+	g, ok := gs.services[groupKey(ns, ref)]
+	if !ok {
+		return "", fmt.Errorf("unknown %s %q", KindServiceGroup, ref)
+	}
+	return g.Set, nil
+}
+
+// groupKey resolves a bare group name to the referencing namespace.
+func groupKey(ns, ref string) string {
+	if strings.Contains(ref, "/") {
+		return ref
+	}
+	return ns + "/" + ref
+}
+
+// setIdent reduces s to the characters nft accepts in a set name.
+func setIdent(s string) string {
+	return strings.Map(func(r rune) rune {
+		switch {
+		case r >= 'a' && r <= 'z', r >= 'A' && r <= 'Z', r >= '0' && r <= '9', r == '_':
+			return r
+		}
+		return '_'
+	}, s)
+}
@@ -0,0 +1,195 @@
+package policy
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Parse reads manifests in either format: data that is valid JSON is read
+// as ParseJSON does, anything else as YAML.
+func (p *Parser) Parse(data []byte) ([]*Manifest, error) {
+	if json.Valid(data) {
+		return p.ParseJSON(data)
+	}
+	return p.ParseReader(bytes.NewReader(data))
+}
+
+// ParseJSON reads one JSON manifest, or an array of them. Field names are
+// those of the YAML form, which the API's JSON shares.
+func (p *Parser) ParseJSON(data []byte) ([]*Manifest, error) {
+	node, err := jsonNode(data)
+	if err != nil {
+		return nil, err
+	}
+	items := []*yaml.Node{node}
+	if node.Kind == yaml.SequenceNode {
+		items = node.Content
+	}
+	docs := make([]document, 0, len(items))
+	for _, n := range items {
+		m, err := decodeManifest(n)
+		if err != nil {
+			return nil, err
+		}
+		docs = append(docs, document{node: n, m: m})
+	}
+	return p.manifestsOf(docs)
+}
+
+// ParseSpecJSON builds the manifest of a stored policy from its kind,
+// metadata and JSON spec, for policies saved without a manifest.
+func (p *Parser) ParseSpecJSON(kind string, meta Metadata, spec []byte) (*Manifest, error) {
+	node, err := jsonNode(spec)
+	if err != nil {
+		return nil, err
+	}
+	if node.Kind != yaml.MappingNode {
+		return nil, fmt.Errorf("%s spec must be a JSON object", kind)
+	}
+	m := &Manifest{APIVersion: APIVersion, Kind: kind, Metadata: meta}
+	if err := decodeSpec(m, node); err != nil {
+		return nil, err
+	}
+	if p.strict {
+		var errs []error
+		unknownFields(node, specType(m), "spec", &errs)
+		if len(errs) > 0 {
+			return nil, errors.Join(errs...)
+		}
+	}
+	if m.TemplateSpec != nil || m.ValuesSpec != nil {
+		return nil, fmt.Errorf("%s cannot be stored as a JSON spec", kind)
+	}
+	return m, nil
+}
+
+// ─── Private helpers ──────────────────────────────────────────────────────
+
+// jsonNode converts a JSON document to the node the YAML decoder would
+// build for it, so both formats share one decoding path. Nodes carry the
+// line and column of their JSON value.
+func jsonNode(data []byte) (*yaml.Node, error) {
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.UseNumber()
+	jd := &jsonDecoder{dec: dec, data: data, lines: []int{0}}
+	for i, c := range data {
+		if c == '\n' {
+			jd.lines = append(jd.lines, i+1)
+		}
+	}
+	node, err := jd.value(0)
+	if err != nil {
+		return nil, jd.errorf(err)
+	}
+	if _, err := dec.Token(); err != io.EOF {
+		return nil, fmt.Errorf("json decode: unexpected data after the document")
+	}
+	return node, nil
+}
+
+type jsonDecoder struct {
+	dec   *json.Decoder
+	data  []byte
+	lines []int // offsets at which lines start
+}
+
+// value reads the next JSON value.
+func (d *jsonDecoder) value(depth int) (*yaml.Node, error) {
+	if depth > maxJSONDepth {
+		return nil, fmt.Errorf("nesting deeper than %d", maxJSONDepth)
+	}
+	line, col := d.position()
+	tok, err := d.dec.Token()
+	if err != nil {
+		if err == io.EOF {
+			err = io.ErrUnexpectedEOF
+		}
+		return nil, err
+	}
+	n := &yaml.Node{Line: line, Column: col}
+	switch t := tok.(type) {
+	case json.Delim:
+		switch t {
+		case '{':
+			n.Kind, n.Tag = yaml.MappingNode, "!!map"
+			for d.dec.More() {
+				kl, kc := d.position()
+				key, err := d.dec.Token()
+				if err != nil {
+					return nil, err
+				}
+				k, _ := key.(string)
+				v, err := d.value(depth + 1)
+				if err != nil {
+					return nil, err
+				}
+				n.Content = append(n.Content, &yaml.Node{Kind: yaml.ScalarNode, Tag: "!!str", Value: k, Line: kl, Column: kc}, v)
+			}
+		case '[':
+			n.Kind, n.Tag = yaml.SequenceNode, "!!seq"
+			for d.dec.More() {
+				v, err := d.value(depth + 1)
+				if err != nil {
+					return nil, err
+				}
+				n.Content = append(n.Content, v)
+			}
+		default:
+			return nil, fmt.Errorf("unexpected %q", t)
+		}
+		if _, err := d.dec.Token(); err != nil { // the closing delimiter
+			return nil, err
+		}
+	case string:
+		n.Kind, n.Tag, n.Value = yaml.ScalarNode, "!!str", t
+		if strings.Contains(t, "\n") {
+			n.Style = yaml.LiteralStyle
+		}
+	case json.Number:
+		n.Kind, n.Tag, n.Value = yaml.ScalarNode, "!!int", t.String()
+		if strings.ContainsAny(n.Value, ".eE") {
+			n.Tag = "!!float"
+		}
+	case bool:
+		n.Kind, n.Tag, n.Value = yaml.ScalarNode, "!!bool", fmt.Sprint(t)
+	case nil:
+		n.Kind, n.Tag, n.Value = yaml.ScalarNode, "!!null", "null"
+	}
+	return n, nil
+}
+
+// maxJSONDepth bounds the nesting of a JSON manifest.
+const maxJSONDepth = 64
+
+// position returns the line and column of the next token.
+func (d *jsonDecoder) position() (int, int) {
+	off := int(d.dec.InputOffset())
+	for off < len(d.data) && strings.IndexByte(" \t\r\n,:", d.data[off]) >= 0 {
+		off++
+	}
+	return d.lineCol(off)
+}
+
+// errorf reports err with the position the decoder stopped at.
+func (d *jsonDecoder) errorf(err error) error {
+	var syn *json.SyntaxError
+	off := int(d.dec.InputOffset())
+	if errors.As(err, &syn) {
+		off = int(syn.Offset)
+	}
+	line, col := d.lineCol(off)
+	return fmt.Errorf("json decode: line %d, column %d: %w", line, col, err)
+}
+
+// lineCol converts a byte offset to a line and column counted from 1.
+func (d *jsonDecoder) lineCol(off int) (int, int) {
+	line := sort.SearchInts(d.lines, off+1)
+	return line, off - d.lines[line-1] + 1
+}
@@ -0,0 +1,93 @@
+package policy
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Limits caps the size of a compiled ruleset so that an IR too large for
+// the kernel to load on a small appliance is refused before it reaches the
+// dataplane. A zero field means no limit.
+type Limits struct {
+	MaxRulesPerChain int // firewall rules in one chain, NAT rules in prerouting/postrouting
+	MaxSetElements   int // addresses or ports in one match set
+	MaxRenderBytes   int // size of the rendered ruleset; checked by the backend
+}
+
+// LimitError reports every limit a compiled IR exceeds.
+type LimitError struct {
+	Violations []string
+}
+
+func (e *LimitError) Error() string {
+	return fmt.Sprintf("ruleset limits exceeded:\n  - %s", strings.Join(e.Violations, "\n  - "))
+}
+
+// SetLimits makes Compile refuse IRs that exceed l.
+func (e *Engine) SetLimits(l Limits) {
+	e.limits = l
+}
+
+// checkLimits counts rules per chain and elements per set the way the nft
+// backend lays them out.
+func checkLimits(ir *IR, l Limits) error {
+	var violations []string
+
+	if l.MaxRulesPerChain > 0 {
+		counts := make(map[string]int)
+		var order []string
+		count := func(chain string) {
+			if _, ok := counts[chain]; !ok {
+				order = append(order, chain)
+			}
+			counts[chain]++
+		}
+		for _, r := range ir.FirewallRules {
+			count(ruleChain(&r))
+			// A dscp rule of both families sets ip and ip6 dscp apart.
+			if r.Action == "dscp" && r.Family == "" {
+				count(ruleChain(&r))
+			}
+		}
+		for _, r := range ir.NATRules {
+			switch r.Type {
+			case "DNAT":
+				count("prerouting")
+			case "NETMAP":
+				count("prerouting")
+				count("postrouting")
+			default:
+				count("postrouting")
+			}
+		}
+		for _, chain := range order {
+			if n := counts[chain]; n > l.MaxRulesPerChain {
+				violations = append(violations, fmt.Sprintf("chain %s has %d rules, limit is %d", chain, n, l.MaxRulesPerChain))
+			}
+		}
+	}
+
+	if l.MaxSetElements > 0 {
+		check := func(rule, field string, n int) {
+			if n > l.MaxSetElements {
+				violations = append(violations, fmt.Sprintf("rule %s: %s has %d elements, limit is %d", rule, field, n, l.MaxSetElements))
+			}
+		}
+		for _, r := range ir.FirewallRules {
+			check(r.Comment, "source", len(r.SrcAddrs))
+			check(r.Comment, "destination", len(r.DstAddrs))
+			check(r.Comment, "sourcePorts", len(r.SrcPorts))
+			check(r.Comment, "destinationPorts", len(r.DstPorts))
+		}
+		for _, g := range append(append([]CompiledGroup{}, ir.AddressGroups...), ir.ServiceGroups...) {
+			if n := len(g.Elements); n > l.MaxSetElements {
+				violations = append(violations, fmt.Sprintf("group %s has %d elements, limit is %d", g.Group, n, l.MaxSetElements))
+			}
+		}
+	}
+
+	if len(violations) > 0 {
+		return &LimitError{Violations: violations}
+	}
+	return nil
+}
@@ -0,0 +1,85 @@
+package policy
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"gopkg.in/yaml.v3"
+)
+
+// NATTarget is one DNAT destination and its share of new connections.
+type NATTarget struct {
+	Address string `yaml:"address" json:"address"`
+	Weight  int    `yaml:"weight"  json:"weight,omitempty"` // default 1
+}
+
+// NATTargets is the toDest of a DNAT rule: a single "addr[:port]" string,
+// or a list of targets that new connections are spread across by a hash of
+// the client address.
+//
+//	toDest: "10.0.1.10:80"
+//	toDest:
+//	  - address: 10.0.1.10
+//	    weight: 2
+//	  - 10.0.1.11
+type NATTargets []NATTarget
+
+// String returns the single target's address, or "" for a list.
+func (t NATTargets) String() string {
+	if len(t) == 1 {
+		return t[0].Address
+	}
+	return ""
+}
+
+func (t *NATTargets) UnmarshalYAML(n *yaml.Node) error {
+	switch n.Kind {
+	case yaml.ScalarNode:
+		*t = nil
+		if n.Value != "" {
+			*t = NATTargets{{Address: n.Value}}
+		}
+		return nil
+	case yaml.SequenceNode:
+		out := make(NATTargets, 0, len(n.Content))
+		for _, item := range n.Content {
+			if item.Kind == yaml.ScalarNode {
+				out = append(out, NATTarget{Address: item.Value})
+				continue
+			}
+			var nt NATTarget
+			if err := item.Decode(&nt); err != nil {
+				return err
+			}
+			out = append(out, nt)
+		}
+		*t = out
+		return nil
+	}
+	return fmt.Errorf("line %d: toDest must be an address or a list of targets", n.Line)
+}
+
+// MarshalJSON keeps the single-address form a plain string.
+func (t NATTargets) MarshalJSON() ([]byte, error) {
+	if len(t) == 1 && t[0].Weight == 0 {
+		return json.Marshal(t[0].Address)
+	}
+	return json.Marshal([]NATTarget(t))
+}
+
+func (t *NATTargets) UnmarshalJSON(data []byte) error {
+	var s string
+	if err := json.Unmarshal(data, &s); err == nil {
+		*t = nil
+		if s != "" {
+			*t = NATTargets{{Address: s}}
+		}
+		return nil
+	}
+	var list []NATTarget
+	if err := json.Unmarshal(data, &list); err != nil {
+		return err
+	}
+	*t = list
+	return nil
+}
@@ -10,10 +10,17 @@ import (
 )
 
 // Parser reads YAML policy manifests and returns typed Manifest slices.
-type Parser struct{}
+type Parser struct {
+	strict bool
+}
 
 func NewParser() *Parser { return &Parser{} }
 
+// NewStrictParser returns a Parser that also refuses keys no field of the
+// manifest takes, such as a misspelt "destnation", reporting each with its
+// line, column and key path. The default Parser ignores them.
+func NewStrictParser() *Parser { return &Parser{strict: true} }
+
 // ParseFile reads one YAML file which may contain multiple ---separated docs.
 func (p *Parser) ParseFile(path string) ([]*Manifest, error) {
 	f, err := os.Open(path)
@@ -24,32 +31,61 @@ func (p *Parser) ParseFile(path string) ([]*Manifest, error) {
 	return p.ParseReader(f)
 }
 
-// ParseDir reads all *.yaml / *.yml files in a directory.
+// ParseDir reads all *.yaml / *.yml files in a directory. Templates are
+// rendered once every file is read, so values may live apart from their
+// template.
 func (p *Parser) ParseDir(dir string) ([]*Manifest, error) {
 	patterns := []string{"*.yaml", "*.yml"}
-	var all []*Manifest
+	var all []document
 	for _, pat := range patterns {
 		matches, err := filepath.Glob(filepath.Join(dir, pat))
 		if err != nil {
 			return nil, err
 		}
 		for _, path := range matches {
-			ms, err := p.ParseFile(path)
+			docs, err := p.parseFileDocs(path)
 			if err != nil {
 				return nil, fmt.Errorf("parsing %s: %w", path, err)
 			}
-			all = append(all, ms...)
+			all = append(all, docs...)
 		}
 	}
-	return all, nil
+	return p.manifestsOf(all)
 }
 
-// ParseReader decodes all YAML documents from r.
+// ParseReader decodes all YAML documents from r and renders the
+// PolicyValues among them with their PolicyTemplate.
 func (p *Parser) ParseReader(r io.Reader) ([]*Manifest, error) {
+	docs, err := p.parseDocs(r)
+	if err != nil {
+		return nil, err
+	}
+	return p.manifestsOf(docs)
+}
+
+// ─── Private helpers ──────────────────────────────────────────────────────
+
+// document is one YAML document and the manifest decoded from it.
+type document struct {
+	node *yaml.Node
+	m    *Manifest
+}
+
+func (p *Parser) parseFileDocs(path string) ([]document, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("open %s: %w", path, err)
+	}
+	defer f.Close()
+	return p.parseDocs(f)
+}
+
+// parseDocs decodes all YAML documents from r without rendering templates.
+func (p *Parser) parseDocs(r io.Reader) ([]document, error) {
 	dec := yaml.NewDecoder(r)
 	dec.KnownFields(false)
 
-	var manifests []*Manifest
+	var docs []document
 	for {
 		// First pass: decode into a generic node to figure out Kind.
 		var node yaml.Node
@@ -59,77 +95,187 @@ func (p *Parser) ParseReader(r io.Reader) ([]*Manifest, error) {
 			}
 			return nil, fmt.Errorf("yaml decode: %w", err)
 		}
+		m, err := decodeManifest(&node)
+		if err != nil {
+			return nil, err
+		}
+		docs = append(docs, document{node: &node, m: m})
+	}
+	return docs, nil
+}
+
+// manifestsOf renders docs and returns their manifests. A strict Parser
+// checks the keys of the documents, and those of the manifests templates
+// render.
+func (p *Parser) manifestsOf(docs []document) ([]*Manifest, error) {
+	if err := p.checkFields(docs); err != nil {
+		return nil, err
+	}
+	docs, err := renderTemplates(docs)
+	if err != nil {
+		return nil, err
+	}
+	if err := p.checkFields(docs); err != nil {
+		return nil, err
+	}
+	manifests := make([]*Manifest, len(docs))
+	for i, d := range docs {
+		manifests[i] = d.m
+	}
+	return manifests, nil
+}
+
+// decodeManifest decodes one document into the typed spec of its Kind.
+func decodeManifest(node *yaml.Node) (*Manifest, error) {
+	// Extract apiVersion + kind without full unmarshal.
+	header := struct {
+		APIVersion string   `yaml:"apiVersion"`
+		Kind       string   `yaml:"kind"`
+		Metadata   Metadata `yaml:"metadata"`
+	}{}
+	if err := node.Decode(&header); err != nil {
+		return nil, fmt.Errorf("decode header: %w", err)
+	}
 
-		// Extract apiVersion + kind without full unmarshal.
-		header := struct {
-			APIVersion string   `yaml:"apiVersion"`
-			Kind       string   `yaml:"kind"`
-			Metadata   Metadata `yaml:"metadata"`
-		}{}
-		if err := node.Decode(&header); err != nil {
-			return nil, fmt.Errorf("decode header: %w", err)
+	if header.APIVersion != APIVersion {
+		return nil, fmt.Errorf("unsupported apiVersion %q (want %s)", header.APIVersion, APIVersion)
+	}
+
+	m := &Manifest{
+		APIVersion: header.APIVersion,
+		Kind:       header.Kind,
+		Metadata:   header.Metadata,
+	}
+
+	wrapper := struct {
+		Spec yaml.Node `yaml:"spec"`
+	}{}
+	if err := node.Decode(&wrapper); err != nil {
+		return nil, fmt.Errorf("decode spec node: %w", err)
+	}
+	if err := decodeSpec(m, &wrapper.Spec); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+// decodeSpec decodes node into the typed spec of m's Kind.
+func decodeSpec(m *Manifest, node *yaml.Node) error {
+	switch m.Kind {
+	case KindFirewallPolicy:
+		var spec FirewallPolicySpec
+		if err := node.Decode(&spec); err != nil {
+			return fmt.Errorf("decode FirewallPolicy spec: %w", err)
 		}
+		m.FirewallSpec = &spec
 
-		if header.APIVersion != APIVersion {
-			return nil, fmt.Errorf("unsupported apiVersion %q (want %s)", header.APIVersion, APIVersion)
+	case KindLoadBalancerPolicy:
+		var spec LoadBalancerPolicySpec
+		if err := node.Decode(&spec); err != nil {
+			return fmt.Errorf("decode LoadBalancerPolicy spec: %w", err)
 		}
+		m.LoadBalancerSpec = &spec
 
-		m := &Manifest{
-			APIVersion: header.APIVersion,
-			Kind:       header.Kind,
-			Metadata:   header.Metadata,
+	case KindVPNPolicy:
+		var spec VPNPolicySpec
+		if err := node.Decode(&spec); err != nil {
+			return fmt.Errorf("decode VPNPolicy spec: %w", err)
 		}
+		m.VPNSpec = &spec
 
-		// Decode spec into the correct typed struct based on Kind.
-		wrapper := struct {
-			Spec yaml.Node `yaml:"spec"`
-		}{}
-		if err := node.Decode(&wrapper); err != nil {
-			return nil, fmt.Errorf("decode spec node: %w", err)
+	case KindNATPolicy:
+		var spec NATPolicySpec
+		if err := node.Decode(&spec); err != nil {
+			return fmt.Errorf("decode NATPolicy spec: %w", err)
 		}
+		m.NATSpec = &spec
 
-		switch header.Kind {
-		case KindFirewallPolicy:
-			var spec FirewallPolicySpec
-			if err := wrapper.Spec.Decode(&spec); err != nil {
-				return nil, fmt.Errorf("decode FirewallPolicy spec: %w", err)
-			}
-			m.FirewallSpec = &spec
+	case KindIDSPolicy:
+		var spec IDSPolicySpec
+		if err := node.Decode(&spec); err != nil {
+			return fmt.Errorf("decode IDSPolicy spec: %w", err)
+		}
+		m.IDSSpec = &spec
 
-		case KindLoadBalancerPolicy:
-			var spec LoadBalancerPolicySpec
-			if err := wrapper.Spec.Decode(&spec); err != nil {
-				return nil, fmt.Errorf("decode LoadBalancerPolicy spec: %w", err)
-			}
-			m.LoadBalancerSpec = &spec
+	case KindHealthCheckPolicy:
+		var spec HealthCheckPolicySpec
+		if err := node.Decode(&spec); err != nil {
+			return fmt.Errorf("decode HealthCheckPolicy spec: %w", err)
+		}
+		m.HealthCheckSpec = &spec
 
-		case KindVPNPolicy:
-			var spec VPNPolicySpec
-			if err := wrapper.Spec.Decode(&spec); err != nil {
-				return nil, fmt.Errorf("decode VPNPolicy spec: %w", err)
-			}
-			m.VPNSpec = &spec
+	case KindWANPolicy:
+		var spec WANPolicySpec
+		if err := node.Decode(&spec); err != nil {
+			return fmt.Errorf("decode WANPolicy spec: %w", err)
+		}
+		m.WANSpec = &spec
 
-		case KindNATPolicy:
-			var spec NATPolicySpec
-			if err := wrapper.Spec.Decode(&spec); err != nil {
-				return nil, fmt.Errorf("decode NATPolicy spec: %w", err)
-			}
-			m.NATSpec = &spec
+	case KindVRFPolicy:
+		var spec VRFPolicySpec
+		if err := node.Decode(&spec); err != nil {
+			return fmt.Errorf("decode VRFPolicy spec: %w", err)
+		}
+		m.VRFSpec = &spec
 
-		case KindIDSPolicy:
-			var spec IDSPolicySpec
-			if err := wrapper.Spec.Decode(&spec); err != nil {
-				return nil, fmt.Errorf("decode IDSPolicy spec: %w", err)
-			}
-			m.IDSSpec = &spec
+	case KindAppControlPolicy:
+		var spec AppControlPolicySpec
+		if err := node.Decode(&spec); err != nil {
+			return fmt.Errorf("decode AppControlPolicy spec: %w", err)
+		}
+		m.AppControlSpec = &spec
 
-		default:
-			return nil, fmt.Errorf("unknown Kind %q", header.Kind)
+	case KindPolicyTest:
+		var spec PolicyTestSpec
+		if err := node.Decode(&spec); err != nil {
+			return fmt.Errorf("decode PolicyTest spec: %w", err)
 		}
+		m.PolicyTestSpec = &spec
 
-		manifests = append(manifests, m)
-	}
+	case KindAddressGroup:
+		var spec AddressGroupSpec
+		if err := node.Decode(&spec); err != nil {
+			return fmt.Errorf("decode AddressGroup spec: %w", err)
+		}
+		m.AddressGroupSpec = &spec
 
-	return manifests, nil
+	case KindServiceGroup:
+		var spec ServiceGroupSpec
+		if err := node.Decode(&spec); err != nil {
+			return fmt.Errorf("decode ServiceGroup spec: %w", err)
+		}
+		m.ServiceGroupSpec = &spec
+
+	case KindServiceDefinition:
+		var spec ServiceDefinitionSpec
+		if err := node.Decode(&spec); err != nil {
+			return fmt.Errorf("decode ServiceDefinition spec: %w", err)
+		}
+		m.ServiceSpec = &spec
+
+	case KindPolicyTemplate:
+		var spec PolicyTemplateSpec
+		if err := node.Decode(&spec); err != nil {
+			return fmt.Errorf("decode PolicyTemplate spec: %w", err)
+		}
+		m.TemplateSpec = &spec
+
+	case KindPolicyValues:
+		var spec PolicyValuesSpec
+		if err := node.Decode(&spec); err != nil {
+			return fmt.Errorf("decode PolicyValues spec: %w", err)
+		}
+		m.ValuesSpec = &spec
+
+	default:
+		if _, ok := lookupKind(m.Kind); !ok {
+			return fmt.Errorf("unknown Kind %q", m.Kind)
+		}
+		spec := map[string]any{}
+		if err := node.Decode(&spec); err != nil {
+			return fmt.Errorf("decode %s spec: %w", m.Kind, err)
+		}
+		m.PluginSpec = spec
+	}
+	return nil
 }
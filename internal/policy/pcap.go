@@ -0,0 +1,180 @@
+package policy
+
+import (
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net/netip"
+	"strconv"
+	"time"
+)
+
+// Link types of the classic pcap format understood by FlowsFromPcap.
+const (
+	linkEthernet = 1
+	linkRaw      = 101
+	linkLinuxSLL = 113
+)
+
+// FlowsFromPcap reads a classic libpcap capture and returns one new Flow
+// per connection, taken from its first packet. Packets in the reply
+// direction of a known connection are skipped, and so are non-IP frames,
+// fragments and anything cut short. pcapng is not supported; convert with
+// `editcap -F pcap`. At most limit flows are returned (0 means no limit).
+func FlowsFromPcap(r io.Reader, limit int) ([]Flow, error) {
+	br := bufio.NewReader(r)
+	var hdr [24]byte
+	if _, err := io.ReadFull(br, hdr[:]); err != nil {
+		return nil, fmt.Errorf("read pcap header: %w", err)
+	}
+
+	var order binary.ByteOrder
+	var nanos bool
+	switch binary.LittleEndian.Uint32(hdr[0:4]) {
+	case 0xa1b2c3d4:
+		order = binary.LittleEndian
+	case 0xa1b23c4d:
+		order, nanos = binary.LittleEndian, true
+	case 0xd4c3b2a1:
+		order = binary.BigEndian
+	case 0x4d3cb2a1:
+		order, nanos = binary.BigEndian, true
+	case 0x0a0d0d0a:
+		return nil, fmt.Errorf("pcapng is not supported; convert with editcap -F pcap")
+	default:
+		return nil, fmt.Errorf("not a pcap file")
+	}
+	link := order.Uint32(hdr[20:24])
+	switch link {
+	case linkEthernet, linkRaw, linkLinuxSLL:
+	default:
+		return nil, fmt.Errorf("unsupported pcap link type %d", link)
+	}
+
+	seen := make(map[string]bool)
+	var flows []Flow
+	var rec [16]byte
+	for limit <= 0 || len(flows) < limit {
+		if _, err := io.ReadFull(br, rec[:]); err != nil {
+			if errors.Is(err, io.EOF) {
+				break
+			}
+			return nil, fmt.Errorf("read pcap record: %w", err)
+		}
+		sec, frac := order.Uint32(rec[0:4]), order.Uint32(rec[4:8])
+		size := order.Uint32(rec[8:12])
+		if size > 1<<18 {
+			return nil, fmt.Errorf("pcap record of %d bytes", size)
+		}
+		data := make([]byte, size)
+		if _, err := io.ReadFull(br, data); err != nil {
+			return nil, fmt.Errorf("read pcap record: %w", err)
+		}
+
+		f, ok := decodePacket(link, data)
+		if !ok {
+			continue
+		}
+		key := flowKey(f.Protocol, f.Src, f.SrcPort, f.Dst, f.DstPort)
+		if seen[key] || seen[flowKey(f.Protocol, f.Dst, f.DstPort, f.Src, f.SrcPort)] {
+			continue
+		}
+		seen[key] = true
+
+		nsec := int64(frac) * 1000
+		if nanos {
+			nsec = int64(frac)
+		}
+		f.At = time.Unix(int64(sec), nsec).UTC()
+		f.State = "new"
+		flows = append(flows, f)
+	}
+	return flows, nil
+}
+
+// decodePacket extracts addresses, protocol and ports from one frame.
+func decodePacket(link uint32, data []byte) (Flow, bool) {
+	var etherType uint16
+	switch link {
+	case linkEthernet:
+		if len(data) < 14 {
+			return Flow{}, false
+		}
+		etherType, data = binary.BigEndian.Uint16(data[12:14]), data[14:]
+		for etherType == 0x8100 || etherType == 0x88a8 { // VLAN tags
+			if len(data) < 4 {
+				return Flow{}, false
+			}
+			etherType, data = binary.BigEndian.Uint16(data[2:4]), data[4:]
+		}
+	case linkLinuxSLL:
+		if len(data) < 16 {
+			return Flow{}, false
+		}
+		etherType, data = binary.BigEndian.Uint16(data[14:16]), data[16:]
+	case linkRaw:
+		if len(data) < 1 {
+			return Flow{}, false
+		}
+		etherType = 0x0800
+		if data[0]>>4 == 6 {
+			etherType = 0x86dd
+		}
+	}
+
+	var f Flow
+	var proto byte
+	switch etherType {
+	case 0x0800:
+		if len(data) < 20 || data[0]>>4 != 4 {
+			return Flow{}, false
+		}
+		ihl := int(data[0]&0x0f) * 4
+		if ihl < 20 || len(data) < ihl {
+			return Flow{}, false
+		}
+		if binary.BigEndian.Uint16(data[6:8])&0x1fff != 0 { // not the first fragment
+			return Flow{}, false
+		}
+		proto = data[9]
+		f.Src = netip.AddrFrom4([4]byte(data[12:16])).String()
+		f.Dst = netip.AddrFrom4([4]byte(data[16:20])).String()
+		data = data[ihl:]
+	case 0x86dd:
+		if len(data) < 40 {
+			return Flow{}, false
+		}
+		proto = data[6]
+		f.Src = netip.AddrFrom16([16]byte(data[8:24])).String()
+		f.Dst = netip.AddrFrom16([16]byte(data[24:40])).String()
+		data = data[40:]
+	default:
+		return Flow{}, false
+	}
+
+	switch proto {
+	case 6, 17:
+		f.Protocol = "tcp"
+		if proto == 17 {
+			f.Protocol = "udp"
+		}
+		if len(data) < 4 {
+			return Flow{}, false
+		}
+		f.SrcPort = int(binary.BigEndian.Uint16(data[0:2]))
+		f.DstPort = int(binary.BigEndian.Uint16(data[2:4]))
+	case 1:
+		f.Protocol = "icmp"
+	case 58:
+		f.Protocol = "icmpv6"
+	default:
+		f.Protocol = strconv.Itoa(int(proto))
+	}
+	return f, true
+}
+
+func flowKey(proto, src string, sport int, dst string, dport int) string {
+	return fmt.Sprintf("%s|%s|%d|%s|%d", proto, src, sport, dst, dport)
+}
@@ -0,0 +1,63 @@
+package policy
+
+import (
+	"fmt"
+	"sync"
+
+	"github.com/aegisx/aegisx/pkg/plugin"
+)
+
+var builtinKinds = map[string]bool{
+	KindFirewallPolicy:     true,
+	KindLoadBalancerPolicy: true,
+	KindVPNPolicy:          true,
+	KindNATPolicy:          true,
+	KindIDSPolicy:          true,
+	KindHealthCheckPolicy:  true,
+	KindWANPolicy:          true,
+	KindAppControlPolicy:   true,
+	KindPolicyTest:         true,
+	KindAddressGroup:       true,
+	KindServiceGroup:       true,
+	KindServiceDefinition:  true,
+	KindPolicyTemplate:     true,
+	KindPolicyValues:       true,
+	KindVRFPolicy:          true,
+}
+
+var (
+	pluginKindsMu sync.RWMutex
+	pluginKinds   = make(map[string]plugin.Kind)
+)
+
+// RegisterKind makes a plugin kind known to every Parser, Validator and
+// Engine. Call it at startup, before any manifest is parsed.
+func RegisterKind(k plugin.Kind) error {
+	name := k.Kind()
+	if name == "" || builtinKinds[name] {
+		return fmt.Errorf("plugin kind %q conflicts with a built-in kind", name)
+	}
+	pluginKindsMu.Lock()
+	defer pluginKindsMu.Unlock()
+	if _, dup := pluginKinds[name]; dup {
+		return fmt.Errorf("plugin kind %q registered twice", name)
+	}
+	pluginKinds[name] = k
+	return nil
+}
+
+func lookupKind(name string) (plugin.Kind, bool) {
+	pluginKindsMu.RLock()
+	defer pluginKindsMu.RUnlock()
+	k, ok := pluginKinds[name]
+	return k, ok
+}
+
+func pluginMetadata(m Metadata) plugin.Metadata {
+	return plugin.Metadata{
+		Name:        m.Name,
+		Namespace:   m.Namespace,
+		Labels:      m.Labels,
+		Annotations: m.Annotations,
+	}
+}
@@ -0,0 +1,383 @@
+package policy
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"reflect"
+	"slices"
+	"sort"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+// JSONSchema is a JSON Schema (draft 2020-12) document, limited to the
+// keywords the schemas of the policy kinds use.
+type JSONSchema struct {
+	Schema     string                 `json:"$schema,omitempty"`
+	Title      string                 `json:"title,omitempty"`
+	Type       string                 `json:"type,omitempty"`
+	Format     string                 `json:"format,omitempty"`
+	Const      string                 `json:"const,omitempty"`
+	Properties map[string]*JSONSchema `json:"properties,omitempty"`
+	Required   []string               `json:"required,omitempty"`
+	Items      *JSONSchema            `json:"items,omitempty"`
+	OneOf      []*JSONSchema          `json:"oneOf,omitempty"`
+
+	// AdditionalProperties is the schema of the values of a map. Closed
+	// objects, those of structs, take no keys but their properties.
+	AdditionalProperties *JSONSchema `json:"additionalProperties,omitempty"`
+	Closed               bool        `json:"-"`
+}
+
+// MarshalJSON writes Closed as "additionalProperties": false.
+func (s *JSONSchema) MarshalJSON() ([]byte, error) {
+	type plain JSONSchema
+	if !s.Closed {
+		return json.Marshal((*plain)(s))
+	}
+	return json.Marshal(struct {
+		*plain
+		AdditionalProperties bool `json:"additionalProperties"`
+	}{plain: (*plain)(s)})
+}
+
+// specTypes are the spec types of the built-in kinds.
+var specTypes = map[string]reflect.Type{
+	KindFirewallPolicy:     reflect.TypeOf(FirewallPolicySpec{}),
+	KindLoadBalancerPolicy: reflect.TypeOf(LoadBalancerPolicySpec{}),
+	KindVPNPolicy:          reflect.TypeOf(VPNPolicySpec{}),
+	KindNATPolicy:          reflect.TypeOf(NATPolicySpec{}),
+	KindIDSPolicy:          reflect.TypeOf(IDSPolicySpec{}),
+	KindHealthCheckPolicy:  reflect.TypeOf(HealthCheckPolicySpec{}),
+	KindWANPolicy:          reflect.TypeOf(WANPolicySpec{}),
+	KindAppControlPolicy:   reflect.TypeOf(AppControlPolicySpec{}),
+	KindPolicyTest:         reflect.TypeOf(PolicyTestSpec{}),
+	KindAddressGroup:       reflect.TypeOf(AddressGroupSpec{}),
+	KindServiceGroup:       reflect.TypeOf(ServiceGroupSpec{}),
+	KindServiceDefinition:  reflect.TypeOf(ServiceDefinitionSpec{}),
+	KindPolicyTemplate:     reflect.TypeOf(PolicyTemplateSpec{}),
+	KindPolicyValues:       reflect.TypeOf(PolicyValuesSpec{}),
+	KindVRFPolicy:          reflect.TypeOf(VRFPolicySpec{}),
+}
+
+// SchemaKinds lists the kinds Schema describes: the built-in ones and
+// those registered by plugins, sorted.
+func SchemaKinds() []string {
+	kinds := make([]string, 0, len(specTypes))
+	for k := range specTypes {
+		kinds = append(kinds, k)
+	}
+	pluginKindsMu.RLock()
+	for k := range pluginKinds {
+		kinds = append(kinds, k)
+	}
+	pluginKindsMu.RUnlock()
+	sort.Strings(kinds)
+	return kinds
+}
+
+// Schema returns the JSON Schema of manifests of kind, generated from its
+// spec type, and false for an unknown kind. Plugin kinds validate their
+// own specs, so their schema takes any object as spec.
+func Schema(kind string) (*JSONSchema, bool) {
+	spec := &JSONSchema{Type: "object"}
+	if t, ok := specTypes[kind]; ok {
+		spec = schemaOf(t, nil)
+	} else if _, ok := lookupKind(kind); !ok {
+		return nil, false
+	}
+	meta := schemaOf(reflect.TypeOf(Metadata{}), nil)
+	meta.Required = []string{"name"}
+	return &JSONSchema{
+		Schema: "https://json-schema.org/draft/2020-12/schema",
+		Title:  kind,
+		Type:   "object",
+		Properties: map[string]*JSONSchema{
+			"apiVersion": {Type: "string", Const: APIVersion},
+			"kind":       {Type: "string", Const: kind},
+			"metadata":   meta,
+			"spec":       spec,
+		},
+		Required: []string{"apiVersion", "kind", "metadata", "spec"},
+		Closed:   true,
+	}, true
+}
+
+var (
+	timeType       = reflect.TypeOf(time.Time{})
+	natTargetsType = reflect.TypeOf(NATTargets{})
+	selectorType   = reflect.TypeOf(TrafficSelector{})
+)
+
+// schemaOf describes the values yaml.v3 decodes into t. Types seen on
+// stack, i.e. recursive ones, take any value.
+func schemaOf(t reflect.Type, stack []reflect.Type) *JSONSchema {
+	for t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+	switch t {
+	case nodeType:
+		return &JSONSchema{}
+	case timeType:
+		return &JSONSchema{Type: "string", Format: "date-time"}
+	case natTargetsType:
+		target := schemaOf(reflect.TypeOf(NATTarget{}), stack)
+		return &JSONSchema{OneOf: []*JSONSchema{
+			{Type: "string"},
+			{Type: "array", Items: &JSONSchema{OneOf: []*JSONSchema{{Type: "string"}, target}}},
+		}}
+	}
+	if slices.Contains(stack, t) {
+		return &JSONSchema{}
+	}
+	switch t.Kind() {
+	case reflect.String:
+		return &JSONSchema{Type: "string"}
+	case reflect.Bool:
+		return &JSONSchema{Type: "boolean"}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return &JSONSchema{Type: "integer"}
+	case reflect.Float32, reflect.Float64:
+		return &JSONSchema{Type: "number"}
+	case reflect.Slice, reflect.Array:
+		return &JSONSchema{Type: "array", Items: schemaOf(t.Elem(), stack)}
+	case reflect.Map:
+		return &JSONSchema{Type: "object", AdditionalProperties: schemaOf(t.Elem(), stack)}
+	case reflect.Struct:
+		s := &JSONSchema{Type: "object", Properties: make(map[string]*JSONSchema), Closed: true}
+		for name, ft := range yamlFields(t) {
+			s.Properties[name] = schemaOf(ft, append(stack, t))
+		}
+		if t == selectorType {
+			// Ports may name ServiceDefinitions; see UnmarshalYAML.
+			s.Properties["ports"].Items = &JSONSchema{OneOf: []*JSONSchema{{Type: "integer"}, {Type: "string"}}}
+		}
+		return s
+	}
+	return &JSONSchema{} // interfaces take any value
+}
+
+// SchemaError is a value its schema does not allow, at its position in the
+// document.
+type SchemaError struct {
+	Line    int    `json:"line"`
+	Column  int    `json:"column"`
+	Path    string `json:"path"` // e.g. spec.rules[0].priority
+	Message string `json:"message"`
+}
+
+func (e *SchemaError) Error() string {
+	path := e.Path
+	if path == "" {
+		path = "document"
+	}
+	return fmt.Sprintf("line %d, column %d: %s: %s", e.Line, e.Column, path, e.Message)
+}
+
+// ValidateSchema checks spec, a JSON or YAML document, against the spec
+// schema of kind and returns what it does not allow. The error is for a
+// kind Schema does not know or a spec that does not parse.
+func ValidateSchema(kind string, spec []byte) ([]*SchemaError, error) {
+	s, ok := Schema(kind)
+	if !ok {
+		return nil, fmt.Errorf("unknown Kind %q", kind)
+	}
+	docs, err := schemaDocuments(spec)
+	if err != nil {
+		return nil, fmt.Errorf("parse spec: %w", err)
+	}
+	if len(docs) != 1 {
+		return nil, fmt.Errorf("parse spec: want one document, got %d", len(docs))
+	}
+	var errs []*SchemaError
+	checkSchema(docs[0], s.Properties["spec"], "spec", &errs)
+	return errs, nil
+}
+
+// ValidateManifestSchema checks each manifest of data, in YAML or JSON as
+// Parser.Parse reads it, against the schema of its kind. Templates are not
+// rendered; a PolicyTemplate's template takes any value.
+func ValidateManifestSchema(data []byte) ([]*SchemaError, error) {
+	docs, err := schemaDocuments(data)
+	if err != nil {
+		return nil, fmt.Errorf("parse manifest: %w", err)
+	}
+	var errs []*SchemaError
+	for _, n := range docs {
+		items := []*yaml.Node{n}
+		if n.Kind == yaml.SequenceNode && json.Valid(data) {
+			items = n.Content
+		}
+		for _, item := range items {
+			kind := mappingValue(item, "kind")
+			if kind == nil {
+				errs = append(errs, &SchemaError{Line: item.Line, Column: item.Column, Path: "kind", Message: "is required"})
+				continue
+			}
+			s, ok := Schema(kind.Value)
+			if !ok {
+				errs = append(errs, &SchemaError{Line: kind.Line, Column: kind.Column, Path: "kind",
+					Message: fmt.Sprintf("unknown Kind %q", kind.Value)})
+				continue
+			}
+			checkSchema(item, s, "", &errs)
+		}
+	}
+	return errs, nil
+}
+
+// schemaDocuments returns the root nodes of the documents of data, read as
+// JSON when it is valid JSON and as YAML otherwise.
+func schemaDocuments(data []byte) ([]*yaml.Node, error) {
+	if json.Valid(data) {
+		n, err := jsonNode(data)
+		if err != nil {
+			return nil, err
+		}
+		return []*yaml.Node{n}, nil
+	}
+	var docs []*yaml.Node
+	dec := yaml.NewDecoder(bytes.NewReader(data))
+	for {
+		var doc yaml.Node
+		if err := dec.Decode(&doc); err == io.EOF {
+			return docs, nil
+		} else if err != nil {
+			return nil, err
+		}
+		if len(doc.Content) == 1 {
+			docs = append(docs, doc.Content[0])
+		}
+	}
+}
+
+// mappingValue returns the value of key in the mapping n, or nil.
+func mappingValue(n *yaml.Node, key string) *yaml.Node {
+	if n.Kind != yaml.MappingNode {
+		return nil
+	}
+	for i := 0; i+1 < len(n.Content); i += 2 {
+		if n.Content[i].Value == key {
+			return n.Content[i+1]
+		}
+	}
+	return nil
+}
+
+// checkSchema appends an error for each value under n that s does not
+// allow. A null is taken as the value being absent, as the decoder does.
+func checkSchema(n *yaml.Node, s *JSONSchema, path string, errs *[]*SchemaError) {
+	for n.Kind == yaml.AliasNode {
+		n = n.Alias
+	}
+	bad := func(n *yaml.Node, path, format string, args ...any) {
+		*errs = append(*errs, &SchemaError{Line: n.Line, Column: n.Column, Path: path, Message: fmt.Sprintf(format, args...)})
+	}
+	tag := n.ShortTag()
+	if tag == "!!null" {
+		return
+	}
+
+	if len(s.OneOf) > 0 {
+		var types []string
+		for _, alt := range s.OneOf {
+			var altErrs []*SchemaError
+			checkSchema(n, alt, path, &altErrs)
+			if len(altErrs) == 0 {
+				return
+			}
+			if alt.Type == "array" && n.Kind == yaml.SequenceNode || alt.Type == "object" && n.Kind == yaml.MappingNode {
+				*errs = append(*errs, altErrs...) // the shape matches; report why the contents do not
+				return
+			}
+			types = append(types, alt.Type)
+		}
+		bad(n, path, "must be one of: %s", strings.Join(types, ", "))
+		return
+	}
+
+	switch s.Type {
+	case "object":
+		if n.Kind != yaml.MappingNode {
+			bad(n, path, "must be an object")
+			return
+		}
+		present := make(map[string]bool)
+		for i := 0; i+1 < len(n.Content); i += 2 {
+			k, v := n.Content[i], n.Content[i+1]
+			if k.Value == "<<" { // merge key
+				continue
+			}
+			present[k.Value] = true
+			sub := s.Properties[k.Value]
+			if sub == nil {
+				sub = s.AdditionalProperties
+			}
+			if sub != nil {
+				checkSchema(v, sub, joinPath(path, k.Value), errs)
+				continue
+			}
+			if s.Closed {
+				names := make([]string, 0, len(s.Properties))
+				for name := range s.Properties {
+					names = append(names, name)
+				}
+				msg := "unknown field"
+				if hint := closest(k.Value, names); hint != "" {
+					msg += fmt.Sprintf(" (did you mean %q?)", hint)
+				}
+				bad(k, joinPath(path, k.Value), "%s", msg)
+			}
+		}
+		for _, r := range s.Required {
+			if !present[r] {
+				bad(n, joinPath(path, r), "is required")
+			}
+		}
+	case "array":
+		if n.Kind != yaml.SequenceNode {
+			bad(n, path, "must be an array")
+			return
+		}
+		for i, item := range n.Content {
+			checkSchema(item, s.Items, fmt.Sprintf("%s[%d]", path, i), errs)
+		}
+	case "string":
+		if n.Kind != yaml.ScalarNode || (tag != "!!str" && tag != "!!timestamp") {
+			bad(n, path, "must be a string")
+			return
+		}
+		if s.Format == "date-time" {
+			if _, err := time.Parse(time.RFC3339, n.Value); err != nil {
+				bad(n, path, "must be a date-time such as 2006-01-02T15:04:05Z")
+			}
+		}
+		if s.Const != "" && n.Value != s.Const {
+			bad(n, path, "must be %q", s.Const)
+		}
+	case "integer":
+		if n.Kind != yaml.ScalarNode || tag != "!!int" {
+			bad(n, path, "must be an integer")
+		}
+	case "number":
+		if n.Kind != yaml.ScalarNode || (tag != "!!int" && tag != "!!float") {
+			bad(n, path, "must be a number")
+		}
+	case "boolean":
+		if n.Kind != yaml.ScalarNode || tag != "!!bool" {
+			bad(n, path, "must be a boolean")
+		}
+	}
+}
+
+func joinPath(path, key string) string {
+	if path == "" {
+		return key
+	}
+	return path + "." + key
+}
@@ -0,0 +1,114 @@
+package policy
+
+import (
+	"fmt"
+	"strconv"
+
+	"gopkg.in/yaml.v3"
+)
+
+// UnmarshalYAML lets ports list ServiceDefinition names next to numbers:
+// the names are moved to Services before the selector is decoded.
+func (s *TrafficSelector) UnmarshalYAML(n *yaml.Node) error {
+	type plain TrafficSelector
+	if n.Kind == yaml.MappingNode {
+		n = serviceNamesOut(n)
+	}
+	return n.Decode((*plain)(s))
+}
+
+// serviceNamesOut returns a copy of the selector mapping n whose ports hold
+// only numbers, with the names among them appended to services.
+func serviceNamesOut(n *yaml.Node) *yaml.Node {
+	var names []*yaml.Node
+	out := *n
+	out.Content = nil
+	var services *yaml.Node
+	for i := 0; i+1 < len(n.Content); i += 2 {
+		key, val := n.Content[i], n.Content[i+1]
+		switch {
+		case key.Value == "ports" && val.Kind == yaml.SequenceNode:
+			ports := *val
+			ports.Content = nil
+			for _, p := range val.Content {
+				if _, err := strconv.Atoi(p.Value); p.Kind == yaml.ScalarNode && err != nil {
+					names = append(names, p)
+					continue
+				}
+				ports.Content = append(ports.Content, p)
+			}
+			val = &ports
+		case key.Value == "services" && val.Kind == yaml.SequenceNode:
+			cp := *val
+			services = &cp
+			val = services
+		}
+		out.Content = append(out.Content, key, val)
+	}
+	if len(names) == 0 {
+		return &out
+	}
+	if services == nil {
+		services = &yaml.Node{Kind: yaml.SequenceNode, Tag: "!!seq"}
+		out.Content = append(out.Content, &yaml.Node{Kind: yaml.ScalarNode, Tag: "!!str", Value: "services"}, services)
+	}
+	services.Content = append(append([]*yaml.Node(nil), services.Content...), names...)
+	return &out
+}
+
+// compileServices collects the ServiceDefinition manifests by
+// "namespace/name".
+func compileServices(manifests []*Manifest) map[string]*ServiceDefinitionSpec {
+	defs := make(map[string]*ServiceDefinitionSpec)
+	for _, m := range manifests {
+		if m.Kind == KindServiceDefinition && m.ServiceSpec != nil {
+			defs[m.Metadata.Namespace+"/"+m.Metadata.Name] = m.ServiceSpec
+		}
+	}
+	return defs
+}
+
+// servicePorts resolves the ServiceDefinitions a selector names to their
+// protocol and ports. Every name must exist and all must share a protocol;
+// the validator reports violations, this only refuses them.
+func (gs *groupSets) servicePorts(ns string, names []string) (string, []string, error) {
+	var proto string
+	var ports []string
+	for _, name := range names {
+		def, ok := gs.defs[groupKey(ns, name)]
+		if !ok {
+			return "", nil, fmt.Errorf("unknown %s %q", KindServiceDefinition, name)
+		}
+		if proto != "" && def.Protocol != proto {
+			return "", nil, fmt.Errorf("%s %q is %s, not %s", KindServiceDefinition, name, def.Protocol, proto)
+		}
+		proto = def.Protocol
+		ports = append(ports, compilePorts(def.Ports, def.PortRanges)...)
+	}
+	return proto, ports, nil
+}
+
+// resolveServices adds the ports of the ServiceDefinitions src and dst
+// name to rule, which takes their protocol if it has none.
+func (gs *groupSets) resolveServices(ns string, rule *CompiledFirewallRule, src, dst TrafficSelector) error {
+	srcProto, srcPorts, err := gs.servicePorts(ns, src.Services)
+	if err != nil {
+		return err
+	}
+	dstProto, dstPorts, err := gs.servicePorts(ns, dst.Services)
+	if err != nil {
+		return err
+	}
+	for _, p := range []string{srcProto, dstProto} {
+		switch {
+		case p == "":
+		case rule.Protocol == "":
+			rule.Protocol = p
+		case rule.Protocol != p:
+			return fmt.Errorf("services are %s but the rule matches %s", p, rule.Protocol)
+		}
+	}
+	rule.SrcPorts = append(rule.SrcPorts, srcPorts...)
+	rule.DstPorts = append(rule.DstPorts, dstPorts...)
+	return nil
+}
@@ -0,0 +1,362 @@
+package policy
+
+import (
+	"net"
+	"net/netip"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Flow is a synthetic connection to run through a compiled ruleset.
+type Flow struct {
+	Name      string    `yaml:"name"      json:"name,omitempty"`
+	Direction string    `yaml:"direction" json:"direction,omitempty"` // input|output|forward (default forward)
+	Iface     string    `yaml:"iface"     json:"iface,omitempty"`     // inbound interface; "lo" is always accepted on input
+	OutIface  string    `yaml:"outIface"  json:"outIface,omitempty"`  // outbound interface, for source NAT
+	Src       string    `yaml:"src"       json:"src"`
+	Dst       string    `yaml:"dst"       json:"dst"`
+	Protocol  string    `yaml:"protocol"  json:"protocol,omitempty"` // tcp|udp|icmp|…
+	SrcPort   int       `yaml:"srcPort"   json:"srcPort,omitempty"`
+	DstPort   int       `yaml:"dstPort"   json:"dstPort,omitempty"`
+	State     string    `yaml:"state"     json:"state,omitempty"`  // new (default)|established|related|invalid
+	At        time.Time `yaml:"at"        json:"at,omitempty"`     // evaluation time for scheduled rules; zero means now
+	Expect    string    `yaml:"expect"    json:"expect,omitempty"` // expected verdict, if any
+
+	// Countries of Src and Dst, for rules selecting countries; the GeoIP
+	// database is not consulted, so a flow without them matches none.
+	SrcCountry string `yaml:"srcCountry,omitempty" json:"srcCountry,omitempty"`
+	DstCountry string `yaml:"dstCountry,omitempty" json:"dstCountry,omitempty"`
+
+	// SrcMAC is the Ethernet source address, for rules matching MAC
+	// addresses; a flow without it matches none.
+	SrcMAC string `yaml:"srcMac,omitempty" json:"srcMac,omitempty"`
+
+	// NetNS is the network namespace the flow passes through; empty for
+	// the host. Only the rules targeting that namespace apply.
+	NetNS string `yaml:"netns,omitempty" json:"netns,omitempty"`
+
+	// VRF is the VRF the flow is routed in. When empty it is the VRF of
+	// Iface, or of OutIface for output flows.
+	VRF string `yaml:"vrf,omitempty" json:"vrf,omitempty"`
+}
+
+// FlowResult is the outcome of simulating one Flow.
+type FlowResult struct {
+	Flow     Flow   `json:"flow"`
+	Verdict  string `json:"verdict"` // accept|drop|reject|tarpit
+	Chain    string `json:"chain"`
+	Rule     string `json:"rule"` // comment of the deciding rule, or the chain policy
+	Priority int    `json:"priority,omitempty"`
+	Logged   bool   `json:"logged,omitempty"`
+	Mark     string `json:"mark,omitempty"` // set by mark rules on the way
+	DSCP     string `json:"dscp,omitempty"`
+	Pass     *bool  `json:"pass,omitempty"` // set when the flow has an expected verdict
+}
+
+// Chain policies of the generated nftables table.
+var defaultVerdicts = map[string]string{
+	"input":   "drop",
+	"forward": "drop",
+	"output":  "accept",
+}
+
+// Simulate walks the firewall rules of ir the way the generated nftables
+// ruleset would and returns the verdict for f. It assumes every rate and
+// connection limit is below its threshold and ignores rule conditions;
+// callers that care pass an IR already gated on health state.
+func (ir *IR) Simulate(f Flow) FlowResult {
+	return ir.simulate(f, nil)
+}
+
+// simulate is Simulate calling onMatch with every rule the flow matches, in
+// evaluation order.
+func (ir *IR) simulate(f Flow, onMatch func(CompiledFirewallRule)) FlowResult {
+	chain := f.Direction
+	if _, ok := defaultVerdicts[chain]; !ok {
+		chain = "forward"
+	}
+	state := strings.ToLower(f.State)
+	if state == "" {
+		state = "new"
+	}
+	at := f.At
+	if at.IsZero() {
+		at = time.Now()
+	}
+
+	res := FlowResult{Flow: f, Chain: chain}
+	decide := func(verdict, rule string, priority int) FlowResult {
+		res.Verdict, res.Rule, res.Priority = verdict, rule, priority
+		if f.Expect != "" {
+			pass := strings.EqualFold(f.Expect, verdict)
+			res.Pass = &pass
+		}
+		return res
+	}
+
+	src, _ := netip.ParseAddr(f.Src)
+	dst, _ := netip.ParseAddr(f.Dst)
+	vrf := ir.flowVRF(f, chain == "output")
+
+	// Mark and dscp rules see every packet before the filter chains do,
+	// and the last one to match wins.
+	for _, r := range ir.FirewallRules {
+		if !r.marking() || r.Chain != chain || r.NetNS != f.NetNS || (r.VRF != "" && r.VRF != vrf) ||
+			!r.matches(ir, f, src, dst, state, at) {
+			continue
+		}
+		if onMatch != nil {
+			onMatch(r)
+		}
+		if r.Log {
+			res.Logged = true
+		}
+		if r.Action == "mark" {
+			res.Mark = r.Mark
+		} else {
+			res.DSCP = r.DSCP
+		}
+	}
+
+	// Fixed rules at the head of each chain.
+	switch {
+	case state == "invalid" && chain != "output":
+		return decide("drop", "drop invalid", 0)
+	case state == "established" || state == "related":
+		return decide("accept", "accept established", 0)
+	case chain == "input" && f.Iface == "lo":
+		return decide("accept", "loopback", 0)
+	}
+
+	// walk evaluates the base chain, or the user-defined chain userChain,
+	// and returns the rule deciding the flow, or nil if the flow falls off
+	// its end or hits a return. The validator rejects loops of chains.
+	var walk func(userChain string) *CompiledFirewallRule
+	walk = func(userChain string) *CompiledFirewallRule {
+		for i := range ir.FirewallRules {
+			r := &ir.FirewallRules[i]
+			if r.UserChain != userChain || r.NetNS != f.NetNS || !r.matches(ir, f, src, dst, state, at) {
+				continue
+			}
+			if userChain == "" && (ruleChain(r) != chain || (r.VRF != "" && r.VRF != vrf)) {
+				continue
+			}
+			if onMatch != nil {
+				onMatch(*r)
+			}
+			if r.Log {
+				res.Logged = true
+			}
+			switch r.Action {
+			case "log":
+				res.Logged = true
+				continue
+			case "jump":
+				if d := walk(r.Target); d != nil {
+					return d
+				}
+				continue
+			case "goto":
+				return walk(r.Target)
+			case "return":
+				return nil
+			}
+			return r
+		}
+		return nil
+	}
+	if r := walk(""); r != nil {
+		return decide(r.Action, r.Comment, r.Priority)
+	}
+	return decide(defaultVerdicts[chain], "policy "+defaultVerdicts[chain], 0)
+}
+
+// flowVRF returns the VRF f is in: f.VRF if set, and otherwise that of its
+// output interface if out, or of its input interface.
+func (ir *IR) flowVRF(f Flow, out bool) string {
+	switch {
+	case f.VRF != "":
+		return f.VRF
+	case out:
+		return ir.VRFOf(f.OutIface)
+	}
+	return ir.VRFOf(f.Iface)
+}
+
+// matches reports whether every match expression of r holds for the flow.
+// Named sets are looked up in ir.
+func (r CompiledFirewallRule) matches(ir *IR, f Flow, src, dst netip.Addr, state string, at time.Time) bool {
+	if r.Protocol != "" && !strings.EqualFold(r.Protocol, f.Protocol) {
+		return false
+	}
+	// Only accept rules match connections within their limit.
+	if r.ConnLimit > 0 && r.Action != "accept" {
+		return false
+	}
+	if len(r.InIfaces) > 0 && !ifaceIn(f.Iface, r.InIfaces) {
+		return false
+	}
+	if len(r.OutIfaces) > 0 && !ifaceIn(f.OutIface, r.OutIfaces) {
+		return false
+	}
+	if len(r.SrcMACs) > 0 && !macIn(f.SrcMAC, r.SrcMACs) {
+		return false
+	}
+	if cs, ok := ir.CountrySet(r.SrcSet); ok && !cs.holds(src, f.SrcCountry) {
+		return false
+	}
+	if cs, ok := ir.CountrySet(r.DstSet); ok && !cs.holds(dst, f.DstCountry) {
+		return false
+	}
+	// FQDN sets match the addresses last resolved, if any.
+	if fs, ok := ir.FQDNSet(r.SrcSet); ok && !addrIn(src, fs.Addresses) {
+		return false
+	}
+	if fs, ok := ir.FQDNSet(r.DstSet); ok && !addrIn(dst, fs.Addresses) {
+		return false
+	}
+	srcAddrs, dstAddrs := ir.setOr(r.SrcSet, r.SrcAddrs), ir.setOr(r.DstSet, r.DstAddrs)
+	srcPorts, dstPorts := ir.setOr(r.SrcPortSet, r.SrcPorts), ir.setOr(r.DstPortSet, r.DstPorts)
+	if len(srcAddrs) > 0 && !addrIn(src, srcAddrs) {
+		return false
+	}
+	if len(dstAddrs) > 0 && !addrIn(dst, dstAddrs) {
+		return false
+	}
+	if len(srcPorts) > 0 && !portIn(f.SrcPort, srcPorts) {
+		return false
+	}
+	if len(dstPorts) > 0 && !portIn(f.DstPort, dstPorts) {
+		return false
+	}
+	if len(r.States) > 0 && !contains(r.States, state) {
+		return false
+	}
+	if r.Schedule != nil && !scheduleActive(r.Schedule, at.In(time.Local)) {
+		return false
+	}
+	if r.ExpiresAt != nil && !at.Before(*r.ExpiresAt) {
+		return false
+	}
+	return true
+}
+
+// setOr returns the elements of the named set, or list when set is empty.
+func (ir *IR) setOr(set string, list []string) []string {
+	if set == "" {
+		return list
+	}
+	g, _ := ir.Group(set)
+	return g.Elements
+}
+
+// holds reports whether a, placed in country, is an element of the set.
+func (cs CompiledCountrySet) holds(a netip.Addr, country string) bool {
+	if !a.IsValid() || (cs.Family == FamilyIPv6) != (a.Is6() && !a.Is4In6()) {
+		return false
+	}
+	return contains(cs.Countries, country)
+}
+
+// addrIn reports whether a matches any address, CIDR or "a-b" range. Like
+// nft's ip saddr, an entry never matches an address of the other family.
+func addrIn(a netip.Addr, list []string) bool {
+	if !a.IsValid() {
+		return false
+	}
+	for _, s := range list {
+		if lo, hi, ok := strings.Cut(s, "-"); ok {
+			from, err1 := netip.ParseAddr(strings.TrimSpace(lo))
+			to, err2 := netip.ParseAddr(strings.TrimSpace(hi))
+			if err1 == nil && err2 == nil && from.BitLen() == a.BitLen() &&
+				from.Compare(a) <= 0 && a.Compare(to) <= 0 {
+				return true
+			}
+			continue
+		}
+		if p, err := netip.ParsePrefix(s); err == nil {
+			if p.Contains(a) {
+				return true
+			}
+			continue
+		}
+		if b, err := netip.ParseAddr(s); err == nil && b == a {
+			return true
+		}
+	}
+	return false
+}
+
+// ifaceIn reports whether name matches any of the names, which may end
+// in a * wildcard.
+func ifaceIn(name string, names []string) bool {
+	if name == "" {
+		return false
+	}
+	for _, n := range names {
+		if prefix, ok := strings.CutSuffix(n, "*"); ok {
+			if strings.HasPrefix(name, prefix) {
+				return true
+			}
+		} else if n == name {
+			return true
+		}
+	}
+	return false
+}
+
+// macIn reports whether mac, in any notation, is one of macs.
+func macIn(mac string, macs []string) bool {
+	hw, err := net.ParseMAC(mac)
+	return err == nil && contains(macs, hw.String())
+}
+
+// portIn reports whether p matches any "80" or "8080-8090" entry.
+func portIn(p int, list []string) bool {
+	for _, s := range list {
+		lo, hi, ok := strings.Cut(s, "-")
+		if !ok {
+			hi = lo
+		}
+		from, err1 := strconv.Atoi(lo)
+		to, err2 := strconv.Atoi(hi)
+		if err1 == nil && err2 == nil && from <= p && p <= to {
+			return true
+		}
+	}
+	return false
+}
+
+// scheduleActive mirrors the meta day / meta hour match of the schedule.
+func scheduleActive(s *RuleSchedule, t time.Time) bool {
+	if len(s.Days) > 0 && !contains(s.Days, t.Weekday().String()) {
+		return false
+	}
+	if s.Start == "" {
+		return true
+	}
+	start, end := minuteOfDay(s.Start), minuteOfDay(s.End)
+	now := t.Hour()*60 + t.Minute()
+	if start <= end {
+		return start <= now && now < end
+	}
+	return now >= start || now < end
+}
+
+func minuteOfDay(hhmm string) int {
+	t, err := time.Parse("15:04", hhmm)
+	if err != nil {
+		return 0
+	}
+	return t.Hour()*60 + t.Minute()
+}
+
+func contains(list []string, v string) bool {
+	for _, s := range list {
+		if strings.EqualFold(s, v) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,201 @@
+package policy
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"strings"
+	"sync"
+
+	"gopkg.in/yaml.v3"
+)
+
+// FieldError is a key a strict Parser does not know, at its position in
+// the document.
+type FieldError struct {
+	Line    int    `json:"line"`
+	Column  int    `json:"column"`
+	Path    string `json:"path"`              // e.g. spec.rules[0].destnation
+	Suggest string `json:"suggest,omitempty"` // the known key it most resembles
+}
+
+func (e *FieldError) Error() string {
+	msg := fmt.Sprintf("line %d, column %d: unknown field %s", e.Line, e.Column, e.Path)
+	if e.Suggest != "" {
+		msg += fmt.Sprintf(" (did you mean %q?)", e.Suggest)
+	}
+	return msg
+}
+
+// checkFields reports the unknown keys of docs when p is strict, naming the
+// manifest each belongs to.
+func (p *Parser) checkFields(docs []document) error {
+	if !p.strict {
+		return nil
+	}
+	var all []error
+	for _, d := range docs {
+		var errs []error
+		manifestFields(d.node, d.m, &errs)
+		if len(errs) > 0 {
+			all = append(all, fmt.Errorf("%s %s/%s: %w", d.m.Kind, d.m.Metadata.Namespace, d.m.Metadata.Name, errors.Join(errs...)))
+		}
+	}
+	return errors.Join(all...)
+}
+
+// manifestFields checks the top-level keys of a manifest document, its
+// metadata and its spec.
+func manifestFields(n *yaml.Node, m *Manifest, errs *[]error) {
+	if n.Kind == yaml.DocumentNode && len(n.Content) == 1 {
+		n = n.Content[0]
+	}
+	if n.Kind != yaml.MappingNode {
+		return
+	}
+	top := []string{"apiVersion", "kind", "metadata", "spec"}
+	for i := 0; i+1 < len(n.Content); i += 2 {
+		k, v := n.Content[i], n.Content[i+1]
+		switch k.Value {
+		case "apiVersion", "kind":
+		case "metadata":
+			unknownFields(v, reflect.TypeOf(Metadata{}), "metadata", errs)
+		case "spec":
+			unknownFields(v, specType(m), "spec", errs)
+		default:
+			*errs = append(*errs, &FieldError{Line: k.Line, Column: k.Column, Path: k.Value, Suggest: closest(k.Value, top)})
+		}
+	}
+}
+
+// specType is the type of m's typed spec; nil for plugin kinds, whose
+// specs take any key.
+func specType(m *Manifest) reflect.Type {
+	v := reflect.ValueOf(m).Elem()
+	for i := 0; i < v.NumField(); i++ {
+		if f := v.Field(i); f.Kind() == reflect.Pointer && !f.IsNil() {
+			return f.Type()
+		}
+	}
+	return nil
+}
+
+var nodeType = reflect.TypeOf(yaml.Node{})
+
+// unknownFields appends an error for each key under n that no field of t
+// takes. Nodes whose shape does not match t are left to the decoder, as
+// are types that decode raw nodes.
+func unknownFields(n *yaml.Node, t reflect.Type, path string, errs *[]error) {
+	for n != nil && n.Kind == yaml.AliasNode {
+		n = n.Alias
+	}
+	for t != nil && t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+	if n == nil || t == nil || t == nodeType {
+		return
+	}
+	switch t.Kind() {
+	case reflect.Struct:
+		if n.Kind != yaml.MappingNode {
+			return
+		}
+		fields := yamlFields(t)
+		for i := 0; i+1 < len(n.Content); i += 2 {
+			k, v := n.Content[i], n.Content[i+1]
+			if k.Value == "<<" { // merge key
+				continue
+			}
+			ft, ok := fields[k.Value]
+			if !ok {
+				names := make([]string, 0, len(fields))
+				for name := range fields {
+					names = append(names, name)
+				}
+				*errs = append(*errs, &FieldError{Line: k.Line, Column: k.Column, Path: path + "." + k.Value, Suggest: closest(k.Value, names)})
+				continue
+			}
+			unknownFields(v, ft, path+"."+k.Value, errs)
+		}
+	case reflect.Map:
+		if n.Kind != yaml.MappingNode {
+			return
+		}
+		for i := 0; i+1 < len(n.Content); i += 2 {
+			unknownFields(n.Content[i+1], t.Elem(), path+"."+n.Content[i].Value, errs)
+		}
+	case reflect.Slice, reflect.Array:
+		if n.Kind != yaml.SequenceNode {
+			return
+		}
+		for i, item := range n.Content {
+			unknownFields(item, t.Elem(), fmt.Sprintf("%s[%d]", path, i), errs)
+		}
+	}
+}
+
+var yamlFieldCache sync.Map // reflect.Type → map[string]reflect.Type
+
+// yamlFields maps the keys yaml.v3 decodes into struct type t to their
+// field types, including those of inlined structs.
+func yamlFields(t reflect.Type) map[string]reflect.Type {
+	if m, ok := yamlFieldCache.Load(t); ok {
+		return m.(map[string]reflect.Type)
+	}
+	m := make(map[string]reflect.Type)
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		if !f.IsExported() {
+			continue
+		}
+		tag := f.Tag.Get("yaml")
+		if tag == "-" {
+			continue
+		}
+		name, opts, _ := strings.Cut(tag, ",")
+		if strings.Contains(","+opts+",", ",inline,") && f.Type.Kind() == reflect.Struct {
+			for k, v := range yamlFields(f.Type) {
+				m[k] = v
+			}
+			continue
+		}
+		if name == "" {
+			name = strings.ToLower(f.Name)
+		}
+		m[name] = f.Type
+	}
+	yamlFieldCache.Store(t, m)
+	return m
+}
+
+// closest returns the name in names within two edits of key, if any.
+func closest(key string, names []string) string {
+	best, bestDist := "", 3
+	for _, name := range names {
+		if d := editDistance(strings.ToLower(key), strings.ToLower(name)); d < bestDist || (d == bestDist && name < best) {
+			best, bestDist = name, d
+		}
+	}
+	return best
+}
+
+// editDistance is the Levenshtein distance of a and b.
+func editDistance(a, b string) int {
+	prev := make([]int, len(b)+1)
+	cur := make([]int, len(b)+1)
+	for j := range prev {
+		prev[j] = j
+	}
+	for i := 1; i <= len(a); i++ {
+		cur[0] = i
+		for j := 1; j <= len(b); j++ {
+			cost := 1
+			if a[i-1] == b[j-1] {
+				cost = 0
+			}
+			cur[j] = min(prev[j]+1, cur[j-1]+1, prev[j-1]+cost)
+		}
+		prev, cur = cur, prev
+	}
+	return prev[len(b)]
+}
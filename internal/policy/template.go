@@ -0,0 +1,261 @@
+package policy
+
+import (
+	"fmt"
+	"io"
+	"regexp"
+	"strings"
+	"text/template"
+
+	"gopkg.in/yaml.v3"
+)
+
+// TemplateAnnotation is set on rendered manifests to the namespace/name of
+// the PolicyTemplate they came from.
+const TemplateAnnotation = "aegisx.io/template"
+
+// PolicyTemplateSpec is a manifest of another kind whose strings may hold
+// text/template actions over a set of values. Each PolicyValues naming the
+// template renders one manifest from it.
+//
+//	kind: PolicyTemplate
+//	metadata: {name: office, namespace: corp}
+//	spec:
+//	  kind: FirewallPolicy
+//	  required: [office_cidr]
+//	  values: {ssh_port: 22}     # defaults
+//	  template:                  # spec of the rendered manifest
+//	    rules:
+//	      - name: ssh
+//	        action: ALLOW
+//	        protocol: tcp
+//	        source: {addresses: ["{{ .office_cidr }}"]}
+//	        destination: {zones: [localhost], ports: ["{{ .ssh_port }}"]}
+//
+// Values are substituted into scalars of the parsed template, never into
+// YAML text, so a value cannot change the structure around it. A scalar
+// that is only a placeholder for a list or map value becomes that list or
+// map.
+type PolicyTemplateSpec struct {
+	Kind     string         `yaml:"kind"     json:"kind"`
+	Required []string       `yaml:"required" json:"required,omitempty"`
+	Values   map[string]any `yaml:"values"   json:"values,omitempty"`
+	Template yaml.Node      `yaml:"template" json:"-"`
+}
+
+// PolicyValuesSpec instantiates a PolicyTemplate. Its values are merged
+// over the template's defaults, maps key by key; the rendered manifest
+// takes the PolicyValues' name and namespace.
+//
+//	kind: PolicyValues
+//	metadata: {name: office-berlin, namespace: corp}
+//	spec:
+//	  template: office           # or namespace/name
+//	  values: {office_cidr: 10.20.0.0/16}
+type PolicyValuesSpec struct {
+	Template string         `yaml:"template" json:"template"`
+	Values   map[string]any `yaml:"values"   json:"values,omitempty"`
+}
+
+// Preview renders the templates among the documents of r and returns all
+// documents as YAML, as the Parser would read them.
+func (p *Parser) Preview(r io.Reader) (string, error) {
+	docs, err := p.parseDocs(r)
+	if err != nil {
+		return "", err
+	}
+	if docs, err = renderTemplates(docs); err != nil {
+		return "", err
+	}
+	var b strings.Builder
+	enc := yaml.NewEncoder(&b)
+	enc.SetIndent(2)
+	for _, d := range docs {
+		if err := enc.Encode(d.node); err != nil {
+			return "", err
+		}
+	}
+	if err := enc.Close(); err != nil {
+		return "", err
+	}
+	return b.String(), nil
+}
+
+// ─── Private helpers ──────────────────────────────────────────────────────
+
+// placeholderRe matches a scalar that is nothing but one value reference.
+var placeholderRe = regexp.MustCompile(`^\{\{-?\s*\.([A-Za-z_][A-Za-z0-9_]*)\s*-?\}\}$`)
+
+// renderTemplates replaces every PolicyValues of docs by the manifest it
+// renders and drops the PolicyTemplates. Other documents are kept in order.
+func renderTemplates(docs []document) ([]document, error) {
+	templates := make(map[string]*Manifest)
+	for _, d := range docs {
+		if d.m.TemplateSpec == nil {
+			continue
+		}
+		key := d.m.Metadata.Namespace + "/" + d.m.Metadata.Name
+		if _, dup := templates[key]; dup {
+			return nil, fmt.Errorf("PolicyTemplate %s is defined twice", key)
+		}
+		templates[key] = d.m
+	}
+
+	out := make([]document, 0, len(docs))
+	for _, d := range docs {
+		switch {
+		case d.m.TemplateSpec != nil:
+		case d.m.ValuesSpec != nil:
+			r, err := instantiate(d.m, templates)
+			if err != nil {
+				return nil, fmt.Errorf("PolicyValues %s/%s: %w", d.m.Metadata.Namespace, d.m.Metadata.Name, err)
+			}
+			out = append(out, r)
+		default:
+			out = append(out, d)
+		}
+	}
+	return out, nil
+}
+
+// instantiate renders the template v refers to with v's values.
+func instantiate(v *Manifest, templates map[string]*Manifest) (document, error) {
+	ref := v.ValuesSpec.Template
+	if ref == "" {
+		return document{}, fmt.Errorf("template is required")
+	}
+	if !strings.Contains(ref, "/") {
+		ref = v.Metadata.Namespace + "/" + ref
+	}
+	t, ok := templates[ref]
+	if !ok {
+		return document{}, fmt.Errorf("PolicyTemplate %s not found", ref)
+	}
+	ts := t.TemplateSpec
+	switch ts.Kind {
+	case "":
+		return document{}, fmt.Errorf("PolicyTemplate %s: kind is required", ref)
+	case KindPolicyTemplate, KindPolicyValues:
+		return document{}, fmt.Errorf("PolicyTemplate %s: cannot render a %s", ref, ts.Kind)
+	}
+
+	values := mergeValues(ts.Values, v.ValuesSpec.Values)
+	for _, name := range ts.Required {
+		if _, ok := values[name]; !ok {
+			return document{}, fmt.Errorf("value %q is required by PolicyTemplate %s", name, ref)
+		}
+	}
+
+	spec := &yaml.Node{Kind: yaml.MappingNode, Tag: "!!map"}
+	if ts.Template.Kind != 0 {
+		spec = cloneNode(&ts.Template)
+	}
+	if err := renderNode(spec, values); err != nil {
+		return document{}, fmt.Errorf("PolicyTemplate %s: %w", ref, err)
+	}
+
+	meta := v.Metadata
+	meta.Labels = mergeLabels(t.Metadata.Labels, v.Metadata.Labels)
+	meta.Annotations = mergeLabels(v.Metadata.Annotations, map[string]string{TemplateAnnotation: ref})
+	var metaNode yaml.Node
+	if err := metaNode.Encode(meta); err != nil {
+		return document{}, err
+	}
+	doc := &yaml.Node{Kind: yaml.MappingNode, Tag: "!!map", Content: []*yaml.Node{
+		scalarNode("apiVersion"), scalarNode(APIVersion),
+		scalarNode("kind"), scalarNode(ts.Kind),
+		scalarNode("metadata"), &metaNode,
+		scalarNode("spec"), spec,
+	}}
+	m, err := decodeManifest(doc)
+	if err != nil {
+		return document{}, fmt.Errorf("rendered %s: %w", ts.Kind, err)
+	}
+	return document{node: doc, m: m}, nil
+}
+
+// renderNode executes the template actions in the scalars under n.
+func renderNode(n *yaml.Node, values map[string]any) error {
+	if n.Kind != yaml.ScalarNode {
+		for _, c := range n.Content {
+			if err := renderNode(c, values); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	if !strings.Contains(n.Value, "{{") {
+		return nil
+	}
+
+	// A lone placeholder for a list or map becomes that structure.
+	if sm := placeholderRe.FindStringSubmatch(strings.TrimSpace(n.Value)); sm != nil {
+		switch v := values[sm[1]].(type) {
+		case []any, map[string]any:
+			var repl yaml.Node
+			if err := repl.Encode(v); err != nil {
+				return fmt.Errorf("line %d: %w", n.Line, err)
+			}
+			*n = repl
+			return nil
+		}
+	}
+
+	tmpl, err := template.New("").Option("missingkey=error").Parse(n.Value)
+	if err != nil {
+		return fmt.Errorf("line %d: %w", n.Line, err)
+	}
+	var b strings.Builder
+	if err := tmpl.Execute(&b, values); err != nil {
+		return fmt.Errorf("line %d: %w", n.Line, err)
+	}
+	// Let the result resolve to its own type, so "{{ .port }}" renders an
+	// int where the field wants one.
+	n.Value, n.Tag, n.Style = b.String(), "", 0
+	return nil
+}
+
+// mergeValues returns over laid on base; nested maps are merged key by key.
+func mergeValues(base, over map[string]any) map[string]any {
+	out := make(map[string]any, len(base)+len(over))
+	for k, v := range base {
+		out[k] = v
+	}
+	for k, v := range over {
+		if bm, ok := out[k].(map[string]any); ok {
+			if om, ok := v.(map[string]any); ok {
+				out[k] = mergeValues(bm, om)
+				continue
+			}
+		}
+		out[k] = v
+	}
+	return out
+}
+
+func mergeLabels(base, over map[string]string) map[string]string {
+	if len(base) == 0 && len(over) == 0 {
+		return nil
+	}
+	out := make(map[string]string, len(base)+len(over))
+	for k, v := range base {
+		out[k] = v
+	}
+	for k, v := range over {
+		out[k] = v
+	}
+	return out
+}
+
+func cloneNode(n *yaml.Node) *yaml.Node {
+	c := *n
+	c.Content = make([]*yaml.Node, len(n.Content))
+	for i, ch := range n.Content {
+		c.Content[i] = cloneNode(ch)
+	}
+	return &c
+}
+
+func scalarNode(v string) *yaml.Node {
+	return &yaml.Node{Kind: yaml.ScalarNode, Tag: "!!str", Value: v}
+}
@@ -0,0 +1,201 @@
+package policy
+
+import (
+	"net/netip"
+	"strconv"
+	"strings"
+)
+
+// Trace is the path of one new connection through a compiled ruleset: the
+// destination NAT applied before filtering, every firewall rule it matched,
+// the verdict, and the source NAT applied on the way out.
+type Trace struct {
+	FlowResult
+	DNAT    *NATStep   `json:"dnat,omitempty"`
+	Matched []RuleStep `json:"matched"` // in evaluation order; only the last one decides
+	SNAT    *NATStep   `json:"snat,omitempty"`
+}
+
+// RuleStep is a firewall rule the packet matched.
+type RuleStep struct {
+	Chain    string `json:"chain"`
+	Rule     string `json:"rule"`
+	Priority int    `json:"priority"`
+	Action   string `json:"action"`
+}
+
+// NATStep is the NAT rule that rewrote the packet and what it became.
+type NATStep struct {
+	Rule    string      `json:"rule"`
+	Type    string      `json:"type"`              // DNAT | SNAT | MASQUERADE | NETMAP
+	To      string      `json:"to,omitempty"`      // new address, with the port if it changed; empty for MASQUERADE
+	Targets []NATTarget `json:"targets,omitempty"` // a spread DNAT picks one by client address; the first is traced
+}
+
+// Trace runs f through the NAT and firewall rules of ir as the generated
+// nftables ruleset would for the first packet of a connection: prerouting
+// DNAT rewrites the destination the filter chains see, and accepted
+// packets leaving through f.OutIface are source-NATed. Like Simulate it
+// ignores rule conditions and rate limits.
+func (ir *IR) Trace(f Flow) Trace {
+	var t Trace
+	orig := f
+	chain := f.Direction
+	if _, ok := defaultVerdicts[chain]; !ok {
+		chain = "forward"
+	}
+
+	// The table has no NAT output chain, so locally generated packets are
+	// not destination-NATed.
+	if chain != "output" {
+		vrf := ir.flowVRF(f, false)
+		for _, r := range ir.NATRules {
+			if r.NetNS != f.NetNS || (r.VRF != "" && r.VRF != vrf) {
+				continue
+			}
+			if step, ok := r.dnat(&f); ok {
+				t.DNAT = step
+				break
+			}
+		}
+	}
+
+	t.FlowResult = ir.simulate(f, func(r CompiledFirewallRule) {
+		t.Matched = append(t.Matched, RuleStep{Chain: ruleChain(&r), Rule: r.Comment, Priority: r.Priority, Action: r.Action})
+	})
+	t.Flow = orig // the packet as sent; DNAT shows what it became
+
+	if t.Verdict == "accept" && chain != "input" {
+		vrf := ir.flowVRF(f, true)
+		for _, r := range ir.NATRules {
+			if r.NetNS != f.NetNS || (r.VRF != "" && r.VRF != vrf) {
+				continue
+			}
+			if step, ok := r.snat(f); ok {
+				t.SNAT = step
+				break
+			}
+		}
+	}
+	return t
+}
+
+// ─── Private helpers ──────────────────────────────────────────────────────
+
+// dnat rewrites the destination of f if the prerouting part of r matches.
+func (r CompiledNATRule) dnat(f *Flow) (*NATStep, bool) {
+	dst, _ := netip.ParseAddr(f.Dst)
+	switch r.Type {
+	case "DNAT":
+		if !r.matchesPacket(*f) || (r.DstAddr != "" && !addrIn(dst, []string{r.DstAddr})) ||
+			(r.DstPorts != "" && !portIn(f.DstPort, []string{r.DstPorts})) {
+			return nil, false
+		}
+		step := &NATStep{Rule: r.Comment, Type: r.Type, Targets: r.Targets}
+		to := r.ToAddr
+		if len(r.Targets) > 0 {
+			to = r.Targets[0].Address
+		}
+		addr, port := splitNATAddr(to)
+		if r.ToPorts != "" {
+			lo, _, _ := strings.Cut(r.ToPorts, "-")
+			port, _ = strconv.Atoi(lo)
+		}
+		if addr.IsValid() {
+			f.Dst = addr.String()
+		}
+		if port != 0 {
+			f.DstPort = port
+			step.To = netip.AddrPortFrom(addr, uint16(port)).String()
+		} else {
+			step.To = addr.String()
+		}
+		return step, true
+	case "NETMAP":
+		internal, err1 := netip.ParsePrefix(r.SrcAddr)
+		external, err2 := netip.ParsePrefix(r.ToAddr)
+		if err1 != nil || err2 != nil || !external.Contains(dst) {
+			return nil, false
+		}
+		mapped := mapPrefix(dst, external, internal)
+		f.Dst = mapped.String()
+		return &NATStep{Rule: r.Comment, Type: r.Type, To: f.Dst}, true
+	}
+	return nil, false
+}
+
+// snat returns the source rewrite of f if the postrouting part of r
+// matches.
+func (r CompiledNATRule) snat(f Flow) (*NATStep, bool) {
+	src, _ := netip.ParseAddr(f.Src)
+	if r.OutIface != "" && r.OutIface != f.OutIface {
+		return nil, false
+	}
+	switch r.Type {
+	case "SNAT", "MASQUERADE":
+		if !r.matchesPacket(f) {
+			return nil, false
+		}
+		step := &NATStep{Rule: r.Comment, Type: r.Type}
+		if r.Type == "SNAT" {
+			step.To = r.ToAddr + natPortsSuffix(r.ToPorts)
+		}
+		return step, true
+	case "NETMAP":
+		internal, err1 := netip.ParsePrefix(r.SrcAddr)
+		external, err2 := netip.ParsePrefix(r.ToAddr)
+		if err1 != nil || err2 != nil || !internal.Contains(src) {
+			return nil, false
+		}
+		return &NATStep{Rule: r.Comment, Type: r.Type, To: mapPrefix(src, internal, external).String()}, true
+	}
+	return nil, false
+}
+
+// matchesPacket reports whether the source address and protocol of f
+// match r. A rule with ports but no protocol matches tcp and udp.
+func (r CompiledNATRule) matchesPacket(f Flow) bool {
+	src, _ := netip.ParseAddr(f.Src)
+	if r.SrcAddr != "" && !addrIn(src, []string{r.SrcAddr}) {
+		return false
+	}
+	proto := strings.ToLower(f.Protocol)
+	switch {
+	case r.Protocol != "":
+		return r.Protocol == proto
+	case r.DstPorts != "" || r.ToPorts != "":
+		return proto == "tcp" || proto == "udp"
+	}
+	return true
+}
+
+// splitNATAddr parses "addr", "addr:port" or "[v6]:port".
+func splitNATAddr(s string) (netip.Addr, int) {
+	if ap, err := netip.ParseAddrPort(s); err == nil {
+		return ap.Addr(), int(ap.Port())
+	}
+	a, _ := netip.ParseAddr(s)
+	return a, 0
+}
+
+func natPortsSuffix(ports string) string {
+	if ports == "" {
+		return ""
+	}
+	return ":" + ports
+}
+
+// mapPrefix keeps the host part of a, which lies in from, and replaces its
+// network part with that of to, as nft's prefix NAT does.
+func mapPrefix(a netip.Addr, from, to netip.Prefix) netip.Addr {
+	ab, tb := a.AsSlice(), to.Masked().Addr().AsSlice()
+	if len(ab) != len(tb) {
+		return a
+	}
+	for i := 0; i < from.Bits() && i < len(ab)*8; i++ {
+		bit := byte(0x80) >> (i % 8)
+		ab[i/8] = ab[i/8]&^bit | tb[i/8]&bit
+	}
+	out, _ := netip.AddrFromSlice(ab)
+	return out
+}
@@ -12,6 +12,16 @@ const (
 	KindVPNPolicy          = "VPNPolicy"
 	KindNATPolicy          = "NATPolicy"
 	KindIDSPolicy          = "IDSPolicy"
+	KindHealthCheckPolicy  = "HealthCheckPolicy"
+	KindWANPolicy          = "WANPolicy"
+	KindAppControlPolicy   = "AppControlPolicy"
+	KindPolicyTest         = "PolicyTest"
+	KindAddressGroup       = "AddressGroup"
+	KindServiceGroup       = "ServiceGroup"
+	KindServiceDefinition  = "ServiceDefinition"
+	KindPolicyTemplate     = "PolicyTemplate"
+	KindPolicyValues       = "PolicyValues"
+	KindVRFPolicy          = "VRFPolicy"
 )
 
 // ─── Top-level manifest ────────────────────────────────────────────────────
@@ -28,6 +38,20 @@ type Manifest struct {
 	VPNSpec          *VPNPolicySpec          `yaml:"-"              json:"-"`
 	NATSpec          *NATPolicySpec          `yaml:"-"              json:"-"`
 	IDSSpec          *IDSPolicySpec          `yaml:"-"              json:"-"`
+	HealthCheckSpec  *HealthCheckPolicySpec  `yaml:"-"              json:"-"`
+	WANSpec          *WANPolicySpec          `yaml:"-"              json:"-"`
+	AppControlSpec   *AppControlPolicySpec   `yaml:"-"              json:"-"`
+	PolicyTestSpec   *PolicyTestSpec         `yaml:"-"              json:"-"`
+	AddressGroupSpec *AddressGroupSpec       `yaml:"-"              json:"-"`
+	ServiceGroupSpec *ServiceGroupSpec       `yaml:"-"              json:"-"`
+	ServiceSpec      *ServiceDefinitionSpec  `yaml:"-"              json:"-"`
+	VRFSpec          *VRFPolicySpec          `yaml:"-"              json:"-"`
+	PluginSpec       map[string]any          `yaml:"-"              json:"-"` // kinds registered by plugins
+
+	// Templates and their values; the Parser renders them into manifests of
+	// the kinds above and never returns them.
+	TemplateSpec *PolicyTemplateSpec `yaml:"-" json:"-"`
+	ValuesSpec   *PolicyValuesSpec   `yaml:"-" json:"-"`
 }
 
 type Metadata struct {
@@ -42,12 +66,48 @@ type Metadata struct {
 type FirewallPolicySpec struct {
 	DefaultAction string         `yaml:"defaultAction" json:"defaultAction"` // ALLOW | DROP | REJECT
 	Rules         []FirewallRule `yaml:"rules"         json:"rules"`
+
+	// TargetNamespace applies the rules inside the named network namespace,
+	// e.g. a container's, instead of on the host; see firewall.NetNS.
+	TargetNamespace string `yaml:"targetNamespace,omitempty" json:"targetNamespace,omitempty"`
+
+	// VRF limits the rules to traffic entering or leaving the named VRF of
+	// a VRFPolicy. They are evaluated in the VRF's own chains, ahead of the
+	// rules of policies without a VRF.
+	VRF string `yaml:"vrf,omitempty" json:"vrf,omitempty"`
+
+	// Chains are named lists of rules, e.g. one per application, that rules
+	// reach with a JUMP or GOTO action naming the chain as their target.
+	Chains []FirewallChain `yaml:"chains,omitempty" json:"chains,omitempty"`
+
+	// RawRules are nftables statements inserted verbatim, for what the
+	// rules above cannot express. Only admins may write policies with them.
+	RawRules []RawRules `yaml:"rawRules,omitempty" json:"rawRules,omitempty"`
+}
+
+// RawRules is a snippet of nftables statements, one per line, for the
+// designated chain raw_<chain>. That chain is jumped to ahead of the
+// generated rules of its base chain, so a verdict in it is final and a
+// packet it does not decide on goes on to the policy's rules. Snippets
+// bypass the simulator, trace and analysis, which do not see them.
+type RawRules struct {
+	Chain string `yaml:"chain" json:"chain"` // input | forward | output
+	Rules string `yaml:"rules" json:"rules"`
+}
+
+// FirewallChain is a user-defined chain of a FirewallPolicy. A packet that
+// reaches its end, or a RETURN rule, continues after the JUMP that sent it
+// there; after a GOTO it continues where the chain holding the GOTO was
+// entered from, which for the policy's rules is the default verdict.
+type FirewallChain struct {
+	Name  string         `yaml:"name"  json:"name"`
+	Rules []FirewallRule `yaml:"rules" json:"rules"`
 }
 
 type FirewallRule struct {
 	Name     string          `yaml:"name"     json:"name"`
 	Priority int             `yaml:"priority" json:"priority"`
-	Action   string          `yaml:"action"   json:"action"` // ALLOW | DROP | REJECT | LOG
+	Action   string          `yaml:"action"   json:"action"` // ALLOW | DROP | REJECT | LOG | TARPIT | JUMP | GOTO | RETURN | MARK | DSCP
 	Protocol string          `yaml:"protocol" json:"protocol"` // tcp|udp|icmp|any
 	Source   TrafficSelector `yaml:"source"   json:"source"`
 	Dest     TrafficSelector `yaml:"destination" json:"destination"`
@@ -55,6 +115,52 @@ type FirewallRule struct {
 	RateLimit *RateLimit     `yaml:"rateLimit,omitempty" json:"rateLimit,omitempty"`
 	Log      bool            `yaml:"log"      json:"log"`
 	Comment  string          `yaml:"comment"  json:"comment"`
+	When     *RuleCondition  `yaml:"when,omitempty" json:"when,omitempty"`
+	Schedule *RuleSchedule   `yaml:"schedule,omitempty" json:"schedule,omitempty"`
+
+	// ConnLimit caps each source address at that many concurrent
+	// connections matched by the rule. An ALLOW rule stops matching the
+	// connections beyond the cap, which fall through to later rules; any
+	// other action applies only to the connections beyond it.
+	ConnLimit int `yaml:"connLimit,omitempty" json:"connLimit,omitempty"`
+
+	// Target is the chain of the policy a JUMP or GOTO rule continues in.
+	Target string `yaml:"target,omitempty" json:"target,omitempty"`
+
+	// RejectWith is the reply a REJECT rule sends: tcp-reset, or an ICMP
+	// error such as icmp-port-unreachable (the default) or
+	// icmpx-admin-prohibited. icmp- types are sent as ICMPv6 to IPv6
+	// traffic; icmpx- types pick the family of the packet in any case.
+	RejectWith string `yaml:"rejectWith,omitempty" json:"rejectWith,omitempty"`
+
+	// Mark and DSCP are what a MARK or DSCP rule sets on every packet it
+	// matches, for policy routing and traffic shaping: a packet mark such
+	// as "0x10", or a DSCP class such as "ef", "af41" or "cs1", or a
+	// number up to 63. Evaluation goes on past these rules, which see the
+	// packets of established connections too. A mark replaces the uplink
+	// mark of traffic a WANPolicy steers.
+	Mark string `yaml:"mark,omitempty" json:"mark,omitempty"`
+	DSCP string `yaml:"dscp,omitempty" json:"dscp,omitempty"`
+
+	// ExpiresAt removes the rule from the ruleset once it passes, e.g. for
+	// a temporary block; the policy itself is left as it is.
+	ExpiresAt *time.Time `yaml:"expiresAt,omitempty" json:"expiresAt,omitempty"`
+}
+
+// RuleSchedule limits a rule to days of the week and a daily time window.
+// nftables evaluates it against the firewall's clock, so a scheduled rule is
+// only as correct as the system time.
+type RuleSchedule struct {
+	Days  []string `yaml:"days"  json:"days"`  // Monday … Sunday; empty means every day
+	Start string   `yaml:"start" json:"start"` // "HH:MM", local time
+	End   string   `yaml:"end"   json:"end"`   // "HH:MM"; before Start wraps past midnight
+}
+
+// RuleCondition gates a rule on the state of a health-check target: the rule
+// is only installed while the target is in the given state.
+type RuleCondition struct {
+	Target string `yaml:"target" json:"target"`
+	State  string `yaml:"state"  json:"state"` // up | down
 }
 
 type TrafficSelector struct {
@@ -63,6 +169,30 @@ type TrafficSelector struct {
 	Ports     []int    `yaml:"ports"     json:"ports"`
 	PortRanges []PortRange `yaml:"portRanges" json:"portRanges"`
 	IPSets    []string `yaml:"ipsets"    json:"ipsets"`
+	// AddressGroup and ServiceGroup match against a named group instead of
+	// inline addresses or ports. A bare name refers to the rule's own
+	// namespace, "namespace/name" to another.
+	AddressGroup string `yaml:"addressGroup,omitempty" json:"addressGroup,omitempty"`
+	ServiceGroup string `yaml:"serviceGroup,omitempty" json:"serviceGroup,omitempty"`
+	// Services names ServiceDefinitions whose ports the selector matches,
+	// with the rule taking their protocol. In YAML they may be listed among
+	// the ports, e.g. ports: [web, 8080]; names resolve like groups.
+	Services []string `yaml:"services,omitempty" json:"services,omitempty"`
+	// Countries matches addresses the GeoIP database places in any of the
+	// listed countries (ISO 3166-1 alpha-2), in place of inline addresses.
+	Countries []string `yaml:"countries,omitempty" json:"countries,omitempty"`
+	// FQDNs matches the addresses the names resolve to, kept current by the
+	// firewall as DNS answers change.
+	FQDNs []string `yaml:"fqdns,omitempty" json:"fqdns,omitempty"`
+	// Interfaces matches the interface the traffic enters through (source)
+	// or leaves through (destination); a trailing * matches a prefix, e.g.
+	// "vlan*".
+	Interfaces []string `yaml:"interfaces,omitempty" json:"interfaces,omitempty"`
+	// MACAddresses matches the Ethernet source address of the traffic, e.g.
+	// to pin a LAN segment to known devices whose addresses are dynamic.
+	// Only the source selector takes it; routed traffic carries the MAC
+	// address of the last router.
+	MACAddresses []string `yaml:"macAddresses,omitempty" json:"macAddresses,omitempty"`
 }
 
 type PortRange struct {
@@ -85,8 +215,14 @@ type LoadBalancerPolicySpec struct {
 
 type LBFrontend struct {
 	Bind    string `yaml:"bind"    json:"bind"`
-	Mode    string `yaml:"mode"    json:"mode"` // tcp | http
+	Mode    string `yaml:"mode"    json:"mode"` // tcp | http | udp
 	MaxConn int    `yaml:"maxConn" json:"maxConn"`
+	// AcceptProxy expects a PROXY protocol header from an upstream balancer.
+	AcceptProxy bool `yaml:"acceptProxy,omitempty" json:"acceptProxy,omitempty"`
+	// UDPResponses is the number of datagrams expected back per client
+	// datagram (0 for syslog, 1 for DNS). Unset keeps the session open until
+	// the backend timeout, which suits game servers.
+	UDPResponses *int `yaml:"udpResponses,omitempty" json:"udpResponses,omitempty"`
 }
 
 type LBBackend struct {
@@ -94,6 +230,26 @@ type LBBackend struct {
 	Servers   []LBServer `yaml:"servers"   json:"servers"`
 	HealthCheck *LBHealthCheck `yaml:"healthCheck,omitempty" json:"healthCheck,omitempty"`
 	Timeout   string     `yaml:"timeout"   json:"timeout"`
+	// ProxyProtocol sends a PROXY protocol header to servers: v1 | v2.
+	ProxyProtocol string `yaml:"proxyProtocol,omitempty" json:"proxyProtocol,omitempty"`
+	// ErrorPages maps an HTTP status to the HTML served in its place, given
+	// inline; 503 doubles as the maintenance page.
+	ErrorPages map[int]string `yaml:"errorPages,omitempty" json:"errorPages,omitempty"`
+	// Discovery adds servers found at runtime to the static Servers list.
+	Discovery *LBDiscovery `yaml:"discovery,omitempty" json:"discovery,omitempty"`
+}
+
+// LBDiscovery sources backend servers dynamically. Membership changes are
+// pushed through the HAProxy runtime API without a reload.
+type LBDiscovery struct {
+	Type       string `yaml:"type"                 json:"type"`    // dns | consul | kubernetes
+	Name       string `yaml:"name"                 json:"name"`    // SRV name, Consul service, or k8s Service
+	Namespace  string `yaml:"namespace,omitempty"  json:"namespace,omitempty"`  // kubernetes; default "default"
+	Port       string `yaml:"port,omitempty"       json:"port,omitempty"`       // kubernetes port name; default first
+	Address    string `yaml:"address,omitempty"    json:"address,omitempty"`    // Consul agent URL
+	Tag        string `yaml:"tag,omitempty"        json:"tag,omitempty"`        // Consul service tag filter
+	Interval   string `yaml:"interval,omitempty"   json:"interval,omitempty"`   // DNS poll interval; default 30s
+	MaxServers int    `yaml:"maxServers,omitempty" json:"maxServers,omitempty"` // server slots; default 16
 }
 
 type LBServer struct {
@@ -126,6 +282,27 @@ type VPNPolicySpec struct {
 	Address    string      `yaml:"address"    json:"address"` // tunnel CIDR
 	DNS        []string    `yaml:"dns"        json:"dns"`
 	Peers      []VPNPeer   `yaml:"peers"      json:"peers"`
+
+	// MTU pins the interface MTU. When 0 it is derived from UplinkMTU minus
+	// the WireGuard and Encapsulation overheads.
+	MTU           int      `yaml:"mtu"           json:"mtu"`
+	UplinkMTU     int      `yaml:"uplinkMTU"     json:"uplinkMTU"`     // default 1500
+	Encapsulation []string `yaml:"encapsulation" json:"encapsulation"` // pppoe|gre|vxlan|wireguard|ipsec
+	// DefaultKeepAlive applies to peers without a static endpoint (roaming,
+	// usually behind NAT) that don't set keepAlive themselves.
+	DefaultKeepAlive int `yaml:"defaultKeepAlive" json:"defaultKeepAlive"`
+	// Transport wraps WireGuard in a relay for peers on UDP-blocking networks.
+	Transport *VPNTransport `yaml:"transport,omitempty" json:"transport,omitempty"`
+}
+
+// VPNTransport configures a relay server in front of the WireGuard port.
+// The relay programs themselves come from the server config (vpn.udp2raw_path,
+// vpn.wstunnel_path), never from a policy.
+type VPNTransport struct {
+	Mode   string `yaml:"mode"             json:"mode"`             // udp (default) | udp2raw | wstunnel
+	Listen string `yaml:"listen"           json:"listen"`           // relay bind address, e.g. "0.0.0.0:443"
+	Secret string `yaml:"secret,omitempty" json:"secret,omitempty"` // udp2raw shared secret
+	Path   string `yaml:"path,omitempty"   json:"path,omitempty"`   // wstunnel upgrade path prefix
 }
 
 type VPNPeer struct {
@@ -141,16 +318,33 @@ type VPNPeer struct {
 
 type NATPolicySpec struct {
 	Rules []NATRule `yaml:"rules" json:"rules"`
+
+	// TargetNamespace applies the rules inside the named network namespace
+	// instead of on the host.
+	TargetNamespace string `yaml:"targetNamespace,omitempty" json:"targetNamespace,omitempty"`
+
+	// VRF limits the rules to traffic entering (DNAT) or leaving (SNAT) the
+	// named VRF.
+	VRF string `yaml:"vrf,omitempty" json:"vrf,omitempty"`
 }
 
 type NATRule struct {
 	Name      string `yaml:"name"      json:"name"`
-	Type      string `yaml:"type"      json:"type"` // SNAT | DNAT | MASQUERADE
+	Priority  int    `yaml:"priority"  json:"priority"` // lower runs first; default is position × 100
+	Type      string `yaml:"type"      json:"type"` // SNAT | DNAT | MASQUERADE | NETMAP
 	Source    string `yaml:"source"    json:"source"`
 	Dest      string `yaml:"destination" json:"destination"`
 	ToSource  string `yaml:"toSource"  json:"toSource"`  // for SNAT
-	ToDest    string `yaml:"toDest"    json:"toDest"`    // for DNAT
+	ToDest    NATTargets `yaml:"toDest" json:"toDest"` // for DNAT; a list spreads connections by weight
 	OutIface  string `yaml:"outInterface" json:"outInterface"`
+	Protocol  string `yaml:"protocol"  json:"protocol,omitempty"` // tcp|udp|any; ports without one match both tcp and udp
+	Ports     string `yaml:"ports"     json:"ports,omitempty"`    // DNAT: destination port or range matched, e.g. "8000-8010"
+	ToPorts   string `yaml:"toPorts"   json:"toPorts,omitempty"`  // translated port or range, e.g. "1024-65535"
+	Persistent bool  `yaml:"persistent" json:"persistent,omitempty"` // SNAT/MASQUERADE: same mapping for every connection of a client
+	Random    bool   `yaml:"random"    json:"random,omitempty"`    // SNAT/MASQUERADE: randomise the source port
+	Internal  string `yaml:"internal"  json:"internal,omitempty"`  // NETMAP: local prefix, e.g. 10.0.0.0/24
+	External  string `yaml:"external"  json:"external,omitempty"`  // NETMAP: prefix it appears as, e.g. 203.0.113.0/24
+	When      *RuleCondition `yaml:"when,omitempty" json:"when,omitempty"`
 }
 
 // ─── IDS Policy ────────────────────────────────────────────────────────────
@@ -160,6 +354,7 @@ type IDSPolicySpec struct {
 	RuleSets    []string    `yaml:"ruleSets"    json:"ruleSets"` // suricata ruleset names
 	CustomRules []IDSRule   `yaml:"customRules" json:"customRules"`
 	Thresholds  []IDSThreshold `yaml:"thresholds" json:"thresholds"`
+	Bypass      []IPSBypass    `yaml:"bypass"     json:"bypass"` // ips mode: traffic kept out of the queue
 }
 
 type IDSRule struct {
@@ -178,6 +373,172 @@ type IDSThreshold struct {
 	Seconds int  `yaml:"seconds" json:"seconds"`
 }
 
+// IPSBypass exempts traffic from inline inspection. Addresses and ports match
+// either end of a connection, so replies are exempt too.
+type IPSBypass struct {
+	Name      string   `yaml:"name"      json:"name"`
+	Protocol  string   `yaml:"protocol"  json:"protocol"` // tcp | udp | any
+	Addresses []string `yaml:"addresses" json:"addresses"`
+	Ports     []int    `yaml:"ports"     json:"ports"`
+}
+
+// ─── Health Check Policy ───────────────────────────────────────────────────
+
+type HealthCheckPolicySpec struct {
+	Targets []HealthTarget `yaml:"targets" json:"targets"`
+}
+
+// HealthTarget is a monitored endpoint whose state can gate rules.
+type HealthTarget struct {
+	Name         string `yaml:"name"                   json:"name"`
+	Type         string `yaml:"type"                   json:"type"`                // icmp | tcp | http | dns
+	Address      string `yaml:"address"                json:"address"`             // host, host:port, URL, or DNS server
+	Interface    string `yaml:"interface,omitempty"    json:"interface,omitempty"` // probe via this link
+	Interval     string `yaml:"interval,omitempty"     json:"interval,omitempty"`  // default 5s
+	Timeout      string `yaml:"timeout,omitempty"      json:"timeout,omitempty"`   // default 2s
+	Rise         int    `yaml:"rise,omitempty"         json:"rise,omitempty"`      // default 2
+	Fall         int    `yaml:"fall,omitempty"         json:"fall,omitempty"`      // default 3
+	ExpectStatus int    `yaml:"expectStatus,omitempty" json:"expectStatus,omitempty"`
+
+	// DNS probes resolve Query through the server at Address, as an A or
+	// AAAA lookup. With ExpectAddress set, the answer must contain it.
+	Query         string `yaml:"query,omitempty"         json:"query,omitempty"`
+	RecordType    string `yaml:"recordType,omitempty"    json:"recordType,omitempty"` // A (default) | AAAA
+	ExpectAddress string `yaml:"expectAddress,omitempty" json:"expectAddress,omitempty"`
+}
+
+// ─── WAN Policy ────────────────────────────────────────────────────────────
+
+type WANPolicySpec struct {
+	Mode    string      `yaml:"mode"    json:"mode"`    // balance | failover
+	Sources []string    `yaml:"sources" json:"sources"` // LAN CIDRs to steer; empty = all forwarded traffic
+	Uplinks []WANUplink `yaml:"uplinks" json:"uplinks"`
+
+	// Paths steer selected traffic over preferred uplinks ahead of the
+	// mode's choice.
+	Paths []WANPath `yaml:"paths,omitempty" json:"paths,omitempty"`
+}
+
+type WANUplink struct {
+	Name      string        `yaml:"name"      json:"name"`
+	Interface string        `yaml:"interface" json:"interface"`
+	Gateway   string        `yaml:"gateway"   json:"gateway"`   // empty for point-to-point links
+	Weight    int           `yaml:"weight"    json:"weight"`    // balance mode share, default 1
+	Priority  int           `yaml:"priority"  json:"priority"`  // failover order, lower first
+	SNAT      string        `yaml:"snat"      json:"snat"`      // source address; empty = masquerade
+	Table     int           `yaml:"table,omitempty" json:"table,omitempty"` // routing table, auto-assigned
+	Check     *HealthTarget `yaml:"check,omitempty" json:"check,omitempty"` // name is derived from the uplink
+
+	// PathsOnly keeps the uplink out of the balance or failover choice, so
+	// it only carries the traffic of paths naming it, e.g. a VPN tunnel.
+	PathsOnly bool `yaml:"pathsOnly,omitempty" json:"pathsOnly,omitempty"`
+}
+
+// WANPath sends the new connections it matches over the first of its
+// uplinks, in order of preference, whose check measures no more latency
+// and loss than the thresholds allow. Latency and loss are averaged over
+// the check's recent probes; an uplink without a check is not measured and
+// always qualifies. When no healthy uplink qualifies, the one with the
+// least loss, then latency, is used, and when none is healthy the traffic
+// is left to the mode. The choice is re-evaluated as measurements change.
+//
+// Applications are selected by destination: addresses, an address group,
+// FQDNs or countries, and ports.
+type WANPath struct {
+	Name        string          `yaml:"name"        json:"name"`
+	Protocol    string          `yaml:"protocol"    json:"protocol"` // tcp|udp|any
+	Destination TrafficSelector `yaml:"destination" json:"destination"`
+	Uplinks     []string        `yaml:"uplinks"     json:"uplinks"`                       // preferred first
+	MaxLatency  string          `yaml:"maxLatency,omitempty" json:"maxLatency,omitempty"` // e.g. "150ms"
+	MaxLoss     float64         `yaml:"maxLoss,omitempty"    json:"maxLoss,omitempty"`    // percent
+}
+
+// ─── App Control Policy ────────────────────────────────────────────────────
+
+// AppControlPolicySpec filters on application-layer identifiers that the
+// L3/L4 firewall cannot see. It is enforced by Suricata: drop and reject
+// only block in ips mode and are logged as alerts otherwise.
+type AppControlPolicySpec struct {
+	Action string           `yaml:"action" json:"action"` // drop | reject | alert; default drop
+	Rules  []AppControlRule `yaml:"rules"  json:"rules"`
+}
+
+// AppControlRule lists values to match. Domain entries written as
+// "*.example.com" also match example.com and all of its subdomains.
+type AppControlRule struct {
+	Name       string   `yaml:"name"       json:"name"`
+	Action     string   `yaml:"action"     json:"action"` // overrides the spec action
+	TLSSNI     []string `yaml:"tlsSNI"     json:"tlsSNI"`
+	JA3        []string `yaml:"ja3"        json:"ja3"` // client fingerprint MD5s
+	HTTPHosts  []string `yaml:"httpHosts"  json:"httpHosts"`
+	DNSDomains []string `yaml:"dnsDomains" json:"dnsDomains"`
+}
+
+// ─── Policy Test ───────────────────────────────────────────────────────────
+
+// PolicyTestSpec declares flows and the verdict each must get from the
+// firewall rules compiled alongside it. A failing expectation fails the
+// compilation, so a broken policy is never applied.
+type PolicyTestSpec struct {
+	Flows []Flow `yaml:"flows" json:"flows"`
+}
+
+// ─── Address and Service Groups ────────────────────────────────────────────
+
+// AddressGroupSpec is a reusable set of addresses and CIDRs that firewall
+// rules reference by name. It is loaded as an nftables named set per
+// address family, so changing it leaves the rules that use it untouched.
+type AddressGroupSpec struct {
+	Addresses []string `yaml:"addresses" json:"addresses"`
+}
+
+// ServiceGroupSpec is a reusable set of ports. The protocol comes from the
+// rule that references it.
+type ServiceGroupSpec struct {
+	Ports      []int       `yaml:"ports"      json:"ports"`
+	PortRanges []PortRange `yaml:"portRanges" json:"portRanges"`
+}
+
+// ServiceDefinitionSpec names the ports of one protocol, e.g. "web" for
+// tcp/80,443, so selectors can list the name instead of repeating them. The
+// engine resolves the name when compiling; no set is created.
+type ServiceDefinitionSpec struct {
+	Protocol   string      `yaml:"protocol"   json:"protocol"` // tcp | udp
+	Ports      []int       `yaml:"ports"      json:"ports"`
+	PortRanges []PortRange `yaml:"portRanges" json:"portRanges"`
+}
+
+// ─── VRF Policy ────────────────────────────────────────────────────────────
+
+// VRFPolicySpec declares Linux VRF devices. Each binds interfaces to its own
+// routing table, so tenants with overlapping address space can share the
+// host; firewall and NAT policies name a VRF to apply only to its traffic.
+type VRFPolicySpec struct {
+	VRFs []VRF `yaml:"vrfs" json:"vrfs"`
+}
+
+// VRF is a VRF device, the interfaces enslaved to it and the routes of its
+// table. The name is that of the device and is unique across policies.
+type VRF struct {
+	Name       string     `yaml:"name"       json:"name"`
+	Table      int        `yaml:"table"      json:"table"`
+	Interfaces []string   `yaml:"interfaces" json:"interfaces"`
+	Routes     []VRFRoute `yaml:"routes"     json:"routes,omitempty"`
+}
+
+type VRFRoute struct {
+	Destination string `yaml:"destination"         json:"destination"` // CIDR or "default"
+	Gateway     string `yaml:"gateway,omitempty"   json:"gateway,omitempty"`
+	Interface   string `yaml:"interface,omitempty" json:"interface,omitempty"` // one of the VRF's
+	Metric      int    `yaml:"metric,omitempty"    json:"metric,omitempty"`
+}
+
+// Devices returns the names traffic of v is seen on: the VRF device itself
+// and its interfaces.
+func (v VRF) Devices() []string {
+	return append([]string{v.Name}, v.Interfaces...)
+}
+
 // ─── Intermediate Representation ──────────────────────────────────────────
 
 // IR is the compiled, backend-agnostic representation of all policies.
@@ -191,12 +552,38 @@ type IR struct {
 	LoadBalancers    []CompiledLoadBalancer    `json:"loadBalancers"`
 	VPNConfigs       []CompiledVPNConfig       `json:"vpnConfigs"`
 	IDSRules         []CompiledIDSRule         `json:"idsRules"`
+	HealthTargets    []HealthTarget            `json:"healthTargets,omitempty"`
+	WAN              *CompiledWAN              `json:"wan,omitempty"`
+	IPSBypass        []CompiledIPSBypass       `json:"ipsBypass,omitempty"`
+	AppRules         []CompiledAppRule         `json:"appRules,omitempty"`
+	AddressGroups    []CompiledGroup           `json:"addressGroups,omitempty"`
+	ServiceGroups    []CompiledGroup           `json:"serviceGroups,omitempty"`
+	CountrySets      []CompiledCountrySet      `json:"countrySets,omitempty"`
+	FQDNSets         []CompiledFQDNSet         `json:"fqdnSets,omitempty"`
+	VRFs             []VRF                     `json:"vrfs,omitempty"`
+	RawRules         []CompiledRawRules        `json:"rawRules,omitempty"`
+
+	// Extensions holds the fragments compiled by plugin kinds, keyed by kind,
+	// for plugin backends to consume.
+	Extensions map[string][]any `json:"extensions,omitempty"`
+
+	// Warnings are the findings of Validator.Analyze, such as shadowed
+	// rules; they do not stop the IR from applying.
+	Warnings []Warning `json:"warnings,omitempty"`
+}
+
+// CompiledRawRules is the snippet of a FirewallPolicy for one chain.
+type CompiledRawRules struct {
+	Policy string   `json:"policy"` // namespace/name
+	Chain  string   `json:"chain"`  // input|forward|output
+	Rules  []string `json:"rules"`  // the snippet's non-empty lines
+	NetNS  string   `json:"netns,omitempty"`
 }
 
 type CompiledFirewallRule struct {
 	Priority    int      `json:"priority"`
 	Chain       string   `json:"chain"`    // input|output|forward
-	Action      string   `json:"action"`   // accept|drop|reject|log
+	Action      string   `json:"action"`   // accept|drop|reject|log|tarpit|jump|goto|return|mark|dscp
 	Protocol    string   `json:"protocol"`
 	SrcAddrs    []string `json:"srcAddrs"`
 	DstAddrs    []string `json:"dstAddrs"`
@@ -206,14 +593,190 @@ type CompiledFirewallRule struct {
 	RateLimit   string   `json:"rateLimit"`
 	Log         bool     `json:"log"`
 	Comment     string   `json:"comment"`
+	When        *RuleCondition `json:"when,omitempty"`
+	Schedule    *RuleSchedule  `json:"schedule,omitempty"`
+
+	// Family is ip or ip6 when the rule matches addresses, which are then
+	// all of that family, and empty for a rule matching both families.
+	Family string `json:"family,omitempty"`
+
+	// Named sets matched in place of the lists above; see CompiledGroup.
+	SrcSet     string `json:"srcSet,omitempty"`
+	DstSet     string `json:"dstSet,omitempty"`
+	SrcPortSet string `json:"srcPortSet,omitempty"`
+	DstPortSet string `json:"dstPortSet,omitempty"`
+
+	// ConnLimit is the number of concurrent connections per source
+	// address the rule applies up to (accept) or beyond (other actions).
+	ConnLimit int `json:"connLimit,omitempty"`
+
+	// InIfaces and OutIfaces match the input and output interface names.
+	InIfaces  []string `json:"inIfaces,omitempty"`
+	OutIfaces []string `json:"outIfaces,omitempty"`
+
+	// SrcMACs matches the Ethernet source address, as aa:bb:cc:dd:ee:ff.
+	SrcMACs []string `json:"srcMacs,omitempty"`
+
+	// NetNS is the network namespace the rule is installed in; empty for
+	// the host.
+	NetNS string `json:"netns,omitempty"`
+
+	// VRF is the VRF whose traffic the rule matches; empty for all.
+	VRF string `json:"vrf,omitempty"`
+
+	// UserChain is the user-defined chain the rule is in, as
+	// namespace/policy/chain; empty for the rules of the base chains.
+	// Target is the user-defined chain a jump or goto rule continues in.
+	UserChain string `json:"userChain,omitempty"`
+	Target    string `json:"target,omitempty"`
+
+	// RejectWith is the reply of a reject rule; see FirewallRule.
+	RejectWith string `json:"rejectWith,omitempty"`
+
+	// Mark and DSCP are what a mark or dscp rule sets: a mark in hex and a
+	// DSCP class name or number.
+	Mark string `json:"mark,omitempty"`
+	DSCP string `json:"dscp,omitempty"`
+
+	// ExpiresAt is when the rule leaves the ruleset; nil for never.
+	ExpiresAt *time.Time `json:"expiresAt,omitempty"`
+}
+
+// CompiledGroup is an address or service group as a backend named set.
+type CompiledGroup struct {
+	Set      string   `json:"set"`   // backend set name, unique within the IR
+	Group    string   `json:"group"`            // namespace/name
+	Family   string   `json:"family,omitempty"` // ip | ip6; empty for service groups
+	Elements []string `json:"elements"`
+}
+
+// CompiledCountrySet is a backend named set of the addresses of one family
+// located in any of Countries. The ruleset declares it empty; its elements
+// are resolved from the GeoIP database at runtime.
+type CompiledCountrySet struct {
+	Set       string   `json:"set"`
+	Family    string   `json:"family"`    // ip | ip6
+	Countries []string `json:"countries"` // sorted ISO codes
+}
+
+// CountrySet returns the country set of that name, and false if there is
+// none.
+func (ir *IR) CountrySet(set string) (CompiledCountrySet, bool) {
+	for _, cs := range ir.CountrySets {
+		if cs.Set == set {
+			return cs, true
+		}
+	}
+	return CompiledCountrySet{}, false
+}
+
+// CompiledFQDNSet is a backend named set of the addresses of one family
+// that FQDNs resolve to. The compiler leaves Addresses empty; the firewall
+// resolves them before loading the ruleset and refreshes them afterwards.
+type CompiledFQDNSet struct {
+	Set       string   `json:"set"`
+	Family    string   `json:"family"` // ip | ip6
+	FQDNs     []string `json:"fqdns"`  // sorted, lower-case, without trailing dot
+	Addresses []string `json:"addresses,omitempty"`
+}
+
+// FQDNSet returns the FQDN set of that name, and false if there is none.
+func (ir *IR) FQDNSet(set string) (CompiledFQDNSet, bool) {
+	for _, fs := range ir.FQDNSets {
+		if fs.Set == set {
+			return fs, true
+		}
+	}
+	return CompiledFQDNSet{}, false
+}
+
+// Scheduled reports whether any firewall rule of the IR depends on the time
+// of day.
+func (ir *IR) Scheduled() bool {
+	for _, r := range ir.FirewallRules {
+		if r.Schedule != nil {
+			return true
+		}
+	}
+	return false
+}
+
+// NetNamespaces returns the network namespaces the firewall and NAT rules
+// target, in order of first use. Rules for the host are not counted.
+func (ir *IR) NetNamespaces() []string {
+	var out []string
+	seen := make(map[string]bool)
+	add := func(ns string) {
+		if ns != "" && !seen[ns] {
+			seen[ns] = true
+			out = append(out, ns)
+		}
+	}
+	for _, r := range ir.FirewallRules {
+		add(r.NetNS)
+	}
+	for _, r := range ir.NATRules {
+		add(r.NetNS)
+	}
+	for _, r := range ir.RawRules {
+		add(r.NetNS)
+	}
+	return out
+}
+
+// VRF returns the named VRF, and false if there is none.
+func (ir *IR) VRF(name string) (VRF, bool) {
+	for _, v := range ir.VRFs {
+		if v.Name == name {
+			return v, true
+		}
+	}
+	return VRF{}, false
+}
+
+// VRFOf returns the name of the VRF that iface is, or belongs to, and ""
+// if it is in the default VRF.
+func (ir *IR) VRFOf(iface string) string {
+	for _, v := range ir.VRFs {
+		if contains(v.Devices(), iface) {
+			return v.Name
+		}
+	}
+	return ""
+}
+
+// Group returns the group compiled to the named set, and false if there is
+// none.
+func (ir *IR) Group(set string) (CompiledGroup, bool) {
+	for _, g := range ir.AddressGroups {
+		if g.Set == set {
+			return g, true
+		}
+	}
+	for _, g := range ir.ServiceGroups {
+		if g.Set == set {
+			return g, true
+		}
+	}
+	return CompiledGroup{}, false
 }
 
 type CompiledNATRule struct {
+	Priority  int    `json:"priority"`
+	Comment   string `json:"comment"` // namespace/policy/rule
 	Type      string `json:"type"`
 	SrcAddr   string `json:"srcAddr"`
 	DstAddr   string `json:"dstAddr"`
 	ToAddr    string `json:"toAddr"`
+	Targets   []NATTarget `json:"targets,omitempty"` // DNAT across several destinations; ToAddr is empty
 	OutIface  string `json:"outIface"`
+	Protocol  string `json:"protocol,omitempty"`
+	DstPorts  string `json:"dstPorts,omitempty"`
+	ToPorts   string `json:"toPorts,omitempty"`
+	Flags     []string `json:"flags,omitempty"` // persistent, random
+	When      *RuleCondition `json:"when,omitempty"`
+	NetNS     string `json:"netns,omitempty"` // empty for the host
+	VRF       string `json:"vrf,omitempty"`   // empty for all traffic
 }
 
 type CompiledLoadBalancer struct {
@@ -224,11 +787,67 @@ type CompiledLoadBalancer struct {
 }
 
 type CompiledVPNConfig struct {
-	Interface  string    `json:"interface"`
-	ListenPort int       `json:"listenPort"`
-	Address    string    `json:"address"`
-	PrivateKey string    `json:"privateKey"`
-	Peers      []VPNPeer `json:"peers"`
+	Interface     string        `json:"interface"`
+	ListenPort    int           `json:"listenPort"`
+	Address       string        `json:"address"`
+	PrivateKey    string        `json:"privateKey"`
+	MTU           int           `json:"mtu,omitempty"`
+	UplinkMTU     int           `json:"uplinkMTU,omitempty"`
+	Encapsulation []string      `json:"encapsulation,omitempty"`
+	Transport     *VPNTransport `json:"transport,omitempty"`
+	Peers         []VPNPeer     `json:"peers"`
+}
+
+type CompiledWAN struct {
+	Mode    string               `json:"mode"`
+	Sources []string             `json:"sources"`
+	Uplinks []CompiledWANUplink  `json:"uplinks"`
+	Paths   []CompiledWANPath    `json:"paths,omitempty"`
+}
+
+type CompiledWANUplink struct {
+	Name      string `json:"name"`
+	Interface string `json:"interface"`
+	Gateway   string `json:"gateway"`
+	Weight    int    `json:"weight"`
+	Priority  int    `json:"priority"`
+	SNAT      string `json:"snat"`
+	Table     int    `json:"table"`
+	Mark      int    `json:"mark"`
+	Check     string `json:"check,omitempty"` // health target name
+	PathsOnly bool   `json:"pathsOnly,omitempty"`
+}
+
+// CompiledWANPath is a WANPath with its destination compiled to firewall
+// rule matches, one per address family. Uplink is set once the firewall
+// has chosen one from the current measurements; until then, and when no
+// uplink is healthy, the path steers nothing.
+type CompiledWANPath struct {
+	Name         string                 `json:"name"` // namespace/policy/path
+	Match        []CompiledFirewallRule `json:"match"`
+	Uplinks      []string               `json:"uplinks"`
+	MaxLatencyMs float64                `json:"maxLatencyMs,omitempty"`
+	MaxLossPct   float64                `json:"maxLossPct,omitempty"`
+
+	Uplink string `json:"uplink,omitempty"`
+	Reason string `json:"reason,omitempty"` // why Uplink was chosen
+}
+
+type CompiledIPSBypass struct {
+	Protocol string   `json:"protocol"`
+	Addrs    []string `json:"addrs"`
+	Ports    []string `json:"ports"`
+	Comment  string   `json:"comment"`
+}
+
+// CompiledAppRule matches one application-layer field against exact values
+// and domain suffixes.
+type CompiledAppRule struct {
+	Action   string   `json:"action"` // drop|reject|alert
+	Field    string   `json:"field"`  // tls.sni|ja3.hash|http.host|dns.query
+	Values   []string `json:"values"`
+	Suffixes []string `json:"suffixes,omitempty"` // "example.com" for "*.example.com"
+	Comment  string   `json:"comment"`
 }
 
 type CompiledIDSRule struct {
@@ -3,7 +3,17 @@ package policy
 import (
 	"fmt"
 	"net"
+	"regexp"
+	"strconv"
 	"strings"
+	"time"
+	"unicode"
+)
+
+var (
+	// appDomainRe accepts host names with an optional leading "*." wildcard.
+	appDomainRe = regexp.MustCompile(`^(\*\.)?([A-Za-z0-9_]([A-Za-z0-9_-]{0,61}[A-Za-z0-9_])?\.)*[A-Za-z0-9_]([A-Za-z0-9_-]{0,61}[A-Za-z0-9_])?$`)
+	ja3Re       = regexp.MustCompile(`^[0-9a-fA-F]{32}$`)
 )
 
 // Validator checks manifests for semantic correctness before compilation.
@@ -28,6 +38,7 @@ func (v *Validator) ValidateAll(manifests []*Manifest) error {
 			errs = append(errs, ve.Errors...)
 		}
 	}
+	errs = append(errs, checkServiceRefs(manifests)...)
 	if len(errs) > 0 {
 		return &ValidationError{Errors: errs}
 	}
@@ -46,15 +57,39 @@ func (v *Validator) Validate(m *Manifest) error {
 	case KindFirewallPolicy:
 		errs = append(errs, v.validateFirewall(ctx, m.FirewallSpec)...)
 	case KindLoadBalancerPolicy:
-		errs = append(errs, v.validateLB(ctx, m.LoadBalancerSpec)...)
+		errs = append(errs, v.validateLB(ctx, m.Metadata.Name, m.LoadBalancerSpec)...)
 	case KindVPNPolicy:
 		errs = append(errs, v.validateVPN(ctx, m.VPNSpec)...)
 	case KindNATPolicy:
 		errs = append(errs, v.validateNAT(ctx, m.NATSpec)...)
 	case KindIDSPolicy:
-		// IDS policies are loosely validated
+		// IDS rules are passed to Suricata as-is; only the bypass list is checked.
+		errs = append(errs, v.validateIDS(ctx, m.IDSSpec)...)
+	case KindHealthCheckPolicy:
+		errs = append(errs, v.validateHealthCheck(ctx, m.HealthCheckSpec)...)
+	case KindWANPolicy:
+		errs = append(errs, v.validateWAN(ctx, m.WANSpec)...)
+	case KindVRFPolicy:
+		errs = append(errs, v.validateVRF(ctx, m.VRFSpec)...)
+	case KindAppControlPolicy:
+		errs = append(errs, v.validateAppControl(ctx, m.AppControlSpec)...)
+	case KindPolicyTest:
+		errs = append(errs, v.validatePolicyTest(ctx, m.PolicyTestSpec)...)
+	case KindAddressGroup:
+		errs = append(errs, v.validateAddressGroup(ctx, m.AddressGroupSpec)...)
+	case KindServiceGroup:
+		errs = append(errs, v.validateServiceGroup(ctx, m.ServiceGroupSpec)...)
+	case KindServiceDefinition:
+		errs = append(errs, v.validateServiceDefinition(ctx, m.ServiceSpec)...)
 	default:
-		errs = append(errs, fmt.Sprintf("%s: unknown kind %q", ctx, m.Kind))
+		k, ok := lookupKind(m.Kind)
+		if !ok {
+			errs = append(errs, fmt.Sprintf("%s: unknown kind %q", ctx, m.Kind))
+			break
+		}
+		for _, e := range k.Validate(pluginMetadata(m.Metadata), m.PluginSpec) {
+			errs = append(errs, ctx+": "+e)
+		}
 	}
 
 	if len(errs) > 0 {
@@ -69,57 +104,319 @@ func (v *Validator) validateFirewall(ctx string, spec *FirewallPolicySpec) []str
 	}
 
 	var errs []string
-	validActions := map[string]bool{"ALLOW": true, "DROP": true, "REJECT": true, "LOG": true}
-	validProtocols := map[string]bool{"tcp": true, "udp": true, "icmp": true, "any": true, "ANY": true, "": true}
+	validActions := map[string]bool{"ALLOW": true, "DROP": true, "REJECT": true, "LOG": true, "TARPIT": true}
 
 	if spec.DefaultAction != "" && !validActions[spec.DefaultAction] {
 		errs = append(errs, fmt.Sprintf("%s: invalid defaultAction %q", ctx, spec.DefaultAction))
 	}
+	errs = append(errs, validateNetNS(ctx, spec.TargetNamespace)...)
+	errs = append(errs, validateVRFRef(ctx, spec.VRF, spec.TargetNamespace)...)
+
+	chains := make(map[string]bool, len(spec.Chains))
+	for i, c := range spec.Chains {
+		cCtx := fmt.Sprintf("%s chain[%d] %q", ctx, i, c.Name)
+		switch {
+		case c.Name == "":
+			errs = append(errs, cCtx+": name is required")
+		case chains[c.Name]:
+			errs = append(errs, cCtx+": duplicate name")
+		}
+		chains[c.Name] = true
+	}
+	if loop := chainLoop(spec.Chains); loop != nil {
+		errs = append(errs, fmt.Sprintf("%s: chains jump in a loop: %s", ctx, strings.Join(loop, " -> ")))
+	}
+	for i, r := range spec.RawRules {
+		errs = append(errs, validateRawRules(fmt.Sprintf("%s rawRules[%d]", ctx, i), spec, r)...)
+	}
 
+	// Rules of chains are compiled alongside the policy's own, so their
+	// names must not clash.
+	names := make(map[string]bool)
 	for i, r := range spec.Rules {
-		rCtx := fmt.Sprintf("%s rule[%d] %q", ctx, i, r.Name)
+		names[r.Name] = true
+		errs = append(errs, validateFirewallRule(fmt.Sprintf("%s rule[%d] %q", ctx, i, r.Name), spec, r, chains, "")...)
+	}
+	for _, c := range spec.Chains {
+		for i, r := range c.Rules {
+			rCtx := fmt.Sprintf("%s chain %q rule[%d] %q", ctx, c.Name, i, r.Name)
+			if r.Name != "" && names[r.Name] {
+				errs = append(errs, rCtx+": duplicate name")
+			}
+			names[r.Name] = true
+			errs = append(errs, validateFirewallRule(rCtx, spec, r, chains, c.Name)...)
+		}
+	}
 
-		if r.Name == "" {
-			errs = append(errs, rCtx+": name is required")
+	return errs
+}
+
+// validateRawRules checks that a snippet names a base chain and cannot
+// reach outside the chain it is inserted in. Whether nft accepts the
+// statements is left to `nft -c`.
+func validateRawRules(rCtx string, spec *FirewallPolicySpec, r RawRules) []string {
+	var errs []string
+	switch r.Chain {
+	case "input", "forward", "output":
+	default:
+		errs = append(errs, fmt.Sprintf("%s: chain must be input, forward or output, not %q", rCtx, r.Chain))
+	}
+	if spec.VRF != "" {
+		errs = append(errs, rCtx+": raw rules cannot be limited to a VRF")
+	}
+	if strings.TrimSpace(r.Rules) == "" {
+		errs = append(errs, rCtx+": rules is required")
+	}
+	if err := rawRulesBalanced(r.Rules); err != "" {
+		errs = append(errs, rCtx+": "+err)
+	}
+	return errs
+}
+
+// rawRulesBalanced reports a snippet whose braces or quotes, outside of
+// comments, do not pair up; a stray "}" would close the chain and let the
+// lines after it change the rest of the table.
+func rawRulesBalanced(rules string) string {
+	depth, quoted, comment := 0, false, false
+	for i := 0; i < len(rules); i++ {
+		switch ch := rules[i]; {
+		case ch == '\n':
+			if quoted {
+				return "quoted strings must end on the line they start"
+			}
+			comment = false
+		case comment:
+		case ch == '"':
+			quoted = !quoted
+		case quoted:
+		case ch == '#':
+			comment = true
+		case ch == '{':
+			depth++
+		case ch == '}':
+			if depth--; depth < 0 {
+				return "unmatched \"}\""
+			}
 		}
-		if !validActions[r.Action] {
-			errs = append(errs, fmt.Sprintf("%s: invalid action %q", rCtx, r.Action))
+	}
+	switch {
+	case quoted:
+		return "unterminated quoted string"
+	case depth > 0:
+		return "unmatched \"{\""
+	}
+	return ""
+}
+
+// validateFirewallRule checks a rule of spec, in the user-defined chain
+// named chain or in the base chains if chain is empty.
+func validateFirewallRule(rCtx string, spec *FirewallPolicySpec, r FirewallRule, chains map[string]bool, chain string) []string {
+	var errs []string
+	validActions := map[string]bool{
+		"ALLOW": true, "DROP": true, "REJECT": true, "LOG": true, "TARPIT": true,
+		"JUMP": true, "GOTO": true, "RETURN": true, "MARK": true, "DSCP": true,
+	}
+	validProtocols := map[string]bool{"tcp": true, "udp": true, "icmp": true, "any": true, "ANY": true, "": true}
+
+	if r.Name == "" {
+		errs = append(errs, rCtx+": name is required")
+	}
+	if !validActions[r.Action] {
+		errs = append(errs, fmt.Sprintf("%s: invalid action %q", rCtx, r.Action))
+	}
+	if !validProtocols[r.Protocol] {
+		errs = append(errs, fmt.Sprintf("%s: invalid protocol %q", rCtx, r.Protocol))
+	}
+	if r.Action == "TARPIT" && r.Protocol != "tcp" {
+		errs = append(errs, rCtx+": TARPIT requires protocol tcp")
+	}
+	switch r.Action {
+	case "JUMP", "GOTO":
+		if r.Target == "" {
+			errs = append(errs, fmt.Sprintf("%s: %s requires a target chain", rCtx, r.Action))
+		} else if !chains[r.Target] {
+			errs = append(errs, fmt.Sprintf("%s: target chain %q is not defined in the policy", rCtx, r.Target))
 		}
-		if !validProtocols[r.Protocol] {
-			errs = append(errs, fmt.Sprintf("%s: invalid protocol %q", rCtx, r.Protocol))
+	default:
+		if r.Target != "" {
+			errs = append(errs, rCtx+": target only applies to JUMP and GOTO")
 		}
+	}
+	if chain == "" && r.Action == "RETURN" {
+		errs = append(errs, rCtx+": RETURN is only valid in a chain")
+	}
+	// TARPIT redirects in prerouting, ahead of the chains.
+	if chain != "" && r.Action == "TARPIT" {
+		errs = append(errs, rCtx+": TARPIT cannot be used in a chain")
+	}
+	if r.ConnLimit < 0 {
+		errs = append(errs, fmt.Sprintf("%s: connLimit %d is negative", rCtx, r.ConnLimit))
+	}
+	errs = append(errs, validateMarking(rCtx, r, chain)...)
+	switch {
+	case r.RejectWith == "":
+	case r.Action != "REJECT":
+		errs = append(errs, rCtx+": rejectWith only applies to REJECT")
+	case r.RejectWith == RejectTCPReset:
+		if r.Protocol != "tcp" {
+			errs = append(errs, rCtx+": rejectWith tcp-reset requires protocol tcp")
+		}
+	case !validRejectWith(r.RejectWith):
+		errs = append(errs, fmt.Sprintf("%s: invalid rejectWith %q", rCtx, r.RejectWith))
+	}
+	errs = append(errs, validateCondition(rCtx, r.When)...)
+	errs = append(errs, validateSchedule(rCtx, r.Schedule)...)
+	errs = append(errs, validateGroupRefs(rCtx+" source", r.Protocol, r.Source)...)
+	errs = append(errs, validateGroupRefs(rCtx+" destination", r.Protocol, r.Dest)...)
+	errs = append(errs, validateInterfaces(rCtx+" source", r.Source.Interfaces)...)
+	errs = append(errs, validateInterfaces(rCtx+" destination", r.Dest.Interfaces)...)
+	errs = append(errs, validateMACs(rCtx+" source", r.Source.MACAddresses)...)
+	if len(r.Dest.MACAddresses) > 0 {
+		errs = append(errs, rCtx+": destination macAddresses are not supported")
+	}
+	// Traffic to the host has no output interface yet, and traffic from
+	// it no input interface.
+	if hasZone(r.Dest.Zones, "localhost") {
+		if len(r.Dest.Interfaces) > 0 {
+			errs = append(errs, rCtx+": destination interfaces cannot match traffic to localhost")
+		}
+	} else if hasZone(r.Source.Zones, "localhost") && len(r.Source.Interfaces) > 0 {
+		errs = append(errs, rCtx+": source interfaces cannot match traffic from localhost")
+	}
+	if hasZone(r.Source.Zones, "localhost") && len(r.Source.MACAddresses) > 0 {
+		errs = append(errs, rCtx+": source macAddresses cannot match traffic from localhost")
+	}
+	// Country sets are filled from GeoIP in the host's table only.
+	if spec.TargetNamespace != "" && len(r.Source.Countries)+len(r.Dest.Countries) > 0 {
+		errs = append(errs, rCtx+": countries cannot be used with targetNamespace")
+	}
 
-		// Validate CIDR addresses
-		for _, addr := range append(r.Source.Addresses, r.Dest.Addresses...) {
-			if _, _, err := net.ParseCIDR(addr); err != nil {
-				if net.ParseIP(addr) == nil {
-					errs = append(errs, fmt.Sprintf("%s: invalid address %q", rCtx, addr))
-				}
-			}
+	// Validate CIDR addresses
+	for _, addr := range append(r.Source.Addresses, r.Dest.Addresses...) {
+		if err := validateAddr(addr); err != "" {
+			errs = append(errs, fmt.Sprintf("%s: %s", rCtx, err))
 		}
+	}
+	if len(r.Source.Addresses) > 0 && len(r.Dest.Addresses) > 0 {
+		s4, s6 := splitAddrs(r.Source.Addresses)
+		d4, d6 := splitAddrs(r.Dest.Addresses)
+		if (len(s4) == 0 || len(d4) == 0) && (len(s6) == 0 || len(d6) == 0) {
+			errs = append(errs, rCtx+": source and destination addresses have no address family in common")
+		}
+	}
 
-		// Validate port ranges
-		for _, port := range append(r.Source.Ports, r.Dest.Ports...) {
-			if port < 1 || port > 65535 {
-				errs = append(errs, fmt.Sprintf("%s: port %d out of range", rCtx, port))
-			}
+	// Validate port ranges
+	for _, port := range append(r.Source.Ports, r.Dest.Ports...) {
+		if port < 1 || port > 65535 {
+			errs = append(errs, fmt.Sprintf("%s: port %d out of range", rCtx, port))
 		}
-		for _, pr := range append(r.Source.PortRanges, r.Dest.PortRanges...) {
-			if pr.Start >= pr.End {
-				errs = append(errs, fmt.Sprintf("%s: portRange start >= end (%d-%d)", rCtx, pr.Start, pr.End))
-			}
+	}
+	for _, pr := range append(r.Source.PortRanges, r.Dest.PortRanges...) {
+		if pr.Start >= pr.End {
+			errs = append(errs, fmt.Sprintf("%s: portRange start >= end (%d-%d)", rCtx, pr.Start, pr.End))
 		}
 	}
 
 	return errs
 }
 
-func (v *Validator) validateLB(ctx string, spec *LoadBalancerPolicySpec) []string {
+// validateMarking checks the mark and dscp of r, which only MARK and DSCP
+// rules set.
+func validateMarking(rCtx string, r FirewallRule, chain string) []string {
+	var errs []string
+	switch {
+	case r.Action == "MARK" && r.Mark == "":
+		errs = append(errs, rCtx+": MARK requires a mark")
+	case r.Action == "MARK":
+		if _, err := strconv.ParseUint(r.Mark, 0, 32); err != nil {
+			errs = append(errs, fmt.Sprintf("%s: invalid mark %q", rCtx, r.Mark))
+		}
+	case r.Mark != "":
+		errs = append(errs, rCtx+": mark only applies to MARK")
+	}
+	switch {
+	case r.Action == "DSCP" && r.DSCP == "":
+		errs = append(errs, rCtx+": DSCP requires a dscp")
+	case r.Action == "DSCP":
+		if !validDSCP(r.DSCP) {
+			errs = append(errs, fmt.Sprintf("%s: invalid dscp %q", rCtx, r.DSCP))
+		}
+	case r.DSCP != "":
+		errs = append(errs, rCtx+": dscp only applies to DSCP")
+	}
+	if r.Action != "MARK" && r.Action != "DSCP" {
+		return errs
+	}
+	// Marking happens in prerouting, or in output for traffic from the
+	// host, where the chains are not reached and inbound traffic has no
+	// output interface yet.
+	if chain != "" {
+		errs = append(errs, fmt.Sprintf("%s: %s cannot be used in a chain", rCtx, r.Action))
+	}
+	if !hasZone(r.Source.Zones, "localhost") && len(r.Dest.Interfaces) > 0 {
+		errs = append(errs, fmt.Sprintf("%s: %s cannot match destination interfaces of inbound traffic", rCtx, r.Action))
+	}
+	return errs
+}
+
+// chainLoop returns the chains of a loop of jumps and gotos among chains,
+// the first one repeated at the end, or nil if there is none.
+func chainLoop(chains []FirewallChain) []string {
+	targets := make(map[string][]string, len(chains))
+	for _, c := range chains {
+		for _, r := range c.Rules {
+			if r.Target != "" {
+				targets[c.Name] = append(targets[c.Name], r.Target)
+			}
+		}
+	}
+	const (
+		visiting = 1
+		done     = 2
+	)
+	state := make(map[string]int)
+	var path []string
+	var visit func(name string) []string
+	visit = func(name string) []string {
+		switch state[name] {
+		case visiting:
+			for i, n := range path {
+				if n == name {
+					return append(append([]string(nil), path[i:]...), name)
+				}
+			}
+		case done:
+			return nil
+		}
+		state[name] = visiting
+		path = append(path, name)
+		for _, t := range targets[name] {
+			if loop := visit(t); loop != nil {
+				return loop
+			}
+		}
+		path = path[:len(path)-1]
+		state[name] = done
+		return nil
+	}
+	for _, c := range chains {
+		if loop := visit(c.Name); loop != nil {
+			return loop
+		}
+	}
+	return nil
+}
+
+func (v *Validator) validateLB(ctx, name string, spec *LoadBalancerPolicySpec) []string {
 	if spec == nil {
 		return []string{ctx + ": spec is required for LoadBalancerPolicy"}
 	}
 
 	var errs []string
+	// The name becomes HAProxy section names and error page file names.
+	if name != "" && !lbNameRe.MatchString(name) {
+		errs = append(errs, fmt.Sprintf("%s: invalid load balancer name %q (letters, digits, _ . -)", ctx, name))
+	}
 	validAlgorithms := map[string]bool{
 		"roundrobin": true, "leastconn": true, "source": true, "random": true,
 	}
@@ -127,8 +424,56 @@ func (v *Validator) validateLB(ctx string, spec *LoadBalancerPolicySpec) []strin
 	if spec.Frontend.Bind == "" {
 		errs = append(errs, ctx+": frontend.bind is required")
 	}
-	if spec.Frontend.Mode == "" {
-		errs = append(errs, ctx+": frontend.mode is required (tcp|http)")
+	switch spec.Frontend.Mode {
+	case "":
+		errs = append(errs, ctx+": frontend.mode is required (tcp|http|udp)")
+	case "tcp", "http":
+		if spec.Frontend.UDPResponses != nil {
+			errs = append(errs, ctx+": frontend.udpResponses only applies to udp mode")
+		}
+	case "udp":
+		// UDP is served by the stream proxy, which can neither terminate TLS,
+		// accept PROXY headers on datagrams, nor emit PROXY v2.
+		if spec.TLS != nil {
+			errs = append(errs, ctx+": tls is not supported in udp mode")
+		}
+		if spec.Frontend.AcceptProxy {
+			errs = append(errs, ctx+": frontend.acceptProxy is not supported in udp mode")
+		}
+		if spec.Backend.ProxyProtocol == "v2" {
+			errs = append(errs, ctx+": backend.proxyProtocol v2 is not supported in udp mode")
+		}
+		if r := spec.Frontend.UDPResponses; r != nil && *r < 0 {
+			errs = append(errs, fmt.Sprintf("%s: invalid frontend.udpResponses %d", ctx, *r))
+		}
+	default:
+		errs = append(errs, fmt.Sprintf("%s: invalid frontend.mode %q", ctx, spec.Frontend.Mode))
+	}
+
+	switch spec.Backend.ProxyProtocol {
+	case "", "v1", "v2":
+	default:
+		errs = append(errs, fmt.Sprintf("%s: invalid backend.proxyProtocol %q (v1|v2)", ctx, spec.Backend.ProxyProtocol))
+	}
+
+	// Status codes HAProxy's errorfile directive accepts.
+	errorfileCodes := map[int]bool{
+		200: true, 400: true, 401: true, 403: true, 404: true, 405: true, 407: true, 408: true,
+		410: true, 413: true, 425: true, 429: true, 500: true, 501: true, 502: true, 503: true, 504: true,
+	}
+	if len(spec.Backend.ErrorPages) > 0 && spec.Frontend.Mode != "http" {
+		errs = append(errs, ctx+": backend.errorPages requires http mode")
+	}
+	for code, page := range spec.Backend.ErrorPages {
+		if !errorfileCodes[code] {
+			errs = append(errs, fmt.Sprintf("%s: errorPages: unsupported status %d", ctx, code))
+		}
+		if page == "" {
+			errs = append(errs, fmt.Sprintf("%s: errorPages[%d]: page HTML is required", ctx, code))
+		}
+		if len(page) > maxErrorPageSize {
+			errs = append(errs, fmt.Sprintf("%s: errorPages[%d]: page is %d bytes, at most %d", ctx, code, len(page), maxErrorPageSize))
+		}
 	}
 
 	algo := spec.Backend.Algorithm
@@ -136,8 +481,29 @@ func (v *Validator) validateLB(ctx string, spec *LoadBalancerPolicySpec) []strin
 		errs = append(errs, fmt.Sprintf("%s: unknown algorithm %q", ctx, algo))
 	}
 
-	if len(spec.Backend.Servers) == 0 {
-		errs = append(errs, ctx+": backend must have at least one server")
+	if len(spec.Backend.Servers) == 0 && spec.Backend.Discovery == nil {
+		errs = append(errs, ctx+": backend must have at least one server or a discovery source")
+	}
+	if d := spec.Backend.Discovery; d != nil {
+		switch d.Type {
+		case "dns", "consul", "kubernetes":
+		default:
+			errs = append(errs, fmt.Sprintf("%s: invalid discovery.type %q (dns|consul|kubernetes)", ctx, d.Type))
+		}
+		if d.Name == "" {
+			errs = append(errs, ctx+": discovery.name is required")
+		}
+		if d.Interval != "" {
+			if _, err := time.ParseDuration(d.Interval); err != nil {
+				errs = append(errs, fmt.Sprintf("%s: invalid discovery.interval %q", ctx, d.Interval))
+			}
+		}
+		if d.MaxServers < 0 || d.MaxServers > 1024 {
+			errs = append(errs, fmt.Sprintf("%s: discovery.maxServers %d out of range (1-1024)", ctx, d.MaxServers))
+		}
+		if spec.Frontend.Mode == "udp" {
+			errs = append(errs, ctx+": discovery is not supported in udp mode")
+		}
 	}
 	for i, s := range spec.Backend.Servers {
 		if s.Address == "" {
@@ -166,6 +532,41 @@ func (v *Validator) validateVPN(ctx string, spec *VPNPolicySpec) []string {
 	if _, _, err := net.ParseCIDR(spec.Address); err != nil {
 		errs = append(errs, fmt.Sprintf("%s: invalid address CIDR %q", ctx, spec.Address))
 	}
+	if spec.MTU != 0 && (spec.MTU < 1280 || spec.MTU > 9000) {
+		errs = append(errs, fmt.Sprintf("%s: mtu %d out of range (1280-9000)", ctx, spec.MTU))
+	}
+	if spec.UplinkMTU != 0 && (spec.UplinkMTU < 576 || spec.UplinkMTU > 9216) {
+		errs = append(errs, fmt.Sprintf("%s: uplinkMTU %d out of range (576-9216)", ctx, spec.UplinkMTU))
+	}
+	// Keep in sync with the overhead table in internal/vpn/mtu.go.
+	validEncaps := map[string]bool{"none": true, "pppoe": true, "gre": true, "vxlan": true, "wireguard": true, "ipsec": true}
+	for _, e := range spec.Encapsulation {
+		if !validEncaps[e] {
+			errs = append(errs, fmt.Sprintf("%s: unknown encapsulation %q", ctx, e))
+		}
+	}
+	if spec.DefaultKeepAlive < 0 || spec.DefaultKeepAlive > 65535 {
+		errs = append(errs, fmt.Sprintf("%s: invalid defaultKeepAlive %d", ctx, spec.DefaultKeepAlive))
+	}
+	if t := spec.Transport; t != nil {
+		switch t.Mode {
+		case "", "udp":
+		case "udp2raw", "wstunnel":
+			if _, _, err := net.SplitHostPort(t.Listen); err != nil {
+				errs = append(errs, fmt.Sprintf("%s: transport.listen %q must be host:port", ctx, t.Listen))
+			}
+			if t.Mode == "udp2raw" && t.Secret == "" {
+				errs = append(errs, ctx+": transport.secret is required for udp2raw")
+			}
+			// udp2raw reads listen and key from a config file, one option
+			// per line; blanks or line breaks would add options of their own.
+			if strings.ContainsFunc(t.Listen+t.Secret, unicode.IsSpace) || strings.ContainsFunc(t.Listen+t.Secret, unicode.IsControl) {
+				errs = append(errs, ctx+": transport.listen and transport.secret must not contain spaces or control characters")
+			}
+		default:
+			errs = append(errs, fmt.Sprintf("%s: invalid transport mode %q", ctx, t.Mode))
+		}
+	}
 	for i, peer := range spec.Peers {
 		if peer.PublicKey == "" {
 			errs = append(errs, fmt.Sprintf("%s peer[%d]: publicKey is required", ctx, i))
@@ -184,11 +585,807 @@ func (v *Validator) validateNAT(ctx string, spec *NATPolicySpec) []string {
 		return []string{ctx + ": spec is required for NATPolicy"}
 	}
 	var errs []string
-	validTypes := map[string]bool{"SNAT": true, "DNAT": true, "MASQUERADE": true}
+	validTypes := map[string]bool{"SNAT": true, "DNAT": true, "MASQUERADE": true, "NETMAP": true}
+	errs = append(errs, validateNetNS(ctx, spec.TargetNamespace)...)
+	errs = append(errs, validateVRFRef(ctx, spec.VRF, spec.TargetNamespace)...)
 	for i, r := range spec.Rules {
+		rCtx := fmt.Sprintf("%s rule[%d]", ctx, i)
 		if !validTypes[r.Type] {
-			errs = append(errs, fmt.Sprintf("%s rule[%d]: invalid type %q", ctx, i, r.Type))
+			errs = append(errs, fmt.Sprintf("%s: invalid type %q", rCtx, r.Type))
+		}
+		switch strings.ToLower(r.Protocol) {
+		case "", "any", "tcp", "udp":
+		default:
+			errs = append(errs, fmt.Sprintf("%s: invalid protocol %q (tcp|udp|any)", rCtx, r.Protocol))
+		}
+		for _, p := range []struct{ field, value string }{{"ports", r.Ports}, {"toPorts", r.ToPorts}} {
+			if p.value == "" {
+				continue
+			}
+			if _, _, ok := parsePortRange(p.value); !ok {
+				errs = append(errs, fmt.Sprintf("%s: %s %q must be a port or a range like 1024-65535", rCtx, p.field, p.value))
+			}
+		}
+		if len(r.ToDest) > 1 {
+			errs = append(errs, validateNATTargets(rCtx, r)...)
+		} else if _, _, err := net.SplitHostPort(r.ToDest.String()); err == nil && r.ToPorts != "" {
+			errs = append(errs, rCtx+": give the port in toDest or in toPorts, not both")
+		}
+		if r.Ports != "" && r.Type != "DNAT" {
+			errs = append(errs, rCtx+": ports only applies to DNAT")
+		}
+		if (r.Persistent || r.Random) && (r.Type == "DNAT" || r.Type == "NETMAP") {
+			errs = append(errs, rCtx+": persistent and random apply to SNAT and MASQUERADE")
+		}
+		switch r.Type {
+		case "DNAT":
+			if len(r.ToDest) == 0 {
+				errs = append(errs, rCtx+": DNAT requires toDest")
+			} else if len(r.ToDest) == 1 && !validNATAddr(r.ToDest.String(), true) {
+				errs = append(errs, fmt.Sprintf("%s: toDest %q must be an IP address, optionally with :port", rCtx, r.ToDest.String()))
+			}
+		case "SNAT":
+			if r.ToSource == "" && r.OutIface == "" {
+				errs = append(errs, rCtx+": SNAT requires toSource or outInterface")
+			} else if r.ToSource != "" && !validNATAddr(r.ToSource, true) {
+				errs = append(errs, fmt.Sprintf("%s: toSource %q must be an IP address, optionally with :port", rCtx, r.ToSource))
+			}
+		case "NETMAP":
+			errs = append(errs, validateNetmap(rCtx, r)...)
+		}
+		if r.OutIface != "" {
+			errs = append(errs, validateInterfaces(rCtx+": outInterface", []string{r.OutIface})...)
+		}
+		for _, a := range []struct{ field, value string }{{"source", r.Source}, {"destination", r.Dest}} {
+			if a.value != "" && !validNATAddr(a.value, false) {
+				errs = append(errs, fmt.Sprintf("%s: %s %q must be an IP address or CIDR", rCtx, a.field, a.value))
+			}
 		}
+		errs = append(errs, validateCondition(rCtx, r.When)...)
+	}
+	return errs
+}
+
+// validNATAddr accepts an IP address or CIDR prefix and, with withPort, an
+// address followed by :port or :lo-hi ([addr]:port for IPv6).
+func validNATAddr(s string, withPort bool) bool {
+	if net.ParseIP(s) != nil {
+		return true
+	}
+	if _, _, err := net.ParseCIDR(s); err == nil {
+		return !withPort
+	}
+	if !withPort {
+		return false
+	}
+	host, port, err := net.SplitHostPort(s)
+	if err != nil || net.ParseIP(host) == nil {
+		return false
+	}
+	_, _, ok := parsePortRange(port)
+	return ok
+}
+
+// validateNATTargets checks a weighted toDest list. The hash map yields an
+// address, so the targets share one port, given in toPorts.
+func validateNATTargets(rCtx string, r NATRule) []string {
+	var errs []string
+	if r.Type != "DNAT" {
+		errs = append(errs, rCtx+": a list of toDest targets needs type DNAT")
+	}
+	family := 0
+	for i, t := range r.ToDest {
+		ip := net.ParseIP(t.Address)
+		if ip == nil {
+			errs = append(errs, fmt.Sprintf("%s toDest[%d]: %q must be an IP address; set the port in toPorts", rCtx, i, t.Address))
+			continue
+		}
+		f := 6
+		if ip.To4() != nil {
+			f = 4
+		}
+		if family != 0 && f != family {
+			errs = append(errs, fmt.Sprintf("%s toDest[%d]: targets must be the same address family", rCtx, i))
+		}
+		family = f
+		if t.Weight < 0 || t.Weight > 1000 {
+			errs = append(errs, fmt.Sprintf("%s toDest[%d]: weight %d out of range (1-1000)", rCtx, i, t.Weight))
+		}
+	}
+	if r.ToPorts != "" {
+		if lo, hi, ok := parsePortRange(r.ToPorts); ok && lo != hi {
+			errs = append(errs, rCtx+": toPorts must be a single port with several toDest targets")
+		}
+	}
+	return errs
+}
+
+// validateNetmap checks that a 1:1 mapping joins two prefixes of the same
+// family and size; the host part of each address carries over unchanged.
+func validateNetmap(rCtx string, r NATRule) []string {
+	if r.Internal == "" || r.External == "" {
+		return []string{rCtx + ": NETMAP requires internal and external prefixes"}
+	}
+	var errs []string
+	_, in, err := net.ParseCIDR(r.Internal)
+	if err != nil {
+		errs = append(errs, fmt.Sprintf("%s: internal %q is not a CIDR prefix", rCtx, r.Internal))
+	}
+	_, ex, err2 := net.ParseCIDR(r.External)
+	if err2 != nil {
+		errs = append(errs, fmt.Sprintf("%s: external %q is not a CIDR prefix", rCtx, r.External))
+	}
+	if err != nil || err2 != nil {
+		return errs
+	}
+	inOnes, inBits := in.Mask.Size()
+	exOnes, exBits := ex.Mask.Size()
+	if inBits != exBits {
+		errs = append(errs, rCtx+": internal and external prefixes must be the same address family")
+	} else if inOnes != exOnes {
+		errs = append(errs, fmt.Sprintf("%s: internal /%d and external /%d must be the same size", rCtx, inOnes, exOnes))
+	}
+	if r.Ports != "" || r.ToPorts != "" || r.ToSource != "" || len(r.ToDest) > 0 {
+		errs = append(errs, rCtx+": NETMAP maps whole prefixes; ports, toSource and toDest do not apply")
+	}
+	return errs
+}
+
+// parsePortRange parses "80" or "1024-65535".
+func parsePortRange(s string) (lo, hi int, ok bool) {
+	from, to, isRange := strings.Cut(s, "-")
+	if !isRange {
+		to = from
+	}
+	lo, err1 := strconv.Atoi(strings.TrimSpace(from))
+	hi, err2 := strconv.Atoi(strings.TrimSpace(to))
+	if err1 != nil || err2 != nil || lo < 1 || hi > 65535 || lo > hi {
+		return 0, 0, false
+	}
+	return lo, hi, true
+}
+
+func (v *Validator) validateHealthCheck(ctx string, spec *HealthCheckPolicySpec) []string {
+	if spec == nil {
+		return []string{ctx + ": spec is required for HealthCheckPolicy"}
+	}
+	var errs []string
+	seen := make(map[string]bool)
+	for i, t := range spec.Targets {
+		tCtx := fmt.Sprintf("%s target[%d] %q", ctx, i, t.Name)
+		if t.Name == "" {
+			errs = append(errs, tCtx+": name is required")
+		} else if seen[t.Name] {
+			errs = append(errs, tCtx+": duplicate name")
+		}
+		seen[t.Name] = true
+		errs = append(errs, validateHealthTarget(tCtx, t)...)
+	}
+	return errs
+}
+
+// ValidateHealthTarget checks a target defined outside a manifest, such as
+// a monitor created through the API.
+func ValidateHealthTarget(t HealthTarget) error {
+	if errs := validateHealthTarget(fmt.Sprintf("target %q", t.Name), t); len(errs) > 0 {
+		return &ValidationError{Errors: errs}
+	}
+	return nil
+}
+
+func validateHealthTarget(tCtx string, t HealthTarget) []string {
+	var errs []string
+	switch t.Type {
+	case "icmp":
+		if t.Address == "" {
+			errs = append(errs, tCtx+": address is required")
+		}
+	case "tcp":
+		if _, _, err := net.SplitHostPort(t.Address); err != nil {
+			errs = append(errs, fmt.Sprintf("%s: tcp address %q must be host:port", tCtx, t.Address))
+		}
+	case "http":
+		if !strings.HasPrefix(t.Address, "http://") && !strings.HasPrefix(t.Address, "https://") {
+			errs = append(errs, fmt.Sprintf("%s: http address %q must be a URL", tCtx, t.Address))
+		}
+	case "dns":
+		if t.Address == "" {
+			errs = append(errs, tCtx+": address of the DNS server is required")
+		}
+		if t.Query == "" {
+			errs = append(errs, tCtx+": query is required for dns checks")
+		}
+		switch strings.ToUpper(t.RecordType) {
+		case "", "A", "AAAA":
+		default:
+			errs = append(errs, fmt.Sprintf("%s: invalid recordType %q (A|AAAA)", tCtx, t.RecordType))
+		}
+		if t.ExpectAddress != "" && net.ParseIP(t.ExpectAddress) == nil {
+			errs = append(errs, fmt.Sprintf("%s: invalid expectAddress %q", tCtx, t.ExpectAddress))
+		}
+	default:
+		errs = append(errs, fmt.Sprintf("%s: invalid type %q (icmp|tcp|http|dns)", tCtx, t.Type))
+	}
+	for field, d := range map[string]string{"interval": t.Interval, "timeout": t.Timeout} {
+		if d == "" {
+			continue
+		}
+		if _, err := time.ParseDuration(d); err != nil {
+			errs = append(errs, fmt.Sprintf("%s: invalid %s %q", tCtx, field, d))
+		}
+	}
+	if t.Rise < 0 || t.Fall < 0 {
+		errs = append(errs, tCtx+": rise and fall must be positive")
+	}
+	return errs
+}
+
+func (v *Validator) validateIDS(ctx string, spec *IDSPolicySpec) []string {
+	if spec == nil {
+		return []string{ctx + ": spec is required for IDSPolicy"}
+	}
+	var errs []string
+	if spec.Mode != "" && spec.Mode != "ids" && spec.Mode != "ips" {
+		errs = append(errs, fmt.Sprintf("%s: invalid mode %q (ids|ips)", ctx, spec.Mode))
+	}
+	for i, b := range spec.Bypass {
+		bCtx := fmt.Sprintf("%s bypass[%d] %q", ctx, i, b.Name)
+		switch b.Protocol {
+		case "tcp", "udp", "any", "ANY", "":
+		default:
+			errs = append(errs, fmt.Sprintf("%s: invalid protocol %q (tcp|udp|any)", bCtx, b.Protocol))
+		}
+		if len(b.Addresses) == 0 && len(b.Ports) == 0 {
+			errs = append(errs, bCtx+": addresses or ports are required")
+		}
+		if len(b.Ports) > 0 && (b.Protocol == "" || b.Protocol == "any" || b.Protocol == "ANY") {
+			errs = append(errs, bCtx+": ports require protocol tcp or udp")
+		}
+		for _, addr := range b.Addresses {
+			if _, _, err := net.ParseCIDR(addr); err != nil && net.ParseIP(addr) == nil {
+				errs = append(errs, fmt.Sprintf("%s: invalid address %q", bCtx, addr))
+			}
+		}
+		for _, port := range b.Ports {
+			if port < 1 || port > 65535 {
+				errs = append(errs, fmt.Sprintf("%s: port %d out of range", bCtx, port))
+			}
+		}
+	}
+	return errs
+}
+
+func (v *Validator) validateWAN(ctx string, spec *WANPolicySpec) []string {
+	if spec == nil {
+		return []string{ctx + ": spec is required for WANPolicy"}
+	}
+	var errs []string
+	if spec.Mode != "" && spec.Mode != "balance" && spec.Mode != "failover" {
+		errs = append(errs, fmt.Sprintf("%s: invalid mode %q (balance|failover)", ctx, spec.Mode))
+	}
+	for _, src := range spec.Sources {
+		if _, _, err := net.ParseCIDR(src); err != nil && net.ParseIP(src) == nil {
+			errs = append(errs, fmt.Sprintf("%s: invalid source %q", ctx, src))
+		}
+	}
+	if len(spec.Uplinks) == 0 {
+		errs = append(errs, ctx+": at least one uplink is required")
+	}
+	names := make(map[string]bool)
+	tables := make(map[int]bool)
+	shared := 0
+	for i, u := range spec.Uplinks {
+		uCtx := fmt.Sprintf("%s uplink[%d] %q", ctx, i, u.Name)
+		if u.Name == "" {
+			errs = append(errs, uCtx+": name is required")
+		} else if names[u.Name] {
+			errs = append(errs, uCtx+": duplicate name")
+		}
+		names[u.Name] = true
+		if u.Interface == "" {
+			errs = append(errs, uCtx+": interface is required")
+		}
+		if u.Gateway != "" && net.ParseIP(u.Gateway) == nil {
+			errs = append(errs, fmt.Sprintf("%s: invalid gateway %q", uCtx, u.Gateway))
+		}
+		if u.SNAT != "" && net.ParseIP(u.SNAT) == nil {
+			errs = append(errs, fmt.Sprintf("%s: invalid snat address %q", uCtx, u.SNAT))
+		}
+		if u.Weight < 0 || u.Weight > 100 {
+			errs = append(errs, fmt.Sprintf("%s: weight %d out of range (0-100)", uCtx, u.Weight))
+		}
+		if u.Table != 0 {
+			// 253-255 are the kernel's default/main/local tables.
+			if u.Table < 1 || u.Table >= 253 && u.Table <= 255 {
+				errs = append(errs, fmt.Sprintf("%s: table %d is reserved", uCtx, u.Table))
+			} else if tables[u.Table] {
+				errs = append(errs, fmt.Sprintf("%s: table %d used by another uplink", uCtx, u.Table))
+			}
+			tables[u.Table] = true
+		}
+		if u.Check != nil {
+			errs = append(errs, validateHealthTarget(uCtx+" check", *u.Check)...)
+		}
+		if !u.PathsOnly {
+			shared++
+		}
+	}
+	if len(spec.Uplinks) > 0 && shared == 0 {
+		errs = append(errs, ctx+": at least one uplink must not be pathsOnly")
+	}
+
+	paths := make(map[string]bool)
+	for i, p := range spec.Paths {
+		pCtx := fmt.Sprintf("%s path[%d] %q", ctx, i, p.Name)
+		if p.Name == "" {
+			errs = append(errs, pCtx+": name is required")
+		} else if paths[p.Name] {
+			errs = append(errs, pCtx+": duplicate name")
+		}
+		paths[p.Name] = true
+		switch p.Protocol {
+		case "", "any", "tcp", "udp":
+		default:
+			errs = append(errs, fmt.Sprintf("%s: invalid protocol %q (tcp|udp|any)", pCtx, p.Protocol))
+		}
+		d := p.Destination
+		if len(d.Zones)+len(d.IPSets)+len(d.Interfaces)+len(d.MACAddresses) > 0 {
+			errs = append(errs, pCtx+": destination zones, ipsets, interfaces and macAddresses are not supported")
+		}
+		if len(d.Ports)+len(d.PortRanges) > 0 && p.Protocol != "tcp" && p.Protocol != "udp" {
+			errs = append(errs, pCtx+": ports require protocol tcp or udp")
+		}
+		for _, addr := range d.Addresses {
+			if err := validateAddr(addr); err != "" {
+				errs = append(errs, fmt.Sprintf("%s: %s", pCtx, err))
+			}
+		}
+		for _, port := range d.Ports {
+			if port < 1 || port > 65535 {
+				errs = append(errs, fmt.Sprintf("%s: port %d out of range", pCtx, port))
+			}
+		}
+		for _, pr := range d.PortRanges {
+			if pr.Start >= pr.End {
+				errs = append(errs, fmt.Sprintf("%s: portRange start >= end (%d-%d)", pCtx, pr.Start, pr.End))
+			}
+		}
+		errs = append(errs, validateGroupRefs(pCtx+" destination", p.Protocol, d)...)
+		if len(p.Uplinks) == 0 {
+			errs = append(errs, pCtx+": at least one uplink is required")
+		}
+		for _, u := range p.Uplinks {
+			if !names[u] {
+				errs = append(errs, fmt.Sprintf("%s: unknown uplink %q", pCtx, u))
+			}
+		}
+		if p.MaxLatency != "" {
+			if d, err := time.ParseDuration(p.MaxLatency); err != nil || d <= 0 {
+				errs = append(errs, fmt.Sprintf("%s: invalid maxLatency %q", pCtx, p.MaxLatency))
+			}
+		}
+		if p.MaxLoss < 0 || p.MaxLoss > 100 {
+			errs = append(errs, fmt.Sprintf("%s: maxLoss %g out of range (0-100)", pCtx, p.MaxLoss))
+		}
+	}
+	return errs
+}
+
+func (v *Validator) validateVRF(ctx string, spec *VRFPolicySpec) []string {
+	if spec == nil {
+		return []string{ctx + ": spec is required for VRFPolicy"}
+	}
+	var errs []string
+	if len(spec.VRFs) == 0 {
+		errs = append(errs, ctx+": at least one vrf is required")
+	}
+	for i, vrf := range spec.VRFs {
+		vCtx := fmt.Sprintf("%s vrf[%d] %q", ctx, i, vrf.Name)
+		if vrf.Name == "" {
+			errs = append(errs, vCtx+": name is required")
+		} else if !vrfNameRe.MatchString(vrf.Name) {
+			errs = append(errs, vCtx+": invalid name")
+		}
+		// 253-255 are the kernel's default/main/local tables.
+		if vrf.Table < 1 || vrf.Table >= 253 && vrf.Table <= 255 {
+			errs = append(errs, fmt.Sprintf("%s: table %d is reserved or missing", vCtx, vrf.Table))
+		}
+		members := make(map[string]bool, len(vrf.Interfaces))
+		for _, n := range vrf.Interfaces {
+			if strings.HasSuffix(n, "*") {
+				errs = append(errs, fmt.Sprintf("%s: interface %q cannot be a wildcard", vCtx, n))
+			} else if n == vrf.Name {
+				errs = append(errs, vCtx+": a VRF cannot contain itself")
+			} else if members[n] {
+				errs = append(errs, fmt.Sprintf("%s: interface %q listed twice", vCtx, n))
+			}
+			members[n] = true
+		}
+		errs = append(errs, validateInterfaces(vCtx, vrf.Interfaces)...)
+		for j, r := range vrf.Routes {
+			rCtx := fmt.Sprintf("%s route[%d]", vCtx, j)
+			if _, _, err := net.ParseCIDR(r.Destination); err != nil && r.Destination != "default" {
+				errs = append(errs, fmt.Sprintf("%s: destination %q must be a CIDR or \"default\"", rCtx, r.Destination))
+			}
+			if r.Gateway == "" && r.Interface == "" {
+				errs = append(errs, rCtx+": gateway or interface is required")
+			}
+			if r.Gateway != "" && net.ParseIP(r.Gateway) == nil {
+				errs = append(errs, fmt.Sprintf("%s: invalid gateway %q", rCtx, r.Gateway))
+			}
+			if r.Interface != "" && !members[r.Interface] {
+				errs = append(errs, fmt.Sprintf("%s: interface %q is not in the VRF", rCtx, r.Interface))
+			}
+			if r.Metric < 0 {
+				errs = append(errs, fmt.Sprintf("%s: metric %d is negative", rCtx, r.Metric))
+			}
+		}
+	}
+	return errs
+}
+
+func (v *Validator) validateAppControl(ctx string, spec *AppControlPolicySpec) []string {
+	if spec == nil {
+		return []string{ctx + ": spec is required for AppControlPolicy"}
+	}
+	validActions := map[string]bool{"drop": true, "reject": true, "alert": true, "": true}
+
+	var errs []string
+	if !validActions[spec.Action] {
+		errs = append(errs, fmt.Sprintf("%s: invalid action %q (drop|reject|alert)", ctx, spec.Action))
+	}
+	if len(spec.Rules) == 0 {
+		errs = append(errs, ctx+": at least one rule is required")
+	}
+	names := make(map[string]bool)
+	for i, r := range spec.Rules {
+		rCtx := fmt.Sprintf("%s rule[%d] %q", ctx, i, r.Name)
+		if r.Name == "" {
+			errs = append(errs, rCtx+": name is required")
+		} else if names[r.Name] {
+			errs = append(errs, rCtx+": duplicate name")
+		}
+		names[r.Name] = true
+		if !validActions[r.Action] {
+			errs = append(errs, fmt.Sprintf("%s: invalid action %q (drop|reject|alert)", rCtx, r.Action))
+		}
+		if len(r.TLSSNI)+len(r.JA3)+len(r.HTTPHosts)+len(r.DNSDomains) == 0 {
+			errs = append(errs, rCtx+": tlsSNI, ja3, httpHosts or dnsDomains is required")
+		}
+		for _, d := range append(append(append([]string{}, r.TLSSNI...), r.HTTPHosts...), r.DNSDomains...) {
+			if !appDomainRe.MatchString(strings.TrimSuffix(d, ".")) {
+				errs = append(errs, fmt.Sprintf("%s: invalid domain %q", rCtx, d))
+			}
+		}
+		for _, h := range r.JA3 {
+			if !ja3Re.MatchString(h) {
+				errs = append(errs, fmt.Sprintf("%s: invalid ja3 hash %q (32 hex digits)", rCtx, h))
+			}
+		}
+	}
+	return errs
+}
+
+func (v *Validator) validatePolicyTest(ctx string, spec *PolicyTestSpec) []string {
+	if spec == nil || len(spec.Flows) == 0 {
+		return []string{ctx + ": spec.flows must list at least one flow"}
+	}
+	var errs []string
+	for i, f := range spec.Flows {
+		fCtx := fmt.Sprintf("%s flow[%d]", ctx, i)
+		if f.Name != "" {
+			fCtx += fmt.Sprintf(" %q", f.Name)
+		}
+		switch strings.ToLower(f.Expect) {
+		case "accept", "drop", "reject", "tarpit":
+		default:
+			errs = append(errs, fmt.Sprintf("%s: expect must be accept, drop, reject or tarpit, got %q", fCtx, f.Expect))
+		}
+		switch f.Direction {
+		case "", "input", "output", "forward":
+		default:
+			errs = append(errs, fmt.Sprintf("%s: direction must be input, output or forward, got %q", fCtx, f.Direction))
+		}
+		switch strings.ToLower(f.State) {
+		case "", "new", "established", "related", "invalid":
+		default:
+			errs = append(errs, fmt.Sprintf("%s: invalid state %q", fCtx, f.State))
+		}
+		for _, a := range []string{f.Src, f.Dst} {
+			if net.ParseIP(a) == nil {
+				errs = append(errs, fmt.Sprintf("%s: invalid address %q", fCtx, a))
+			}
+		}
+		for _, p := range []int{f.SrcPort, f.DstPort} {
+			if p < 0 || p > 65535 {
+				errs = append(errs, fmt.Sprintf("%s: port %d out of range", fCtx, p))
+			}
+		}
+	}
+	return errs
+}
+
+func (v *Validator) validateAddressGroup(ctx string, spec *AddressGroupSpec) []string {
+	if spec == nil || len(spec.Addresses) == 0 {
+		return []string{ctx + ": spec.addresses must list at least one address"}
+	}
+	var errs []string
+	for _, addr := range spec.Addresses {
+		if err := validateAddr(addr); err != "" {
+			errs = append(errs, fmt.Sprintf("%s: %s", ctx, err))
+		}
+	}
+	return errs
+}
+
+func (v *Validator) validateServiceGroup(ctx string, spec *ServiceGroupSpec) []string {
+	if spec == nil || len(spec.Ports)+len(spec.PortRanges) == 0 {
+		return []string{ctx + ": spec.ports or spec.portRanges must list at least one port"}
+	}
+	var errs []string
+	for _, port := range spec.Ports {
+		if port < 1 || port > 65535 {
+			errs = append(errs, fmt.Sprintf("%s: port %d out of range", ctx, port))
+		}
+	}
+	for _, pr := range spec.PortRanges {
+		if pr.Start < 1 || pr.End > 65535 || pr.Start >= pr.End {
+			errs = append(errs, fmt.Sprintf("%s: invalid portRange %d-%d", ctx, pr.Start, pr.End))
+		}
+	}
+	return errs
+}
+
+func (v *Validator) validateServiceDefinition(ctx string, spec *ServiceDefinitionSpec) []string {
+	if spec == nil {
+		return []string{ctx + ": spec is required for ServiceDefinition"}
+	}
+	var errs []string
+	if spec.Protocol != "tcp" && spec.Protocol != "udp" {
+		errs = append(errs, fmt.Sprintf("%s: protocol must be tcp or udp, got %q", ctx, spec.Protocol))
+	}
+	return append(errs, v.validateServiceGroup(ctx, &ServiceGroupSpec{Ports: spec.Ports, PortRanges: spec.PortRanges})...)
+}
+
+// checkServiceRefs reports the ServiceDefinition names that selectors of
+// manifests use but no manifest defines, and selectors whose services
+// disagree on the protocol with each other or with their rule.
+func checkServiceRefs(manifests []*Manifest) []string {
+	defs := compileServices(manifests)
+	var errs []string
+	check := func(ctx, ns, protocol string, sels ...TrafficSelector) {
+		protocol = normalizeProtocol(protocol)
+		for _, s := range sels {
+			for _, name := range s.Services {
+				def, ok := defs[groupKey(ns, name)]
+				switch {
+				case !ok:
+					errs = append(errs, fmt.Sprintf("%s: unknown service %q", ctx, name))
+				case protocol == "":
+					protocol = def.Protocol
+				case def.Protocol != protocol:
+					errs = append(errs, fmt.Sprintf("%s: service %q is %s, not %s", ctx, name, def.Protocol, protocol))
+				}
+			}
+		}
+	}
+
+	for _, m := range manifests {
+		ctx := fmt.Sprintf("[%s/%s]", m.Metadata.Namespace, m.Metadata.Name)
+		ns := m.Metadata.Namespace
+		switch {
+		case m.Kind == KindFirewallPolicy && m.FirewallSpec != nil:
+			for i, r := range m.FirewallSpec.Rules {
+				check(fmt.Sprintf("%s rule[%d] %q", ctx, i, r.Name), ns, r.Protocol, r.Source, r.Dest)
+			}
+			for _, c := range m.FirewallSpec.Chains {
+				for i, r := range c.Rules {
+					check(fmt.Sprintf("%s chain %q rule[%d] %q", ctx, c.Name, i, r.Name), ns, r.Protocol, r.Source, r.Dest)
+				}
+			}
+		case m.Kind == KindWANPolicy && m.WANSpec != nil:
+			for _, p := range m.WANSpec.Paths {
+				check(fmt.Sprintf("%s path %q", ctx, p.Name), ns, p.Protocol, p.Destination)
+			}
+		}
+	}
+	return errs
+}
+
+// validateAddr checks an address or CIDR of either family and returns a
+// message, or "" if it is valid. An IPv4-mapped IPv6 address is refused:
+// nft would match it against IPv6 traffic only, which is rarely meant.
+func validateAddr(addr string) string {
+	ip := net.ParseIP(addr)
+	if ip == nil {
+		var err error
+		if ip, _, err = net.ParseCIDR(addr); err != nil {
+			return fmt.Sprintf("invalid address %q", addr)
+		}
+	}
+	if strings.Contains(addr, ":") && ip.To4() != nil {
+		return fmt.Sprintf("address %q is IPv4-mapped; write it as IPv4", addr)
+	}
+	return ""
+}
+
+// validateGroupRefs checks that a selector uses a group or inline values,
+// not both, since the backend matches one set per field.
+func validateGroupRefs(ctx, protocol string, s TrafficSelector) []string {
+	var errs []string
+	selections := 0
+	for _, given := range []bool{len(s.Addresses) > 0, s.AddressGroup != "", len(s.Countries) > 0, len(s.FQDNs) > 0} {
+		if given {
+			selections++
+		}
+	}
+	if selections > 1 {
+		errs = append(errs, ctx+": addresses, addressGroup, countries and fqdns are mutually exclusive")
+	}
+	for _, c := range s.Countries {
+		if !validCountry(c) {
+			errs = append(errs, fmt.Sprintf("%s: country %q is not an ISO 3166-1 alpha-2 code", ctx, c))
+		}
+	}
+	for _, name := range s.FQDNs {
+		if !validFQDN(name) {
+			errs = append(errs, fmt.Sprintf("%s: %q is not a fully qualified domain name", ctx, name))
+		}
+	}
+	if s.ServiceGroup != "" {
+		if len(s.Ports)+len(s.PortRanges)+len(s.Services) > 0 {
+			errs = append(errs, ctx+": serviceGroup and ports are mutually exclusive")
+		}
+		if protocol != "tcp" && protocol != "udp" {
+			errs = append(errs, ctx+": serviceGroup requires protocol tcp or udp")
+		}
+	}
+	return errs
+}
+
+func validateCondition(ctx string, c *RuleCondition) []string {
+	if c == nil {
+		return nil
+	}
+	var errs []string
+	if c.Target == "" {
+		errs = append(errs, ctx+": when.target is required")
+	}
+	if c.State != "up" && c.State != "down" {
+		errs = append(errs, fmt.Sprintf("%s: when.state must be up or down, got %q", ctx, c.State))
+	}
+	return errs
+}
+
+// lbNameRe accepts load balancer names that are safe as HAProxy section
+// names and as file names.
+var lbNameRe = regexp.MustCompile(`^[A-Za-z0-9_][A-Za-z0-9_.-]{0,63}$`)
+
+// ValidLBName reports whether name is acceptable as a load balancer name.
+func ValidLBName(name string) bool { return lbNameRe.MatchString(name) }
+
+// maxErrorPageSize keeps an error page, with its headers, inside HAProxy's
+// default 16 KiB buffer, which errorfile responses must fit.
+const maxErrorPageSize = 12 << 10
+
+// ifaceRe accepts Linux interface names, at most 15 bytes, optionally
+// followed by a * wildcard.
+var ifaceRe = regexp.MustCompile(`^[A-Za-z0-9_.:@-]{1,15}\*?$`)
+
+func validateInterfaces(ctx string, names []string) []string {
+	var errs []string
+	for _, n := range names {
+		if !ifaceRe.MatchString(n) {
+			errs = append(errs, fmt.Sprintf("%s: invalid interface name %q", ctx, n))
+		}
+	}
+	return errs
+}
+
+// RejectTCPReset answers a rejected TCP connection with a reset.
+const RejectTCPReset = "tcp-reset"
+
+// RejectICMPTypes are the ICMP errors a REJECT rule can send, as both
+// "icmp-<type>" and "icmpx-<type>" values of rejectWith.
+var RejectICMPTypes = []string{"port-unreachable", "host-unreachable", "no-route", "admin-prohibited"}
+
+func validRejectWith(s string) bool {
+	t, ok := strings.CutPrefix(s, "icmpx-")
+	if !ok {
+		t, ok = strings.CutPrefix(s, "icmp-")
+	}
+	for _, v := range RejectICMPTypes {
+		if ok && t == v {
+			return true
+		}
+	}
+	return false
+}
+
+// DSCPClasses are the DSCP class names a DSCP rule can set, besides
+// numbers from 0 to 63.
+var DSCPClasses = []string{
+	"cs0", "cs1", "cs2", "cs3", "cs4", "cs5", "cs6", "cs7",
+	"af11", "af12", "af13", "af21", "af22", "af23",
+	"af31", "af32", "af33", "af41", "af42", "af43", "ef",
+}
+
+func validDSCP(s string) bool {
+	if n, err := strconv.Atoi(s); err == nil {
+		return n >= 0 && n <= 63
+	}
+	return contains(DSCPClasses, strings.ToLower(s))
+}
+
+// validateMACs checks that every entry is a 48-bit MAC address, in any of
+// the notations net.ParseMAC reads.
+func validateMACs(ctx string, macs []string) []string {
+	var errs []string
+	for _, m := range macs {
+		if hw, err := net.ParseMAC(m); err != nil || len(hw) != 6 {
+			errs = append(errs, fmt.Sprintf("%s: invalid MAC address %q", ctx, m))
+		}
+	}
+	return errs
+}
+
+// netnsRe accepts the names `ip netns` and container runtimes give network
+// namespaces.
+var netnsRe = regexp.MustCompile(`^[A-Za-z0-9_][A-Za-z0-9_.-]{0,63}$`)
+
+func validateNetNS(ctx, name string) []string {
+	if name != "" && !netnsRe.MatchString(name) {
+		return []string{fmt.Sprintf("%s: invalid targetNamespace %q", ctx, name)}
+	}
+	return nil
+}
+
+// vrfNameRe accepts names usable as a VRF device: interface names without
+// wildcards.
+var vrfNameRe = regexp.MustCompile(`^[A-Za-z0-9_.-]{1,15}$`)
+
+// validateVRFRef checks the vrf a firewall or NAT policy names. VRFs live
+// on the host, so it cannot be combined with targetNamespace.
+func validateVRFRef(ctx, vrf, netns string) []string {
+	switch {
+	case vrf == "":
+		return nil
+	case !vrfNameRe.MatchString(vrf):
+		return []string{fmt.Sprintf("%s: invalid vrf %q", ctx, vrf)}
+	case netns != "":
+		return []string{ctx + ": vrf cannot be used with targetNamespace"}
+	}
+	return nil
+}
+
+var (
+	weekdays = map[string]bool{"monday": true, "tuesday": true, "wednesday": true, "thursday": true, "friday": true, "saturday": true, "sunday": true}
+	clockRe  = regexp.MustCompile(`^([01][0-9]|2[0-3]):[0-5][0-9]$`)
+)
+
+func validateSchedule(ctx string, s *RuleSchedule) []string {
+	if s == nil {
+		return nil
+	}
+	var errs []string
+	for _, d := range s.Days {
+		if !weekdays[strings.ToLower(d)] {
+			errs = append(errs, fmt.Sprintf("%s: schedule day %q is not a weekday name", ctx, d))
+		}
+	}
+	if (s.Start == "") != (s.End == "") {
+		errs = append(errs, ctx+": schedule start and end must be set together")
+	}
+	for _, t := range []string{s.Start, s.End} {
+		if t != "" && !clockRe.MatchString(t) {
+			errs = append(errs, fmt.Sprintf("%s: schedule time %q must be HH:MM", ctx, t))
+		}
+	}
+	if s.Start != "" && s.Start == s.End {
+		errs = append(errs, ctx+": schedule start and end must differ")
+	}
+	if len(s.Days) == 0 && s.Start == "" {
+		errs = append(errs, ctx+": schedule needs days or a start/end window")
 	}
 	return errs
 }
@@ -0,0 +1,53 @@
+package redact
+
+import (
+	"go.uber.org/zap/zapcore"
+)
+
+// Core wraps a zap core so that messages, string fields and errors are
+// redacted before they are encoded. Use it with zap.WrapCore.
+func Core(c zapcore.Core) zapcore.Core { return &core{Core: c} }
+
+type core struct {
+	zapcore.Core
+}
+
+func (c *core) With(fields []zapcore.Field) zapcore.Core {
+	return &core{Core: c.Core.With(Fields(fields))}
+}
+
+func (c *core) Check(e zapcore.Entry, ce *zapcore.CheckedEntry) *zapcore.CheckedEntry {
+	if c.Enabled(e.Level) {
+		return ce.AddCore(e, c)
+	}
+	return ce
+}
+
+func (c *core) Write(e zapcore.Entry, fields []zapcore.Field) error {
+	e.Message = String(e.Message)
+	return c.Core.Write(e, Fields(fields))
+}
+
+// Fields returns a copy of fields with secrets redacted: fields named like
+// a secret are replaced outright, and strings and errors are scrubbed.
+func Fields(fields []zapcore.Field) []zapcore.Field {
+	out := make([]zapcore.Field, len(fields))
+	for i, f := range fields {
+		switch {
+		case Key(f.Key):
+			out[i] = zapcore.Field{Key: f.Key, Type: zapcore.StringType, String: Placeholder}
+		case f.Type == zapcore.StringType:
+			f.String = String(f.String)
+			out[i] = f
+		case f.Type == zapcore.ErrorType:
+			if err, ok := f.Interface.(error); ok && err != nil {
+				out[i] = zapcore.Field{Key: f.Key, Type: zapcore.StringType, String: String(err.Error())}
+				continue
+			}
+			out[i] = f
+		default:
+			out[i] = f
+		}
+	}
+	return out
+}
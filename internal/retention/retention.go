@@ -0,0 +1,155 @@
+// Package retention prunes aged rows from the append-only tables — IDS
+// alerts, firewall events, flow statistics, monitor state changes and the
+// audit log — optionally archiving them as gzipped ND-JSON before they are
+// deleted.
+package retention
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"go.uber.org/zap"
+
+	"github.com/aegisx/aegisx/internal/blob"
+	"github.com/aegisx/aegisx/internal/metrics"
+	"github.com/aegisx/aegisx/internal/store"
+)
+
+// Policy is the retention of one table.
+type Policy struct {
+	Table   string
+	MaxAge  time.Duration // rows older than this are pruned; 0 keeps them forever
+	Archive bool          // write pruned rows to the archiver first
+}
+
+// Config tunes the pruning job.
+type Config struct {
+	Interval  time.Duration
+	BatchSize int // rows deleted per transaction, and per archive object
+	Policies  []Policy
+}
+
+// Result is the outcome of pruning one table.
+type Result struct {
+	Table    string    `json:"table"`
+	Cutoff   time.Time `json:"cutoff"`
+	Deleted  int64     `json:"deleted"`
+	Archived int       `json:"archived"` // archive objects written
+	Error    string    `json:"error,omitempty"`
+}
+
+// Manager runs the retention policies.
+type Manager struct {
+	store   *store.RetentionStore
+	archive blob.Store // nil when archival is off
+	cfg     Config
+	log     *zap.Logger
+}
+
+// NewManager returns a Manager that writes archives to archive. Policies
+// that archive need one.
+func NewManager(s *store.RetentionStore, archive blob.Store, cfg Config, log *zap.Logger) (*Manager, error) {
+	if cfg.BatchSize <= 0 {
+		cfg.BatchSize = 10000
+	}
+	for _, p := range cfg.Policies {
+		if p.Archive && archive == nil {
+			return nil, fmt.Errorf("retention of %s archives but no storage is configured", p.Table)
+		}
+	}
+	return &Manager{store: s, archive: archive, cfg: cfg, log: log}, nil
+}
+
+// Run applies the policies every Interval, starting immediately.
+// Call this in a goroutine; it blocks until ctx is cancelled.
+func (m *Manager) Run(ctx context.Context) {
+	ticker := time.NewTicker(m.cfg.Interval)
+	defer ticker.Stop()
+
+	for {
+		for _, r := range m.RunOnce(ctx) {
+			if r.Error != "" {
+				m.log.Error("retention failed", zap.String("table", r.Table), zap.String("error", r.Error))
+			} else if r.Deleted > 0 {
+				m.log.Info("retention pruned rows", zap.String("table", r.Table),
+					zap.Int64("rows", r.Deleted), zap.Int("archives", r.Archived))
+			}
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// RunOnce applies every policy once. A failing table does not stop the
+// others; its rows from the failed batch on are kept for the next run.
+func (m *Manager) RunOnce(ctx context.Context) []Result {
+	now := time.Now()
+	var out []Result
+	for _, p := range m.cfg.Policies {
+		if p.MaxAge <= 0 {
+			continue
+		}
+		res := Result{Table: p.Table, Cutoff: now.Add(-p.MaxAge)}
+		if err := m.prune(ctx, p, &res); err != nil {
+			res.Error = err.Error()
+		}
+		metrics.RetentionRowsDeleted.WithLabelValues(p.Table).Add(float64(res.Deleted))
+		out = append(out, res)
+	}
+	return out
+}
+
+// ─── Private helpers ──────────────────────────────────────────────────────
+
+func (m *Manager) prune(ctx context.Context, p Policy, res *Result) error {
+	var archive func([]json.RawMessage) error
+	if p.Archive {
+		archive = func(rows []json.RawMessage) error {
+			if err := m.writeArchive(ctx, p.Table, res.Archived+1, rows); err != nil {
+				return err
+			}
+			res.Archived++
+			return nil
+		}
+	}
+	for {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		n, err := m.store.PruneBatch(ctx, p.Table, res.Cutoff, m.cfg.BatchSize, archive)
+		if err != nil {
+			return err
+		}
+		res.Deleted += int64(n)
+		if n < m.cfg.BatchSize {
+			return nil
+		}
+	}
+}
+
+// writeArchive writes the seq-th batch of a run as a gzipped ND-JSON object keyed
+// by table, day and time, e.g.
+// ids_alerts/2024/05/01/ids_alerts-20240501T030000Z-0001.ndjson.gz.
+func (m *Manager) writeArchive(ctx context.Context, table string, seq int, rows []json.RawMessage) error {
+	var buf bytes.Buffer
+	zw := gzip.NewWriter(&buf)
+	for _, r := range rows {
+		zw.Write(r)
+		zw.Write([]byte{'\n'})
+	}
+	if err := zw.Close(); err != nil {
+		return err
+	}
+	at := time.Now().UTC()
+	key := fmt.Sprintf("%s/%s/%s-%s-%04d.ndjson.gz", table, at.Format("2006/01/02"), table,
+		at.Format("20060102T150405Z"), seq)
+	return m.archive.Put(ctx, key, &buf, int64(buf.Len()))
+}
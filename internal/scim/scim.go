@@ -0,0 +1,234 @@
+// Package scim holds the SCIM 2.0 (RFC 7643/7644) resource shapes the
+// provisioning API speaks, and the mapping from IdP groups to AegisX
+// tenants and roles.
+package scim
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Schema URNs.
+const (
+	SchemaUser         = "urn:ietf:params:scim:schemas:core:2.0:User"
+	SchemaGroup        = "urn:ietf:params:scim:schemas:core:2.0:Group"
+	SchemaListResponse = "urn:ietf:params:scim:api:messages:2.0:ListResponse"
+	SchemaPatchOp      = "urn:ietf:params:scim:api:messages:2.0:PatchOp"
+	SchemaError        = "urn:ietf:params:scim:api:messages:2.0:Error"
+	SchemaSPConfig     = "urn:ietf:params:scim:schemas:core:2.0:ServiceProviderConfig"
+)
+
+// ContentType is the media type of SCIM requests and responses.
+const ContentType = "application/scim+json"
+
+// Meta is the common resource metadata.
+type Meta struct {
+	ResourceType string    `json:"resourceType"`
+	Created      time.Time `json:"created"`
+	LastModified time.Time `json:"lastModified"`
+	Location     string    `json:"location,omitempty"`
+}
+
+// Ref points at another resource, e.g. a group member.
+type Ref struct {
+	Value   string `json:"value"`
+	Display string `json:"display,omitempty"`
+	Ref     string `json:"$ref,omitempty"`
+}
+
+// Email is a multi-valued email attribute.
+type Email struct {
+	Value   string `json:"value"`
+	Type    string `json:"type,omitempty"`
+	Primary bool   `json:"primary,omitempty"`
+}
+
+// Name is the structured name; only Formatted is stored.
+type Name struct {
+	Formatted  string `json:"formatted,omitempty"`
+	GivenName  string `json:"givenName,omitempty"`
+	FamilyName string `json:"familyName,omitempty"`
+}
+
+// User is the SCIM User resource.
+type User struct {
+	Schemas     []string `json:"schemas"`
+	ID          string   `json:"id,omitempty"`
+	ExternalID  string   `json:"externalId,omitempty"`
+	UserName    string   `json:"userName"`
+	Name        *Name    `json:"name,omitempty"`
+	DisplayName string   `json:"displayName,omitempty"`
+	Emails      []Email  `json:"emails,omitempty"`
+	Active      *bool    `json:"active,omitempty"`
+	Groups      []Ref    `json:"groups,omitempty"`
+	Meta        *Meta    `json:"meta,omitempty"`
+}
+
+// PrimaryEmail returns the primary email, else the first one.
+func (u *User) PrimaryEmail() string {
+	for _, e := range u.Emails {
+		if e.Primary {
+			return e.Value
+		}
+	}
+	if len(u.Emails) > 0 {
+		return u.Emails[0].Value
+	}
+	return ""
+}
+
+// Display returns the best human-readable name.
+func (u *User) Display() string {
+	switch {
+	case u.DisplayName != "":
+		return u.DisplayName
+	case u.Name != nil && u.Name.Formatted != "":
+		return u.Name.Formatted
+	case u.Name != nil:
+		return strings.TrimSpace(u.Name.GivenName + " " + u.Name.FamilyName)
+	}
+	return ""
+}
+
+// Group is the SCIM Group resource.
+type Group struct {
+	Schemas     []string `json:"schemas"`
+	ID          string   `json:"id,omitempty"`
+	ExternalID  string   `json:"externalId,omitempty"`
+	DisplayName string   `json:"displayName"`
+	Members     []Ref    `json:"members,omitempty"`
+	Meta        *Meta    `json:"meta,omitempty"`
+}
+
+// ListResponse wraps query results.
+type ListResponse struct {
+	Schemas      []string `json:"schemas"`
+	TotalResults int      `json:"totalResults"`
+	StartIndex   int      `json:"startIndex"`
+	ItemsPerPage int      `json:"itemsPerPage"`
+	Resources    []any    `json:"Resources"`
+}
+
+// PatchRequest is a PATCH body.
+type PatchRequest struct {
+	Schemas    []string         `json:"schemas"`
+	Operations []PatchOperation `json:"Operations"`
+}
+
+// PatchOperation is one PATCH step. Op is case-insensitive; Azure AD sends
+// "Replace" and string-typed booleans.
+type PatchOperation struct {
+	Op    string `json:"op"`
+	Path  string `json:"path,omitempty"`
+	Value any    `json:"value,omitempty"`
+}
+
+// Error is the SCIM error body.
+type Error struct {
+	Schemas  []string `json:"schemas"`
+	Status   string   `json:"status"`
+	ScimType string   `json:"scimType,omitempty"`
+	Detail   string   `json:"detail"`
+}
+
+// NewError builds an error body for an HTTP status.
+func NewError(status int, scimType, detail string) Error {
+	return Error{Schemas: []string{SchemaError}, Status: strconv.Itoa(status), ScimType: scimType, Detail: detail}
+}
+
+// Filter is the one filter form IdPs use for lookups: `attr eq "value"`.
+type Filter struct {
+	Attr  string // lower-cased attribute name
+	Value string
+}
+
+// ParseFilter parses an equality filter; an empty string yields nil.
+func ParseFilter(s string) (*Filter, error) {
+	s = strings.TrimSpace(s)
+	if s == "" {
+		return nil, nil
+	}
+	parts := strings.SplitN(s, " ", 3)
+	if len(parts) != 3 || !strings.EqualFold(parts[1], "eq") {
+		return nil, fmt.Errorf("only `attribute eq \"value\"` filters are supported")
+	}
+	val, err := strconv.Unquote(strings.TrimSpace(parts[2]))
+	if err != nil {
+		return nil, fmt.Errorf("filter value must be a quoted string")
+	}
+	return &Filter{Attr: strings.ToLower(parts[0]), Value: val}, nil
+}
+
+// Bool reads a PATCH value that should be a boolean, accepting the string
+// forms some IdPs send.
+func Bool(v any) (bool, error) {
+	switch b := v.(type) {
+	case bool:
+		return b, nil
+	case string:
+		return strconv.ParseBool(b)
+	}
+	return false, fmt.Errorf("expected a boolean, got %T", v)
+}
+
+// ─── Group mapping ────────────────────────────────────────────────────────
+
+// roleRank orders roles so the most privileged mapping wins.
+var roleRank = map[string]int{"viewer": 1, "operator": 2, "admin": 3}
+
+// Mapping grants members of an IdP group a role in a tenant.
+type Mapping struct {
+	Group    string
+	TenantID uuid.UUID
+	Role     string
+}
+
+// Mapper resolves a user's groups to a tenant and role.
+type Mapper struct {
+	mappings      []Mapping
+	defaultTenant uuid.UUID
+	defaultRole   string
+}
+
+// NewMapper validates the mappings. Users in no mapped group land in
+// defaultTenant with defaultRole; an empty defaultRole gives them no access.
+func NewMapper(mappings []Mapping, defaultTenant uuid.UUID, defaultRole string) (*Mapper, error) {
+	for _, m := range mappings {
+		if m.Group == "" {
+			return nil, fmt.Errorf("scim mapping: group is required")
+		}
+		if roleRank[m.Role] == 0 {
+			return nil, fmt.Errorf("scim mapping %q: role must be one of admin|operator|viewer", m.Group)
+		}
+		if m.TenantID == uuid.Nil {
+			return nil, fmt.Errorf("scim mapping %q: tenant is required", m.Group)
+		}
+	}
+	if defaultRole != "" && roleRank[defaultRole] == 0 {
+		return nil, fmt.Errorf("scim: default role must be one of admin|operator|viewer")
+	}
+	return &Mapper{mappings: mappings, defaultTenant: defaultTenant, defaultRole: defaultRole}, nil
+}
+
+// Resolve returns the tenant and role for a member of groups: the most
+// privileged matching mapping, the first in config order on ties. Group
+// names compare case-insensitively.
+func (m *Mapper) Resolve(groups []string) (uuid.UUID, string) {
+	var best *Mapping
+	for i := range m.mappings {
+		mp := &m.mappings[i]
+		for _, g := range groups {
+			if strings.EqualFold(g, mp.Group) && (best == nil || roleRank[mp.Role] > roleRank[best.Role]) {
+				best = mp
+			}
+		}
+	}
+	if best == nil {
+		return m.defaultTenant, m.defaultRole
+	}
+	return best.TenantID, best.Role
+}
@@ -0,0 +1,193 @@
+// Package setup implements first-run bootstrap: while no admin credential
+// is configured the API serves only the setup endpoint, which creates the
+// admin and default tenant, generates the JWT secret and writes them to the
+// config file.
+package setup
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+
+	"github.com/google/uuid"
+	"golang.org/x/crypto/bcrypt"
+	"gopkg.in/yaml.v3"
+
+	"github.com/aegisx/aegisx/internal/config"
+	"github.com/aegisx/aegisx/internal/store"
+)
+
+// DefaultConfigPath is written when the API started without a config file.
+const DefaultConfigPath = "/etc/aegisx/aegisx.yaml"
+
+// MinPasswordLength is the shortest admin password the wizard accepts.
+const MinPasswordLength = 12
+
+// DefaultTenantID is the tenant the bootstrap admin belongs to.
+var DefaultTenantID = uuid.MustParse("00000000-0000-0000-0000-000000000001")
+
+// Needed reports whether cfg has no admin credential and the API must
+// start in setup mode.
+func Needed(cfg *config.Config) bool {
+	return cfg.Auth.AdminPassword == "" && cfg.Auth.AdminPasswordHash == ""
+}
+
+// Request is the body of POST /api/v1/setup.
+type Request struct {
+	AdminUser     string `json:"adminUser"` // default "admin"
+	AdminPassword string `json:"adminPassword" binding:"required"`
+	TenantName    string `json:"tenantName"` // default "default"
+}
+
+// Wizard performs the one-time setup. It is safe for concurrent use; only
+// the first successful Complete takes effect.
+type Wizard struct {
+	cfg     *config.Config
+	tenants *store.TenantStore
+	token   string
+
+	mu   sync.Mutex
+	done chan struct{}
+}
+
+// NewWizard creates a wizard with a fresh one-time token.
+func NewWizard(cfg *config.Config, tenants *store.TenantStore) (*Wizard, error) {
+	buf := make([]byte, 16)
+	if _, err := rand.Read(buf); err != nil {
+		return nil, err
+	}
+	return &Wizard{cfg: cfg, tenants: tenants, token: hex.EncodeToString(buf), done: make(chan struct{})}, nil
+}
+
+// Token is the one-time setup token, printed to stdout; every setup
+// request must present it.
+func (w *Wizard) Token() string { return w.token }
+
+// CheckToken reports whether t is the setup token.
+func (w *Wizard) CheckToken(t string) bool {
+	return t != "" && subtle.ConstantTimeCompare([]byte(t), []byte(w.token)) == 1
+}
+
+// Done is closed once setup has completed.
+func (w *Wizard) Done() <-chan struct{} { return w.done }
+
+// Completed reports whether setup has already run.
+func (w *Wizard) Completed() bool {
+	select {
+	case <-w.done:
+		return true
+	default:
+		return false
+	}
+}
+
+// Path is the config file the wizard writes.
+func (w *Wizard) Path() string {
+	if w.cfg.File != "" {
+		return w.cfg.File
+	}
+	return DefaultConfigPath
+}
+
+// Complete creates the default tenant and writes the admin credential and
+// JWT secret to the config file. A configured JWT secret is kept.
+func (w *Wizard) Complete(ctx context.Context, req Request) error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.Completed() {
+		return fmt.Errorf("setup already completed")
+	}
+
+	if req.AdminUser == "" {
+		req.AdminUser = "admin"
+	}
+	if req.TenantName == "" {
+		req.TenantName = "default"
+	}
+	if len(req.AdminPassword) < MinPasswordLength {
+		return fmt.Errorf("adminPassword must be at least %d characters", MinPasswordLength)
+	}
+
+	hash, err := bcrypt.GenerateFromPassword([]byte(req.AdminPassword), bcrypt.DefaultCost)
+	if err != nil {
+		return fmt.Errorf("hash admin password: %w", err)
+	}
+	secret := w.cfg.Auth.JWTSecret
+	if secret == "" {
+		buf := make([]byte, 48)
+		if _, err := rand.Read(buf); err != nil {
+			return err
+		}
+		secret = base64.StdEncoding.EncodeToString(buf)
+	}
+
+	if err := w.tenants.Ensure(ctx, DefaultTenantID, req.TenantName); err != nil {
+		return err
+	}
+	if err := writeConfig(w.Path(), map[string]any{
+		"admin_user":          req.AdminUser,
+		"admin_password_hash": string(hash),
+		"jwt_secret":          secret,
+	}); err != nil {
+		return err
+	}
+	close(w.done)
+	return nil
+}
+
+// writeConfig merges auth into the YAML config at path, drops any
+// plaintext admin_password, and replaces the file atomically with mode 0600.
+func writeConfig(path string, auth map[string]any) error {
+	doc := map[string]any{}
+	if data, err := os.ReadFile(path); err == nil {
+		if err := yaml.Unmarshal(data, &doc); err != nil {
+			return fmt.Errorf("parse %s: %w", path, err)
+		}
+	} else if !os.IsNotExist(err) {
+		return fmt.Errorf("read %s: %w", path, err)
+	}
+
+	section, _ := doc["auth"].(map[string]any)
+	if section == nil {
+		section = map[string]any{}
+	}
+	delete(section, "admin_password")
+	for k, v := range auth {
+		section[k] = v
+	}
+	doc["auth"] = section
+
+	out, err := yaml.Marshal(doc)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0o750); err != nil {
+		return fmt.Errorf("create config dir: %w", err)
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(path), ".aegisx-setup-*.yaml")
+	if err != nil {
+		return fmt.Errorf("write config: %w", err)
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(out); err != nil {
+		tmp.Close()
+		return fmt.Errorf("write config: %w", err)
+	}
+	if err := tmp.Chmod(0o600); err != nil {
+		tmp.Close()
+		return fmt.Errorf("write config: %w", err)
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("write config: %w", err)
+	}
+	if err := os.Rename(tmp.Name(), path); err != nil {
+		return fmt.Errorf("write config: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,121 @@
+// Package signing verifies the detached signatures policy manifests are
+// submitted with. A signature is what
+//
+//	cosign sign-blob --key cosign.key policy.yaml
+//
+// prints: the base64 signature of the manifest's SHA-256 digest, made with
+// an ECDSA, Ed25519 or RSA key whose public half is trusted in the config.
+package signing
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+)
+
+var (
+	// ErrUnsigned is a manifest without a signature while signatures are
+	// enforced.
+	ErrUnsigned = errors.New("manifest is not signed")
+	// ErrInvalid is a signature no trusted key made over the manifest.
+	ErrInvalid = errors.New("signature does not match the manifest with any trusted key")
+)
+
+// Verifier checks signatures against a set of trusted public keys.
+type Verifier struct {
+	keys    []key
+	enforce bool
+}
+
+type key struct {
+	id  string // hex SHA-256 of the PKIX public key
+	pub crypto.PublicKey
+}
+
+// New loads the PEM public keys in files. When enforce is set, manifests
+// without a signature are refused; signed ones are verified either way.
+func New(files []string, enforce bool) (*Verifier, error) {
+	v := &Verifier{enforce: enforce}
+	for _, f := range files {
+		data, err := os.ReadFile(f)
+		if err != nil {
+			return nil, fmt.Errorf("read key: %w", err)
+		}
+		for rest := data; ; {
+			var block *pem.Block
+			block, rest = pem.Decode(rest)
+			if block == nil {
+				break
+			}
+			if block.Type != "PUBLIC KEY" {
+				continue
+			}
+			pub, err := x509.ParsePKIXPublicKey(block.Bytes)
+			if err != nil {
+				return nil, fmt.Errorf("%s: %w", f, err)
+			}
+			sum := sha256.Sum256(block.Bytes)
+			v.keys = append(v.keys, key{id: hex.EncodeToString(sum[:]), pub: pub})
+		}
+	}
+	if len(v.keys) == 0 {
+		return nil, errors.New("no public keys found")
+	}
+	return v, nil
+}
+
+// Enforced reports whether unsigned manifests are refused. A nil Verifier
+// enforces nothing.
+func (v *Verifier) Enforced() bool {
+	return v != nil && v.enforce
+}
+
+// Verify checks signature over manifest and returns the ID of the key that
+// made it. An empty signature yields an empty ID, or ErrUnsigned when
+// signatures are enforced. A nil Verifier checks nothing.
+func (v *Verifier) Verify(manifest, signature string) (string, error) {
+	if v == nil {
+		return "", nil
+	}
+	if signature == "" {
+		if v.enforce {
+			return "", ErrUnsigned
+		}
+		return "", nil
+	}
+	sig, err := base64.StdEncoding.DecodeString(strings.TrimSpace(signature))
+	if err != nil {
+		return "", fmt.Errorf("%w: not base64", ErrInvalid)
+	}
+	digest := sha256.Sum256([]byte(manifest))
+	for _, k := range v.keys {
+		if verify(k.pub, []byte(manifest), digest[:], sig) {
+			return k.id, nil
+		}
+	}
+	return "", ErrInvalid
+}
+
+// ─── Private helpers ──────────────────────────────────────────────────────
+
+func verify(pub crypto.PublicKey, msg, digest, sig []byte) bool {
+	switch pub := pub.(type) {
+	case *ecdsa.PublicKey:
+		return ecdsa.VerifyASN1(pub, digest, sig)
+	case ed25519.PublicKey:
+		return ed25519.Verify(pub, msg, sig)
+	case *rsa.PublicKey:
+		return rsa.VerifyPKCS1v15(pub, crypto.SHA256, digest, sig) == nil
+	}
+	return false
+}
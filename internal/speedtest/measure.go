@@ -0,0 +1,212 @@
+package speedtest
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"net"
+	"net/http"
+	"os/exec"
+	"strconv"
+	"time"
+
+	"github.com/aegisx/aegisx/internal/health"
+)
+
+// Latency sampling. A handshake slower than sampleTimeout counts as lost.
+const (
+	sampleTimeout = 2 * time.Second
+	sampleGap     = 200 * time.Millisecond
+)
+
+// latency is the outcome of timing a series of TCP handshakes. latencyMs
+// and jitterMs are nil when no handshake completed.
+type latency struct {
+	latencyMs *float64
+	jitterMs  *float64
+	lossPct   float64
+}
+
+// measureLatency times n TCP handshakes with target over iface. Latency is
+// their mean, jitter the mean difference between consecutive ones.
+func measureLatency(ctx context.Context, target, iface string, n int) (*latency, error) {
+	if n <= 0 {
+		n = 10
+	}
+	d := net.Dialer{Timeout: sampleTimeout}
+	if iface != "" {
+		d.Control = health.BindToDevice(iface)
+	}
+
+	var rtts []float64
+	var lastErr error
+	for i := 0; i < n; i++ {
+		if i > 0 {
+			select {
+			case <-ctx.Done():
+				return nil, ctx.Err()
+			case <-time.After(sampleGap):
+			}
+		}
+		start := time.Now()
+		conn, err := d.DialContext(ctx, "tcp", target)
+		if err != nil {
+			lastErr = err
+			continue
+		}
+		rtts = append(rtts, float64(time.Since(start))/float64(time.Millisecond))
+		conn.Close()
+	}
+
+	lat := &latency{lossPct: 100 * float64(n-len(rtts)) / float64(n)}
+	if len(rtts) == 0 {
+		return lat, lastErr
+	}
+	var sum, diffs float64
+	for i, rtt := range rtts {
+		sum += rtt
+		if i > 0 {
+			diffs += math.Abs(rtt - rtts[i-1])
+		}
+	}
+	mean := sum / float64(len(rtts))
+	jitter := 0.0
+	if len(rtts) > 1 {
+		jitter = diffs / float64(len(rtts)-1)
+	}
+	lat.latencyMs, lat.jitterMs = &mean, &jitter
+	return lat, nil
+}
+
+// httpDownload reads url over iface for up to d and returns the rate in
+// Mbit/s. A body that ends sooner is measured as far as it went.
+func httpDownload(ctx context.Context, url, iface string, d time.Duration) (float64, error) {
+	ctx, cancel := context.WithTimeout(ctx, d)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return 0, err
+	}
+	start := time.Now()
+	resp, err := client(iface).Do(req)
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode >= 400 {
+		return 0, fmt.Errorf("status %d", resp.StatusCode)
+	}
+	n, err := io.Copy(io.Discard, resp.Body)
+	if err != nil && !errors.Is(ctx.Err(), context.DeadlineExceeded) {
+		return 0, err
+	}
+	return mbps(n, time.Since(start))
+}
+
+// httpUpload POSTs zeros to url over iface for up to d and returns the rate
+// in Mbit/s.
+func httpUpload(ctx context.Context, url, iface string, d time.Duration) (float64, error) {
+	ctx, cancel := context.WithTimeout(ctx, d)
+	defer cancel()
+	body := &countingReader{}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, body)
+	if err != nil {
+		return 0, err
+	}
+	req.Header.Set("Content-Type", "application/octet-stream")
+	start := time.Now()
+	resp, err := client(iface).Do(req)
+	if err == nil {
+		resp.Body.Close()
+		if resp.StatusCode >= 400 {
+			return 0, fmt.Errorf("status %d", resp.StatusCode)
+		}
+	} else if !errors.Is(ctx.Err(), context.DeadlineExceeded) {
+		return 0, err
+	}
+	return mbps(body.n, time.Since(start))
+}
+
+// iperf3 runs a client against server over iface for d and returns the rate
+// in Mbit/s the receiver saw. reverse has the server send, measuring the
+// download direction.
+func iperf3(ctx context.Context, path, server, iface string, d time.Duration, reverse bool) (float64, error) {
+	if server == "" {
+		return 0, errors.New("no iperf3 server configured")
+	}
+	host, port := server, ""
+	if h, p, err := net.SplitHostPort(server); err == nil {
+		host, port = h, p
+	}
+	secs := int(d / time.Second)
+	if secs < 1 {
+		secs = 1
+	}
+	args := []string{"-c", host, "-J", "-t", strconv.Itoa(secs)}
+	if port != "" {
+		args = append(args, "-p", port)
+	}
+	if reverse {
+		args = append(args, "-R")
+	}
+	if iface != "" {
+		args = append(args, "--bind-dev", iface)
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, d+15*time.Second)
+	defer cancel()
+	out, runErr := exec.CommandContext(ctx, path, args...).Output()
+
+	// iperf3 -J reports its own failures in the JSON, with a non-zero exit.
+	var res struct {
+		Error string `json:"error"`
+		End   struct {
+			SumReceived struct {
+				BitsPerSecond float64 `json:"bits_per_second"`
+			} `json:"sum_received"`
+		} `json:"end"`
+	}
+	if err := json.Unmarshal(out, &res); err != nil {
+		if runErr != nil {
+			return 0, fmt.Errorf("iperf3: %w", runErr)
+		}
+		return 0, fmt.Errorf("parse iperf3 output: %w", err)
+	}
+	if res.Error != "" {
+		return 0, errors.New(res.Error)
+	}
+	if runErr != nil {
+		return 0, fmt.Errorf("iperf3: %w", runErr)
+	}
+	return res.End.SumReceived.BitsPerSecond / 1e6, nil
+}
+
+// client returns an HTTP client whose connections leave through iface.
+func client(iface string) *http.Client {
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.DisableKeepAlives = true
+	transport.DisableCompression = true // count the bytes on the wire
+	if iface != "" {
+		transport.DialContext = (&net.Dialer{Control: health.BindToDevice(iface)}).DialContext
+	}
+	return &http.Client{Transport: transport}
+}
+
+func mbps(n int64, elapsed time.Duration) (float64, error) {
+	if n == 0 || elapsed <= 0 {
+		return 0, errors.New("no data transferred")
+	}
+	return float64(n) * 8 / elapsed.Seconds() / 1e6, nil
+}
+
+// countingReader is an endless stream of zeros that counts what was read.
+type countingReader struct{ n int64 }
+
+func (r *countingReader) Read(p []byte) (int, error) {
+	clear(p)
+	r.n += int64(len(p))
+	return len(p), nil
+}
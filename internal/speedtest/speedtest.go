@@ -0,0 +1,258 @@
+// Package speedtest measures the quality of the WAN uplinks: latency,
+// jitter and loss from timed TCP handshakes, and throughput either with the
+// built-in HTTP measurement or with an iperf3 server. Tests run on demand
+// through the API or on a schedule; each result is stored and exported as
+// metrics.
+package speedtest
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/google/uuid"
+	"go.uber.org/zap"
+
+	"github.com/aegisx/aegisx/internal/firewall"
+	"github.com/aegisx/aegisx/internal/metrics"
+	"github.com/aegisx/aegisx/internal/store"
+)
+
+// Throughput measurement methods.
+const (
+	MethodHTTP   = "http"
+	MethodIperf3 = "iperf3"
+)
+
+// What started a test.
+const (
+	TriggerManual    = "manual"
+	TriggerScheduled = "scheduled"
+)
+
+// DefaultUplink names the test of the default route when no WANPolicy is
+// applied.
+const DefaultUplink = "default"
+
+var (
+	ErrBusy          = errors.New("a WAN test is already running")
+	ErrUnknownUplink = errors.New("unknown uplink")
+)
+
+// Config selects what a test measures and against which servers.
+type Config struct {
+	Method        string // http | iperf3
+	LatencyTarget string // host:port whose TCP handshake is timed
+	Samples       int    // handshakes per test
+	DownloadURL   string // http: fetched for the download rate
+	UploadURL     string // http: receives a POST for the upload rate; empty skips it
+	Iperf3Path    string
+	Iperf3Server  string        // iperf3: host or host:port
+	Duration      time.Duration // per throughput direction
+	Interval      time.Duration // between scheduled tests; 0 tests on demand only
+}
+
+// Uplink is a link a test can run over.
+type Uplink struct {
+	Name      string `json:"name"`
+	Interface string `json:"interface,omitempty"` // empty follows the default route
+}
+
+// Runner runs WAN tests one at a time and records their results.
+type Runner struct {
+	running atomic.Bool
+	store   *store.WANTestStore
+	fw      *firewall.Service
+	cfg     Config
+	log     *zap.Logger
+}
+
+func NewRunner(s *store.WANTestStore, fw *firewall.Service, cfg Config, log *zap.Logger) *Runner {
+	return &Runner{store: s, fw: fw, cfg: cfg, log: log}
+}
+
+// Store returns the backing store, for the API.
+func (r *Runner) Store() *store.WANTestStore { return r.store }
+
+// Uplinks returns the uplinks of the applied WANPolicy, or the default
+// route when there is none.
+func (r *Runner) Uplinks() []Uplink {
+	ir := r.fw.CurrentIR()
+	if ir == nil || ir.WAN == nil || len(ir.WAN.Uplinks) == 0 {
+		return []Uplink{{Name: DefaultUplink}}
+	}
+	out := make([]Uplink, len(ir.WAN.Uplinks))
+	for i, u := range ir.WAN.Uplinks {
+		out[i] = Uplink{Name: u.Name, Interface: u.Interface}
+	}
+	return out
+}
+
+// Start tests the named uplink, or every uplink one after the other when
+// uplink is empty, in the background; the results land in the store. It
+// returns ErrBusy while another test runs.
+func (r *Runner) Start(uplink string, createdBy *uuid.UUID) error {
+	targets, err := r.targets(uplink)
+	if err != nil {
+		return err
+	}
+	if !r.running.CompareAndSwap(false, true) {
+		return ErrBusy
+	}
+	go func() {
+		defer r.running.Store(false)
+		ctx, cancel := context.WithTimeout(context.Background(), time.Duration(len(targets))*r.budget())
+		defer cancel()
+		r.run(ctx, targets, TriggerManual, createdBy)
+	}()
+	return nil
+}
+
+// Run is Start for every uplink, waiting for the results. A failed
+// measurement is part of the result, not an error.
+func (r *Runner) Run(ctx context.Context, trigger string) ([]*store.WANTest, error) {
+	targets, err := r.targets("")
+	if err != nil {
+		return nil, err
+	}
+	if !r.running.CompareAndSwap(false, true) {
+		return nil, ErrBusy
+	}
+	defer r.running.Store(false)
+	return r.run(ctx, targets, trigger, nil), nil
+}
+
+// Running reports whether a test is in progress.
+func (r *Runner) Running() bool { return r.running.Load() }
+
+// Schedule tests every uplink each Interval, the first time one Interval
+// after it starts. It returns at once when Interval is 0.
+// Call this in a goroutine; it blocks until ctx is cancelled.
+func (r *Runner) Schedule(ctx context.Context) {
+	if r.cfg.Interval <= 0 {
+		return
+	}
+	ticker := time.NewTicker(r.cfg.Interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+		if _, err := r.Run(ctx, TriggerScheduled); err != nil && !errors.Is(err, ErrBusy) {
+			r.log.Warn("scheduled wan test", zap.Error(err))
+		}
+	}
+}
+
+// ─── Private helpers ──────────────────────────────────────────────────────
+
+func (r *Runner) targets(uplink string) ([]Uplink, error) {
+	var targets []Uplink
+	for _, u := range r.Uplinks() {
+		if uplink == "" || u.Name == uplink {
+			targets = append(targets, u)
+		}
+	}
+	if len(targets) == 0 {
+		return nil, fmt.Errorf("%w %q", ErrUnknownUplink, uplink)
+	}
+	return targets, nil
+}
+
+func (r *Runner) run(ctx context.Context, targets []Uplink, trigger string, createdBy *uuid.UUID) []*store.WANTest {
+	results := make([]*store.WANTest, 0, len(targets))
+	for _, u := range targets {
+		t := r.measure(ctx, u)
+		t.Trigger, t.CreatedBy = trigger, createdBy
+		if err := r.store.Record(context.WithoutCancel(ctx), t); err != nil {
+			r.log.Warn("record wan test", zap.String("uplink", u.Name), zap.Error(err))
+		}
+		export(t)
+		r.log.Info("wan test finished", zap.String("uplink", u.Name), zap.String("trigger", trigger),
+			zap.Int("duration_ms", t.DurationMs), zap.String("error", t.Error))
+		results = append(results, t)
+	}
+	return results
+}
+
+// budget bounds the test of one uplink: every handshake timing out, and
+// both throughput directions running into their kill timeout.
+func (r *Runner) budget() time.Duration {
+	return time.Duration(r.cfg.Samples)*(sampleTimeout+sampleGap) + 2*(r.cfg.Duration+15*time.Second)
+}
+
+// measure runs every measurement over u. Each one that fails adds to the
+// error and leaves its metrics nil; the others still run.
+func (r *Runner) measure(ctx context.Context, u Uplink) *store.WANTest {
+	start := time.Now()
+	t := &store.WANTest{Uplink: u.Name, Interface: u.Interface, Method: r.cfg.Method, StartedAt: start}
+	var errs []string
+
+	if r.cfg.LatencyTarget != "" {
+		lat, err := measureLatency(ctx, r.cfg.LatencyTarget, u.Interface, r.cfg.Samples)
+		if lat != nil {
+			t.LatencyMs, t.JitterMs, t.LossPct = lat.latencyMs, lat.jitterMs, &lat.lossPct
+		}
+		if err != nil {
+			errs = append(errs, "latency: "+err.Error())
+		}
+	}
+
+	rate := func(dir string, fn func() (float64, error)) *float64 {
+		mbps, err := fn()
+		if err != nil {
+			errs = append(errs, dir+": "+err.Error())
+			return nil
+		}
+		return &mbps
+	}
+	switch r.cfg.Method {
+	case MethodIperf3:
+		t.DownloadMbps = rate("download", func() (float64, error) {
+			return iperf3(ctx, r.cfg.Iperf3Path, r.cfg.Iperf3Server, u.Interface, r.cfg.Duration, true)
+		})
+		t.UploadMbps = rate("upload", func() (float64, error) {
+			return iperf3(ctx, r.cfg.Iperf3Path, r.cfg.Iperf3Server, u.Interface, r.cfg.Duration, false)
+		})
+	default:
+		if r.cfg.DownloadURL != "" {
+			t.DownloadMbps = rate("download", func() (float64, error) {
+				return httpDownload(ctx, r.cfg.DownloadURL, u.Interface, r.cfg.Duration)
+			})
+		}
+		if r.cfg.UploadURL != "" {
+			t.UploadMbps = rate("upload", func() (float64, error) {
+				return httpUpload(ctx, r.cfg.UploadURL, u.Interface, r.cfg.Duration)
+			})
+		}
+	}
+
+	t.Error = strings.Join(errs, "; ")
+	t.DurationMs = int(time.Since(start) / time.Millisecond)
+	return t
+}
+
+// export sets the gauges of t's uplink to what it measured.
+func export(t *store.WANTest) {
+	if t.LatencyMs != nil {
+		metrics.WANTestLatencySeconds.WithLabelValues(t.Uplink).Set(*t.LatencyMs / 1000)
+	}
+	if t.JitterMs != nil {
+		metrics.WANTestJitterSeconds.WithLabelValues(t.Uplink).Set(*t.JitterMs / 1000)
+	}
+	if t.LossPct != nil {
+		metrics.WANTestLossRatio.WithLabelValues(t.Uplink).Set(*t.LossPct / 100)
+	}
+	if t.DownloadMbps != nil {
+		metrics.WANTestThroughput.WithLabelValues(t.Uplink, "download").Set(*t.DownloadMbps * 1e6)
+	}
+	if t.UploadMbps != nil {
+		metrics.WANTestThroughput.WithLabelValues(t.Uplink, "upload").Set(*t.UploadMbps * 1e6)
+	}
+}
@@ -0,0 +1,254 @@
+package store
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/aegisx/aegisx/internal/config"
+	"github.com/aegisx/aegisx/internal/redact"
+)
+
+// Audit actions.
+const (
+	AuditImpersonateStart   = "IMPERSONATE_START"
+	AuditImpersonateEnd     = "IMPERSONATE_END"
+	AuditImpersonateRequest = "IMPERSONATED_REQUEST"
+	AuditBreakGlassRequest  = "BREAK_GLASS_REQUEST"
+	AuditBreakGlassGrant    = "BREAK_GLASS_GRANT"
+	AuditBreakGlassDeny     = "BREAK_GLASS_DENY"
+	AuditBreakGlassEnd      = "BREAK_GLASS_END"
+	AuditBreakGlassUse      = "BREAK_GLASS_USE"
+)
+
+// AuditEntry is one row of the audit log.
+type AuditEntry struct {
+	ID              uuid.UUID       `json:"id"`
+	TenantID        *uuid.UUID      `json:"tenantId,omitempty"`
+	UserID          *uuid.UUID      `json:"userId,omitempty"`
+	ImpersonationID *uuid.UUID      `json:"impersonationId,omitempty"`
+	Action          string          `json:"action"`
+	Resource        string          `json:"resource,omitempty"`
+	ResourceID      string          `json:"resourceId,omitempty"`
+	Detail          json.RawMessage `json:"detail,omitempty"`
+	IPAddress       string          `json:"ipAddress,omitempty"`
+	UserAgent       string          `json:"userAgent,omitempty"`
+	Status          string          `json:"status"`
+	CreatedAt       time.Time       `json:"createdAt"`
+
+	// Seq numbers the entries in the order they were written. Hash covers
+	// the entry and PrevHash, the Hash of entry Seq-1, so changing or
+	// removing an entry breaks the chain; see VerifyChain.
+	Seq      int64  `json:"seq"`
+	PrevHash string `json:"prevHash,omitempty"`
+	Hash     string `json:"hash"`
+}
+
+// AuditFilter narrows an audit listing. Zero-valued fields are ignored.
+type AuditFilter struct {
+	TenantID        *uuid.UUID
+	ImpersonationID *uuid.UUID
+	Action          string
+	Since           time.Time
+	Until           time.Time
+	Limit           int // default 100
+}
+
+// AuditStore appends to and reads the audit log.
+type AuditStore struct{ db *DB }
+
+func NewAuditStore(db *DB) *AuditStore { return &AuditStore{db: db} }
+
+// Record appends e to the audit log. Secrets in Detail are redacted first.
+func (s *AuditStore) Record(ctx context.Context, e *AuditEntry) error {
+	if e.Status == "" {
+		e.Status = "success"
+	}
+	e.Detail = redact.JSON(e.Detail)
+	const insert = `
+		INSERT INTO audit_log
+			(tenant_id, user_id, impersonation_id, action, resource, resource_id,
+			 detail, ip_address, user_agent, status)
+		VALUES ($1, $2, $3, $4, NULLIF($5, ''), NULLIF($6, ''), $7, NULLIF($8, '')::inet, NULLIF($9, ''), $10)`
+	args := []any{e.TenantID, e.UserID, e.ImpersonationID, e.Action, e.Resource, e.ResourceID,
+		e.Detail, e.IPAddress, e.UserAgent, e.Status}
+	if s.db.driver == config.DriverSQLite {
+		return s.recordSQLite(ctx, e, insert, args)
+	}
+	err := s.db.Pool.QueryRow(ctx, insert+`
+		RETURNING id, created_at, seq, COALESCE(prev_hash, ''), hash`, args...,
+	).Scan(&e.ID, &e.CreatedAt, &e.Seq, &e.PrevHash, &e.Hash)
+	if err != nil {
+		return fmt.Errorf("insert audit entry: %w", err)
+	}
+	return nil
+}
+
+// recordSQLite is Record for SQLite, where the audit_log_chain trigger
+// links the entry after the insert, too late for RETURNING to see; the
+// chain columns are read back in the same transaction instead.
+func (s *AuditStore) recordSQLite(ctx context.Context, e *AuditEntry, insert string, args []any) error {
+	tx, err := s.db.Pool.Begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback(ctx)
+
+	var rowid int64
+	if err := tx.QueryRow(ctx, insert+` RETURNING rowid`, args...).Scan(&rowid); err != nil {
+		return fmt.Errorf("insert audit entry: %w", err)
+	}
+	err = tx.QueryRow(ctx, `
+		SELECT id, created_at, seq, COALESCE(prev_hash, ''), hash FROM audit_log WHERE rowid = $1`, rowid,
+	).Scan(&e.ID, &e.CreatedAt, &e.Seq, &e.PrevHash, &e.Hash)
+	if err != nil {
+		return fmt.Errorf("read audit entry: %w", err)
+	}
+	return tx.Commit(ctx)
+}
+
+// List returns the newest entries matching filter.
+func (s *AuditStore) List(ctx context.Context, filter AuditFilter) ([]*AuditEntry, error) {
+	var items []*AuditEntry
+	err := s.Each(ctx, filter, func(e *AuditEntry) error {
+		items = append(items, e)
+		return nil
+	})
+	return items, err
+}
+
+// Each calls fn with the entries matching filter, newest first, and stops
+// at the first error fn returns.
+func (s *AuditStore) Each(ctx context.Context, filter AuditFilter, fn func(*AuditEntry) error) error {
+	if filter.Limit <= 0 {
+		filter.Limit = 100
+	}
+	rows, err := s.db.Pool.Query(ctx, `
+		SELECT id, tenant_id, user_id, impersonation_id, action,
+		       COALESCE(resource, ''), COALESCE(resource_id, ''), detail,
+		       COALESCE(host(ip_address), ''), COALESCE(user_agent, ''), status, created_at,
+		       seq, COALESCE(prev_hash, ''), hash
+		FROM audit_log
+		WHERE ($1::uuid IS NULL OR tenant_id = $1)
+		  AND ($2::uuid IS NULL OR impersonation_id = $2)
+		  AND ($3 = '' OR action = $3)
+		  AND ($4::timestamptz IS NULL OR created_at >= $4)
+		  AND ($5::timestamptz IS NULL OR created_at < $5)
+		ORDER BY created_at DESC
+		LIMIT $6`,
+		filter.TenantID, filter.ImpersonationID, filter.Action,
+		nullIfZeroTime(filter.Since), nullIfZeroTime(filter.Until), filter.Limit)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var e AuditEntry
+		if err := rows.Scan(&e.ID, &e.TenantID, &e.UserID, &e.ImpersonationID, &e.Action,
+			&e.Resource, &e.ResourceID, &e.Detail, &e.IPAddress, &e.UserAgent,
+			&e.Status, &e.CreatedAt, &e.Seq, &e.PrevHash, &e.Hash); err != nil {
+			return err
+		}
+		if err := fn(&e); err != nil {
+			return err
+		}
+	}
+	return rows.Err()
+}
+
+// AuditChainReport is the outcome of VerifyChain.
+type AuditChainReport struct {
+	Intact   bool   `json:"intact"`
+	Entries  int64  `json:"entries"`            // entries checked
+	FirstSeq int64  `json:"firstSeq,omitempty"` // oldest entry retention has left
+	HeadSeq  int64  `json:"headSeq"`
+	HeadHash string `json:"headHash,omitempty"` // kept elsewhere, shows a chain rewritten later
+	// Problems lists the breaks found, oldest first, up to
+	// maxChainProblems.
+	Problems  []AuditChainProblem `json:"problems,omitempty"`
+	CheckedAt time.Time           `json:"checkedAt"`
+}
+
+// AuditChainProblem is one break in the audit chain.
+type AuditChainProblem struct {
+	Seq     int64  `json:"seq"`
+	Problem string `json:"problem"`
+}
+
+const maxChainProblems = 100
+
+// VerifyChain recomputes the hash of every audit entry, oldest first, and
+// checks that each links to the one before it and the newest to the chain
+// head. Retention prunes the oldest entries, so the chain is checked from
+// the oldest one left.
+func (s *AuditStore) VerifyChain(ctx context.Context) (*AuditChainReport, error) {
+	rep := &AuditChainReport{CheckedAt: time.Now()}
+	var headHash *string
+	err := s.db.Pool.QueryRow(ctx, `SELECT seq, hash FROM audit_log_head`).Scan(&rep.HeadSeq, &headHash)
+	if err != nil {
+		return nil, fmt.Errorf("read audit chain head: %w", err)
+	}
+	if headHash != nil {
+		rep.HeadHash = *headHash
+	}
+
+	hashExpr := `audit_log_hash(a)`
+	if s.db.driver == config.DriverSQLite {
+		// SQLite functions take columns, not rows.
+		hashExpr = `audit_log_hash(seq, prev_hash, id, tenant_id, user_id, impersonation_id, action, resource,
+			resource_id, detail, ip_address, user_agent, status, created_at)`
+	}
+	rows, err := s.db.Pool.Query(ctx, `
+		SELECT seq, COALESCE(prev_hash, ''), hash, `+hashExpr+`
+		FROM audit_log a
+		ORDER BY seq`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	report := func(seq int64, format string, args ...any) {
+		if len(rep.Problems) < maxChainProblems {
+			rep.Problems = append(rep.Problems, AuditChainProblem{Seq: seq, Problem: fmt.Sprintf(format, args...)})
+		}
+	}
+	var lastSeq int64
+	var lastHash string
+	for rows.Next() {
+		var seq int64
+		var prev, hash, computed string
+		if err := rows.Scan(&seq, &prev, &hash, &computed); err != nil {
+			return nil, err
+		}
+		if hash != computed {
+			report(seq, "entry was changed after it was written")
+		}
+		switch {
+		case rep.Entries == 0:
+			rep.FirstSeq = seq
+		case seq != lastSeq+1:
+			report(seq, "entries %d to %d are missing", lastSeq+1, seq-1)
+		case prev != lastHash:
+			report(seq, "does not link to entry %d", lastSeq)
+		}
+		rep.Entries++
+		lastSeq, lastHash = seq, hash
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	switch {
+	case rep.Entries == 0:
+	case lastSeq < rep.HeadSeq:
+		report(lastSeq+1, "entries %d to %d are missing from the end of the log", lastSeq+1, rep.HeadSeq)
+	case lastSeq > rep.HeadSeq || lastHash != rep.HeadHash:
+		report(lastSeq, "newest entry does not match the chain head")
+	}
+	rep.Intact = len(rep.Problems) == 0
+	return rep, nil
+}
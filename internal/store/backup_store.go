@@ -0,0 +1,542 @@
+package store
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/jackc/pgx/v5"
+
+	"github.com/aegisx/aegisx/internal/config"
+)
+
+// backupExcluded are the tables a backup leaves out: telemetry, which
+// retention prunes anyway and which can dwarf the configuration, and the
+// migration bookkeeping, which belongs to the binary rather than the data.
+var backupExcluded = map[string]bool{
+	TableIDSAlerts:        true,
+	TableFirewallEvents:   true,
+	TableVPNPeerStats:     true,
+	TableMetricsSnapshots: true,
+	"schema_migrations":   true,
+}
+
+// ForeignKey is a foreign-key constraint between two public tables.
+type ForeignKey struct {
+	Name       string   `json:"name"`
+	Table      string   `json:"table"`
+	Columns    []string `json:"columns"`
+	RefTable   string   `json:"refTable"`
+	RefColumns []string `json:"refColumns"`
+}
+
+// TableDiff compares a table loaded into a scratch schema with its live
+// counterpart. Added, Removed and Changed are by id and are only set when
+// the table has an id column.
+type TableDiff struct {
+	Rows    int64 `json:"rows"`
+	Live    int64 `json:"live"`
+	ByID    bool  `json:"byId"`
+	Added   int64 `json:"added"`
+	Removed int64 `json:"removed"`
+	Changed int64 `json:"changed"`
+}
+
+// ScratchPolicy is a policy row as loaded into a scratch schema.
+type ScratchPolicy struct {
+	ID       string
+	TenantID string
+	Name     string
+	RawYAML  string
+	Enabled  bool
+}
+
+// BackupStore reads the tables a backup covers and stages backups in
+// scratch schemas for verification.
+type BackupStore struct{ db *DB }
+
+func NewBackupStore(db *DB) *BackupStore { return &BackupStore{db: db} }
+
+// Tables returns the public base tables a backup covers, by name.
+func (s *BackupStore) Tables(ctx context.Context) ([]string, error) {
+	names, err := s.tables(ctx, "public")
+	if err != nil {
+		return nil, err
+	}
+	out := names[:0]
+	for _, n := range names {
+		if !backupExcluded[n] {
+			out = append(out, n)
+		}
+	}
+	return out, nil
+}
+
+// Columns returns the columns of schema.table in table order.
+func (s *BackupStore) Columns(ctx context.Context, schema, table string) ([]string, error) {
+	if s.db.driver == config.DriverSQLite {
+		cols, err := sqliteColumns(ctx, s.db.Pool, sqliteTable(schema, table))
+		out := make([]string, len(cols))
+		for i, c := range cols {
+			out[i] = c.name
+		}
+		return out, err
+	}
+	rows, err := s.db.Pool.Query(ctx, `
+		SELECT column_name FROM information_schema.columns
+		WHERE table_schema = $1 AND table_name = $2
+		ORDER BY ordinal_position`, schema, table)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var out []string
+	for rows.Next() {
+		var c string
+		if err := rows.Scan(&c); err != nil {
+			return nil, err
+		}
+		out = append(out, c)
+	}
+	return out, rows.Err()
+}
+
+// Dump passes every row of the public tables to fn as a JSON object, table
+// by table. All tables are read in one snapshot.
+func (s *BackupStore) Dump(ctx context.Context, tables []string, fn func(table string, row json.RawMessage) error) error {
+	tx, err := s.db.Pool.BeginTx(ctx, pgx.TxOptions{IsoLevel: pgx.RepeatableRead, AccessMode: pgx.ReadOnly})
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback(ctx)
+
+	for _, t := range tables {
+		if err := s.dumpTable(ctx, tx, t, fn); err != nil {
+			return fmt.Errorf("dump %s: %w", t, err)
+		}
+	}
+	return nil
+}
+
+// CreateScratch creates schema holding an empty copy of each public table,
+// with its columns, defaults, checks and unique indexes but no foreign
+// keys, so rows load in any order and references are checked afterwards.
+func (s *BackupStore) CreateScratch(ctx context.Context, schema string, tables []string) error {
+	tx, err := s.db.Pool.Begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback(ctx)
+
+	if s.db.driver == config.DriverSQLite {
+		for _, t := range tables {
+			if err := createSQLiteScratch(ctx, tx, schema, t); err != nil {
+				return fmt.Errorf("create %s.%s: %w", schema, t, err)
+			}
+		}
+		return tx.Commit(ctx)
+	}
+	if _, err := tx.Exec(ctx, `CREATE SCHEMA `+pgx.Identifier{schema}.Sanitize()); err != nil {
+		return fmt.Errorf("create schema %s: %w", schema, err)
+	}
+	for _, t := range tables {
+		if _, err := tx.Exec(ctx, fmt.Sprintf(`CREATE TABLE %s (LIKE %s INCLUDING ALL)`,
+			s.ident(schema, t), s.ident("public", t))); err != nil {
+			return fmt.Errorf("create %s.%s: %w", schema, t, err)
+		}
+	}
+	return tx.Commit(ctx)
+}
+
+// LoadScratch inserts rows, JSON objects keyed by column, into
+// schema.table. Only columns are set; the others take their defaults.
+func (s *BackupStore) LoadScratch(ctx context.Context, schema, table string, columns []string, rows []json.RawMessage) error {
+	if len(rows) == 0 || len(columns) == 0 {
+		return nil
+	}
+	doc, err := json.Marshal(rows)
+	if err != nil {
+		return err
+	}
+	cols := identList(columns)
+	query := fmt.Sprintf(`
+		INSERT INTO %[1]s (%[2]s)
+		SELECT %[2]s FROM json_populate_recordset(NULL::%[1]s, $1::json)`,
+		s.ident(schema, table), cols)
+	if s.db.driver == config.DriverSQLite {
+		values, err := sqliteJSONValues(ctx, s.db.Pool, sqliteTable(schema, table), columns)
+		if err != nil {
+			return fmt.Errorf("load %s: %w", table, err)
+		}
+		query = fmt.Sprintf(`
+		INSERT INTO %s (%s)
+		SELECT %s FROM json_each($1)`, s.ident(schema, table), cols, values)
+	}
+	_, err = s.db.Pool.Exec(ctx, query, doc)
+	if err != nil {
+		return fmt.Errorf("load %s: %w", table, err)
+	}
+	return nil
+}
+
+// DropScratch drops schema and everything in it.
+func (s *BackupStore) DropScratch(ctx context.Context, schema string) error {
+	if s.db.driver == config.DriverSQLite {
+		tables, err := s.tables(ctx, schema)
+		if err != nil {
+			return err
+		}
+		for _, t := range tables {
+			if _, err := s.db.Pool.Exec(ctx, `DROP TABLE IF EXISTS `+s.ident(schema, t)); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	_, err := s.db.Pool.Exec(ctx, `DROP SCHEMA IF EXISTS `+pgx.Identifier{schema}.Sanitize()+` CASCADE`)
+	return err
+}
+
+// ForeignKeys returns the foreign keys between public tables.
+func (s *BackupStore) ForeignKeys(ctx context.Context) ([]ForeignKey, error) {
+	if s.db.driver == config.DriverSQLite {
+		return s.sqliteForeignKeys(ctx)
+	}
+	rows, err := s.db.Pool.Query(ctx, `
+		SELECT c.conname, t.relname, r.relname,
+		       ARRAY(SELECT a.attname FROM unnest(c.conkey) WITH ORDINALITY k(n, i)
+		             JOIN pg_attribute a ON a.attrelid = c.conrelid AND a.attnum = k.n
+		             ORDER BY k.i)::text[],
+		       ARRAY(SELECT a.attname FROM unnest(c.confkey) WITH ORDINALITY k(n, i)
+		             JOIN pg_attribute a ON a.attrelid = c.confrelid AND a.attnum = k.n
+		             ORDER BY k.i)::text[]
+		FROM pg_constraint c
+		JOIN pg_class t ON t.oid = c.conrelid
+		JOIN pg_class r ON r.oid = c.confrelid
+		JOIN pg_namespace n ON n.oid = t.relnamespace
+		WHERE c.contype = 'f' AND n.nspname = 'public'
+		ORDER BY t.relname, c.conname`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var out []ForeignKey
+	for rows.Next() {
+		var fk ForeignKey
+		if err := rows.Scan(&fk.Name, &fk.Table, &fk.RefTable, &fk.Columns, &fk.RefColumns); err != nil {
+			return nil, err
+		}
+		out = append(out, fk)
+	}
+	return out, rows.Err()
+}
+
+// Orphans counts the rows of fk.Table in schema whose reference matches no
+// row of fk.RefTable in refSchema. Rows with a NULL in the key reference
+// nothing and are not counted.
+func (s *BackupStore) Orphans(ctx context.Context, schema, refSchema string, fk ForeignKey) (int64, error) {
+	var notNull, join []string
+	for i, c := range fk.Columns {
+		col := pgx.Identifier{c}.Sanitize()
+		notNull = append(notNull, "c."+col+" IS NOT NULL")
+		join = append(join, "p."+pgx.Identifier{fk.RefColumns[i]}.Sanitize()+" = c."+col)
+	}
+	var n int64
+	err := s.db.Pool.QueryRow(ctx, fmt.Sprintf(`
+		SELECT count(*) FROM %s c
+		WHERE %s AND NOT EXISTS (SELECT 1 FROM %s p WHERE %s)`,
+		s.ident(schema, fk.Table), strings.Join(notNull, " AND "), s.ident(refSchema, fk.RefTable), strings.Join(join, " AND "))).Scan(&n)
+	if err != nil {
+		return 0, fmt.Errorf("check %s: %w", fk.Name, err)
+	}
+	return n, nil
+}
+
+// Diff compares schema.table with the live table of the same name.
+func (s *BackupStore) Diff(ctx context.Context, schema, table string) (*TableDiff, error) {
+	scratch, live := s.ident(schema, table), s.ident("public", table)
+	d := &TableDiff{}
+	err := s.db.Pool.QueryRow(ctx, fmt.Sprintf(`
+		SELECT (SELECT count(*) FROM %s), (SELECT count(*) FROM %s)`, scratch, live)).Scan(&d.Rows, &d.Live)
+	if err != nil {
+		return nil, fmt.Errorf("diff %s: %w", table, err)
+	}
+	cols, err := s.Columns(ctx, "public", table)
+	if err != nil {
+		return nil, err
+	}
+	if !contains(cols, "id") {
+		return d, nil
+	}
+	d.ByID = true
+	backupRow, liveRow := "to_jsonb(b)", "to_jsonb(l)"
+	if s.db.driver == config.DriverSQLite {
+		if backupRow, err = sqliteRowJSON(ctx, s.db.Pool, sqliteTable(schema, table), "b"); err != nil {
+			return nil, err
+		}
+		if liveRow, err = sqliteRowJSON(ctx, s.db.Pool, table, "l"); err != nil {
+			return nil, err
+		}
+	}
+	err = s.db.Pool.QueryRow(ctx, fmt.Sprintf(`
+		SELECT
+		  (SELECT count(*) FROM %[1]s b WHERE NOT EXISTS (SELECT 1 FROM %[2]s l WHERE l.id = b.id)),
+		  (SELECT count(*) FROM %[2]s l WHERE NOT EXISTS (SELECT 1 FROM %[1]s b WHERE b.id = l.id)),
+		  (SELECT count(*) FROM %[1]s b JOIN %[2]s l ON l.id = b.id WHERE %[3]s <> %[4]s)`,
+		scratch, live, backupRow, liveRow)).Scan(&d.Added, &d.Removed, &d.Changed)
+	if err != nil {
+		return nil, fmt.Errorf("diff %s: %w", table, err)
+	}
+	return d, nil
+}
+
+// ScratchPolicies returns the policies loaded into schema.
+func (s *BackupStore) ScratchPolicies(ctx context.Context, schema string) ([]ScratchPolicy, error) {
+	rows, err := s.db.Pool.Query(ctx, fmt.Sprintf(`
+		SELECT id::text, tenant_id::text, name, raw_yaml, enabled
+		FROM %s ORDER BY tenant_id, name`, s.ident(schema, "policies")))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var out []ScratchPolicy
+	for rows.Next() {
+		var p ScratchPolicy
+		if err := rows.Scan(&p.ID, &p.TenantID, &p.Name, &p.RawYAML, &p.Enabled); err != nil {
+			return nil, err
+		}
+		out = append(out, p)
+	}
+	return out, rows.Err()
+}
+
+// ─── Private helpers ──────────────────────────────────────────────────────
+
+func (s *BackupStore) tables(ctx context.Context, schema string) ([]string, error) {
+	query, args := `
+		SELECT table_name FROM information_schema.tables
+		WHERE table_schema = $1 AND table_type = 'BASE TABLE'
+		ORDER BY table_name`, []any{schema}
+	if s.db.driver == config.DriverSQLite {
+		query, args = `
+		SELECT name FROM sqlite_master
+		WHERE type = 'table' AND name NOT LIKE 'sqlite%'
+		ORDER BY name`, nil
+	}
+	rows, err := s.db.Pool.Query(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var out []string
+	for rows.Next() {
+		var t string
+		if err := rows.Scan(&t); err != nil {
+			return nil, err
+		}
+		if s.db.driver == config.DriverSQLite {
+			var ok bool
+			if t, ok = sqliteTableIn(schema, t); !ok {
+				continue
+			}
+		}
+		out = append(out, t)
+	}
+	return out, rows.Err()
+}
+
+func (s *BackupStore) dumpTable(ctx context.Context, tx pgx.Tx, table string, fn func(string, json.RawMessage) error) error {
+	row := "row_to_json(t)"
+	if s.db.driver == config.DriverSQLite {
+		var err error
+		if row, err = sqliteRowJSON(ctx, tx, table, "t"); err != nil {
+			return err
+		}
+	}
+	rows, err := tx.Query(ctx, fmt.Sprintf(`SELECT %s FROM %s t`, row, s.ident("public", table)))
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var row []byte
+		if err := rows.Scan(&row); err != nil {
+			return err
+		}
+		if err := fn(table, row); err != nil {
+			return err
+		}
+	}
+	return rows.Err()
+}
+
+// ident quotes schema.table; see sqliteTable for SQLite.
+func (s *BackupStore) ident(schema, table string) string {
+	if s.db.driver == config.DriverSQLite {
+		return pgx.Identifier{sqliteTable(schema, table)}.Sanitize()
+	}
+	return pgx.Identifier{schema, table}.Sanitize()
+}
+
+func identList(cols []string) string {
+	out := make([]string, len(cols))
+	for i, c := range cols {
+		out[i] = pgx.Identifier{c}.Sanitize()
+	}
+	return strings.Join(out, ", ")
+}
+
+func contains(list []string, s string) bool {
+	for _, v := range list {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
+
+// ─── SQLite ───────────────────────────────────────────────────────────────
+
+// SQLite has no schemas, so a scratch schema is a set of tables named
+// schema.table beside the public ones, which keep their bare names.
+
+func sqliteTable(schema, table string) string {
+	if schema == "public" {
+		return table
+	}
+	return schema + "." + table
+}
+
+// sqliteTableIn reports whether the SQLite table name belongs to schema,
+// and returns it without the schema.
+func sqliteTableIn(schema, name string) (string, bool) {
+	if schema == "public" {
+		return name, !strings.Contains(name, ".")
+	}
+	return strings.CutPrefix(name, schema+".")
+}
+
+var (
+	sqliteCreateTable = regexp.MustCompile(`^CREATE TABLE\s+("[^"]+"|\S+)`)
+	sqliteReferences  = regexp.MustCompile(`(?i)\s+REFERENCES\s+\w+\s*\([^)]*\)(\s+ON\s+(DELETE|UPDATE)\s+(SET\s+NULL|SET\s+DEFAULT|CASCADE|RESTRICT|NO\s+ACTION))*`)
+	sqliteCreateIndex = regexp.MustCompile(`^CREATE UNIQUE INDEX\s+("[^"]+"|\S+)\s+ON\s+("[^"]+"|[^\s(]+)`)
+)
+
+// createSQLiteScratch copies table and its unique indexes into schema,
+// leaving out the foreign keys.
+func createSQLiteScratch(ctx context.Context, tx pgx.Tx, schema, table string) error {
+	rows, err := tx.Query(ctx, `
+		SELECT type, name, sql FROM sqlite_master
+		WHERE tbl_name = $1 AND sql IS NOT NULL AND type IN ('table', 'index')
+		ORDER BY type = 'index', name`, table)
+	if err != nil {
+		return err
+	}
+	var stmts []string
+	for rows.Next() {
+		var typ, name, sql string
+		if err := rows.Scan(&typ, &name, &sql); err != nil {
+			rows.Close()
+			return err
+		}
+		scratch := pgx.Identifier{sqliteTable(schema, table)}.Sanitize()
+		switch {
+		case typ == "table":
+			sql = sqliteCreateTable.ReplaceAllLiteralString(sql, "CREATE TABLE "+scratch)
+			stmts = append(stmts, sqliteReferences.ReplaceAllString(sql, ""))
+		case sqliteCreateIndex.MatchString(sql):
+			index := pgx.Identifier{sqliteTable(schema, name)}.Sanitize()
+			stmts = append(stmts, sqliteCreateIndex.ReplaceAllLiteralString(sql,
+				"CREATE UNIQUE INDEX "+index+" ON "+scratch))
+		}
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return err
+	}
+	if len(stmts) == 0 {
+		return fmt.Errorf("table %s not found", table)
+	}
+	for _, stmt := range stmts {
+		if _, err := tx.Exec(ctx, stmt); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// sqliteJSONValues returns the expressions reading columns of table from
+// the JSON objects json_each yields, SQLite's json_populate_recordset.
+func sqliteJSONValues(ctx context.Context, q querier, table string, columns []string) (string, error) {
+	cols, err := sqliteColumns(ctx, q, table)
+	if err != nil {
+		return "", err
+	}
+	types := make(map[string]string, len(cols))
+	for _, c := range cols {
+		types[c.name] = c.typ
+	}
+	out := make([]string, len(columns))
+	for i, c := range columns {
+		typ, ok := types[c]
+		if !ok {
+			return "", fmt.Errorf("column %s of %s not found", c, table)
+		}
+		path := "'$." + strings.ReplaceAll(pgx.Identifier{c}.Sanitize(), "'", "''") + "'"
+		switch typ {
+		case "JSONB":
+			out[i] = "CASE json_type(value, " + path + ") WHEN 'null' THEN NULL ELSE value -> " + path + " END"
+		case "BYTEA":
+			out[i] = "unhex(substr(value ->> " + path + ", 3))"
+		default:
+			out[i] = "value ->> " + path
+		}
+	}
+	return strings.Join(out, ", "), nil
+}
+
+// sqliteForeignKeys is ForeignKeys for SQLite, which leaves foreign keys
+// unnamed; they are named as PostgreSQL would name them.
+func (s *BackupStore) sqliteForeignKeys(ctx context.Context) ([]ForeignKey, error) {
+	rows, err := s.db.Pool.Query(ctx, `
+		SELECT m.name, f.id, f."table", f."from", COALESCE(f."to", 'id')
+		FROM sqlite_master m, pragma_foreign_key_list(m.name) f
+		WHERE m.type = 'table' AND instr(m.name, '.') = 0
+		ORDER BY m.name, f.id, f.seq`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var out []ForeignKey
+	lastTable, lastID := "", int64(-1)
+	for rows.Next() {
+		var table, ref, from, to string
+		var id int64
+		if err := rows.Scan(&table, &id, &ref, &from, &to); err != nil {
+			return nil, err
+		}
+		if table != lastTable || id != lastID {
+			out = append(out, ForeignKey{Table: table, RefTable: ref})
+			lastTable, lastID = table, id
+		}
+		fk := &out[len(out)-1]
+		fk.Columns = append(fk.Columns, from)
+		fk.RefColumns = append(fk.RefColumns, to)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	for i := range out {
+		out[i].Name = out[i].Table + "_" + strings.Join(out[i].Columns, "_") + "_fkey"
+	}
+	sort.Slice(out, func(i, j int) bool {
+		if out[i].Table != out[j].Table {
+			return out[i].Table < out[j].Table
+		}
+		return out[i].Name < out[j].Name
+	})
+	return out, nil
+}
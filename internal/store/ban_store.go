@@ -0,0 +1,120 @@
+package store
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+)
+
+// Ban blocks an address for a limited time.
+type Ban struct {
+	ID        uuid.UUID  `json:"id"`
+	Address   string     `json:"address"`
+	Jail      string     `json:"jail"`
+	Reason    string     `json:"reason"`
+	Failures  int        `json:"failures"`
+	BannedAt  time.Time  `json:"bannedAt"`
+	ExpiresAt time.Time  `json:"expiresAt"`
+	LiftedAt  *time.Time `json:"liftedAt,omitempty"`
+	LiftedBy  *uuid.UUID `json:"liftedBy,omitempty"`
+}
+
+// BanStore handles brute-force bans.
+type BanStore struct{ db *DB }
+
+func NewBanStore(db *DB) *BanStore { return &BanStore{db: db} }
+
+// Create records a new ban.
+func (s *BanStore) Create(ctx context.Context, b *Ban) error {
+	err := s.db.Pool.QueryRow(ctx, `
+		INSERT INTO bans (address, jail, reason, failures, expires_at)
+		VALUES ($1::inet, $2, $3, $4, $5)
+		RETURNING id, banned_at`,
+		b.Address, b.Jail, b.Reason, b.Failures, b.ExpiresAt,
+	).Scan(&b.ID, &b.BannedAt)
+	if err != nil {
+		return fmt.Errorf("insert ban: %w", err)
+	}
+	return nil
+}
+
+// ListActive returns the bans that have neither expired nor been lifted.
+func (s *BanStore) ListActive(ctx context.Context) ([]*Ban, error) {
+	rows, err := s.db.Pool.Query(ctx, `
+		SELECT `+banColumns+`
+		FROM bans
+		WHERE lifted_at IS NULL AND expires_at > NOW()
+		ORDER BY banned_at DESC`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var items []*Ban
+	for rows.Next() {
+		b, err := scanBan(rows)
+		if err != nil {
+			return nil, err
+		}
+		items = append(items, b)
+	}
+	return items, rows.Err()
+}
+
+// CountSince returns how many times address was banned since the given time.
+func (s *BanStore) CountSince(ctx context.Context, address string, since time.Time) (int, error) {
+	var n int
+	err := s.db.Pool.QueryRow(ctx, `
+		SELECT COUNT(*) FROM bans WHERE address = $1::inet AND banned_at >= $2`,
+		address, since).Scan(&n)
+	return n, err
+}
+
+// Lift ends every active ban of address and returns the lifted bans.
+func (s *BanStore) Lift(ctx context.Context, address string, by *uuid.UUID) ([]*Ban, error) {
+	rows, err := s.db.Pool.Query(ctx, `
+		UPDATE bans SET lifted_at = NOW(), lifted_by = $2
+		WHERE address = $1::inet AND lifted_at IS NULL AND expires_at > NOW()
+		RETURNING `+banColumns,
+		address, by)
+	if err != nil {
+		return nil, fmt.Errorf("lift ban: %w", err)
+	}
+	defer rows.Close()
+
+	var items []*Ban
+	for rows.Next() {
+		b, err := scanBan(rows)
+		if err != nil {
+			return nil, err
+		}
+		items = append(items, b)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	if len(items) == 0 {
+		return nil, fmt.Errorf("ban not found")
+	}
+	return items, nil
+}
+
+// ─── Private helpers ──────────────────────────────────────────────────────
+
+const banColumns = `id, host(address), jail, reason, failures, banned_at, expires_at, lifted_at, lifted_by`
+
+func scanBan(row scanner) (*Ban, error) {
+	var b Ban
+	err := row.Scan(&b.ID, &b.Address, &b.Jail, &b.Reason, &b.Failures,
+		&b.BannedAt, &b.ExpiresAt, &b.LiftedAt, &b.LiftedBy)
+	if err != nil {
+		if err == pgx.ErrNoRows {
+			return nil, fmt.Errorf("ban not found")
+		}
+		return nil, err
+	}
+	return &b, nil
+}
@@ -0,0 +1,419 @@
+package store
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+
+	"github.com/aegisx/aegisx/internal/config"
+)
+
+// BlockList is a named collection of addresses and prefixes that are dropped
+// in both directions.
+type BlockList struct {
+	ID          uuid.UUID  `json:"id"`
+	Name        string     `json:"name"`
+	Description string     `json:"description"`
+	Enabled     bool       `json:"enabled"`
+	CreatedBy   *uuid.UUID `json:"createdBy,omitempty"`
+	CreatedAt   time.Time  `json:"createdAt"`
+	UpdatedAt   time.Time  `json:"updatedAt"`
+}
+
+// BlockListFeed is a subscription that fills a list from a URL.
+type BlockListFeed struct {
+	ID            uuid.UUID  `json:"id"`
+	BlockListID   uuid.UUID  `json:"blocklistId"`
+	URL           string     `json:"url"`
+	Format        string     `json:"format"`              // plain|cidr|json
+	JSONField     string     `json:"jsonField,omitempty"` // object key of the address in a JSON feed
+	RefreshSecs   int        `json:"refreshSeconds"`
+	LastFetchedAt *time.Time `json:"lastFetchedAt,omitempty"`
+	LastError     string     `json:"lastError,omitempty"`
+	EntryCount    int        `json:"entryCount"`
+	CreatedBy     *uuid.UUID `json:"createdBy,omitempty"`
+	CreatedAt     time.Time  `json:"createdAt"`
+}
+
+// Due reports whether the feed should be fetched at now.
+func (f *BlockListFeed) Due(now time.Time) bool {
+	return f.LastFetchedAt == nil || !now.Before(f.LastFetchedAt.Add(time.Duration(f.RefreshSecs)*time.Second))
+}
+
+// BlockListEntry is one address or prefix of a list. FeedID is nil for
+// entries added by hand.
+type BlockListEntry struct {
+	ID          uuid.UUID  `json:"id"`
+	BlockListID uuid.UUID  `json:"blocklistId"`
+	FeedID      *uuid.UUID `json:"feedId,omitempty"`
+	Address     string     `json:"address"`
+	Comment     string     `json:"comment,omitempty"`
+	ExpiresAt   *time.Time `json:"expiresAt,omitempty"`
+	CreatedBy   *uuid.UUID `json:"createdBy,omitempty"`
+	CreatedAt   time.Time  `json:"createdAt"`
+}
+
+// BlockListStore handles block lists, their feeds and entries.
+type BlockListStore struct{ db *DB }
+
+func NewBlockListStore(db *DB) *BlockListStore { return &BlockListStore{db: db} }
+
+// ─── Lists ────────────────────────────────────────────────────────────────
+
+// Create records a new list.
+func (s *BlockListStore) Create(ctx context.Context, l *BlockList) error {
+	err := s.db.Pool.QueryRow(ctx, `
+		INSERT INTO blocklists (name, description, enabled, created_by)
+		VALUES ($1, $2, $3, $4)
+		RETURNING id, created_at, updated_at`,
+		l.Name, l.Description, l.Enabled, l.CreatedBy,
+	).Scan(&l.ID, &l.CreatedAt, &l.UpdatedAt)
+	if err != nil {
+		if strings.Contains(err.Error(), "duplicate key") {
+			return fmt.Errorf("blocklist %q already exists", l.Name)
+		}
+		return fmt.Errorf("insert blocklist: %w", err)
+	}
+	return nil
+}
+
+// Get returns one list.
+func (s *BlockListStore) Get(ctx context.Context, id uuid.UUID) (*BlockList, error) {
+	row := s.db.Pool.QueryRow(ctx, `
+		SELECT `+blockListColumns+` FROM blocklists WHERE id = $1`, id)
+	return scanBlockList(row)
+}
+
+// List returns every list by name.
+func (s *BlockListStore) List(ctx context.Context) ([]*BlockList, error) {
+	rows, err := s.db.Pool.Query(ctx, `
+		SELECT `+blockListColumns+` FROM blocklists ORDER BY name`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var items []*BlockList
+	for rows.Next() {
+		l, err := scanBlockList(rows)
+		if err != nil {
+			return nil, err
+		}
+		items = append(items, l)
+	}
+	return items, rows.Err()
+}
+
+// Update saves the description and enabled flag of a list.
+func (s *BlockListStore) Update(ctx context.Context, l *BlockList) error {
+	tag, err := s.db.Pool.Exec(ctx, `
+		UPDATE blocklists SET description = $2, enabled = $3, updated_at = NOW()
+		WHERE id = $1`,
+		l.ID, l.Description, l.Enabled)
+	if err != nil {
+		return fmt.Errorf("update blocklist: %w", err)
+	}
+	if tag.RowsAffected() == 0 {
+		return fmt.Errorf("blocklist not found")
+	}
+	return nil
+}
+
+// Delete removes a list with its feeds and entries.
+func (s *BlockListStore) Delete(ctx context.Context, id uuid.UUID) error {
+	tag, err := s.db.Pool.Exec(ctx, `DELETE FROM blocklists WHERE id = $1`, id)
+	if err != nil {
+		return err
+	}
+	if tag.RowsAffected() == 0 {
+		return fmt.Errorf("blocklist not found")
+	}
+	return nil
+}
+
+// ─── Feeds ────────────────────────────────────────────────────────────────
+
+// CreateFeed subscribes a list to a feed.
+func (s *BlockListStore) CreateFeed(ctx context.Context, f *BlockListFeed) error {
+	err := s.db.Pool.QueryRow(ctx, `
+		INSERT INTO blocklist_feeds (blocklist_id, url, format, json_field, refresh_seconds, created_by)
+		VALUES ($1, $2, $3, $4, $5, $6)
+		RETURNING id, created_at`,
+		f.BlockListID, f.URL, f.Format, f.JSONField, f.RefreshSecs, f.CreatedBy,
+	).Scan(&f.ID, &f.CreatedAt)
+	if err != nil {
+		if strings.Contains(err.Error(), "duplicate key") {
+			return fmt.Errorf("feed %s already exists", f.URL)
+		}
+		return fmt.Errorf("insert blocklist feed: %w", err)
+	}
+	return nil
+}
+
+// GetFeed returns one feed of a list.
+func (s *BlockListStore) GetFeed(ctx context.Context, listID, id uuid.UUID) (*BlockListFeed, error) {
+	row := s.db.Pool.QueryRow(ctx, `
+		SELECT `+blockListFeedColumns+`
+		FROM blocklist_feeds WHERE blocklist_id = $1 AND id = $2`, listID, id)
+	return scanBlockListFeed(row)
+}
+
+// ListFeeds returns the feeds of a list, or of every list when listID is
+// uuid.Nil.
+func (s *BlockListStore) ListFeeds(ctx context.Context, listID uuid.UUID) ([]*BlockListFeed, error) {
+	rows, err := s.db.Pool.Query(ctx, `
+		SELECT `+blockListFeedColumns+`
+		FROM blocklist_feeds
+		WHERE $1 = '00000000-0000-0000-0000-000000000000'::uuid OR blocklist_id = $1
+		ORDER BY created_at`, listID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var items []*BlockListFeed
+	for rows.Next() {
+		f, err := scanBlockListFeed(rows)
+		if err != nil {
+			return nil, err
+		}
+		items = append(items, f)
+	}
+	return items, rows.Err()
+}
+
+// DeleteFeed unsubscribes a list from a feed and drops the feed's entries.
+func (s *BlockListStore) DeleteFeed(ctx context.Context, listID, id uuid.UUID) error {
+	tag, err := s.db.Pool.Exec(ctx, `
+		DELETE FROM blocklist_feeds WHERE blocklist_id = $1 AND id = $2`, listID, id)
+	if err != nil {
+		return err
+	}
+	if tag.RowsAffected() == 0 {
+		return fmt.Errorf("feed not found")
+	}
+	return nil
+}
+
+// ReplaceFeedEntries swaps the entries of a feed for addresses and records
+// a successful fetch, in one transaction.
+func (s *BlockListStore) ReplaceFeedEntries(ctx context.Context, f *BlockListFeed, addresses []string) error {
+	tx, err := s.db.Pool.Begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback(ctx)
+
+	if _, err := tx.Exec(ctx, `DELETE FROM blocklist_entries WHERE feed_id = $1`, f.ID); err != nil {
+		return fmt.Errorf("delete feed entries: %w", err)
+	}
+	insert := `
+		INSERT INTO blocklist_entries (blocklist_id, feed_id, address)
+		SELECT $1, $2, a FROM unnest($3::text[]::cidr[]) AS a`
+	if s.db.driver == config.DriverSQLite {
+		insert = `
+		INSERT INTO blocklist_entries (blocklist_id, feed_id, address)
+		SELECT $1, $2, cidr(value) FROM json_each($3)`
+	}
+	if _, err := tx.Exec(ctx, insert, f.BlockListID, f.ID, addresses); err != nil {
+		return fmt.Errorf("insert feed entries: %w", err)
+	}
+	if _, err := tx.Exec(ctx, `
+		UPDATE blocklist_feeds SET last_fetched_at = NOW(), last_error = '', entry_count = $2
+		WHERE id = $1`, f.ID, len(addresses)); err != nil {
+		return err
+	}
+	return tx.Commit(ctx)
+}
+
+// MarkFeedFailed records a failed fetch. The previous entries stay, and the
+// feed is retried after its refresh interval.
+func (s *BlockListStore) MarkFeedFailed(ctx context.Context, id uuid.UUID, fetchErr error) error {
+	_, err := s.db.Pool.Exec(ctx, `
+		UPDATE blocklist_feeds SET last_fetched_at = NOW(), last_error = $2 WHERE id = $1`,
+		id, fetchErr.Error())
+	return err
+}
+
+// ─── Entries ──────────────────────────────────────────────────────────────
+
+// AddEntry adds a manual entry.
+func (s *BlockListStore) AddEntry(ctx context.Context, e *BlockListEntry) error {
+	err := s.db.Pool.QueryRow(ctx, `
+		INSERT INTO blocklist_entries (blocklist_id, address, comment, expires_at, created_by)
+		VALUES ($1, $2::cidr, $3, $4, $5)
+		RETURNING id, `+cidrText+`, created_at`,
+		e.BlockListID, e.Address, e.Comment, e.ExpiresAt, e.CreatedBy,
+	).Scan(&e.ID, &e.Address, &e.CreatedAt)
+	if err != nil {
+		return fmt.Errorf("insert blocklist entry: %w", err)
+	}
+	return nil
+}
+
+// DeleteEntry removes a manual entry; feed entries go with their feed.
+func (s *BlockListStore) DeleteEntry(ctx context.Context, listID, id uuid.UUID) error {
+	tag, err := s.db.Pool.Exec(ctx, `
+		DELETE FROM blocklist_entries
+		WHERE blocklist_id = $1 AND id = $2 AND feed_id IS NULL`, listID, id)
+	if err != nil {
+		return err
+	}
+	if tag.RowsAffected() == 0 {
+		return fmt.Errorf("entry not found")
+	}
+	return nil
+}
+
+// ListEntries returns a page of the unexpired entries of a list, newest
+// first, and their total count.
+func (s *BlockListStore) ListEntries(ctx context.Context, listID uuid.UUID, limit, offset int) ([]*BlockListEntry, int, error) {
+	var total int
+	if err := s.db.Pool.QueryRow(ctx, `
+		SELECT COUNT(*) FROM blocklist_entries
+		WHERE blocklist_id = $1 AND (expires_at IS NULL OR expires_at > NOW())`, listID,
+	).Scan(&total); err != nil {
+		return nil, 0, err
+	}
+
+	rows, err := s.db.Pool.Query(ctx, `
+		SELECT `+blockListEntryColumns+`
+		FROM blocklist_entries
+		WHERE blocklist_id = $1 AND (expires_at IS NULL OR expires_at > NOW())
+		ORDER BY created_at DESC, id
+		LIMIT $2 OFFSET $3`, listID, limit, offset)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer rows.Close()
+
+	var items []*BlockListEntry
+	for rows.Next() {
+		e, err := scanBlockListEntry(rows)
+		if err != nil {
+			return nil, 0, err
+		}
+		items = append(items, e)
+	}
+	return items, total, rows.Err()
+}
+
+// ActiveAddresses returns the distinct unexpired addresses of every enabled
+// list: what the nftables sets should hold.
+func (s *BlockListStore) ActiveAddresses(ctx context.Context) ([]string, error) {
+	rows, err := s.db.Pool.Query(ctx, `
+		SELECT DISTINCT `+cidrText+`
+		FROM blocklist_entries e
+		JOIN blocklists l ON l.id = e.blocklist_id
+		WHERE l.enabled AND (e.expires_at IS NULL OR e.expires_at > NOW())`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var items []string
+	for rows.Next() {
+		var a string
+		if err := rows.Scan(&a); err != nil {
+			return nil, err
+		}
+		items = append(items, a)
+	}
+	return items, rows.Err()
+}
+
+// EntryAddresses returns the distinct unexpired addresses of one list.
+func (s *BlockListStore) EntryAddresses(ctx context.Context, listID uuid.UUID) ([]string, error) {
+	rows, err := s.db.Pool.Query(ctx, `
+		SELECT DISTINCT `+cidrText+`
+		FROM blocklist_entries
+		WHERE blocklist_id = $1 AND (expires_at IS NULL OR expires_at > NOW())`, listID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var items []string
+	for rows.Next() {
+		var a string
+		if err := rows.Scan(&a); err != nil {
+			return nil, err
+		}
+		items = append(items, a)
+	}
+	return items, rows.Err()
+}
+
+// DeleteExpired removes manual entries whose expiry has passed.
+func (s *BlockListStore) DeleteExpired(ctx context.Context) (int64, error) {
+	tag, err := s.db.Pool.Exec(ctx, `
+		DELETE FROM blocklist_entries WHERE expires_at IS NOT NULL AND expires_at <= NOW()`)
+	if err != nil {
+		return 0, err
+	}
+	return tag.RowsAffected(), nil
+}
+
+// NextExpiry returns the earliest expiry of the manual entries still
+// active, or nil when none expires.
+func (s *BlockListStore) NextExpiry(ctx context.Context) (*time.Time, error) {
+	var next *time.Time
+	err := s.db.Pool.QueryRow(ctx, `
+		SELECT MIN(expires_at) FROM blocklist_entries WHERE expires_at > NOW()`).Scan(&next)
+	return next, err
+}
+
+// ─── Private helpers ──────────────────────────────────────────────────────
+
+// cidrText prints single hosts without their /32 or /128.
+const cidrText = `CASE WHEN masklen(address) = max_masklen(address)
+		THEN host(address) ELSE text(address) END`
+
+const blockListColumns = `id, name, description, enabled, created_by, created_at, updated_at`
+
+const blockListFeedColumns = `id, blocklist_id, url, format, json_field, refresh_seconds,
+	last_fetched_at, last_error, entry_count, created_by, created_at`
+
+const blockListEntryColumns = `id, blocklist_id, feed_id, ` + cidrText + `, comment, expires_at, created_by, created_at`
+
+func scanBlockList(row scanner) (*BlockList, error) {
+	var l BlockList
+	err := row.Scan(&l.ID, &l.Name, &l.Description, &l.Enabled, &l.CreatedBy, &l.CreatedAt, &l.UpdatedAt)
+	if err != nil {
+		if err == pgx.ErrNoRows {
+			return nil, fmt.Errorf("blocklist not found")
+		}
+		return nil, err
+	}
+	return &l, nil
+}
+
+func scanBlockListFeed(row scanner) (*BlockListFeed, error) {
+	var f BlockListFeed
+	err := row.Scan(&f.ID, &f.BlockListID, &f.URL, &f.Format, &f.JSONField, &f.RefreshSecs,
+		&f.LastFetchedAt, &f.LastError, &f.EntryCount, &f.CreatedBy, &f.CreatedAt)
+	if err != nil {
+		if err == pgx.ErrNoRows {
+			return nil, fmt.Errorf("feed not found")
+		}
+		return nil, err
+	}
+	return &f, nil
+}
+
+func scanBlockListEntry(row scanner) (*BlockListEntry, error) {
+	var e BlockListEntry
+	err := row.Scan(&e.ID, &e.BlockListID, &e.FeedID, &e.Address, &e.Comment,
+		&e.ExpiresAt, &e.CreatedBy, &e.CreatedAt)
+	if err != nil {
+		if err == pgx.ErrNoRows {
+			return nil, fmt.Errorf("entry not found")
+		}
+		return nil, err
+	}
+	return &e, nil
+}
@@ -0,0 +1,133 @@
+package store
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+)
+
+// BreakGlass is an emergency access session. It is pending until granted,
+// then active until it expires or is ended.
+type BreakGlass struct {
+	ID          uuid.UUID  `json:"id"`
+	TenantID    uuid.UUID  `json:"tenantId"`
+	UserID      uuid.UUID  `json:"userId"`
+	Reason      string     `json:"reason"`
+	TTLSeconds  int        `json:"ttlSeconds"`
+	Method      string     `json:"method"` // code|approval
+	RequestedAt time.Time  `json:"requestedAt"`
+	ApprovedBy  *uuid.UUID `json:"approvedBy,omitempty"`
+	GrantedAt   *time.Time `json:"grantedAt,omitempty"`
+	ExpiresAt   *time.Time `json:"expiresAt,omitempty"`
+	EndedAt     *time.Time `json:"endedAt,omitempty"`
+	EndedBy     *uuid.UUID `json:"endedBy,omitempty"`
+}
+
+// Pending reports whether the session still awaits approval.
+func (b *BreakGlass) Pending() bool {
+	return b.GrantedAt == nil && b.EndedAt == nil
+}
+
+// Active reports whether the session has been granted and has neither
+// expired nor been ended.
+func (b *BreakGlass) Active() bool {
+	return b.ExpiresAt != nil && b.EndedAt == nil && time.Now().Before(*b.ExpiresAt)
+}
+
+// BreakGlassStore handles break-glass sessions.
+type BreakGlassStore struct{ db *DB }
+
+func NewBreakGlassStore(db *DB) *BreakGlassStore { return &BreakGlassStore{db: db} }
+
+// Create records a new session; with grant set it is active at once.
+func (s *BreakGlassStore) Create(ctx context.Context, b *BreakGlass, grant bool) error {
+	row := s.db.Pool.QueryRow(ctx, `
+		INSERT INTO break_glass_sessions (tenant_id, user_id, reason, ttl_seconds, method, granted_at, expires_at)
+		VALUES ($1, $2, $3, $4, $5,
+		        CASE WHEN $6 THEN NOW() END,
+		        CASE WHEN $6 THEN NOW() + make_interval(secs => $4) END)
+		RETURNING `+breakGlassColumns,
+		b.TenantID, b.UserID, b.Reason, b.TTLSeconds, b.Method, grant)
+	created, err := scanBreakGlass(row)
+	if err != nil {
+		return fmt.Errorf("insert break-glass session: %w", err)
+	}
+	*b = *created
+	return nil
+}
+
+// Get returns a session by ID.
+func (s *BreakGlassStore) Get(ctx context.Context, id uuid.UUID) (*BreakGlass, error) {
+	row := s.db.Pool.QueryRow(ctx, `
+		SELECT `+breakGlassColumns+`
+		FROM break_glass_sessions
+		WHERE id = $1`, id)
+	return scanBreakGlass(row)
+}
+
+// List returns the tenant's newest sessions, only userID's when it is set.
+func (s *BreakGlassStore) List(ctx context.Context, tenantID uuid.UUID, userID *uuid.UUID) ([]*BreakGlass, error) {
+	rows, err := s.db.Pool.Query(ctx, `
+		SELECT `+breakGlassColumns+`
+		FROM break_glass_sessions
+		WHERE tenant_id = $1 AND ($2::uuid IS NULL OR user_id = $2)
+		ORDER BY requested_at DESC
+		LIMIT 200`, tenantID, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var items []*BreakGlass
+	for rows.Next() {
+		b, err := scanBreakGlass(rows)
+		if err != nil {
+			return nil, err
+		}
+		items = append(items, b)
+	}
+	return items, rows.Err()
+}
+
+// Approve grants a pending session; its TTL starts now.
+func (s *BreakGlassStore) Approve(ctx context.Context, id, approver uuid.UUID) (*BreakGlass, error) {
+	row := s.db.Pool.QueryRow(ctx, `
+		UPDATE break_glass_sessions
+		SET approved_by = $2, granted_at = NOW(), expires_at = NOW() + make_interval(secs => ttl_seconds)
+		WHERE id = $1 AND granted_at IS NULL AND ended_at IS NULL
+		RETURNING `+breakGlassColumns,
+		id, approver)
+	return scanBreakGlass(row)
+}
+
+// End closes a pending or active session; its tokens stop working
+// immediately.
+func (s *BreakGlassStore) End(ctx context.Context, id uuid.UUID, by uuid.UUID) (*BreakGlass, error) {
+	row := s.db.Pool.QueryRow(ctx, `
+		UPDATE break_glass_sessions SET ended_at = NOW(), ended_by = $2
+		WHERE id = $1 AND ended_at IS NULL AND (expires_at IS NULL OR expires_at > NOW())
+		RETURNING `+breakGlassColumns,
+		id, by)
+	return scanBreakGlass(row)
+}
+
+// ─── Private helpers ──────────────────────────────────────────────────────
+
+const breakGlassColumns = `id, tenant_id, user_id, reason, ttl_seconds, method, requested_at,
+	approved_by, granted_at, expires_at, ended_at, ended_by`
+
+func scanBreakGlass(row scanner) (*BreakGlass, error) {
+	var b BreakGlass
+	err := row.Scan(&b.ID, &b.TenantID, &b.UserID, &b.Reason, &b.TTLSeconds, &b.Method, &b.RequestedAt,
+		&b.ApprovedBy, &b.GrantedAt, &b.ExpiresAt, &b.EndedAt, &b.EndedBy)
+	if err != nil {
+		if err == pgx.ErrNoRows {
+			return nil, fmt.Errorf("break-glass session not found")
+		}
+		return nil, err
+	}
+	return &b, nil
+}
@@ -0,0 +1,137 @@
+package store
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+
+	"github.com/aegisx/aegisx/internal/redact"
+)
+
+// PolicyChange is a proposed policy awaiting review.
+type PolicyChange struct {
+	ID            uuid.UUID       `json:"id"`
+	TenantID      uuid.UUID       `json:"tenantId"`
+	Namespace     string          `json:"namespace"`
+	Name          string          `json:"name"`
+	Kind          string          `json:"kind"`
+	Spec          json.RawMessage `json:"spec"`
+	RawYAML       string          `json:"rawYaml"`
+	Reason        string          `json:"reason"`
+	Source        string          `json:"source"` // user | ids-suggestion
+	Status        string          `json:"status"` // pending | approved | rejected
+	PolicyID      *uuid.UUID      `json:"policyId,omitempty"`
+	RequestedBy   *uuid.UUID      `json:"requestedBy"`
+	ReviewedBy    *uuid.UUID      `json:"reviewedBy,omitempty"`
+	ReviewComment string          `json:"reviewComment,omitempty"`
+	ReviewedAt    *time.Time      `json:"reviewedAt,omitempty"`
+	CreatedAt     time.Time       `json:"createdAt"`
+}
+
+// MarshalJSON redacts secrets from Spec and RawYAML, as PolicyRecord does.
+func (ch PolicyChange) MarshalJSON() ([]byte, error) {
+	type plain PolicyChange
+	out := plain(ch)
+	out.Spec = redact.JSON(ch.Spec)
+	out.RawYAML = redact.String(ch.RawYAML)
+	return json.Marshal(out)
+}
+
+// ChangeStore handles the policy change approval queue.
+type ChangeStore struct{ db *DB }
+
+func NewChangeStore(db *DB) *ChangeStore { return &ChangeStore{db: db} }
+
+// Create queues a pending change.
+func (s *ChangeStore) Create(ctx context.Context, ch *PolicyChange) error {
+	if ch.ID == uuid.Nil {
+		ch.ID = uuid.New()
+	}
+	ch.Status = "pending"
+	err := s.db.Pool.QueryRow(ctx, `
+		INSERT INTO policy_changes
+			(id, tenant_id, namespace, name, kind, spec, raw_yaml, reason, source, requested_by)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10)
+		RETURNING created_at`,
+		ch.ID, ch.TenantID, ch.Namespace, ch.Name, ch.Kind, ch.Spec, ch.RawYAML,
+		ch.Reason, ch.Source, ch.RequestedBy,
+	).Scan(&ch.CreatedAt)
+	if err != nil {
+		return fmt.Errorf("insert policy change: %w", err)
+	}
+	return nil
+}
+
+// List returns a tenant's changes with the given status (all if empty),
+// newest first.
+func (s *ChangeStore) List(ctx context.Context, tenantID uuid.UUID, status string) ([]*PolicyChange, error) {
+	rows, err := s.db.Pool.Query(ctx, `
+		SELECT id, tenant_id, namespace, name, kind, spec, raw_yaml, reason, source, status,
+		       policy_id, requested_by, reviewed_by, review_comment, reviewed_at, created_at
+		FROM policy_changes
+		WHERE tenant_id = $1 AND ($2 = '' OR status = $2)
+		ORDER BY created_at DESC`, tenantID, status)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var items []*PolicyChange
+	for rows.Next() {
+		ch, err := scanChange(rows)
+		if err != nil {
+			return nil, err
+		}
+		items = append(items, ch)
+	}
+	return items, rows.Err()
+}
+
+// Get returns one change by ID.
+func (s *ChangeStore) Get(ctx context.Context, tenantID, id uuid.UUID) (*PolicyChange, error) {
+	row := s.db.Pool.QueryRow(ctx, `
+		SELECT id, tenant_id, namespace, name, kind, spec, raw_yaml, reason, source, status,
+		       policy_id, requested_by, reviewed_by, review_comment, reviewed_at, created_at
+		FROM policy_changes
+		WHERE id = $1 AND tenant_id = $2`, id, tenantID)
+	return scanChange(row)
+}
+
+// Review records the decision on a pending change. It fails if the change
+// was already reviewed, so concurrent reviewers cannot both win.
+func (s *ChangeStore) Review(ctx context.Context, ch *PolicyChange) error {
+	err := s.db.Pool.QueryRow(ctx, `
+		UPDATE policy_changes
+		SET status = $3, policy_id = $4, reviewed_by = $5, review_comment = $6, reviewed_at = NOW()
+		WHERE id = $1 AND tenant_id = $2 AND status = 'pending'
+		RETURNING reviewed_at`,
+		ch.ID, ch.TenantID, ch.Status, ch.PolicyID, ch.ReviewedBy, ch.ReviewComment,
+	).Scan(&ch.ReviewedAt)
+	if err == pgx.ErrNoRows {
+		return fmt.Errorf("change not found or already reviewed")
+	}
+	if err != nil {
+		return fmt.Errorf("review policy change: %w", err)
+	}
+	return nil
+}
+
+// ─── Private helpers ──────────────────────────────────────────────────────
+
+func scanChange(row scanner) (*PolicyChange, error) {
+	var ch PolicyChange
+	err := row.Scan(&ch.ID, &ch.TenantID, &ch.Namespace, &ch.Name, &ch.Kind, &ch.Spec, &ch.RawYAML,
+		&ch.Reason, &ch.Source, &ch.Status, &ch.PolicyID, &ch.RequestedBy, &ch.ReviewedBy,
+		&ch.ReviewComment, &ch.ReviewedAt, &ch.CreatedAt)
+	if err != nil {
+		if err == pgx.ErrNoRows {
+			return nil, fmt.Errorf("change not found")
+		}
+		return nil, err
+	}
+	return &ch, nil
+}
@@ -3,21 +3,51 @@ package store
 import (
 	"context"
 	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
 
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
 	"github.com/jackc/pgx/v5/pgxpool"
 	"go.uber.org/zap"
 
 	"github.com/aegisx/aegisx/internal/config"
+	"github.com/aegisx/aegisx/internal/redact"
 )
 
-// DB wraps a pgxpool.Pool and provides helper methods.
+// Pool is the part of pgxpool.Pool the stores use. A SQLite database is
+// reached through an adapter with the same methods; see sqlite.go.
+type Pool interface {
+	Exec(ctx context.Context, sql string, args ...any) (pgconn.CommandTag, error)
+	Query(ctx context.Context, sql string, args ...any) (pgx.Rows, error)
+	QueryRow(ctx context.Context, sql string, args ...any) pgx.Row
+	Begin(ctx context.Context) (pgx.Tx, error)
+	BeginTx(ctx context.Context, opts pgx.TxOptions) (pgx.Tx, error)
+	SendBatch(ctx context.Context, b *pgx.Batch) pgx.BatchResults
+	Ping(ctx context.Context) error
+	Close()
+}
+
+// DB wraps a connection pool and provides helper methods.
 type DB struct {
-	Pool *pgxpool.Pool
-	log  *zap.Logger
+	Pool   Pool
+	driver string // config.DriverPostgres or config.DriverSQLite
+	log    *zap.Logger
 }
 
 // Connect creates and validates a new database connection pool.
 func Connect(ctx context.Context, cfg config.DatabaseConfig, log *zap.Logger) (*DB, error) {
+	switch cfg.Driver {
+	case config.DriverPostgres:
+	case config.DriverSQLite:
+		return connectSQLite(ctx, cfg, log)
+	default:
+		return nil, fmt.Errorf("database driver %q: must be %s or %s", cfg.Driver, config.DriverPostgres, config.DriverSQLite)
+	}
+
 	poolCfg, err := pgxpool.ParseConfig(cfg.DSN)
 	if err != nil {
 		return nil, fmt.Errorf("parse DSN: %w", err)
@@ -26,6 +56,9 @@ func Connect(ctx context.Context, cfg config.DatabaseConfig, log *zap.Logger) (*
 	poolCfg.MaxConns = int32(cfg.MaxOpenConns)
 	poolCfg.MinConns = int32(cfg.MaxIdleConns)
 	poolCfg.MaxConnLifetime = cfg.ConnMaxLifetime
+	if cfg.StatementTimeout > 0 {
+		poolCfg.ConnConfig.RuntimeParams["statement_timeout"] = strconv.FormatInt(cfg.StatementTimeout.Milliseconds(), 10)
+	}
 
 	pool, err := pgxpool.NewWithConfig(ctx, poolCfg)
 	if err != nil {
@@ -37,8 +70,8 @@ func Connect(ctx context.Context, cfg config.DatabaseConfig, log *zap.Logger) (*
 		return nil, fmt.Errorf("ping database: %w", err)
 	}
 
-	log.Info("database connected", zap.String("dsn_masked", maskDSN(cfg.DSN)))
-	return &DB{Pool: pool, log: log}, nil
+	log.Info("database connected", zap.String("dsn_masked", redact.DSN(cfg.DSN)))
+	return &DB{Pool: pool, driver: config.DriverPostgres, log: log}, nil
 }
 
 // Close releases all connections.
@@ -48,7 +81,12 @@ func (db *DB) Close() {
 
 // Migrate runs SQL migration files in order using golang-migrate.
 // For simplicity we exec files directly here; swap for golang-migrate in prod.
+// SQLite databases are migrated from the schema embedded in the binary;
+// see migrateSQLite.
 func (db *DB) Migrate(ctx context.Context, migrationsPath string) error {
+	if db.driver == config.DriverSQLite {
+		return db.migrateSQLite(ctx)
+	}
 	db.log.Info("running migrations", zap.String("path", migrationsPath))
 
 	// Create schema_migrations table if it doesn't exist.
@@ -66,10 +104,45 @@ func (db *DB) Migrate(ctx context.Context, migrationsPath string) error {
 	return nil
 }
 
-func maskDSN(dsn string) string {
-	// Very basic masking: hide password
-	if len(dsn) > 20 {
-		return dsn[:20] + "..."
+var createTable = regexp.MustCompile(`(?i)CREATE\s+TABLE\s+(?:IF\s+NOT\s+EXISTS\s+)?([a-z_][a-z0-9_.]*)`)
+
+// CheckSchema reports the migrations under migrationsPath whose tables are
+// missing from the database, i.e. that have not been applied. For SQLite
+// it checks the embedded migrations instead.
+func (db *DB) CheckSchema(ctx context.Context, migrationsPath string) (applied int, missing []string, err error) {
+	if db.driver == config.DriverSQLite {
+		return db.checkSQLiteSchema(ctx)
+	}
+	files, err := filepath.Glob(filepath.Join(migrationsPath, "*.sql"))
+	if err != nil {
+		return 0, nil, err
+	}
+	if len(files) == 0 {
+		return 0, nil, fmt.Errorf("no migrations found in %s", migrationsPath)
+	}
+	sort.Strings(files)
+
+	for _, f := range files {
+		data, err := os.ReadFile(f)
+		if err != nil {
+			return 0, nil, err
+		}
+		ok := true
+		for _, m := range createTable.FindAllStringSubmatch(string(data), -1) {
+			var exists bool
+			if err := db.Pool.QueryRow(ctx, `SELECT to_regclass($1) IS NOT NULL`, m[1]).Scan(&exists); err != nil {
+				return 0, nil, fmt.Errorf("check table %s: %w", m[1], err)
+			}
+			if !exists {
+				ok = false
+				break
+			}
+		}
+		if ok {
+			applied++
+		} else {
+			missing = append(missing, filepath.Base(f))
+		}
 	}
-	return dsn
+	return applied, missing, nil
 }
@@ -0,0 +1,240 @@
+package store
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+)
+
+// FirewallEvent is one persisted packet logged by a firewall rule.
+type FirewallEvent struct {
+	Timestamp time.Time `json:"timestamp"`
+	Rule      string    `json:"rule"`
+	Action    string    `json:"action"`
+	InIface   string    `json:"inIface,omitempty"`
+	OutIface  string    `json:"outIface,omitempty"`
+	SrcIP     string    `json:"srcIp"`
+	DstIP     string    `json:"dstIp"`
+	Protocol  string    `json:"protocol"`
+	SrcPort   int       `json:"srcPort,omitempty"`
+	DstPort   int       `json:"dstPort,omitempty"`
+
+	// Country, ASN and ASOrg describe the remote address; empty or zero
+	// when unknown.
+	Country string `json:"country,omitempty"`
+	ASN     uint32 `json:"asn,omitempty"`
+	ASOrg   string `json:"asOrg,omitempty"`
+}
+
+// EventQuery selects logged firewall events or IDS alerts to list.
+type EventQuery struct {
+	Since  time.Time
+	Until  time.Time // zero for no upper bound
+	Action string    // empty for every action
+	Limit  int
+}
+
+// Sources of blocked traffic for the analytics.
+const (
+	TrafficFirewall = "firewall" // packets dropped by logging firewall rules
+	TrafficIDS      = "ids"      // alerts Suricata, the tarpit or scan detection blocked
+)
+
+// Groupings of blocked traffic.
+const (
+	GroupCountry = "country"
+	GroupASN     = "asn"
+	GroupPort    = "port"
+)
+
+// TrafficQuery selects blocked traffic to aggregate.
+type TrafficQuery struct {
+	Source string // TrafficFirewall | TrafficIDS
+	By     string // GroupCountry | GroupASN | GroupPort
+	Since  time.Time
+	Bucket time.Duration // width of the series buckets; 0 for totals only
+	Limit  int           // number of groups, largest first
+}
+
+// TrafficGroup is the blocked traffic sharing one grouping key.
+type TrafficGroup struct {
+	Key     string         `json:"key"`             // ISO country code, AS number, or proto/port
+	Label   string         `json:"label,omitempty"` // AS organisation for ASN groups
+	Count   int64          `json:"count"`
+	Sources int64          `json:"sources"` // distinct source addresses
+	Series  []TrafficPoint `json:"series,omitempty"`
+}
+
+// TrafficPoint is the count of one time bucket.
+type TrafficPoint struct {
+	At    time.Time `json:"at"`
+	Count int64     `json:"count"`
+}
+
+// EventStore handles logged firewall events and the blocked-traffic
+// analytics over them and the IDS alerts.
+type EventStore struct{ db *DB }
+
+func NewEventStore(db *DB) *EventStore { return &EventStore{db: db} }
+
+// InsertFirewallEvent persists one logged packet.
+func (s *EventStore) InsertFirewallEvent(ctx context.Context, e *FirewallEvent) error {
+	_, err := s.db.Pool.Exec(ctx, `
+		INSERT INTO firewall_events
+			(timestamp, rule, action, in_iface, out_iface, src_ip, dst_ip, protocol,
+			 src_port, dst_port, country, asn, as_org)
+		VALUES ($1, $2, $3, $4, $5, $6::inet, $7::inet, $8, $9, $10, $11, $12, $13)`,
+		e.Timestamp, e.Rule, e.Action, e.InIface, e.OutIface, e.SrcIP, e.DstIP, e.Protocol,
+		nullIfZeroInt(e.SrcPort), nullIfZeroInt(e.DstPort), e.Country, nullIfZero(e.ASN), e.ASOrg,
+	)
+	if err != nil {
+		return fmt.Errorf("insert firewall event: %w", err)
+	}
+	return nil
+}
+
+// EachFirewallEvent calls fn with the events q selects, newest first, and
+// stops at the first error fn returns.
+func (s *EventStore) EachFirewallEvent(ctx context.Context, q EventQuery, fn func(*FirewallEvent) error) error {
+	rows, err := s.db.Pool.Query(ctx, `
+		SELECT timestamp, rule, action, in_iface, out_iface, host(src_ip), host(dst_ip),
+		       protocol, COALESCE(src_port, 0), COALESCE(dst_port, 0), country,
+		       COALESCE(asn, 0), as_org
+		FROM firewall_events
+		WHERE timestamp >= $1 AND ($2::timestamptz IS NULL OR timestamp < $2)
+		  AND ($3 = '' OR action = $3)
+		ORDER BY timestamp DESC
+		LIMIT $4`, q.Since, nullIfZeroTime(q.Until), q.Action, q.Limit)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var e FirewallEvent
+		if err := rows.Scan(&e.Timestamp, &e.Rule, &e.Action, &e.InIface, &e.OutIface,
+			&e.SrcIP, &e.DstIP, &e.Protocol, &e.SrcPort, &e.DstPort, &e.Country,
+			&e.ASN, &e.ASOrg); err != nil {
+			return err
+		}
+		if err := fn(&e); err != nil {
+			return err
+		}
+	}
+	return rows.Err()
+}
+
+// BlockedTraffic groups the blocked traffic of q.Source since q.Since by
+// country, AS or destination port and returns the q.Limit largest groups,
+// each with a time series when q.Bucket is set. Traffic whose key is
+// unknown, e.g. from private addresses, is left out.
+func (s *EventStore) BlockedTraffic(ctx context.Context, q TrafficQuery) ([]*TrafficGroup, error) {
+	table, blocked, err := trafficSource(q.Source)
+	if err != nil {
+		return nil, err
+	}
+	key, label, known, err := trafficGroup(q.By)
+	if err != nil {
+		return nil, err
+	}
+	where := fmt.Sprintf("timestamp >= $1 AND %s AND %s", blocked, known)
+
+	rows, err := s.db.Pool.Query(ctx, fmt.Sprintf(`
+		SELECT %s, %s, COUNT(*), COUNT(DISTINCT src_ip)
+		FROM %s
+		WHERE %s
+		GROUP BY 1
+		ORDER BY 3 DESC, 1
+		LIMIT $2`, key, label, table, where), q.Since, q.Limit)
+	if err != nil {
+		return nil, err
+	}
+	groups, err := scanTrafficGroups(rows)
+	if err != nil || q.Bucket <= 0 || len(groups) == 0 {
+		return groups, err
+	}
+
+	keys := make([]string, len(groups))
+	byKey := make(map[string]*TrafficGroup, len(groups))
+	for i, g := range groups {
+		keys[i] = g.Key
+		byKey[g.Key] = g
+	}
+	rows, err = s.db.Pool.Query(ctx, fmt.Sprintf(`
+		SELECT %s, to_timestamp(floor(extract(epoch FROM timestamp) / $3) * $3), COUNT(*)
+		FROM %s
+		WHERE %s AND %s = ANY($2)
+		GROUP BY 1, 2
+		ORDER BY 2`, key, table, where, key), q.Since, keys, q.Bucket.Seconds())
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var k string
+		var p TrafficPoint
+		if err := rows.Scan(&k, &p.At, &p.Count); err != nil {
+			return nil, err
+		}
+		if g := byKey[k]; g != nil {
+			g.Series = append(g.Series, p)
+		}
+	}
+	return groups, rows.Err()
+}
+
+// ─── Private helpers ──────────────────────────────────────────────────────
+
+// trafficSource returns the table of a traffic source and the condition
+// selecting its blocked rows.
+func trafficSource(source string) (table, blocked string, err error) {
+	switch source {
+	case TrafficFirewall:
+		return "firewall_events", "action IN ('drop', 'reject', 'tarpit')", nil
+	case TrafficIDS:
+		return "ids_alerts", "action = 'blocked'", nil
+	}
+	return "", "", fmt.Errorf("unknown traffic source %q", source)
+}
+
+// trafficGroup returns the key and label expressions of a grouping and the
+// condition excluding rows whose key is unknown.
+func trafficGroup(by string) (key, label, known string, err error) {
+	switch by {
+	case GroupCountry:
+		return "country", "''", "country <> ''", nil
+	case GroupASN:
+		return "asn::text", "MAX(as_org)", "asn IS NOT NULL", nil
+	case GroupPort:
+		return "LOWER(protocol) || '/' || dst_port", "''", "dst_port > 0 AND protocol <> ''", nil
+	}
+	return "", "", "", fmt.Errorf("unknown grouping %q", by)
+}
+
+func scanTrafficGroups(rows pgx.Rows) ([]*TrafficGroup, error) {
+	defer rows.Close()
+	items := []*TrafficGroup{}
+	for rows.Next() {
+		var g TrafficGroup
+		if err := rows.Scan(&g.Key, &g.Label, &g.Count, &g.Sources); err != nil {
+			return nil, err
+		}
+		items = append(items, &g)
+	}
+	return items, rows.Err()
+}
+
+func nullIfZeroInt(n int) *int {
+	if n == 0 {
+		return nil
+	}
+	return &n
+}
+
+func nullIfZeroTime(t time.Time) *time.Time {
+	if t.IsZero() {
+		return nil
+	}
+	return &t
+}
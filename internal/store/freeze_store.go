@@ -0,0 +1,115 @@
+package store
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+)
+
+// FreezeWindow is a period during which a tenant's dataplane changes are
+// refused, e.g. a release weekend.
+type FreezeWindow struct {
+	ID        uuid.UUID  `json:"id"`
+	TenantID  uuid.UUID  `json:"tenantId"`
+	Reason    string     `json:"reason"`
+	StartsAt  time.Time  `json:"startsAt"`
+	EndsAt    time.Time  `json:"endsAt"`
+	CreatedBy *uuid.UUID `json:"createdBy,omitempty"`
+	CreatedAt time.Time  `json:"createdAt"`
+}
+
+// FreezeStore handles freeze windows.
+type FreezeStore struct{ db *DB }
+
+func NewFreezeStore(db *DB) *FreezeStore { return &FreezeStore{db: db} }
+
+// Create records a new window.
+func (s *FreezeStore) Create(ctx context.Context, w *FreezeWindow) error {
+	err := s.db.Pool.QueryRow(ctx, `
+		INSERT INTO freeze_windows (tenant_id, reason, starts_at, ends_at, created_by)
+		VALUES ($1, $2, $3, $4, $5)
+		RETURNING id, created_at`,
+		w.TenantID, w.Reason, w.StartsAt, w.EndsAt, w.CreatedBy,
+	).Scan(&w.ID, &w.CreatedAt)
+	if err != nil {
+		return fmt.Errorf("insert freeze window: %w", err)
+	}
+	return nil
+}
+
+// List returns the tenant's windows that have not ended yet, or all of them
+// when includePast is set, soonest first.
+func (s *FreezeStore) List(ctx context.Context, tenantID uuid.UUID, includePast bool) ([]*FreezeWindow, error) {
+	rows, err := s.db.Pool.Query(ctx, `
+		SELECT `+freezeColumns+`
+		FROM freeze_windows
+		WHERE tenant_id = $1 AND ($2 OR ends_at > NOW())
+		ORDER BY starts_at
+		LIMIT 200`, tenantID, includePast)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var items []*FreezeWindow
+	for rows.Next() {
+		w, err := scanFreeze(rows)
+		if err != nil {
+			return nil, err
+		}
+		items = append(items, w)
+	}
+	return items, rows.Err()
+}
+
+// Active returns the open window of the tenant that ends last, or nil when
+// no freeze is in effect.
+func (s *FreezeStore) Active(ctx context.Context, tenantID uuid.UUID) (*FreezeWindow, error) {
+	row := s.db.Pool.QueryRow(ctx, `
+		SELECT `+freezeColumns+`
+		FROM freeze_windows
+		WHERE tenant_id = $1 AND starts_at <= NOW() AND ends_at > NOW()
+		ORDER BY ends_at DESC
+		LIMIT 1`, tenantID)
+	w, err := scanFreeze(row)
+	if err != nil {
+		if strings.Contains(err.Error(), "not found") {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("check freeze: %w", err)
+	}
+	return w, nil
+}
+
+// Delete removes a window of the tenant, ending it early if it is open.
+func (s *FreezeStore) Delete(ctx context.Context, tenantID, id uuid.UUID) error {
+	tag, err := s.db.Pool.Exec(ctx, `
+		DELETE FROM freeze_windows WHERE id = $1 AND tenant_id = $2`, id, tenantID)
+	if err != nil {
+		return fmt.Errorf("delete freeze window: %w", err)
+	}
+	if tag.RowsAffected() == 0 {
+		return fmt.Errorf("freeze window not found")
+	}
+	return nil
+}
+
+// ─── Private helpers ──────────────────────────────────────────────────────
+
+const freezeColumns = `id, tenant_id, reason, starts_at, ends_at, created_by, created_at`
+
+func scanFreeze(row scanner) (*FreezeWindow, error) {
+	var w FreezeWindow
+	err := row.Scan(&w.ID, &w.TenantID, &w.Reason, &w.StartsAt, &w.EndsAt, &w.CreatedBy, &w.CreatedAt)
+	if err != nil {
+		if err == pgx.ErrNoRows {
+			return nil, fmt.Errorf("freeze window not found")
+		}
+		return nil, err
+	}
+	return &w, nil
+}
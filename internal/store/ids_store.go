@@ -0,0 +1,340 @@
+package store
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+)
+
+// IDSAlert is one persisted Suricata alert.
+type IDSAlert struct {
+	Timestamp    time.Time       `json:"timestamp"`
+	SignatureID  int             `json:"signatureId"`
+	SignatureMsg string          `json:"signature"`
+	Severity     int             `json:"severity"`
+	Category     string          `json:"category"`
+	Action       string          `json:"action"`
+	SrcIP        string          `json:"srcIp"`
+	DstIP        string          `json:"dstIp"`
+	SrcPort      int             `json:"srcPort"`
+	DstPort      int             `json:"dstPort"`
+	Protocol     string          `json:"protocol"`
+	FlowID       int64           `json:"flowId"`
+	Raw          json.RawMessage `json:"-"`
+
+	// Country, ASN and ASOrg describe the remote address; empty or zero
+	// when unknown.
+	Country string `json:"country,omitempty"`
+	ASN     uint32 `json:"asn,omitempty"`
+	ASOrg   string `json:"asOrg,omitempty"`
+}
+
+// AlertAggregate summarises the alerts sharing one grouping key.
+type AlertAggregate struct {
+	SrcIP      string // set for source aggregates
+	Protocol   string // set for port aggregates
+	DstPort    int    // set for port aggregates
+	Count      int64
+	Sources    int
+	Signatures []string
+	FirstSeen  time.Time
+	LastSeen   time.Time
+}
+
+// IDSSuggestion is a proposed firewall rule derived from recurring alerts.
+type IDSSuggestion struct {
+	ID          uuid.UUID  `json:"id"`
+	Kind        string     `json:"kind"`   // block_source | close_port
+	Target      string     `json:"target"` // CIDR, or proto/port
+	AlertCount  int64      `json:"alertCount"`
+	SourceCount int        `json:"sourceCount"`
+	Signatures  []string   `json:"signatures"`
+	FirstSeen   time.Time  `json:"firstSeen"`
+	LastSeen    time.Time  `json:"lastSeen"`
+	Status      string     `json:"status"` // open | accepted | dismissed
+	ChangeID    *uuid.UUID `json:"changeId,omitempty"`
+	CreatedAt   time.Time  `json:"createdAt"`
+	UpdatedAt   time.Time  `json:"updatedAt"`
+}
+
+// IDSStore handles IDS alerts and the suggestions derived from them.
+type IDSStore struct{ db *DB }
+
+func NewIDSStore(db *DB) *IDSStore { return &IDSStore{db: db} }
+
+// InsertAlert persists one alert.
+func (s *IDSStore) InsertAlert(ctx context.Context, a *IDSAlert) error {
+	_, err := s.db.Pool.Exec(ctx, `
+		INSERT INTO ids_alerts
+			(timestamp, signature_id, signature_msg, severity, category, action,
+			 src_ip, dst_ip, src_port, dst_port, protocol, flow_id, raw,
+			 country, asn, as_org)
+		VALUES ($1, $2, $3, $4, $5, $6, $7::inet, $8::inet, $9, $10, $11, $12, $13, $14, $15, $16)`,
+		a.Timestamp, a.SignatureID, a.SignatureMsg, a.Severity, a.Category, a.Action,
+		nullIfEmpty(a.SrcIP), nullIfEmpty(a.DstIP), a.SrcPort, a.DstPort, a.Protocol, a.FlowID, a.Raw,
+		a.Country, nullIfZero(a.ASN), a.ASOrg,
+	)
+	if err != nil {
+		return fmt.Errorf("insert alert: %w", err)
+	}
+	return nil
+}
+
+// EachAlert calls fn with the alerts q selects, newest first, and stops at
+// the first error fn returns.
+func (s *IDSStore) EachAlert(ctx context.Context, q EventQuery, fn func(*IDSAlert) error) error {
+	rows, err := s.db.Pool.Query(ctx, `
+		SELECT timestamp, COALESCE(signature_id, 0), COALESCE(signature_msg, ''),
+		       COALESCE(severity, 0), COALESCE(category, ''), COALESCE(action, ''),
+		       COALESCE(host(src_ip), ''), COALESCE(host(dst_ip), ''),
+		       COALESCE(src_port, 0), COALESCE(dst_port, 0), COALESCE(protocol, ''),
+		       COALESCE(flow_id, 0), country, COALESCE(asn, 0), as_org
+		FROM ids_alerts
+		WHERE timestamp >= $1 AND ($2::timestamptz IS NULL OR timestamp < $2)
+		  AND ($3 = '' OR action = $3)
+		ORDER BY timestamp DESC
+		LIMIT $4`, q.Since, nullIfZeroTime(q.Until), q.Action, q.Limit)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var a IDSAlert
+		if err := rows.Scan(&a.Timestamp, &a.SignatureID, &a.SignatureMsg, &a.Severity,
+			&a.Category, &a.Action, &a.SrcIP, &a.DstIP, &a.SrcPort, &a.DstPort, &a.Protocol,
+			&a.FlowID, &a.Country, &a.ASN, &a.ASOrg); err != nil {
+			return err
+		}
+		if err := fn(&a); err != nil {
+			return err
+		}
+	}
+	return rows.Err()
+}
+
+// SourceAggregates groups alerts since the given time by source address.
+func (s *IDSStore) SourceAggregates(ctx context.Context, since time.Time) ([]*AlertAggregate, error) {
+	rows, err := s.db.Pool.Query(ctx, `
+		SELECT host(src_ip), COUNT(*), 1,
+		       (array_agg(DISTINCT signature_msg))[1:5],
+		       MIN(timestamp), MAX(timestamp)
+		FROM ids_alerts
+		WHERE timestamp >= $1 AND src_ip IS NOT NULL
+		GROUP BY src_ip`, since)
+	if err != nil {
+		return nil, err
+	}
+	return scanAggregates(rows, func(a *AlertAggregate) []any { return []any{&a.SrcIP} })
+}
+
+// PortAggregates groups alerts since the given time by targeted protocol and
+// destination port. Honeypot probes are left out: those ports are traps.
+func (s *IDSStore) PortAggregates(ctx context.Context, since time.Time) ([]*AlertAggregate, error) {
+	rows, err := s.db.Pool.Query(ctx, `
+		SELECT LOWER(protocol), dst_port, COUNT(*), COUNT(DISTINCT src_ip),
+		       (array_agg(DISTINCT signature_msg))[1:5],
+		       MIN(timestamp), MAX(timestamp)
+		FROM ids_alerts
+		WHERE timestamp >= $1 AND dst_port IS NOT NULL AND dst_port > 0
+		  AND LOWER(protocol) IN ('tcp', 'udp')
+		  AND category IS DISTINCT FROM 'Honeypot'
+		GROUP BY LOWER(protocol), dst_port`, since)
+	if err != nil {
+		return nil, err
+	}
+	return scanAggregates(rows, func(a *AlertAggregate) []any { return []any{&a.Protocol, &a.DstPort} })
+}
+
+// UpsertSuggestion records a suggestion, or refreshes its counters while it
+// is still open. Accepted and dismissed suggestions are left untouched.
+func (s *IDSStore) UpsertSuggestion(ctx context.Context, sg *IDSSuggestion) error {
+	_, err := s.db.Pool.Exec(ctx, `
+		INSERT INTO ids_suggestions
+			(kind, target, alert_count, source_count, signatures, first_seen, last_seen)
+		VALUES ($1, $2, $3, $4, $5, $6, $7)
+		ON CONFLICT (kind, target) DO UPDATE SET
+			alert_count  = EXCLUDED.alert_count,
+			source_count = EXCLUDED.source_count,
+			signatures   = EXCLUDED.signatures,
+			last_seen    = EXCLUDED.last_seen,
+			updated_at   = NOW()
+		WHERE ids_suggestions.status = 'open'`,
+		sg.Kind, sg.Target, sg.AlertCount, sg.SourceCount, sg.Signatures, sg.FirstSeen, sg.LastSeen,
+	)
+	if err != nil {
+		return fmt.Errorf("upsert suggestion: %w", err)
+	}
+	return nil
+}
+
+// ListSuggestions returns suggestions with the given status (all if empty),
+// most alerts first.
+func (s *IDSStore) ListSuggestions(ctx context.Context, status string) ([]*IDSSuggestion, error) {
+	rows, err := s.db.Pool.Query(ctx, `
+		SELECT id, kind, target, alert_count, source_count, signatures, first_seen, last_seen,
+		       status, change_id, created_at, updated_at
+		FROM ids_suggestions
+		WHERE $1 = '' OR status = $1
+		ORDER BY alert_count DESC`, status)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var items []*IDSSuggestion
+	for rows.Next() {
+		sg, err := scanSuggestion(rows)
+		if err != nil {
+			return nil, err
+		}
+		items = append(items, sg)
+	}
+	return items, rows.Err()
+}
+
+// GetSuggestion returns one suggestion by ID.
+func (s *IDSStore) GetSuggestion(ctx context.Context, id uuid.UUID) (*IDSSuggestion, error) {
+	row := s.db.Pool.QueryRow(ctx, `
+		SELECT id, kind, target, alert_count, source_count, signatures, first_seen, last_seen,
+		       status, change_id, created_at, updated_at
+		FROM ids_suggestions
+		WHERE id = $1`, id)
+	return scanSuggestion(row)
+}
+
+// ResolveSuggestion moves an open suggestion to accepted or dismissed.
+func (s *IDSStore) ResolveSuggestion(ctx context.Context, id uuid.UUID, status string, changeID *uuid.UUID) error {
+	tag, err := s.db.Pool.Exec(ctx, `
+		UPDATE ids_suggestions
+		SET status = $2, change_id = $3, updated_at = NOW()
+		WHERE id = $1 AND status = 'open'`,
+		id, status, changeID)
+	if err != nil {
+		return fmt.Errorf("resolve suggestion: %w", err)
+	}
+	if tag.RowsAffected() == 0 {
+		return fmt.Errorf("suggestion not found or no longer open")
+	}
+	return nil
+}
+
+// ─── SID overrides ────────────────────────────────────────────────────────
+
+// SIDOverride tunes one upstream signature. Action rewrites the rule
+// (disable, enable, alert, drop); ThresholdType adds a threshold or
+// suppression for it. Either may be empty.
+type SIDOverride struct {
+	GID           int        `json:"gid"`
+	SID           int64      `json:"sid"`
+	Action        string     `json:"action,omitempty"`
+	ThresholdType string     `json:"thresholdType,omitempty"`
+	Track         string     `json:"track,omitempty"`
+	Count         int        `json:"count,omitempty"`
+	Seconds       int        `json:"seconds,omitempty"`
+	Comment       string     `json:"comment"`
+	UpdatedBy     *uuid.UUID `json:"updatedBy"`
+	UpdatedAt     time.Time  `json:"updatedAt"`
+}
+
+// ListSIDOverrides returns every override ordered by signature.
+func (s *IDSStore) ListSIDOverrides(ctx context.Context) ([]*SIDOverride, error) {
+	rows, err := s.db.Pool.Query(ctx, `
+		SELECT gid, sid, action, threshold_type, track, count, seconds, comment, updated_by, updated_at
+		FROM ids_sid_overrides
+		ORDER BY gid, sid`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var items []*SIDOverride
+	for rows.Next() {
+		var o SIDOverride
+		if err := rows.Scan(&o.GID, &o.SID, &o.Action, &o.ThresholdType, &o.Track, &o.Count,
+			&o.Seconds, &o.Comment, &o.UpdatedBy, &o.UpdatedAt); err != nil {
+			return nil, err
+		}
+		items = append(items, &o)
+	}
+	return items, rows.Err()
+}
+
+// PutSIDOverride creates or replaces the override of a signature.
+func (s *IDSStore) PutSIDOverride(ctx context.Context, o *SIDOverride) error {
+	err := s.db.Pool.QueryRow(ctx, `
+		INSERT INTO ids_sid_overrides
+			(gid, sid, action, threshold_type, track, count, seconds, comment, updated_by)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9)
+		ON CONFLICT (gid, sid) DO UPDATE SET
+			action = EXCLUDED.action, threshold_type = EXCLUDED.threshold_type,
+			track = EXCLUDED.track, count = EXCLUDED.count, seconds = EXCLUDED.seconds,
+			comment = EXCLUDED.comment, updated_by = EXCLUDED.updated_by, updated_at = NOW()
+		RETURNING updated_at`,
+		o.GID, o.SID, o.Action, o.ThresholdType, o.Track, o.Count, o.Seconds, o.Comment, o.UpdatedBy,
+	).Scan(&o.UpdatedAt)
+	if err != nil {
+		return fmt.Errorf("put sid override: %w", err)
+	}
+	return nil
+}
+
+// DeleteSIDOverride removes the override of a signature.
+func (s *IDSStore) DeleteSIDOverride(ctx context.Context, gid int, sid int64) error {
+	tag, err := s.db.Pool.Exec(ctx, `
+		DELETE FROM ids_sid_overrides WHERE gid = $1 AND sid = $2`, gid, sid)
+	if err != nil {
+		return fmt.Errorf("delete sid override: %w", err)
+	}
+	if tag.RowsAffected() == 0 {
+		return fmt.Errorf("sid override not found")
+	}
+	return nil
+}
+
+// ─── Private helpers ──────────────────────────────────────────────────────
+
+func scanAggregates(rows pgx.Rows, key func(*AlertAggregate) []any) ([]*AlertAggregate, error) {
+	defer rows.Close()
+	var items []*AlertAggregate
+	for rows.Next() {
+		var a AlertAggregate
+		dest := append(key(&a), &a.Count, &a.Sources, &a.Signatures, &a.FirstSeen, &a.LastSeen)
+		if err := rows.Scan(dest...); err != nil {
+			return nil, err
+		}
+		items = append(items, &a)
+	}
+	return items, rows.Err()
+}
+
+func scanSuggestion(row scanner) (*IDSSuggestion, error) {
+	var sg IDSSuggestion
+	err := row.Scan(&sg.ID, &sg.Kind, &sg.Target, &sg.AlertCount, &sg.SourceCount, &sg.Signatures,
+		&sg.FirstSeen, &sg.LastSeen, &sg.Status, &sg.ChangeID, &sg.CreatedAt, &sg.UpdatedAt)
+	if err != nil {
+		if err == pgx.ErrNoRows {
+			return nil, fmt.Errorf("suggestion not found")
+		}
+		return nil, err
+	}
+	return &sg, nil
+}
+
+func nullIfEmpty(s string) *string {
+	if s == "" {
+		return nil
+	}
+	return &s
+}
+
+func nullIfZero(n uint32) *int64 {
+	if n == 0 {
+		return nil
+	}
+	v := int64(n)
+	return &v
+}
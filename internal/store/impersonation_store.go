@@ -0,0 +1,112 @@
+package store
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+)
+
+// Impersonation is a time-boxed session in which an admin acts inside
+// another tenant.
+type Impersonation struct {
+	ID        uuid.UUID  `json:"id"`
+	ActorID   uuid.UUID  `json:"actorId"`
+	TenantID  uuid.UUID  `json:"tenantId"`
+	Role      string     `json:"role"`
+	Reason    string     `json:"reason"`
+	StartedAt time.Time  `json:"startedAt"`
+	ExpiresAt time.Time  `json:"expiresAt"`
+	EndedAt   *time.Time `json:"endedAt,omitempty"`
+	EndedBy   *uuid.UUID `json:"endedBy,omitempty"`
+}
+
+// Active reports whether the session has neither expired nor been ended.
+func (i *Impersonation) Active() bool {
+	return i.EndedAt == nil && time.Now().Before(i.ExpiresAt)
+}
+
+// ImpersonationStore handles impersonation sessions.
+type ImpersonationStore struct{ db *DB }
+
+func NewImpersonationStore(db *DB) *ImpersonationStore { return &ImpersonationStore{db: db} }
+
+// Create records a new session. The target tenant must exist.
+func (s *ImpersonationStore) Create(ctx context.Context, i *Impersonation) error {
+	err := s.db.Pool.QueryRow(ctx, `
+		INSERT INTO impersonation_sessions (actor_id, tenant_id, role, reason, expires_at)
+		SELECT $1, $2, $3, $4, $5
+		WHERE EXISTS (SELECT 1 FROM tenants WHERE id = $2)
+		RETURNING id, started_at`,
+		i.ActorID, i.TenantID, i.Role, i.Reason, i.ExpiresAt,
+	).Scan(&i.ID, &i.StartedAt)
+	if err != nil {
+		if err == pgx.ErrNoRows {
+			return fmt.Errorf("tenant not found")
+		}
+		return fmt.Errorf("insert impersonation: %w", err)
+	}
+	return nil
+}
+
+// Get returns a session by ID.
+func (s *ImpersonationStore) Get(ctx context.Context, id uuid.UUID) (*Impersonation, error) {
+	row := s.db.Pool.QueryRow(ctx, `
+		SELECT `+impersonationColumns+`
+		FROM impersonation_sessions
+		WHERE id = $1`, id)
+	return scanImpersonation(row)
+}
+
+// List returns the newest sessions, active ones only when activeOnly is set.
+func (s *ImpersonationStore) List(ctx context.Context, activeOnly bool) ([]*Impersonation, error) {
+	rows, err := s.db.Pool.Query(ctx, `
+		SELECT `+impersonationColumns+`
+		FROM impersonation_sessions
+		WHERE NOT $1 OR (ended_at IS NULL AND expires_at > NOW())
+		ORDER BY started_at DESC
+		LIMIT 200`, activeOnly)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var items []*Impersonation
+	for rows.Next() {
+		i, err := scanImpersonation(rows)
+		if err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	return items, rows.Err()
+}
+
+// End closes an active session; its tokens stop working immediately.
+func (s *ImpersonationStore) End(ctx context.Context, id uuid.UUID, by *uuid.UUID) (*Impersonation, error) {
+	row := s.db.Pool.QueryRow(ctx, `
+		UPDATE impersonation_sessions SET ended_at = NOW(), ended_by = $2
+		WHERE id = $1 AND ended_at IS NULL AND expires_at > NOW()
+		RETURNING `+impersonationColumns,
+		id, by)
+	return scanImpersonation(row)
+}
+
+// ─── Private helpers ──────────────────────────────────────────────────────
+
+const impersonationColumns = `id, actor_id, tenant_id, role, reason, started_at, expires_at, ended_at, ended_by`
+
+func scanImpersonation(row scanner) (*Impersonation, error) {
+	var i Impersonation
+	err := row.Scan(&i.ID, &i.ActorID, &i.TenantID, &i.Role, &i.Reason,
+		&i.StartedAt, &i.ExpiresAt, &i.EndedAt, &i.EndedBy)
+	if err != nil {
+		if err == pgx.ErrNoRows {
+			return nil, fmt.Errorf("impersonation not found")
+		}
+		return nil, err
+	}
+	return &i, nil
+}
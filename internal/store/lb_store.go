@@ -0,0 +1,77 @@
+package store
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// LBMaintenance records a backend that an operator has put into maintenance.
+type LBMaintenance struct {
+	TenantID  uuid.UUID  `json:"tenantId"`
+	Backend   string     `json:"backend"`
+	Reason    string     `json:"reason"`
+	StartedBy *uuid.UUID `json:"startedBy"`
+	StartedAt time.Time  `json:"startedAt"`
+}
+
+// LBStore handles load balancer operational state.
+type LBStore struct{ db *DB }
+
+func NewLBStore(db *DB) *LBStore { return &LBStore{db: db} }
+
+// ListMaintenance returns the backends of a tenant that are in maintenance.
+// A nil tenantID returns all of them, which is used to restore state on start.
+func (s *LBStore) ListMaintenance(ctx context.Context, tenantID *uuid.UUID) ([]*LBMaintenance, error) {
+	rows, err := s.db.Pool.Query(ctx, `
+		SELECT tenant_id, backend, reason, started_by, started_at
+		FROM lb_maintenance
+		WHERE $1::uuid IS NULL OR tenant_id = $1
+		ORDER BY backend`, tenantID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var items []*LBMaintenance
+	for rows.Next() {
+		var m LBMaintenance
+		if err := rows.Scan(&m.TenantID, &m.Backend, &m.Reason, &m.StartedBy, &m.StartedAt); err != nil {
+			return nil, err
+		}
+		items = append(items, &m)
+	}
+	return items, rows.Err()
+}
+
+// StartMaintenance marks a backend as in maintenance. Repeating the call
+// updates the reason but keeps the original start time.
+func (s *LBStore) StartMaintenance(ctx context.Context, m *LBMaintenance) error {
+	err := s.db.Pool.QueryRow(ctx, `
+		INSERT INTO lb_maintenance (tenant_id, backend, reason, started_by)
+		VALUES ($1, $2, $3, $4)
+		ON CONFLICT (tenant_id, backend) DO UPDATE SET reason = EXCLUDED.reason
+		RETURNING started_by, started_at`,
+		m.TenantID, m.Backend, m.Reason, m.StartedBy,
+	).Scan(&m.StartedBy, &m.StartedAt)
+	if err != nil {
+		return fmt.Errorf("start maintenance: %w", err)
+	}
+	return nil
+}
+
+// EndMaintenance takes a backend out of maintenance.
+func (s *LBStore) EndMaintenance(ctx context.Context, tenantID uuid.UUID, backend string) error {
+	tag, err := s.db.Pool.Exec(ctx, `
+		DELETE FROM lb_maintenance WHERE tenant_id = $1 AND backend = $2`,
+		tenantID, backend)
+	if err != nil {
+		return fmt.Errorf("end maintenance: %w", err)
+	}
+	if tag.RowsAffected() == 0 {
+		return fmt.Errorf("backend not in maintenance")
+	}
+	return nil
+}
@@ -0,0 +1,120 @@
+package store
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+)
+
+// Reasons a login session ended.
+const (
+	SessionLogout  = "logout"
+	SessionEvicted = "evicted" // the user signed in too many times
+	SessionIdle    = "idle"
+)
+
+// LoginSession is the server-side record of one sign-in, shared by the
+// access and refresh tokens issued for it.
+type LoginSession struct {
+	ID         uuid.UUID  `json:"id"`
+	TenantID   uuid.UUID  `json:"tenantId"`
+	UserID     uuid.UUID  `json:"userId"`
+	CreatedAt  time.Time  `json:"createdAt"`
+	LastSeenAt time.Time  `json:"lastSeenAt"`
+	ExpiresAt  time.Time  `json:"expiresAt"`
+	EndedAt    *time.Time `json:"endedAt,omitempty"`
+	EndReason  string     `json:"endReason,omitempty"`
+}
+
+// Active reports whether the session has neither expired nor been ended.
+func (l *LoginSession) Active() bool {
+	return l.EndedAt == nil && time.Now().Before(l.ExpiresAt)
+}
+
+// LoginSessionStore handles login sessions.
+type LoginSessionStore struct{ db *DB }
+
+func NewLoginSessionStore(db *DB) *LoginSessionStore { return &LoginSessionStore{db: db} }
+
+// Create records a new session. The user's sessions that ended or expired
+// more than a day ago are deleted.
+func (s *LoginSessionStore) Create(ctx context.Context, l *LoginSession) error {
+	if _, err := s.db.Pool.Exec(ctx, `
+		DELETE FROM login_sessions
+		WHERE user_id = $1 AND COALESCE(ended_at, expires_at) < $2`,
+		l.UserID, time.Now().Add(-24*time.Hour)); err != nil {
+		return fmt.Errorf("prune login sessions: %w", err)
+	}
+	err := s.db.Pool.QueryRow(ctx, `
+		INSERT INTO login_sessions (tenant_id, user_id, expires_at)
+		VALUES ($1, $2, $3)
+		RETURNING id, created_at, last_seen_at`,
+		l.TenantID, l.UserID, l.ExpiresAt,
+	).Scan(&l.ID, &l.CreatedAt, &l.LastSeenAt)
+	if err != nil {
+		return fmt.Errorf("insert login session: %w", err)
+	}
+	return nil
+}
+
+// Get returns a session by ID.
+func (s *LoginSessionStore) Get(ctx context.Context, id uuid.UUID) (*LoginSession, error) {
+	row := s.db.Pool.QueryRow(ctx, `
+		SELECT `+loginSessionColumns+`
+		FROM login_sessions
+		WHERE id = $1`, id)
+	return scanLoginSession(row)
+}
+
+// Touch marks an active session as used now.
+func (s *LoginSessionStore) Touch(ctx context.Context, id uuid.UUID) error {
+	_, err := s.db.Pool.Exec(ctx, `
+		UPDATE login_sessions SET last_seen_at = NOW()
+		WHERE id = $1 AND ended_at IS NULL`, id)
+	return err
+}
+
+// End closes an active session for reason; its tokens stop working
+// immediately.
+func (s *LoginSessionStore) End(ctx context.Context, id uuid.UUID, reason string) error {
+	_, err := s.db.Pool.Exec(ctx, `
+		UPDATE login_sessions SET ended_at = NOW(), end_reason = $2
+		WHERE id = $1 AND ended_at IS NULL`, id, reason)
+	return err
+}
+
+// EvictOldest ends the active sessions of userID beyond the newest keep,
+// counting only those used since idleSince, and returns how many it ended.
+func (s *LoginSessionStore) EvictOldest(ctx context.Context, userID uuid.UUID, keep int, idleSince time.Time) (int, error) {
+	tag, err := s.db.Pool.Exec(ctx, `
+		UPDATE login_sessions SET ended_at = NOW(), end_reason = $4
+		WHERE id IN (
+			SELECT id FROM login_sessions
+			WHERE user_id = $1 AND ended_at IS NULL AND expires_at > NOW() AND last_seen_at >= $3
+			ORDER BY created_at DESC
+			OFFSET $2)`,
+		userID, keep, idleSince, SessionEvicted)
+	if err != nil {
+		return 0, fmt.Errorf("evict login sessions: %w", err)
+	}
+	return int(tag.RowsAffected()), nil
+}
+
+// ─── Private helpers ──────────────────────────────────────────────────────
+
+const loginSessionColumns = `id, tenant_id, user_id, created_at, last_seen_at, expires_at, ended_at, COALESCE(end_reason, '')`
+
+func scanLoginSession(row scanner) (*LoginSession, error) {
+	var l LoginSession
+	err := row.Scan(&l.ID, &l.TenantID, &l.UserID, &l.CreatedAt, &l.LastSeenAt, &l.ExpiresAt, &l.EndedAt, &l.EndReason)
+	if err != nil {
+		if err == pgx.ErrNoRows {
+			return nil, fmt.Errorf("login session not found")
+		}
+		return nil, err
+	}
+	return &l, nil
+}
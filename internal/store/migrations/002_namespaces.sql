@@ -0,0 +1,36 @@
+-- AegisX database schema — migration 002
+-- Namespaces: named policy scopes inside a tenant, with RBAC bindings and quotas.
+
+BEGIN;
+
+-- ─── Namespaces ────────────────────────────────────────────────────────────
+CREATE TABLE namespaces (
+    id              UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+    tenant_id       UUID NOT NULL REFERENCES tenants(id) ON DELETE CASCADE,
+    name            TEXT NOT NULL,
+    description     TEXT NOT NULL DEFAULT '',
+    labels          JSONB NOT NULL DEFAULT '{}',
+    max_policies    INT NOT NULL DEFAULT 0,     -- 0 = unlimited
+    max_rules       INT NOT NULL DEFAULT 0,     -- firewall rules per policy, 0 = unlimited
+    created_by      UUID REFERENCES users(id),
+    created_at      TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    updated_at      TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    UNIQUE (tenant_id, name)
+);
+
+CREATE INDEX idx_namespaces_tenant ON namespaces(tenant_id);
+
+-- Per-namespace role bindings. A namespace with at least one binding is
+-- "owned": only bound users (and tenant admins) may see or change its policies.
+CREATE TABLE namespace_bindings (
+    id              UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+    namespace_id    UUID NOT NULL REFERENCES namespaces(id) ON DELETE CASCADE,
+    user_id         UUID NOT NULL,
+    role            TEXT NOT NULL DEFAULT 'viewer',  -- admin|operator|viewer
+    created_at      TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    UNIQUE (namespace_id, user_id)
+);
+
+CREATE INDEX idx_namespace_bindings_user ON namespace_bindings(user_id);
+
+COMMIT;
@@ -0,0 +1,20 @@
+-- AegisX database schema — migration 003
+-- Per-peer WireGuard counters sampled on a fixed interval for bandwidth charts.
+
+BEGIN;
+
+-- ─── VPN peer statistics ───────────────────────────────────────────────────
+-- One row per peer per bucket. rx/tx are the cumulative interface counters
+-- at sample time; rates are derived from deltas when the series is queried.
+CREATE TABLE vpn_peer_stats (
+    peer_id         UUID NOT NULL REFERENCES vpn_peers(id) ON DELETE CASCADE,
+    bucket          TIMESTAMPTZ NOT NULL,
+    rx_bytes        BIGINT NOT NULL,
+    tx_bytes        BIGINT NOT NULL,
+    last_handshake  TIMESTAMPTZ,
+    PRIMARY KEY (peer_id, bucket)
+);
+
+CREATE INDEX idx_vpn_peer_stats_bucket ON vpn_peer_stats(bucket);
+
+COMMIT;
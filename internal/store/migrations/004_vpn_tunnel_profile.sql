@@ -0,0 +1,10 @@
+-- AegisX database schema — migration 004
+-- Per-peer client tunnel profile: split (internal CIDRs only) or full (all traffic).
+
+BEGIN;
+
+ALTER TABLE vpn_peers
+    ADD COLUMN tunnel_profile TEXT NOT NULL DEFAULT 'split'
+        CHECK (tunnel_profile IN ('split', 'full'));
+
+COMMIT;
@@ -0,0 +1,18 @@
+-- AegisX database schema — migration 005
+-- Operator-controlled maintenance windows for load balancer backends.
+
+BEGIN;
+
+-- ─── LB maintenance ────────────────────────────────────────────────────────
+-- A row means the backend is in maintenance: its servers are disabled and
+-- clients get the backend's 503 page. Rows are deleted on exit.
+CREATE TABLE lb_maintenance (
+    tenant_id       UUID NOT NULL REFERENCES tenants(id) ON DELETE CASCADE,
+    backend         VARCHAR(253) NOT NULL,
+    reason          TEXT NOT NULL DEFAULT '',
+    started_by      UUID REFERENCES users(id) ON DELETE SET NULL,
+    started_at      TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    PRIMARY KEY (tenant_id, backend)
+);
+
+COMMIT;
@@ -0,0 +1,54 @@
+-- AegisX database schema — migration 006
+-- Firewall rule suggestions derived from recurring IDS alerts, and the
+-- pending-change queue through which they (and other generated policies)
+-- are approved before they reach the policy store.
+
+BEGIN;
+
+-- ─── Policy change requests ────────────────────────────────────────────────
+-- A proposed policy create/replace awaiting review. Approving it writes the
+-- policy; applying it to the dataplane remains a separate step.
+CREATE TABLE policy_changes (
+    id              UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+    tenant_id       UUID NOT NULL REFERENCES tenants(id) ON DELETE CASCADE,
+    namespace       TEXT NOT NULL DEFAULT 'default',
+    name            TEXT NOT NULL,
+    kind            TEXT NOT NULL,
+    spec            JSONB NOT NULL,
+    raw_yaml        TEXT NOT NULL,
+    reason          TEXT NOT NULL DEFAULT '',
+    source          TEXT NOT NULL DEFAULT 'user',     -- user|ids-suggestion
+    status          TEXT NOT NULL DEFAULT 'pending',  -- pending|approved|rejected
+    policy_id       UUID REFERENCES policies(id) ON DELETE SET NULL,
+    requested_by    UUID REFERENCES users(id) ON DELETE SET NULL,
+    reviewed_by     UUID REFERENCES users(id) ON DELETE SET NULL,
+    review_comment  TEXT NOT NULL DEFAULT '',
+    reviewed_at     TIMESTAMPTZ,
+    created_at      TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX idx_policy_changes_tenant_status ON policy_changes(tenant_id, status);
+
+-- ─── IDS suggestions ───────────────────────────────────────────────────────
+-- One row per (kind, target); counters are refreshed while the suggestion is
+-- open. Accepted and dismissed suggestions keep their row so they are not
+-- proposed again.
+CREATE TABLE ids_suggestions (
+    id              UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+    kind            TEXT NOT NULL,                    -- block_source|close_port
+    target          TEXT NOT NULL,                    -- CIDR, or proto/port
+    alert_count     BIGINT NOT NULL DEFAULT 0,
+    source_count    INT NOT NULL DEFAULT 0,           -- distinct attacking hosts
+    signatures      TEXT[] NOT NULL DEFAULT '{}',
+    first_seen      TIMESTAMPTZ NOT NULL,
+    last_seen       TIMESTAMPTZ NOT NULL,
+    status          TEXT NOT NULL DEFAULT 'open',     -- open|accepted|dismissed
+    change_id       UUID REFERENCES policy_changes(id) ON DELETE SET NULL,
+    created_at      TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    updated_at      TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    UNIQUE (kind, target)
+);
+
+CREATE INDEX idx_ids_suggestions_status ON ids_suggestions(status);
+
+COMMIT;
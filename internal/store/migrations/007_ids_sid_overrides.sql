@@ -0,0 +1,23 @@
+-- AegisX database schema — migration 007
+-- Per-signature tuning applied on top of downloaded Suricata rulesets.
+
+BEGIN;
+
+-- ─── SID overrides ─────────────────────────────────────────────────────────
+-- One row per signature. The action rewrites the rule during the ruleset
+-- build; the optional threshold is written to Suricata's threshold config.
+CREATE TABLE ids_sid_overrides (
+    gid             INT NOT NULL DEFAULT 1,
+    sid             BIGINT NOT NULL,
+    action          TEXT NOT NULL DEFAULT '',         -- ''|disable|enable|alert|drop
+    threshold_type  TEXT NOT NULL DEFAULT '',         -- ''|limit|threshold|both|suppress
+    track           TEXT NOT NULL DEFAULT '',         -- by_src|by_dst
+    count           INT NOT NULL DEFAULT 0,
+    seconds         INT NOT NULL DEFAULT 0,
+    comment         TEXT NOT NULL DEFAULT '',
+    updated_by      UUID REFERENCES users(id) ON DELETE SET NULL,
+    updated_at      TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    PRIMARY KEY (gid, sid)
+);
+
+COMMIT;
@@ -0,0 +1,24 @@
+-- AegisX database schema — migration 008
+-- Addresses banned after repeated authentication failures (SSH, API login).
+
+BEGIN;
+
+-- ─── Bans ──────────────────────────────────────────────────────────────────
+-- A ban is active until it expires or is lifted. Rows are kept afterwards as
+-- history and to escalate repeat offenders.
+CREATE TABLE bans (
+    id              UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+    address         INET NOT NULL,
+    jail            TEXT NOT NULL,                    -- sshd|api|manual
+    reason          TEXT NOT NULL DEFAULT '',
+    failures        INT NOT NULL DEFAULT 0,
+    banned_at       TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    expires_at      TIMESTAMPTZ NOT NULL,
+    lifted_at       TIMESTAMPTZ,
+    lifted_by       UUID REFERENCES users(id) ON DELETE SET NULL
+);
+
+CREATE INDEX idx_bans_active ON bans(address) WHERE lifted_at IS NULL;
+CREATE INDEX idx_bans_expires ON bans(expires_at);
+
+COMMIT;
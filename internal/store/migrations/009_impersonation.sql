@@ -0,0 +1,32 @@
+-- AegisX database schema — migration 009
+-- Time-boxed "act-as tenant" sessions for support engineers, and linking of
+-- audit entries to the session they were made under.
+
+BEGIN;
+
+-- ─── Impersonation sessions ────────────────────────────────────────────────
+-- actor_id has no foreign key: the bootstrap admin has no users row.
+CREATE TABLE impersonation_sessions (
+    id              UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+    actor_id        UUID NOT NULL,
+    tenant_id       UUID NOT NULL REFERENCES tenants(id) ON DELETE CASCADE,
+    role            TEXT NOT NULL,                    -- admin|operator|viewer
+    reason          TEXT NOT NULL,
+    started_at      TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    expires_at      TIMESTAMPTZ NOT NULL,
+    ended_at        TIMESTAMPTZ,
+    ended_by        UUID
+);
+
+CREATE INDEX idx_impersonation_sessions_tenant ON impersonation_sessions(tenant_id);
+
+-- ─── Audit log ─────────────────────────────────────────────────────────────
+-- Audit entries must also name the bootstrap admin and outlive deleted users.
+ALTER TABLE audit_log DROP CONSTRAINT IF EXISTS audit_log_user_id_fkey;
+ALTER TABLE audit_log
+    ADD COLUMN impersonation_id UUID REFERENCES impersonation_sessions(id) ON DELETE SET NULL;
+
+CREATE INDEX idx_audit_log_impersonation ON audit_log(impersonation_id)
+    WHERE impersonation_id IS NOT NULL;
+
+COMMIT;
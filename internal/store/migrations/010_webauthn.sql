@@ -0,0 +1,23 @@
+-- AegisX database schema — migration 010
+-- WebAuthn (passkey) credentials for dashboard logins.
+
+BEGIN;
+
+-- ─── WebAuthn credentials ──────────────────────────────────────────────────
+-- user_id has no foreign key: the bootstrap admin has no users row.
+-- credential holds the verified public key and authenticator data as JSON;
+-- sign_count is kept separately to detect cloned authenticators.
+CREATE TABLE webauthn_credentials (
+    id              UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+    user_id         UUID NOT NULL,
+    name            TEXT NOT NULL DEFAULT '',
+    credential_id   BYTEA NOT NULL UNIQUE,
+    credential      JSONB NOT NULL,
+    sign_count      BIGINT NOT NULL DEFAULT 0,
+    created_at      TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    last_used_at    TIMESTAMPTZ
+);
+
+CREATE INDEX idx_webauthn_credentials_user ON webauthn_credentials(user_id);
+
+COMMIT;
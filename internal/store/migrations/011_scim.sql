@@ -0,0 +1,39 @@
+-- AegisX database schema — migration 011
+-- SCIM 2.0 provisioning: IdP-managed users and groups.
+
+BEGIN;
+
+-- ─── Users ─────────────────────────────────────────────────────────────────
+-- Provisioned users sign in through passkeys or SSO and have no password.
+-- Their names come from the IdP and are unique across tenants, since group
+-- mappings may move a user between tenants. Deprovisioned users are kept,
+-- inactive, because policies and revisions still reference them.
+ALTER TABLE users ALTER COLUMN password_hash SET DEFAULT '';
+ALTER TABLE users
+    ADD COLUMN external_id       TEXT,
+    ADD COLUMN display_name      TEXT NOT NULL DEFAULT '',
+    ADD COLUMN provisioned       BOOLEAN NOT NULL DEFAULT FALSE,
+    ADD COLUMN deprovisioned_at  TIMESTAMPTZ;
+
+CREATE UNIQUE INDEX idx_users_provisioned_username ON users(lower(username))
+    WHERE provisioned AND deprovisioned_at IS NULL;
+
+-- ─── SCIM groups ───────────────────────────────────────────────────────────
+-- Groups only carry membership; config maps group names to tenants and roles.
+CREATE TABLE scim_groups (
+    id              UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+    external_id     TEXT,
+    display_name    TEXT NOT NULL UNIQUE,
+    created_at      TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    updated_at      TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+CREATE TABLE scim_group_members (
+    group_id        UUID NOT NULL REFERENCES scim_groups(id) ON DELETE CASCADE,
+    user_id         UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    PRIMARY KEY (group_id, user_id)
+);
+
+CREATE INDEX idx_scim_group_members_user ON scim_group_members(user_id);
+
+COMMIT;
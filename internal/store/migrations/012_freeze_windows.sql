@@ -0,0 +1,22 @@
+-- AegisX database schema — migration 012
+-- Tenant change freezes: while a window is open, dataplane changes are
+-- refused except under break-glass access.
+
+BEGIN;
+
+-- ─── Freeze windows ────────────────────────────────────────────────────────
+-- created_by has no foreign key: the bootstrap admin has no users row.
+CREATE TABLE freeze_windows (
+    id              UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+    tenant_id       UUID NOT NULL REFERENCES tenants(id) ON DELETE CASCADE,
+    reason          TEXT NOT NULL,
+    starts_at       TIMESTAMPTZ NOT NULL,
+    ends_at         TIMESTAMPTZ NOT NULL,
+    created_by      UUID,
+    created_at      TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    CHECK (ends_at > starts_at)
+);
+
+CREATE INDEX idx_freeze_windows_tenant ON freeze_windows(tenant_id, ends_at);
+
+COMMIT;
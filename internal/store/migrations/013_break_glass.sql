@@ -0,0 +1,27 @@
+-- AegisX database schema — migration 013
+-- Break-glass sessions: time-boxed emergency admin access, granted by a
+-- sealed code or by a second admin.
+
+BEGIN;
+
+-- ─── Break-glass sessions ──────────────────────────────────────────────────
+-- user_id, approved_by and ended_by have no foreign key: the bootstrap admin
+-- has no users row. expires_at is set once the session is granted.
+CREATE TABLE break_glass_sessions (
+    id              UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+    tenant_id       UUID NOT NULL REFERENCES tenants(id) ON DELETE CASCADE,
+    user_id         UUID NOT NULL,
+    reason          TEXT NOT NULL,
+    ttl_seconds     INTEGER NOT NULL CHECK (ttl_seconds > 0),
+    method          TEXT NOT NULL,                    -- code|approval
+    requested_at    TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    approved_by     UUID,
+    granted_at      TIMESTAMPTZ,
+    expires_at      TIMESTAMPTZ,
+    ended_at        TIMESTAMPTZ,
+    ended_by        UUID
+);
+
+CREATE INDEX idx_break_glass_sessions_tenant ON break_glass_sessions(tenant_id, requested_at DESC);
+
+COMMIT;
@@ -0,0 +1,53 @@
+-- AegisX database schema — migration 014
+-- Block lists: addresses and prefixes dropped in both directions, entered by
+-- hand or pulled from subscribed threat feeds.
+
+BEGIN;
+
+-- ─── Block lists ───────────────────────────────────────────────────────────
+-- created_by has no foreign key: the bootstrap admin has no users row.
+CREATE TABLE blocklists (
+    id              UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+    name            TEXT NOT NULL UNIQUE,
+    description     TEXT NOT NULL DEFAULT '',
+    enabled         BOOLEAN NOT NULL DEFAULT TRUE,
+    created_by      UUID,
+    created_at      TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    updated_at      TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+-- ─── Feed subscriptions ────────────────────────────────────────────────────
+-- A feed's entries are replaced wholesale on every successful fetch.
+CREATE TABLE blocklist_feeds (
+    id              UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+    blocklist_id    UUID NOT NULL REFERENCES blocklists(id) ON DELETE CASCADE,
+    url             TEXT NOT NULL,
+    format          TEXT NOT NULL,                    -- plain|cidr|json
+    json_field      TEXT NOT NULL DEFAULT '',         -- object key holding the address; empty for an array of strings
+    refresh_seconds INT NOT NULL CHECK (refresh_seconds > 0),
+    last_fetched_at TIMESTAMPTZ,
+    last_error      TEXT NOT NULL DEFAULT '',
+    entry_count     INT NOT NULL DEFAULT 0,
+    created_by      UUID,
+    created_at      TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    UNIQUE (blocklist_id, url)
+);
+
+-- ─── Entries ───────────────────────────────────────────────────────────────
+-- feed_id is NULL for manual entries.
+CREATE TABLE blocklist_entries (
+    id              UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+    blocklist_id    UUID NOT NULL REFERENCES blocklists(id) ON DELETE CASCADE,
+    feed_id         UUID REFERENCES blocklist_feeds(id) ON DELETE CASCADE,
+    address         CIDR NOT NULL,
+    comment         TEXT NOT NULL DEFAULT '',
+    expires_at      TIMESTAMPTZ,
+    created_by      UUID,
+    created_at      TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX idx_blocklist_entries_list ON blocklist_entries(blocklist_id, created_at);
+CREATE INDEX idx_blocklist_entries_feed ON blocklist_entries(feed_id);
+CREATE INDEX idx_blocklist_entries_expires ON blocklist_entries(expires_at) WHERE expires_at IS NOT NULL;
+
+COMMIT;
@@ -0,0 +1,40 @@
+-- AegisX database schema — migration 015
+-- Packets logged by firewall rules, and country/ASN enrichment of those
+-- events and of IDS alerts for the traffic analytics.
+
+BEGIN;
+
+-- ─── Firewall events ───────────────────────────────────────────────────────
+-- One row per packet logged by a rule with log enabled, read back from the
+-- kernel log. rule is the rule comment (namespace/policy/rule).
+CREATE TABLE firewall_events (
+    id              BIGSERIAL PRIMARY KEY,
+    timestamp       TIMESTAMPTZ NOT NULL,
+    rule            TEXT NOT NULL,
+    action          TEXT NOT NULL,                    -- accept|drop|reject|tarpit|log
+    in_iface        TEXT NOT NULL DEFAULT '',
+    out_iface       TEXT NOT NULL DEFAULT '',
+    src_ip          INET NOT NULL,
+    dst_ip          INET NOT NULL,
+    protocol        TEXT NOT NULL DEFAULT '',
+    src_port        INT,
+    dst_port        INT,
+    country         TEXT NOT NULL DEFAULT '',         -- ISO code of the remote address
+    asn             BIGINT,                           -- AS of the remote address
+    as_org          TEXT NOT NULL DEFAULT ''
+);
+
+CREATE INDEX idx_firewall_events_timestamp ON firewall_events(timestamp DESC);
+CREATE INDEX idx_firewall_events_action_timestamp ON firewall_events(action, timestamp DESC);
+
+-- ─── IDS alert enrichment ──────────────────────────────────────────────────
+-- The remote address is the source, or the destination when the source is
+-- local. Alerts stored before this migration stay unenriched.
+ALTER TABLE ids_alerts
+    ADD COLUMN country TEXT NOT NULL DEFAULT '',
+    ADD COLUMN asn     BIGINT,
+    ADD COLUMN as_org  TEXT NOT NULL DEFAULT '';
+
+CREATE INDEX idx_ids_alerts_action_timestamp ON ids_alerts(action, timestamp DESC);
+
+COMMIT;
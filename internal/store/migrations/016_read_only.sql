@@ -0,0 +1,21 @@
+-- AegisX database schema — migration 016
+-- Read-only switches set through the API, for the whole API or one tenant.
+
+BEGIN;
+
+-- ─── Read-only switches ────────────────────────────────────────────────────
+-- A row means mutating requests are refused. tenant_id NULL is the global
+-- switch; at most one row exists per scope. enabled_by has no foreign key:
+-- the bootstrap admin has no users row.
+CREATE TABLE read_only_switches (
+    id              UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+    tenant_id       UUID REFERENCES tenants(id) ON DELETE CASCADE,
+    reason          TEXT NOT NULL,
+    enabled_by      UUID,
+    created_at      TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+CREATE UNIQUE INDEX idx_read_only_switches_tenant ON read_only_switches(tenant_id) WHERE tenant_id IS NOT NULL;
+CREATE UNIQUE INDEX idx_read_only_switches_global ON read_only_switches((tenant_id IS NULL)) WHERE tenant_id IS NULL;
+
+COMMIT;
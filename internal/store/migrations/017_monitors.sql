@@ -0,0 +1,46 @@
+-- AegisX database schema — migration 017
+-- Synthetic monitors: ICMP/TCP/HTTP/DNS probes from the gateway, managed
+-- through the API, and the history of their state changes.
+
+BEGIN;
+
+-- ─── Monitors ──────────────────────────────────────────────────────────────
+-- A monitor is probed like a HealthCheckPolicy target of the same name, so
+-- rule conditions and WAN checks can refer to it. created_by has no foreign
+-- key: the bootstrap admin has no users row.
+CREATE TABLE monitors (
+    id              UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+    name            TEXT NOT NULL UNIQUE,
+    description     TEXT NOT NULL DEFAULT '',
+    type            TEXT NOT NULL,                    -- icmp|tcp|http|dns
+    address         TEXT NOT NULL,                    -- host, host:port, URL, or DNS server
+    interface       TEXT NOT NULL DEFAULT '',
+    interval_ms     INT NOT NULL CHECK (interval_ms > 0),
+    timeout_ms      INT NOT NULL CHECK (timeout_ms > 0),
+    rise            INT NOT NULL DEFAULT 2 CHECK (rise > 0),
+    fall            INT NOT NULL DEFAULT 3 CHECK (fall > 0),
+    expect_status   INT NOT NULL DEFAULT 0,           -- http: 0 accepts any status below 400
+    query           TEXT NOT NULL DEFAULT '',         -- dns: name to resolve
+    record_type     TEXT NOT NULL DEFAULT '',         -- dns: A|AAAA
+    expect_address  TEXT NOT NULL DEFAULT '',         -- dns: address the answer must contain
+    enabled         BOOLEAN NOT NULL DEFAULT TRUE,
+    created_by      UUID,
+    created_at      TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    updated_at      TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+-- ─── State changes ─────────────────────────────────────────────────────────
+-- One row each time a monitor settles up or down.
+CREATE TABLE monitor_events (
+    id              BIGSERIAL PRIMARY KEY,
+    monitor_id      UUID NOT NULL REFERENCES monitors(id) ON DELETE CASCADE,
+    up              BOOLEAN NOT NULL,
+    error           TEXT NOT NULL DEFAULT '',
+    latency_ms      DOUBLE PRECISION NOT NULL DEFAULT 0,
+    at              TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX idx_monitor_events_monitor ON monitor_events(monitor_id, at DESC);
+CREATE INDEX idx_monitor_events_at ON monitor_events(at);
+
+COMMIT;
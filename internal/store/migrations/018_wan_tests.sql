@@ -0,0 +1,31 @@
+-- AegisX database schema — migration 018
+-- WAN quality tests: latency, jitter, loss and throughput of each uplink,
+-- measured on demand or on a schedule.
+
+BEGIN;
+
+-- ─── WAN tests ─────────────────────────────────────────────────────────────
+-- One row per uplink and run. A failed measurement keeps the metrics that
+-- were taken before it and its error. created_by is NULL for scheduled runs
+-- and has no foreign key: the bootstrap admin has no users row.
+CREATE TABLE wan_tests (
+    id              BIGSERIAL PRIMARY KEY,
+    uplink          TEXT NOT NULL,                    -- WAN uplink name; "default" without a WANPolicy
+    interface       TEXT NOT NULL DEFAULT '',
+    method          TEXT NOT NULL,                    -- http|iperf3
+    trigger         TEXT NOT NULL,                    -- manual|scheduled
+    latency_ms      DOUBLE PRECISION,
+    jitter_ms       DOUBLE PRECISION,
+    loss_pct        DOUBLE PRECISION,
+    download_mbps   DOUBLE PRECISION,
+    upload_mbps     DOUBLE PRECISION,
+    error           TEXT NOT NULL DEFAULT '',
+    duration_ms     INT NOT NULL DEFAULT 0,
+    created_by      UUID,
+    started_at      TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX idx_wan_tests_uplink ON wan_tests(uplink, started_at DESC);
+CREATE INDEX idx_wan_tests_started ON wan_tests(started_at);
+
+COMMIT;
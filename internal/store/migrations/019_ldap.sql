@@ -0,0 +1,16 @@
+-- AegisX database schema — migration 019
+-- LDAP / Active Directory: users and groups synced from a directory next to
+-- those provisioned through SCIM.
+
+BEGIN;
+
+-- ─── Sources ───────────────────────────────────────────────────────────────
+-- Each provisioned user and group belongs to the connector that manages it,
+-- so a sync only updates and removes its own records. For LDAP records
+-- external_id holds the entry's DN.
+ALTER TABLE users ADD COLUMN source TEXT NOT NULL DEFAULT 'scim';  -- scim|ldap
+ALTER TABLE scim_groups ADD COLUMN source TEXT NOT NULL DEFAULT 'scim';
+
+CREATE INDEX idx_users_source ON users(source) WHERE provisioned AND deprovisioned_at IS NULL;
+
+COMMIT;
@@ -0,0 +1,22 @@
+-- AegisX database schema — migration 020
+-- Server-side login sessions, for idle expiry and per-user session limits.
+
+BEGIN;
+
+-- ─── Login sessions ────────────────────────────────────────────────────────
+-- user_id has no foreign key: the bootstrap admin has no users row.
+-- expires_at is that of the session's refresh token.
+CREATE TABLE login_sessions (
+    id              UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+    tenant_id       UUID NOT NULL REFERENCES tenants(id) ON DELETE CASCADE,
+    user_id         UUID NOT NULL,
+    created_at      TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    last_seen_at    TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    expires_at      TIMESTAMPTZ NOT NULL,
+    ended_at        TIMESTAMPTZ,
+    end_reason      TEXT                              -- logout|evicted|idle
+);
+
+CREATE INDEX idx_login_sessions_user ON login_sessions(user_id, created_at DESC);
+
+COMMIT;
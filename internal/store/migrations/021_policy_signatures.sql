@@ -0,0 +1,15 @@
+-- AegisX database schema — migration 021
+-- Signed policy manifests: the detached signature each policy was written
+-- with and the trusted key it verified against.
+
+BEGIN;
+
+-- ─── Policy provenance ─────────────────────────────────────────────────────
+-- signed_by is the SHA-256 of the public key; NULL when the signature was
+-- not verified, e.g. because no signing keys were configured.
+ALTER TABLE policies
+    ADD COLUMN signature    TEXT NOT NULL DEFAULT '',
+    ADD COLUMN signed_by    TEXT,
+    ADD COLUMN verified_at  TIMESTAMPTZ;
+
+COMMIT;
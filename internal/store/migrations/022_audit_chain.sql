@@ -0,0 +1,89 @@
+-- AegisX database schema — migration 022
+-- Tamper-evident audit log: every entry carries the hash of the one before
+-- it, and entries can no longer be changed once written.
+
+BEGIN;
+
+-- ─── Immutable entries ─────────────────────────────────────────────────────
+-- Entries keep the IDs of deleted tenants and impersonation sessions rather
+-- than being rewritten to NULL, which would break their hashes.
+ALTER TABLE audit_log DROP CONSTRAINT IF EXISTS audit_log_tenant_id_fkey;
+ALTER TABLE audit_log DROP CONSTRAINT IF EXISTS audit_log_impersonation_id_fkey;
+
+ALTER TABLE audit_log
+    ADD COLUMN seq          BIGINT,
+    ADD COLUMN prev_hash    TEXT,       -- hash of entry seq - 1; NULL for the first
+    ADD COLUMN hash         TEXT;
+
+-- audit_log_hash is the SHA-256 of every column of e but hash, in a
+-- canonical JSON encoding.
+CREATE FUNCTION audit_log_hash(e audit_log) RETURNS TEXT AS $$
+    SELECT encode(sha256(convert_to(jsonb_build_array(
+        e.seq, e.prev_hash, e.id, e.tenant_id, e.user_id, e.impersonation_id,
+        e.action, e.resource, e.resource_id, e.detail, host(e.ip_address),
+        e.user_agent, e.status,
+        to_char(e.created_at AT TIME ZONE 'UTC', 'YYYY-MM-DD"T"HH24:MI:SS.US')
+    )::text, 'UTF8')), 'hex')
+$$ LANGUAGE sql STABLE;
+
+-- ─── Chain head ────────────────────────────────────────────────────────────
+-- The last entry written. It survives retention pruning, so the chain goes
+-- on from where it was, and shows when the newest entries are deleted.
+CREATE TABLE audit_log_head (
+    id          BOOLEAN PRIMARY KEY DEFAULT TRUE CHECK (id),
+    seq         BIGINT NOT NULL,
+    hash        TEXT
+);
+
+-- Chain the existing entries in the order they were written.
+DO $$
+DECLARE
+    r    RECORD;
+    n    BIGINT := 0;
+    prev TEXT;
+BEGIN
+    FOR r IN SELECT id FROM audit_log ORDER BY created_at, id LOOP
+        n := n + 1;
+        UPDATE audit_log SET seq = n, prev_hash = prev WHERE id = r.id;
+        UPDATE audit_log a SET hash = audit_log_hash(a) WHERE a.id = r.id
+            RETURNING a.hash INTO prev;
+    END LOOP;
+    INSERT INTO audit_log_head (seq, hash) VALUES (n, prev);
+END $$;
+
+ALTER TABLE audit_log
+    ALTER COLUMN seq SET NOT NULL,
+    ALTER COLUMN hash SET NOT NULL;
+
+CREATE UNIQUE INDEX idx_audit_log_seq ON audit_log(seq);
+
+-- ─── Triggers ──────────────────────────────────────────────────────────────
+-- Locking the head row serializes writers, so each entry links to the one
+-- committed before it. Dating entries once the lock is held keeps created_at
+-- in seq order, so retention prunes a prefix of the chain.
+CREATE FUNCTION audit_log_chain() RETURNS trigger AS $$
+DECLARE
+    head audit_log_head%ROWTYPE;
+BEGIN
+    SELECT * INTO head FROM audit_log_head FOR UPDATE;
+    NEW.created_at := clock_timestamp();
+    NEW.seq := head.seq + 1;
+    NEW.prev_hash := head.hash;
+    NEW.hash := audit_log_hash(NEW);
+    UPDATE audit_log_head SET seq = NEW.seq, hash = NEW.hash;
+    RETURN NEW;
+END
+$$ LANGUAGE plpgsql;
+
+CREATE FUNCTION audit_log_immutable() RETURNS trigger AS $$
+BEGIN
+    RAISE EXCEPTION 'audit_log entries cannot be changed';
+END
+$$ LANGUAGE plpgsql;
+
+CREATE TRIGGER audit_log_chain BEFORE INSERT ON audit_log
+    FOR EACH ROW EXECUTE FUNCTION audit_log_chain();
+CREATE TRIGGER audit_log_immutable BEFORE UPDATE ON audit_log
+    FOR EACH ROW EXECUTE FUNCTION audit_log_immutable();
+
+COMMIT;
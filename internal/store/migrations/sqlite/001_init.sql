@@ -0,0 +1,617 @@
+-- AegisX SQLite schema — migration 001
+-- The PostgreSQL schema of migrations 001 to 022 in one file, for the
+-- appliance profile. Column types keep their PostgreSQL names; the store
+-- reads them back through the same adapter (see internal/store/sqlite.go):
+--   UUID, INET, CIDR      text
+--   TIMESTAMPTZ           UTC text, 2006-01-02T15:04:05.000000Z
+--   JSONB                 JSON text; PostgreSQL arrays are JSON arrays
+--   BOOLEAN               0 or 1
+-- now() and gen_random_uuid() are registered by the store.
+
+-- ─── Tenants ───────────────────────────────────────────────────────────────
+CREATE TABLE tenants (
+    id          UUID PRIMARY KEY DEFAULT (gen_random_uuid()),
+    name        TEXT NOT NULL UNIQUE,
+    slug        TEXT NOT NULL UNIQUE,
+    settings    JSONB NOT NULL DEFAULT '{}',
+    created_at  TIMESTAMPTZ NOT NULL DEFAULT (now()),
+    updated_at  TIMESTAMPTZ NOT NULL DEFAULT (now()),
+    deleted_at  TIMESTAMPTZ
+);
+
+-- ─── Users / Auth ──────────────────────────────────────────────────────────
+CREATE TABLE users (
+    id                UUID PRIMARY KEY DEFAULT (gen_random_uuid()),
+    tenant_id         UUID NOT NULL REFERENCES tenants(id) ON DELETE CASCADE,
+    username          TEXT NOT NULL,
+    email             TEXT NOT NULL,
+    password_hash     TEXT NOT NULL DEFAULT '',    -- bcrypt
+    role              TEXT NOT NULL DEFAULT 'viewer',  -- admin|operator|viewer
+    active            BOOLEAN NOT NULL DEFAULT TRUE,
+    last_login_at     TIMESTAMPTZ,
+    created_at        TIMESTAMPTZ NOT NULL DEFAULT (now()),
+    updated_at        TIMESTAMPTZ NOT NULL DEFAULT (now()),
+    external_id       TEXT,
+    display_name      TEXT NOT NULL DEFAULT '',
+    provisioned       BOOLEAN NOT NULL DEFAULT FALSE,
+    deprovisioned_at  TIMESTAMPTZ,
+    source            TEXT NOT NULL DEFAULT 'scim',  -- scim|ldap
+    UNIQUE (tenant_id, username),
+    UNIQUE (tenant_id, email)
+);
+
+CREATE INDEX idx_users_tenant ON users(tenant_id);
+CREATE UNIQUE INDEX idx_users_provisioned_username ON users(lower(username))
+    WHERE provisioned AND deprovisioned_at IS NULL;
+CREATE INDEX idx_users_source ON users(source) WHERE provisioned AND deprovisioned_at IS NULL;
+
+-- ─── API Tokens ────────────────────────────────────────────────────────────
+CREATE TABLE api_tokens (
+    id          UUID PRIMARY KEY DEFAULT (gen_random_uuid()),
+    user_id     UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    name        TEXT NOT NULL,
+    token_hash  TEXT NOT NULL UNIQUE,   -- sha256
+    scopes      JSONB NOT NULL DEFAULT '[]',
+    expires_at  TIMESTAMPTZ,
+    last_used   TIMESTAMPTZ,
+    created_at  TIMESTAMPTZ NOT NULL DEFAULT (now())
+);
+
+-- ─── Policies ──────────────────────────────────────────────────────────────
+CREATE TABLE policies (
+    id          UUID PRIMARY KEY DEFAULT (gen_random_uuid()),
+    tenant_id   UUID NOT NULL REFERENCES tenants(id) ON DELETE CASCADE,
+    name        TEXT NOT NULL,
+    namespace   TEXT NOT NULL DEFAULT 'default',
+    kind        TEXT NOT NULL,          -- FirewallPolicy|LoadBalancerPolicy|etc
+    version     INT NOT NULL DEFAULT 1,
+    spec        JSONB NOT NULL,         -- full YAML spec as JSON
+    raw_yaml    TEXT,                   -- original YAML source
+    enabled     BOOLEAN NOT NULL DEFAULT TRUE,
+    applied_at  TIMESTAMPTZ,
+    created_by  UUID REFERENCES users(id),
+    created_at  TIMESTAMPTZ NOT NULL DEFAULT (now()),
+    updated_at  TIMESTAMPTZ NOT NULL DEFAULT (now()),
+    deleted_at  TIMESTAMPTZ,
+    signature   TEXT NOT NULL DEFAULT '',
+    signed_by   TEXT,
+    verified_at TIMESTAMPTZ,
+    UNIQUE (tenant_id, namespace, name)
+);
+
+CREATE INDEX idx_policies_tenant ON policies(tenant_id);
+CREATE INDEX idx_policies_kind ON policies(kind);
+CREATE INDEX idx_policies_enabled ON policies(enabled) WHERE enabled = TRUE;
+
+-- Policy version history
+CREATE TABLE policy_revisions (
+    id          UUID PRIMARY KEY DEFAULT (gen_random_uuid()),
+    policy_id   UUID NOT NULL REFERENCES policies(id) ON DELETE CASCADE,
+    version     INT NOT NULL,
+    spec        JSONB NOT NULL,
+    raw_yaml    TEXT,
+    changed_by  UUID REFERENCES users(id),
+    changed_at  TIMESTAMPTZ NOT NULL DEFAULT (now()),
+    comment     TEXT,
+    UNIQUE (policy_id, version)
+);
+
+CREATE INDEX idx_policy_revisions_policy ON policy_revisions(policy_id);
+
+-- ─── Compiled IR snapshots ─────────────────────────────────────────────────
+CREATE TABLE ir_snapshots (
+    id          UUID PRIMARY KEY,       -- matches IR.ID
+    tenant_id   UUID NOT NULL REFERENCES tenants(id) ON DELETE CASCADE,
+    version     BIGINT NOT NULL,
+    ir          JSONB NOT NULL,
+    applied     BOOLEAN NOT NULL DEFAULT FALSE,
+    applied_at  TIMESTAMPTZ,
+    created_at  TIMESTAMPTZ NOT NULL DEFAULT (now())
+);
+
+CREATE INDEX idx_ir_snapshots_tenant ON ir_snapshots(tenant_id, applied);
+
+-- ─── Firewall Rules (denormalized for fast reads) ──────────────────────────
+CREATE TABLE firewall_rules (
+    id          UUID PRIMARY KEY DEFAULT (gen_random_uuid()),
+    tenant_id   UUID NOT NULL REFERENCES tenants(id) ON DELETE CASCADE,
+    policy_id   UUID REFERENCES policies(id) ON DELETE SET NULL,
+    name        TEXT NOT NULL,
+    priority    INT NOT NULL DEFAULT 100,
+    chain       TEXT NOT NULL,          -- input|forward|output
+    action      TEXT NOT NULL,          -- accept|drop|reject
+    protocol    TEXT,
+    src_addrs   JSONB,
+    dst_addrs   JSONB,
+    src_ports   JSONB,
+    dst_ports   JSONB,
+    states      JSONB,
+    rate_limit  TEXT,
+    log         BOOLEAN NOT NULL DEFAULT FALSE,
+    comment     TEXT,
+    enabled     BOOLEAN NOT NULL DEFAULT TRUE,
+    created_at  TIMESTAMPTZ NOT NULL DEFAULT (now())
+);
+
+CREATE INDEX idx_firewall_rules_tenant ON firewall_rules(tenant_id);
+CREATE INDEX idx_firewall_rules_priority ON firewall_rules(priority);
+
+-- ─── Impersonation ─────────────────────────────────────────────────────────
+CREATE TABLE impersonation_sessions (
+    id              UUID PRIMARY KEY DEFAULT (gen_random_uuid()),
+    actor_id        UUID NOT NULL,
+    tenant_id       UUID NOT NULL REFERENCES tenants(id) ON DELETE CASCADE,
+    role            TEXT NOT NULL,                    -- admin|operator|viewer
+    reason          TEXT NOT NULL,
+    started_at      TIMESTAMPTZ NOT NULL DEFAULT (now()),
+    expires_at      TIMESTAMPTZ NOT NULL,
+    ended_at        TIMESTAMPTZ,
+    ended_by        UUID
+);
+
+CREATE INDEX idx_impersonation_sessions_tenant ON impersonation_sessions(tenant_id);
+
+-- ─── Audit Log ────────────────────────────────────────────────────────────
+-- Entries form a hash chain as in PostgreSQL. SQLite triggers cannot set
+-- the columns of the row being inserted, so audit_log_chain fills in seq,
+-- prev_hash, hash and created_at right after the insert; until then hash
+-- is NULL, which is also what lets that one update through
+-- audit_log_immutable. audit_log_hash is registered by the store.
+CREATE TABLE audit_log (
+    id               UUID PRIMARY KEY DEFAULT (gen_random_uuid()),
+    tenant_id        UUID,
+    user_id          UUID,
+    action           TEXT NOT NULL,      -- CREATE_POLICY | APPLY_POLICY | ROLLBACK | etc
+    resource         TEXT,               -- resource type
+    resource_id      TEXT,               -- resource uuid
+    detail           JSONB,
+    ip_address       INET,
+    user_agent       TEXT,
+    status           TEXT NOT NULL DEFAULT 'success',  -- success|failure
+    created_at       TIMESTAMPTZ NOT NULL DEFAULT (now()),
+    impersonation_id UUID,
+    seq              BIGINT,
+    prev_hash        TEXT,               -- hash of entry seq - 1; NULL for the first
+    hash             TEXT
+);
+
+CREATE INDEX idx_audit_log_tenant ON audit_log(tenant_id);
+CREATE INDEX idx_audit_log_created ON audit_log(created_at DESC);
+CREATE INDEX idx_audit_log_action ON audit_log(action);
+CREATE INDEX idx_audit_log_impersonation ON audit_log(impersonation_id)
+    WHERE impersonation_id IS NOT NULL;
+CREATE UNIQUE INDEX idx_audit_log_seq ON audit_log(seq);
+
+CREATE TABLE audit_log_head (
+    id          BOOLEAN PRIMARY KEY DEFAULT TRUE CHECK (id),
+    seq         BIGINT NOT NULL,
+    hash        TEXT
+);
+
+INSERT INTO audit_log_head (id, seq, hash) VALUES (TRUE, 0, NULL);
+
+CREATE TRIGGER audit_log_chain AFTER INSERT ON audit_log
+BEGIN
+    UPDATE audit_log
+    SET created_at = now(),
+        seq = (SELECT seq + 1 FROM audit_log_head),
+        prev_hash = (SELECT hash FROM audit_log_head)
+    WHERE rowid = NEW.rowid;
+    UPDATE audit_log
+    SET hash = audit_log_hash(seq, prev_hash, id, tenant_id, user_id, impersonation_id,
+                              action, resource, resource_id, detail, ip_address,
+                              user_agent, status, created_at)
+    WHERE rowid = NEW.rowid;
+    UPDATE audit_log_head
+    SET seq = (SELECT seq FROM audit_log WHERE rowid = NEW.rowid),
+        hash = (SELECT hash FROM audit_log WHERE rowid = NEW.rowid);
+END;
+
+CREATE TRIGGER audit_log_immutable BEFORE UPDATE ON audit_log
+WHEN OLD.hash IS NOT NULL
+BEGIN
+    SELECT RAISE(ABORT, 'audit_log entries cannot be changed');
+END;
+
+-- ─── IDS Alerts ────────────────────────────────────────────────────────────
+CREATE TABLE ids_alerts (
+    id              UUID PRIMARY KEY DEFAULT (gen_random_uuid()),
+    tenant_id       UUID REFERENCES tenants(id) ON DELETE SET NULL,
+    timestamp       TIMESTAMPTZ NOT NULL,
+    signature_id    BIGINT,
+    signature_msg   TEXT,
+    severity        INT,                -- 1=high, 2=med, 3=low
+    category        TEXT,
+    action          TEXT,               -- allowed|blocked
+    src_ip          INET,
+    dst_ip          INET,
+    src_port        INT,
+    dst_port        INT,
+    protocol        TEXT,
+    flow_id         BIGINT,
+    payload_b64     TEXT,
+    raw             JSONB,
+    created_at      TIMESTAMPTZ NOT NULL DEFAULT (now()),
+    country         TEXT NOT NULL DEFAULT '',
+    asn             BIGINT,
+    as_org          TEXT NOT NULL DEFAULT ''
+);
+
+CREATE INDEX idx_ids_alerts_tenant ON ids_alerts(tenant_id);
+CREATE INDEX idx_ids_alerts_timestamp ON ids_alerts(timestamp DESC);
+CREATE INDEX idx_ids_alerts_severity ON ids_alerts(severity);
+CREATE INDEX idx_ids_alerts_src_ip ON ids_alerts(src_ip);
+CREATE INDEX idx_ids_alerts_action_timestamp ON ids_alerts(action, timestamp DESC);
+
+-- ─── VPN Peers ────────────────────────────────────────────────────────────
+CREATE TABLE vpn_peers (
+    id              UUID PRIMARY KEY DEFAULT (gen_random_uuid()),
+    tenant_id       UUID NOT NULL REFERENCES tenants(id) ON DELETE CASCADE,
+    name            TEXT NOT NULL,
+    public_key      TEXT NOT NULL UNIQUE,
+    preshared_key   TEXT,               -- encrypted at rest
+    allowed_ips     JSONB,
+    endpoint        TEXT,
+    keepalive       INT DEFAULT 25,
+    active          BOOLEAN NOT NULL DEFAULT TRUE,
+    last_handshake  TIMESTAMPTZ,
+    created_at      TIMESTAMPTZ NOT NULL DEFAULT (now()),
+    tunnel_profile  TEXT NOT NULL DEFAULT 'split'
+        CHECK (tunnel_profile IN ('split', 'full')),
+    UNIQUE (tenant_id, name)
+);
+
+CREATE TABLE vpn_peer_stats (
+    peer_id         UUID NOT NULL REFERENCES vpn_peers(id) ON DELETE CASCADE,
+    bucket          TIMESTAMPTZ NOT NULL,
+    rx_bytes        BIGINT NOT NULL,
+    tx_bytes        BIGINT NOT NULL,
+    last_handshake  TIMESTAMPTZ,
+    PRIMARY KEY (peer_id, bucket)
+);
+
+CREATE INDEX idx_vpn_peer_stats_bucket ON vpn_peer_stats(bucket);
+
+-- ─── Load Balancer ─────────────────────────────────────────────────────────
+CREATE TABLE lb_backends (
+    id              UUID PRIMARY KEY DEFAULT (gen_random_uuid()),
+    tenant_id       UUID NOT NULL REFERENCES tenants(id) ON DELETE CASCADE,
+    policy_id       UUID REFERENCES policies(id) ON DELETE CASCADE,
+    name            TEXT NOT NULL,
+    address         TEXT NOT NULL,
+    port            INT NOT NULL,
+    weight          INT NOT NULL DEFAULT 1,
+    enabled         BOOLEAN NOT NULL DEFAULT TRUE,
+    health_status   TEXT NOT NULL DEFAULT 'unknown',  -- up|down|unknown
+    last_check      TIMESTAMPTZ,
+    created_at      TIMESTAMPTZ NOT NULL DEFAULT (now())
+);
+
+CREATE INDEX idx_lb_backends_tenant ON lb_backends(tenant_id);
+CREATE INDEX idx_lb_backends_policy ON lb_backends(policy_id);
+
+CREATE TABLE lb_maintenance (
+    tenant_id       UUID NOT NULL REFERENCES tenants(id) ON DELETE CASCADE,
+    backend         VARCHAR(253) NOT NULL,
+    reason          TEXT NOT NULL DEFAULT '',
+    started_by      UUID REFERENCES users(id) ON DELETE SET NULL,
+    started_at      TIMESTAMPTZ NOT NULL DEFAULT (now()),
+    PRIMARY KEY (tenant_id, backend)
+);
+
+-- ─── Metrics Snapshots ─────────────────────────────────────────────────────
+CREATE TABLE metrics_snapshots (
+    id              UUID PRIMARY KEY DEFAULT (gen_random_uuid()),
+    tenant_id       UUID REFERENCES tenants(id) ON DELETE SET NULL,
+    timestamp       TIMESTAMPTZ NOT NULL,
+    metric_name     TEXT NOT NULL,
+    labels          JSONB,
+    value           DOUBLE PRECISION NOT NULL
+);
+
+CREATE INDEX idx_metrics_snapshots_tenant_time ON metrics_snapshots(tenant_id, timestamp DESC);
+CREATE INDEX idx_metrics_snapshots_name ON metrics_snapshots(metric_name);
+
+-- ─── Namespaces ────────────────────────────────────────────────────────────
+CREATE TABLE namespaces (
+    id              UUID PRIMARY KEY DEFAULT (gen_random_uuid()),
+    tenant_id       UUID NOT NULL REFERENCES tenants(id) ON DELETE CASCADE,
+    name            TEXT NOT NULL,
+    description     TEXT NOT NULL DEFAULT '',
+    labels          JSONB NOT NULL DEFAULT '{}',
+    max_policies    INT NOT NULL DEFAULT 0,     -- 0 = unlimited
+    max_rules       INT NOT NULL DEFAULT 0,     -- firewall rules per policy, 0 = unlimited
+    created_by      UUID REFERENCES users(id),
+    created_at      TIMESTAMPTZ NOT NULL DEFAULT (now()),
+    updated_at      TIMESTAMPTZ NOT NULL DEFAULT (now()),
+    UNIQUE (tenant_id, name)
+);
+
+CREATE INDEX idx_namespaces_tenant ON namespaces(tenant_id);
+
+CREATE TABLE namespace_bindings (
+    id              UUID PRIMARY KEY DEFAULT (gen_random_uuid()),
+    namespace_id    UUID NOT NULL REFERENCES namespaces(id) ON DELETE CASCADE,
+    user_id         UUID NOT NULL,
+    role            TEXT NOT NULL DEFAULT 'viewer',  -- admin|operator|viewer
+    created_at      TIMESTAMPTZ NOT NULL DEFAULT (now()),
+    UNIQUE (namespace_id, user_id)
+);
+
+CREATE INDEX idx_namespace_bindings_user ON namespace_bindings(user_id);
+
+-- ─── Change requests and IDS suggestions ───────────────────────────────────
+CREATE TABLE policy_changes (
+    id              UUID PRIMARY KEY DEFAULT (gen_random_uuid()),
+    tenant_id       UUID NOT NULL REFERENCES tenants(id) ON DELETE CASCADE,
+    namespace       TEXT NOT NULL DEFAULT 'default',
+    name            TEXT NOT NULL,
+    kind            TEXT NOT NULL,
+    spec            JSONB NOT NULL,
+    raw_yaml        TEXT NOT NULL,
+    reason          TEXT NOT NULL DEFAULT '',
+    source          TEXT NOT NULL DEFAULT 'user',     -- user|ids-suggestion
+    status          TEXT NOT NULL DEFAULT 'pending',  -- pending|approved|rejected
+    policy_id       UUID REFERENCES policies(id) ON DELETE SET NULL,
+    requested_by    UUID REFERENCES users(id) ON DELETE SET NULL,
+    reviewed_by     UUID REFERENCES users(id) ON DELETE SET NULL,
+    review_comment  TEXT NOT NULL DEFAULT '',
+    reviewed_at     TIMESTAMPTZ,
+    created_at      TIMESTAMPTZ NOT NULL DEFAULT (now())
+);
+
+CREATE INDEX idx_policy_changes_tenant_status ON policy_changes(tenant_id, status);
+
+CREATE TABLE ids_suggestions (
+    id              UUID PRIMARY KEY DEFAULT (gen_random_uuid()),
+    kind            TEXT NOT NULL,                    -- block_source|close_port
+    target          TEXT NOT NULL,                    -- CIDR, or proto/port
+    alert_count     BIGINT NOT NULL DEFAULT 0,
+    source_count    INT NOT NULL DEFAULT 0,           -- distinct attacking hosts
+    signatures      JSONB NOT NULL DEFAULT '[]',
+    first_seen      TIMESTAMPTZ NOT NULL,
+    last_seen       TIMESTAMPTZ NOT NULL,
+    status          TEXT NOT NULL DEFAULT 'open',     -- open|accepted|dismissed
+    change_id       UUID REFERENCES policy_changes(id) ON DELETE SET NULL,
+    created_at      TIMESTAMPTZ NOT NULL DEFAULT (now()),
+    updated_at      TIMESTAMPTZ NOT NULL DEFAULT (now()),
+    UNIQUE (kind, target)
+);
+
+CREATE INDEX idx_ids_suggestions_status ON ids_suggestions(status);
+
+CREATE TABLE ids_sid_overrides (
+    gid             INT NOT NULL DEFAULT 1,
+    sid             BIGINT NOT NULL,
+    action          TEXT NOT NULL DEFAULT '',         -- ''|disable|enable|alert|drop
+    threshold_type  TEXT NOT NULL DEFAULT '',         -- ''|limit|threshold|both|suppress
+    track           TEXT NOT NULL DEFAULT '',         -- by_src|by_dst
+    count           INT NOT NULL DEFAULT 0,
+    seconds         INT NOT NULL DEFAULT 0,
+    comment         TEXT NOT NULL DEFAULT '',
+    updated_by      UUID REFERENCES users(id) ON DELETE SET NULL,
+    updated_at      TIMESTAMPTZ NOT NULL DEFAULT (now()),
+    PRIMARY KEY (gid, sid)
+);
+
+-- ─── Bans ──────────────────────────────────────────────────────────────────
+CREATE TABLE bans (
+    id              UUID PRIMARY KEY DEFAULT (gen_random_uuid()),
+    address         INET NOT NULL,
+    jail            TEXT NOT NULL,                    -- sshd|api|manual
+    reason          TEXT NOT NULL DEFAULT '',
+    failures        INT NOT NULL DEFAULT 0,
+    banned_at       TIMESTAMPTZ NOT NULL DEFAULT (now()),
+    expires_at      TIMESTAMPTZ NOT NULL,
+    lifted_at       TIMESTAMPTZ,
+    lifted_by       UUID REFERENCES users(id) ON DELETE SET NULL
+);
+
+CREATE INDEX idx_bans_active ON bans(address) WHERE lifted_at IS NULL;
+CREATE INDEX idx_bans_expires ON bans(expires_at);
+
+-- ─── WebAuthn ──────────────────────────────────────────────────────────────
+CREATE TABLE webauthn_credentials (
+    id              UUID PRIMARY KEY DEFAULT (gen_random_uuid()),
+    user_id         UUID NOT NULL,
+    name            TEXT NOT NULL DEFAULT '',
+    credential_id   BYTEA NOT NULL UNIQUE,
+    credential      JSONB NOT NULL,
+    sign_count      BIGINT NOT NULL DEFAULT 0,
+    created_at      TIMESTAMPTZ NOT NULL DEFAULT (now()),
+    last_used_at    TIMESTAMPTZ
+);
+
+CREATE INDEX idx_webauthn_credentials_user ON webauthn_credentials(user_id);
+
+-- ─── SCIM groups ───────────────────────────────────────────────────────────
+CREATE TABLE scim_groups (
+    id              UUID PRIMARY KEY DEFAULT (gen_random_uuid()),
+    external_id     TEXT,
+    display_name    TEXT NOT NULL UNIQUE,
+    created_at      TIMESTAMPTZ NOT NULL DEFAULT (now()),
+    updated_at      TIMESTAMPTZ NOT NULL DEFAULT (now()),
+    source          TEXT NOT NULL DEFAULT 'scim'
+);
+
+CREATE TABLE scim_group_members (
+    group_id        UUID NOT NULL REFERENCES scim_groups(id) ON DELETE CASCADE,
+    user_id         UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    PRIMARY KEY (group_id, user_id)
+);
+
+CREATE INDEX idx_scim_group_members_user ON scim_group_members(user_id);
+
+-- ─── Freeze windows and break-glass ────────────────────────────────────────
+CREATE TABLE freeze_windows (
+    id              UUID PRIMARY KEY DEFAULT (gen_random_uuid()),
+    tenant_id       UUID NOT NULL REFERENCES tenants(id) ON DELETE CASCADE,
+    reason          TEXT NOT NULL,
+    starts_at       TIMESTAMPTZ NOT NULL,
+    ends_at         TIMESTAMPTZ NOT NULL,
+    created_by      UUID,
+    created_at      TIMESTAMPTZ NOT NULL DEFAULT (now()),
+    CHECK (ends_at > starts_at)
+);
+
+CREATE INDEX idx_freeze_windows_tenant ON freeze_windows(tenant_id, ends_at);
+
+CREATE TABLE break_glass_sessions (
+    id              UUID PRIMARY KEY DEFAULT (gen_random_uuid()),
+    tenant_id       UUID NOT NULL REFERENCES tenants(id) ON DELETE CASCADE,
+    user_id         UUID NOT NULL,
+    reason          TEXT NOT NULL,
+    ttl_seconds     INTEGER NOT NULL CHECK (ttl_seconds > 0),
+    method          TEXT NOT NULL,                    -- code|approval
+    requested_at    TIMESTAMPTZ NOT NULL DEFAULT (now()),
+    approved_by     UUID,
+    granted_at      TIMESTAMPTZ,
+    expires_at      TIMESTAMPTZ,
+    ended_at        TIMESTAMPTZ,
+    ended_by        UUID
+);
+
+CREATE INDEX idx_break_glass_sessions_tenant ON break_glass_sessions(tenant_id, requested_at DESC);
+
+-- ─── Blocklists ────────────────────────────────────────────────────────────
+CREATE TABLE blocklists (
+    id              UUID PRIMARY KEY DEFAULT (gen_random_uuid()),
+    name            TEXT NOT NULL UNIQUE,
+    description     TEXT NOT NULL DEFAULT '',
+    enabled         BOOLEAN NOT NULL DEFAULT TRUE,
+    created_by      UUID,
+    created_at      TIMESTAMPTZ NOT NULL DEFAULT (now()),
+    updated_at      TIMESTAMPTZ NOT NULL DEFAULT (now())
+);
+
+CREATE TABLE blocklist_feeds (
+    id              UUID PRIMARY KEY DEFAULT (gen_random_uuid()),
+    blocklist_id    UUID NOT NULL REFERENCES blocklists(id) ON DELETE CASCADE,
+    url             TEXT NOT NULL,
+    format          TEXT NOT NULL,                    -- plain|cidr|json
+    json_field      TEXT NOT NULL DEFAULT '',         -- object key holding the address; empty for an array of strings
+    refresh_seconds INT NOT NULL CHECK (refresh_seconds > 0),
+    last_fetched_at TIMESTAMPTZ,
+    last_error      TEXT NOT NULL DEFAULT '',
+    entry_count     INT NOT NULL DEFAULT 0,
+    created_by      UUID,
+    created_at      TIMESTAMPTZ NOT NULL DEFAULT (now()),
+    UNIQUE (blocklist_id, url)
+);
+
+CREATE TABLE blocklist_entries (
+    id              UUID PRIMARY KEY DEFAULT (gen_random_uuid()),
+    blocklist_id    UUID NOT NULL REFERENCES blocklists(id) ON DELETE CASCADE,
+    feed_id         UUID REFERENCES blocklist_feeds(id) ON DELETE CASCADE,
+    address         CIDR NOT NULL,
+    comment         TEXT NOT NULL DEFAULT '',
+    expires_at      TIMESTAMPTZ,
+    created_by      UUID,
+    created_at      TIMESTAMPTZ NOT NULL DEFAULT (now())
+);
+
+CREATE INDEX idx_blocklist_entries_list ON blocklist_entries(blocklist_id, created_at);
+CREATE INDEX idx_blocklist_entries_feed ON blocklist_entries(feed_id);
+CREATE INDEX idx_blocklist_entries_expires ON blocklist_entries(expires_at) WHERE expires_at IS NOT NULL;
+
+-- ─── Firewall events ───────────────────────────────────────────────────────
+CREATE TABLE firewall_events (
+    id              INTEGER PRIMARY KEY,
+    timestamp       TIMESTAMPTZ NOT NULL,
+    rule            TEXT NOT NULL,
+    action          TEXT NOT NULL,                    -- accept|drop|reject|tarpit|log
+    in_iface        TEXT NOT NULL DEFAULT '',
+    out_iface       TEXT NOT NULL DEFAULT '',
+    src_ip          INET NOT NULL,
+    dst_ip          INET NOT NULL,
+    protocol        TEXT NOT NULL DEFAULT '',
+    src_port        INT,
+    dst_port        INT,
+    country         TEXT NOT NULL DEFAULT '',         -- ISO code of the remote address
+    asn             BIGINT,                           -- AS of the remote address
+    as_org          TEXT NOT NULL DEFAULT ''
+);
+
+CREATE INDEX idx_firewall_events_timestamp ON firewall_events(timestamp DESC);
+CREATE INDEX idx_firewall_events_action_timestamp ON firewall_events(action, timestamp DESC);
+
+-- ─── Read-only switches ────────────────────────────────────────────────────
+CREATE TABLE read_only_switches (
+    id              UUID PRIMARY KEY DEFAULT (gen_random_uuid()),
+    tenant_id       UUID REFERENCES tenants(id) ON DELETE CASCADE,
+    reason          TEXT NOT NULL,
+    enabled_by      UUID,
+    created_at      TIMESTAMPTZ NOT NULL DEFAULT (now())
+);
+
+CREATE UNIQUE INDEX idx_read_only_switches_tenant ON read_only_switches(tenant_id) WHERE tenant_id IS NOT NULL;
+CREATE UNIQUE INDEX idx_read_only_switches_global ON read_only_switches((tenant_id IS NULL)) WHERE tenant_id IS NULL;
+
+-- ─── Monitors ──────────────────────────────────────────────────────────────
+CREATE TABLE monitors (
+    id              UUID PRIMARY KEY DEFAULT (gen_random_uuid()),
+    name            TEXT NOT NULL UNIQUE,
+    description     TEXT NOT NULL DEFAULT '',
+    type            TEXT NOT NULL,                    -- icmp|tcp|http|dns
+    address         TEXT NOT NULL,                    -- host, host:port, URL, or DNS server
+    interface       TEXT NOT NULL DEFAULT '',
+    interval_ms     INT NOT NULL CHECK (interval_ms > 0),
+    timeout_ms      INT NOT NULL CHECK (timeout_ms > 0),
+    rise            INT NOT NULL DEFAULT 2 CHECK (rise > 0),
+    fall            INT NOT NULL DEFAULT 3 CHECK (fall > 0),
+    expect_status   INT NOT NULL DEFAULT 0,           -- http: 0 accepts any status below 400
+    query           TEXT NOT NULL DEFAULT '',         -- dns: name to resolve
+    record_type     TEXT NOT NULL DEFAULT '',         -- dns: A|AAAA
+    expect_address  TEXT NOT NULL DEFAULT '',         -- dns: address the answer must contain
+    enabled         BOOLEAN NOT NULL DEFAULT TRUE,
+    created_by      UUID,
+    created_at      TIMESTAMPTZ NOT NULL DEFAULT (now()),
+    updated_at      TIMESTAMPTZ NOT NULL DEFAULT (now())
+);
+
+CREATE TABLE monitor_events (
+    id              INTEGER PRIMARY KEY,
+    monitor_id      UUID NOT NULL REFERENCES monitors(id) ON DELETE CASCADE,
+    up              BOOLEAN NOT NULL,
+    error           TEXT NOT NULL DEFAULT '',
+    latency_ms      DOUBLE PRECISION NOT NULL DEFAULT 0,
+    at              TIMESTAMPTZ NOT NULL DEFAULT (now())
+);
+
+CREATE INDEX idx_monitor_events_monitor ON monitor_events(monitor_id, at DESC);
+CREATE INDEX idx_monitor_events_at ON monitor_events(at);
+
+-- ─── WAN tests ─────────────────────────────────────────────────────────────
+CREATE TABLE wan_tests (
+    id              INTEGER PRIMARY KEY,
+    uplink          TEXT NOT NULL,                    -- WAN uplink name; "default" without a WANPolicy
+    interface       TEXT NOT NULL DEFAULT '',
+    method          TEXT NOT NULL,                    -- http|iperf3
+    trigger         TEXT NOT NULL,                    -- manual|scheduled
+    latency_ms      DOUBLE PRECISION,
+    jitter_ms       DOUBLE PRECISION,
+    loss_pct        DOUBLE PRECISION,
+    download_mbps   DOUBLE PRECISION,
+    upload_mbps     DOUBLE PRECISION,
+    error           TEXT NOT NULL DEFAULT '',
+    duration_ms     INT NOT NULL DEFAULT 0,
+    created_by      UUID,
+    started_at      TIMESTAMPTZ NOT NULL DEFAULT (now())
+);
+
+CREATE INDEX idx_wan_tests_uplink ON wan_tests(uplink, started_at DESC);
+CREATE INDEX idx_wan_tests_started ON wan_tests(started_at);
+
+-- ─── Login sessions ────────────────────────────────────────────────────────
+CREATE TABLE login_sessions (
+    id              UUID PRIMARY KEY DEFAULT (gen_random_uuid()),
+    tenant_id       UUID NOT NULL REFERENCES tenants(id) ON DELETE CASCADE,
+    user_id         UUID NOT NULL,
+    created_at      TIMESTAMPTZ NOT NULL DEFAULT (now()),
+    last_seen_at    TIMESTAMPTZ NOT NULL DEFAULT (now()),
+    expires_at      TIMESTAMPTZ NOT NULL,
+    ended_at        TIMESTAMPTZ,
+    end_reason      TEXT                              -- logout|evicted|idle
+);
+
+CREATE INDEX idx_login_sessions_user ON login_sessions(user_id, created_at DESC);
@@ -0,0 +1,181 @@
+package store
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+)
+
+// Monitor is a synthetic probe from the gateway to a target, managed
+// through the API.
+type Monitor struct {
+	ID            uuid.UUID  `json:"id"`
+	Name          string     `json:"name"`
+	Description   string     `json:"description"`
+	Type          string     `json:"type"`    // icmp|tcp|http|dns
+	Address       string     `json:"address"` // host, host:port, URL, or DNS server
+	Interface     string     `json:"interface,omitempty"`
+	IntervalMs    int        `json:"intervalMs"`
+	TimeoutMs     int        `json:"timeoutMs"`
+	Rise          int        `json:"rise"`
+	Fall          int        `json:"fall"`
+	ExpectStatus  int        `json:"expectStatus,omitempty"`
+	Query         string     `json:"query,omitempty"`
+	RecordType    string     `json:"recordType,omitempty"`
+	ExpectAddress string     `json:"expectAddress,omitempty"`
+	Enabled       bool       `json:"enabled"`
+	CreatedBy     *uuid.UUID `json:"createdBy,omitempty"`
+	CreatedAt     time.Time  `json:"createdAt"`
+	UpdatedAt     time.Time  `json:"updatedAt"`
+}
+
+// MonitorEvent is a monitor settling up or down.
+type MonitorEvent struct {
+	ID        int64     `json:"id"`
+	MonitorID uuid.UUID `json:"monitorId"`
+	Up        bool      `json:"up"`
+	Error     string    `json:"error,omitempty"`
+	LatencyMs float64   `json:"latencyMs"`
+	At        time.Time `json:"at"`
+}
+
+// MonitorStore handles monitors and their state changes.
+type MonitorStore struct{ db *DB }
+
+func NewMonitorStore(db *DB) *MonitorStore { return &MonitorStore{db: db} }
+
+// Create records a new monitor.
+func (s *MonitorStore) Create(ctx context.Context, m *Monitor) error {
+	err := s.db.Pool.QueryRow(ctx, `
+		INSERT INTO monitors (name, description, type, address, interface, interval_ms, timeout_ms,
+			rise, fall, expect_status, query, record_type, expect_address, enabled, created_by)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14, $15)
+		RETURNING id, created_at, updated_at`,
+		m.Name, m.Description, m.Type, m.Address, m.Interface, m.IntervalMs, m.TimeoutMs,
+		m.Rise, m.Fall, m.ExpectStatus, m.Query, m.RecordType, m.ExpectAddress, m.Enabled, m.CreatedBy,
+	).Scan(&m.ID, &m.CreatedAt, &m.UpdatedAt)
+	if err != nil {
+		if strings.Contains(err.Error(), "duplicate key") {
+			return fmt.Errorf("monitor %q already exists", m.Name)
+		}
+		return fmt.Errorf("insert monitor: %w", err)
+	}
+	return nil
+}
+
+// Get returns one monitor.
+func (s *MonitorStore) Get(ctx context.Context, id uuid.UUID) (*Monitor, error) {
+	row := s.db.Pool.QueryRow(ctx, `
+		SELECT `+monitorColumns+` FROM monitors WHERE id = $1`, id)
+	return scanMonitor(row)
+}
+
+// List returns every monitor by name.
+func (s *MonitorStore) List(ctx context.Context) ([]*Monitor, error) {
+	rows, err := s.db.Pool.Query(ctx, `
+		SELECT `+monitorColumns+` FROM monitors ORDER BY name`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var items []*Monitor
+	for rows.Next() {
+		m, err := scanMonitor(rows)
+		if err != nil {
+			return nil, err
+		}
+		items = append(items, m)
+	}
+	return items, rows.Err()
+}
+
+// Update saves everything but the name of a monitor.
+func (s *MonitorStore) Update(ctx context.Context, m *Monitor) error {
+	err := s.db.Pool.QueryRow(ctx, `
+		UPDATE monitors SET description = $2, type = $3, address = $4, interface = $5,
+			interval_ms = $6, timeout_ms = $7, rise = $8, fall = $9, expect_status = $10,
+			query = $11, record_type = $12, expect_address = $13, enabled = $14, updated_at = NOW()
+		WHERE id = $1
+		RETURNING updated_at`,
+		m.ID, m.Description, m.Type, m.Address, m.Interface, m.IntervalMs, m.TimeoutMs,
+		m.Rise, m.Fall, m.ExpectStatus, m.Query, m.RecordType, m.ExpectAddress, m.Enabled,
+	).Scan(&m.UpdatedAt)
+	if err != nil {
+		if err == pgx.ErrNoRows {
+			return fmt.Errorf("monitor not found")
+		}
+		return fmt.Errorf("update monitor: %w", err)
+	}
+	return nil
+}
+
+// Delete removes a monitor with its history.
+func (s *MonitorStore) Delete(ctx context.Context, id uuid.UUID) error {
+	tag, err := s.db.Pool.Exec(ctx, `DELETE FROM monitors WHERE id = $1`, id)
+	if err != nil {
+		return err
+	}
+	if tag.RowsAffected() == 0 {
+		return fmt.Errorf("monitor not found")
+	}
+	return nil
+}
+
+// RecordEvent stores a state change of the monitor called name. Changes of
+// a monitor deleted in the meantime are dropped.
+func (s *MonitorStore) RecordEvent(ctx context.Context, name string, e *MonitorEvent) error {
+	_, err := s.db.Pool.Exec(ctx, `
+		INSERT INTO monitor_events (monitor_id, up, error, latency_ms, at)
+		SELECT id, $2, $3, $4, $5 FROM monitors WHERE name = $1`,
+		name, e.Up, e.Error, e.LatencyMs, e.At)
+	if err != nil {
+		return fmt.Errorf("insert monitor event: %w", err)
+	}
+	return nil
+}
+
+// ListEvents returns the latest state changes of a monitor, newest first.
+func (s *MonitorStore) ListEvents(ctx context.Context, id uuid.UUID, limit int) ([]*MonitorEvent, error) {
+	rows, err := s.db.Pool.Query(ctx, `
+		SELECT id, monitor_id, up, error, latency_ms, at
+		FROM monitor_events WHERE monitor_id = $1
+		ORDER BY at DESC, id DESC LIMIT $2`, id, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var items []*MonitorEvent
+	for rows.Next() {
+		var e MonitorEvent
+		if err := rows.Scan(&e.ID, &e.MonitorID, &e.Up, &e.Error, &e.LatencyMs, &e.At); err != nil {
+			return nil, err
+		}
+		items = append(items, &e)
+	}
+	return items, rows.Err()
+}
+
+// ─── Private helpers ──────────────────────────────────────────────────────
+
+const monitorColumns = `id, name, description, type, address, interface, interval_ms, timeout_ms,
+	rise, fall, expect_status, query, record_type, expect_address, enabled, created_by, created_at, updated_at`
+
+func scanMonitor(row scanner) (*Monitor, error) {
+	var m Monitor
+	err := row.Scan(&m.ID, &m.Name, &m.Description, &m.Type, &m.Address, &m.Interface,
+		&m.IntervalMs, &m.TimeoutMs, &m.Rise, &m.Fall, &m.ExpectStatus, &m.Query, &m.RecordType,
+		&m.ExpectAddress, &m.Enabled, &m.CreatedBy, &m.CreatedAt, &m.UpdatedAt)
+	if err != nil {
+		if err == pgx.ErrNoRows {
+			return nil, fmt.Errorf("monitor not found")
+		}
+		return nil, err
+	}
+	return &m, nil
+}
@@ -0,0 +1,287 @@
+package store
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+)
+
+// DefaultNamespace always exists implicitly and cannot be deleted.
+const DefaultNamespace = "default"
+
+// NamespaceRecord is the DB representation of a namespace.
+type NamespaceRecord struct {
+	ID          uuid.UUID         `json:"id"`
+	TenantID    uuid.UUID         `json:"tenantId"`
+	Name        string            `json:"name"`
+	Description string            `json:"description"`
+	Labels      map[string]string `json:"labels"`
+	MaxPolicies int               `json:"maxPolicies"` // 0 = unlimited
+	MaxRules    int               `json:"maxRules"`    // 0 = unlimited
+	CreatedBy   *uuid.UUID        `json:"createdBy"`
+	CreatedAt   time.Time         `json:"createdAt"`
+	UpdatedAt   time.Time         `json:"updatedAt"`
+}
+
+// NamespaceBinding grants a user a role inside one namespace.
+type NamespaceBinding struct {
+	ID          uuid.UUID `json:"id"`
+	NamespaceID uuid.UUID `json:"namespaceId"`
+	UserID      uuid.UUID `json:"userId"`
+	Role        string    `json:"role"` // admin|operator|viewer
+	CreatedAt   time.Time `json:"createdAt"`
+}
+
+// NamespaceStore handles CRUD for namespaces and their role bindings.
+type NamespaceStore struct{ db *DB }
+
+func NewNamespaceStore(db *DB) *NamespaceStore { return &NamespaceStore{db: db} }
+
+// Create inserts a new namespace.
+func (s *NamespaceStore) Create(ctx context.Context, n *NamespaceRecord) error {
+	if n.ID == uuid.Nil {
+		n.ID = uuid.New()
+	}
+	n.CreatedAt = time.Now()
+	n.UpdatedAt = time.Now()
+
+	labels, err := json.Marshal(n.Labels)
+	if err != nil {
+		return fmt.Errorf("encode labels: %w", err)
+	}
+
+	_, err = s.db.Pool.Exec(ctx, `
+		INSERT INTO namespaces
+			(id, tenant_id, name, description, labels, max_policies, max_rules, created_by)
+		VALUES
+			($1, $2, $3, $4, $5, $6, $7, $8)`,
+		n.ID, n.TenantID, n.Name, n.Description, labels,
+		n.MaxPolicies, n.MaxRules, n.CreatedBy,
+	)
+	if err != nil {
+		return fmt.Errorf("insert namespace: %w", err)
+	}
+	return nil
+}
+
+// Get returns a single namespace by name.
+func (s *NamespaceStore) Get(ctx context.Context, tenantID uuid.UUID, name string) (*NamespaceRecord, error) {
+	row := s.db.Pool.QueryRow(ctx, `
+		SELECT id, tenant_id, name, description, labels, max_policies, max_rules,
+		       created_by, created_at, updated_at
+		FROM namespaces
+		WHERE tenant_id = $1 AND name = $2`,
+		tenantID, name)
+
+	return scanNamespace(row)
+}
+
+// List returns all namespaces for a tenant.
+func (s *NamespaceStore) List(ctx context.Context, tenantID uuid.UUID) ([]*NamespaceRecord, error) {
+	rows, err := s.db.Pool.Query(ctx, `
+		SELECT id, tenant_id, name, description, labels, max_policies, max_rules,
+		       created_by, created_at, updated_at
+		FROM namespaces
+		WHERE tenant_id = $1
+		ORDER BY name`, tenantID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var namespaces []*NamespaceRecord
+	for rows.Next() {
+		n, err := scanNamespace(rows)
+		if err != nil {
+			return nil, err
+		}
+		namespaces = append(namespaces, n)
+	}
+	return namespaces, rows.Err()
+}
+
+// Update persists description, labels and quotas.
+func (s *NamespaceStore) Update(ctx context.Context, n *NamespaceRecord) error {
+	n.UpdatedAt = time.Now()
+
+	labels, err := json.Marshal(n.Labels)
+	if err != nil {
+		return fmt.Errorf("encode labels: %w", err)
+	}
+
+	tag, err := s.db.Pool.Exec(ctx, `
+		UPDATE namespaces
+		SET description = $1, labels = $2, max_policies = $3, max_rules = $4, updated_at = NOW()
+		WHERE id = $5 AND tenant_id = $6`,
+		n.Description, labels, n.MaxPolicies, n.MaxRules, n.ID, n.TenantID,
+	)
+	if err != nil {
+		return fmt.Errorf("update namespace: %w", err)
+	}
+	if tag.RowsAffected() == 0 {
+		return fmt.Errorf("namespace not found")
+	}
+	return nil
+}
+
+// Delete removes a namespace. It refuses while live policies still reference it.
+func (s *NamespaceStore) Delete(ctx context.Context, tenantID uuid.UUID, name string) error {
+	count, err := s.CountPolicies(ctx, tenantID, name)
+	if err != nil {
+		return err
+	}
+	if count > 0 {
+		return fmt.Errorf("namespace %q still contains %d policies", name, count)
+	}
+
+	tag, err := s.db.Pool.Exec(ctx, `
+		DELETE FROM namespaces WHERE tenant_id = $1 AND name = $2`,
+		tenantID, name)
+	if err != nil {
+		return err
+	}
+	if tag.RowsAffected() == 0 {
+		return fmt.Errorf("namespace not found")
+	}
+	return nil
+}
+
+// CountPolicies returns the number of live policies in a namespace.
+func (s *NamespaceStore) CountPolicies(ctx context.Context, tenantID uuid.UUID, name string) (int, error) {
+	var count int
+	err := s.db.Pool.QueryRow(ctx, `
+		SELECT COUNT(*) FROM policies
+		WHERE tenant_id = $1 AND namespace = $2 AND deleted_at IS NULL`,
+		tenantID, name).Scan(&count)
+	if err != nil {
+		return 0, fmt.Errorf("count policies: %w", err)
+	}
+	return count, nil
+}
+
+// ─── Bindings ─────────────────────────────────────────────────────────────
+
+// ListBindings returns all role bindings of a namespace.
+func (s *NamespaceStore) ListBindings(ctx context.Context, tenantID, namespaceID uuid.UUID) ([]*NamespaceBinding, error) {
+	rows, err := s.db.Pool.Query(ctx, `
+		SELECT b.id, b.namespace_id, b.user_id, b.role, b.created_at
+		FROM namespace_bindings b
+		JOIN namespaces n ON n.id = b.namespace_id
+		WHERE b.namespace_id = $1 AND n.tenant_id = $2
+		ORDER BY b.created_at`, namespaceID, tenantID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var bindings []*NamespaceBinding
+	for rows.Next() {
+		var b NamespaceBinding
+		if err := rows.Scan(&b.ID, &b.NamespaceID, &b.UserID, &b.Role, &b.CreatedAt); err != nil {
+			return nil, err
+		}
+		bindings = append(bindings, &b)
+	}
+	return bindings, rows.Err()
+}
+
+// PutBinding creates or replaces the role of a user inside a namespace.
+// Both the namespace and the user must belong to tenantID.
+func (s *NamespaceStore) PutBinding(ctx context.Context, tenantID uuid.UUID, b *NamespaceBinding) error {
+	if b.ID == uuid.Nil {
+		b.ID = uuid.New()
+	}
+	err := s.db.Pool.QueryRow(ctx, `
+		INSERT INTO namespace_bindings (id, namespace_id, user_id, role)
+		SELECT $1, $2, $3, $4
+		WHERE EXISTS (SELECT 1 FROM namespaces WHERE id = $2 AND tenant_id = $5)
+		  AND EXISTS (SELECT 1 FROM users WHERE id = $3 AND tenant_id = $5)
+		ON CONFLICT (namespace_id, user_id) DO UPDATE SET role = EXCLUDED.role
+		RETURNING id, created_at`,
+		b.ID, b.NamespaceID, b.UserID, b.Role, tenantID,
+	).Scan(&b.ID, &b.CreatedAt)
+	if err != nil {
+		if err == pgx.ErrNoRows {
+			return fmt.Errorf("user not found")
+		}
+		return fmt.Errorf("upsert binding: %w", err)
+	}
+	return nil
+}
+
+// DeleteBinding removes a user's role from a namespace.
+func (s *NamespaceStore) DeleteBinding(ctx context.Context, tenantID, namespaceID, userID uuid.UUID) error {
+	tag, err := s.db.Pool.Exec(ctx, `
+		DELETE FROM namespace_bindings
+		WHERE namespace_id = $1 AND user_id = $2
+		  AND namespace_id IN (SELECT id FROM namespaces WHERE tenant_id = $3)`,
+		namespaceID, userID, tenantID)
+	if err != nil {
+		return err
+	}
+	if tag.RowsAffected() == 0 {
+		return fmt.Errorf("binding not found")
+	}
+	return nil
+}
+
+// LoadAccess returns, for a tenant, the set of namespaces that have at least
+// one binding ("owned" namespaces) and the roles userID holds in each.
+func (s *NamespaceStore) LoadAccess(ctx context.Context, tenantID, userID uuid.UUID) (owned map[string]bool, roles map[string]string, err error) {
+	rows, err := s.db.Pool.Query(ctx, `
+		SELECT n.name, b.user_id, b.role
+		FROM namespace_bindings b
+		JOIN namespaces n ON n.id = b.namespace_id
+		WHERE n.tenant_id = $1`, tenantID)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer rows.Close()
+
+	owned = make(map[string]bool)
+	roles = make(map[string]string)
+	for rows.Next() {
+		var (
+			name string
+			uid  uuid.UUID
+			role string
+		)
+		if err := rows.Scan(&name, &uid, &role); err != nil {
+			return nil, nil, err
+		}
+		owned[name] = true
+		if uid == userID {
+			roles[name] = role
+		}
+	}
+	return owned, roles, rows.Err()
+}
+
+// ─── Private helpers ──────────────────────────────────────────────────────
+
+func scanNamespace(row scanner) (*NamespaceRecord, error) {
+	var (
+		n      NamespaceRecord
+		labels []byte
+	)
+	err := row.Scan(
+		&n.ID, &n.TenantID, &n.Name, &n.Description, &labels,
+		&n.MaxPolicies, &n.MaxRules, &n.CreatedBy, &n.CreatedAt, &n.UpdatedAt,
+	)
+	if err != nil {
+		if err == pgx.ErrNoRows {
+			return nil, fmt.Errorf("namespace not found")
+		}
+		return nil, err
+	}
+	if len(labels) > 0 {
+		if err := json.Unmarshal(labels, &n.Labels); err != nil {
+			return nil, fmt.Errorf("decode labels: %w", err)
+		}
+	}
+	return &n, nil
+}
@@ -8,6 +8,8 @@ import (
 
 	"github.com/google/uuid"
 	"github.com/jackc/pgx/v5"
+
+	"github.com/aegisx/aegisx/internal/redact"
 )
 
 // PolicyRecord is the DB representation of a policy.
@@ -25,12 +27,37 @@ type PolicyRecord struct {
 	CreatedBy *uuid.UUID      `json:"createdBy"`
 	CreatedAt time.Time       `json:"createdAt"`
 	UpdatedAt time.Time       `json:"updatedAt"`
+
+	// Signature is the detached signature RawYAML was written with, and
+	// Provenance the trusted key it verified against; Provenance is nil
+	// for unsigned policies and signatures that were not verified.
+	Signature  string      `json:"signature"`
+	Provenance *Provenance `json:"provenance"`
+}
+
+// Provenance records the key that signed a policy's manifest.
+type Provenance struct {
+	KeyID      string    `json:"keyId"` // SHA-256 of the public key
+	VerifiedAt time.Time `json:"verifiedAt"`
+}
+
+// MarshalJSON redacts secrets such as VPN private and preshared keys from
+// Spec and RawYAML so they never reach API responses.
+func (p PolicyRecord) MarshalJSON() ([]byte, error) {
+	type plain PolicyRecord
+	out := plain(p)
+	out.Spec = redact.JSON(p.Spec)
+	out.RawYAML = redact.String(p.RawYAML)
+	return json.Marshal(out)
 }
 
 // PolicyStore handles CRUD for policies.
-type PolicyStore struct{ db *DB }
+type PolicyStore struct {
+	db    *DB
+	watch *policyWatch
+}
 
-func NewPolicyStore(db *DB) *PolicyStore { return &PolicyStore{db: db} }
+func NewPolicyStore(db *DB) *PolicyStore { return &PolicyStore{db: db, watch: newPolicyWatch()} }
 
 // Create inserts a new policy and returns its ID.
 func (s *PolicyStore) Create(ctx context.Context, p *PolicyRecord) error {
@@ -43,25 +70,32 @@ func (s *PolicyStore) Create(ctx context.Context, p *PolicyRecord) error {
 
 	_, err := s.db.Pool.Exec(ctx, `
 		INSERT INTO policies
-			(id, tenant_id, name, namespace, kind, version, spec, raw_yaml, enabled, created_by)
+			(id, tenant_id, name, namespace, kind, version, spec, raw_yaml, enabled, created_by,
+			 signature, signed_by, verified_at)
 		VALUES
-			($1, $2, $3, $4, $5, $6, $7, $8, $9, $10)`,
+			($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13)`,
 		p.ID, p.TenantID, p.Name, p.Namespace, p.Kind,
 		p.Version, p.Spec, p.RawYAML, p.Enabled, p.CreatedBy,
+		p.Signature, p.signedBy(), p.verifiedAt(),
 	)
 	if err != nil {
 		return fmt.Errorf("insert policy: %w", err)
 	}
 
 	// Write revision
-	return s.appendRevision(ctx, p)
+	if err := s.appendRevision(ctx, p); err != nil {
+		return err
+	}
+	s.publish(WatchAdded, p)
+	return nil
 }
 
 // Get returns a single policy by ID.
 func (s *PolicyStore) Get(ctx context.Context, tenantID, id uuid.UUID) (*PolicyRecord, error) {
 	row := s.db.Pool.QueryRow(ctx, `
 		SELECT id, tenant_id, name, namespace, kind, version, spec, raw_yaml,
-		       enabled, applied_at, created_by, created_at, updated_at
+		       enabled, applied_at, created_by, created_at, updated_at,
+		       signature, signed_by, verified_at
 		FROM policies
 		WHERE id = $1 AND tenant_id = $2 AND deleted_at IS NULL`,
 		id, tenantID)
@@ -69,18 +103,49 @@ func (s *PolicyStore) Get(ctx context.Context, tenantID, id uuid.UUID) (*PolicyR
 	return scanPolicy(row)
 }
 
-// List returns all policies for a tenant, optionally filtered by kind.
-func (s *PolicyStore) List(ctx context.Context, tenantID uuid.UUID, kind string) ([]*PolicyRecord, error) {
-	query := `
+// GetByName returns the policy with the given name in a namespace.
+func (s *PolicyStore) GetByName(ctx context.Context, tenantID uuid.UUID, namespace, name string) (*PolicyRecord, error) {
+	row := s.db.Pool.QueryRow(ctx, `
 		SELECT id, tenant_id, name, namespace, kind, version, spec, raw_yaml,
-		       enabled, applied_at, created_by, created_at, updated_at
+		       enabled, applied_at, created_by, created_at, updated_at,
+		       signature, signed_by, verified_at
+		FROM policies
+		WHERE tenant_id = $1 AND namespace = $2 AND name = $3 AND deleted_at IS NULL`,
+		tenantID, namespace, name)
+
+	return scanPolicy(row)
+}
+
+// PolicyFilter narrows a policy listing. Zero-valued fields are ignored.
+type PolicyFilter struct {
+	Kind      string
+	Namespace string
+
+	// SkipBodies leaves Spec and RawYAML empty for lightweight listings.
+	SkipBodies bool
+}
+
+// List returns all policies for a tenant matching the filter.
+func (s *PolicyStore) List(ctx context.Context, tenantID uuid.UUID, filter PolicyFilter) ([]*PolicyRecord, error) {
+	bodies := "spec, raw_yaml"
+	if filter.SkipBodies {
+		bodies = "'null'::jsonb, ''"
+	}
+	query := `
+		SELECT id, tenant_id, name, namespace, kind, version, ` + bodies + `,
+		       enabled, applied_at, created_by, created_at, updated_at,
+		       signature, signed_by, verified_at
 		FROM policies
 		WHERE tenant_id = $1 AND deleted_at IS NULL`
 	args := []any{tenantID}
 
-	if kind != "" {
-		query += " AND kind = $2"
-		args = append(args, kind)
+	if filter.Kind != "" {
+		args = append(args, filter.Kind)
+		query += fmt.Sprintf(" AND kind = $%d", len(args))
+	}
+	if filter.Namespace != "" {
+		args = append(args, filter.Namespace)
+		query += fmt.Sprintf(" AND namespace = $%d", len(args))
 	}
 	query += " ORDER BY namespace, name"
 
@@ -101,54 +166,102 @@ func (s *PolicyStore) List(ctx context.Context, tenantID uuid.UUID, kind string)
 	return policies, rows.Err()
 }
 
-// Update increments the version and persists changes.
+// Update increments the version and persists changes; p is updated to the
+// stored policy.
 func (s *PolicyStore) Update(ctx context.Context, p *PolicyRecord) error {
-	p.UpdatedAt = time.Now()
-
-	tag, err := s.db.Pool.Exec(ctx, `
+	row := s.db.Pool.QueryRow(ctx, `
 		UPDATE policies
-		SET spec = $1, raw_yaml = $2, enabled = $3, version = version + 1, updated_at = NOW()
-		WHERE id = $4 AND tenant_id = $5`,
+		SET spec = $1, raw_yaml = $2, enabled = $3, version = version + 1, updated_at = NOW(),
+		    signature = $6, signed_by = $7, verified_at = $8
+		WHERE id = $4 AND tenant_id = $5 AND deleted_at IS NULL
+		RETURNING `+policyColumns,
 		p.Spec, p.RawYAML, p.Enabled, p.ID, p.TenantID,
+		p.Signature, p.signedBy(), p.verifiedAt(),
 	)
+	updated, err := scanPolicy(row)
+	if err != nil {
+		return err
+	}
+	*p = *updated
+	if err := s.appendRevision(ctx, p); err != nil {
+		return err
+	}
+	s.publish(WatchModified, p)
+	return nil
+}
+
+// SetEnabled flips the enabled flag, bumps the version and records actor in
+// the revision history. It returns the updated policy.
+func (s *PolicyStore) SetEnabled(ctx context.Context, tenantID, id uuid.UUID, enabled bool, actor *uuid.UUID) (*PolicyRecord, error) {
+	row := s.db.Pool.QueryRow(ctx, `
+		UPDATE policies
+		SET enabled = $1, version = version + 1, updated_at = NOW()
+		WHERE id = $2 AND tenant_id = $3 AND deleted_at IS NULL
+		RETURNING `+policyColumns,
+		enabled, id, tenantID)
+	p, err := scanPolicy(row)
 	if err != nil {
-		return fmt.Errorf("update policy: %w", err)
+		return nil, err
+	}
+
+	comment := "disabled"
+	if enabled {
+		comment = "enabled"
 	}
-	if tag.RowsAffected() == 0 {
-		return fmt.Errorf("policy not found")
+	_, err = s.db.Pool.Exec(ctx, `
+		INSERT INTO policy_revisions (policy_id, version, spec, raw_yaml, changed_by, comment)
+		VALUES ($1, $2, $3, $4, $5, $6)`,
+		p.ID, p.Version, p.Spec, p.RawYAML, actor, comment)
+	if err != nil {
+		return nil, fmt.Errorf("record revision: %w", err)
 	}
-	return s.appendRevision(ctx, p)
+	s.publish(WatchModified, p)
+	return p, nil
 }
 
 // Delete soft-deletes a policy.
 func (s *PolicyStore) Delete(ctx context.Context, tenantID, id uuid.UUID) error {
-	tag, err := s.db.Pool.Exec(ctx, `
-		UPDATE policies SET deleted_at = NOW() WHERE id = $1 AND tenant_id = $2`,
+	row := s.db.Pool.QueryRow(ctx, `
+		UPDATE policies SET deleted_at = NOW() WHERE id = $1 AND tenant_id = $2 AND deleted_at IS NULL
+		RETURNING `+policyColumns,
 		id, tenantID)
+	p, err := scanPolicy(row)
 	if err != nil {
 		return err
 	}
-	if tag.RowsAffected() == 0 {
-		return fmt.Errorf("policy not found")
-	}
+	s.publish(WatchDeleted, p)
 	return nil
 }
 
 // MarkApplied sets applied_at on a policy.
 func (s *PolicyStore) MarkApplied(ctx context.Context, tenantID, id uuid.UUID) error {
-	_, err := s.db.Pool.Exec(ctx, `
-		UPDATE policies SET applied_at = NOW() WHERE id = $1 AND tenant_id = $2`,
+	rows, err := s.db.Pool.Query(ctx, `
+		UPDATE policies SET applied_at = NOW() WHERE id = $1 AND tenant_id = $2 AND deleted_at IS NULL
+		RETURNING `+policyColumns,
 		id, tenantID)
-	return err
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+	if !rows.Next() {
+		return rows.Err()
+	}
+	p, err := scanPolicy(rows)
+	if err != nil {
+		return err
+	}
+	s.publish(WatchModified, p)
+	return nil
 }
 
 // ListRevisions returns the revision history for a policy.
-func (s *PolicyStore) ListRevisions(ctx context.Context, policyID uuid.UUID) ([]*PolicyRevision, error) {
+func (s *PolicyStore) ListRevisions(ctx context.Context, tenantID, policyID uuid.UUID) ([]*PolicyRevision, error) {
 	rows, err := s.db.Pool.Query(ctx, `
-		SELECT id, policy_id, version, spec, changed_by, changed_at, comment
-		FROM policy_revisions
-		WHERE policy_id = $1
-		ORDER BY version DESC`, policyID)
+		SELECT r.id, r.policy_id, r.version, r.spec, r.changed_by, r.changed_at, COALESCE(r.comment, '')
+		FROM policy_revisions r
+		JOIN policies p ON p.id = r.policy_id
+		WHERE r.policy_id = $1 AND p.tenant_id = $2
+		ORDER BY r.version DESC`, policyID, tenantID)
 	if err != nil {
 		return nil, err
 	}
@@ -176,6 +289,14 @@ type PolicyRevision struct {
 	Comment   string          `json:"comment"`
 }
 
+// MarshalJSON redacts secrets from Spec, as PolicyRecord does.
+func (r PolicyRevision) MarshalJSON() ([]byte, error) {
+	type plain PolicyRevision
+	out := plain(r)
+	out.Spec = redact.JSON(r.Spec)
+	return json.Marshal(out)
+}
+
 // ─── Private helpers ──────────────────────────────────────────────────────
 
 func (s *PolicyStore) appendRevision(ctx context.Context, p *PolicyRecord) error {
@@ -186,16 +307,24 @@ func (s *PolicyStore) appendRevision(ctx context.Context, p *PolicyRecord) error
 	return err
 }
 
+// policyColumns are the columns scanPolicy reads, in order.
+const policyColumns = `id, tenant_id, name, namespace, kind, version, spec, raw_yaml,
+		enabled, applied_at, created_by, created_at, updated_at,
+		signature, signed_by, verified_at`
+
 type scanner interface {
 	Scan(dest ...any) error
 }
 
 func scanPolicy(row scanner) (*PolicyRecord, error) {
 	var p PolicyRecord
+	var signedBy *string
+	var verifiedAt *time.Time
 	err := row.Scan(
 		&p.ID, &p.TenantID, &p.Name, &p.Namespace, &p.Kind, &p.Version,
 		&p.Spec, &p.RawYAML, &p.Enabled, &p.AppliedAt, &p.CreatedBy,
 		&p.CreatedAt, &p.UpdatedAt,
+		&p.Signature, &signedBy, &verifiedAt,
 	)
 	if err != nil {
 		if err == pgx.ErrNoRows {
@@ -203,5 +332,24 @@ func scanPolicy(row scanner) (*PolicyRecord, error) {
 		}
 		return nil, err
 	}
+	if signedBy != nil && verifiedAt != nil {
+		p.Provenance = &Provenance{KeyID: *signedBy, VerifiedAt: *verifiedAt}
+	}
 	return &p, nil
 }
+
+// signedBy and verifiedAt are the provenance columns of p, NULL when it
+// has none.
+func (p *PolicyRecord) signedBy() *string {
+	if p.Provenance == nil {
+		return nil
+	}
+	return &p.Provenance.KeyID
+}
+
+func (p *PolicyRecord) verifiedAt() *time.Time {
+	if p.Provenance == nil {
+		return nil
+	}
+	return &p.Provenance.VerifiedAt
+}
@@ -0,0 +1,116 @@
+package store
+
+import (
+	"errors"
+	"sync"
+	"time"
+)
+
+// Policy watch event types.
+const (
+	WatchAdded    = "added"
+	WatchModified = "modified"
+	WatchDeleted  = "deleted"
+)
+
+// ErrResourceVersionExpired is a watch resuming from a resourceVersion
+// whose later events are no longer kept; the caller must list again.
+var ErrResourceVersionExpired = errors.New("resourceVersion is too old; list again and watch from the version returned")
+
+// Policy watches keep this many recent events to resume from, and buffer
+// this many undelivered events per watcher before dropping it.
+const (
+	watchBacklog = 1000
+	watchBuffer  = 256
+)
+
+// PolicyEvent is a change to a policy seen by this process.
+type PolicyEvent struct {
+	Type            string        `json:"type"` // added | modified | deleted
+	ResourceVersion int64         `json:"resourceVersion"`
+	Policy          *PolicyRecord `json:"object"`
+}
+
+// policyWatch fans policy changes out to watchers. Resource versions count
+// up from the time the process started, so they keep increasing across
+// restarts while a version from before one is recognised as expired.
+type policyWatch struct {
+	mu     sync.Mutex
+	rv     int64
+	floor  int64 // the watchers may resume from versions >= floor
+	recent []PolicyEvent
+	subs   map[chan PolicyEvent]struct{}
+}
+
+func newPolicyWatch() *policyWatch {
+	now := time.Now().UnixMicro()
+	return &policyWatch{rv: now, floor: now, subs: make(map[chan PolicyEvent]struct{})}
+}
+
+// ResourceVersion returns the version of the latest policy change. A list
+// read after it may be watched from it without missing changes.
+func (s *PolicyStore) ResourceVersion() int64 {
+	s.watch.mu.Lock()
+	defer s.watch.mu.Unlock()
+	return s.watch.rv
+}
+
+// Watch subscribes to policy changes of every tenant after version since,
+// or after the latest when since is 0, and returns the version it starts
+// from. The channel is closed when cancel is called, or when the watcher
+// falls too far behind; it should then resume from the last version it
+// received. Only changes made through this process are seen.
+func (s *PolicyStore) Watch(since int64) (<-chan PolicyEvent, func(), int64, error) {
+	w := s.watch
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if since == 0 {
+		since = w.rv
+	}
+	if since < w.floor || since > w.rv {
+		return nil, nil, 0, ErrResourceVersionExpired
+	}
+	var replay []PolicyEvent
+	for _, ev := range w.recent {
+		if ev.ResourceVersion > since {
+			replay = append(replay, ev)
+		}
+	}
+	ch := make(chan PolicyEvent, len(replay)+watchBuffer)
+	for _, ev := range replay {
+		ch <- ev
+	}
+	w.subs[ch] = struct{}{}
+	cancel := func() {
+		w.mu.Lock()
+		defer w.mu.Unlock()
+		if _, ok := w.subs[ch]; ok {
+			delete(w.subs, ch)
+			close(ch)
+		}
+	}
+	return ch, cancel, since, nil
+}
+
+// publish records a change of p and sends it to the watchers.
+func (s *PolicyStore) publish(typ string, p *PolicyRecord) {
+	w := s.watch
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.rv++
+	cp := *p
+	ev := PolicyEvent{Type: typ, ResourceVersion: w.rv, Policy: &cp}
+	if len(w.recent) == watchBacklog {
+		w.floor = w.recent[0].ResourceVersion
+		w.recent = append(w.recent[:0], w.recent[1:]...)
+	}
+	w.recent = append(w.recent, ev)
+	for ch := range w.subs {
+		select {
+		case ch <- ev:
+		default: // too far behind; it resumes from its last version
+			delete(w.subs, ch)
+			close(ch)
+		}
+	}
+}
@@ -0,0 +1,102 @@
+package store
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+)
+
+// ReadOnlySwitch puts the API, or one tenant, in read-only mode: mutating
+// requests are refused until it is removed.
+type ReadOnlySwitch struct {
+	ID        uuid.UUID  `json:"id"`
+	TenantID  *uuid.UUID `json:"tenantId,omitempty"` // nil for the global switch
+	Reason    string     `json:"reason"`
+	EnabledBy *uuid.UUID `json:"enabledBy,omitempty"`
+	CreatedAt time.Time  `json:"createdAt"`
+}
+
+// ReadOnlyStore handles read-only switches.
+type ReadOnlyStore struct{ db *DB }
+
+func NewReadOnlyStore(db *DB) *ReadOnlyStore { return &ReadOnlyStore{db: db} }
+
+// Get returns the switch of tenantID, or the global one when tenantID is
+// nil; nil when it is off.
+func (s *ReadOnlyStore) Get(ctx context.Context, tenantID *uuid.UUID) (*ReadOnlySwitch, error) {
+	row := s.db.Pool.QueryRow(ctx, `
+		SELECT `+readOnlyColumns+`
+		FROM read_only_switches
+		WHERE tenant_id IS NOT DISTINCT FROM $1`, tenantID)
+	return scanReadOnlyOrNil(row)
+}
+
+// Active returns the switch that makes tenantID read-only, preferring the
+// global one, or nil when neither is on.
+func (s *ReadOnlyStore) Active(ctx context.Context, tenantID uuid.UUID) (*ReadOnlySwitch, error) {
+	row := s.db.Pool.QueryRow(ctx, `
+		SELECT `+readOnlyColumns+`
+		FROM read_only_switches
+		WHERE tenant_id IS NULL OR tenant_id = $1
+		ORDER BY tenant_id NULLS FIRST
+		LIMIT 1`, tenantID)
+	return scanReadOnlyOrNil(row)
+}
+
+// Set turns sw's scope read-only, replacing the reason of a switch that is
+// already on.
+func (s *ReadOnlyStore) Set(ctx context.Context, sw *ReadOnlySwitch) error {
+	tx, err := s.db.Pool.Begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback(ctx)
+
+	if _, err := tx.Exec(ctx, `
+		DELETE FROM read_only_switches WHERE tenant_id IS NOT DISTINCT FROM $1`, sw.TenantID); err != nil {
+		return fmt.Errorf("replace read-only switch: %w", err)
+	}
+	err = tx.QueryRow(ctx, `
+		INSERT INTO read_only_switches (tenant_id, reason, enabled_by)
+		VALUES ($1, $2, $3)
+		RETURNING id, created_at`,
+		sw.TenantID, sw.Reason, sw.EnabledBy,
+	).Scan(&sw.ID, &sw.CreatedAt)
+	if err != nil {
+		return fmt.Errorf("insert read-only switch: %w", err)
+	}
+	return tx.Commit(ctx)
+}
+
+// Clear turns off the switch of tenantID, or the global one when tenantID
+// is nil.
+func (s *ReadOnlyStore) Clear(ctx context.Context, tenantID *uuid.UUID) error {
+	tag, err := s.db.Pool.Exec(ctx, `
+		DELETE FROM read_only_switches WHERE tenant_id IS NOT DISTINCT FROM $1`, tenantID)
+	if err != nil {
+		return fmt.Errorf("delete read-only switch: %w", err)
+	}
+	if tag.RowsAffected() == 0 {
+		return fmt.Errorf("read-only switch not found")
+	}
+	return nil
+}
+
+// ─── Private helpers ──────────────────────────────────────────────────────
+
+const readOnlyColumns = `id, tenant_id, reason, enabled_by, created_at`
+
+func scanReadOnlyOrNil(row scanner) (*ReadOnlySwitch, error) {
+	var sw ReadOnlySwitch
+	err := row.Scan(&sw.ID, &sw.TenantID, &sw.Reason, &sw.EnabledBy, &sw.CreatedAt)
+	if err == pgx.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("read-only switch: %w", err)
+	}
+	return &sw, nil
+}
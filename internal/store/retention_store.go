@@ -0,0 +1,99 @@
+package store
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/aegisx/aegisx/internal/config"
+)
+
+// Tables subject to retention.
+const (
+	TableIDSAlerts        = "ids_alerts"
+	TableFirewallEvents   = "firewall_events"
+	TableAuditLog         = "audit_log"
+	TableVPNPeerStats     = "vpn_peer_stats"
+	TableMetricsSnapshots = "metrics_snapshots"
+	TableMonitorEvents    = "monitor_events"
+	TableWANTests         = "wan_tests"
+)
+
+// retentionColumns maps each table subject to retention to the column that
+// dates its rows.
+var retentionColumns = map[string]string{
+	TableIDSAlerts:        "timestamp",
+	TableFirewallEvents:   "timestamp",
+	TableAuditLog:         "created_at",
+	TableVPNPeerStats:     "bucket",
+	TableMetricsSnapshots: "timestamp",
+	TableMonitorEvents:    "at",
+	TableWANTests:         "started_at",
+}
+
+// RetentionStore prunes aged rows from the append-only tables.
+type RetentionStore struct{ db *DB }
+
+func NewRetentionStore(db *DB) *RetentionStore { return &RetentionStore{db: db} }
+
+// PruneBatch deletes up to limit rows of table dated before cutoff, oldest
+// first, and returns how many it deleted. When archive is set it receives
+// the rows as JSON objects before the delete commits; if it fails, nothing
+// is deleted.
+func (s *RetentionStore) PruneBatch(ctx context.Context, table string, before time.Time, limit int,
+	archive func(rows []json.RawMessage) error) (int, error) {
+	col, ok := retentionColumns[table]
+	if !ok {
+		return 0, fmt.Errorf("no retention for table %q", table)
+	}
+
+	tx, err := s.db.Pool.Begin(ctx)
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback(ctx)
+
+	// SQLite has rowid for ctid, and row_to_json spelled out.
+	rowID, row := "ctid", "row_to_json("+table+".*)"
+	if s.db.driver == config.DriverSQLite {
+		rowID = "rowid"
+		if row, err = sqliteRowJSON(ctx, tx, table, ""); err != nil {
+			return 0, fmt.Errorf("prune %s: %w", table, err)
+		}
+	}
+	rows, err := tx.Query(ctx, fmt.Sprintf(`
+		DELETE FROM %[1]s
+		WHERE %[3]s IN (
+			SELECT %[3]s FROM %[1]s WHERE %[2]s < $1 ORDER BY %[2]s LIMIT $2)
+		RETURNING %[4]s`, table, col, rowID, row), before, limit)
+	if err != nil {
+		return 0, fmt.Errorf("prune %s: %w", table, err)
+	}
+	var deleted []json.RawMessage
+	for rows.Next() {
+		var row []byte
+		if err := rows.Scan(&row); err != nil {
+			rows.Close()
+			return 0, err
+		}
+		deleted = append(deleted, row)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return 0, fmt.Errorf("prune %s: %w", table, err)
+	}
+	if len(deleted) == 0 {
+		return 0, nil
+	}
+
+	if archive != nil {
+		if err := archive(deleted); err != nil {
+			return 0, fmt.Errorf("archive %s: %w", table, err)
+		}
+	}
+	if err := tx.Commit(ctx); err != nil {
+		return 0, err
+	}
+	return len(deleted), nil
+}
@@ -0,0 +1,844 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"embed"
+	"encoding"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/mattn/go-sqlite3"
+	"go.uber.org/zap"
+
+	"github.com/aegisx/aegisx/internal/config"
+)
+
+// The stores are written against pgx and PostgreSQL. For the appliance
+// profile the same stores run on SQLite: sqlitePool offers the methods of
+// pgxpool.Pool over database/sql, rewrites each query into SQLite's dialect
+// (see sqliteSQL) and converts arguments and results so that the values the
+// stores scan match what pgx would return.
+
+// sqliteDriverName is the database/sql driver of the SQLite pool: go-sqlite3
+// with the functions of sqliteFuncs registered on every connection.
+const sqliteDriverName = "aegisx-sqlite3"
+
+// sqliteParams are added to the DSN: foreign keys are off in SQLite unless
+// asked for, WAL lets readers run beside the single writer, and
+// transactions take the write lock up front so that two of them cannot
+// deadlock upgrading their read locks.
+const sqliteParams = "_foreign_keys=on&_journal_mode=WAL&_busy_timeout=5000&_txlock=immediate"
+
+//go:embed migrations/sqlite/*.sql
+var sqliteMigrations embed.FS
+
+func init() {
+	sql.Register(sqliteDriverName, &sqlite3.SQLiteDriver{ConnectHook: registerSQLiteFuncs})
+}
+
+// connectSQLite opens the SQLite database file named by cfg.DSN, creating
+// it if needed.
+func connectSQLite(ctx context.Context, cfg config.DatabaseConfig, log *zap.Logger) (*DB, error) {
+	file := cfg.DSN
+	switch {
+	case file == "":
+		return nil, fmt.Errorf("database.dsn: the path of the SQLite file is required")
+	case strings.Contains(file, "://") || strings.Contains(file, "host="):
+		return nil, fmt.Errorf("database.dsn looks like a PostgreSQL DSN; set database.driver to %s or make the DSN a file path", config.DriverPostgres)
+	}
+	if name, _, _ := strings.Cut(file, "?"); name != ":memory:" {
+		if err := os.MkdirAll(filepath.Dir(name), 0o750); err != nil {
+			return nil, fmt.Errorf("create database directory: %w", err)
+		}
+	}
+	sep := "?"
+	if strings.Contains(file, "?") {
+		sep = "&"
+	}
+
+	db, err := sql.Open(sqliteDriverName, file+sep+sqliteParams)
+	if err != nil {
+		return nil, fmt.Errorf("open database: %w", err)
+	}
+	db.SetMaxOpenConns(cfg.MaxOpenConns)
+	db.SetMaxIdleConns(cfg.MaxIdleConns)
+	db.SetConnMaxLifetime(cfg.ConnMaxLifetime)
+
+	if err := db.PingContext(ctx); err != nil {
+		db.Close()
+		return nil, fmt.Errorf("ping database: %w", err)
+	}
+
+	log.Info("database connected", zap.String("driver", config.DriverSQLite), zap.String("path", file))
+	return &DB{Pool: &sqlitePool{db: db}, driver: config.DriverSQLite, log: log}, nil
+}
+
+// migrateSQLite applies the embedded SQLite migrations not yet recorded in
+// schema_migrations, each in its own transaction.
+func (db *DB) migrateSQLite(ctx context.Context) error {
+	db.log.Info("running migrations", zap.String("driver", config.DriverSQLite))
+
+	_, err := db.Pool.Exec(ctx, `
+		CREATE TABLE IF NOT EXISTS schema_migrations (
+			version     INT PRIMARY KEY,
+			applied_at  TIMESTAMPTZ NOT NULL DEFAULT (now())
+		)
+	`)
+	if err != nil {
+		return fmt.Errorf("create schema_migrations: %w", err)
+	}
+
+	files, err := sqliteMigrationFiles()
+	if err != nil {
+		return err
+	}
+	for _, f := range files {
+		version, err := migrationVersion(f)
+		if err != nil {
+			return err
+		}
+		var done bool
+		if err := db.Pool.QueryRow(ctx, `
+			SELECT EXISTS (SELECT 1 FROM schema_migrations WHERE version = $1)`, version).Scan(&done); err != nil {
+			return fmt.Errorf("check migration %s: %w", f, err)
+		}
+		if done {
+			continue
+		}
+		if err := db.applySQLiteMigration(ctx, f, version); err != nil {
+			return fmt.Errorf("apply %s: %w", f, err)
+		}
+		db.log.Info("migration applied", zap.String("file", f))
+	}
+
+	db.log.Info("migrations complete")
+	return nil
+}
+
+func (db *DB) applySQLiteMigration(ctx context.Context, file string, version int) error {
+	data, err := sqliteMigrations.ReadFile(path.Join("migrations/sqlite", file))
+	if err != nil {
+		return err
+	}
+	tx, err := db.Pool.Begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback(ctx)
+
+	if _, err := tx.Exec(ctx, string(data)); err != nil {
+		return err
+	}
+	if _, err := tx.Exec(ctx, `INSERT INTO schema_migrations (version) VALUES ($1)`, version); err != nil {
+		return err
+	}
+	return tx.Commit(ctx)
+}
+
+// checkSQLiteSchema is CheckSchema for SQLite, where migrations are
+// recorded in schema_migrations.
+func (db *DB) checkSQLiteSchema(ctx context.Context) (applied int, missing []string, err error) {
+	files, err := sqliteMigrationFiles()
+	if err != nil {
+		return 0, nil, err
+	}
+	var tracked bool
+	if err := db.Pool.QueryRow(ctx, `
+		SELECT EXISTS (SELECT 1 FROM sqlite_master WHERE type = 'table' AND name = 'schema_migrations')`).Scan(&tracked); err != nil {
+		return 0, nil, err
+	}
+	for _, f := range files {
+		version, err := migrationVersion(f)
+		if err != nil {
+			return 0, nil, err
+		}
+		var done bool
+		if tracked {
+			if err := db.Pool.QueryRow(ctx, `
+				SELECT EXISTS (SELECT 1 FROM schema_migrations WHERE version = $1)`, version).Scan(&done); err != nil {
+				return 0, nil, fmt.Errorf("check migration %s: %w", f, err)
+			}
+		}
+		if done {
+			applied++
+		} else {
+			missing = append(missing, f)
+		}
+	}
+	return applied, missing, nil
+}
+
+func sqliteMigrationFiles() ([]string, error) {
+	files, err := fs.Glob(sqliteMigrations, "migrations/sqlite/*.sql")
+	if err != nil {
+		return nil, err
+	}
+	for i, f := range files {
+		files[i] = path.Base(f)
+	}
+	sort.Strings(files)
+	return files, nil
+}
+
+// migrationVersion returns the number a migration file name starts with.
+func migrationVersion(file string) (int, error) {
+	n, _, _ := strings.Cut(file, "_")
+	v, err := strconv.Atoi(n)
+	if err != nil {
+		return 0, fmt.Errorf("migration %s: name must start with its version", file)
+	}
+	return v, nil
+}
+
+// ─── Pool adapter ─────────────────────────────────────────────────────────
+
+// sqlConn is what sqlitePool and sqliteTx run statements on.
+type sqlConn interface {
+	ExecContext(ctx context.Context, query string, args ...any) (sql.Result, error)
+	QueryContext(ctx context.Context, query string, args ...any) (*sql.Rows, error)
+}
+
+// sqlitePool implements Pool over a SQLite database.
+type sqlitePool struct{ db *sql.DB }
+
+func (p *sqlitePool) Exec(ctx context.Context, query string, args ...any) (pgconn.CommandTag, error) {
+	return sqliteExec(ctx, p.db, query, args)
+}
+
+func (p *sqlitePool) Query(ctx context.Context, query string, args ...any) (pgx.Rows, error) {
+	return sqliteQuery(ctx, p.db, query, args)
+}
+
+func (p *sqlitePool) QueryRow(ctx context.Context, query string, args ...any) pgx.Row {
+	rows, err := sqliteQuery(ctx, p.db, query, args)
+	return &sqliteRow{rows: rows, err: err}
+}
+
+func (p *sqlitePool) Begin(ctx context.Context) (pgx.Tx, error) {
+	return p.BeginTx(ctx, pgx.TxOptions{})
+}
+
+// BeginTx starts a transaction. SQLite transactions are serializable and
+// take the write lock when they start (see sqliteParams), so opts are not
+// needed.
+func (p *sqlitePool) BeginTx(ctx context.Context, opts pgx.TxOptions) (pgx.Tx, error) {
+	tx, err := p.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, sqliteError(err)
+	}
+	return &sqliteTx{tx: tx}, nil
+}
+
+// SendBatch runs the queued queries in one transaction, as PostgreSQL runs
+// a batch sent outside a transaction.
+func (p *sqlitePool) SendBatch(ctx context.Context, b *pgx.Batch) pgx.BatchResults {
+	tx, err := p.db.BeginTx(ctx, nil)
+	if err != nil {
+		return &sqliteBatch{err: sqliteError(err)}
+	}
+	return &sqliteBatch{ctx: ctx, conn: tx, tx: tx, queued: b.QueuedQueries}
+}
+
+func (p *sqlitePool) Ping(ctx context.Context) error { return p.db.PingContext(ctx) }
+
+func (p *sqlitePool) Close() { p.db.Close() }
+
+// sqliteTx implements pgx.Tx over a SQLite transaction.
+type sqliteTx struct{ tx *sql.Tx }
+
+func (t *sqliteTx) Begin(ctx context.Context) (pgx.Tx, error) {
+	return nil, errors.New("sqlite: nested transactions are not supported")
+}
+
+func (t *sqliteTx) Commit(ctx context.Context) error {
+	if err := t.tx.Commit(); err != nil {
+		if errors.Is(err, sql.ErrTxDone) {
+			return pgx.ErrTxClosed
+		}
+		return sqliteError(err)
+	}
+	return nil
+}
+
+func (t *sqliteTx) Rollback(ctx context.Context) error {
+	if err := t.tx.Rollback(); err != nil {
+		if errors.Is(err, sql.ErrTxDone) {
+			return pgx.ErrTxClosed
+		}
+		return sqliteError(err)
+	}
+	return nil
+}
+
+func (t *sqliteTx) CopyFrom(ctx context.Context, table pgx.Identifier, columns []string, src pgx.CopyFromSource) (int64, error) {
+	return 0, errors.New("sqlite: COPY is not supported")
+}
+
+func (t *sqliteTx) SendBatch(ctx context.Context, b *pgx.Batch) pgx.BatchResults {
+	return &sqliteBatch{ctx: ctx, conn: t.tx, queued: b.QueuedQueries}
+}
+
+func (t *sqliteTx) LargeObjects() pgx.LargeObjects { return pgx.LargeObjects{} }
+
+func (t *sqliteTx) Prepare(ctx context.Context, name, query string) (*pgconn.StatementDescription, error) {
+	return nil, errors.New("sqlite: prepared statements are not supported")
+}
+
+func (t *sqliteTx) Exec(ctx context.Context, query string, args ...any) (pgconn.CommandTag, error) {
+	return sqliteExec(ctx, t.tx, query, args)
+}
+
+func (t *sqliteTx) Query(ctx context.Context, query string, args ...any) (pgx.Rows, error) {
+	return sqliteQuery(ctx, t.tx, query, args)
+}
+
+func (t *sqliteTx) QueryRow(ctx context.Context, query string, args ...any) pgx.Row {
+	rows, err := sqliteQuery(ctx, t.tx, query, args)
+	return &sqliteRow{rows: rows, err: err}
+}
+
+func (t *sqliteTx) Conn() *pgx.Conn { return nil }
+
+// sqliteBatch implements pgx.BatchResults. The queries run one by one as
+// their results are read; Close runs the rest and, for a batch sent
+// outside a transaction, commits unless one failed.
+type sqliteBatch struct {
+	ctx    context.Context
+	conn   sqlConn
+	tx     *sql.Tx // set when the batch has its own transaction
+	queued []*pgx.QueuedQuery
+	err    error
+}
+
+func (b *sqliteBatch) next() (*pgx.QueuedQuery, error) {
+	if b.err != nil {
+		return nil, b.err
+	}
+	if len(b.queued) == 0 {
+		return nil, errors.New("sqlite: no more results in batch")
+	}
+	q := b.queued[0]
+	b.queued = b.queued[1:]
+	return q, nil
+}
+
+func (b *sqliteBatch) Exec() (pgconn.CommandTag, error) {
+	q, err := b.next()
+	if err != nil {
+		return pgconn.CommandTag{}, err
+	}
+	tag, err := sqliteExec(b.ctx, b.conn, q.SQL, q.Arguments)
+	if err != nil {
+		b.err = err
+	}
+	return tag, err
+}
+
+func (b *sqliteBatch) Query() (pgx.Rows, error) {
+	q, err := b.next()
+	if err != nil {
+		return nil, err
+	}
+	rows, err := sqliteQuery(b.ctx, b.conn, q.SQL, q.Arguments)
+	if err != nil {
+		b.err = err
+	}
+	return rows, err
+}
+
+func (b *sqliteBatch) QueryRow() pgx.Row {
+	rows, err := b.Query()
+	return &sqliteRow{rows: rows, err: err}
+}
+
+func (b *sqliteBatch) Close() error {
+	for len(b.queued) > 0 && b.err == nil {
+		b.Exec()
+	}
+	if b.tx != nil {
+		if b.err != nil {
+			b.tx.Rollback()
+		} else if err := b.tx.Commit(); err != nil {
+			b.err = sqliteError(err)
+		}
+		b.tx = nil
+	}
+	return b.err
+}
+
+func sqliteExec(ctx context.Context, c sqlConn, query string, args []any) (pgconn.CommandTag, error) {
+	vals, err := sqliteArgs(args)
+	if err != nil {
+		return pgconn.CommandTag{}, err
+	}
+	query = sqliteSQL(query)
+	res, err := c.ExecContext(ctx, query, vals...)
+	if err != nil {
+		return pgconn.CommandTag{}, sqliteError(err)
+	}
+	n, err := res.RowsAffected()
+	if err != nil {
+		return pgconn.CommandTag{}, err
+	}
+	verb, _, _ := strings.Cut(strings.TrimSpace(query), " ")
+	return pgconn.NewCommandTag(fmt.Sprintf("%s %d", strings.ToUpper(verb), n)), nil
+}
+
+func sqliteQuery(ctx context.Context, c sqlConn, query string, args []any) (pgx.Rows, error) {
+	vals, err := sqliteArgs(args)
+	if err != nil {
+		return nil, err
+	}
+	rows, err := c.QueryContext(ctx, sqliteSQL(query), vals...)
+	if err != nil {
+		return nil, sqliteError(err)
+	}
+	return &sqliteRows{rows: rows}, nil
+}
+
+// sqliteRows implements pgx.Rows.
+type sqliteRows struct {
+	rows *sql.Rows
+	err  error
+}
+
+func (r *sqliteRows) Close() { r.rows.Close() }
+
+func (r *sqliteRows) Err() error {
+	if r.err != nil {
+		return r.err
+	}
+	return sqliteError(r.rows.Err())
+}
+
+func (r *sqliteRows) CommandTag() pgconn.CommandTag { return pgconn.CommandTag{} }
+
+func (r *sqliteRows) FieldDescriptions() []pgconn.FieldDescription {
+	cols, _ := r.rows.Columns()
+	out := make([]pgconn.FieldDescription, len(cols))
+	for i, c := range cols {
+		out[i].Name = c
+	}
+	return out
+}
+
+func (r *sqliteRows) Next() bool {
+	if r.err != nil {
+		return false
+	}
+	return r.rows.Next()
+}
+
+func (r *sqliteRows) Scan(dest ...any) error {
+	vals, err := r.Values()
+	if err != nil {
+		return err
+	}
+	if len(dest) != len(vals) {
+		return fmt.Errorf("sqlite: %d columns, %d scan targets", len(vals), len(dest))
+	}
+	for i, d := range dest {
+		if d == nil {
+			continue
+		}
+		if err := sqliteScan(d, vals[i]); err != nil {
+			return fmt.Errorf("scan column %d: %w", i, err)
+		}
+	}
+	return nil
+}
+
+func (r *sqliteRows) Values() ([]any, error) {
+	cols, err := r.rows.Columns()
+	if err != nil {
+		return nil, err
+	}
+	vals := make([]any, len(cols))
+	ptrs := make([]any, len(cols))
+	for i := range vals {
+		ptrs[i] = &vals[i]
+	}
+	if err := r.rows.Scan(ptrs...); err != nil {
+		return nil, err
+	}
+	return vals, nil
+}
+
+func (r *sqliteRows) RawValues() [][]byte { return nil }
+
+func (r *sqliteRows) Conn() *pgx.Conn { return nil }
+
+// sqliteRow implements pgx.Row.
+type sqliteRow struct {
+	rows pgx.Rows
+	err  error
+}
+
+func (r *sqliteRow) Scan(dest ...any) error {
+	if r.err != nil {
+		return r.err
+	}
+	defer r.rows.Close()
+	if !r.rows.Next() {
+		if err := r.rows.Err(); err != nil {
+			return err
+		}
+		return pgx.ErrNoRows
+	}
+	if err := r.rows.Scan(dest...); err != nil {
+		return err
+	}
+	r.rows.Close()
+	return r.rows.Err()
+}
+
+// ─── Values ───────────────────────────────────────────────────────────────
+
+// sqliteTimeLayout is how timestamps are stored: UTC with a fixed number
+// of digits, so that they sort and compare as text.
+const sqliteTimeLayout = "2006-01-02T15:04:05.000000Z"
+
+func sqliteTime(t time.Time) string { return t.UTC().Format(sqliteTimeLayout) }
+
+func parseSQLiteTime(s string) (time.Time, error) {
+	for _, layout := range []string{sqliteTimeLayout, time.RFC3339Nano, "2006-01-02 15:04:05"} {
+		if t, err := time.Parse(layout, s); err == nil {
+			return t, nil
+		}
+	}
+	return time.Time{}, fmt.Errorf("invalid timestamp %q", s)
+}
+
+func sqliteArgs(args []any) ([]any, error) {
+	out := make([]any, len(args))
+	for i, a := range args {
+		v, err := sqliteArg(a)
+		if err != nil {
+			return nil, fmt.Errorf("argument %d: %w", i+1, err)
+		}
+		out[i] = v
+	}
+	return out, nil
+}
+
+// sqliteArg converts a query argument to a value SQLite stores the way the
+// column types of the SQLite schema expect: timestamps as sqliteTimeLayout
+// text, UUIDs as text, and slices, maps and structs as JSON.
+func sqliteArg(v any) (any, error) {
+	if v == nil {
+		return nil, nil
+	}
+	rv := reflect.ValueOf(v)
+	if rv.Kind() == reflect.Pointer {
+		if rv.IsNil() {
+			return nil, nil
+		}
+		return sqliteArg(rv.Elem().Interface())
+	}
+	switch v := v.(type) {
+	case string, bool, int64, float64:
+		return v, nil
+	case time.Time:
+		return sqliteTime(v), nil
+	case json.RawMessage:
+		if v == nil {
+			return nil, nil
+		}
+		return string(v), nil
+	case []byte:
+		// pgx sends a []byte to a JSONB column as the document itself,
+		// so JSON stays text; anything else is a BLOB.
+		if v == nil {
+			return nil, nil
+		}
+		if json.Valid(v) {
+			return string(v), nil
+		}
+		return v, nil
+	case driver.Valuer:
+		val, err := v.Value()
+		if err != nil {
+			return nil, err
+		}
+		return sqliteArg(val)
+	case encoding.TextMarshaler:
+		b, err := v.MarshalText()
+		return string(b), err
+	}
+	switch rv.Kind() {
+	case reflect.Bool:
+		return rv.Bool(), nil
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return rv.Int(), nil
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return int64(rv.Uint()), nil
+	case reflect.Float32, reflect.Float64:
+		return rv.Float(), nil
+	case reflect.String:
+		return rv.String(), nil
+	case reflect.Slice, reflect.Map:
+		if rv.IsNil() {
+			return nil, nil
+		}
+	}
+	b, err := json.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+	return string(b), nil
+}
+
+// sqliteScan stores src, a value read from SQLite, in dst, converting it
+// as pgx would convert the PostgreSQL value: TIMESTAMPTZ text becomes a
+// time.Time, JSON text an array, map or struct, and so on. NULL sets dst
+// to its zero value.
+func sqliteScan(dst, src any) error {
+	if s, ok := dst.(sql.Scanner); ok {
+		return s.Scan(src)
+	}
+	if b, ok := src.([]byte); ok && !isBytesDest(dst) {
+		src = string(b)
+	}
+
+	switch d := dst.(type) {
+	case *any:
+		*d = src
+		return nil
+	case *string:
+		switch v := src.(type) {
+		case nil:
+			*d = ""
+		case string:
+			*d = v
+		case int64:
+			*d = strconv.FormatInt(v, 10)
+		case float64:
+			*d = strconv.FormatFloat(v, 'g', -1, 64)
+		case bool:
+			*d = strconv.FormatBool(v)
+		case time.Time:
+			*d = sqliteTime(v)
+		default:
+			return fmt.Errorf("cannot scan %T into *string", src)
+		}
+		return nil
+	case *[]byte:
+		b, err := sqliteBytes(src)
+		*d = b
+		return err
+	case *json.RawMessage:
+		b, err := sqliteBytes(src)
+		*d = b
+		return err
+	case *bool:
+		switch v := src.(type) {
+		case nil:
+			*d = false
+		case bool:
+			*d = v
+		case int64:
+			*d = v != 0
+		case float64:
+			*d = v != 0
+		case string:
+			b, err := strconv.ParseBool(v)
+			*d = b
+			return err
+		default:
+			return fmt.Errorf("cannot scan %T into *bool", src)
+		}
+		return nil
+	case *time.Time:
+		switch v := src.(type) {
+		case nil:
+			*d = time.Time{}
+		case time.Time:
+			*d = v
+		case string:
+			t, err := parseSQLiteTime(v)
+			*d = t
+			return err
+		default:
+			return fmt.Errorf("cannot scan %T into *time.Time", src)
+		}
+		return nil
+	}
+
+	rv := reflect.ValueOf(dst)
+	if rv.Kind() != reflect.Pointer || rv.IsNil() {
+		return fmt.Errorf("cannot scan into %T", dst)
+	}
+	ev := rv.Elem()
+	if src == nil {
+		ev.Set(reflect.Zero(ev.Type()))
+		return nil
+	}
+	switch ev.Kind() {
+	case reflect.Pointer:
+		nv := reflect.New(ev.Type().Elem())
+		if err := sqliteScan(nv.Interface(), src); err != nil {
+			return err
+		}
+		ev.Set(nv)
+		return nil
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		n, err := sqliteInt(src)
+		ev.SetInt(n)
+		return err
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		n, err := sqliteInt(src)
+		ev.SetUint(uint64(n))
+		return err
+	case reflect.Float32, reflect.Float64:
+		var f float64
+		switch v := src.(type) {
+		case float64:
+			f = v
+		case int64:
+			f = float64(v)
+		case string:
+			var err error
+			if f, err = strconv.ParseFloat(v, 64); err != nil {
+				return err
+			}
+		default:
+			return fmt.Errorf("cannot scan %T into %T", src, dst)
+		}
+		ev.SetFloat(f)
+		return nil
+	case reflect.String:
+		var s string
+		if err := sqliteScan(&s, src); err != nil {
+			return err
+		}
+		ev.SetString(s)
+		return nil
+	case reflect.Bool:
+		var b bool
+		if err := sqliteScan(&b, src); err != nil {
+			return err
+		}
+		ev.SetBool(b)
+		return nil
+	}
+	if s, ok := src.(string); ok {
+		return json.Unmarshal([]byte(s), dst)
+	}
+	return fmt.Errorf("cannot scan %T into %T", src, dst)
+}
+
+func isBytesDest(dst any) bool {
+	switch dst.(type) {
+	case *[]byte, *json.RawMessage, *any:
+		return true
+	}
+	return false
+}
+
+func sqliteBytes(src any) ([]byte, error) {
+	switch v := src.(type) {
+	case nil:
+		return nil, nil
+	case []byte:
+		return append([]byte(nil), v...), nil
+	case string:
+		return []byte(v), nil
+	case int64:
+		return strconv.AppendInt(nil, v, 10), nil
+	case float64:
+		return strconv.AppendFloat(nil, v, 'g', -1, 64), nil
+	case bool:
+		return strconv.AppendBool(nil, v), nil
+	}
+	return nil, fmt.Errorf("cannot scan %T into bytes", src)
+}
+
+func sqliteInt(src any) (int64, error) {
+	switch v := src.(type) {
+	case int64:
+		return v, nil
+	case float64:
+		return int64(v), nil
+	case bool:
+		if v {
+			return 1, nil
+		}
+		return 0, nil
+	case string:
+		return strconv.ParseInt(v, 10, 64)
+	}
+	return 0, fmt.Errorf("cannot scan %T into an integer", src)
+}
+
+// ─── Rows as JSON ─────────────────────────────────────────────────────────
+
+// querier is a Pool or a pgx.Tx.
+type querier interface {
+	Query(ctx context.Context, sql string, args ...any) (pgx.Rows, error)
+}
+
+// sqliteRowJSON returns an expression building a row of table as a JSON
+// object, SQLite's row_to_json. JSONB columns are embedded as JSON,
+// BOOLEAN ones as true or false and BYTEA ones as \x and hex, as
+// PostgreSQL prints them. alias, if set, qualifies the columns.
+func sqliteRowJSON(ctx context.Context, q querier, table, alias string) (string, error) {
+	cols, err := sqliteColumns(ctx, q, table)
+	if err != nil {
+		return "", err
+	}
+	if len(cols) == 0 {
+		return "", fmt.Errorf("table %s not found", table)
+	}
+	parts := make([]string, 0, 2*len(cols))
+	for _, c := range cols {
+		col := pgx.Identifier{c.name}.Sanitize()
+		if alias != "" {
+			col = alias + "." + col
+		}
+		switch c.typ {
+		case "JSONB":
+			col = "json(" + col + ")"
+		case "BOOLEAN":
+			col = "json(CASE WHEN " + col + " THEN 'true' WHEN NOT " + col + " THEN 'false' END)"
+		case "BYTEA":
+			col = `'\x' || lower(hex(` + col + `))`
+		}
+		parts = append(parts, "'"+strings.ReplaceAll(c.name, "'", "''")+"'", col)
+	}
+	return "json_object(" + strings.Join(parts, ", ") + ")", nil
+}
+
+type sqliteColumn struct{ name, typ string }
+
+// sqliteColumns returns the columns of table, in order, with their
+// declared types in upper case.
+func sqliteColumns(ctx context.Context, q querier, table string) ([]sqliteColumn, error) {
+	rows, err := q.Query(ctx, `SELECT name, upper(type) FROM pragma_table_info($1) ORDER BY cid`, table)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var out []sqliteColumn
+	for rows.Next() {
+		var c sqliteColumn
+		if err := rows.Scan(&c.name, &c.typ); err != nil {
+			return nil, err
+		}
+		out = append(out, c)
+	}
+	return out, rows.Err()
+}
@@ -0,0 +1,433 @@
+package store
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"math"
+	"net/netip"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/mattn/go-sqlite3"
+)
+
+// sqliteSQL rewrites a query written for PostgreSQL into SQLite's dialect.
+// It covers what the stores use and nothing more:
+//
+//   - $1 placeholders become ?1;
+//   - casts are dropped, or become CAST(x AS TEXT), inet(x) or cidr(x);
+//   - x = ANY($1) becomes x IN (SELECT value FROM json_each(?1)), arrays
+//     being sent as JSON (see sqliteArg);
+//   - IS [NOT] DISTINCT FROM becomes IS [NOT];
+//   - NOW() + make_interval(secs => n), extract(epoch FROM t) and
+//     (array_agg(x))[1:n] become calls to the functions of sqliteFuncs;
+//   - OFFSET without LIMIT gets LIMIT -1.
+//
+// String literals, quoted identifiers and comments are left as they are.
+//
+// Other functions PostgreSQL has and SQLite lacks, such as host and
+// masklen, are provided by sqliteFuncs under the same names.
+func sqliteSQL(q string) string {
+	var out strings.Builder
+	out.Grow(len(q))
+	var (
+		parens     []int // offsets in out of the open parentheses
+		groupStart = -1  // offset in out of the group that just closed
+		groupEnd   = -1
+		litStart   = -1 // offset in out of the literal that just ended
+		litEnd     = -1
+		quoted     []string // literals and comments, held out of the rewrites
+	)
+	for i := 0; i < len(q); i++ {
+		c := q[i]
+		switch {
+		case c == '\'' || c == '"':
+			start := out.Len()
+			j := i + 1
+			for j < len(q) {
+				if q[j] == c {
+					if j+1 < len(q) && q[j+1] == c {
+						j += 2
+						continue
+					}
+					break
+				}
+				j++
+			}
+			if j >= len(q) {
+				j = len(q) - 1
+			}
+			out.WriteString(hold(&quoted, q[i:j+1]))
+			litStart, litEnd = start, out.Len()
+			i = j
+		case c == '-' && strings.HasPrefix(q[i:], "--"):
+			j := strings.IndexByte(q[i:], '\n')
+			if j < 0 {
+				j = len(q) - i
+			}
+			out.WriteString(hold(&quoted, q[i:i+j]))
+			i += j - 1
+		case c == '$' && i+1 < len(q) && isDigit(q[i+1]):
+			out.WriteByte('?')
+		case c == '(':
+			parens = append(parens, out.Len())
+			out.WriteByte(c)
+		case c == ')':
+			out.WriteByte(c)
+			if n := len(parens); n > 0 {
+				groupStart, groupEnd = parens[n-1], out.Len()
+				parens = parens[:n-1]
+			}
+		case c == ':' && strings.HasPrefix(q[i:], "::"):
+			j := i + 2
+			for j < len(q) && (isWordChar(q[j]) || q[j] == '[' || q[j] == ']') {
+				j++
+			}
+			typ := strings.ToLower(q[i+2 : j])
+			s := out.String()
+			start := castOperand(s, groupStart, groupEnd, litStart, litEnd)
+			operand := s[start:]
+			out.Reset()
+			out.WriteString(s[:start])
+			switch {
+			case typ == "text":
+				out.WriteString("CAST(" + operand + " AS TEXT)")
+			case typ == "inet" || typ == "cidr":
+				out.WriteString(typ + "(" + operand + ")")
+			default: // uuid, timestamptz, json, jsonb and arrays need no cast
+				out.WriteString(operand)
+			}
+			groupStart, groupEnd = start, out.Len()
+			litStart, litEnd = -1, -1
+			i = j - 1
+		default:
+			out.WriteByte(c)
+		}
+	}
+
+	s := out.String()
+	for _, r := range sqliteRewrites {
+		s = r.re.ReplaceAllString(s, r.repl)
+	}
+	s = limitOffsets(s)
+	return heldText.ReplaceAllStringFunc(s, func(m string) string {
+		n, _ := strconv.Atoi(m[1 : len(m)-1])
+		return quoted[n]
+	})
+}
+
+// heldText matches the stand-ins hold writes.
+var heldText = regexp.MustCompile("\x00[0-9]+\x00")
+
+// hold appends text to held and returns its stand-in, a NUL-delimited
+// index that no rewrite matches.
+func hold(held *[]string, text string) string {
+	*held = append(*held, text)
+	return "\x00" + strconv.Itoa(len(*held)-1) + "\x00"
+}
+
+// castOperand returns the offset in s where the operand of a cast that
+// follows s starts: the group or literal that just ended, or the word,
+// placeholder or function call before the cast.
+func castOperand(s string, groupStart, groupEnd, litStart, litEnd int) int {
+	end := len(s)
+	switch {
+	case end == litEnd:
+		return litStart
+	case end == groupEnd:
+		start := groupStart
+		for start > 0 && isWordChar(s[start-1]) {
+			start--
+		}
+		return start
+	}
+	start := end
+	for start > 0 && (isWordChar(s[start-1]) || s[start-1] == '.' || s[start-1] == '?') {
+		start--
+	}
+	return start
+}
+
+func isDigit(c byte) bool { return c >= '0' && c <= '9' }
+
+func isWordChar(c byte) bool {
+	return c == '_' || isDigit(c) || (c|0x20) >= 'a' && (c|0x20) <= 'z'
+}
+
+var sqliteRewrites = []struct {
+	re   *regexp.Regexp
+	repl string
+}{
+	{regexp.MustCompile(`(?i)\bNOW\(\)\s*\+\s*make_interval\(\s*secs\s*=>\s*([^()]+?)\s*\)`), `add_seconds(now(), $1)`},
+	{regexp.MustCompile(`(?i)=\s*ANY\s*\(\s*(\?\d+)\s*\)`), `IN (SELECT value FROM json_each($1))`},
+	{regexp.MustCompile(`(?i)\bIS\s+NOT\s+DISTINCT\s+FROM\b`), `IS`},
+	{regexp.MustCompile(`(?i)\bIS\s+DISTINCT\s+FROM\b`), `IS NOT`},
+	{regexp.MustCompile(`(?i)\bextract\(\s*epoch\s+FROM\s+`), `epoch(`},
+	{regexp.MustCompile(`(?i)\(\s*array_agg\(\s*(DISTINCT\s+)?([^()]+?)\s*\)\s*\)\[1:(\d+)\]`), `array_head(json_group_array($1$2), $3)`},
+}
+
+var (
+	offsetClause = regexp.MustCompile(`(?i)\bOFFSET\b`)
+	limitClause  = regexp.MustCompile(`(?i)\bLIMIT\s+\S+\s+$`)
+)
+
+// limitOffsets adds LIMIT -1 before each OFFSET that has no LIMIT, which
+// SQLite requires.
+func limitOffsets(s string) string {
+	locs := offsetClause.FindAllStringIndex(s, -1)
+	for k := len(locs) - 1; k >= 0; k-- {
+		at := locs[k][0]
+		if !limitClause.MatchString(s[:at]) {
+			s = s[:at] + "LIMIT -1 " + s[at:]
+		}
+	}
+	return s
+}
+
+// ─── Functions ────────────────────────────────────────────────────────────
+
+// sqliteFuncs are registered on every SQLite connection. The first group
+// stands in for PostgreSQL functions of the same name; the second is what
+// sqliteSQL rewrites PostgreSQL expressions into.
+var sqliteFuncs = []struct {
+	name string
+	impl any
+	pure bool
+}{
+	{"now", func() string { return sqliteTime(time.Now()) }, false},
+	{"gen_random_uuid", func() string { return uuid.NewString() }, false},
+	{"host", sqliteHost, true},
+	{"masklen", sqliteMasklen, true},
+	{"max_masklen", sqliteMaxMasklen, true},
+	{"text", sqliteText, true},
+	{"floor", sqliteFloor, true},
+	{"to_timestamp", sqliteToTimestamp, true},
+	{"audit_log_hash", auditLogHash, true},
+
+	{"inet", sqliteInet, true},
+	{"cidr", sqliteCIDR, true},
+	{"epoch", sqliteEpoch, true},
+	{"add_seconds", sqliteAddSeconds, true},
+	{"array_head", sqliteArrayHead, true},
+}
+
+func registerSQLiteFuncs(conn *sqlite3.SQLiteConn) error {
+	for _, f := range sqliteFuncs {
+		if err := conn.RegisterFunc(f.name, f.impl, f.pure); err != nil {
+			return fmt.Errorf("register %s: %w", f.name, err)
+		}
+	}
+	return nil
+}
+
+// parsePrefix parses an INET or CIDR value; a bare address is a host
+// prefix.
+func parsePrefix(v string) (netip.Prefix, error) {
+	if strings.Contains(v, "/") {
+		return netip.ParsePrefix(v)
+	}
+	a, err := netip.ParseAddr(v)
+	if err != nil {
+		return netip.Prefix{}, err
+	}
+	return netip.PrefixFrom(a, a.BitLen()), nil
+}
+
+// sqliteInet stores an INET value as PostgreSQL prints it: the address,
+// followed by the prefix length unless it is a single host.
+func sqliteInet(v any) (any, error) {
+	s, ok := sqliteString(v)
+	if !ok {
+		return nil, nil
+	}
+	p, err := parsePrefix(s)
+	if err != nil {
+		return nil, fmt.Errorf("invalid input syntax for type inet: %q", s)
+	}
+	if p.IsSingleIP() {
+		return p.Addr().String(), nil
+	}
+	return p.String(), nil
+}
+
+// sqliteCIDR stores a CIDR value as PostgreSQL prints it, always with the
+// prefix length. Like PostgreSQL it rejects bits set right of the mask.
+func sqliteCIDR(v any) (any, error) {
+	s, ok := sqliteString(v)
+	if !ok {
+		return nil, nil
+	}
+	p, err := parsePrefix(s)
+	if err != nil {
+		return nil, fmt.Errorf("invalid input syntax for type cidr: %q", s)
+	}
+	if p.Masked() != p {
+		return nil, fmt.Errorf("invalid cidr value: %q has bits set to right of mask", s)
+	}
+	return p.String(), nil
+}
+
+func sqliteHost(v any) (any, error) {
+	s, ok := sqliteString(v)
+	if !ok {
+		return nil, nil
+	}
+	p, err := parsePrefix(s)
+	if err != nil {
+		return nil, err
+	}
+	return p.Addr().String(), nil
+}
+
+func sqliteText(v any) (any, error) {
+	s, ok := sqliteString(v)
+	if !ok {
+		return nil, nil
+	}
+	p, err := parsePrefix(s)
+	if err != nil {
+		return nil, err
+	}
+	return p.String(), nil
+}
+
+func sqliteMasklen(v any) (any, error) {
+	s, ok := sqliteString(v)
+	if !ok {
+		return nil, nil
+	}
+	p, err := parsePrefix(s)
+	if err != nil {
+		return nil, err
+	}
+	return int64(p.Bits()), nil
+}
+
+func sqliteMaxMasklen(v any) (any, error) {
+	s, ok := sqliteString(v)
+	if !ok {
+		return nil, nil
+	}
+	p, err := parsePrefix(s)
+	if err != nil {
+		return nil, err
+	}
+	return int64(p.Addr().BitLen()), nil
+}
+
+func sqliteFloor(v any) any {
+	if f, ok := sqliteFloat(v); ok {
+		return math.Floor(f)
+	}
+	return nil
+}
+
+func sqliteToTimestamp(v any) any {
+	if f, ok := sqliteFloat(v); ok {
+		return sqliteTime(time.UnixMicro(int64(math.Round(f * 1e6))))
+	}
+	return nil
+}
+
+func sqliteEpoch(v any) (any, error) {
+	s, ok := sqliteString(v)
+	if !ok {
+		return nil, nil
+	}
+	t, err := parseSQLiteTime(s)
+	if err != nil {
+		return nil, err
+	}
+	return float64(t.UnixMicro()) / 1e6, nil
+}
+
+func sqliteAddSeconds(v, sec any) (any, error) {
+	s, ok := sqliteString(v)
+	n, isNum := sqliteFloat(sec)
+	if !ok || !isNum {
+		return nil, nil
+	}
+	t, err := parseSQLiteTime(s)
+	if err != nil {
+		return nil, err
+	}
+	return sqliteTime(t.Add(time.Duration(n * float64(time.Second)))), nil
+}
+
+// sqliteArrayHead returns the first n elements of a JSON array in sorted
+// order, as PostgreSQL's (array_agg(DISTINCT x))[1:n] does.
+func sqliteArrayHead(v string, n int) (string, error) {
+	var items []*string
+	if err := json.Unmarshal([]byte(v), &items); err != nil {
+		return "", err
+	}
+	sort.SliceStable(items, func(i, j int) bool {
+		switch {
+		case items[i] == nil: // NULL sorts last
+			return false
+		case items[j] == nil:
+			return true
+		}
+		return *items[i] < *items[j]
+	})
+	if len(items) > n {
+		items = items[:n]
+	}
+	b, err := json.Marshal(items)
+	return string(b), err
+}
+
+// auditLogHash is the SQLite audit_log_hash: the SHA-256, in hex, of the
+// audit entry's columns as a JSON array, in the order of the PostgreSQL
+// function. detail is included as the JSON document it holds.
+func auditLogHash(seq, prevHash, id, tenantID, userID, impersonationID, action, resource, resourceID,
+	detail, ipAddress, userAgent, status, createdAt any) (string, error) {
+	cols := []any{seq, prevHash, id, tenantID, userID, impersonationID, action, resource,
+		resourceID, detail, ipAddress, userAgent, status, createdAt}
+	for i, c := range cols {
+		if s, ok := sqliteString(c); ok {
+			cols[i] = s
+		} else if _, isBytes := c.([]byte); isBytes {
+			cols[i] = nil
+		}
+	}
+	if d, ok := cols[9].(string); ok && json.Valid([]byte(d)) {
+		cols[9] = json.RawMessage(d)
+	}
+	b, err := json.Marshal(cols)
+	if err != nil {
+		return "", err
+	}
+	sum := sha256.Sum256(b)
+	return hex.EncodeToString(sum[:]), nil
+}
+
+// sqliteString returns a TEXT or BLOB function argument as a string, and
+// false for NULL, which go-sqlite3 passes as a nil []byte.
+func sqliteString(v any) (string, bool) {
+	switch v := v.(type) {
+	case string:
+		return v, true
+	case []byte:
+		return string(v), v != nil
+	}
+	return "", false
+}
+
+// sqliteFloat returns a numeric function argument as a float64, and false
+// for anything else.
+func sqliteFloat(v any) (float64, bool) {
+	switch v := v.(type) {
+	case float64:
+		return v, true
+	case int64:
+		return float64(v), true
+	}
+	return 0, false
+}
@@ -0,0 +1,160 @@
+package store
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestSQLiteSQL(t *testing.T) {
+	tests := []struct {
+		name string
+		in   string
+		want string
+	}{
+		// Placeholders.
+		{"placeholders", `SELECT a FROM t WHERE a = $1 AND b = $12`, `SELECT a FROM t WHERE a = ?1 AND b = ?12`},
+		{"dollar not a placeholder", `SELECT a$b FROM t WHERE c = $`, `SELECT a$b FROM t WHERE c = $`},
+
+		// Literals, identifiers and comments pass through.
+		{"string literal", `SELECT '$1::text' FROM t WHERE a = $1`, `SELECT '$1::text' FROM t WHERE a = ?1`},
+		{"escaped quote", `SELECT 'it''s $1' FROM t`, `SELECT 'it''s $1' FROM t`},
+		{"quoted identifier", `SELECT "a$1" FROM t`, `SELECT "a$1" FROM t`},
+		{"comment", "SELECT a -- a = $1::text OFFSET 1\nFROM t", "SELECT a -- a = $1::text OFFSET 1\nFROM t"},
+		{"unterminated literal", `SELECT 'abc`, `SELECT 'abc`},
+		{"keywords in literal", `SELECT 'x = ANY($1) OFFSET 2' WHERE a IS DISTINCT FROM 'NOW() + make_interval(secs => 1)'`,
+			`SELECT 'x = ANY($1) OFFSET 2' WHERE a IS NOT 'NOW() + make_interval(secs => 1)'`},
+		{"literal in rewrite", `WHERE a = NULLIF($1, '')::inet OFFSET 0`, `WHERE a = inet(NULLIF(?1, '')) LIMIT -1 OFFSET 0`},
+
+		// Casts.
+		{"text cast", `SELECT id::text FROM t`, `SELECT CAST(id AS TEXT) FROM t`},
+		{"qualified text cast", `SELECT t.id::TEXT FROM t`, `SELECT CAST(t.id AS TEXT) FROM t`},
+		{"placeholder text cast", `WHERE a = $1::text`, `WHERE a = CAST(?1 AS TEXT)`},
+		{"group text cast", `SELECT (a || b)::text FROM t`, `SELECT CAST((a || b) AS TEXT) FROM t`},
+		{"call text cast", `SELECT host(ip)::text FROM t`, `SELECT CAST(host(ip) AS TEXT) FROM t`},
+		{"literal text cast", `SELECT 'x'::text`, `SELECT CAST('x' AS TEXT)`},
+		{"inet cast", `VALUES (NULLIF($8, '')::inet)`, `VALUES (inet(NULLIF(?8, '')))`},
+		{"cidr literal cast", `WHERE a >>= '10.0.0.0/8'::cidr`, `WHERE a >>= cidr('10.0.0.0/8')`},
+		{"uuid cast dropped", `WHERE id = $1::uuid`, `WHERE id = ?1`},
+		{"timestamptz cast dropped", `WHERE at < $2::timestamptz`, `WHERE at < ?2`},
+		{"jsonb cast dropped", `SET detail = $3::jsonb`, `SET detail = ?3`},
+		{"array cast dropped", `WHERE a = $1::text[]`, `WHERE a = ?1`},
+		{"chained casts", `SELECT $3::text[]::cidr[]`, `SELECT ?3`},
+		{"cast then text cast", `SELECT $1::uuid::text`, `SELECT CAST(?1 AS TEXT)`},
+
+		// Rewritten expressions.
+		{"make_interval", `SET expires_at = NOW() + make_interval(secs => $2)`, `SET expires_at = add_seconds(now(), ?2)`},
+		{"make_interval expression", `SET expires_at = now() + make_interval(secs => ttl_seconds)`, `SET expires_at = add_seconds(now(), ttl_seconds)`},
+		{"any", `WHERE id = ANY($1)`, `WHERE id IN (SELECT value FROM json_each(?1))`},
+		{"any spaced", `WHERE id = any ( $2 )`, `WHERE id IN (SELECT value FROM json_each(?2))`},
+		{"is not distinct from", `WHERE a IS NOT DISTINCT FROM $1`, `WHERE a IS ?1`},
+		{"is distinct from", `WHERE a is distinct from $1`, `WHERE a IS NOT ?1`},
+		{"extract epoch", `SELECT extract(epoch FROM s.bucket) / $3`, `SELECT epoch(s.bucket) / ?3`},
+		{"array_agg slice", `SELECT (array_agg(DISTINCT rule))[1:5] FROM t`, `SELECT array_head(json_group_array(DISTINCT rule), 5) FROM t`},
+		{"array_agg slice without distinct", `SELECT (array_agg(rule))[1:3] FROM t`, `SELECT array_head(json_group_array(rule), 3) FROM t`},
+
+		// OFFSET.
+		{"offset without limit", `SELECT a FROM t ORDER BY a OFFSET $1`, `SELECT a FROM t ORDER BY a LIMIT -1 OFFSET ?1`},
+		{"offset with limit", `SELECT a FROM t ORDER BY a LIMIT $1 OFFSET $2`, `SELECT a FROM t ORDER BY a LIMIT ?1 OFFSET ?2`},
+		{"offset in subquery", `SELECT a FROM (SELECT a FROM t OFFSET 2) LIMIT 1 OFFSET 1`, `SELECT a FROM (SELECT a FROM t LIMIT -1 OFFSET 2) LIMIT 1 OFFSET 1`},
+
+		{"unchanged", `SELECT a, b FROM t WHERE a = 1`, `SELECT a, b FROM t WHERE a = 1`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := sqliteSQL(tt.in); got != tt.want {
+				t.Errorf("sqliteSQL(%q)\n got %q\nwant %q", tt.in, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSQLitePrefixFuncs(t *testing.T) {
+	tests := []struct {
+		name    string
+		fn      func(any) (any, error)
+		in      any
+		want    any
+		wantErr bool
+	}{
+		{"inet host", sqliteInet, "10.0.0.1", "10.0.0.1", false},
+		{"inet host prefix", sqliteInet, "10.0.0.1/32", "10.0.0.1", false},
+		{"inet network", sqliteInet, "10.0.0.1/8", "10.0.0.1/8", false},
+		{"inet ipv6", sqliteInet, "2001:db8::1", "2001:db8::1", false},
+		{"inet bytes", sqliteInet, []byte("192.0.2.1"), "192.0.2.1", false},
+		{"inet null", sqliteInet, []byte(nil), nil, false},
+		{"inet invalid", sqliteInet, "nope", nil, true},
+		{"cidr host", sqliteCIDR, "1.2.3.4", "1.2.3.4/32", false},
+		{"cidr network", sqliteCIDR, "2001:db8::/32", "2001:db8::/32", false},
+		{"cidr host bits", sqliteCIDR, "10.0.0.1/8", nil, true},
+		{"host", sqliteHost, "10.0.0.1/8", "10.0.0.1", false},
+		{"text", sqliteText, "10.0.0.1", "10.0.0.1/32", false},
+		{"masklen", sqliteMasklen, "10.0.0.0/8", int64(8), false},
+		{"masklen host", sqliteMasklen, "2001:db8::1", int64(128), false},
+		{"max_masklen", sqliteMaxMasklen, "10.0.0.0/8", int64(32), false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := tt.fn(tt.in)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("got error %v, want error %v", err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("got %#v, want %#v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSQLiteArrayHead(t *testing.T) {
+	tests := []struct {
+		in   string
+		n    int
+		want string
+	}{
+		{`["c","a","b"]`, 2, `["a","b"]`},
+		{`["b",null,"a"]`, 3, `["a","b",null]`},
+		{`["a"]`, 5, `["a"]`},
+		{`[]`, 5, `[]`},
+	}
+	for _, tt := range tests {
+		got, err := sqliteArrayHead(tt.in, tt.n)
+		if err != nil {
+			t.Fatalf("sqliteArrayHead(%s, %d): %v", tt.in, tt.n, err)
+		}
+		if got != tt.want {
+			t.Errorf("sqliteArrayHead(%s, %d) = %s, want %s", tt.in, tt.n, got, tt.want)
+		}
+	}
+}
+
+func TestSQLiteTimeFuncs(t *testing.T) {
+	at := time.Date(2024, 5, 1, 12, 0, 0, 250000000, time.UTC)
+
+	sec, err := sqliteEpoch(sqliteTime(at))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if sec != float64(at.UnixMicro())/1e6 {
+		t.Errorf("epoch = %v, want %v", sec, float64(at.UnixMicro())/1e6)
+	}
+	if got := sqliteToTimestamp(sec); got != sqliteTime(at) {
+		t.Errorf("to_timestamp(epoch) = %v, want %v", got, sqliteTime(at))
+	}
+
+	later, err := sqliteAddSeconds(sqliteTime(at), int64(90))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := sqliteTime(at.Add(90 * time.Second)); later != want {
+		t.Errorf("add_seconds = %v, want %v", later, want)
+	}
+	if got, _ := sqliteAddSeconds(nil, int64(1)); got != nil {
+		t.Errorf("add_seconds(NULL) = %v, want NULL", got)
+	}
+
+	// Stored timestamps compare as text in time order.
+	if a, b := sqliteTime(at), sqliteTime(at.Add(time.Millisecond)); !(a < b) || !strings.HasPrefix(a, "2024-05-01") {
+		t.Errorf("sqliteTime order: %q, %q", a, b)
+	}
+}
@@ -0,0 +1,33 @@
+//go:build cgo
+
+package store
+
+import (
+	"errors"
+
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/mattn/go-sqlite3"
+)
+
+// sqliteError turns SQLite constraint violations into the PostgreSQL errors
+// the stores check for, such as a unique violation (23505).
+func sqliteError(err error) error {
+	var se sqlite3.Error
+	if !errors.As(err, &se) {
+		return err
+	}
+	pe := &pgconn.PgError{Severity: "ERROR", Message: se.Error()}
+	switch se.ExtendedCode {
+	case sqlite3.ErrConstraintUnique, sqlite3.ErrConstraintPrimaryKey:
+		pe.Code, pe.Message = "23505", "duplicate key value violates unique constraint: "+se.Error()
+	case sqlite3.ErrConstraintForeignKey:
+		pe.Code, pe.Message = "23503", "violates foreign key constraint: "+se.Error()
+	case sqlite3.ErrConstraintCheck:
+		pe.Code, pe.Message = "23514", "violates check constraint: "+se.Error()
+	case sqlite3.ErrConstraintNotNull:
+		pe.Code, pe.Message = "23502", "violates not-null constraint: "+se.Error()
+	default:
+		return err
+	}
+	return pe
+}
@@ -0,0 +1,7 @@
+//go:build !cgo
+
+package store
+
+// sqliteError returns err unchanged: without cgo, SQLite cannot be opened
+// and no query gets far enough to violate a constraint.
+func sqliteError(err error) error { return err }
@@ -0,0 +1,578 @@
+//go:build cgo
+
+package store
+
+import (
+	"context"
+	"encoding/json"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"go.uber.org/zap"
+
+	"github.com/aegisx/aegisx/internal/config"
+)
+
+// openSQLite returns a migrated SQLite database in a temporary directory,
+// with a tenant and a user to own what the tests create.
+func openSQLite(t *testing.T) (*DB, uuid.UUID, *UserRecord) {
+	t.Helper()
+	ctx := context.Background()
+	db, err := Connect(ctx, config.DatabaseConfig{
+		Driver:       config.DriverSQLite,
+		DSN:          filepath.Join(t.TempDir(), "data", "aegisx.db"),
+		MaxOpenConns: 2,
+	}, zap.NewNop())
+	if err != nil {
+		t.Fatalf("connect: %v", err)
+	}
+	t.Cleanup(db.Close)
+	if err := db.Migrate(ctx, ""); err != nil {
+		t.Fatalf("migrate: %v", err)
+	}
+
+	tenantID := uuid.New()
+	if err := NewTenantStore(db).Ensure(ctx, tenantID, "acme"); err != nil {
+		t.Fatalf("tenant: %v", err)
+	}
+	u := &UserRecord{TenantID: tenantID, Username: "alice", Email: "alice@example.com", Role: "admin", Active: true}
+	if err := NewUserStore(db).Create(ctx, u); err != nil {
+		t.Fatalf("user: %v", err)
+	}
+	return db, tenantID, u
+}
+
+func TestSQLiteMigrate(t *testing.T) {
+	ctx := context.Background()
+	db, _, _ := openSQLite(t)
+
+	if err := db.Migrate(ctx, ""); err != nil {
+		t.Fatalf("second migrate: %v", err)
+	}
+	applied, missing, err := db.CheckSchema(ctx, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if applied == 0 || len(missing) > 0 {
+		t.Errorf("CheckSchema = %d applied, missing %v", applied, missing)
+	}
+}
+
+func TestSQLiteUsers(t *testing.T) {
+	ctx := context.Background()
+	db, tenantID, u := openSQLite(t)
+	users := NewUserStore(db)
+
+	if u.ID == uuid.Nil || u.CreatedAt.IsZero() {
+		t.Fatalf("Create did not return the generated columns: %+v", u)
+	}
+	got, err := users.Get(ctx, u.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.Username != u.Username || got.TenantID != tenantID || !got.Active || !got.CreatedAt.Equal(u.CreatedAt) {
+		t.Errorf("Get = %+v, want %+v", got, u)
+	}
+
+	dup := &UserRecord{TenantID: tenantID, Username: "alice", Email: "other@example.com", Role: "viewer", Active: true}
+	if err := users.Create(ctx, dup); err == nil || !strings.Contains(err.Error(), "already exists") {
+		t.Errorf("duplicate user: got %v, want an already-exists error", err)
+	}
+
+	list, total, err := users.List(ctx, UserFilter{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if total != 1 || len(list) != 1 {
+		t.Errorf("List = %d of %d users, want 1 of 1", len(list), total)
+	}
+}
+
+func TestSQLitePolicies(t *testing.T) {
+	ctx := context.Background()
+	db, tenantID, u := openSQLite(t)
+	policies := NewPolicyStore(db)
+
+	p := &PolicyRecord{
+		TenantID: tenantID, Name: "web", Namespace: DefaultNamespace, Kind: "FirewallPolicy",
+		Spec: json.RawMessage(`{"rules":[]}`), RawYAML: "kind: FirewallPolicy", Enabled: true, CreatedBy: &u.ID,
+	}
+	if err := policies.Create(ctx, p); err != nil {
+		t.Fatal(err)
+	}
+
+	list, err := policies.List(ctx, tenantID, PolicyFilter{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(list) != 1 || list[0].ID != p.ID || string(list[0].Spec) != `{"rules":[]}` || !list[0].Enabled {
+		t.Fatalf("List = %+v", list)
+	}
+
+	p.RawYAML = "kind: FirewallPolicy\n# changed"
+	if err := policies.Update(ctx, p); err != nil {
+		t.Fatal(err)
+	}
+	if err := policies.MarkApplied(ctx, tenantID, p.ID); err != nil {
+		t.Fatal(err)
+	}
+	off, err := policies.SetEnabled(ctx, tenantID, p.ID, false, &u.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if off.Enabled {
+		t.Error("SetEnabled(false) left the policy enabled")
+	}
+	got, err := policies.GetByName(ctx, tenantID, DefaultNamespace, "web")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.RawYAML != p.RawYAML || got.Enabled {
+		t.Errorf("GetByName = %+v", got)
+	}
+	revs, err := policies.ListRevisions(ctx, tenantID, p.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(revs) < 2 {
+		t.Errorf("ListRevisions = %d revisions, want at least 2", len(revs))
+	}
+}
+
+func TestSQLiteAuditChain(t *testing.T) {
+	ctx := context.Background()
+	db, tenantID, u := openSQLite(t)
+	audit := NewAuditStore(db)
+
+	var prev string
+	for i := 0; i < 3; i++ {
+		e := &AuditEntry{
+			TenantID: &tenantID, UserID: &u.ID, Action: "update", Resource: "policy", ResourceID: "web",
+			Detail: json.RawMessage(`{"field":"enabled"}`), IPAddress: "192.0.2.1", Status: "success",
+		}
+		if err := audit.Record(ctx, e); err != nil {
+			t.Fatal(err)
+		}
+		if e.Seq != int64(i+1) || e.Hash == "" || e.PrevHash != prev {
+			t.Fatalf("entry %d: seq %d, prev %q, hash %q; want seq %d after %q", i, e.Seq, e.PrevHash, e.Hash, i+1, prev)
+		}
+		prev = e.Hash
+	}
+	if err := audit.Record(ctx, &AuditEntry{Action: "login", Resource: "system", Status: "failure"}); err != nil {
+		t.Fatalf("entry without tenant: %v", err)
+	}
+
+	rep, err := audit.VerifyChain(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !rep.Intact || rep.Entries != 4 || rep.HeadSeq != 4 {
+		t.Fatalf("VerifyChain = %+v, want 4 intact entries", rep)
+	}
+
+	if _, err := db.Pool.Exec(ctx, `UPDATE audit_log SET action = 'delete'`); err == nil {
+		t.Error("audit_log accepted an update")
+	}
+
+	entries, err := audit.List(ctx, AuditFilter{Limit: 10})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 4 {
+		t.Errorf("List = %d entries, want 4", len(entries))
+	}
+
+	// Retention removes the oldest entries without breaking the chain.
+	var archived int
+	n, err := NewRetentionStore(db).PruneBatch(ctx, TableAuditLog, time.Now().Add(time.Hour), 2, func(rows []json.RawMessage) error {
+		archived += len(rows)
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n != 2 || archived != 2 {
+		t.Errorf("PruneBatch deleted %d and archived %d rows, want 2", n, archived)
+	}
+	rep, err = audit.VerifyChain(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !rep.Intact || rep.Entries != 2 || rep.FirstSeq != 3 {
+		t.Errorf("VerifyChain after prune = %+v, want 2 intact entries from seq 3", rep)
+	}
+}
+
+func TestSQLiteBackupScratch(t *testing.T) {
+	ctx := context.Background()
+	db, tenantID, u := openSQLite(t)
+	if err := NewPolicyStore(db).Create(ctx, &PolicyRecord{
+		TenantID: tenantID, Name: "web", Namespace: DefaultNamespace, Kind: "FirewallPolicy",
+		Spec: json.RawMessage(`{}`), Enabled: true, CreatedBy: &u.ID,
+	}); err != nil {
+		t.Fatal(err)
+	}
+	backups := NewBackupStore(db)
+
+	tables, err := backups.Tables(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	dump := map[string][]json.RawMessage{}
+	if err := backups.Dump(ctx, tables, func(table string, row json.RawMessage) error {
+		dump[table] = append(dump[table], row)
+		return nil
+	}); err != nil {
+		t.Fatal(err)
+	}
+	if len(dump["users"]) != 1 || len(dump["policies"]) != 1 {
+		t.Fatalf("Dump = %d users, %d policies; want 1 each", len(dump["users"]), len(dump["policies"]))
+	}
+
+	const scratch = "restore_test"
+	if err := backups.CreateScratch(ctx, scratch, tables); err != nil {
+		t.Fatal(err)
+	}
+	loaded := map[string]bool{}
+	for _, table := range tables {
+		loaded[table] = true
+		cols, err := backups.Columns(ctx, "public", table)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := backups.LoadScratch(ctx, scratch, table, cols, dump[table]); err != nil {
+			t.Fatalf("load %s: %v", table, err)
+		}
+	}
+
+	fks, err := backups.ForeignKeys(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(fks) == 0 {
+		t.Fatal("ForeignKeys found none")
+	}
+	for _, fk := range fks {
+		if !loaded[fk.Table] || !loaded[fk.RefTable] {
+			continue
+		}
+		orphans, err := backups.Orphans(ctx, scratch, scratch, fk)
+		if err != nil {
+			t.Fatalf("orphans of %s: %v", fk.Name, err)
+		}
+		if orphans != 0 {
+			t.Errorf("%s: %d orphans in a consistent copy", fk.Name, orphans)
+		}
+	}
+	for _, table := range []string{"tenants", "users", "policies"} {
+		d, err := backups.Diff(ctx, scratch, table)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if d.Rows != d.Live || d.Added+d.Removed+d.Changed != 0 {
+			t.Errorf("Diff %s = %+v, want no changes", table, d)
+		}
+	}
+	sp, err := backups.ScratchPolicies(ctx, scratch)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(sp) != 1 {
+		t.Errorf("ScratchPolicies = %d, want 1", len(sp))
+	}
+
+	if err := backups.DropScratch(ctx, scratch); err != nil {
+		t.Fatal(err)
+	}
+	after, err := backups.Tables(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(after) != len(tables) {
+		t.Errorf("tables after DropScratch = %v, want %v", after, tables)
+	}
+}
+
+func TestSQLiteBlockList(t *testing.T) {
+	ctx := context.Background()
+	db, _, _ := openSQLite(t)
+	lists := NewBlockListStore(db)
+
+	l := &BlockList{Name: "spamhaus", Enabled: true}
+	if err := lists.Create(ctx, l); err != nil {
+		t.Fatal(err)
+	}
+	f := &BlockListFeed{BlockListID: l.ID, URL: "https://example.com/drop.txt", Format: "plain", RefreshSecs: 3600}
+	if err := lists.CreateFeed(ctx, f); err != nil {
+		t.Fatal(err)
+	}
+	if err := lists.ReplaceFeedEntries(ctx, f, []string{"10.0.0.0/8", "192.0.2.1/32", "2001:db8::/32"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := lists.ReplaceFeedEntries(ctx, f, []string{"10.0.0.1/8"}); err == nil {
+		t.Error("ReplaceFeedEntries accepted a CIDR with host bits set")
+	}
+
+	addrs, err := lists.ActiveAddresses(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(addrs) != 3 {
+		t.Errorf("ActiveAddresses = %v, want 3", addrs)
+	}
+	entries, total, err := lists.ListEntries(ctx, l.ID, 1, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 1 || total != 3 {
+		t.Errorf("ListEntries = %d of %d, want 1 of 3", len(entries), total)
+	}
+}
+
+func TestSQLiteBans(t *testing.T) {
+	ctx := context.Background()
+	db, _, u := openSQLite(t)
+	bans := NewBanStore(db)
+
+	if err := bans.Create(ctx, &Ban{Address: "192.0.2.1", Jail: "ssh", Reason: "auth", Failures: 5, ExpiresAt: time.Now().Add(time.Hour)}); err != nil {
+		t.Fatal(err)
+	}
+	active, err := bans.ListActive(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(active) != 1 || active[0].Address != "192.0.2.1" {
+		t.Errorf("ListActive = %+v", active)
+	}
+	n, err := bans.CountSince(ctx, "192.0.2.1", time.Now().Add(-time.Hour))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n != 1 {
+		t.Errorf("CountSince = %d, want 1", n)
+	}
+	lifted, err := bans.Lift(ctx, "192.0.2.1", &u.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(lifted) != 1 {
+		t.Errorf("Lift = %d bans, want 1", len(lifted))
+	}
+	if active, _ = bans.ListActive(ctx); len(active) != 0 {
+		t.Errorf("ListActive after Lift = %d bans", len(active))
+	}
+}
+
+func TestSQLiteVPNStats(t *testing.T) {
+	ctx := context.Background()
+	db, tenantID, _ := openSQLite(t)
+	vpn := NewVPNStore(db)
+
+	peerID := uuid.New()
+	if _, err := db.Pool.Exec(ctx, `
+		INSERT INTO vpn_peers (id, tenant_id, name, public_key) VALUES ($1, $2, 'laptop', 'pub')`,
+		peerID, tenantID); err != nil {
+		t.Fatal(err)
+	}
+	now := time.Now().UTC().Truncate(time.Hour)
+	if err := vpn.RecordPeerSamples(ctx, []PeerSample{
+		{PeerID: peerID, Bucket: now.Add(-2 * time.Minute), RxBytes: 10, TxBytes: 5},
+		{PeerID: peerID, Bucket: now.Add(-time.Minute), RxBytes: 30, TxBytes: 7, LastHandshake: &now},
+	}); err != nil {
+		t.Fatal(err)
+	}
+	stats, err := vpn.PeerStats(ctx, tenantID, peerID, now.Add(-time.Hour), time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(stats) != 1 || stats[0].RxBytes != 30 || stats[0].TxBytes != 7 || !stats[0].Bucket.Equal(now.Add(-time.Hour)) {
+		t.Errorf("PeerStats = %+v, want one hourly point of 30/7", stats)
+	}
+
+	peers, err := vpn.ListPeers(ctx, tenantID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(peers) != 1 || peers[0].PublicKey != "pub" {
+		t.Errorf("ListPeers = %+v", peers)
+	}
+	if err := vpn.UpdatePeerKey(ctx, tenantID, peerID, "rotated"); err != nil {
+		t.Fatal(err)
+	}
+	if peers, _ = vpn.ListPeers(ctx, tenantID); len(peers) != 1 || peers[0].PublicKey != "rotated" {
+		t.Errorf("ListPeers after UpdatePeerKey = %+v", peers)
+	}
+}
+
+func TestSQLiteEvents(t *testing.T) {
+	ctx := context.Background()
+	db, _, _ := openSQLite(t)
+	events := NewEventStore(db)
+
+	for i := 0; i < 3; i++ {
+		if err := events.InsertFirewallEvent(ctx, &FirewallEvent{
+			Timestamp: time.Now(), Rule: "default/web/deny", Action: "drop", SrcIP: "192.0.2.1", DstIP: "10.0.0.1",
+			Protocol: "tcp", DstPort: 22, Country: "DE", ASN: 3320,
+		}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	groups, err := events.BlockedTraffic(ctx, TrafficQuery{
+		Source: TrafficFirewall, By: GroupCountry, Since: time.Now().Add(-time.Hour), Bucket: time.Minute, Limit: 5,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(groups) != 1 || groups[0].Key != "DE" || groups[0].Count != 3 || groups[0].Sources != 1 {
+		t.Errorf("BlockedTraffic = %+v, want DE with 3 events from 1 source", groups)
+	}
+
+	var seen int
+	if err := events.EachFirewallEvent(ctx, EventQuery{Since: time.Now().Add(-time.Hour), Limit: 2}, func(e *FirewallEvent) error {
+		if e.SrcIP != "192.0.2.1" || e.DstPort != 22 {
+			t.Errorf("event = %+v", e)
+		}
+		seen++
+		return nil
+	}); err != nil {
+		t.Fatal(err)
+	}
+	if seen != 2 {
+		t.Errorf("EachFirewallEvent visited %d events, want 2", seen)
+	}
+
+	ids := NewIDSStore(db)
+	if err := ids.InsertAlert(ctx, &IDSAlert{
+		Timestamp: time.Now(), SignatureID: 2001, SignatureMsg: "scan", Severity: 2,
+		SrcIP: "192.0.2.1", DstIP: "10.0.0.1", DstPort: 80, Protocol: "TCP", Raw: json.RawMessage(`{}`),
+	}); err != nil {
+		t.Fatal(err)
+	}
+	aggs, err := ids.SourceAggregates(ctx, time.Now().Add(-time.Hour))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(aggs) != 1 {
+		t.Errorf("SourceAggregates = %d, want 1", len(aggs))
+	}
+	if aggs, err = ids.PortAggregates(ctx, time.Now().Add(-time.Hour)); err != nil || len(aggs) != 1 {
+		t.Errorf("PortAggregates = %d, %v; want 1", len(aggs), err)
+	}
+}
+
+func TestSQLiteSessions(t *testing.T) {
+	ctx := context.Background()
+	db, tenantID, u := openSQLite(t)
+
+	sessions := NewLoginSessionStore(db)
+	for i := 0; i < 3; i++ {
+		if err := sessions.Create(ctx, &LoginSession{TenantID: tenantID, UserID: u.ID, ExpiresAt: time.Now().Add(time.Hour)}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	evicted, err := sessions.EvictOldest(ctx, u.ID, 1, time.Now().Add(-time.Hour))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if evicted != 2 {
+		t.Errorf("EvictOldest = %d, want 2", evicted)
+	}
+
+	bg := NewBreakGlassStore(db)
+	granted := &BreakGlass{TenantID: tenantID, UserID: u.ID, Reason: "outage", TTLSeconds: 600, Method: "code"}
+	if err := bg.Create(ctx, granted, true); err != nil {
+		t.Fatal(err)
+	}
+	if granted.ExpiresAt == nil || time.Until(*granted.ExpiresAt) < 9*time.Minute {
+		t.Errorf("granted break-glass expires at %v, want in 10 minutes", granted.ExpiresAt)
+	}
+	pending := &BreakGlass{TenantID: tenantID, UserID: u.ID, Reason: "outage", TTLSeconds: 600, Method: "approval"}
+	if err := bg.Create(ctx, pending, false); err != nil {
+		t.Fatal(err)
+	}
+	approved, err := bg.Approve(ctx, pending.ID, u.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if approved.GrantedAt == nil || approved.ExpiresAt == nil {
+		t.Errorf("Approve = %+v, want it granted", approved)
+	}
+}
+
+func TestSQLiteReadOnly(t *testing.T) {
+	ctx := context.Background()
+	db, tenantID, _ := openSQLite(t)
+	ro := NewReadOnlyStore(db)
+
+	if err := ro.Set(ctx, &ReadOnlySwitch{TenantID: &tenantID, Reason: "audit"}); err != nil {
+		t.Fatal(err)
+	}
+	active, err := ro.Active(ctx, tenantID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if active == nil || active.Reason != "audit" {
+		t.Fatalf("Active = %+v, want the tenant switch", active)
+	}
+	for _, reason := range []string{"upgrade", "migration"} {
+		if err := ro.Set(ctx, &ReadOnlySwitch{Reason: reason}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if active, err = ro.Active(ctx, tenantID); err != nil || active == nil || active.TenantID != nil || active.Reason != "migration" {
+		t.Errorf("Active = %+v, %v; want the global switch", active, err)
+	}
+	if err := ro.Clear(ctx, nil); err != nil {
+		t.Fatal(err)
+	}
+	if active, _ = ro.Active(ctx, tenantID); active == nil || active.Reason != "audit" {
+		t.Errorf("Active after clearing the global switch = %+v", active)
+	}
+}
+
+func TestSQLiteNamespaces(t *testing.T) {
+	ctx := context.Background()
+	db, tenantID, u := openSQLite(t)
+	namespaces := NewNamespaceStore(db)
+
+	ns := &NamespaceRecord{TenantID: tenantID, Name: "payments", Labels: map[string]string{"team": "pay"}}
+	if err := namespaces.Create(ctx, ns); err != nil {
+		t.Fatal(err)
+	}
+	if err := namespaces.PutBinding(ctx, tenantID, &NamespaceBinding{NamespaceID: ns.ID, UserID: u.ID, Role: "viewer"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := namespaces.PutBinding(ctx, tenantID, &NamespaceBinding{NamespaceID: ns.ID, UserID: u.ID, Role: "operator"}); err != nil {
+		t.Fatalf("replace binding: %v", err)
+	}
+	owned, roles, err := namespaces.LoadAccess(ctx, tenantID, u.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !owned["payments"] || roles["payments"] != "operator" {
+		t.Errorf("LoadAccess = %v, %v; want payments as operator", owned, roles)
+	}
+	if err := namespaces.DeleteBinding(ctx, tenantID, ns.ID, u.ID); err != nil {
+		t.Fatal(err)
+	}
+	if err := namespaces.DeleteBinding(ctx, tenantID, ns.ID, u.ID); err == nil {
+		t.Error("deleting a missing binding succeeded")
+	}
+
+	list, err := namespaces.List(ctx, tenantID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var found *NamespaceRecord
+	for _, n := range list {
+		if n.Name == "payments" {
+			found = n
+		}
+	}
+	if found == nil || found.Labels["team"] != "pay" {
+		t.Errorf("List = %+v, want payments with its labels", list)
+	}
+}
@@ -0,0 +1,50 @@
+package store
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/google/uuid"
+)
+
+// TenantStore handles the tenants table.
+type TenantStore struct{ db *DB }
+
+func NewTenantStore(db *DB) *TenantStore { return &TenantStore{db: db} }
+
+// Exists reports whether the tenant exists and is not deleted.
+func (s *TenantStore) Exists(ctx context.Context, id uuid.UUID) (bool, error) {
+	var ok bool
+	err := s.db.Pool.QueryRow(ctx, `
+		SELECT EXISTS (SELECT 1 FROM tenants WHERE id = $1 AND deleted_at IS NULL)`, id).Scan(&ok)
+	if err != nil {
+		return false, fmt.Errorf("check tenant: %w", err)
+	}
+	return ok, nil
+}
+
+// Ensure creates the tenant with the given ID and name unless it exists.
+func (s *TenantStore) Ensure(ctx context.Context, id uuid.UUID, name string) error {
+	_, err := s.db.Pool.Exec(ctx, `
+		INSERT INTO tenants (id, name, slug)
+		VALUES ($1, $2, $3)
+		ON CONFLICT (id) DO NOTHING`,
+		id, name, slugify(name))
+	if err != nil {
+		if isUniqueViolation(err) {
+			return fmt.Errorf("tenant %q already exists", name)
+		}
+		return fmt.Errorf("insert tenant: %w", err)
+	}
+	return nil
+}
+
+// ─── Private helpers ──────────────────────────────────────────────────────
+
+var nonSlug = regexp.MustCompile(`[^a-z0-9]+`)
+
+func slugify(name string) string {
+	return strings.Trim(nonSlug.ReplaceAllString(strings.ToLower(name), "-"), "-")
+}
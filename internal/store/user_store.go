@@ -0,0 +1,439 @@
+package store
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+)
+
+// Sources of provisioned users and groups.
+const (
+	SourceSCIM = "scim"
+	SourceLDAP = "ldap"
+)
+
+// UserRecord is a user provisioned through SCIM or synced from LDAP.
+type UserRecord struct {
+	ID          uuid.UUID `json:"id"`
+	TenantID    uuid.UUID `json:"tenantId"`
+	Username    string    `json:"username"`
+	Email       string    `json:"email"`
+	DisplayName string    `json:"displayName"`
+	ExternalID  string    `json:"externalId,omitempty"`
+	Role        string    `json:"role"`
+	Active      bool      `json:"active"`
+	Source      string    `json:"source"` // scim | ldap; "" creates a SCIM user
+	CreatedAt   time.Time `json:"createdAt"`
+	UpdatedAt   time.Time `json:"updatedAt"`
+}
+
+// GroupRecord is an IdP group; Members are user IDs.
+type GroupRecord struct {
+	ID          uuid.UUID   `json:"id"`
+	ExternalID  string      `json:"externalId,omitempty"`
+	DisplayName string      `json:"displayName"`
+	Members     []uuid.UUID `json:"members"`
+	Source      string      `json:"source"` // scim | ldap; "" creates a SCIM group
+	CreatedAt   time.Time   `json:"createdAt"`
+	UpdatedAt   time.Time   `json:"updatedAt"`
+}
+
+// UserFilter narrows a user or group listing. Zero-valued fields are ignored.
+type UserFilter struct {
+	Username    string // users: case-insensitive exact match
+	ExternalID  string
+	DisplayName string // groups: exact match
+	Source      string
+	Offset      int
+	Limit       int // default 100
+}
+
+// UserStore handles provisioned users and their groups.
+type UserStore struct{ db *DB }
+
+func NewUserStore(db *DB) *UserStore { return &UserStore{db: db} }
+
+// ─── Users ────────────────────────────────────────────────────────────────
+
+// Create inserts a provisioned user.
+func (s *UserStore) Create(ctx context.Context, u *UserRecord) error {
+	if u.Email == "" {
+		u.Email = u.Username
+	}
+	if u.Source == "" {
+		u.Source = SourceSCIM
+	}
+	err := s.db.Pool.QueryRow(ctx, `
+		INSERT INTO users
+			(tenant_id, username, email, display_name, external_id, role, active, provisioned, source)
+		VALUES ($1, $2, $3, $4, NULLIF($5, ''), $6, $7, TRUE, $8)
+		RETURNING id, created_at, updated_at`,
+		u.TenantID, u.Username, u.Email, u.DisplayName, u.ExternalID, u.Role, u.Active, u.Source,
+	).Scan(&u.ID, &u.CreatedAt, &u.UpdatedAt)
+	if err != nil {
+		if isUniqueViolation(err) {
+			return fmt.Errorf("user %q already exists", u.Username)
+		}
+		return fmt.Errorf("insert user: %w", err)
+	}
+	return nil
+}
+
+// Get returns a provisioned user by ID.
+func (s *UserStore) Get(ctx context.Context, id uuid.UUID) (*UserRecord, error) {
+	row := s.db.Pool.QueryRow(ctx, `
+		SELECT `+userColumns+` FROM users WHERE id = $1 AND `+provisionedUser, id)
+	return scanUser(row)
+}
+
+// GetByUsername returns a provisioned user by name, case-insensitively.
+func (s *UserStore) GetByUsername(ctx context.Context, username string) (*UserRecord, error) {
+	row := s.db.Pool.QueryRow(ctx, `
+		SELECT `+userColumns+` FROM users WHERE lower(username) = lower($1) AND `+provisionedUser, username)
+	return scanUser(row)
+}
+
+// List returns provisioned users matching filter and the total match count.
+func (s *UserStore) List(ctx context.Context, filter UserFilter) ([]*UserRecord, int, error) {
+	where := provisionedUser
+	var args []any
+	if filter.Username != "" {
+		args = append(args, filter.Username)
+		where += fmt.Sprintf(" AND lower(username) = lower($%d)", len(args))
+	}
+	if filter.ExternalID != "" {
+		args = append(args, filter.ExternalID)
+		where += fmt.Sprintf(" AND external_id = $%d", len(args))
+	}
+	if filter.Source != "" {
+		args = append(args, filter.Source)
+		where += fmt.Sprintf(" AND source = $%d", len(args))
+	}
+
+	var total int
+	if err := s.db.Pool.QueryRow(ctx, `SELECT COUNT(*) FROM users WHERE `+where, args...).Scan(&total); err != nil {
+		return nil, 0, err
+	}
+
+	limit, offset := page(filter)
+	args = append(args, limit, offset)
+	rows, err := s.db.Pool.Query(ctx, fmt.Sprintf(`
+		SELECT `+userColumns+` FROM users WHERE %s
+		ORDER BY created_at LIMIT $%d OFFSET $%d`, where, len(args)-1, len(args)), args...)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer rows.Close()
+
+	var items []*UserRecord
+	for rows.Next() {
+		u, err := scanUser(rows)
+		if err != nil {
+			return nil, 0, err
+		}
+		items = append(items, u)
+	}
+	return items, total, rows.Err()
+}
+
+// Update persists the mutable fields of a provisioned user.
+func (s *UserStore) Update(ctx context.Context, u *UserRecord) error {
+	if u.Email == "" {
+		u.Email = u.Username
+	}
+	err := s.db.Pool.QueryRow(ctx, `
+		UPDATE users
+		SET tenant_id = $2, username = $3, email = $4, display_name = $5,
+		    external_id = NULLIF($6, ''), role = $7, active = $8, updated_at = NOW()
+		WHERE id = $1 AND `+provisionedUser+`
+		RETURNING updated_at`,
+		u.ID, u.TenantID, u.Username, u.Email, u.DisplayName, u.ExternalID, u.Role, u.Active,
+	).Scan(&u.UpdatedAt)
+	if err != nil {
+		if err == pgx.ErrNoRows {
+			return fmt.Errorf("user not found")
+		}
+		if isUniqueViolation(err) {
+			return fmt.Errorf("user %q already exists", u.Username)
+		}
+		return fmt.Errorf("update user: %w", err)
+	}
+	return nil
+}
+
+// Delete deprovisions a user: it is deactivated, leaves its groups and is
+// no longer visible, but stays referenced by what it created.
+func (s *UserStore) Delete(ctx context.Context, id uuid.UUID) error {
+	tx, err := s.db.Pool.Begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback(ctx)
+
+	tag, err := tx.Exec(ctx, `
+		UPDATE users SET active = FALSE, deprovisioned_at = NOW(), updated_at = NOW()
+		WHERE id = $1 AND `+provisionedUser, id)
+	if err != nil {
+		return err
+	}
+	if tag.RowsAffected() == 0 {
+		return fmt.Errorf("user not found")
+	}
+	if _, err := tx.Exec(ctx, `DELETE FROM scim_group_members WHERE user_id = $1`, id); err != nil {
+		return err
+	}
+	return tx.Commit(ctx)
+}
+
+// GroupNames returns the names of the groups the user belongs to.
+func (s *UserStore) GroupNames(ctx context.Context, userID uuid.UUID) ([]string, error) {
+	rows, err := s.db.Pool.Query(ctx, `
+		SELECT g.display_name
+		FROM scim_groups g
+		JOIN scim_group_members m ON m.group_id = g.id
+		WHERE m.user_id = $1
+		ORDER BY g.display_name`, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var names []string
+	for rows.Next() {
+		var n string
+		if err := rows.Scan(&n); err != nil {
+			return nil, err
+		}
+		names = append(names, n)
+	}
+	return names, rows.Err()
+}
+
+// ─── Groups ───────────────────────────────────────────────────────────────
+
+// CreateGroup inserts a group with its members.
+func (s *UserStore) CreateGroup(ctx context.Context, g *GroupRecord) error {
+	tx, err := s.db.Pool.Begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback(ctx)
+
+	if g.Source == "" {
+		g.Source = SourceSCIM
+	}
+	err = tx.QueryRow(ctx, `
+		INSERT INTO scim_groups (external_id, display_name, source)
+		VALUES (NULLIF($1, ''), $2, $3)
+		RETURNING id, created_at, updated_at`,
+		g.ExternalID, g.DisplayName, g.Source,
+	).Scan(&g.ID, &g.CreatedAt, &g.UpdatedAt)
+	if err != nil {
+		if isUniqueViolation(err) {
+			return fmt.Errorf("group %q already exists", g.DisplayName)
+		}
+		return fmt.Errorf("insert group: %w", err)
+	}
+	if err := addMembers(ctx, tx, g.ID, g.Members); err != nil {
+		return err
+	}
+	return tx.Commit(ctx)
+}
+
+// GetGroup returns a group with its members.
+func (s *UserStore) GetGroup(ctx context.Context, id uuid.UUID) (*GroupRecord, error) {
+	var g GroupRecord
+	var ext *string
+	err := s.db.Pool.QueryRow(ctx, `
+		SELECT id, external_id, display_name, source, created_at, updated_at
+		FROM scim_groups WHERE id = $1`, id,
+	).Scan(&g.ID, &ext, &g.DisplayName, &g.Source, &g.CreatedAt, &g.UpdatedAt)
+	if err != nil {
+		if err == pgx.ErrNoRows {
+			return nil, fmt.Errorf("group not found")
+		}
+		return nil, err
+	}
+	if ext != nil {
+		g.ExternalID = *ext
+	}
+	if g.Members, err = s.members(ctx, g.ID); err != nil {
+		return nil, err
+	}
+	return &g, nil
+}
+
+// ListGroups returns groups matching filter and the total match count.
+func (s *UserStore) ListGroups(ctx context.Context, filter UserFilter) ([]*GroupRecord, int, error) {
+	where := "TRUE"
+	var args []any
+	if filter.DisplayName != "" {
+		args = append(args, filter.DisplayName)
+		where += fmt.Sprintf(" AND display_name = $%d", len(args))
+	}
+	if filter.ExternalID != "" {
+		args = append(args, filter.ExternalID)
+		where += fmt.Sprintf(" AND external_id = $%d", len(args))
+	}
+	if filter.Source != "" {
+		args = append(args, filter.Source)
+		where += fmt.Sprintf(" AND source = $%d", len(args))
+	}
+
+	var total int
+	if err := s.db.Pool.QueryRow(ctx, `SELECT COUNT(*) FROM scim_groups WHERE `+where, args...).Scan(&total); err != nil {
+		return nil, 0, err
+	}
+
+	limit, offset := page(filter)
+	args = append(args, limit, offset)
+	rows, err := s.db.Pool.Query(ctx, fmt.Sprintf(`
+		SELECT id FROM scim_groups WHERE %s
+		ORDER BY created_at LIMIT $%d OFFSET $%d`, where, len(args)-1, len(args)), args...)
+	if err != nil {
+		return nil, 0, err
+	}
+	var ids []uuid.UUID
+	for rows.Next() {
+		var id uuid.UUID
+		if err := rows.Scan(&id); err != nil {
+			rows.Close()
+			return nil, 0, err
+		}
+		ids = append(ids, id)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, 0, err
+	}
+
+	items := make([]*GroupRecord, 0, len(ids))
+	for _, id := range ids {
+		g, err := s.GetGroup(ctx, id)
+		if err != nil {
+			return nil, 0, err
+		}
+		items = append(items, g)
+	}
+	return items, total, nil
+}
+
+// UpdateGroup renames a group and replaces its members.
+func (s *UserStore) UpdateGroup(ctx context.Context, g *GroupRecord) error {
+	tx, err := s.db.Pool.Begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback(ctx)
+
+	err = tx.QueryRow(ctx, `
+		UPDATE scim_groups
+		SET external_id = NULLIF($2, ''), display_name = $3, updated_at = NOW()
+		WHERE id = $1
+		RETURNING updated_at`,
+		g.ID, g.ExternalID, g.DisplayName,
+	).Scan(&g.UpdatedAt)
+	if err != nil {
+		if err == pgx.ErrNoRows {
+			return fmt.Errorf("group not found")
+		}
+		return fmt.Errorf("update group: %w", err)
+	}
+	if _, err := tx.Exec(ctx, `DELETE FROM scim_group_members WHERE group_id = $1`, g.ID); err != nil {
+		return err
+	}
+	if err := addMembers(ctx, tx, g.ID, g.Members); err != nil {
+		return err
+	}
+	return tx.Commit(ctx)
+}
+
+// DeleteGroup removes a group and returns its former members.
+func (s *UserStore) DeleteGroup(ctx context.Context, id uuid.UUID) ([]uuid.UUID, error) {
+	members, err := s.members(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	tag, err := s.db.Pool.Exec(ctx, `DELETE FROM scim_groups WHERE id = $1`, id)
+	if err != nil {
+		return nil, err
+	}
+	if tag.RowsAffected() == 0 {
+		return nil, fmt.Errorf("group not found")
+	}
+	return members, nil
+}
+
+// ─── Private helpers ──────────────────────────────────────────────────────
+
+const provisionedUser = `provisioned AND deprovisioned_at IS NULL`
+
+const userColumns = `id, tenant_id, username, email, display_name, COALESCE(external_id, ''),
+	role, active, source, created_at, updated_at`
+
+func scanUser(row scanner) (*UserRecord, error) {
+	var u UserRecord
+	err := row.Scan(&u.ID, &u.TenantID, &u.Username, &u.Email, &u.DisplayName, &u.ExternalID,
+		&u.Role, &u.Active, &u.Source, &u.CreatedAt, &u.UpdatedAt)
+	if err != nil {
+		if err == pgx.ErrNoRows {
+			return nil, fmt.Errorf("user not found")
+		}
+		return nil, err
+	}
+	return &u, nil
+}
+
+func (s *UserStore) members(ctx context.Context, groupID uuid.UUID) ([]uuid.UUID, error) {
+	rows, err := s.db.Pool.Query(ctx, `
+		SELECT user_id FROM scim_group_members WHERE group_id = $1`, groupID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	members := []uuid.UUID{}
+	for rows.Next() {
+		var id uuid.UUID
+		if err := rows.Scan(&id); err != nil {
+			return nil, err
+		}
+		members = append(members, id)
+	}
+	return members, rows.Err()
+}
+
+func addMembers(ctx context.Context, tx pgx.Tx, groupID uuid.UUID, members []uuid.UUID) error {
+	for _, m := range members {
+		_, err := tx.Exec(ctx, `
+			INSERT INTO scim_group_members (group_id, user_id)
+			SELECT $1, id FROM users WHERE id = $2 AND `+provisionedUser+`
+			ON CONFLICT DO NOTHING`, groupID, m)
+		if err != nil {
+			return fmt.Errorf("add member %s: %w", m, err)
+		}
+	}
+	return nil
+}
+
+func page(f UserFilter) (limit, offset int) {
+	limit, offset = f.Limit, f.Offset
+	if limit <= 0 || limit > 1000 {
+		limit = 100
+	}
+	if offset < 0 {
+		offset = 0
+	}
+	return limit, offset
+}
+
+// isUniqueViolation reports a Postgres unique_violation.
+func isUniqueViolation(err error) bool {
+	var pgErr *pgconn.PgError
+	return errors.As(err, &pgErr) && pgErr.Code == "23505"
+}
@@ -0,0 +1,201 @@
+package store
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+)
+
+// VPNPeerRecord is the DB representation of a WireGuard peer.
+type VPNPeerRecord struct {
+	ID            uuid.UUID  `json:"id"`
+	TenantID      uuid.UUID  `json:"tenantId"`
+	Name          string     `json:"name"`
+	PublicKey     string     `json:"publicKey"`
+	PresharedKey  string     `json:"-"`
+	AllowedIPs    []string   `json:"allowedIPs"`
+	Endpoint      string     `json:"endpoint"`
+	KeepAlive     int        `json:"keepAlive"`
+	TunnelProfile string     `json:"tunnelProfile"` // split | full
+	Active        bool       `json:"active"`
+	LastHandshake *time.Time `json:"lastHandshake"`
+	CreatedAt     time.Time  `json:"createdAt"`
+}
+
+// PeerSample is one point-in-time reading of a peer's WireGuard counters.
+type PeerSample struct {
+	PeerID        uuid.UUID  `json:"-"`
+	Bucket        time.Time  `json:"t"`
+	RxBytes       int64      `json:"rxBytes"`
+	TxBytes       int64      `json:"txBytes"`
+	LastHandshake *time.Time `json:"lastHandshake,omitempty"`
+}
+
+// VPNStore handles VPN peer records and their statistics history.
+type VPNStore struct{ db *DB }
+
+func NewVPNStore(db *DB) *VPNStore { return &VPNStore{db: db} }
+
+// ListPeers returns all peers of a tenant.
+func (s *VPNStore) ListPeers(ctx context.Context, tenantID uuid.UUID) ([]*VPNPeerRecord, error) {
+	rows, err := s.db.Pool.Query(ctx, `
+		SELECT id, tenant_id, name, public_key, COALESCE(preshared_key, ''), allowed_ips,
+		       COALESCE(endpoint, ''), COALESCE(keepalive, 0), tunnel_profile, active,
+		       last_handshake, created_at
+		FROM vpn_peers
+		WHERE tenant_id = $1
+		ORDER BY name`, tenantID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var peers []*VPNPeerRecord
+	for rows.Next() {
+		p, err := scanPeer(rows)
+		if err != nil {
+			return nil, err
+		}
+		peers = append(peers, p)
+	}
+	return peers, rows.Err()
+}
+
+// GetPeer returns a single peer by ID.
+func (s *VPNStore) GetPeer(ctx context.Context, tenantID, id uuid.UUID) (*VPNPeerRecord, error) {
+	row := s.db.Pool.QueryRow(ctx, `
+		SELECT id, tenant_id, name, public_key, COALESCE(preshared_key, ''), allowed_ips,
+		       COALESCE(endpoint, ''), COALESCE(keepalive, 0), tunnel_profile, active,
+		       last_handshake, created_at
+		FROM vpn_peers
+		WHERE id = $1 AND tenant_id = $2`,
+		id, tenantID)
+	return scanPeer(row)
+}
+
+// UpdatePeerKey replaces a peer's public key after a key rotation.
+func (s *VPNStore) UpdatePeerKey(ctx context.Context, tenantID, id uuid.UUID, publicKey string) error {
+	tag, err := s.db.Pool.Exec(ctx, `
+		UPDATE vpn_peers SET public_key = $1 WHERE id = $2 AND tenant_id = $3`,
+		publicKey, id, tenantID)
+	if err != nil {
+		return fmt.Errorf("update peer key: %w", err)
+	}
+	if tag.RowsAffected() == 0 {
+		return fmt.Errorf("peer not found")
+	}
+	return nil
+}
+
+// SetTunnelProfile selects the client tunnel profile of a peer.
+func (s *VPNStore) SetTunnelProfile(ctx context.Context, tenantID, id uuid.UUID, profile string) error {
+	tag, err := s.db.Pool.Exec(ctx, `
+		UPDATE vpn_peers SET tunnel_profile = $1 WHERE id = $2 AND tenant_id = $3`,
+		profile, id, tenantID)
+	if err != nil {
+		return fmt.Errorf("set tunnel profile: %w", err)
+	}
+	if tag.RowsAffected() == 0 {
+		return fmt.Errorf("peer not found")
+	}
+	return nil
+}
+
+// PeerIDsByPublicKey maps every known public key to its peer ID. The stats
+// collector uses it to attribute kernel counters to DB peers.
+func (s *VPNStore) PeerIDsByPublicKey(ctx context.Context) (map[string]uuid.UUID, error) {
+	rows, err := s.db.Pool.Query(ctx, `SELECT public_key, id FROM vpn_peers`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	ids := make(map[string]uuid.UUID)
+	for rows.Next() {
+		var (
+			key string
+			id  uuid.UUID
+		)
+		if err := rows.Scan(&key, &id); err != nil {
+			return nil, err
+		}
+		ids[key] = id
+	}
+	return ids, rows.Err()
+}
+
+// RecordPeerSamples upserts one sample per peer and refreshes last_handshake
+// on the peer row. Re-sampling inside the same bucket overwrites the point.
+func (s *VPNStore) RecordPeerSamples(ctx context.Context, samples []PeerSample) error {
+	batch := &pgx.Batch{}
+	for _, smp := range samples {
+		batch.Queue(`
+			INSERT INTO vpn_peer_stats (peer_id, bucket, rx_bytes, tx_bytes, last_handshake)
+			VALUES ($1, $2, $3, $4, $5)
+			ON CONFLICT (peer_id, bucket) DO UPDATE
+			SET rx_bytes = EXCLUDED.rx_bytes, tx_bytes = EXCLUDED.tx_bytes,
+			    last_handshake = EXCLUDED.last_handshake`,
+			smp.PeerID, smp.Bucket, smp.RxBytes, smp.TxBytes, smp.LastHandshake)
+		if smp.LastHandshake != nil {
+			batch.Queue(`UPDATE vpn_peers SET last_handshake = $1 WHERE id = $2`,
+				smp.LastHandshake, smp.PeerID)
+		}
+	}
+
+	br := s.db.Pool.SendBatch(ctx, batch)
+	defer br.Close()
+	for i := 0; i < batch.Len(); i++ {
+		if _, err := br.Exec(); err != nil {
+			return fmt.Errorf("record peer sample: %w", err)
+		}
+	}
+	return nil
+}
+
+// PeerStats returns samples for a peer since the given time, downsampled to
+// one point per step (the last counter reading in each step window).
+func (s *VPNStore) PeerStats(ctx context.Context, tenantID, peerID uuid.UUID, since time.Time, step time.Duration) ([]PeerSample, error) {
+	rows, err := s.db.Pool.Query(ctx, `
+		SELECT to_timestamp(floor(extract(epoch FROM s.bucket) / $3) * $3) AS t,
+		       MAX(s.rx_bytes), MAX(s.tx_bytes), MAX(s.last_handshake)
+		FROM vpn_peer_stats s
+		JOIN vpn_peers p ON p.id = s.peer_id
+		WHERE s.peer_id = $1 AND s.bucket >= $2 AND p.tenant_id = $4
+		GROUP BY t
+		ORDER BY t`,
+		peerID, since, step.Seconds(), tenantID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var samples []PeerSample
+	for rows.Next() {
+		smp := PeerSample{PeerID: peerID}
+		if err := rows.Scan(&smp.Bucket, &smp.RxBytes, &smp.TxBytes, &smp.LastHandshake); err != nil {
+			return nil, err
+		}
+		samples = append(samples, smp)
+	}
+	return samples, rows.Err()
+}
+
+// ─── Private helpers ──────────────────────────────────────────────────────
+
+func scanPeer(row scanner) (*VPNPeerRecord, error) {
+	var p VPNPeerRecord
+	err := row.Scan(
+		&p.ID, &p.TenantID, &p.Name, &p.PublicKey, &p.PresharedKey, &p.AllowedIPs,
+		&p.Endpoint, &p.KeepAlive, &p.TunnelProfile, &p.Active, &p.LastHandshake, &p.CreatedAt,
+	)
+	if err != nil {
+		if err == pgx.ErrNoRows {
+			return nil, fmt.Errorf("peer not found")
+		}
+		return nil, err
+	}
+	return &p, nil
+}
@@ -0,0 +1,86 @@
+package store
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// WANTest is one quality measurement of a WAN uplink. Metrics that could
+// not be measured are nil.
+type WANTest struct {
+	ID           int64      `json:"id"`
+	Uplink       string     `json:"uplink"`
+	Interface    string     `json:"interface,omitempty"`
+	Method       string     `json:"method"`  // http|iperf3
+	Trigger      string     `json:"trigger"` // manual|scheduled
+	LatencyMs    *float64   `json:"latencyMs,omitempty"`
+	JitterMs     *float64   `json:"jitterMs,omitempty"`
+	LossPct      *float64   `json:"lossPct,omitempty"`
+	DownloadMbps *float64   `json:"downloadMbps,omitempty"`
+	UploadMbps   *float64   `json:"uploadMbps,omitempty"`
+	Error        string     `json:"error,omitempty"`
+	DurationMs   int        `json:"durationMs"`
+	CreatedBy    *uuid.UUID `json:"createdBy,omitempty"`
+	StartedAt    time.Time  `json:"startedAt"`
+}
+
+// WANTestFilter narrows ListWANTests.
+type WANTestFilter struct {
+	Uplink string // empty for all
+	Since  time.Time
+	Limit  int
+}
+
+// WANTestStore handles WAN test history.
+type WANTestStore struct{ db *DB }
+
+func NewWANTestStore(db *DB) *WANTestStore { return &WANTestStore{db: db} }
+
+// Record stores a finished test.
+func (s *WANTestStore) Record(ctx context.Context, t *WANTest) error {
+	err := s.db.Pool.QueryRow(ctx, `
+		INSERT INTO wan_tests (uplink, interface, method, trigger, latency_ms, jitter_ms, loss_pct,
+			download_mbps, upload_mbps, error, duration_ms, created_by, started_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13)
+		RETURNING id`,
+		t.Uplink, t.Interface, t.Method, t.Trigger, t.LatencyMs, t.JitterMs, t.LossPct,
+		t.DownloadMbps, t.UploadMbps, t.Error, t.DurationMs, t.CreatedBy, t.StartedAt,
+	).Scan(&t.ID)
+	if err != nil {
+		return fmt.Errorf("insert wan test: %w", err)
+	}
+	return nil
+}
+
+// List returns tests matching f, newest first.
+func (s *WANTestStore) List(ctx context.Context, f WANTestFilter) ([]*WANTest, error) {
+	if f.Limit <= 0 {
+		f.Limit = 100
+	}
+	rows, err := s.db.Pool.Query(ctx, `
+		SELECT id, uplink, interface, method, trigger, latency_ms, jitter_ms, loss_pct,
+		       download_mbps, upload_mbps, error, duration_ms, created_by, started_at
+		FROM wan_tests
+		WHERE ($1 = '' OR uplink = $1) AND started_at >= $2
+		ORDER BY started_at DESC, id DESC
+		LIMIT $3`, f.Uplink, f.Since, f.Limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var items []*WANTest
+	for rows.Next() {
+		var t WANTest
+		if err := rows.Scan(&t.ID, &t.Uplink, &t.Interface, &t.Method, &t.Trigger, &t.LatencyMs,
+			&t.JitterMs, &t.LossPct, &t.DownloadMbps, &t.UploadMbps, &t.Error, &t.DurationMs,
+			&t.CreatedBy, &t.StartedAt); err != nil {
+			return nil, err
+		}
+		items = append(items, &t)
+	}
+	return items, rows.Err()
+}
@@ -0,0 +1,94 @@
+package store
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// WebAuthnCredential is a registered passkey. Credential is the library's
+// credential record, stored opaquely.
+type WebAuthnCredential struct {
+	ID           uuid.UUID       `json:"id"`
+	UserID       uuid.UUID       `json:"userId"`
+	Name         string          `json:"name"`
+	CredentialID []byte          `json:"-"`
+	Credential   json.RawMessage `json:"-"`
+	SignCount    uint32          `json:"-"`
+	CreatedAt    time.Time       `json:"createdAt"`
+	LastUsedAt   *time.Time      `json:"lastUsedAt,omitempty"`
+}
+
+// WebAuthnStore handles passkey credentials.
+type WebAuthnStore struct{ db *DB }
+
+func NewWebAuthnStore(db *DB) *WebAuthnStore { return &WebAuthnStore{db: db} }
+
+// Create stores a newly registered credential.
+func (s *WebAuthnStore) Create(ctx context.Context, c *WebAuthnCredential) error {
+	err := s.db.Pool.QueryRow(ctx, `
+		INSERT INTO webauthn_credentials (user_id, name, credential_id, credential, sign_count)
+		VALUES ($1, $2, $3, $4, $5)
+		RETURNING id, created_at`,
+		c.UserID, c.Name, c.CredentialID, c.Credential, int64(c.SignCount),
+	).Scan(&c.ID, &c.CreatedAt)
+	if err != nil {
+		return fmt.Errorf("insert webauthn credential: %w", err)
+	}
+	return nil
+}
+
+// List returns the credentials of a user, oldest first.
+func (s *WebAuthnStore) List(ctx context.Context, userID uuid.UUID) ([]*WebAuthnCredential, error) {
+	rows, err := s.db.Pool.Query(ctx, `
+		SELECT id, user_id, name, credential_id, credential, sign_count, created_at, last_used_at
+		FROM webauthn_credentials
+		WHERE user_id = $1
+		ORDER BY created_at`, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var items []*WebAuthnCredential
+	for rows.Next() {
+		var (
+			c     WebAuthnCredential
+			count int64
+		)
+		if err := rows.Scan(&c.ID, &c.UserID, &c.Name, &c.CredentialID, &c.Credential,
+			&count, &c.CreatedAt, &c.LastUsedAt); err != nil {
+			return nil, err
+		}
+		c.SignCount = uint32(count)
+		items = append(items, &c)
+	}
+	return items, rows.Err()
+}
+
+// Touch records a successful login with the credential.
+func (s *WebAuthnStore) Touch(ctx context.Context, credentialID []byte, signCount uint32, credential json.RawMessage) error {
+	_, err := s.db.Pool.Exec(ctx, `
+		UPDATE webauthn_credentials
+		SET sign_count = $2, credential = $3, last_used_at = NOW()
+		WHERE credential_id = $1`,
+		credentialID, int64(signCount), credential)
+	return err
+}
+
+// Delete removes one of a user's credentials.
+func (s *WebAuthnStore) Delete(ctx context.Context, userID, id uuid.UUID) error {
+	tag, err := s.db.Pool.Exec(ctx, `
+		DELETE FROM webauthn_credentials WHERE id = $1 AND user_id = $2`,
+		id, userID)
+	if err != nil {
+		return err
+	}
+	if tag.RowsAffected() == 0 {
+		return fmt.Errorf("credential not found")
+	}
+	return nil
+}
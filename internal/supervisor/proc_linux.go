@@ -0,0 +1,10 @@
+package supervisor
+
+import "syscall"
+
+// sysProcAttr has the kernel send a daemon SIGTERM when AegisX dies
+// without stopping it, so a restarted AegisX does not find the old copy
+// holding its sockets.
+func sysProcAttr() *syscall.SysProcAttr {
+	return &syscall.SysProcAttr{Pdeathsig: syscall.SIGTERM}
+}
@@ -0,0 +1,10 @@
+//go:build !linux
+
+package supervisor
+
+import "syscall"
+
+// sysProcAttr is empty where the parent-death signal is unavailable.
+func sysProcAttr() *syscall.SysProcAttr {
+	return nil
+}
@@ -0,0 +1,298 @@
+// Package supervisor launches the daemons AegisX owns, such as Suricata,
+// HAProxy and the DHCP and DNS servers, and keeps them running. A daemon
+// that exits is restarted after a backoff that doubles with every crash in
+// a row; each crash is logged, counted and reported to the daemon-crash
+// hooks. Daemons that are not configured are left to whatever manages them
+// outside AegisX.
+package supervisor
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os/exec"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+
+	"go.uber.org/zap"
+
+	"github.com/aegisx/aegisx/internal/hooks"
+	"github.com/aegisx/aegisx/internal/metrics"
+)
+
+// Daemon states.
+const (
+	StateStarting = "starting"
+	StateRunning  = "running"
+	StateBackoff  = "backoff" // exited; waiting to be restarted
+	StateStopped  = "stopped"
+)
+
+// signals are the reload signals a daemon may be configured with.
+var signals = map[string]syscall.Signal{
+	"SIGHUP":  syscall.SIGHUP,
+	"SIGUSR1": syscall.SIGUSR1,
+	"SIGUSR2": syscall.SIGUSR2,
+}
+
+// Daemon is a program to keep running. It must stay in the foreground.
+type Daemon struct {
+	Name         string
+	Command      []string // argv
+	ReloadSignal string   // SIGHUP | SIGUSR1 | SIGUSR2; empty if it cannot be reloaded by signal
+}
+
+// Config is the restart and shutdown timing shared by all daemons.
+type Config struct {
+	MinBackoff  time.Duration // delay before the first restart, doubled after each crash in a row
+	MaxBackoff  time.Duration
+	StableAfter time.Duration // uptime after which a crash starts the backoff over
+	StopTimeout time.Duration // between SIGTERM and SIGKILL on shutdown
+}
+
+// Status is the state of one daemon, for the health API.
+type Status struct {
+	Name       string     `json:"name"`
+	State      string     `json:"state"`
+	PID        int        `json:"pid,omitempty"`
+	StartedAt  *time.Time `json:"startedAt,omitempty"`
+	Restarts   int        `json:"restarts"`
+	LastExit   string     `json:"lastExit,omitempty"` // e.g. "exit status 1" or "signal: killed"
+	LastExitAt *time.Time `json:"lastExitAt,omitempty"`
+	NextStart  *time.Time `json:"nextStart,omitempty"` // while in backoff
+}
+
+// Supervisor runs the configured daemons.
+type Supervisor struct {
+	cfg   Config
+	hooks *hooks.Runner
+	log   *zap.Logger
+
+	mu      sync.Mutex
+	daemons []*daemon // in configuration order
+}
+
+// daemon is a Daemon and its current process. Fields other than spec are
+// guarded by Supervisor.mu.
+type daemon struct {
+	spec   Daemon
+	signal syscall.Signal
+	cmd    *exec.Cmd // nil while not running
+	status Status
+}
+
+// New checks the daemons and returns a Supervisor that runs them once Run
+// is called.
+func New(daemons []Daemon, cfg Config, hooks *hooks.Runner, log *zap.Logger) (*Supervisor, error) {
+	s := &Supervisor{cfg: cfg, hooks: hooks, log: log}
+	seen := make(map[string]bool, len(daemons))
+	for _, d := range daemons {
+		if d.Name == "" {
+			return nil, errors.New("daemon without a name")
+		}
+		if seen[d.Name] {
+			return nil, fmt.Errorf("daemon %q: configured twice", d.Name)
+		}
+		seen[d.Name] = true
+		if len(d.Command) == 0 {
+			return nil, fmt.Errorf("daemon %q: no command", d.Name)
+		}
+		var sig syscall.Signal
+		if d.ReloadSignal != "" {
+			var ok bool
+			if sig, ok = signals[strings.ToUpper(d.ReloadSignal)]; !ok {
+				return nil, fmt.Errorf("daemon %q: unsupported reload signal %q (SIGHUP|SIGUSR1|SIGUSR2)", d.Name, d.ReloadSignal)
+			}
+		}
+		s.daemons = append(s.daemons, &daemon{spec: d, signal: sig, status: Status{Name: d.Name, State: StateStopped}})
+	}
+	return s, nil
+}
+
+// Run starts every daemon and restarts those that exit. When ctx is
+// cancelled it stops them and returns once they have exited.
+// Call this in a goroutine.
+func (s *Supervisor) Run(ctx context.Context) {
+	var wg sync.WaitGroup
+	for _, d := range s.daemons {
+		wg.Add(1)
+		go func(d *daemon) {
+			defer wg.Done()
+			s.supervise(ctx, d)
+		}(d)
+	}
+	wg.Wait()
+}
+
+// Manages reports whether the named daemon is run by the supervisor.
+func (s *Supervisor) Manages(name string) bool {
+	return s.find(name) != nil
+}
+
+// Reload sends the daemon its reload signal. A daemon that is not running
+// reads its configuration when it starts, so there is nothing to do.
+func (s *Supervisor) Reload(name string) error {
+	d := s.find(name)
+	if d == nil {
+		return fmt.Errorf("daemon %q is not supervised", name)
+	}
+	if d.signal == 0 {
+		return fmt.Errorf("daemon %q has no reload signal", name)
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if d.cmd == nil || d.status.State != StateRunning {
+		return nil
+	}
+	if err := d.cmd.Process.Signal(d.signal); err != nil {
+		return fmt.Errorf("signal %s: %w", name, err)
+	}
+	return nil
+}
+
+// Status returns the state of every daemon in configuration order.
+func (s *Supervisor) Status() []Status {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	out := make([]Status, len(s.daemons))
+	for i, d := range s.daemons {
+		out[i] = d.status
+	}
+	return out
+}
+
+// ─── Private helpers ──────────────────────────────────────────────────────
+
+func (s *Supervisor) find(name string) *daemon {
+	for _, d := range s.daemons {
+		if d.spec.Name == name {
+			return d
+		}
+	}
+	return nil
+}
+
+// supervise runs d until ctx is cancelled, restarting it whenever it
+// exits.
+func (s *Supervisor) supervise(ctx context.Context, d *daemon) {
+	backoff := s.cfg.MinBackoff
+	for {
+		start := time.Now()
+		err := s.runOnce(ctx, d)
+		if ctx.Err() != nil {
+			s.setStopped(d, err)
+			return
+		}
+		if time.Since(start) >= s.cfg.StableAfter {
+			backoff = s.cfg.MinBackoff
+		}
+		s.crashed(d, err, backoff)
+
+		select {
+		case <-ctx.Done():
+			s.setStopped(d, err)
+			return
+		case <-time.After(backoff):
+		}
+		backoff *= 2
+		if backoff > s.cfg.MaxBackoff {
+			backoff = s.cfg.MaxBackoff
+		}
+	}
+}
+
+// runOnce starts d and waits for it to exit. Cancelling ctx sends it
+// SIGTERM, and SIGKILL after StopTimeout.
+func (s *Supervisor) runOnce(ctx context.Context, d *daemon) error {
+	cmd := exec.CommandContext(ctx, d.spec.Command[0], d.spec.Command[1:]...)
+	cmd.SysProcAttr = sysProcAttr()
+	cmd.Cancel = func() error { return cmd.Process.Signal(syscall.SIGTERM) }
+	cmd.WaitDelay = s.cfg.StopTimeout
+	out := s.output(d.spec.Name)
+	defer out.Close()
+	cmd.Stdout, cmd.Stderr = out, out
+
+	s.mu.Lock()
+	d.status.State = StateStarting
+	d.status.NextStart = nil
+	s.mu.Unlock()
+	if err := cmd.Start(); err != nil {
+		return err
+	}
+
+	now := time.Now()
+	s.mu.Lock()
+	d.cmd = cmd
+	d.status.State = StateRunning
+	d.status.PID = cmd.Process.Pid
+	d.status.StartedAt = &now
+	s.mu.Unlock()
+	s.log.Info("daemon started", zap.String("daemon", d.spec.Name), zap.Int("pid", cmd.Process.Pid))
+	metrics.DaemonUp.WithLabelValues(d.spec.Name).Set(1)
+
+	err := cmd.Wait()
+	if err == nil {
+		err = errors.New("exit status 0")
+	}
+	return err
+}
+
+// crashed records that d exited on its own and will be restarted after
+// backoff.
+func (s *Supervisor) crashed(d *daemon, err error, backoff time.Duration) {
+	now := time.Now()
+	next := now.Add(backoff)
+	s.mu.Lock()
+	d.cmd = nil
+	d.status.State = StateBackoff
+	d.status.PID = 0
+	d.status.Restarts++
+	d.status.LastExit = err.Error()
+	d.status.LastExitAt = &now
+	d.status.NextStart = &next
+	restarts := d.status.Restarts
+	s.mu.Unlock()
+
+	s.log.Error("daemon exited; restarting", zap.String("daemon", d.spec.Name),
+		zap.Duration("backoff", backoff), zap.Int("restarts", restarts), zap.Error(err))
+	metrics.DaemonUp.WithLabelValues(d.spec.Name).Set(0)
+	metrics.DaemonRestartsTotal.WithLabelValues(d.spec.Name).Inc()
+	s.hooks.NotifyDaemon(hooks.DaemonNotice{
+		Daemon:    d.spec.Name,
+		Error:     err.Error(),
+		Restarts:  restarts,
+		RestartIn: backoff.String(),
+	})
+}
+
+func (s *Supervisor) setStopped(d *daemon, err error) {
+	s.mu.Lock()
+	d.cmd = nil
+	d.status.State = StateStopped
+	d.status.PID = 0
+	d.status.NextStart = nil
+	s.mu.Unlock()
+	s.log.Info("daemon stopped", zap.String("daemon", d.spec.Name), zap.NamedError("exit", err))
+	metrics.DaemonUp.WithLabelValues(d.spec.Name).Set(0)
+}
+
+// output returns a writer that logs what a daemon prints, line by line.
+func (s *Supervisor) output(name string) io.WriteCloser {
+	r, w := io.Pipe()
+	log := s.log.With(zap.String("daemon", name))
+	go func() {
+		sc := bufio.NewScanner(r)
+		sc.Buffer(make([]byte, 64*1024), 1024*1024)
+		for sc.Scan() {
+			log.Info(sc.Text())
+		}
+		// A line too long for the scanner ends the logging; keep draining
+		// so the daemon never blocks on a full pipe.
+		io.Copy(io.Discard, r)
+	}()
+	return w
+}
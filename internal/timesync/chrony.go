@@ -0,0 +1,197 @@
+// Package timesync manages the NTP client (chrony) and watches the clock.
+// Scheduled firewall rules and token expiry both trust the system time, so
+// the rest of AegisX asks this package whether that trust is warranted.
+package timesync
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"math"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"text/template"
+	"time"
+
+	"go.uber.org/zap"
+
+	"github.com/aegisx/aegisx/internal/metrics"
+)
+
+const chronyTemplate = `# AegisX managed chrony configuration — DO NOT EDIT MANUALLY
+{{ range .Servers }}server {{ . }} iburst
+{{ end }}{{ range .Pools }}pool {{ . }} iburst
+{{ end }}driftfile /var/lib/chrony/chrony.drift
+# Step the clock on large offsets during the first updates after boot only.
+makestep 1.0 3
+rtcsync
+`
+
+type Config struct {
+	ConfPath string        // e.g. /etc/chrony/chrony.conf
+	Servers  []string      // unicast NTP servers
+	Pools    []string      // NTP pools, e.g. pool.ntp.org
+	MaxSkew  time.Duration // offset above which the clock counts as unsynchronised
+	Interval time.Duration // between tracking checks, default 30s
+}
+
+// Status is the last observed state of the clock.
+type Status struct {
+	Synchronized bool      `json:"synchronized"`
+	Offset       float64   `json:"offsetSeconds"` // pending correction; positive when the clock is slow
+	Stratum      int       `json:"stratum"`
+	Reference    string    `json:"reference"`
+	LeapStatus   string    `json:"leapStatus"`
+	CheckedAt    time.Time `json:"checkedAt"`
+	Error        string    `json:"error,omitempty"`
+}
+
+// Monitor polls chrony and keeps the latest Status.
+type Monitor struct {
+	cfg Config
+	log *zap.Logger
+
+	mu     sync.RWMutex
+	status Status
+}
+
+func NewMonitor(cfg Config, log *zap.Logger) *Monitor {
+	if cfg.Interval <= 0 {
+		cfg.Interval = 30 * time.Second
+	}
+	return &Monitor{
+		cfg:    cfg,
+		log:    log,
+		status: Status{Error: "not checked yet"},
+	}
+}
+
+// WriteConfig renders the chrony configuration and restarts chronyd when the
+// file changed.
+func (m *Monitor) WriteConfig() error {
+	if len(m.cfg.Servers) == 0 && len(m.cfg.Pools) == 0 {
+		return fmt.Errorf("no NTP servers or pools configured")
+	}
+	tmpl := template.Must(template.New("chrony").Parse(chronyTemplate))
+	var buf bytes.Buffer
+	if err := tmpl.Execute(&buf, m.cfg); err != nil {
+		return fmt.Errorf("render chrony config: %w", err)
+	}
+
+	if cur, err := os.ReadFile(m.cfg.ConfPath); err == nil && bytes.Equal(cur, buf.Bytes()) {
+		return nil
+	}
+	if err := os.MkdirAll(filepath.Dir(m.cfg.ConfPath), 0755); err != nil {
+		return err
+	}
+	tmp := m.cfg.ConfPath + ".tmp"
+	if err := os.WriteFile(tmp, buf.Bytes(), 0644); err != nil {
+		return fmt.Errorf("write chrony config: %w", err)
+	}
+	if err := os.Rename(tmp, m.cfg.ConfPath); err != nil {
+		return fmt.Errorf("write chrony config: %w", err)
+	}
+
+	// Debian names the unit chrony, Red Hat chronyd.
+	out, err := exec.Command("systemctl", "restart", "chronyd").CombinedOutput()
+	if err != nil {
+		out2, err2 := exec.Command("systemctl", "restart", "chrony").CombinedOutput()
+		if err2 != nil {
+			return fmt.Errorf("restart chrony: %w (output: %s %s)", err2, out, out2)
+		}
+	}
+	m.log.Info("chrony configuration updated",
+		zap.Strings("servers", m.cfg.Servers), zap.Strings("pools", m.cfg.Pools))
+	return nil
+}
+
+// Run checks the clock every interval until ctx is cancelled.
+// Call this in a goroutine.
+func (m *Monitor) Run(ctx context.Context) {
+	m.check()
+	ticker := time.NewTicker(m.cfg.Interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			m.check()
+		}
+	}
+}
+
+// Status returns the last observed clock state.
+func (m *Monitor) Status() Status {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	return m.status
+}
+
+// Synced returns nil while the clock is synchronised within MaxSkew, and an
+// error describing the problem otherwise.
+func (m *Monitor) Synced() error {
+	st := m.Status()
+	if st.Synchronized {
+		return nil
+	}
+	if st.Error != "" {
+		return fmt.Errorf("clock not synchronised: %s", st.Error)
+	}
+	return fmt.Errorf("clock not synchronised: leap status %q, offset %.3fs (max %s)",
+		st.LeapStatus, st.Offset, m.cfg.MaxSkew)
+}
+
+// ─── Private helpers ──────────────────────────────────────────────────────
+
+func (m *Monitor) check() {
+	st, err := tracking()
+	if err != nil {
+		st.Error = err.Error()
+	} else {
+		st.Synchronized = st.LeapStatus != "Not synchronised" &&
+			(m.cfg.MaxSkew <= 0 || math.Abs(st.Offset) <= m.cfg.MaxSkew.Seconds())
+	}
+	st.CheckedAt = time.Now()
+
+	m.mu.Lock()
+	was := m.status.Synchronized
+	m.status = st
+	m.mu.Unlock()
+
+	metrics.TimeOffsetSeconds.Set(st.Offset)
+	if st.Synchronized {
+		metrics.TimeSynchronized.Set(1)
+	} else {
+		metrics.TimeSynchronized.Set(0)
+	}
+	switch {
+	case was && !st.Synchronized:
+		m.log.Warn("clock lost synchronisation", zap.Float64("offset", st.Offset),
+			zap.String("leap_status", st.LeapStatus), zap.String("error", st.Error))
+	case !was && st.Synchronized:
+		m.log.Info("clock synchronised", zap.Float64("offset", st.Offset),
+			zap.String("reference", st.Reference), zap.Int("stratum", st.Stratum))
+	}
+}
+
+// tracking parses `chronyc -c tracking`: reference ID, reference name,
+// stratum, reference time, system time offset, … , leap status (last field).
+func tracking() (Status, error) {
+	out, err := exec.Command("chronyc", "-c", "tracking").Output()
+	if err != nil {
+		return Status{}, fmt.Errorf("chronyc tracking: %w", err)
+	}
+	f := strings.Split(strings.TrimSpace(string(out)), ",")
+	if len(f) < 14 {
+		return Status{}, fmt.Errorf("chronyc tracking: unexpected output %q", out)
+	}
+	st := Status{Reference: f[1], LeapStatus: f[len(f)-1]}
+	st.Stratum, _ = strconv.Atoi(f[2])
+	st.Offset, _ = strconv.ParseFloat(f[4], 64)
+	return st, nil
+}
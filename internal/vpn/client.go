@@ -0,0 +1,131 @@
+package vpn
+
+import (
+	"fmt"
+	"net"
+	"strings"
+	"text/template"
+
+	"golang.zx2c4.com/wireguard/wgctrl"
+	"golang.zx2c4.com/wireguard/wgctrl/wgtypes"
+)
+
+// privateKeyPlaceholder is rendered when the server does not know the
+// client's private key (it is only ever returned once, at generation time).
+const privateKeyPlaceholder = "<paste your private key here>"
+
+const wgClientTemplate = `# WireGuard client configuration — generated by AegisX
+[Interface]
+PrivateKey = {{ .PrivateKey }}
+Address    = {{ join .Address ", " }}
+{{ if gt .MTU 0 }}MTU        = {{ .MTU }}
+{{ end }}{{ if .DNS }}DNS        = {{ join .DNS ", " }}
+{{ end }}
+[Peer]
+PublicKey  = {{ .ServerPublicKey }}
+AllowedIPs = {{ join .AllowedIPs ", " }}
+Endpoint   = {{ .Endpoint }}
+{{ if .PresharedKey }}PresharedKey = {{ .PresharedKey }}
+{{ end }}{{ if gt .KeepAlive 0 }}PersistentKeepalive = {{ .KeepAlive }}
+{{ end }}`
+
+// ClientConfig is everything needed to render a peer's wg-quick config.
+type ClientConfig struct {
+	PrivateKey      string
+	Address         []string // the peer's tunnel address(es)
+	DNS             []string
+	MTU             int
+	ServerPublicKey string
+	Endpoint        string   // server host:port as reachable by the client
+	AllowedIPs      []string // routes sent through the tunnel
+	PresharedKey    string
+	KeepAlive       int
+}
+
+// GenerateClientConfig renders a client-side wg-quick configuration.
+func GenerateClientConfig(cfg ClientConfig) (string, error) {
+	if cfg.PrivateKey == "" {
+		cfg.PrivateKey = privateKeyPlaceholder
+	}
+	if cfg.Endpoint == "" {
+		return "", fmt.Errorf("server endpoint is not configured")
+	}
+
+	tmpl, err := template.New("wg-client").
+		Funcs(template.FuncMap{"join": strings.Join}).
+		Parse(wgClientTemplate)
+	if err != nil {
+		return "", err
+	}
+
+	var sb strings.Builder
+	if err := tmpl.Execute(&sb, cfg); err != nil {
+		return "", err
+	}
+	return sb.String(), nil
+}
+
+// ReplacePeerKey swaps a live peer's public key in place, keeping its allowed
+// IPs and preshared key, and the endpoint and keepalive the device has for
+// the old key, so a rotation takes effect without a full re-apply.
+func (m *Manager) ReplacePeerKey(oldKey, newKey string, allowedIPs []string, presharedKey string) error {
+	oldPub, err := wgtypes.ParseKey(oldKey)
+	if err != nil {
+		return fmt.Errorf("parse old key: %w", err)
+	}
+	newPub, err := wgtypes.ParseKey(newKey)
+	if err != nil {
+		return fmt.Errorf("parse new key: %w", err)
+	}
+
+	nets := make([]net.IPNet, 0, len(allowedIPs))
+	for _, cidr := range allowedIPs {
+		_, n, err := net.ParseCIDR(cidr)
+		if err != nil {
+			return fmt.Errorf("parse allowed IP %q: %w", cidr, err)
+		}
+		nets = append(nets, *n)
+	}
+
+	peer := wgtypes.PeerConfig{
+		PublicKey:         newPub,
+		ReplaceAllowedIPs: true,
+		AllowedIPs:        nets,
+	}
+	if presharedKey != "" {
+		psk, err := wgtypes.ParseKey(presharedKey)
+		if err != nil {
+			return fmt.Errorf("parse preshared key: %w", err)
+		}
+		peer.PresharedKey = &psk
+	}
+
+	client, err := wgctrl.New()
+	if err != nil {
+		return fmt.Errorf("wgctrl: %w", err)
+	}
+	defer client.Close()
+
+	dev, err := client.Device(m.iface)
+	if err != nil {
+		return fmt.Errorf("read device %s: %w", m.iface, err)
+	}
+	for _, p := range dev.Peers {
+		if p.PublicKey != oldPub {
+			continue
+		}
+		peer.Endpoint = p.Endpoint
+		if p.PersistentKeepaliveInterval > 0 {
+			keepAlive := p.PersistentKeepaliveInterval
+			peer.PersistentKeepaliveInterval = &keepAlive
+		}
+		break
+	}
+
+	return client.ConfigureDevice(m.iface, wgtypes.Config{
+		Peers: []wgtypes.PeerConfig{
+			{PublicKey: oldPub, Remove: true},
+			peer,
+		},
+	})
+}
@@ -0,0 +1,110 @@
+package vpn
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"os/exec"
+	"strconv"
+)
+
+// Per-packet overheads in bytes.
+const (
+	wgOverheadIPv4 = 60 // outer IPv4 (20) + UDP (8) + WireGuard header/tag (32)
+	wgOverheadIPv6 = 80 // outer IPv6 (40) + UDP (8) + WireGuard header/tag (32)
+
+	// DefaultUplinkMTU is the Ethernet MTU assumed when none is configured.
+	DefaultUplinkMTU = 1500
+	// minTunnelMTU is the IPv6 minimum link MTU; WireGuard refuses less
+	// when carrying IPv6 and many stacks misbehave below it anyway.
+	minTunnelMTU = 1280
+	// DefaultKeepAlive keeps NAT mappings for roaming peers alive. 25s sits
+	// under the 30s UDP timeout used by most consumer NATs.
+	DefaultKeepAlive = 25
+)
+
+// encapOverhead lists additional underlay encapsulations an uplink may add.
+var encapOverhead = map[string]int{
+	"":          0,
+	"none":      0,
+	"pppoe":     8,
+	"gre":       24,
+	"vxlan":     50,
+	"wireguard": wgOverheadIPv6, // WireGuard nested in another WireGuard tunnel
+	"ipsec":     73,             // ESP tunnel mode, AES-GCM, worst case
+}
+
+// ValidEncapsulation reports whether name is a known uplink encapsulation.
+func ValidEncapsulation(name string) bool {
+	_, ok := encapOverhead[name]
+	return ok
+}
+
+// RecommendedMTU returns the tunnel MTU for an uplink MTU and the
+// encapsulations stacked beneath WireGuard. ipv6Underlay selects the larger
+// outer-header overhead; when unsure, pass true for a safe value.
+func RecommendedMTU(uplinkMTU int, ipv6Underlay bool, encaps ...string) int {
+	if uplinkMTU <= 0 {
+		uplinkMTU = DefaultUplinkMTU
+	}
+	mtu := uplinkMTU - wgOverheadIPv4
+	if ipv6Underlay {
+		mtu = uplinkMTU - wgOverheadIPv6
+	}
+	for _, e := range encaps {
+		mtu -= encapOverhead[e]
+	}
+	if mtu < minTunnelMTU {
+		mtu = minTunnelMTU
+	}
+	return mtu
+}
+
+// ProbePathMTU discovers the path MTU to host by binary-searching
+// don't-fragment ICMP echo sizes with ping(8). It returns the largest
+// packet size (including IP + ICMP headers) that got through.
+func ProbePathMTU(ctx context.Context, host string) (int, error) {
+	ip := net.ParseIP(host)
+	if ip == nil {
+		addrs, err := net.DefaultResolver.LookupIPAddr(ctx, host)
+		if err != nil {
+			return 0, fmt.Errorf("resolve %s: %w", host, err)
+		}
+		if len(addrs) == 0 {
+			return 0, fmt.Errorf("resolve %s: no addresses", host)
+		}
+		ip = addrs[0].IP
+	}
+
+	v6 := ip.To4() == nil
+	headers := 28 // IPv4 (20) + ICMP (8)
+	if v6 {
+		headers = 48 // IPv6 (40) + ICMPv6 (8)
+	}
+
+	ping := func(size int) bool {
+		args := []string{"-c", "1", "-W", "1", "-M", "do", "-s", strconv.Itoa(size - headers)}
+		if v6 {
+			args = append([]string{"-6"}, args...)
+		}
+		args = append(args, ip.String())
+		return exec.CommandContext(ctx, "ping", args...).Run() == nil
+	}
+
+	lo, hi := 576, 9000
+	if !ping(lo) {
+		return 0, fmt.Errorf("%s unreachable with %d-byte packets", ip, lo)
+	}
+	for lo < hi {
+		if ctx.Err() != nil {
+			return 0, ctx.Err()
+		}
+		mid := (lo + hi + 1) / 2
+		if ping(mid) {
+			lo = mid
+		} else {
+			hi = mid - 1
+		}
+	}
+	return lo, nil
+}
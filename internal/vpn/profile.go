@@ -0,0 +1,47 @@
+package vpn
+
+import "fmt"
+
+// Client tunnel profiles.
+const (
+	// ProfileSplit routes only the configured internal CIDRs through the
+	// tunnel and leaves the client's DNS untouched.
+	ProfileSplit = "split"
+	// ProfileFull routes all IPv4/IPv6 traffic through the tunnel and
+	// overrides the client's DNS so lookups cannot leak.
+	ProfileFull = "full"
+)
+
+// ProfileSettings holds the server-wide inputs for profile rendering.
+type ProfileSettings struct {
+	SplitCIDRs []string // routes for split tunnel; falls back to the tunnel network
+	Network    string   // the VPN tunnel network
+	DNS        []string // resolvers pushed to full-tunnel clients
+}
+
+// ValidProfile reports whether p names a known tunnel profile.
+func ValidProfile(p string) bool {
+	return p == ProfileSplit || p == ProfileFull
+}
+
+// ApplyProfile sets AllowedIPs and DNS on a client config according to the
+// selected profile.
+func ApplyProfile(cfg *ClientConfig, profile string, s ProfileSettings) error {
+	switch profile {
+	case ProfileFull:
+		if len(s.DNS) == 0 {
+			return fmt.Errorf("full tunnel profile requires vpn.dns to be configured")
+		}
+		cfg.AllowedIPs = []string{"0.0.0.0/0", "::/0"}
+		cfg.DNS = s.DNS
+	case ProfileSplit, "":
+		cfg.AllowedIPs = s.SplitCIDRs
+		if len(cfg.AllowedIPs) == 0 {
+			cfg.AllowedIPs = []string{s.Network}
+		}
+		cfg.DNS = nil
+	default:
+		return fmt.Errorf("unknown tunnel profile %q", profile)
+	}
+	return nil
+}
@@ -0,0 +1,240 @@
+package vpn
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"go.uber.org/zap"
+
+	"github.com/aegisx/aegisx/internal/policy"
+)
+
+// Relay transports carry WireGuard's UDP over something that survives
+// networks which block or throttle UDP.
+const (
+	TransportUDP      = "udp"      // plain WireGuard, no relay
+	TransportUDP2Raw  = "udp2raw"  // fake-TCP framing
+	TransportWSTunnel = "wstunnel" // WebSocket over HTTP(S)
+)
+
+const (
+	relayMinBackoff = time.Second
+	relayMaxBackoff = 30 * time.Second
+	// relayStableAfter resets the backoff once a relay has stayed up this long.
+	relayStableAfter = time.Minute
+)
+
+// RelayBinaries are the programs started for the relay transports, by name
+// on PATH or by absolute path. They come from the server config so that a
+// policy can pick a transport but never the program the daemon runs.
+type RelayBinaries struct {
+	UDP2Raw  string
+	WSTunnel string
+}
+
+// RelayStatus reports the state of the supervised relay process.
+type RelayStatus struct {
+	Mode      string    `json:"mode"`
+	Listen    string    `json:"listen"`
+	Running   bool      `json:"running"`
+	PID       int       `json:"pid,omitempty"`
+	Restarts  int       `json:"restarts"`
+	StartedAt time.Time `json:"startedAt,omitempty"`
+	LastError string    `json:"lastError,omitempty"`
+}
+
+// relay supervises one relay server process, restarting it with exponential
+// backoff whenever it exits.
+type relay struct {
+	spec   policy.VPNTransport
+	args   []string
+	conf   string // content of the relay's config file, if it reads one
+	cancel context.CancelFunc
+	done   chan struct{}
+	log    *zap.Logger
+
+	mu     sync.Mutex
+	status RelayStatus
+}
+
+// relayCommand builds the relay server argv that forwards to the local
+// WireGuard listen port. udp2raw takes its options from confFile instead,
+// returned as conf, so the shared secret stays out of the process list.
+func relayCommand(t *policy.VPNTransport, listenPort int, bins RelayBinaries, confFile string) (args []string, conf string, err error) {
+	target := fmt.Sprintf("127.0.0.1:%d", listenPort)
+	switch t.Mode {
+	case TransportUDP2Raw:
+		if strings.ContainsAny(t.Listen+t.Secret, " \t\r\n") {
+			return nil, "", fmt.Errorf("udp2raw listen address and key must not contain blanks")
+		}
+		conf = fmt.Sprintf("-s\n-l %s\n-r %s\n-k %s\n--raw-mode faketcp\n-a\n", t.Listen, target, t.Secret)
+		return []string{bins.UDP2Raw, "--conf-file", confFile}, conf, nil
+	case TransportWSTunnel:
+		args := []string{bins.WSTunnel, "server", "--restrict-to", target}
+		if t.Path != "" {
+			args = append(args, "--restrict-http-upgrade-path-prefix", strings.TrimPrefix(t.Path, "/"))
+		}
+		return append(args, "ws://"+t.Listen), "", nil
+	default:
+		return nil, "", fmt.Errorf("unknown transport %q", t.Mode)
+	}
+}
+
+func startRelay(spec policy.VPNTransport, args []string, conf string, log *zap.Logger) *relay {
+	ctx, cancel := context.WithCancel(context.Background())
+	r := &relay{
+		spec:   spec,
+		args:   args,
+		conf:   conf,
+		cancel: cancel,
+		done:   make(chan struct{}),
+		log:    log.With(zap.String("transport", spec.Mode)),
+		status: RelayStatus{Mode: spec.Mode, Listen: spec.Listen},
+	}
+	go r.supervise(ctx)
+	return r
+}
+
+func (r *relay) supervise(ctx context.Context) {
+	defer close(r.done)
+
+	backoff := relayMinBackoff
+	for {
+		cmd := exec.CommandContext(ctx, r.args[0], r.args[1:]...)
+		started := time.Now()
+		err := cmd.Start()
+		if err == nil {
+			r.setRunning(cmd.Process.Pid, started)
+			r.log.Info("VPN relay started", zap.String("listen", r.spec.Listen), zap.Int("pid", cmd.Process.Pid))
+			err = cmd.Wait()
+		}
+		r.setStopped(err)
+
+		if ctx.Err() != nil {
+			return
+		}
+		if time.Since(started) >= relayStableAfter {
+			backoff = relayMinBackoff
+		}
+		r.log.Warn("VPN relay exited; restarting", zap.Error(err), zap.Duration("backoff", backoff))
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(backoff):
+		}
+		backoff *= 2
+		if backoff > relayMaxBackoff {
+			backoff = relayMaxBackoff
+		}
+	}
+}
+
+func (r *relay) stop() {
+	r.cancel()
+	<-r.done
+}
+
+func (r *relay) snapshot() RelayStatus {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.status
+}
+
+func (r *relay) setRunning(pid int, at time.Time) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.status.Running = true
+	r.status.PID = pid
+	r.status.StartedAt = at
+}
+
+func (r *relay) setStopped(err error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.status.Running {
+		r.status.Restarts++
+	}
+	r.status.Running = false
+	r.status.PID = 0
+	if err != nil {
+		r.status.LastError = err.Error()
+	}
+}
+
+// ─── Manager integration ──────────────────────────────────────────────────
+
+// syncRelay starts, restarts or stops the relay so it matches the policy.
+// An unchanged transport leaves the running process alone.
+func (m *Manager) syncRelay(cfg *policy.CompiledVPNConfig) error {
+	m.relayMu.Lock()
+	defer m.relayMu.Unlock()
+
+	if cfg.Transport == nil || cfg.Transport.Mode == "" || cfg.Transport.Mode == TransportUDP {
+		if m.relay != nil {
+			m.relay.stop()
+			m.relay = nil
+			os.Remove(m.relayConfPath())
+			m.log.Info("VPN relay stopped", zap.String("iface", m.iface))
+		}
+		return nil
+	}
+
+	args, conf, err := relayCommand(cfg.Transport, cfg.ListenPort, m.relayBins, m.relayConfPath())
+	if err != nil {
+		return err
+	}
+	if m.relay != nil {
+		if sameArgs(m.relay.args, args) && m.relay.conf == conf {
+			return nil
+		}
+		m.relay.stop()
+		m.relay = nil
+	}
+	if _, err := exec.LookPath(args[0]); err != nil {
+		return fmt.Errorf("relay binary %q not found: %w", args[0], err)
+	}
+	if conf != "" {
+		if err := os.WriteFile(m.relayConfPath(), []byte(conf), 0o600); err != nil {
+			return fmt.Errorf("write relay config: %w", err)
+		}
+	}
+	m.relay = startRelay(*cfg.Transport, args, conf, m.log)
+	return nil
+}
+
+// relayConfPath is the config file of a relay that reads one, beside the
+// WireGuard config and readable by root only.
+func (m *Manager) relayConfPath() string {
+	return filepath.Join(filepath.Dir(m.configPath), m.iface+"-relay.conf")
+}
+
+// RelayStatus returns the state of the relay, or nil when the interface uses
+// plain UDP.
+func (m *Manager) RelayStatus() *RelayStatus {
+	m.relayMu.Lock()
+	defer m.relayMu.Unlock()
+	if m.relay == nil {
+		return nil
+	}
+	st := m.relay.snapshot()
+	return &st
+}
+
+func sameArgs(a, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,90 @@
+package vpn
+
+import (
+	"context"
+	"time"
+
+	"go.uber.org/zap"
+
+	"github.com/aegisx/aegisx/internal/metrics"
+	"github.com/aegisx/aegisx/internal/store"
+)
+
+// handshakeFreshness is how recent a handshake must be for a peer to count
+// as connected. WireGuard re-keys every 2 minutes on an active session.
+const handshakeFreshness = 3 * time.Minute
+
+// StatsCollector periodically samples per-peer counters from the kernel and
+// persists them so the API can serve bandwidth history. Old samples are
+// pruned by package retention.
+type StatsCollector struct {
+	mgr      *Manager
+	store    *store.VPNStore
+	interval time.Duration
+	log      *zap.Logger
+}
+
+func NewStatsCollector(mgr *Manager, store *store.VPNStore, interval time.Duration, log *zap.Logger) *StatsCollector {
+	if interval <= 0 {
+		interval = time.Minute
+	}
+	return &StatsCollector{mgr: mgr, store: store, interval: interval, log: log}
+}
+
+// Run samples until ctx is cancelled. Call this in a goroutine.
+func (c *StatsCollector) Run(ctx context.Context) {
+	ticker := time.NewTicker(c.interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case now := <-ticker.C:
+			if err := c.sample(ctx, now); err != nil {
+				c.log.Warn("vpn stats sample failed", zap.Error(err))
+			}
+		}
+	}
+}
+
+func (c *StatsCollector) sample(ctx context.Context, now time.Time) error {
+	status, err := c.mgr.Status()
+	if err != nil {
+		return err
+	}
+	ids, err := c.store.PeerIDsByPublicKey(ctx)
+	if err != nil {
+		return err
+	}
+
+	bucket := now.Truncate(c.interval)
+	connected := 0
+	samples := make([]store.PeerSample, 0, len(status.Peers))
+	for _, p := range status.Peers {
+		if now.Sub(p.LastHandshakeTime) < handshakeFreshness {
+			connected++
+		}
+		id, ok := ids[p.PublicKey]
+		if !ok {
+			continue // peer configured out-of-band; nothing to attribute it to
+		}
+		smp := store.PeerSample{
+			PeerID:  id,
+			Bucket:  bucket,
+			RxBytes: p.RxBytes,
+			TxBytes: p.TxBytes,
+		}
+		if !p.LastHandshakeTime.IsZero() {
+			hs := p.LastHandshakeTime
+			smp.LastHandshake = &hs
+		}
+		samples = append(samples, smp)
+	}
+	metrics.VPNPeersConnected.Set(float64(connected))
+
+	if len(samples) == 0 {
+		return nil
+	}
+	return c.store.RecordPeerSamples(ctx, samples)
+}
@@ -9,7 +9,9 @@ import (
 	"os"
 	"os/exec"
 	"strings"
+	"sync"
 	"text/template"
+	"time"
 
 	"go.uber.org/zap"
 	"golang.zx2c4.com/wireguard/wgctrl"
@@ -23,6 +25,7 @@ const wgConfigTemplate = `# WireGuard configuration — managed by AegisX
 PrivateKey = {{ .PrivateKey }}
 Address    = {{ .Address }}
 ListenPort = {{ .ListenPort }}
+{{ if gt .MTU 0 }}MTU        = {{ .MTU }}{{ end }}
 {{ if .DNS }}DNS = {{ .DNS }}{{ end }}
 PostUp   = iptables -A FORWARD -i %i -j ACCEPT; iptables -A FORWARD -o %i -j ACCEPT; iptables -t nat -A POSTROUTING -o eth0 -j MASQUERADE
 PostDown = iptables -D FORWARD -i %i -j ACCEPT; iptables -D FORWARD -o %i -j ACCEPT; iptables -t nat -D POSTROUTING -o eth0 -j MASQUERADE
@@ -41,10 +44,14 @@ type Manager struct {
 	iface      string
 	configPath string
 	log        *zap.Logger
+
+	relayBins RelayBinaries
+	relayMu   sync.Mutex
+	relay     *relay // nil when peers connect over plain UDP
 }
 
-func NewManager(iface, configPath string, log *zap.Logger) *Manager {
-	return &Manager{iface: iface, configPath: configPath, log: log}
+func NewManager(iface, configPath string, relays RelayBinaries, log *zap.Logger) *Manager {
+	return &Manager{iface: iface, configPath: configPath, relayBins: relays, log: log}
 }
 
 // GenerateKeyPair generates a new WireGuard private/public key pair.
@@ -65,7 +72,8 @@ func GeneratePresharedKey() (string, error) {
 	return base64.StdEncoding.EncodeToString(key), nil
 }
 
-// Apply writes the WireGuard config and brings the interface up.
+// Apply writes the WireGuard config, brings the interface up and reconciles
+// the relay transport, if any.
 func (m *Manager) Apply(cfg *policy.CompiledVPNConfig) error {
 	config, err := m.generate(cfg)
 	if err != nil {
@@ -78,9 +86,31 @@ func (m *Manager) Apply(cfg *policy.CompiledVPNConfig) error {
 
 	// Bring interface up / sync
 	if m.isUp() {
-		return m.syncConf()
+		err = m.syncConf()
+	} else {
+		err = m.up()
+	}
+	if err != nil {
+		return err
+	}
+	return m.syncRelay(cfg)
+}
+
+// Render returns the wg-quick config Apply would write for cfg.
+func (m *Manager) Render(cfg *policy.CompiledVPNConfig) (string, error) {
+	return m.generate(cfg)
+}
+
+// KernelSupport reports whether the wireguard kernel module is loaded or
+// can be loaded.
+func KernelSupport() error {
+	if _, err := os.Stat("/sys/module/wireguard"); err == nil {
+		return nil
+	}
+	if out, err := exec.Command("modprobe", "-n", "wireguard").CombinedOutput(); err != nil {
+		return fmt.Errorf("wireguard module not available: %w (output: %s)", err, out)
 	}
-	return m.up()
+	return nil
 }
 
 // Status returns current WireGuard interface status.
@@ -101,6 +131,7 @@ func (m *Manager) Status() (*InterfaceStatus, error) {
 		PublicKey:  device.PublicKey.String(),
 		ListenPort: device.ListenPort,
 		Peers:      make([]PeerStatus, len(device.Peers)),
+		Relay:      m.RelayStatus(),
 	}
 
 	for i, p := range device.Peers {
@@ -118,8 +149,9 @@ func (m *Manager) Status() (*InterfaceStatus, error) {
 	return status, nil
 }
 
-// Down tears down the WireGuard interface.
+// Down tears down the relay and the WireGuard interface.
 func (m *Manager) Down() error {
+	m.syncRelay(&policy.CompiledVPNConfig{})
 	out, err := exec.Command("wg-quick", "down", m.iface).CombinedOutput()
 	if err != nil {
 		return fmt.Errorf("wg-quick down: %w (output: %s)", err, out)
@@ -130,6 +162,14 @@ func (m *Manager) Down() error {
 // ─── Private helpers ──────────────────────────────────────────────────────
 
 func (m *Manager) generate(cfg *policy.CompiledVPNConfig) (string, error) {
+	// Derive the MTU when the uplink is described but no MTU is pinned.
+	// Without either, wg-quick's own route-based guess is used.
+	if cfg.MTU == 0 && (cfg.UplinkMTU > 0 || len(cfg.Encapsulation) > 0) {
+		tuned := *cfg
+		tuned.MTU = RecommendedMTU(cfg.UplinkMTU, true, cfg.Encapsulation...)
+		cfg = &tuned
+	}
+
 	funcMap := template.FuncMap{
 		"join": strings.Join,
 	}
@@ -184,13 +224,14 @@ type InterfaceStatus struct {
 	PublicKey  string
 	ListenPort int
 	Peers      []PeerStatus
+	Relay      *RelayStatus
 }
 
 type PeerStatus struct {
 	PublicKey         string
 	Endpoint          string
 	AllowedIPs        []string
-	LastHandshakeTime interface{}
+	LastHandshakeTime time.Time
 	RxBytes           int64
 	TxBytes           int64
 }
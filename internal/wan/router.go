@@ -0,0 +1,153 @@
+// Package wan installs the policy-routing state behind a WANPolicy: one
+// routing table per uplink holding its default route, and fwmark rules that
+// send marked traffic to that table. Which uplink new connections get is
+// decided by the nftables marks rendered by the firewall adapter. It also
+// manages the VRF devices of VRFPolicies and the routes of their tables.
+package wan
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"reflect"
+	"strconv"
+	"strings"
+	"sync"
+
+	"go.uber.org/zap"
+
+	"github.com/aegisx/aegisx/internal/policy"
+)
+
+// rulePriorityBase is the first `ip rule` priority used by AegisX. The rule
+// at the base lets more specific main-table routes (LAN, VPN) win over the
+// per-uplink default routes; fwmark rules follow it.
+const rulePriorityBase = 5000
+
+// Router reconciles kernel routing tables and rules with the compiled WAN.
+type Router struct {
+	dryRun bool
+	log    *zap.Logger
+
+	mu         sync.Mutex
+	applied    *policy.CompiledWAN
+	priorities []int // ip rule priorities installed by the last Apply
+	tables     []int // routing tables populated by the last Apply
+
+	vrfs []policy.VRF // applied by the last ApplyVRFs
+}
+
+func NewRouter(dryRun bool, log *zap.Logger) *Router {
+	return &Router{dryRun: dryRun, log: log}
+}
+
+// Apply replaces the routing state of the previous WAN with w. A nil w
+// removes everything that was installed. Applying an unchanged WAN is a
+// no-op, so periodic policy reloads don't churn the routing rules.
+func (r *Router) Apply(w *policy.CompiledWAN) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if reflect.DeepEqual(r.applied, w) {
+		return nil
+	}
+	r.applied = nil
+
+	var cmds [][]string
+	for _, p := range r.priorities {
+		cmds = append(cmds, []string{"rule", "del", "priority", strconv.Itoa(p)})
+	}
+	for _, t := range r.tables {
+		cmds = append(cmds, []string{"route", "flush", "table", strconv.Itoa(t)})
+	}
+	r.run(cmds, true)
+	r.priorities, r.tables = nil, nil
+	if w == nil {
+		return nil
+	}
+
+	cmds = [][]string{{"rule", "add", "priority", strconv.Itoa(rulePriorityBase),
+		"lookup", "main", "suppress_prefixlength", "0"}}
+	r.priorities = append(r.priorities, rulePriorityBase)
+	for i, u := range w.Uplinks {
+		table := strconv.Itoa(u.Table)
+		route := []string{"route", "replace", "default"}
+		if u.Gateway != "" {
+			route = append(route, "via", u.Gateway)
+		}
+		route = append(route, "dev", u.Interface, "table", table)
+
+		prio := rulePriorityBase + i + 1
+		cmds = append(cmds, route, []string{"rule", "add", "priority", strconv.Itoa(prio),
+			"fwmark", fmt.Sprintf("%#x", u.Mark), "lookup", table})
+		r.priorities = append(r.priorities, prio)
+		r.tables = append(r.tables, u.Table)
+	}
+	if err := r.run(cmds, false); err != nil {
+		return err
+	}
+	r.applied = w
+	r.log.Info("wan routing applied", zap.Int("uplinks", len(w.Uplinks)), zap.String("mode", w.Mode))
+	return nil
+}
+
+func (r *Router) run(cmds [][]string, ignoreErrors bool) error {
+	for _, args := range cmds {
+		if r.dryRun {
+			r.log.Info("dry-run: ip", zap.Strings("args", args))
+			continue
+		}
+		out, err := exec.Command("ip", args...).CombinedOutput()
+		if err != nil && !ignoreErrors {
+			return fmt.Errorf("ip %s: %w (output: %s)", strings.Join(args, " "), err, out)
+		}
+	}
+	return nil
+}
+
+// ─── Status ───────────────────────────────────────────────────────────────
+
+// UplinkStatus combines an uplink's configuration, health and counters.
+type UplinkStatus struct {
+	Name      string `json:"name"`
+	Interface string `json:"interface"`
+	Gateway   string `json:"gateway,omitempty"`
+	Table     int    `json:"table"`
+	Mark      string `json:"mark"`
+	Weight    int    `json:"weight"`
+	Priority  int    `json:"priority"`
+	LinkUp    bool   `json:"linkUp"`
+	Healthy   bool   `json:"healthy"` // true when there is no check configured
+	Active    bool   `json:"active"`  // receiving new connections
+	RxBytes   int64  `json:"rxBytes"`
+	TxBytes   int64  `json:"txBytes"`
+}
+
+// NewUplinkStatus fills the link state and byte counters of u from sysfs.
+func NewUplinkStatus(u policy.CompiledWANUplink, healthy, active bool) UplinkStatus {
+	dir := "/sys/class/net/" + u.Interface
+	oper, _ := os.ReadFile(dir + "/operstate")
+	state := strings.TrimSpace(string(oper))
+	return UplinkStatus{
+		Name:      u.Name,
+		Interface: u.Interface,
+		Gateway:   u.Gateway,
+		Table:     u.Table,
+		Mark:      fmt.Sprintf("%#x", u.Mark),
+		Weight:    u.Weight,
+		Priority:  u.Priority,
+		LinkUp:    state == "up" || state == "unknown", // PPP and tunnels report "unknown"
+		Healthy:   healthy,
+		Active:    active,
+		RxBytes:   readCounter(dir + "/statistics/rx_bytes"),
+		TxBytes:   readCounter(dir + "/statistics/tx_bytes"),
+	}
+}
+
+func readCounter(path string) int64 {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return 0
+	}
+	n, _ := strconv.ParseInt(strings.TrimSpace(string(b)), 10, 64)
+	return n
+}
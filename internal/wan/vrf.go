@@ -0,0 +1,164 @@
+package wan
+
+import (
+	"encoding/json"
+	"os"
+	"os/exec"
+	"reflect"
+	"strconv"
+	"strings"
+
+	"go.uber.org/zap"
+
+	"github.com/aegisx/aegisx/internal/policy"
+)
+
+// ApplyVRFs reconciles the VRF devices, their interfaces and the routes of
+// their tables with vrfs. VRFs no longer listed are deleted, which releases
+// their interfaces back to the default VRF. Applying unchanged VRFs is a
+// no-op. A VRF that already exists with the same table, e.g. after a
+// restart, is kept, so its traffic is not interrupted.
+func (r *Router) ApplyVRFs(vrfs []policy.VRF) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if reflect.DeepEqual(r.vrfs, vrfs) {
+		return nil
+	}
+
+	want := make(map[string]policy.VRF, len(vrfs))
+	members := make(map[string]bool)
+	for _, v := range vrfs {
+		want[v.Name] = v
+		for _, n := range v.Interfaces {
+			members[n] = true
+		}
+	}
+
+	// Undo what the previous VRFs had that the new ones do not.
+	var cmds [][]string
+	for _, old := range r.vrfs {
+		v, ok := want[old.Name]
+		if !ok || v.Table != old.Table {
+			cmds = append(cmds, []string{"link", "del", "dev", old.Name},
+				[]string{"route", "flush", "table", strconv.Itoa(old.Table)})
+			continue
+		}
+		for _, n := range old.Interfaces {
+			if !members[n] {
+				cmds = append(cmds, []string{"link", "set", "dev", n, "nomaster"})
+			}
+		}
+		for _, rt := range old.Routes {
+			if !containsRoute(v.Routes, rt) {
+				cmds = append(cmds, routeCmd("del", rt, v.Table))
+			}
+		}
+	}
+	r.run(cmds, true)
+
+	cmds = nil
+	for _, v := range vrfs {
+		table := strconv.Itoa(v.Table)
+		if t, ok := r.vrfTable(v.Name); !ok || t != v.Table {
+			if ok {
+				cmds = append(cmds, []string{"link", "del", "dev", v.Name})
+			}
+			cmds = append(cmds, []string{"link", "add", "dev", v.Name, "type", "vrf", "table", table})
+		}
+		cmds = append(cmds, []string{"link", "set", "dev", v.Name, "up"})
+		for _, n := range v.Interfaces {
+			cmds = append(cmds, []string{"link", "set", "dev", n, "master", v.Name})
+		}
+		for _, rt := range v.Routes {
+			cmds = append(cmds, routeCmd("replace", rt, v.Table))
+		}
+	}
+	if err := r.run(cmds, false); err != nil {
+		return err
+	}
+	r.vrfs = vrfs
+	if len(vrfs) > 0 {
+		r.log.Info("vrfs applied", zap.Int("vrfs", len(vrfs)))
+	}
+	return nil
+}
+
+// routeCmd returns the ip arguments to add or remove rt in table.
+func routeCmd(op string, rt policy.VRFRoute, table int) []string {
+	var args []string
+	if strings.Contains(rt.Destination, ":") || strings.Contains(rt.Gateway, ":") {
+		args = append(args, "-6")
+	}
+	args = append(args, "route", op, rt.Destination)
+	if rt.Gateway != "" {
+		args = append(args, "via", rt.Gateway)
+	}
+	if rt.Interface != "" {
+		args = append(args, "dev", rt.Interface)
+	}
+	if rt.Metric != 0 {
+		args = append(args, "metric", strconv.Itoa(rt.Metric))
+	}
+	return append(args, "table", strconv.Itoa(table))
+}
+
+func containsRoute(routes []policy.VRFRoute, rt policy.VRFRoute) bool {
+	for _, r := range routes {
+		if r == rt {
+			return true
+		}
+	}
+	return false
+}
+
+// vrfTable returns the table of the VRF device name, and false if there is
+// no such device or it is not a VRF.
+func (r *Router) vrfTable(name string) (int, bool) {
+	if r.dryRun {
+		return 0, false
+	}
+	out, err := exec.Command("ip", "-j", "-d", "link", "show", "dev", name).Output()
+	if err != nil {
+		return 0, false
+	}
+	var links []struct {
+		LinkInfo struct {
+			Kind string `json:"info_kind"`
+			Data struct {
+				Table int `json:"table"`
+			} `json:"info_data"`
+		} `json:"linkinfo"`
+	}
+	if json.Unmarshal(out, &links) != nil || len(links) != 1 || links[0].LinkInfo.Kind != "vrf" {
+		return 0, false
+	}
+	return links[0].LinkInfo.Data.Table, true
+}
+
+// ─── Status ───────────────────────────────────────────────────────────────
+
+// VRFStatus is a VRF's configuration and the link state of its device.
+type VRFStatus struct {
+	Name       string            `json:"name"`
+	Table      int               `json:"table"`
+	Interfaces []string          `json:"interfaces"`
+	Routes     []policy.VRFRoute `json:"routes"`
+	Up         bool              `json:"up"`
+}
+
+// NewVRFStatus fills the link state of v from sysfs.
+func NewVRFStatus(v policy.VRF) VRFStatus {
+	oper, _ := os.ReadFile("/sys/class/net/" + v.Name + "/operstate")
+	state := strings.TrimSpace(string(oper))
+	routes := v.Routes
+	if routes == nil {
+		routes = []policy.VRFRoute{}
+	}
+	return VRFStatus{
+		Name:       v.Name,
+		Table:      v.Table,
+		Interfaces: v.Interfaces,
+		Routes:     routes,
+		Up:         state == "up" || state == "unknown",
+	}
+}
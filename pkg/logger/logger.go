@@ -5,9 +5,12 @@ import (
 
 	"go.uber.org/zap"
 	"go.uber.org/zap/zapcore"
+
+	"github.com/aegisx/aegisx/internal/redact"
 )
 
-// New creates a structured zap logger.
+// New creates a structured zap logger. Secrets in messages and fields are
+// redacted before they are written.
 func New(level, format string) (*zap.Logger, error) {
 	var zapLevel zapcore.Level
 	if err := zapLevel.UnmarshalText([]byte(level)); err != nil {
@@ -23,7 +26,7 @@ func New(level, format string) (*zap.Logger, error) {
 	}
 	cfg.Level = zap.NewAtomicLevelAt(zapLevel)
 
-	log, err := cfg.Build(zap.AddCallerSkip(0))
+	log, err := cfg.Build(zap.AddCallerSkip(0), zap.WrapCore(redact.Core))
 	if err != nil {
 		return nil, fmt.Errorf("build logger: %w", err)
 	}
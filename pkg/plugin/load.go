@@ -0,0 +1,45 @@
+package plugin
+
+import (
+	"fmt"
+	"path/filepath"
+	goplugin "plugin"
+)
+
+// LoadDir opens every *.so in dir and calls its Register function. Go
+// plugins must be built with the same Go version and module versions as the
+// AegisX binary, or Open fails.
+func (r *Registry) LoadDir(dir string) ([]string, error) {
+	paths, err := filepath.Glob(filepath.Join(dir, "*.so"))
+	if err != nil {
+		return nil, err
+	}
+	var loaded []string
+	for _, path := range paths {
+		if err := r.Load(path); err != nil {
+			return loaded, err
+		}
+		loaded = append(loaded, path)
+	}
+	return loaded, nil
+}
+
+// Load opens one plugin and calls its Register function.
+func (r *Registry) Load(path string) error {
+	p, err := goplugin.Open(path)
+	if err != nil {
+		return fmt.Errorf("open plugin %s: %w", path, err)
+	}
+	sym, err := p.Lookup(Symbol)
+	if err != nil {
+		return fmt.Errorf("plugin %s: %w", path, err)
+	}
+	register, ok := sym.(func(*Registry) error)
+	if !ok {
+		return fmt.Errorf("plugin %s: %s has type %T, want func(*plugin.Registry) error", path, Symbol, sym)
+	}
+	if err := register(r); err != nil {
+		return fmt.Errorf("plugin %s: register: %w", path, err)
+	}
+	return nil
+}
@@ -0,0 +1,81 @@
+// Package plugin is the extension API for AegisX. A plugin is a Go plugin
+// (built with -buildmode=plugin) that exports
+//
+//	func Register(r *plugin.Registry) error
+//
+// and uses it to add manifest kinds and dataplane backends. Only this package
+// is visible to plugins; the compiled IR reaches backends as JSON, so plugins
+// do not depend on AegisX internals.
+package plugin
+
+import (
+	"context"
+	"sync"
+)
+
+// Symbol is the function every plugin must export.
+const Symbol = "Register"
+
+// Metadata is the metadata block of a manifest.
+type Metadata struct {
+	Name        string            `json:"name"`
+	Namespace   string            `json:"namespace"`
+	Labels      map[string]string `json:"labels"`
+	Annotations map[string]string `json:"annotations"`
+}
+
+// Kind implements a manifest kind, e.g. a proprietary "SDWANPolicy".
+// Specs reach the plugin as decoded YAML (maps, slices and scalars).
+type Kind interface {
+	// Kind returns the manifest kind; it must not collide with a built-in one.
+	Kind() string
+	// Validate returns one message per problem in spec.
+	Validate(meta Metadata, spec map[string]any) []string
+	// Compile returns the plugin's IR fragment for one manifest. It must be
+	// JSON-serialisable; fragments are collected under IR.extensions[kind].
+	Compile(meta Metadata, spec map[string]any) (any, error)
+}
+
+// Backend programs a dataplane from the compiled IR.
+type Backend interface {
+	Name() string
+	// Apply receives the full IR as JSON after the built-in backends applied it.
+	Apply(ctx context.Context, ir []byte) error
+}
+
+// Registry collects what plugins register.
+type Registry struct {
+	mu       sync.Mutex
+	kinds    []Kind
+	backends []Backend
+}
+
+func NewRegistry() *Registry { return &Registry{} }
+
+// RegisterKind adds a manifest kind.
+func (r *Registry) RegisterKind(k Kind) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.kinds = append(r.kinds, k)
+}
+
+// RegisterBackend adds a dataplane backend.
+func (r *Registry) RegisterBackend(b Backend) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.backends = append(r.backends, b)
+}
+
+// Kinds returns the registered kinds.
+func (r *Registry) Kinds() []Kind {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return append([]Kind(nil), r.kinds...)
+}
+
+// Backends returns the registered backends.
+func (r *Registry) Backends() []Backend {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return append([]Backend(nil), r.backends...)
+}
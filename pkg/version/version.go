@@ -0,0 +1,125 @@
+// Package version holds the build metadata of the running binary. The
+// release build sets it with -ldflags:
+//
+//	-X github.com/aegisx/aegisx/pkg/version.Version=v1.2.3
+//	-X github.com/aegisx/aegisx/pkg/version.Commit=abc1234
+//	-X github.com/aegisx/aegisx/pkg/version.BuildTime=2024-01-01T00:00:00Z
+//
+// Plain `go build` inside a git checkout still reports the commit and its
+// time from the VCS stamp the toolchain embeds.
+package version
+
+import (
+	"context"
+	"os/exec"
+	"regexp"
+	"runtime"
+	"runtime/debug"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Set at link time.
+var (
+	Version   = "dev"
+	Commit    = "unknown"
+	BuildTime = "unknown"
+)
+
+// Info describes the build.
+type Info struct {
+	Version   string `json:"version"`
+	Commit    string `json:"gitCommit"`
+	BuildTime string `json:"buildTime"`
+	Modified  bool   `json:"modified,omitempty"` // built from a dirty tree
+	GoVersion string `json:"goVersion"`
+	OS        string `json:"os"`
+	Arch      string `json:"arch"`
+}
+
+var (
+	once sync.Once
+	info Info
+)
+
+// Get returns the build metadata, filling what the linker left unset from
+// the embedded VCS stamp.
+func Get() Info {
+	once.Do(func() {
+		info = Info{
+			Version:   Version,
+			Commit:    Commit,
+			BuildTime: BuildTime,
+			GoVersion: runtime.Version(),
+			OS:        runtime.GOOS,
+			Arch:      runtime.GOARCH,
+		}
+		bi, ok := debug.ReadBuildInfo()
+		if !ok {
+			return
+		}
+		for _, s := range bi.Settings {
+			switch s.Key {
+			case "vcs.revision":
+				if info.Commit == "unknown" {
+					info.Commit = s.Value
+				}
+			case "vcs.time":
+				if info.BuildTime == "unknown" {
+					info.BuildTime = s.Value
+				}
+			case "vcs.modified":
+				info.Modified = s.Value == "true"
+			}
+		}
+		if len(info.Commit) > 12 {
+			info.Commit = info.Commit[:12]
+		}
+	})
+	return info
+}
+
+// Dataplane components whose version Backends can report, with the command
+// that prints it.
+var backendCommands = map[string][]string{
+	"nftables":  {"nft", "--version"},
+	"haproxy":   {"haproxy", "-v"},
+	"suricata":  {"suricata", "-V"},
+	"wireguard": {"wg", "--version"},
+}
+
+var versionRe = regexp.MustCompile(`\d+\.\d+(\.\d+)*`)
+
+// Backends returns the version of each named dataplane component, or
+// "unavailable" when its tool is missing or does not answer in time.
+func Backends(ctx context.Context, names ...string) map[string]string {
+	ctx, cancel := context.WithTimeout(ctx, 3*time.Second)
+	defer cancel()
+
+	out := make(map[string]string, len(names))
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+	for _, name := range names {
+		cmd, ok := backendCommands[name]
+		if !ok {
+			continue
+		}
+		wg.Add(1)
+		go func(name string, cmd []string) {
+			defer wg.Done()
+			v := "unavailable"
+			if b, err := exec.CommandContext(ctx, cmd[0], cmd[1:]...).CombinedOutput(); err == nil {
+				first, _, _ := strings.Cut(string(b), "\n")
+				if m := versionRe.FindString(first); m != "" {
+					v = m
+				}
+			}
+			mu.Lock()
+			out[name] = v
+			mu.Unlock()
+		}(name, cmd)
+	}
+	wg.Wait()
+	return out
+}